@@ -36,7 +36,10 @@ func main() {
 		log.Fatalf("数据库迁移失败: %v", err)
 	}
 	// 初始化服务
-	services := service.NewServices(db, cfg)
+	services, err := service.NewServices(db, cfg)
+	if err != nil {
+		log.Fatalf("初始化服务失败: %v", err)
+	}
 
 	// 初始化系统配置和数据
 	if err := services.Bootstrap.InitializeSystem(); err != nil {
@@ -45,6 +48,11 @@ func main() {
 		log.Println("系统配置和初始数据加载成功")
 	}
 
+	// 恢复充电桩注册的指令下发地址
+	if err := services.PileRegistry.LoadEndpoints(); err != nil {
+		log.Printf("加载充电桩注册信息失败: %v", err)
+	}
+
 	// 初始化路由
 	router := api.SetupRouter(services, cfg)
 
@@ -0,0 +1,254 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/google/uuid"
+
+	"backend/internal/middleware"
+	"backend/internal/model"
+	"backend/internal/service"
+)
+
+// AdminUserHandler 管理端用户处理器
+type AdminUserHandler struct {
+	userService            *service.UserService
+	chargingRequestService *service.ChargingRequestService
+	billingService         *service.BillingService
+}
+
+// NewAdminUserHandler 创建管理端用户处理器
+func NewAdminUserHandler(userService *service.UserService, chargingRequestService *service.ChargingRequestService, billingService *service.BillingService) *AdminUserHandler {
+	return &AdminUserHandler{
+		userService:            userService,
+		chargingRequestService: chargingRequestService,
+		billingService:         billingService,
+	}
+}
+
+// ListUsers 查询用户列表
+func (h *AdminUserHandler) ListUsers(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	filter := &model.UserFilter{
+		Keyword:  query.Get("keyword"),
+		UserType: model.UserType(query.Get("userType")),
+		Status:   query.Get("status"),
+	}
+	if filter.UserType != "" && !model.IsValidUserType(filter.UserType) {
+		http.Error(w, "无效的角色", http.StatusBadRequest)
+		return
+	}
+	if filter.Status != "" && filter.Status != "active" && filter.Status != "disabled" {
+		http.Error(w, "无效的账户状态", http.StatusBadRequest)
+		return
+	}
+
+	// 解析分页参数
+	page, _ := strconv.Atoi(query.Get("page"))
+	if page < 1 {
+		page = 1
+	}
+	pageSize, _ := strconv.Atoi(query.Get("pageSize"))
+	if pageSize < 1 {
+		pageSize = 10
+	}
+
+	users, total, err := h.userService.GetUsers(filter, page, pageSize)
+	if err != nil {
+		http.Error(w, "获取用户列表失败: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	response := model.Response{
+		Code:    200,
+		Message: "success",
+		Data: map[string]any{
+			"total":    total,
+			"page":     page,
+			"pageSize": pageSize,
+			"users":    users,
+		},
+		Timestamp: model.NowTimestamp(),
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
+
+// GetUserActivity 获取用户活动概览
+func (h *AdminUserHandler) GetUserActivity(w http.ResponseWriter, r *http.Request) {
+	userID, err := uuid.Parse(r.PathValue("userId"))
+	if err != nil {
+		http.Error(w, "无效的用户ID", http.StatusBadRequest)
+		return
+	}
+
+	user, err := h.userService.GetUserByID(userID)
+	if err != nil {
+		http.Error(w, "获取用户信息失败: "+err.Error(), http.StatusNotFound)
+		return
+	}
+
+	activity := &model.UserActivity{User: user}
+
+	// 当前活跃请求
+	if activeReq, err := h.chargingRequestService.GetActiveRequestByUserID(userID); err == nil {
+		activity.ActiveRequest = activeReq
+	}
+
+	// 最近的充电请求
+	activity.Requests, activity.TotalRequests, err = h.chargingRequestService.GetUserRequests(userID, 1, 10)
+	if err != nil {
+		http.Error(w, "获取充电请求失败: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// 最近的账单及累计消费
+	activity.Bills, activity.TotalBills, err = h.billingService.GetUserBills(userID, nil, nil, 1, 10)
+	if err != nil {
+		http.Error(w, "获取账单失败: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	activity.TotalFee, err = h.billingService.GetUserTotalFee(userID)
+	if err != nil {
+		http.Error(w, "获取累计消费失败: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// 活跃登录会话
+	activity.Sessions, err = h.userService.GetUserSessions(userID, uuid.Nil)
+	if err != nil {
+		http.Error(w, "获取会话列表失败: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	response := model.Response{
+		Code:      200,
+		Message:   "success",
+		Data:      activity,
+		Timestamp: model.NowTimestamp(),
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
+
+// DisableUserRequest 禁用用户请求
+type DisableUserRequest struct {
+	Reason string `json:"reason"`
+}
+
+// DisableUser 禁用用户
+func (h *AdminUserHandler) DisableUser(w http.ResponseWriter, r *http.Request) {
+	operator, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "未授权访问", http.StatusUnauthorized)
+		return
+	}
+
+	userID, err := uuid.Parse(r.PathValue("userId"))
+	if err != nil {
+		http.Error(w, "无效的用户ID", http.StatusBadRequest)
+		return
+	}
+
+	var req DisableUserRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "无效的请求参数", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.userService.DisableUser(operator.ID, userID, req.Reason); err != nil {
+		http.Error(w, "禁用用户失败: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	response := model.Response{
+		Code:    200,
+		Message: "用户已禁用",
+		Data: map[string]any{
+			"userId": userID.String(),
+			"status": "disabled",
+		},
+		Timestamp: model.NowTimestamp(),
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
+
+// EnableUser 启用用户
+func (h *AdminUserHandler) EnableUser(w http.ResponseWriter, r *http.Request) {
+	operator, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "未授权访问", http.StatusUnauthorized)
+		return
+	}
+
+	userID, err := uuid.Parse(r.PathValue("userId"))
+	if err != nil {
+		http.Error(w, "无效的用户ID", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.userService.EnableUser(operator.ID, userID); err != nil {
+		http.Error(w, "启用用户失败: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	response := model.Response{
+		Code:    200,
+		Message: "用户已启用",
+		Data: map[string]any{
+			"userId": userID.String(),
+			"status": "active",
+		},
+		Timestamp: model.NowTimestamp(),
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
+
+// CreatePasswordResetToken 生成密码重置令牌
+func (h *AdminUserHandler) CreatePasswordResetToken(w http.ResponseWriter, r *http.Request) {
+	operator, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "未授权访问", http.StatusUnauthorized)
+		return
+	}
+
+	userID, err := uuid.Parse(r.PathValue("userId"))
+	if err != nil {
+		http.Error(w, "无效的用户ID", http.StatusBadRequest)
+		return
+	}
+
+	token, expiresAt, err := h.userService.CreatePasswordResetToken(operator.ID, userID)
+	if err != nil {
+		http.Error(w, "生成重置令牌失败: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	response := model.Response{
+		Code:    200,
+		Message: "重置令牌已生成",
+		Data: map[string]any{
+			"userId":     userID.String(),
+			"resetToken": token,
+			"expiresAt":  expiresAt,
+		},
+		Timestamp: model.NowTimestamp(),
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
@@ -100,6 +100,7 @@ func (h *BillingHandler) GetBillingDetails(w http.ResponseWriter, r *http.Reques
 			"serviceFee":       detail.ServiceFee,
 			"totalFee":         detail.TotalFee,
 		}
+		addBillSoCFields(detailData, detail)
 		detailsData = append(detailsData, detailData)
 	}
 
@@ -151,7 +152,7 @@ func (h *BillingHandler) GetBillingDetailByID(w http.ResponseWriter, r *http.Req
 	}
 
 	// 检查权限
-	if detail.UserID != user.ID && user.UserType != model.UserTypeAdmin {
+	if detail.UserID != user.ID && !model.HasPermission(user.UserType, model.PermBillingView) {
 		http.Error(w, "权限不足", http.StatusForbidden)
 		return
 	}
@@ -172,6 +173,7 @@ func (h *BillingHandler) GetBillingDetailByID(w http.ResponseWriter, r *http.Req
 		"totalFee":         detail.TotalFee,
 		"priceType":        detail.PriceType,
 	}
+	addBillSoCFields(detailData, detail)
 
 	response := model.Response{
 		Code:      200,
@@ -258,3 +260,69 @@ func (h *BillingHandler) CalculateChargingFee(w http.ResponseWriter, r *http.Req
 	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(response)
 }
+
+// GetBillingStatistics 获取账单统计（管理端）
+func (h *BillingHandler) GetBillingStatistics(w http.ResponseWriter, r *http.Request) {
+	startDateStr := r.URL.Query().Get("startDate")
+	endDateStr := r.URL.Query().Get("endDate")
+	pileIDStr := r.URL.Query().Get("pileId")
+
+	// 解析日期参数
+	var startDate, endDate time.Time
+	var err error
+
+	if startDateStr == "" {
+		// 默认开始日期为30天前
+		startDate = time.Now().UTC().AddDate(0, 0, -30)
+	} else {
+		startDate, err = time.Parse("2006-01-02", startDateStr)
+		if err != nil {
+			http.Error(w, "开始日期格式错误", http.StatusBadRequest)
+			return
+		}
+	}
+
+	if endDateStr == "" {
+		endDate = time.Now().UTC()
+	} else {
+		endDate, err = time.Parse("2006-01-02", endDateStr)
+		if err != nil {
+			http.Error(w, "结束日期格式错误", http.StatusBadRequest)
+			return
+		}
+	}
+	// 设置为当天的结束时间 23:59:59
+	endDate = time.Date(endDate.Year(), endDate.Month(), endDate.Day(), 23, 59, 59, 999999999, endDate.Location())
+
+	var pileID *string
+	if pileIDStr != "" {
+		pileID = &pileIDStr
+	}
+
+	stats, err := h.billingService.GetBillingStatistics(startDate, endDate, pileID)
+	if err != nil {
+		http.Error(w, "获取账单统计失败: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	response := model.Response{
+		Code:      200,
+		Message:   "success",
+		Data:      stats,
+		Timestamp: model.NowTimestamp(),
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
+
+// addBillSoCFields 在详单数据中附加起止SoC
+func addBillSoCFields(data map[string]any, detail *model.BillingDetail) {
+	if detail.StartSoC != nil {
+		data["startSoc"] = *detail.StartSoC
+	}
+	if detail.EndSoC != nil {
+		data["endSoc"] = *detail.EndSoC
+	}
+}
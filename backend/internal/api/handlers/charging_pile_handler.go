@@ -2,6 +2,7 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
 
 	"backend/internal/model"
@@ -11,12 +12,14 @@ import (
 // ChargingPileHandler 充电桩处理器
 type ChargingPileHandler struct {
 	chargingPileService *service.ChargingPileService
+	schedulerService    *service.SchedulerService
 }
 
 // NewChargingPileHandler 创建充电桩处理器
-func NewChargingPileHandler(chargingPileService *service.ChargingPileService) *ChargingPileHandler {
+func NewChargingPileHandler(chargingPileService *service.ChargingPileService, schedulerService *service.SchedulerService) *ChargingPileHandler {
 	return &ChargingPileHandler{
 		chargingPileService: chargingPileService,
+		schedulerService:    schedulerService,
 	}
 }
 
@@ -46,9 +49,10 @@ func (h *ChargingPileHandler) GetAllPiles(w http.ResponseWriter, r *http.Request
 	for _, pile := range fastPiles {
 		// 处理快充桩数据
 		fastPilesData = append(fastPilesData, map[string]any{
-			"pileId": pile.ID,
-			"status": pile.Status,
-			"power":  pile.Power,
+			"pileId":         pile.ID,
+			"status":         pile.Status,
+			"power":          pile.Power,
+			"effectivePower": pile.EffectivePower(), // 降功率运行时低于额定功率
 		})
 	}
 
@@ -56,9 +60,10 @@ func (h *ChargingPileHandler) GetAllPiles(w http.ResponseWriter, r *http.Request
 	for _, pile := range slowPiles {
 		// 处理慢充桩数据
 		slowPilesData = append(slowPilesData, map[string]any{
-			"pileId": pile.ID,
-			"status": pile.Status,
-			"power":  pile.Power,
+			"pileId":         pile.ID,
+			"status":         pile.Status,
+			"power":          pile.Power,
+			"effectivePower": pile.EffectivePower(), // 降功率运行时低于额定功率
 		})
 	}
 
@@ -83,6 +88,43 @@ type ControlPileRequest struct {
 	Reason string `json:"reason"`
 }
 
+// SetOCPPPasswordRequest 设置OCPP连接密码请求
+type SetOCPPPasswordRequest struct {
+	Password string `json:"password"`
+}
+
+// SetOCPPPassword 设置充电桩的OCPP连接密码（管理员）
+func (h *ChargingPileHandler) SetOCPPPassword(w http.ResponseWriter, r *http.Request) {
+	var req SetOCPPPasswordRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "无效的请求参数", http.StatusBadRequest)
+		return
+	}
+
+	pileID := r.PathValue("pileId")
+	if err := h.chargingPileService.SetOCPPPassword(pileID, req.Password); err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, service.ErrInvalidOCPPPassword) {
+			status = http.StatusBadRequest
+		}
+		http.Error(w, "设置OCPP连接密码失败: "+err.Error(), status)
+		return
+	}
+
+	response := model.Response{
+		Code:    200,
+		Message: "OCPP连接密码已设置",
+		Data: map[string]any{
+			"pileId": pileID,
+		},
+		Timestamp: model.NowTimestamp(),
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
+
 // ControlPile 控制充电桩（管理员）
 func (h *ChargingPileHandler) ControlPile(w http.ResponseWriter, r *http.Request) {
 	// 获取路径参数
@@ -114,6 +156,14 @@ func (h *ChargingPileHandler) ControlPile(w http.ResponseWriter, r *http.Request
 		newStatus = model.PileStatusMaintenance
 	}
 
+	// 进入维护前转移排队车辆并停止正在充电的会话
+	if newStatus == model.PileStatusMaintenance {
+		if err := h.schedulerService.EnterMaintenance(pileID, req.Reason); err != nil {
+			http.Error(w, "充电桩进入维护失败: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+
 	// 更新充电桩状态
 	err := h.chargingPileService.UpdatePileStatus(pileID, newStatus)
 	if err != nil {
@@ -187,10 +237,11 @@ func (h *ChargingPileHandler) GetQueueVehicles(w http.ResponseWriter, r *http.Re
 
 		// 整理该充电桩的基本信息
 		pileInfo := map[string]any{
-			"pileId": pile.ID,
-			"type":   pileType,
-			"status": pile.Status,
-			"power":  pile.Power,
+			"pileId":         pile.ID,
+			"type":           pileType,
+			"status":         pile.Status,
+			"power":          pile.Power,
+			"effectivePower": pile.EffectivePower(),
 		}
 
 		// 整理等候车辆信息
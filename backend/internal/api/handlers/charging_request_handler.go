@@ -2,6 +2,7 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
 
 	"backend/internal/middleware"
@@ -28,9 +29,22 @@ func NewChargingRequestHandler(chargingRequestService *service.ChargingRequestSe
 
 // CreateRequestRequest 创建请求请求参数
 type CreateRequestRequest struct {
-	ChargingMode      string  `json:"chargingMode"` // 充电模式：fast|slow
-	RequestedCapacity float64 `json:"requestedCapacity"`
-	Urgency           string  `json:"urgency,omitempty"` // 紧急程度：normal|urgent
+	VehicleID         string   `json:"vehicleId,omitempty"` // 充电车辆，只有一辆车时可省略
+	ChargingMode      string   `json:"chargingMode"`        // 充电模式：fast|slow，省略时使用车辆默认模式
+	RequestedCapacity float64  `json:"requestedCapacity"`   // 请求充电量(度)，与SoC二选一
+	StartSoC          *float64 `json:"startSoc,omitempty"`  // 当前电量百分比
+	TargetSoC         *float64 `json:"targetSoc,omitempty"` // 目标电量百分比
+	Urgency           string   `json:"urgency,omitempty"`   // 紧急程度：normal|urgent
+}
+
+// addSoCFields 在响应数据中附加SoC信息
+func addSoCFields(data map[string]any, request *model.ChargingRequest) {
+	if request.StartSoC != nil {
+		data["startSoc"] = *request.StartSoC
+	}
+	if request.TargetSoC != nil {
+		data["targetSoc"] = *request.TargetSoC
+	}
 }
 
 // CreateRequest 创建充电请求
@@ -49,20 +63,38 @@ func (h *ChargingRequestHandler) CreateRequest(w http.ResponseWriter, r *http.Re
 	}
 
 	// 验证参数
-	if req.ChargingMode != "fast" && req.ChargingMode != "slow" {
+	if req.ChargingMode != "" && req.ChargingMode != "fast" && req.ChargingMode != "slow" {
 		http.Error(w, "充电模式无效", http.StatusBadRequest)
 		return
 	}
 
-	if req.RequestedCapacity <= 0 {
+	hasSoC := req.StartSoC != nil && req.TargetSoC != nil
+	if (req.StartSoC == nil) != (req.TargetSoC == nil) {
+		http.Error(w, "当前电量和目标电量需同时提供", http.StatusBadRequest)
+		return
+	}
+	if !hasSoC && req.RequestedCapacity <= 0 {
 		http.Error(w, "请求充电量必须大于0", http.StatusBadRequest)
 		return
 	}
 
+	var vehicleID uuid.UUID
+	if req.VehicleID != "" {
+		var err error
+		vehicleID, err = uuid.Parse(req.VehicleID)
+		if err != nil {
+			http.Error(w, "无效的车辆ID", http.StatusBadRequest)
+			return
+		}
+	}
+
 	// 创建充电请求对象
 	createReq := &model.ChargingRequestCreate{
+		VehicleID:         vehicleID,
 		ChargingMode:      model.ChargingMode(req.ChargingMode),
 		RequestedCapacity: req.RequestedCapacity,
+		StartSoC:          req.StartSoC,
+		TargetSoC:         req.TargetSoC,
 	}
 
 	// 提交充电请求
@@ -79,15 +111,27 @@ func (h *ChargingRequestHandler) CreateRequest(w http.ResponseWriter, r *http.Re
 		"waitingPosition":   request.QueuePosition,
 	}
 
+	data := map[string]any{
+		"requestId":         request.ID.String(),
+		"vehicleId":         request.VehicleID.String(),
+		"chargingMode":      request.ChargingMode,
+		"requestedCapacity": request.RequestedCapacity,
+		"queueNumber":       queueInfo["queueNumber"],
+		"estimatedWaitTime": queueInfo["estimatedWaitTime"],
+		"waitingPosition":   queueInfo["waitingPosition"],
+		"status":            request.Status,
+	}
+	addSoCFields(data, request)
+
+	message := "充电请求提交成功"
+	if request.Status == model.RequestStatusWaitlisted {
+		message = "等候区已满，已加入候补队列"
+	}
+
 	response := model.Response{
-		Code:    200,
-		Message: "充电请求提交成功",
-		Data: map[string]any{
-			"requestId":         request.ID.String(),
-			"queueNumber":       queueInfo["queueNumber"],
-			"estimatedWaitTime": queueInfo["estimatedWaitTime"],
-			"waitingPosition":   queueInfo["waitingPosition"],
-		},
+		Code:      200,
+		Message:   message,
+		Data:      data,
 		Timestamp: model.NowTimestamp(),
 	}
 
@@ -141,6 +185,11 @@ func (h *ChargingRequestHandler) UpdateRequest(w http.ResponseWriter, r *http.Re
 		updateReq.RequestedCapacity = req.RequestedCapacity
 	}
 
+	// 判断目标电量
+	if req.TargetSoC != nil {
+		updateReq.TargetSoC = req.TargetSoC
+	}
+
 	// 更新充电请求
 	request, err := h.chargingRequestService.UpdateRequest(user.ID, requestID, updateReq)
 	if err != nil {
@@ -160,6 +209,8 @@ func (h *ChargingRequestHandler) UpdateRequest(w http.ResponseWriter, r *http.Re
 		Message: "充电请求修改成功",
 		Data: map[string]any{
 			"requestId":         request.ID.String(),
+			"requestedCapacity": request.RequestedCapacity,
+			"targetSoc":         request.TargetSoC,
 			"queueNumber":       queueInfo["queueNumber"],
 			"estimatedWaitTime": queueInfo["estimatedWaitTime"],
 			"waitingPosition":   queueInfo["waitingPosition"],
@@ -223,6 +274,85 @@ func (h *ChargingRequestHandler) CancelRequest(w http.ResponseWriter, r *http.Re
 	json.NewEncoder(w).Encode(response)
 }
 
+// CheckOut 确认车辆驶离充电桩，驶离后充电桩才可为下一辆车充电
+func (h *ChargingRequestHandler) CheckOut(w http.ResponseWriter, r *http.Request) {
+	// 从上下文获取用户
+	user, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "未授权访问", http.StatusUnauthorized)
+		return
+	}
+
+	requestID, err := uuid.Parse(r.PathValue("requestId"))
+	if err != nil {
+		http.Error(w, "无效的充电请求ID", http.StatusBadRequest)
+		return
+	}
+
+	departure, err := h.chargingRequestService.CheckOut(user.ID, requestID)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, service.ErrNoParkedVehicle) || errors.Is(err, service.ErrVehicleStillCharging) {
+			status = http.StatusConflict
+		}
+		http.Error(w, "确认驶离失败: "+err.Error(), status)
+		return
+	}
+
+	response := model.Response{
+		Code:      200,
+		Message:   "车辆已驶离",
+		Data:      departure,
+		Timestamp: model.NowTimestamp(),
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
+
+// CheckIn 候补请求获得入场资格后到场签到
+func (h *ChargingRequestHandler) CheckIn(w http.ResponseWriter, r *http.Request) {
+	// 从上下文获取用户
+	user, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "未授权访问", http.StatusUnauthorized)
+		return
+	}
+
+	requestID, err := uuid.Parse(r.PathValue("requestId"))
+	if err != nil {
+		http.Error(w, "无效的充电请求ID", http.StatusBadRequest)
+		return
+	}
+
+	request, err := h.chargingRequestService.CheckIn(user.ID, requestID)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, service.ErrNotAdmitted) || errors.Is(err, service.ErrArrivalExpired) {
+			status = http.StatusConflict
+		}
+		http.Error(w, "到场签到失败: "+err.Error(), status)
+		return
+	}
+
+	response := model.Response{
+		Code:    200,
+		Message: "签到成功，已进入等候区",
+		Data: map[string]any{
+			"requestId":    request.ID.String(),
+			"chargingMode": request.ChargingMode,
+			"queueNumber":  request.QueueNumber,
+			"status":       request.Status,
+		},
+		Timestamp: model.NowTimestamp(),
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
+
 // GetRequestByID 获取指定充电请求
 func (h *ChargingRequestHandler) GetRequestByID(w http.ResponseWriter, r *http.Request) {
 	// 从上下文获取用户
@@ -253,7 +383,7 @@ func (h *ChargingRequestHandler) GetRequestByID(w http.ResponseWriter, r *http.R
 	}
 
 	// 检查权限 (使用UserType而不是Role)
-	if request.UserID != user.ID && user.UserType != model.UserTypeAdmin {
+	if request.UserID != user.ID && !model.HasPermission(user.UserType, model.PermQueueView) {
 		http.Error(w, "无权访问该充电请求", http.StatusForbidden)
 		return
 	}
@@ -261,12 +391,14 @@ func (h *ChargingRequestHandler) GetRequestByID(w http.ResponseWriter, r *http.R
 	// 构建响应数据
 	requestData := map[string]any{
 		"requestId":         request.ID.String(),
+		"vehicleId":         request.VehicleID.String(),
 		"status":            request.Status,
 		"requestedCapacity": request.RequestedCapacity,
 		"queueNumber":       request.QueueNumber,
 		"queuePosition":     request.QueuePosition,
 		"estimatedWaitTime": request.EstimatedWaitTime,
 	}
+	addSoCFields(requestData, request)
 
 	if request.PileID != "" {
 		requestData["chargingPileId"] = request.PileID
@@ -312,12 +444,14 @@ func (h *ChargingRequestHandler) GetRequests(w http.ResponseWriter, r *http.Requ
 	for _, req := range requests {
 		requestData := map[string]any{
 			"requestId":         req.ID.String(),
+			"vehicleId":         req.VehicleID.String(),
 			"status":            req.Status,
 			"requestedCapacity": req.RequestedCapacity,
 			"queueNumber":       req.QueueNumber,
 			"createdAt":         req.CreatedAt,
 			"updatedAt":         req.UpdatedAt,
 		}
+		addSoCFields(requestData, req)
 		requestsData = append(requestsData, requestData)
 	}
 
@@ -368,6 +502,7 @@ func (h *ChargingRequestHandler) GetLatestRequest(w http.ResponseWriter, r *http
 	// 构建响应数据
 	requestData := map[string]any{
 		"requestId":         request.ID.String(),
+		"vehicleId":         request.VehicleID.String(),
 		"status":            request.Status,
 		"requestedCapacity": request.RequestedCapacity,
 		"queueNumber":       request.QueueNumber,
@@ -376,6 +511,7 @@ func (h *ChargingRequestHandler) GetLatestRequest(w http.ResponseWriter, r *http
 		"createdAt":         request.CreatedAt,
 		"updatedAt":         request.UpdatedAt,
 	}
+	addSoCFields(requestData, request)
 
 	if request.PileID != "" {
 		requestData["chargingPileId"] = request.PileID
@@ -386,6 +522,15 @@ func (h *ChargingRequestHandler) GetLatestRequest(w http.ResponseWriter, r *http
 		session, err := h.sessionRepo.GetByRequestID(request.ID)
 		if err == nil && session != nil {
 			requestData["actualCapacity"] = session.ActualCapacity
+			if session.CurrentSoC != nil {
+				requestData["currentSoc"] = *session.CurrentSoC
+			}
+			if session.CurrentPower != nil {
+				requestData["currentPower"] = *session.CurrentPower
+			}
+			if session.RemainingTime != nil {
+				requestData["remainingTime"] = *session.RemainingTime
+			}
 		}
 	}
 
@@ -0,0 +1,88 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"backend/internal/model"
+	"backend/internal/service"
+)
+
+// CommandHandler 充电指令与对账处理器
+type CommandHandler struct {
+	commandQueueService *service.CommandQueueService
+	reconcilerService   *service.ReconcilerService
+}
+
+// NewCommandHandler 创建充电指令与对账处理器
+func NewCommandHandler(commandQueueService *service.CommandQueueService, reconcilerService *service.ReconcilerService) *CommandHandler {
+	return &CommandHandler{
+		commandQueueService: commandQueueService,
+		reconcilerService:   reconcilerService,
+	}
+}
+
+// GetStuckCommands 查询卡住的充电指令
+// 包括投递失败仍在重试的指令，以及近24小时内超时转故障处理的指令
+func (h *CommandHandler) GetStuckCommands(w http.ResponseWriter, r *http.Request) {
+	commands, err := h.commandQueueService.GetStuckCommands()
+	if err != nil {
+		http.Error(w, "获取充电指令失败: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if commands == nil {
+		commands = []*model.PileCommand{}
+	}
+
+	response := model.Response{
+		Code:    200,
+		Message: "success",
+		Data: map[string]any{
+			"total":    len(commands),
+			"commands": commands,
+		},
+		Timestamp: model.NowTimestamp(),
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
+
+// GetReconciliationAlerts 查询对账告警
+func (h *CommandHandler) GetReconciliationAlerts(w http.ResponseWriter, r *http.Request) {
+	unresolvedOnly := r.URL.Query().Get("unresolved") == "true"
+
+	// 解析分页参数
+	page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+	if page < 1 {
+		page = 1
+	}
+	pageSize, _ := strconv.Atoi(r.URL.Query().Get("pageSize"))
+	if pageSize < 1 {
+		pageSize = 10
+	}
+
+	alerts, total, err := h.reconcilerService.GetAlerts(unresolvedOnly, page, pageSize)
+	if err != nil {
+		http.Error(w, "获取对账告警失败: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	response := model.Response{
+		Code:    200,
+		Message: "success",
+		Data: map[string]any{
+			"total":    total,
+			"page":     page,
+			"pageSize": pageSize,
+			"alerts":   alerts,
+		},
+		Timestamp: model.NowTimestamp(),
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
@@ -0,0 +1,182 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"backend/internal/middleware"
+	"backend/internal/model"
+	"backend/internal/service"
+)
+
+// FaultHandler 故障维修处理器
+type FaultHandler struct {
+	systemService       *service.SystemService
+	chargingPileService *service.ChargingPileService
+	schedulerService    *service.SchedulerService
+	faultTicketService  *service.FaultTicketService
+}
+
+// NewFaultHandler 创建故障维修处理器
+func NewFaultHandler(systemService *service.SystemService, chargingPileService *service.ChargingPileService, schedulerService *service.SchedulerService, faultTicketService *service.FaultTicketService) *FaultHandler {
+	return &FaultHandler{
+		systemService:       systemService,
+		chargingPileService: chargingPileService,
+		schedulerService:    schedulerService,
+		faultTicketService:  faultTicketService,
+	}
+}
+
+// GetFaultRecords 查询故障记录
+func (h *FaultHandler) GetFaultRecords(w http.ResponseWriter, r *http.Request) {
+	// 获取查询参数
+	startDateStr := r.URL.Query().Get("startDate")
+	endDateStr := r.URL.Query().Get("endDate")
+	pileIDStr := r.URL.Query().Get("pileId")
+
+	// 解析日期参数
+	var startDate, endDate time.Time
+	var err error
+
+	if startDateStr == "" {
+		// 默认开始日期为30天前
+		startDate = time.Now().UTC().AddDate(0, 0, -30)
+	} else {
+		startDate, err = time.Parse("2006-01-02", startDateStr)
+		if err != nil {
+			http.Error(w, "开始日期格式错误", http.StatusBadRequest)
+			return
+		}
+	}
+
+	if endDateStr == "" {
+		endDate = time.Now().UTC()
+	} else {
+		endDate, err = time.Parse("2006-01-02", endDateStr)
+		if err != nil {
+			http.Error(w, "结束日期格式错误", http.StatusBadRequest)
+			return
+		}
+	}
+	// 设置为当天23:59:59
+	endDate = time.Date(endDate.Year(), endDate.Month(), endDate.Day(), 23, 59, 59, 999999999, endDate.Location())
+
+	var pileID *string
+	if pileIDStr != "" {
+		pileID = &pileIDStr
+	}
+
+	// 解析分页参数
+	page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+	if page < 1 {
+		page = 1
+	}
+	pageSize, _ := strconv.Atoi(r.URL.Query().Get("pageSize"))
+	if pageSize < 1 {
+		pageSize = 10
+	}
+
+	records, total, err := h.systemService.GetFaultRecords(startDate, endDate, pileID, page, pageSize)
+	if err != nil {
+		http.Error(w, "获取故障记录失败: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	response := model.Response{
+		Code:    200,
+		Message: "success",
+		Data: map[string]any{
+			"total":    total,
+			"page":     page,
+			"pageSize": pageSize,
+			"records":  records,
+		},
+		Timestamp: model.NowTimestamp(),
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
+
+// RepairPileRequest 维修完成请求，请求体可省略
+type RepairPileRequest struct {
+	RecoveryNotes string `json:"recoveryNotes"` // 为空时使用工单的恢复说明
+	Override      bool   `json:"override"`      // 故障工单未修复时强制恢复，需要充电桩控制权限
+	Reason        string `json:"reason"`        // 强制恢复原因
+}
+
+// RepairPile 维修完成，恢复充电桩；故障工单未修复时需运营人员强制恢复
+func (h *FaultHandler) RepairPile(w http.ResponseWriter, r *http.Request) {
+	user, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "未授权访问", http.StatusUnauthorized)
+		return
+	}
+
+	pileID := r.PathValue("pileId")
+	if pileID == "" {
+		http.Error(w, "充电桩ID不能为空", http.StatusBadRequest)
+		return
+	}
+
+	var req RepairPileRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
+		http.Error(w, "无效的请求参数", http.StatusBadRequest)
+		return
+	}
+
+	if req.Override {
+		if !model.HasPermission(user.UserType, model.PermPileControl) {
+			http.Error(w, "权限不足: 强制恢复需要 "+string(model.PermPileControl)+" 权限", http.StatusForbidden)
+			return
+		}
+		if req.Reason == "" {
+			http.Error(w, "强制恢复原因不能为空", http.StatusBadRequest)
+			return
+		}
+	} else if err := h.faultTicketService.CheckRecoverable(pileID); err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, service.ErrTicketNotResolved) {
+			status = http.StatusConflict
+		}
+		http.Error(w, "维修充电桩失败: "+err.Error(), status)
+		return
+	}
+
+	// 关闭故障记录
+	if err := h.chargingPileService.RepairPile(pileID, req.RecoveryNotes); err != nil {
+		http.Error(w, "维修充电桩失败: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// 恢复调度
+	var err error
+	if req.Override {
+		err = h.schedulerService.OverridePileRecovery(user.ID, pileID, req.Reason)
+	} else {
+		err = h.schedulerService.HandlePileRecovery(pileID)
+	}
+	if err != nil {
+		http.Error(w, "处理故障恢复失败: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	response := model.Response{
+		Code:    200,
+		Message: "充电桩已修复，正在重新调度",
+		Data: map[string]any{
+			"pileId": pileID,
+			"status": model.PileStatusAvailable,
+		},
+		Timestamp: model.NowTimestamp(),
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
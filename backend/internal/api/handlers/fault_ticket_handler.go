@@ -0,0 +1,249 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"backend/internal/middleware"
+	"backend/internal/model"
+	"backend/internal/service"
+
+	"github.com/google/uuid"
+)
+
+// FaultTicketHandler 故障工单处理器
+type FaultTicketHandler struct {
+	faultTicketService *service.FaultTicketService
+}
+
+// NewFaultTicketHandler 创建故障工单处理器
+func NewFaultTicketHandler(faultTicketService *service.FaultTicketService) *FaultTicketHandler {
+	return &FaultTicketHandler{
+		faultTicketService: faultTicketService,
+	}
+}
+
+// faultTicketErrorStatus 工单不存在返回404，操作无效返回409，其余错误返回500
+func faultTicketErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, service.ErrTicketNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, service.ErrInvalidTicketOperation):
+		return http.StatusConflict
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// writeFaultTicketResponse 写回工单操作成功的响应
+func writeFaultTicketResponse(w http.ResponseWriter, message string, data any) {
+	response := model.Response{
+		Code:      200,
+		Message:   message,
+		Data:      data,
+		Timestamp: model.NowTimestamp(),
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
+
+// GetTickets 查询故障工单
+func (h *FaultTicketHandler) GetTickets(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	filter := &model.FaultTicketFilter{
+		Status:      model.FaultTicketStatus(query.Get("status")),
+		PileID:      query.Get("pileId"),
+		OverdueOnly: query.Get("overdue") == "true",
+	}
+	if assigneeStr := query.Get("assigneeId"); assigneeStr != "" {
+		assigneeID, err := uuid.Parse(assigneeStr)
+		if err != nil {
+			http.Error(w, "无效的指派人ID", http.StatusBadRequest)
+			return
+		}
+		filter.AssigneeID = &assigneeID
+	}
+
+	// 解析分页参数
+	page, _ := strconv.Atoi(query.Get("page"))
+	if page < 1 {
+		page = 1
+	}
+	pageSize, _ := strconv.Atoi(query.Get("pageSize"))
+	if pageSize < 1 {
+		pageSize = 10
+	}
+
+	tickets, total, err := h.faultTicketService.GetTickets(filter, page, pageSize)
+	if err != nil {
+		http.Error(w, "获取故障工单失败: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	writeFaultTicketResponse(w, "success", map[string]any{
+		"total":    total,
+		"page":     page,
+		"pageSize": pageSize,
+		"tickets":  tickets,
+	})
+}
+
+// GetTicket 获取故障工单详情
+func (h *FaultTicketHandler) GetTicket(w http.ResponseWriter, r *http.Request) {
+	ticketID, err := uuid.Parse(r.PathValue("ticketId"))
+	if err != nil {
+		http.Error(w, "无效的工单ID", http.StatusBadRequest)
+		return
+	}
+
+	ticket, err := h.faultTicketService.GetTicket(ticketID)
+	if err != nil {
+		http.Error(w, "获取故障工单失败: "+err.Error(), faultTicketErrorStatus(err))
+		return
+	}
+
+	writeFaultTicketResponse(w, "success", ticket)
+}
+
+// AssignTicketRequest 指派工单请求
+type AssignTicketRequest struct {
+	AssigneeID uuid.UUID `json:"assigneeId"`
+}
+
+// AssignTicket 指派故障工单
+func (h *FaultTicketHandler) AssignTicket(w http.ResponseWriter, r *http.Request) {
+	operator, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "未授权访问", http.StatusUnauthorized)
+		return
+	}
+
+	ticketID, err := uuid.Parse(r.PathValue("ticketId"))
+	if err != nil {
+		http.Error(w, "无效的工单ID", http.StatusBadRequest)
+		return
+	}
+
+	var req AssignTicketRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.AssigneeID == uuid.Nil {
+		http.Error(w, "无效的请求参数", http.StatusBadRequest)
+		return
+	}
+
+	ticket, err := h.faultTicketService.Assign(operator.ID, ticketID, req.AssigneeID)
+	if err != nil {
+		http.Error(w, "指派工单失败: "+err.Error(), faultTicketErrorStatus(err))
+		return
+	}
+
+	writeFaultTicketResponse(w, "工单已指派", ticket)
+}
+
+// TransitionTicketRequest 工单状态流转请求
+type TransitionTicketRequest struct {
+	Status model.FaultTicketStatus `json:"status"`
+	Notes  string                  `json:"notes"` // 修复时为恢复说明
+}
+
+// TransitionTicket 流转故障工单状态
+func (h *FaultTicketHandler) TransitionTicket(w http.ResponseWriter, r *http.Request) {
+	operator, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "未授权访问", http.StatusUnauthorized)
+		return
+	}
+
+	ticketID, err := uuid.Parse(r.PathValue("ticketId"))
+	if err != nil {
+		http.Error(w, "无效的工单ID", http.StatusBadRequest)
+		return
+	}
+
+	var req TransitionTicketRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Status == "" {
+		http.Error(w, "无效的请求参数", http.StatusBadRequest)
+		return
+	}
+
+	ticket, err := h.faultTicketService.Transition(operator.ID, ticketID, req.Status, req.Notes)
+	if err != nil {
+		http.Error(w, "更新工单状态失败: "+err.Error(), faultTicketErrorStatus(err))
+		return
+	}
+
+	writeFaultTicketResponse(w, "工单状态已更新", ticket)
+}
+
+// TicketNoteRequest 工单备注请求
+type TicketNoteRequest struct {
+	Content string `json:"content"`
+}
+
+// AddNote 添加工单备注
+func (h *FaultTicketHandler) AddNote(w http.ResponseWriter, r *http.Request) {
+	operator, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "未授权访问", http.StatusUnauthorized)
+		return
+	}
+
+	ticketID, err := uuid.Parse(r.PathValue("ticketId"))
+	if err != nil {
+		http.Error(w, "无效的工单ID", http.StatusBadRequest)
+		return
+	}
+
+	var req TicketNoteRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "无效的请求参数", http.StatusBadRequest)
+		return
+	}
+
+	entry, err := h.faultTicketService.AddNote(operator.ID, ticketID, req.Content)
+	if err != nil {
+		http.Error(w, "添加工单备注失败: "+err.Error(), faultTicketErrorStatus(err))
+		return
+	}
+
+	writeFaultTicketResponse(w, "备注已添加", entry)
+}
+
+// TicketPartRequest 登记配件请求
+type TicketPartRequest struct {
+	PartName string `json:"partName"`
+	Quantity int    `json:"quantity"`
+	Note     string `json:"note"`
+}
+
+// AddPart 登记工单使用的配件
+func (h *FaultTicketHandler) AddPart(w http.ResponseWriter, r *http.Request) {
+	operator, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "未授权访问", http.StatusUnauthorized)
+		return
+	}
+
+	ticketID, err := uuid.Parse(r.PathValue("ticketId"))
+	if err != nil {
+		http.Error(w, "无效的工单ID", http.StatusBadRequest)
+		return
+	}
+
+	var req TicketPartRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "无效的请求参数", http.StatusBadRequest)
+		return
+	}
+
+	entry, err := h.faultTicketService.AddPart(operator.ID, ticketID, req.PartName, req.Quantity, req.Note)
+	if err != nil {
+		http.Error(w, "登记配件失败: "+err.Error(), faultTicketErrorStatus(err))
+		return
+	}
+
+	writeFaultTicketResponse(w, "配件已登记", entry)
+}
@@ -0,0 +1,321 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"backend/internal/middleware"
+	"backend/internal/model"
+	"backend/internal/service"
+
+	"github.com/google/uuid"
+)
+
+// InterventionHandler 管理员人工干预处理器
+type InterventionHandler struct {
+	schedulerService *service.SchedulerService
+	auditService     *service.AuditService
+}
+
+// NewInterventionHandler 创建管理员人工干预处理器
+func NewInterventionHandler(schedulerService *service.SchedulerService, auditService *service.AuditService) *InterventionHandler {
+	return &InterventionHandler{
+		schedulerService: schedulerService,
+		auditService:     auditService,
+	}
+}
+
+// interventionErrorStatus 目标状态不允许该操作时返回409，其余错误返回500
+func interventionErrorStatus(err error) int {
+	if errors.Is(err, service.ErrInvalidIntervention) {
+		return http.StatusConflict
+	}
+	return http.StatusInternalServerError
+}
+
+// MoveRequestRequest 移动排队请求
+type MoveRequestRequest struct {
+	PileID string `json:"pileId"` // 目标充电桩，为空时放回等候区
+	Reason string `json:"reason"`
+}
+
+// MoveRequest 将排队中的请求移到其他充电桩或放回等候区
+func (h *InterventionHandler) MoveRequest(w http.ResponseWriter, r *http.Request) {
+	operator, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "未授权访问", http.StatusUnauthorized)
+		return
+	}
+
+	requestID, err := uuid.Parse(r.PathValue("requestId"))
+	if err != nil {
+		http.Error(w, "无效的请求ID", http.StatusBadRequest)
+		return
+	}
+
+	var req MoveRequestRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "无效的请求参数", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.schedulerService.MoveRequest(operator.ID, requestID, req.PileID, req.Reason); err != nil {
+		http.Error(w, "移动请求失败: "+err.Error(), interventionErrorStatus(err))
+		return
+	}
+
+	message := "请求已移至充电桩 " + req.PileID
+	if req.PileID == "" {
+		message = "请求已放回等候区"
+	}
+	response := model.Response{
+		Code:    200,
+		Message: message,
+		Data: map[string]any{
+			"requestId": requestID.String(),
+			"pileId":    req.PileID,
+		},
+		Timestamp: model.NowTimestamp(),
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
+
+// InterventionReasonRequest 带原因的人工干预请求
+type InterventionReasonRequest struct {
+	Reason string `json:"reason"`
+}
+
+// ForceStopCharging 强制停止充电并生成详单
+func (h *InterventionHandler) ForceStopCharging(w http.ResponseWriter, r *http.Request) {
+	operator, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "未授权访问", http.StatusUnauthorized)
+		return
+	}
+
+	requestID, err := uuid.Parse(r.PathValue("requestId"))
+	if err != nil {
+		http.Error(w, "无效的请求ID", http.StatusBadRequest)
+		return
+	}
+
+	var req InterventionReasonRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "无效的请求参数", http.StatusBadRequest)
+		return
+	}
+	if req.Reason == "" {
+		http.Error(w, "强制停止原因不能为空", http.StatusBadRequest)
+		return
+	}
+
+	session, err := h.schedulerService.ForceStopCharging(operator.ID, requestID, req.Reason)
+	if err != nil {
+		http.Error(w, "强制停止充电失败: "+err.Error(), interventionErrorStatus(err))
+		return
+	}
+
+	response := model.Response{
+		Code:    200,
+		Message: "充电已强制停止",
+		Data: map[string]any{
+			"requestId":      requestID.String(),
+			"sessionId":      session.ID.String(),
+			"sessionStatus":  session.Status,
+			"actualCapacity": session.ActualCapacity,
+		},
+		Timestamp: model.NowTimestamp(),
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
+
+// CancelRequest 代用户取消请求
+func (h *InterventionHandler) CancelRequest(w http.ResponseWriter, r *http.Request) {
+	operator, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "未授权访问", http.StatusUnauthorized)
+		return
+	}
+
+	requestID, err := uuid.Parse(r.PathValue("requestId"))
+	if err != nil {
+		http.Error(w, "无效的请求ID", http.StatusBadRequest)
+		return
+	}
+
+	var req InterventionReasonRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "无效的请求参数", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.schedulerService.CancelRequestByAdmin(operator.ID, requestID, req.Reason); err != nil {
+		http.Error(w, "取消请求失败: "+err.Error(), interventionErrorStatus(err))
+		return
+	}
+
+	response := model.Response{
+		Code:    200,
+		Message: "请求已取消",
+		Data: map[string]any{
+			"requestId": requestID.String(),
+			"status":    model.RequestStatusCancelled,
+		},
+		Timestamp: model.NowTimestamp(),
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
+
+// ReorderWaitingRequest 调整等候区顺序
+type ReorderWaitingRequest struct {
+	ChargingMode model.ChargingMode `json:"chargingMode"` // fast/slow
+	RequestIDs   []uuid.UUID        `json:"requestIds"`   // 排在最前的请求，依次排列
+	Reason       string             `json:"reason"`
+}
+
+// ReorderWaitingArea 调整等候区顺序
+func (h *InterventionHandler) ReorderWaitingArea(w http.ResponseWriter, r *http.Request) {
+	operator, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "未授权访问", http.StatusUnauthorized)
+		return
+	}
+
+	var req ReorderWaitingRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "无效的请求参数", http.StatusBadRequest)
+		return
+	}
+	if req.ChargingMode != model.ChargingModeFast && req.ChargingMode != model.ChargingModeSlow {
+		http.Error(w, "无效的充电模式", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.schedulerService.ReorderWaitingArea(operator.ID, req.ChargingMode, req.RequestIDs, req.Reason); err != nil {
+		http.Error(w, "调整等候区顺序失败: "+err.Error(), interventionErrorStatus(err))
+		return
+	}
+
+	response := model.Response{
+		Code:    200,
+		Message: "等候区顺序已调整",
+		Data: map[string]any{
+			"chargingMode": req.ChargingMode,
+			"requestIds":   req.RequestIDs,
+		},
+		Timestamp: model.NowTimestamp(),
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
+
+// FaultPolicyRequest 修改故障调度策略请求
+type FaultPolicyRequest struct {
+	Policy model.FaultReschedulingPolicy `json:"policy"` // priority|time_order
+	Reason string                        `json:"reason"`
+}
+
+// GetFaultPolicy 查询当前的故障调度策略
+func (h *InterventionHandler) GetFaultPolicy(w http.ResponseWriter, r *http.Request) {
+	policy, err := h.schedulerService.GetFaultReschedulingPolicy()
+	if err != nil {
+		http.Error(w, "获取故障调度策略失败: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	response := model.Response{
+		Code:      200,
+		Message:   "success",
+		Data:      map[string]any{"policy": policy},
+		Timestamp: model.NowTimestamp(),
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
+
+// UpdateFaultPolicy 修改故障调度策略
+func (h *InterventionHandler) UpdateFaultPolicy(w http.ResponseWriter, r *http.Request) {
+	operator, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "未授权访问", http.StatusUnauthorized)
+		return
+	}
+
+	var req FaultPolicyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "无效的请求参数", http.StatusBadRequest)
+		return
+	}
+	if !model.IsValidFaultReschedulingPolicy(req.Policy) {
+		http.Error(w, "无效的故障调度策略", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.schedulerService.SetFaultReschedulingPolicy(operator.ID, req.Policy, req.Reason); err != nil {
+		http.Error(w, "修改故障调度策略失败: "+err.Error(), interventionErrorStatus(err))
+		return
+	}
+
+	response := model.Response{
+		Code:      200,
+		Message:   "故障调度策略已修改",
+		Data:      map[string]any{"policy": req.Policy},
+		Timestamp: model.NowTimestamp(),
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
+
+// GetAuditLogs 查询审计日志
+func (h *InterventionHandler) GetAuditLogs(w http.ResponseWriter, r *http.Request) {
+	action := model.AuditAction(r.URL.Query().Get("action"))
+
+	// 解析分页参数
+	page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+	if page < 1 {
+		page = 1
+	}
+	pageSize, _ := strconv.Atoi(r.URL.Query().Get("pageSize"))
+	if pageSize < 1 {
+		pageSize = 10
+	}
+
+	logs, total, err := h.auditService.GetLogs(action, page, pageSize)
+	if err != nil {
+		http.Error(w, "获取审计日志失败: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	response := model.Response{
+		Code:    200,
+		Message: "success",
+		Data: map[string]any{
+			"total":    total,
+			"page":     page,
+			"pageSize": pageSize,
+			"logs":     logs,
+		},
+		Timestamp: model.NowTimestamp(),
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
@@ -0,0 +1,131 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"backend/internal/middleware"
+	"backend/internal/model"
+	"backend/internal/service"
+
+	"github.com/google/uuid"
+)
+
+// MaintenanceHandler 计划维护处理器
+type MaintenanceHandler struct {
+	maintenanceService *service.MaintenanceService
+}
+
+// NewMaintenanceHandler 创建计划维护处理器
+func NewMaintenanceHandler(maintenanceService *service.MaintenanceService) *MaintenanceHandler {
+	return &MaintenanceHandler{
+		maintenanceService: maintenanceService,
+	}
+}
+
+// maintenanceErrorStatus 维护窗口无效时返回400，其余错误返回500
+func maintenanceErrorStatus(err error) int {
+	if errors.Is(err, service.ErrInvalidMaintenanceWindow) {
+		return http.StatusBadRequest
+	}
+	return http.StatusInternalServerError
+}
+
+// ScheduleMaintenanceRequest 计划维护请求
+type ScheduleMaintenanceRequest struct {
+	StartTime time.Time `json:"startTime"` // RFC3339，例如 2025-06-01T22:00:00+08:00
+	EndTime   time.Time `json:"endTime"`
+	Reason    string    `json:"reason"`
+}
+
+// ScheduleMaintenance 为充电桩计划维护窗口
+func (h *MaintenanceHandler) ScheduleMaintenance(w http.ResponseWriter, r *http.Request) {
+	operator, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "未授权访问", http.StatusUnauthorized)
+		return
+	}
+
+	pileID := r.PathValue("pileId")
+	if pileID == "" {
+		http.Error(w, "充电桩ID不能为空", http.StatusBadRequest)
+		return
+	}
+
+	var req ScheduleMaintenanceRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "无效的请求参数", http.StatusBadRequest)
+		return
+	}
+
+	window, err := h.maintenanceService.ScheduleWindow(operator.ID, pileID, req.StartTime, req.EndTime, req.Reason)
+	if err != nil {
+		http.Error(w, "计划维护失败: "+err.Error(), maintenanceErrorStatus(err))
+		return
+	}
+
+	response := model.Response{
+		Code:      200,
+		Message:   "维护窗口已创建",
+		Data:      window,
+		Timestamp: model.NowTimestamp(),
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
+
+// CancelMaintenance 取消维护窗口
+func (h *MaintenanceHandler) CancelMaintenance(w http.ResponseWriter, r *http.Request) {
+	windowID, err := uuid.Parse(r.PathValue("windowId"))
+	if err != nil {
+		http.Error(w, "无效的维护窗口ID", http.StatusBadRequest)
+		return
+	}
+
+	window, err := h.maintenanceService.CancelWindow(windowID)
+	if err != nil {
+		http.Error(w, "取消维护失败: "+err.Error(), maintenanceErrorStatus(err))
+		return
+	}
+
+	response := model.Response{
+		Code:      200,
+		Message:   "维护窗口已取消",
+		Data:      window,
+		Timestamp: model.NowTimestamp(),
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
+
+// GetMaintenanceWindows 查询维护窗口
+func (h *MaintenanceHandler) GetMaintenanceWindows(w http.ResponseWriter, r *http.Request) {
+	pileID := r.URL.Query().Get("pileId")
+	pendingOnly := r.URL.Query().Get("pending") == "true"
+
+	windows, err := h.maintenanceService.GetWindows(pileID, pendingOnly)
+	if err != nil {
+		http.Error(w, "获取维护窗口失败: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	response := model.Response{
+		Code:    200,
+		Message: "success",
+		Data: map[string]any{
+			"total":   len(windows),
+			"windows": windows,
+		},
+		Timestamp: model.NowTimestamp(),
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
@@ -0,0 +1,210 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"backend/internal/middleware"
+	"backend/internal/model"
+	"backend/internal/service"
+
+	"github.com/google/uuid"
+)
+
+// NotificationHandler 通知处理器
+type NotificationHandler struct {
+	notificationService *service.NotificationService
+}
+
+// NewNotificationHandler 创建通知处理器
+func NewNotificationHandler(notificationService *service.NotificationService) *NotificationHandler {
+	return &NotificationHandler{
+		notificationService: notificationService,
+	}
+}
+
+// writeNotificationResponse 写回通知操作成功的响应
+func writeNotificationResponse(w http.ResponseWriter, message string, data any) {
+	response := model.Response{
+		Code:      200,
+		Message:   message,
+		Data:      data,
+		Timestamp: model.NowTimestamp(),
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
+
+// parsePagination 解析分页参数
+func parsePagination(r *http.Request) (int, int) {
+	query := r.URL.Query()
+	page, _ := strconv.Atoi(query.Get("page"))
+	if page < 1 {
+		page = 1
+	}
+	pageSize, _ := strconv.Atoi(query.Get("pageSize"))
+	if pageSize < 1 {
+		pageSize = 10
+	}
+	return page, pageSize
+}
+
+// GetNotifications 查询当前用户的站内信
+func (h *NotificationHandler) GetNotifications(w http.ResponseWriter, r *http.Request) {
+	user, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "未授权访问", http.StatusUnauthorized)
+		return
+	}
+
+	page, pageSize := parsePagination(r)
+	unreadOnly := r.URL.Query().Get("unread") == "true"
+
+	notifications, total, unread, err := h.notificationService.GetNotifications(user.ID, unreadOnly, page, pageSize)
+	if err != nil {
+		http.Error(w, "获取通知失败: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	writeNotificationResponse(w, "success", map[string]any{
+		"total":         total,
+		"unread":        unread,
+		"page":          page,
+		"pageSize":      pageSize,
+		"notifications": notifications,
+	})
+}
+
+// MarkRead 将站内信标记为已读
+func (h *NotificationHandler) MarkRead(w http.ResponseWriter, r *http.Request) {
+	user, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "未授权访问", http.StatusUnauthorized)
+		return
+	}
+
+	notificationID, err := uuid.Parse(r.PathValue("notificationId"))
+	if err != nil {
+		http.Error(w, "无效的通知ID", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.notificationService.MarkRead(user.ID, notificationID); err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, service.ErrNotificationNotFound) {
+			status = http.StatusNotFound
+		}
+		http.Error(w, "标记已读失败: "+err.Error(), status)
+		return
+	}
+
+	writeNotificationResponse(w, "已标记为已读", nil)
+}
+
+// MarkAllRead 将当前用户的全部站内信标记为已读
+func (h *NotificationHandler) MarkAllRead(w http.ResponseWriter, r *http.Request) {
+	user, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "未授权访问", http.StatusUnauthorized)
+		return
+	}
+
+	count, err := h.notificationService.MarkAllRead(user.ID)
+	if err != nil {
+		http.Error(w, "标记已读失败: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	writeNotificationResponse(w, "已全部标记为已读", map[string]any{
+		"marked": count,
+	})
+}
+
+// GetPreferences 获取当前用户的通知渠道偏好
+func (h *NotificationHandler) GetPreferences(w http.ResponseWriter, r *http.Request) {
+	user, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "未授权访问", http.StatusUnauthorized)
+		return
+	}
+
+	preferences, err := h.notificationService.GetPreferences(user.ID)
+	if err != nil {
+		http.Error(w, "获取通知偏好失败: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	writeNotificationResponse(w, "success", map[string]any{
+		"preferences": preferences,
+		"events":      model.NotificationEvents,
+	})
+}
+
+// UpdatePreferencesRequest 更新通知偏好请求，未提交的渠道保持不变
+type UpdatePreferencesRequest struct {
+	Preferences []*model.NotificationPreference `json:"preferences"`
+}
+
+// UpdatePreferences 更新当前用户的通知渠道偏好
+func (h *NotificationHandler) UpdatePreferences(w http.ResponseWriter, r *http.Request) {
+	user, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "未授权访问", http.StatusUnauthorized)
+		return
+	}
+
+	var req UpdatePreferencesRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || len(req.Preferences) == 0 {
+		http.Error(w, "无效的请求参数", http.StatusBadRequest)
+		return
+	}
+
+	preferences, err := h.notificationService.UpdatePreferences(user.ID, req.Preferences)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, service.ErrInvalidNotificationPreference) {
+			status = http.StatusBadRequest
+		}
+		http.Error(w, "更新通知偏好失败: "+err.Error(), status)
+		return
+	}
+
+	writeNotificationResponse(w, "通知偏好已更新", map[string]any{
+		"preferences": preferences,
+	})
+}
+
+// GetDeliveries 查询通知投递记录
+func (h *NotificationHandler) GetDeliveries(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	filter := &model.NotificationDeliveryFilter{
+		Status:  model.NotificationDeliveryStatus(query.Get("status")),
+		Channel: model.NotificationChannel(query.Get("channel")),
+	}
+	if userStr := query.Get("userId"); userStr != "" {
+		userID, err := uuid.Parse(userStr)
+		if err != nil {
+			http.Error(w, "无效的用户ID", http.StatusBadRequest)
+			return
+		}
+		filter.UserID = &userID
+	}
+
+	page, pageSize := parsePagination(r)
+	deliveries, total, err := h.notificationService.GetDeliveries(filter, page, pageSize)
+	if err != nil {
+		http.Error(w, "获取投递记录失败: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	writeNotificationResponse(w, "success", map[string]any{
+		"total":      total,
+		"page":       page,
+		"pageSize":   pageSize,
+		"deliveries": deliveries,
+	})
+}
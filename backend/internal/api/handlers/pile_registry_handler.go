@@ -0,0 +1,160 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"backend/internal/middleware"
+	"backend/internal/model"
+	"backend/internal/service"
+)
+
+// registrationSecretHeader 充电桩自注册携带共享密钥的请求头
+const registrationSecretHeader = "X-Registration-Secret"
+
+// PileRegistryHandler 充电桩注册处理器
+type PileRegistryHandler struct {
+	pileRegistryService *service.PileRegistryService
+}
+
+// NewPileRegistryHandler 创建充电桩注册处理器
+func NewPileRegistryHandler(pileRegistryService *service.PileRegistryService) *PileRegistryHandler {
+	return &PileRegistryHandler{
+		pileRegistryService: pileRegistryService,
+	}
+}
+
+// PileRegisterRequest 充电桩注册请求
+type PileRegisterRequest struct {
+	CallbackURL string `json:"callbackUrl"` // 指令下发地址，例如 http://10.0.0.2:8090
+	Piles       []struct {
+		ID     string  `json:"id"`
+		Type   string  `json:"type"`   // fast/slow，模拟器的trickle视为slow
+		Power  float64 `json:"power"`  // 充电功率(度/小时)
+		Status string  `json:"status"` // 模拟器状态，charging视为occupied
+	} `json:"piles"`
+}
+
+// Register 充电桩自注册
+func (h *PileRegistryHandler) Register(w http.ResponseWriter, r *http.Request) {
+	var req PileRegisterRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "无效的请求参数", http.StatusBadRequest)
+		return
+	}
+
+	registrations := make([]model.PileRegistration, 0, len(req.Piles))
+	for _, pile := range req.Piles {
+		reg := model.PileRegistration{
+			PileID:   pile.ID,
+			PileType: model.PileType(pile.Type),
+			Power:    pile.Power,
+			Status:   model.PileStatus(pile.Status),
+		}
+		if pile.Type == "trickle" {
+			reg.PileType = model.PileTypeSlow
+		}
+		if pile.Status == "charging" {
+			reg.Status = model.PileStatusOccupied
+		}
+		registrations = append(registrations, reg)
+	}
+
+	piles, err := h.pileRegistryService.Register(r.Header.Get(registrationSecretHeader), req.CallbackURL, registrations)
+	if err != nil {
+		status := http.StatusInternalServerError
+		switch {
+		case errors.Is(err, service.ErrRegistrationUnauthorized):
+			status = http.StatusUnauthorized
+		case errors.Is(err, service.ErrInvalidRegistration):
+			status = http.StatusBadRequest
+		case errors.Is(err, service.ErrEndpointConflict), errors.Is(err, service.ErrPileSpecBusy):
+			status = http.StatusConflict
+		}
+		http.Error(w, "充电桩注册失败: "+err.Error(), status)
+		return
+	}
+
+	response := model.Response{
+		Code:    200,
+		Message: "充电桩注册成功",
+		Data: map[string]any{
+			"callbackUrl": req.CallbackURL,
+			"piles":       piles,
+		},
+		Timestamp: model.NowTimestamp(),
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
+
+// GetEndpoints 查询充电桩注册的指令下发地址
+func (h *PileRegistryHandler) GetEndpoints(w http.ResponseWriter, r *http.Request) {
+	endpoints, err := h.pileRegistryService.GetEndpoints()
+	if err != nil {
+		http.Error(w, "获取充电桩注册信息失败: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if endpoints == nil {
+		endpoints = []*model.PileEndpoint{}
+	}
+
+	response := model.Response{
+		Code:    200,
+		Message: "success",
+		Data: map[string]any{
+			"total":     len(endpoints),
+			"endpoints": endpoints,
+		},
+		Timestamp: model.NowTimestamp(),
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
+
+// MoveEndpointRequest 迁移充电桩注册地址请求
+type MoveEndpointRequest struct {
+	EndpointURL string `json:"endpointUrl"` // 新的指令下发地址
+	Reason      string `json:"reason"`
+}
+
+// MoveEndpoint 管理员迁移充电桩的指令下发地址
+func (h *PileRegistryHandler) MoveEndpoint(w http.ResponseWriter, r *http.Request) {
+	user, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "未授权访问", http.StatusUnauthorized)
+		return
+	}
+
+	var req MoveEndpointRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "无效的请求参数", http.StatusBadRequest)
+		return
+	}
+
+	endpoint, err := h.pileRegistryService.MoveEndpoint(user.ID, r.PathValue("pileId"), req.EndpointURL, req.Reason)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, service.ErrInvalidRegistration) {
+			status = http.StatusBadRequest
+		}
+		http.Error(w, "迁移充电桩地址失败: "+err.Error(), status)
+		return
+	}
+
+	response := model.Response{
+		Code:      200,
+		Message:   "充电桩地址已迁移",
+		Data:      endpoint,
+		Timestamp: model.NowTimestamp(),
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
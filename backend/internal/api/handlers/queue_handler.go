@@ -59,6 +59,7 @@ func (h *QueueHandler) GetQueueStatus(w http.ResponseWriter, r *http.Request) {
 			"availableSlots": queueStatus.AvailableSlots,
 		}
 	}
+	data["waitlist"] = queueStatus.WaitlistLength
 
 	response := model.Response{
 		Code:      200,
@@ -92,7 +93,7 @@ func (h *QueueHandler) GetUserQueuePosition(w http.ResponseWriter, r *http.Reque
 	}
 
 	// 检查权限
-	if userID != user.ID && user.UserType != model.UserTypeAdmin {
+	if userID != user.ID && !model.HasPermission(user.UserType, model.PermQueueView) {
 		http.Error(w, "权限不足", http.StatusForbidden)
 		return
 	}
@@ -104,7 +105,7 @@ func (h *QueueHandler) GetUserQueuePosition(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
-	if request == nil || (request.Status != model.RequestStatusWaiting && request.Status != model.RequestStatusQueued && request.Status != model.RequestStatusCharging) {
+	if request == nil || (request.Status != model.RequestStatusWaitlisted && request.Status != model.RequestStatusWaiting && request.Status != model.RequestStatusQueued && request.Status != model.RequestStatusCharging) {
 		response := model.Response{
 			Code:      404,
 			Message:   "用户当前没有排队中的充电请求",
@@ -114,7 +115,29 @@ func (h *QueueHandler) GetUserQueuePosition(w http.ResponseWriter, r *http.Reque
 		w.WriteHeader(http.StatusNotFound)
 		json.NewEncoder(w).Encode(response)
 		return
-	} // 计算前面的车辆数量
+	}
+
+	// 候补请求返回候补位置与预计入场时间
+	if request.Status == model.RequestStatusWaitlisted {
+		waitlistStatus, err := h.chargingRequestService.GetWaitlistStatus(request)
+		if err != nil {
+			http.Error(w, "获取候补队列信息失败: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		response := model.Response{
+			Code:      200,
+			Message:   "success",
+			Data:      waitlistStatus,
+			Timestamp: model.NowTimestamp(),
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(response)
+		return
+	}
+
+	// 计算前面的车辆数量
 	var carsAhead int
 	if request.Status == model.RequestStatusWaiting {
 		// 对于等候区的请求，计算同一充电模式下更早创建的请求数量
@@ -124,9 +147,10 @@ func (h *QueueHandler) GetUserQueuePosition(w http.ResponseWriter, r *http.Reque
 			return
 		}
 
-		// 计算比当前请求更早的请求数量
+		// 计算排在当前请求之前的请求数量：优先级更高，或优先级相同且更早创建
 		for _, waitingReq := range waitingRequests {
-			if waitingReq.CreatedAt.Before(request.CreatedAt) {
+			if waitingReq.Priority > request.Priority ||
+				(waitingReq.Priority == request.Priority && waitingReq.CreatedAt.Before(request.CreatedAt)) {
 				carsAhead++
 			}
 		}
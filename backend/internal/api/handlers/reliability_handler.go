@@ -0,0 +1,78 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"backend/internal/model"
+	"backend/internal/service"
+)
+
+// ReliabilityHandler 可靠性报表处理器
+type ReliabilityHandler struct {
+	reliabilityService *service.ReliabilityService
+}
+
+// NewReliabilityHandler 创建可靠性报表处理器
+func NewReliabilityHandler(reliabilityService *service.ReliabilityService) *ReliabilityHandler {
+	return &ReliabilityHandler{
+		reliabilityService: reliabilityService,
+	}
+}
+
+// GetReliabilityReport 获取充电桩可靠性报表
+func (h *ReliabilityHandler) GetReliabilityReport(w http.ResponseWriter, r *http.Request) {
+	// 获取查询参数
+	startDateStr := r.URL.Query().Get("startDate")
+	endDateStr := r.URL.Query().Get("endDate")
+
+	// 解析日期参数
+	var startDate, endDate time.Time
+	var err error
+
+	if startDateStr == "" {
+		// 默认开始日期为30天前
+		startDate = time.Now().UTC().AddDate(0, 0, -30)
+	} else {
+		startDate, err = time.Parse("2006-01-02", startDateStr)
+		if err != nil {
+			http.Error(w, "开始日期格式错误", http.StatusBadRequest)
+			return
+		}
+	}
+
+	if endDateStr == "" {
+		endDate = time.Now().UTC()
+	} else {
+		endDate, err = time.Parse("2006-01-02", endDateStr)
+		if err != nil {
+			http.Error(w, "结束日期格式错误", http.StatusBadRequest)
+			return
+		}
+		// 设置为当天23:59:59
+		endDate = time.Date(endDate.Year(), endDate.Month(), endDate.Day(), 23, 59, 59, 999999999, endDate.Location())
+	}
+
+	if !endDate.After(startDate) {
+		http.Error(w, "开始日期必须早于结束日期", http.StatusBadRequest)
+		return
+	}
+
+	report, err := h.reliabilityService.GetReport(startDate, endDate)
+	if err != nil {
+		http.Error(w, "获取可靠性报表失败: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	response := model.Response{
+		Code:      200,
+		Message:   "success",
+		Data:      report,
+		Timestamp: model.NowTimestamp(),
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
@@ -2,26 +2,93 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"time"
 
 	"backend/internal/model"
 	"backend/internal/service"
+
+	"github.com/google/uuid"
 )
 
 // SimulatorHandler 模拟器处理器
 type SimulatorHandler struct {
-	chargingPileService *service.ChargingPileService
-	schedulerService    *service.SchedulerService
+	chargingPileService   *service.ChargingPileService
+	schedulerService      *service.SchedulerService
+	simulatorEventService *service.SimulatorEventService
+	reconcilerService     *service.ReconcilerService
 }
 
 // NewSimulatorHandler 创建模拟器处理器
-func NewSimulatorHandler(chargingPileService *service.ChargingPileService, schedulerService *service.SchedulerService) *SimulatorHandler {
+func NewSimulatorHandler(chargingPileService *service.ChargingPileService, schedulerService *service.SchedulerService, simulatorEventService *service.SimulatorEventService, reconcilerService *service.ReconcilerService) *SimulatorHandler {
 	return &SimulatorHandler{
-		chargingPileService: chargingPileService,
-		schedulerService:    schedulerService,
+		chargingPileService:   chargingPileService,
+		schedulerService:      schedulerService,
+		simulatorEventService: simulatorEventService,
+		reconcilerService:     reconcilerService,
+	}
+}
+
+// processEvent 按事件ID幂等处理回调并写回响应
+// 重复的事件直接返回首次处理的结果，响应头 X-Event-Replayed 标记重放
+func (h *SimulatorHandler) processEvent(w http.ResponseWriter, event *model.SimulatorEvent, handle func() (int, []byte)) {
+	statusCode, body, replayed, err := h.simulatorEventService.Process(event, handle)
+	if err != nil {
+		http.Error(w, "处理回调事件失败: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if statusCode >= 200 && statusCode < 300 {
+		w.Header().Set("Content-Type", "application/json")
+	} else {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+	}
+	if replayed {
+		w.Header().Set("X-Event-Replayed", "true")
+	}
+	w.WriteHeader(statusCode)
+	w.Write(body)
+}
+
+// eventResult 成功回调的响应
+func eventResult(response model.Response) (int, []byte) {
+	data, err := json.Marshal(response)
+	if err != nil {
+		return eventError(http.StatusInternalServerError, "序列化响应失败: "+err.Error())
 	}
+	return http.StatusOK, append(data, '\n')
+}
+
+// eventError 失败回调的响应
+func eventError(statusCode int, message string) (int, []byte) {
+	return statusCode, []byte(message + "\n")
+}
+
+// sessionErrorStatus 会话校验失败返回409，模拟器不再重试；其余错误返回500
+func sessionErrorStatus(err error) int {
+	if errors.Is(err, service.ErrSessionEnded) || errors.Is(err, service.ErrSessionMismatch) {
+		return http.StatusConflict
+	}
+	return http.StatusInternalServerError
+}
+
+// parseSessionID 解析回调携带的会话ID，为空时返回uuid.Nil
+func parseSessionID(sessionID string) (uuid.UUID, error) {
+	if sessionID == "" {
+		return uuid.Nil, nil
+	}
+	return uuid.Parse(sessionID)
+}
+
+// eventSessionID 事件记录中的会话ID
+func eventSessionID(sessionID uuid.UUID) *uuid.UUID {
+	if sessionID == uuid.Nil {
+		return nil
+	}
+	return &sessionID
 }
 
 // PileStatusUpdateRequest 充电桩状态更新请求
@@ -29,6 +96,7 @@ type PileStatusUpdateRequest struct {
 	PileID         string `json:"pileId"`
 	Status         string `json:"status"` // charging|available|fault|maintenance|offline
 	CurrentVehicle *struct {
+		SessionID         string    `json:"sessionId,omitempty"`
 		UserID            string    `json:"userId"`
 		StartTime         time.Time `json:"startTime"`
 		RequestedCapacity float64   `json:"requestedCapacity"`
@@ -41,15 +109,65 @@ type PileStatusUpdateRequest struct {
 	} `json:"queue,omitempty"`
 }
 
+// UpdatePileStatus 接收模拟器定期推送的充电桩状态并与后端对账
+func (h *SimulatorHandler) UpdatePileStatus(w http.ResponseWriter, r *http.Request) {
+	var req PileStatusUpdateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "无效的请求参数", http.StatusBadRequest)
+		return
+	}
+
+	// 验证参数
+	if req.PileID == "" || req.Status == "" {
+		http.Error(w, "充电桩ID和状态不能为空", http.StatusBadRequest)
+		return
+	}
+
+	report := &model.PileStatusReport{
+		PileID:     req.PileID,
+		Status:     req.Status,
+		ReportedAt: time.Now().UTC(),
+	}
+	if req.CurrentVehicle != nil {
+		report.Vehicle = &model.ReportedCharge{
+			SessionID:         req.CurrentVehicle.SessionID,
+			UserID:            req.CurrentVehicle.UserID,
+			StartTime:         req.CurrentVehicle.StartTime,
+			RequestedCapacity: req.CurrentVehicle.RequestedCapacity,
+			CurrentCapacity:   req.CurrentVehicle.CurrentCapacity,
+		}
+	}
+
+	if err := h.reconcilerService.Reconcile(report); err != nil {
+		http.Error(w, "充电桩状态对账失败: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	response := model.Response{
+		Code:      200,
+		Message:   "充电桩状态已接收",
+		Timestamp: model.NowTimestamp(),
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
+
 // ChargingProgressRequest 充电进度更新请求
 type ChargingProgressRequest struct {
+	EventID           string    `json:"eventId,omitempty"`   // 事件ID，重放时返回首次处理结果
+	SessionID         string    `json:"sessionId,omitempty"` // 充电会话ID
 	PileID            string    `json:"pileId"`
 	UserID            string    `json:"userId"`
 	StartTime         time.Time `json:"startTime"`
 	CurrentCapacity   float64   `json:"currentCapacity"`
 	RequestedCapacity float64   `json:"requestedCapacity"`
-	ChargingRate      float64   `json:"chargingRate"`  // 度/小时
-	RemainingTime     int       `json:"remainingTime"` // 秒
+	ChargingRate      float64   `json:"chargingRate"`           // 度/小时
+	RemainingTime     int       `json:"remainingTime"`          // 秒
+	CurrentSoC        *float64  `json:"currentSoc,omitempty"`   // 当前电量百分比
+	CurrentPower      *float64  `json:"currentPower,omitempty"` // 瞬时充电功率(kW)
+	RatedPower        float64   `json:"ratedPower,omitempty"`   // 充电桩额定功率(kW)
 }
 
 // UpdateChargingProgress 更新充电进度
@@ -68,29 +186,54 @@ func (h *SimulatorHandler) UpdateChargingProgress(w http.ResponseWriter, r *http
 		return
 	}
 
-	err := h.schedulerService.UpdateChargingProgress(req.PileID, req.UserID, req.CurrentCapacity, req.RemainingTime)
-
+	sessionID, err := parseSessionID(req.SessionID)
 	if err != nil {
-		http.Error(w, "更新充电进度失败: "+err.Error(), http.StatusInternalServerError)
+		http.Error(w, "无效的充电会话ID", http.StatusBadRequest)
 		return
 	}
 
-	response := model.Response{
-		Code:      200,
-		Message:   "充电进度已更新",
-		Timestamp: model.NowTimestamp(),
+	// 旧版模拟器未上报瞬时功率时以充电速率代替
+	currentPower := req.ChargingRate
+	if req.CurrentPower != nil {
+		currentPower = *req.CurrentPower
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(response)
+	event := &model.SimulatorEvent{
+		EventID:   req.EventID,
+		EventType: model.SimulatorEventChargingProgress,
+		PileID:    req.PileID,
+		SessionID: eventSessionID(sessionID),
+	}
+	h.processEvent(w, event, func() (int, []byte) {
+		err := h.schedulerService.UpdateChargingProgress(&model.ChargingProgress{
+			SessionID:       sessionID,
+			PileID:          req.PileID,
+			UserID:          req.UserID,
+			CurrentCapacity: req.CurrentCapacity,
+			CurrentSoC:      req.CurrentSoC,
+			CurrentPower:    currentPower,
+			RemainingTime:   req.RemainingTime,
+		})
+		if err != nil {
+			return eventError(sessionErrorStatus(err), "更新充电进度失败: "+err.Error())
+		}
+
+		return eventResult(model.Response{
+			Code:      200,
+			Message:   "充电进度已更新",
+			Timestamp: model.NowTimestamp(),
+		})
+	})
 }
 
 // FaultReportSimRequest 故障报告请求（模拟器版本）
 type FaultReportSimRequest struct {
-	PileID      string `json:"pileId"`
-	FaultType   string `json:"faultType"` // hardware|software|power
-	Description string `json:"description"`
+	EventID       string  `json:"eventId,omitempty"`   // 事件ID，重放时返回首次处理结果
+	SessionID     string  `json:"sessionId,omitempty"` // 故障时正在充电的会话ID
+	PileID        string  `json:"pileId"`
+	FaultType     string  `json:"faultType"` // hardware|software|power
+	Description   string  `json:"description"`
+	DegradedPower float64 `json:"degradedPower,omitempty"` // 大于0时充电桩降功率运行，不中断服务
 }
 
 // ReportFault 报告故障（模拟器）
@@ -109,35 +252,61 @@ func (h *SimulatorHandler) ReportFault(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// 报告故障到充电桩服务
-	err := h.chargingPileService.ReportPileFault(req.PileID, req.FaultType, req.Description)
+	sessionID, err := parseSessionID(req.SessionID)
 	if err != nil {
-		http.Error(w, "报告故障失败: "+err.Error(), http.StatusInternalServerError)
+		http.Error(w, "无效的充电会话ID", http.StatusBadRequest)
 		return
 	}
 
-	// 调用调度服务处理故障
-	err = h.schedulerService.HandlePileFault(req.PileID, req.FaultType, req.Description)
-	if err != nil {
-		http.Error(w, "处理故障调度失败: "+err.Error(), http.StatusInternalServerError)
-		return
+	event := &model.SimulatorEvent{
+		EventID:   req.EventID,
+		EventType: model.SimulatorEventFaultReport,
+		PileID:    req.PileID,
+		SessionID: eventSessionID(sessionID),
 	}
-
-	// 创建一个故障记录ID
-	faultID := "fault-" + time.Now().UTC().Format("20060102150405")
-
-	response := model.Response{
-		Code:    200,
-		Message: "故障已报告，正在重新调度",
-		Data: map[string]any{
-			"faultId": faultID,
-		},
-		Timestamp: model.NowTimestamp(),
-	}
-
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(response)
+	h.processEvent(w, event, func() (int, []byte) {
+		// 降功率运行：充电桩继续服务，调度与预计时间按降低后的功率计算
+		if req.DegradedPower > 0 {
+			err := h.chargingPileService.ReportPileDegradation(req.PileID, req.FaultType, req.Description, req.DegradedPower)
+			if err != nil {
+				return eventError(http.StatusBadRequest, "报告降功率运行失败: "+err.Error())
+			}
+
+			return eventResult(model.Response{
+				Code:    200,
+				Message: "充电桩降功率运行",
+				Data: map[string]any{
+					"pileId":         req.PileID,
+					"effectivePower": req.DegradedPower,
+				},
+				Timestamp: model.NowTimestamp(),
+			})
+		}
+
+		// 报告故障到充电桩服务
+		err := h.chargingPileService.ReportPileFault(req.PileID, req.FaultType, req.Description)
+		if err != nil {
+			return eventError(http.StatusInternalServerError, "报告故障失败: "+err.Error())
+		}
+
+		// 调用调度服务处理故障
+		err = h.schedulerService.HandlePileFault(req.PileID, req.FaultType, req.Description)
+		if err != nil {
+			return eventError(http.StatusInternalServerError, "处理故障调度失败: "+err.Error())
+		}
+
+		// 创建一个故障记录ID
+		faultID := "fault-" + time.Now().UTC().Format("20060102150405")
+
+		return eventResult(model.Response{
+			Code:    200,
+			Message: "故障已报告，正在重新调度",
+			Data: map[string]any{
+				"faultId": faultID,
+			},
+			Timestamp: model.NowTimestamp(),
+		})
+	})
 }
 
 // HeartbeatRequest 心跳请求
@@ -169,7 +338,9 @@ func (h *SimulatorHandler) Heartbeat(w http.ResponseWriter, r *http.Request) {
 
 // FaultRecoveryRequest 故障恢复请求
 type FaultRecoveryRequest struct {
-	PileID string `json:"pileId"`
+	EventID   string `json:"eventId,omitempty"`   // 事件ID，重放时返回首次处理结果
+	SessionID string `json:"sessionId,omitempty"` // 故障时中断的充电会话ID
+	PileID    string `json:"pileId"`
 }
 
 // RecoverFault 故障恢复（模拟器）
@@ -188,30 +359,62 @@ func (h *SimulatorHandler) RecoverFault(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	// 调用调度服务处理故障恢复
-	err := h.schedulerService.HandlePileRecovery(req.PileID)
+	sessionID, err := parseSessionID(req.SessionID)
 	if err != nil {
-		http.Error(w, "处理故障恢复失败: "+err.Error(), http.StatusInternalServerError)
+		http.Error(w, "无效的充电会话ID", http.StatusBadRequest)
 		return
 	}
 
-	response := model.Response{
-		Code:    200,
-		Message: "故障已恢复，正在重新调度",
-		Data: map[string]any{
-			"pileId": req.PileID,
-			"status": "recovered",
-		},
-		Timestamp: model.NowTimestamp(),
+	event := &model.SimulatorEvent{
+		EventID:   req.EventID,
+		EventType: model.SimulatorEventFaultRecovery,
+		PileID:    req.PileID,
+		SessionID: eventSessionID(sessionID),
 	}
-
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(response)
+	h.processEvent(w, event, func() (int, []byte) {
+		// 降功率运行的充电桩恢复额定功率，不需要重新调度
+		if pile, err := h.chargingPileService.GetPileByID(req.PileID); err == nil && pile.IsDegraded() && pile.Status != model.PileStatusFault {
+			if _, err := h.chargingPileService.ClearPileDegradation(req.PileID); err != nil {
+				return eventError(http.StatusInternalServerError, "恢复额定功率失败: "+err.Error())
+			}
+
+			return eventResult(model.Response{
+				Code:    200,
+				Message: "充电桩已恢复额定功率",
+				Data: map[string]any{
+					"pileId": req.PileID,
+					"status": "restored",
+				},
+				Timestamp: model.NowTimestamp(),
+			})
+		}
+
+		// 调用调度服务处理故障恢复
+		err := h.schedulerService.HandlePileRecovery(req.PileID)
+		if errors.Is(err, service.ErrTicketNotResolved) {
+			// 故障工单修复前硬件恢复不生效，充电桩保持故障状态
+			return eventError(http.StatusConflict, "处理故障恢复失败: "+err.Error())
+		}
+		if err != nil {
+			return eventError(http.StatusInternalServerError, "处理故障恢复失败: "+err.Error())
+		}
+
+		return eventResult(model.Response{
+			Code:    200,
+			Message: "故障已恢复，正在重新调度",
+			Data: map[string]any{
+				"pileId": req.PileID,
+				"status": "recovered",
+			},
+			Timestamp: model.NowTimestamp(),
+		})
+	})
 }
 
 // ChargingCompleteRequest 充电完成请求
 type ChargingCompleteRequest struct {
+	EventID           string    `json:"eventId,omitempty"`   // 事件ID，重放时返回首次处理结果
+	SessionID         string    `json:"sessionId,omitempty"` // 充电会话ID
 	PileID            string    `json:"pileId"`
 	UserID            string    `json:"userId"`
 	StartTime         time.Time `json:"startTime"`
@@ -219,6 +422,7 @@ type ChargingCompleteRequest struct {
 	RequestedCapacity float64   `json:"requestedCapacity"`
 	ActualCapacity    float64   `json:"actualCapacity"`
 	ChargingDuration  int       `json:"chargingDuration"` // 秒
+	EndSoC            *float64  `json:"endSoc,omitempty"` // 结束电量百分比
 }
 
 // CompleteCharging 处理充电完成请求
@@ -237,27 +441,97 @@ func (h *SimulatorHandler) CompleteCharging(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
-	// 调用调度服务处理充电完成
-	err := h.schedulerService.CompleteCharging(req.PileID, req.UserID, req.StartTime, req.EndTime, req.RequestedCapacity, req.ActualCapacity, req.ChargingDuration)
+	sessionID, err := parseSessionID(req.SessionID)
 	if err != nil {
-		http.Error(w, "处理充电完成失败: "+err.Error(), http.StatusInternalServerError)
+		http.Error(w, "无效的充电会话ID", http.StatusBadRequest)
 		return
 	}
 
-	response := model.Response{
-		Code:    200,
-		Message: "充电完成处理成功",
-		Data: map[string]any{
-			"pileId":           req.PileID,
-			"userId":           req.UserID,
-			"actualCapacity":   req.ActualCapacity,
-			"chargingDuration": req.ChargingDuration,
-			"status":           "completed",
-		},
-		Timestamp: model.NowTimestamp(),
+	event := &model.SimulatorEvent{
+		EventID:   req.EventID,
+		EventType: model.SimulatorEventChargingComplete,
+		PileID:    req.PileID,
+		SessionID: eventSessionID(sessionID),
 	}
+	h.processEvent(w, event, func() (int, []byte) {
+		// 调用调度服务处理充电完成
+		err := h.schedulerService.CompleteCharging(sessionID, req.PileID, req.UserID, req.StartTime, req.EndTime, req.RequestedCapacity, req.ActualCapacity, req.EndSoC, req.ChargingDuration)
+		if err != nil {
+			return eventError(sessionErrorStatus(err), "处理充电完成失败: "+err.Error())
+		}
+
+		return eventResult(model.Response{
+			Code:    200,
+			Message: "充电完成处理成功",
+			Data: map[string]any{
+				"sessionId":        req.SessionID,
+				"pileId":           req.PileID,
+				"userId":           req.UserID,
+				"actualCapacity":   req.ActualCapacity,
+				"chargingDuration": req.ChargingDuration,
+				"endSoc":           req.EndSoC,
+				"status":           "completed",
+			},
+			Timestamp: model.NowTimestamp(),
+		})
+	})
+}
 
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(response)
+// VehicleDepartedRequest 车辆驶离请求
+type VehicleDepartedRequest struct {
+	EventID    string     `json:"eventId,omitempty"`   // 事件ID，重放时返回首次处理结果
+	SessionID  string     `json:"sessionId,omitempty"` // 已结束的充电会话ID，为空时按充电桩查找等待驶离的车辆
+	PileID     string     `json:"pileId"`
+	DepartedAt *time.Time `json:"departedAt,omitempty"` // 驶离时间，为空时使用当前时间
+}
+
+// VehicleDeparted 处理车辆驶离，驶离后充电桩才可为下一辆车充电
+func (h *SimulatorHandler) VehicleDeparted(w http.ResponseWriter, r *http.Request) {
+	var req VehicleDepartedRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "无效的请求参数", http.StatusBadRequest)
+		return
+	}
+
+	fmt.Println("Received VehicleDepartedRequest:", req)
+
+	// 验证参数
+	if req.PileID == "" {
+		http.Error(w, "充电桩ID不能为空", http.StatusBadRequest)
+		return
+	}
+
+	sessionID, err := parseSessionID(req.SessionID)
+	if err != nil {
+		http.Error(w, "无效的充电会话ID", http.StatusBadRequest)
+		return
+	}
+	departedAt := time.Now().UTC()
+	if req.DepartedAt != nil {
+		departedAt = req.DepartedAt.UTC()
+	}
+
+	event := &model.SimulatorEvent{
+		EventID:   req.EventID,
+		EventType: model.SimulatorEventVehicleDeparted,
+		PileID:    req.PileID,
+		SessionID: eventSessionID(sessionID),
+	}
+	h.processEvent(w, event, func() (int, []byte) {
+		departure, err := h.schedulerService.HandleVehicleDeparture(sessionID, req.PileID, departedAt)
+		if err != nil {
+			status := sessionErrorStatus(err)
+			if errors.Is(err, service.ErrNoParkedVehicle) || errors.Is(err, service.ErrVehicleStillCharging) {
+				status = http.StatusConflict
+			}
+			return eventError(status, "处理车辆驶离失败: "+err.Error())
+		}
+
+		return eventResult(model.Response{
+			Code:      200,
+			Message:   "车辆已驶离",
+			Data:      departure,
+			Timestamp: model.NowTimestamp(),
+		})
+	})
 }
@@ -2,6 +2,7 @@ package handlers
 
 import (
 	"encoding/json"
+	"net"
 	"net/http"
 
 	"github.com/google/uuid"
@@ -99,8 +100,10 @@ func (h *UserHandler) Login(w http.ResponseWriter, r *http.Request) {
 
 	// 创建登录请求对象
 	loginReq := &model.LoginRequest{
-		Username: req.Username,
-		Password: req.Password,
+		Username:  req.Username,
+		Password:  req.Password,
+		UserAgent: r.UserAgent(),
+		IPAddress: clientIP(r),
 	}
 
 	// 登录用户
@@ -111,14 +114,9 @@ func (h *UserHandler) Login(w http.ResponseWriter, r *http.Request) {
 	}
 
 	response := model.Response{
-		Code:    200,
-		Message: "登录成功",
-		Data: map[string]any{
-			"token":     loginResp.Token,
-			"userId":    loginResp.UserID.String(),
-			"userType":  loginResp.UserType,
-			"expiresIn": loginResp.ExpiresIn,
-		},
+		Code:      200,
+		Message:   "登录成功",
+		Data:      tokenResponseData(loginResp),
 		Timestamp: model.NowTimestamp(),
 	}
 
@@ -146,7 +144,7 @@ func (h *UserHandler) GetUserInfo(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// 检查权限 (假设 UserType 是 User 对象中的字段，而不是 Role)
-	if userID != user.ID && user.UserType != "admin" {
+	if userID != user.ID && !model.HasPermission(user.UserType, model.PermUserView) {
 		http.Error(w, "权限不足", http.StatusForbidden)
 		return
 	}
@@ -177,3 +175,305 @@ func (h *UserHandler) GetUserInfo(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(response)
 }
+
+// clientIP 客户端地址，只保留主机部分
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// tokenResponseData 组装令牌响应数据
+func tokenResponseData(resp *model.LoginResponse) map[string]any {
+	return map[string]any{
+		"token":            resp.Token,
+		"refreshToken":     resp.RefreshToken,
+		"sessionId":        resp.SessionID.String(),
+		"userId":           resp.UserID.String(),
+		"userType":         resp.UserType,
+		"expiresIn":        resp.ExpiresIn,
+		"refreshExpiresIn": resp.RefreshExpiresIn,
+	}
+}
+
+// RefreshTokenRequest 刷新令牌请求
+type RefreshTokenRequest struct {
+	RefreshToken string `json:"refreshToken"`
+}
+
+// RefreshToken 刷新访问令牌
+func (h *UserHandler) RefreshToken(w http.ResponseWriter, r *http.Request) {
+	var req RefreshTokenRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "无效的请求参数", http.StatusBadRequest)
+		return
+	}
+
+	if req.RefreshToken == "" {
+		http.Error(w, "请求参数错误", http.StatusBadRequest)
+		return
+	}
+
+	tokenResp, err := h.userService.RefreshToken(req.RefreshToken)
+	if err != nil {
+		http.Error(w, "刷新令牌失败: "+err.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	response := model.Response{
+		Code:      200,
+		Message:   "刷新成功",
+		Data:      tokenResponseData(tokenResp),
+		Timestamp: model.NowTimestamp(),
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
+
+// Logout 用户登出
+func (h *UserHandler) Logout(w http.ResponseWriter, r *http.Request) {
+	sessionID, ok := middleware.GetSessionIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "未授权访问", http.StatusUnauthorized)
+		return
+	}
+
+	if err := h.userService.Logout(sessionID); err != nil {
+		http.Error(w, "登出失败: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	response := model.Response{
+		Code:      200,
+		Message:   "登出成功",
+		Timestamp: model.NowTimestamp(),
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
+
+// GetMySessions 获取当前用户的活跃会话
+func (h *UserHandler) GetMySessions(w http.ResponseWriter, r *http.Request) {
+	user, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "未授权访问", http.StatusUnauthorized)
+		return
+	}
+	sessionID, _ := middleware.GetSessionIDFromContext(r.Context())
+
+	sessions, err := h.userService.GetUserSessions(user.ID, sessionID)
+	if err != nil {
+		http.Error(w, "获取会话列表失败: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	response := model.Response{
+		Code:    200,
+		Message: "success",
+		Data: map[string]any{
+			"sessions": sessions,
+			"total":    len(sessions),
+		},
+		Timestamp: model.NowTimestamp(),
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
+
+// RevokeMySession 注销当前用户的指定会话
+func (h *UserHandler) RevokeMySession(w http.ResponseWriter, r *http.Request) {
+	user, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "未授权访问", http.StatusUnauthorized)
+		return
+	}
+
+	sessionID, err := uuid.Parse(r.PathValue("sessionId"))
+	if err != nil {
+		http.Error(w, "无效的会话ID", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.userService.RevokeUserSession(user.ID, sessionID); err != nil {
+		http.Error(w, "注销会话失败: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	response := model.Response{
+		Code:    200,
+		Message: "会话已注销",
+		Data: map[string]any{
+			"sessionId": sessionID.String(),
+		},
+		Timestamp: model.NowTimestamp(),
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
+
+// RevokeOtherSessions 注销当前用户的其他设备会话
+func (h *UserHandler) RevokeOtherSessions(w http.ResponseWriter, r *http.Request) {
+	user, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "未授权访问", http.StatusUnauthorized)
+		return
+	}
+	sessionID, _ := middleware.GetSessionIDFromContext(r.Context())
+
+	count, err := h.userService.RevokeOtherSessions(user.ID, sessionID)
+	if err != nil {
+		http.Error(w, "注销会话失败: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	response := model.Response{
+		Code:    200,
+		Message: "其他设备已下线",
+		Data: map[string]any{
+			"revokedCount": count,
+		},
+		Timestamp: model.NowTimestamp(),
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
+
+// ForceLogoutUser 管理员强制用户下线
+func (h *UserHandler) ForceLogoutUser(w http.ResponseWriter, r *http.Request) {
+	userID, err := uuid.Parse(r.PathValue("userId"))
+	if err != nil {
+		http.Error(w, "无效的用户ID", http.StatusBadRequest)
+		return
+	}
+
+	count, err := h.userService.ForceLogoutUser(userID)
+	if err != nil {
+		http.Error(w, "强制下线失败: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	response := model.Response{
+		Code:    200,
+		Message: "用户已强制下线",
+		Data: map[string]any{
+			"userId":       userID.String(),
+			"revokedCount": count,
+		},
+		Timestamp: model.NowTimestamp(),
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
+
+// GetRoles 获取角色及其权限列表
+func (h *UserHandler) GetRoles(w http.ResponseWriter, r *http.Request) {
+	response := model.Response{
+		Code:    200,
+		Message: "success",
+		Data: map[string]any{
+			"roles": model.RolePermissions,
+		},
+		Timestamp: model.NowTimestamp(),
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
+
+// AssignRoleRequest 分配角色请求
+type AssignRoleRequest struct {
+	UserType model.UserType `json:"userType"`
+}
+
+// AssignRole 分配用户角色
+func (h *UserHandler) AssignRole(w http.ResponseWriter, r *http.Request) {
+	operator, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "未授权访问", http.StatusUnauthorized)
+		return
+	}
+
+	userID, err := uuid.Parse(r.PathValue("userId"))
+	if err != nil {
+		http.Error(w, "无效的用户ID", http.StatusBadRequest)
+		return
+	}
+
+	var req AssignRoleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "无效的请求参数", http.StatusBadRequest)
+		return
+	}
+
+	user, err := h.userService.AssignRole(operator.ID, userID, req.UserType)
+	if err != nil {
+		http.Error(w, "分配角色失败: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	response := model.Response{
+		Code:    200,
+		Message: "角色已更新",
+		Data: map[string]any{
+			"userId":      user.ID.String(),
+			"username":    user.Username,
+			"userType":    user.UserType,
+			"permissions": model.RolePermissions[user.UserType],
+		},
+		Timestamp: model.NowTimestamp(),
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
+
+// ResetPasswordRequest 重置密码请求
+type ResetPasswordRequest struct {
+	ResetToken  string `json:"resetToken"`
+	NewPassword string `json:"newPassword"`
+}
+
+// ResetPassword 使用重置令牌设置新密码
+func (h *UserHandler) ResetPassword(w http.ResponseWriter, r *http.Request) {
+	var req ResetPasswordRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "无效的请求参数", http.StatusBadRequest)
+		return
+	}
+
+	if req.ResetToken == "" || req.NewPassword == "" {
+		http.Error(w, "请求参数错误", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.userService.ResetPassword(req.ResetToken, req.NewPassword); err != nil {
+		http.Error(w, "重置密码失败: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	response := model.Response{
+		Code:      200,
+		Message:   "密码已重置，请重新登录",
+		Timestamp: model.NowTimestamp(),
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
@@ -0,0 +1,188 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/google/uuid"
+
+	"backend/internal/middleware"
+	"backend/internal/model"
+	"backend/internal/service"
+)
+
+// VehicleHandler 车辆处理器
+type VehicleHandler struct {
+	vehicleService *service.VehicleService
+}
+
+// NewVehicleHandler 创建车辆处理器
+func NewVehicleHandler(vehicleService *service.VehicleService) *VehicleHandler {
+	return &VehicleHandler{
+		vehicleService: vehicleService,
+	}
+}
+
+// GetVehicles 获取我的车辆列表
+func (h *VehicleHandler) GetVehicles(w http.ResponseWriter, r *http.Request) {
+	user, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "未授权访问", http.StatusUnauthorized)
+		return
+	}
+
+	vehicles, err := h.vehicleService.GetUserVehicles(user.ID)
+	if err != nil {
+		http.Error(w, "获取车辆列表失败: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	response := model.Response{
+		Code:    200,
+		Message: "success",
+		Data: map[string]any{
+			"vehicles": vehicles,
+			"total":    len(vehicles),
+		},
+		Timestamp: model.NowTimestamp(),
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
+
+// GetVehicle 获取指定车辆
+func (h *VehicleHandler) GetVehicle(w http.ResponseWriter, r *http.Request) {
+	user, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "未授权访问", http.StatusUnauthorized)
+		return
+	}
+
+	vehicleID, err := uuid.Parse(r.PathValue("vehicleId"))
+	if err != nil {
+		http.Error(w, "无效的车辆ID", http.StatusBadRequest)
+		return
+	}
+
+	vehicle, err := h.vehicleService.GetVehicle(user.ID, vehicleID)
+	if err != nil {
+		http.Error(w, "获取车辆失败: "+err.Error(), http.StatusNotFound)
+		return
+	}
+
+	response := model.Response{
+		Code:      200,
+		Message:   "success",
+		Data:      vehicle,
+		Timestamp: model.NowTimestamp(),
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
+
+// CreateVehicle 登记车辆
+func (h *VehicleHandler) CreateVehicle(w http.ResponseWriter, r *http.Request) {
+	user, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "未授权访问", http.StatusUnauthorized)
+		return
+	}
+
+	var req model.VehicleCreate
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "无效的请求参数", http.StatusBadRequest)
+		return
+	}
+
+	vehicle, err := h.vehicleService.CreateVehicle(user.ID, &req)
+	if err != nil {
+		http.Error(w, "登记车辆失败: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	response := model.Response{
+		Code:      200,
+		Message:   "车辆登记成功",
+		Data:      vehicle,
+		Timestamp: model.NowTimestamp(),
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
+
+// UpdateVehicle 更新车辆信息
+func (h *VehicleHandler) UpdateVehicle(w http.ResponseWriter, r *http.Request) {
+	user, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "未授权访问", http.StatusUnauthorized)
+		return
+	}
+
+	vehicleID, err := uuid.Parse(r.PathValue("vehicleId"))
+	if err != nil {
+		http.Error(w, "无效的车辆ID", http.StatusBadRequest)
+		return
+	}
+
+	var req model.VehicleCreate
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "无效的请求参数", http.StatusBadRequest)
+		return
+	}
+
+	vehicle, err := h.vehicleService.UpdateVehicle(user.ID, vehicleID, &req)
+	if err != nil {
+		http.Error(w, "更新车辆失败: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	response := model.Response{
+		Code:      200,
+		Message:   "车辆信息已更新",
+		Data:      vehicle,
+		Timestamp: model.NowTimestamp(),
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
+
+// DeleteVehicle 删除车辆
+func (h *VehicleHandler) DeleteVehicle(w http.ResponseWriter, r *http.Request) {
+	user, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "未授权访问", http.StatusUnauthorized)
+		return
+	}
+
+	vehicleID, err := uuid.Parse(r.PathValue("vehicleId"))
+	if err != nil {
+		http.Error(w, "无效的车辆ID", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.vehicleService.DeleteVehicle(user.ID, vehicleID); err != nil {
+		http.Error(w, "删除车辆失败: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	response := model.Response{
+		Code:    200,
+		Message: "车辆已删除",
+		Data: map[string]any{
+			"vehicleId": vehicleID.String(),
+		},
+		Timestamp: model.NowTimestamp(),
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
@@ -0,0 +1,218 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"backend/internal/middleware"
+	"backend/internal/model"
+	"backend/internal/service"
+
+	"github.com/google/uuid"
+)
+
+// WebhookHandler 第三方webhook订阅处理器
+type WebhookHandler struct {
+	webhookService *service.WebhookService
+}
+
+// NewWebhookHandler 创建webhook订阅处理器
+func NewWebhookHandler(webhookService *service.WebhookService) *WebhookHandler {
+	return &WebhookHandler{
+		webhookService: webhookService,
+	}
+}
+
+// webhookErrorStatus 订阅或投递记录不存在返回404，参数无效返回400，无法重放返回409，其余错误返回500
+func webhookErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, service.ErrWebhookSubscriptionNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, service.ErrInvalidWebhookSubscription):
+		return http.StatusBadRequest
+	case errors.Is(err, service.ErrWebhookDeliveryNotReplayable):
+		return http.StatusConflict
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// writeWebhookResponse 写回webhook操作成功的响应
+func writeWebhookResponse(w http.ResponseWriter, message string, data any) {
+	response := model.Response{
+		Code:      200,
+		Message:   message,
+		Data:      data,
+		Timestamp: model.NowTimestamp(),
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
+
+// GetSubscriptions 查询webhook订阅
+func (h *WebhookHandler) GetSubscriptions(w http.ResponseWriter, r *http.Request) {
+	subscriptions, err := h.webhookService.GetSubscriptions()
+	if err != nil {
+		http.Error(w, "获取webhook订阅失败: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	writeWebhookResponse(w, "success", map[string]any{
+		"subscriptions": subscriptions,
+		"events":        model.WebhookEventTypes,
+	})
+}
+
+// CreateSubscription 创建webhook订阅，响应中的签名密钥只返回这一次
+func (h *WebhookHandler) CreateSubscription(w http.ResponseWriter, r *http.Request) {
+	operator, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "未授权访问", http.StatusUnauthorized)
+		return
+	}
+
+	var req model.WebhookSubscriptionCreate
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "无效的请求参数", http.StatusBadRequest)
+		return
+	}
+
+	subscription, secret, err := h.webhookService.CreateSubscription(operator.ID, &req)
+	if err != nil {
+		http.Error(w, "创建webhook订阅失败: "+err.Error(), webhookErrorStatus(err))
+		return
+	}
+
+	writeWebhookResponse(w, "webhook订阅已创建", map[string]any{
+		"subscription": subscription,
+		"secret":       secret,
+	})
+}
+
+// UpdateSubscription 更新webhook订阅
+func (h *WebhookHandler) UpdateSubscription(w http.ResponseWriter, r *http.Request) {
+	subscriptionID, err := uuid.Parse(r.PathValue("subscriptionId"))
+	if err != nil {
+		http.Error(w, "无效的订阅ID", http.StatusBadRequest)
+		return
+	}
+
+	var req model.WebhookSubscriptionUpdate
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "无效的请求参数", http.StatusBadRequest)
+		return
+	}
+
+	subscription, err := h.webhookService.UpdateSubscription(subscriptionID, &req)
+	if err != nil {
+		http.Error(w, "更新webhook订阅失败: "+err.Error(), webhookErrorStatus(err))
+		return
+	}
+
+	writeWebhookResponse(w, "webhook订阅已更新", subscription)
+}
+
+// DeleteSubscription 删除webhook订阅
+func (h *WebhookHandler) DeleteSubscription(w http.ResponseWriter, r *http.Request) {
+	subscriptionID, err := uuid.Parse(r.PathValue("subscriptionId"))
+	if err != nil {
+		http.Error(w, "无效的订阅ID", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.webhookService.DeleteSubscription(subscriptionID); err != nil {
+		http.Error(w, "删除webhook订阅失败: "+err.Error(), webhookErrorStatus(err))
+		return
+	}
+
+	writeWebhookResponse(w, "webhook订阅已删除", nil)
+}
+
+// RotateSecret 轮换webhook订阅的签名密钥
+func (h *WebhookHandler) RotateSecret(w http.ResponseWriter, r *http.Request) {
+	subscriptionID, err := uuid.Parse(r.PathValue("subscriptionId"))
+	if err != nil {
+		http.Error(w, "无效的订阅ID", http.StatusBadRequest)
+		return
+	}
+
+	secret, err := h.webhookService.RotateSecret(subscriptionID)
+	if err != nil {
+		http.Error(w, "轮换签名密钥失败: "+err.Error(), webhookErrorStatus(err))
+		return
+	}
+
+	writeWebhookResponse(w, "签名密钥已轮换", map[string]any{
+		"secret": secret,
+	})
+}
+
+// ReplayDeadDeliveries 重放订阅的全部死信
+func (h *WebhookHandler) ReplayDeadDeliveries(w http.ResponseWriter, r *http.Request) {
+	subscriptionID, err := uuid.Parse(r.PathValue("subscriptionId"))
+	if err != nil {
+		http.Error(w, "无效的订阅ID", http.StatusBadRequest)
+		return
+	}
+
+	count, err := h.webhookService.ReplayDeadDeliveries(subscriptionID)
+	if err != nil {
+		http.Error(w, "重放死信失败: "+err.Error(), webhookErrorStatus(err))
+		return
+	}
+
+	writeWebhookResponse(w, "死信已重新加入投递队列", map[string]any{
+		"replayed": count,
+	})
+}
+
+// GetDeliveries 查询webhook投递记录，status=dead 即死信队列
+func (h *WebhookHandler) GetDeliveries(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	filter := &model.WebhookDeliveryFilter{
+		Status:    model.WebhookDeliveryStatus(query.Get("status")),
+		EventType: model.WebhookEventType(query.Get("eventType")),
+	}
+	if subscriptionStr := query.Get("subscriptionId"); subscriptionStr != "" {
+		subscriptionID, err := uuid.Parse(subscriptionStr)
+		if err != nil {
+			http.Error(w, "无效的订阅ID", http.StatusBadRequest)
+			return
+		}
+		filter.SubscriptionID = &subscriptionID
+	}
+
+	page, pageSize := parsePagination(r)
+	deliveries, total, err := h.webhookService.GetDeliveries(filter, page, pageSize)
+	if err != nil {
+		http.Error(w, "获取投递记录失败: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	writeWebhookResponse(w, "success", map[string]any{
+		"total":      total,
+		"page":       page,
+		"pageSize":   pageSize,
+		"deliveries": deliveries,
+	})
+}
+
+// ReplayDelivery 重放一条投递记录
+func (h *WebhookHandler) ReplayDelivery(w http.ResponseWriter, r *http.Request) {
+	deliveryID, err := uuid.Parse(r.PathValue("deliveryId"))
+	if err != nil {
+		http.Error(w, "无效的投递记录ID", http.StatusBadRequest)
+		return
+	}
+
+	delivery, err := h.webhookService.ReplayDelivery(deliveryID)
+	if err != nil {
+		http.Error(w, "重放投递失败: "+err.Error(), webhookErrorStatus(err))
+		return
+	}
+
+	writeWebhookResponse(w, "已重新加入投递队列", delivery)
+}
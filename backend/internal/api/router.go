@@ -6,6 +6,8 @@ import (
 	"backend/internal/api/handlers"
 	"backend/internal/config"
 	"backend/internal/middleware"
+	"backend/internal/model"
+	"backend/internal/ocpp"
 	"backend/internal/service"
 )
 
@@ -16,14 +18,27 @@ func SetupRouter(services *service.Services, cfg *config.Config) http.Handler {
 
 	// 中间件
 	auth := middleware.NewAuthMiddleware(services.User)
-	admin := middleware.NewAdminMiddleware() // 创建处理器
+	require := middleware.NewPermissionMiddleware()
+
+	// 创建处理器
 	userHandler := handlers.NewUserHandler(services.User)
 	chargingRequestHandler := handlers.NewChargingRequestHandler(services.ChargingRequest, services.ChargingSessionRepo)
-	chargingPileHandler := handlers.NewChargingPileHandler(services.ChargingPile)
+	chargingPileHandler := handlers.NewChargingPileHandler(services.ChargingPile, services.Scheduler)
 	queueHandler := handlers.NewQueueHandler(services.ChargingRequest, services.System)
 	billingHandler := handlers.NewBillingHandler(services.Billing)
 	systemHandler := handlers.NewSystemHandler(services.System)
-	simulatorHandler := handlers.NewSimulatorHandler(services.ChargingPile, services.Scheduler)
+	simulatorHandler := handlers.NewSimulatorHandler(services.ChargingPile, services.Scheduler, services.SimulatorEvent, services.Reconciler)
+	faultHandler := handlers.NewFaultHandler(services.System, services.ChargingPile, services.Scheduler, services.FaultTicket)
+	vehicleHandler := handlers.NewVehicleHandler(services.Vehicle)
+	commandHandler := handlers.NewCommandHandler(services.CommandQueue, services.Reconciler)
+	pileRegistryHandler := handlers.NewPileRegistryHandler(services.PileRegistry)
+	adminUserHandler := handlers.NewAdminUserHandler(services.User, services.ChargingRequest, services.Billing)
+	interventionHandler := handlers.NewInterventionHandler(services.Scheduler, services.Audit)
+	maintenanceHandler := handlers.NewMaintenanceHandler(services.Maintenance)
+	faultTicketHandler := handlers.NewFaultTicketHandler(services.FaultTicket)
+	reliabilityHandler := handlers.NewReliabilityHandler(services.Reliability)
+	notificationHandler := handlers.NewNotificationHandler(services.Notification)
+	webhookHandler := handlers.NewWebhookHandler(services.Webhook)
 
 	// === 公共接口 ===
 
@@ -41,11 +56,46 @@ func SetupRouter(services *service.Services, cfg *config.Config) http.Handler {
 	// 用户登录
 	mux.HandleFunc("POST /api/v1/auth/login", userHandler.Login)
 
+	// 刷新令牌
+	mux.HandleFunc("POST /api/v1/auth/refresh", userHandler.RefreshToken)
+
+	// 用户登出
+	mux.HandleFunc("POST /api/v1/auth/logout", auth(userHandler.Logout))
+
+	// 使用重置令牌设置新密码
+	mux.HandleFunc("POST /api/v1/auth/password-reset", userHandler.ResetPassword)
+
 	// === 用户接口(需认证) ===
 
 	// 获取用户信息
 	mux.HandleFunc("GET /api/v1/users/{userId}", auth(userHandler.GetUserInfo))
 
+	// 查询我的登录会话
+	mux.HandleFunc("GET /api/v1/users/me/sessions", auth(userHandler.GetMySessions))
+
+	// 注销其他设备
+	mux.HandleFunc("DELETE /api/v1/users/me/sessions", auth(userHandler.RevokeOtherSessions))
+
+	// 注销指定会话
+	mux.HandleFunc("DELETE /api/v1/users/me/sessions/{sessionId}", auth(userHandler.RevokeMySession))
+
+	// === 车辆接口 ===
+
+	// 我的车辆列表
+	mux.HandleFunc("GET /api/v1/vehicles", auth(vehicleHandler.GetVehicles))
+
+	// 登记车辆
+	mux.HandleFunc("POST /api/v1/vehicles", auth(vehicleHandler.CreateVehicle))
+
+	// 获取车辆
+	mux.HandleFunc("GET /api/v1/vehicles/{vehicleId}", auth(vehicleHandler.GetVehicle))
+
+	// 更新车辆
+	mux.HandleFunc("PUT /api/v1/vehicles/{vehicleId}", auth(vehicleHandler.UpdateVehicle))
+
+	// 删除车辆
+	mux.HandleFunc("DELETE /api/v1/vehicles/{vehicleId}", auth(vehicleHandler.DeleteVehicle))
+
 	// === 充电请求接口 ===
 
 	// 提交充电请求
@@ -66,6 +116,12 @@ func SetupRouter(services *service.Services, cfg *config.Config) http.Handler {
 	// 取消充电请求
 	mux.HandleFunc("DELETE /api/v1/charging/requests/{requestId}", auth(chargingRequestHandler.CancelRequest))
 
+	// 候补请求到场签到
+	mux.HandleFunc("POST /api/v1/charging/requests/{requestId}/check-in", auth(chargingRequestHandler.CheckIn))
+
+	// 确认车辆驶离
+	mux.HandleFunc("POST /api/v1/charging/requests/{requestId}/check-out", auth(chargingRequestHandler.CheckOut))
+
 	// === 排队系统接口 ===
 
 	// 查询排队状态
@@ -90,28 +146,142 @@ func SetupRouter(services *service.Services, cfg *config.Config) http.Handler {
 	// 计算预估充电费用
 	mux.HandleFunc("POST /api/v1/billing/calculate", auth(billingHandler.CalculateChargingFee))
 
-	// === 管理员接口 ===
+	// === 通知接口 ===
+
+	// 查询站内信
+	mux.HandleFunc("GET /api/v1/notifications", auth(notificationHandler.GetNotifications))
 
-	// 控制充电桩
-	mux.HandleFunc("POST /api/v1/admin/charging-piles/{pileId}/control", auth(admin(chargingPileHandler.ControlPile)))
+	// 标记站内信已读
+	mux.HandleFunc("POST /api/v1/notifications/{notificationId}/read", auth(notificationHandler.MarkRead))
 
-	// 获取充电桩等候车辆信息
-	mux.HandleFunc("GET /api/v1/admin/charging-piles/queue-vehicles", auth(admin(chargingPileHandler.GetQueueVehicles)))
+	// 全部标记已读
+	mux.HandleFunc("POST /api/v1/notifications/read-all", auth(notificationHandler.MarkAllRead))
 
-	// 充电桩使用报表
-	mux.HandleFunc("GET /api/v1/admin/reports/charging-piles", auth(admin(systemHandler.GetPileUsageReport)))
+	// 查询通知渠道偏好
+	mux.HandleFunc("GET /api/v1/notifications/preferences", auth(notificationHandler.GetPreferences))
+
+	// 更新通知渠道偏好
+	mux.HandleFunc("PUT /api/v1/notifications/preferences", auth(notificationHandler.UpdatePreferences))
+
+	// === 管理员接口 ===
 
-	// 系统运营统计
-	mux.HandleFunc("GET /api/v1/admin/reports/operations", auth(admin(systemHandler.GetOperationStats)))
+	// 管理接口按权限声明，角色与权限的对应关系见 model.RolePermissions
+	adminRoutes := []struct {
+		pattern    string
+		permission model.Permission
+		handler    http.HandlerFunc
+	}{
+		// 控制充电桩
+		{"POST /api/v1/admin/charging-piles/{pileId}/control", model.PermPileControl, chargingPileHandler.ControlPile},
+		// 获取充电桩等候车辆信息
+		{"GET /api/v1/admin/charging-piles/queue-vehicles", model.PermQueueView, chargingPileHandler.GetQueueVehicles},
+		// 充电桩注册的指令下发地址
+		{"GET /api/v1/admin/charging-piles/endpoints", model.PermPileControl, pileRegistryHandler.GetEndpoints},
+		// 迁移充电桩的指令下发地址
+		{"PUT /api/v1/admin/charging-piles/{pileId}/endpoint", model.PermPileControl, pileRegistryHandler.MoveEndpoint},
+		// 设置充电桩的OCPP连接密码
+		{"PUT /api/v1/admin/charging-piles/{pileId}/ocpp-password", model.PermPileControl, chargingPileHandler.SetOCPPPassword},
+		// 计划维护窗口
+		{"POST /api/v1/admin/charging-piles/{pileId}/maintenance-windows", model.PermPileControl, maintenanceHandler.ScheduleMaintenance},
+		// 维护窗口列表
+		{"GET /api/v1/admin/maintenance-windows", model.PermFaultView, maintenanceHandler.GetMaintenanceWindows},
+		// 取消维护窗口
+		{"POST /api/v1/admin/maintenance-windows/{windowId}/cancel", model.PermPileControl, maintenanceHandler.CancelMaintenance},
+		// 维修完成恢复充电桩
+		{"POST /api/v1/admin/charging-piles/{pileId}/repair", model.PermFaultRepair, faultHandler.RepairPile},
+		// 故障记录
+		{"GET /api/v1/admin/faults", model.PermFaultView, faultHandler.GetFaultRecords},
+		// 故障工单列表
+		{"GET /api/v1/admin/fault-tickets", model.PermFaultView, faultTicketHandler.GetTickets},
+		// 故障工单详情
+		{"GET /api/v1/admin/fault-tickets/{ticketId}", model.PermFaultView, faultTicketHandler.GetTicket},
+		// 指派故障工单
+		{"PUT /api/v1/admin/fault-tickets/{ticketId}/assignee", model.PermFaultAssign, faultTicketHandler.AssignTicket},
+		// 流转故障工单状态
+		{"POST /api/v1/admin/fault-tickets/{ticketId}/status", model.PermFaultRepair, faultTicketHandler.TransitionTicket},
+		// 工单备注
+		{"POST /api/v1/admin/fault-tickets/{ticketId}/notes", model.PermFaultRepair, faultTicketHandler.AddNote},
+		// 工单使用的配件
+		{"POST /api/v1/admin/fault-tickets/{ticketId}/parts", model.PermFaultRepair, faultTicketHandler.AddPart},
+		// 卡住的充电指令
+		{"GET /api/v1/admin/commands/stuck", model.PermFaultView, commandHandler.GetStuckCommands},
+		// 后端与模拟器状态对账告警
+		{"GET /api/v1/admin/reconciliation/alerts", model.PermFaultView, commandHandler.GetReconciliationAlerts},
+		// 人工干预：移动排队请求
+		{"POST /api/v1/admin/charging/requests/{requestId}/move", model.PermQueueManage, interventionHandler.MoveRequest},
+		// 人工干预：强制停止充电
+		{"POST /api/v1/admin/charging/requests/{requestId}/force-stop", model.PermQueueManage, interventionHandler.ForceStopCharging},
+		// 人工干预：代用户取消请求
+		{"POST /api/v1/admin/charging/requests/{requestId}/cancel", model.PermQueueManage, interventionHandler.CancelRequest},
+		// 人工干预：调整等候区顺序
+		{"PUT /api/v1/admin/queue/waiting-order", model.PermQueueManage, interventionHandler.ReorderWaitingArea},
+		// 故障调度策略
+		{"GET /api/v1/admin/scheduling/fault-policy", model.PermQueueView, interventionHandler.GetFaultPolicy},
+		// 修改故障调度策略
+		{"PUT /api/v1/admin/scheduling/fault-policy", model.PermSchedulingConfig, interventionHandler.UpdateFaultPolicy},
+		// 人工干预审计日志
+		{"GET /api/v1/admin/audit-logs", model.PermAuditView, interventionHandler.GetAuditLogs},
+		// 账单统计
+		{"GET /api/v1/admin/billing/statistics", model.PermBillingView, billingHandler.GetBillingStatistics},
+		// 充电桩使用报表
+		{"GET /api/v1/admin/reports/charging-piles", model.PermReportPileUsage, systemHandler.GetPileUsageReport},
+		// 系统运营统计
+		{"GET /api/v1/admin/reports/operations", model.PermReportOperations, systemHandler.GetOperationStats},
+		// 充电桩可靠性报表
+		{"GET /api/v1/admin/reports/reliability", model.PermReportPileUsage, reliabilityHandler.GetReliabilityReport},
+		// 用户列表（支持搜索与筛选）
+		{"GET /api/v1/admin/users", model.PermUserView, adminUserHandler.ListUsers},
+		// 用户活动概览
+		{"GET /api/v1/admin/users/{userId}/activity", model.PermUserView, adminUserHandler.GetUserActivity},
+		// 禁用用户
+		{"POST /api/v1/admin/users/{userId}/disable", model.PermUserManage, adminUserHandler.DisableUser},
+		// 启用用户
+		{"POST /api/v1/admin/users/{userId}/enable", model.PermUserManage, adminUserHandler.EnableUser},
+		// 生成密码重置令牌
+		{"POST /api/v1/admin/users/{userId}/password-reset", model.PermUserManage, adminUserHandler.CreatePasswordResetToken},
+		// 强制用户下线
+		{"POST /api/v1/admin/users/{userId}/logout", model.PermUserManage, userHandler.ForceLogoutUser},
+		// 通知投递记录
+		{"GET /api/v1/admin/notifications/deliveries", model.PermUserView, notificationHandler.GetDeliveries},
+		// 第三方webhook订阅
+		{"GET /api/v1/admin/webhooks", model.PermWebhookManage, webhookHandler.GetSubscriptions},
+		// 创建webhook订阅
+		{"POST /api/v1/admin/webhooks", model.PermWebhookManage, webhookHandler.CreateSubscription},
+		// 更新webhook订阅
+		{"PUT /api/v1/admin/webhooks/{subscriptionId}", model.PermWebhookManage, webhookHandler.UpdateSubscription},
+		// 删除webhook订阅
+		{"DELETE /api/v1/admin/webhooks/{subscriptionId}", model.PermWebhookManage, webhookHandler.DeleteSubscription},
+		// 轮换webhook签名密钥
+		{"POST /api/v1/admin/webhooks/{subscriptionId}/rotate-secret", model.PermWebhookManage, webhookHandler.RotateSecret},
+		// 重放订阅的全部死信
+		{"POST /api/v1/admin/webhooks/{subscriptionId}/replay-dead", model.PermWebhookManage, webhookHandler.ReplayDeadDeliveries},
+		// webhook投递记录与死信
+		{"GET /api/v1/admin/webhook-deliveries", model.PermWebhookManage, webhookHandler.GetDeliveries},
+		// 重放webhook投递
+		{"POST /api/v1/admin/webhook-deliveries/{deliveryId}/replay", model.PermWebhookManage, webhookHandler.ReplayDelivery},
+		// 角色及权限列表
+		{"GET /api/v1/admin/roles", model.PermRoleAssign, userHandler.GetRoles},
+		// 分配用户角色
+		{"PUT /api/v1/admin/users/{userId}/role", model.PermRoleAssign, userHandler.AssignRole},
+	}
+	for _, route := range adminRoutes {
+		mux.HandleFunc(route.pattern, auth(require(route.permission)(route.handler)))
+	}
 
 	// === 模拟器接口 ===
 
+	// 充电桩自注册
+	mux.HandleFunc("POST /api/v1/simulator/register", pileRegistryHandler.Register)
+
 	// 充电进度更新
 	mux.HandleFunc("POST /api/v1/simulator/charging-progress", simulatorHandler.UpdateChargingProgress)
 
 	// 充电完成上报
 	mux.HandleFunc("POST /api/v1/simulator/charging-complete", simulatorHandler.CompleteCharging)
 
+	// 车辆驶离通知
+	mux.HandleFunc("POST /api/v1/simulator/vehicle-departed", simulatorHandler.VehicleDeparted)
+
 	// 故障报告
 	mux.HandleFunc("POST /api/v1/simulator/fault-report", simulatorHandler.ReportFault)
 
@@ -121,6 +291,21 @@ func SetupRouter(services *service.Services, cfg *config.Config) http.Handler {
 	// 模拟器心跳检测
 	mux.HandleFunc("POST /api/v1/simulator/heartbeat", simulatorHandler.Heartbeat)
 
+	// 充电桩状态推送（对账）
+	mux.HandleFunc("POST /api/v1/simulator/pile-status", simulatorHandler.UpdatePileStatus)
+
+	// === OCPP 1.6-J 中央系统 ===
+
+	if cfg.OCPP.Enabled {
+		centralSystem := ocpp.NewCentralSystem(services, cfg.OCPP)
+		// 已连接OCPP的充电桩改用远程启停，其余仍走模拟器HTTP接口
+		services.CommandQueue.SetDispatcher(ocpp.NewDispatcher(centralSystem, services.SimulatorClient))
+		services.ChargingPile.SetAvailabilityController(centralSystem)
+
+		// 充电桩WebSocket连接
+		mux.HandleFunc("GET /ocpp/{chargePointId}", centralSystem.ServeWS)
+	}
+
 	// 应用CORS中间件
 	corsHandler := middleware.CORSMiddleware(mux)
 	return corsHandler
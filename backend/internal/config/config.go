@@ -7,11 +7,22 @@ import (
 
 // Config 应用程序配置结构
 type Config struct {
-	Server   ServerConfig   `json:"server"`
-	Database DatabaseConfig `json:"database"`
-	Auth     AuthConfig     `json:"auth"`
-	Charging ChargingConfig `json:"charging"`
-	Pricing  PricingConfig  `json:"pricing"`
+	Server       ServerConfig       `json:"server"`
+	Database     DatabaseConfig     `json:"database"`
+	Auth         AuthConfig         `json:"auth"`
+	Charging     ChargingConfig     `json:"charging"`
+	Pricing      PricingConfig      `json:"pricing"`
+	OCPP         OCPPConfig         `json:"ocpp"`
+	Dispatch     DispatchConfig     `json:"dispatch"`
+	Reconcile    ReconcileConfig    `json:"reconcile"`
+	Maintenance  MaintenanceConfig  `json:"maintenance"`
+	FaultTicket  FaultTicketConfig  `json:"faultTicket"`
+	Reliability  ReliabilityConfig  `json:"reliability"`
+	Notification NotificationConfig `json:"notification"`
+	Webhook      WebhookConfig      `json:"webhook"`
+	Overstay     OverstayConfig     `json:"overstay"`
+	QueueNumber  QueueNumberConfig  `json:"queueNumber"`
+	Waitlist     WaitlistConfig     `json:"waitlist"`
 }
 
 // ServerConfig 服务器配置
@@ -35,8 +46,9 @@ type DatabaseConfig struct {
 
 // AuthConfig 认证配置
 type AuthConfig struct {
-	JWTSecret        string `json:"jwtSecret"`
-	JWTExpirationMin int    `json:"jwtExpirationMin"`
+	JWTSecret             string `json:"jwtSecret"`
+	JWTExpirationMin      int    `json:"jwtExpirationMin"`      // 访问令牌有效期（分钟）
+	RefreshExpirationDays int    `json:"refreshExpirationDays"` // 刷新令牌有效期（天）
 }
 
 // ChargingConfig 充电系统配置
@@ -49,6 +61,103 @@ type ChargingConfig struct {
 	TrickleChargingPower   float64 `json:"trickleChargingPower"`
 	ServiceFeePerUnit      float64 `json:"serviceFeePerUnit"`
 	ExtendedSchedulingMode string  `json:"extendedSchedulingMode"` // "disabled", "batch"
+
+	FaultReschedulingPolicy string `json:"faultReschedulingPolicy"` // "priority", "time_order"，仅作为system_config的初始值
+}
+
+// OCPPConfig OCPP 1.6-J 中央系统配置
+type OCPPConfig struct {
+	Enabled           bool `json:"enabled"`           // 是否启用OCPP接入
+	HeartbeatInterval int  `json:"heartbeatInterval"` // BootNotification下发的心跳间隔（秒）
+	CallTimeout       int  `json:"callTimeout"`       // 下发指令等待响应的超时（秒）
+}
+
+// DispatchConfig 充电指令下发配置
+type DispatchConfig struct {
+	AckTimeout      int    `json:"ackTimeout"`      // 指令超过该时间仍未被确认则转故障处理（秒）
+	MaxBackoff      int    `json:"maxBackoff"`      // 重试间隔上限（秒）
+	DefaultEndpoint string `json:"defaultEndpoint"` // 未自注册的充电桩使用的模拟器地址
+
+	RegistrationSecret string `json:"registrationSecret"` // 充电桩自注册的共享密钥，为空时拒绝自注册
+}
+
+// ReconcileConfig 后端与模拟器状态对账配置
+type ReconcileConfig struct {
+	Mode         string `json:"mode"`         // alert: 仅告警；correct: 自动纠正可确定的不一致
+	GracePeriod  int    `json:"gracePeriod"`  // 不一致持续超过该时间才处理（秒），避开指令投递中的短暂不一致
+	PullInterval int    `json:"pullInterval"` // 主动拉取模拟器状态的间隔（秒），0表示只依赖模拟器推送
+}
+
+// MaintenanceConfig 计划维护配置
+type MaintenanceConfig struct {
+	LeadTime      int `json:"leadTime"`      // 维护开始前多久停止分配会与维护重叠的车辆并转移排队车辆（秒）
+	CheckInterval int `json:"checkInterval"` // 检查维护窗口开始与结束的间隔（秒）
+}
+
+// FaultTicketConfig 故障工单SLA配置
+type FaultTicketConfig struct {
+	AckSLA     int `json:"ackSLA"`     // 故障发生后须在该时间内受理（秒）
+	ResolveSLA int `json:"resolveSLA"` // 故障发生后须在该时间内修复（秒）
+}
+
+// ReliabilityConfig 可靠性报表告警阈值
+type ReliabilityConfig struct {
+	AlertFaultCount int     `json:"alertFaultCount"` // 统计窗口内故障次数达到该值时标记充电桩需要巡检
+	AlertWindow     int     `json:"alertWindow"`     // 故障次数统计窗口（秒）
+	MinAvailability float64 `json:"minAvailability"` // 可用率低于该百分比时标记充电桩需要巡检，0表示不检查
+}
+
+// NotificationConfig 通知配置
+type NotificationConfig struct {
+	MaxAttempts    int                             `json:"maxAttempts"`    // 外部渠道最大投递次数，超过后记为失败
+	MaxBackoff     int                             `json:"maxBackoff"`     // 重试间隔上限（秒）
+	WebhookTimeout int                             `json:"webhookTimeout"` // Webhook请求超时（秒）
+	SMTP           SMTPConfig                      `json:"smtp"`
+	Templates      map[string]NotificationTemplate `json:"templates"` // 按事件覆盖默认模板
+}
+
+// SMTPConfig 邮件发送配置，Host为空时不启用邮件渠道
+type SMTPConfig struct {
+	Host     string `json:"host"`
+	Port     int    `json:"port"`
+	Username string `json:"username"` // 为空时不进行认证，便于对接本地SMTP测试服务
+	Password string `json:"password"`
+	From     string `json:"from"`
+}
+
+// NotificationTemplate 通知模板，使用 text/template 语法，可引用事件数据字段
+type NotificationTemplate struct {
+	Title string `json:"title"`
+	Body  string `json:"body"`
+}
+
+// WebhookConfig 第三方webhook推送配置
+type WebhookConfig struct {
+	MaxAttempts int `json:"maxAttempts"` // 最大投递次数，超过后进入死信
+	MaxBackoff  int `json:"maxBackoff"`  // 重试间隔上限（秒）
+	Timeout     int `json:"timeout"`     // 单次请求超时（秒）
+}
+
+// OverstayConfig 充电结束后超时占位配置
+type OverstayConfig struct {
+	Enabled          bool    `json:"enabled"`          // 是否跟踪车辆驶离，关闭时充电结束即视为驶离
+	GracePeriod      int     `json:"gracePeriod"`      // 充电结束后的免费停留时间（秒）
+	IdleFeePerMinute float64 `json:"idleFeePerMinute"` // 超出免费停留时间后每分钟的占位费
+}
+
+// QueueNumberConfig 队列号配置
+type QueueNumberConfig struct {
+	StationID string `json:"stationId"` // 队列号序列所属站点，多个后端实例服务同一站点时需一致
+	ResetTime []int  `json:"resetTime"` // 每日重置时间，格式为 [小时, 分钟]
+	Timezone  string `json:"timezone"`  // 重置时间所在时区，如 Asia/Shanghai，为空时使用服务器本地时区
+}
+
+// WaitlistConfig 候补队列配置
+type WaitlistConfig struct {
+	Enabled       bool `json:"enabled"`       // 等候区已满时是否进入候补队列
+	MaxSize       int  `json:"maxSize"`       // 候补队列容量，0表示不限
+	ArrivalWindow int  `json:"arrivalWindow"` // 获得入场资格后到场签到的时限（秒）
+	CheckInterval int  `json:"checkInterval"` // 检查到场超时的间隔（秒）
 }
 
 // PricingConfig 计价配置
@@ -7,6 +7,8 @@ import (
 
 	"backend/internal/model"
 	"backend/internal/service"
+
+	"github.com/google/uuid"
 )
 
 // UserContextKey 用户上下文键
@@ -15,6 +17,9 @@ type UserContextKey string
 // UserKey 用户键
 const UserKey UserContextKey = "user"
 
+// SessionKey 会话键
+const SessionKey UserContextKey = "session"
+
 // NewAuthMiddleware 创建认证中间件
 func NewAuthMiddleware(userService *service.UserService) func(http.HandlerFunc) http.HandlerFunc {
 	return func(next http.HandlerFunc) http.HandlerFunc {
@@ -34,22 +39,29 @@ func NewAuthMiddleware(userService *service.UserService) func(http.HandlerFunc)
 			}
 			token := tokenParts[1]
 
-			// 验证令牌
-			userID, err := userService.ValidateToken(token)
+			// 验证令牌（吊销检查走内存缓存）
+			claims, err := userService.ValidateToken(token)
 			if err != nil {
 				http.Error(w, "认证失败："+err.Error(), http.StatusUnauthorized)
 				return
 			}
 
 			// 根据ID获取用户信息
-			user, err := userService.GetUserByID(*userID)
+			user, err := userService.GetUserByID(claims.UserID)
 			if err != nil {
 				http.Error(w, "认证失败：获取用户信息失败", http.StatusUnauthorized)
 				return
 			}
 
+			// 拒绝已禁用的账户
+			if user.IsDisabled() {
+				http.Error(w, "账户已被禁用", http.StatusForbidden)
+				return
+			}
+
 			// 将用户信息添加到请求上下文
 			ctx := context.WithValue(r.Context(), UserKey, user)
+			ctx = context.WithValue(ctx, SessionKey, claims.SessionID)
 			next.ServeHTTP(w, r.WithContext(ctx))
 		}
 	}
@@ -61,24 +73,32 @@ func GetUserFromContext(ctx context.Context) (*model.User, bool) {
 	return user, ok
 }
 
-// NewAdminMiddleware 创建管理员中间件
-func NewAdminMiddleware() func(http.HandlerFunc) http.HandlerFunc {
-	return func(next http.HandlerFunc) http.HandlerFunc {
-		return func(w http.ResponseWriter, r *http.Request) {
-			// 从上下文获取用户
-			user, ok := GetUserFromContext(r.Context())
-			if !ok {
-				http.Error(w, "未授权访问", http.StatusUnauthorized)
-				return
-			}
+// GetSessionIDFromContext 从上下文获取当前会话ID
+func GetSessionIDFromContext(ctx context.Context) (uuid.UUID, bool) {
+	sessionID, ok := ctx.Value(SessionKey).(uuid.UUID)
+	return sessionID, ok
+}
 
-			// 检查是否为管理员
-			if user.UserType != "admin" {
-				http.Error(w, "权限不足", http.StatusForbidden)
-				return
-			}
+// NewPermissionMiddleware 创建权限中间件，返回按权限生成中间件的函数
+func NewPermissionMiddleware() func(model.Permission) func(http.HandlerFunc) http.HandlerFunc {
+	return func(perm model.Permission) func(http.HandlerFunc) http.HandlerFunc {
+		return func(next http.HandlerFunc) http.HandlerFunc {
+			return func(w http.ResponseWriter, r *http.Request) {
+				// 从上下文获取用户
+				user, ok := GetUserFromContext(r.Context())
+				if !ok {
+					http.Error(w, "未授权访问", http.StatusUnauthorized)
+					return
+				}
+
+				// 检查角色权限
+				if !model.HasPermission(user.UserType, perm) {
+					http.Error(w, "权限不足", http.StatusForbidden)
+					return
+				}
 
-			next.ServeHTTP(w, r)
+				next.ServeHTTP(w, r)
+			}
 		}
 	}
 }
@@ -0,0 +1,35 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// AuditAction 审计操作类型
+type AuditAction string
+
+const (
+	AuditActionMoveRequest      AuditAction = "move_request"      // 移动排队请求到其他充电桩或等候区
+	AuditActionForceStop        AuditAction = "force_stop"        // 强制停止充电
+	AuditActionReorderWaiting   AuditAction = "reorder_waiting"   // 调整等候区顺序
+	AuditActionCancelRequest    AuditAction = "cancel_request"    // 代用户取消请求
+	AuditActionOverrideRecovery AuditAction = "override_recovery" // 故障工单未修复时强制恢复充电桩
+	AuditActionFaultPolicy      AuditAction = "fault_policy"      // 修改故障调度策略
+	AuditActionFaultReschedule  AuditAction = "fault_reschedule"  // 故障调度，记录所用策略与调度结果
+	AuditActionMoveEndpoint     AuditAction = "move_endpoint"     // 迁移充电桩的指令下发地址
+)
+
+// AuditLog 审计日志
+type AuditLog struct {
+	ID           uuid.UUID      `json:"id"`
+	OperatorID   *uuid.UUID     `json:"operatorId,omitempty"` // 操作人，系统操作为空
+	Action       AuditAction    `json:"action"`
+	RequestID    *uuid.UUID     `json:"requestId,omitempty"`
+	PileID       string         `json:"pileId,omitempty"`
+	Reason       string         `json:"reason,omitempty"`
+	Detail       map[string]any `json:"detail,omitempty"` // 操作前后的状态
+	Success      bool           `json:"success"`
+	ErrorMessage string         `json:"errorMessage,omitempty"`
+	CreatedAt    time.Time      `json:"createdAt"`
+}
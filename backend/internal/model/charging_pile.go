@@ -2,6 +2,8 @@ package model
 
 import (
 	"time"
+
+	"github.com/google/uuid"
 )
 
 // PileType 表示充电桩类型
@@ -25,18 +27,45 @@ const (
 
 // ChargingPile 充电桩模型
 type ChargingPile struct {
-	ID            string     `json:"id"`            // A, B, C, D, E
-	PileType      PileType   `json:"pileType"`      // fast/slow
-	Power         float64    `json:"power"`         // 充电功率(度/小时)
-	Status        PileStatus `json:"status"`        // 充电桩状态
-	QueueLength   int        `json:"queueLength"`   // 队列长度
-	TotalSessions int        `json:"totalSessions"` // 累计充电次数
-	TotalDuration float64    `json:"totalDuration"` // 累计充电时长(小时)
-	TotalEnergy   float64    `json:"totalEnergy"`   // 累计充电电量(度)
+	ID            string     `json:"id"`                      // A, B, C, D, E
+	PileType      PileType   `json:"pileType"`                // fast/slow
+	Power         float64    `json:"power"`                   // 充电功率(度/小时)
+	DegradedPower *float64   `json:"degradedPower,omitempty"` // 降功率运行时的实际功率，为空时按额定功率运行
+	Status        PileStatus `json:"status"`                  // 充电桩状态
+	QueueLength   int        `json:"queueLength"`             // 队列长度
+	TotalSessions int        `json:"totalSessions"`           // 累计充电次数
+	TotalDuration float64    `json:"totalDuration"`           // 累计充电时长(小时)
+	TotalEnergy   float64    `json:"totalEnergy"`             // 累计充电电量(度)
 	CreatedAt     time.Time  `json:"createdAt"`
 	UpdatedAt     time.Time  `json:"updatedAt"`
 }
 
+// IsDegraded 是否降功率运行
+func (p *ChargingPile) IsDegraded() bool {
+	return p.DegradedPower != nil
+}
+
+// EffectivePower 当前实际充电功率，降功率运行时为降低后的功率
+func (p *ChargingPile) EffectivePower() float64 {
+	if p.DegradedPower != nil {
+		return *p.DegradedPower
+	}
+	return p.Power
+}
+
+// PileDegradation 充电桩降功率运行时段
+type PileDegradation struct {
+	ID             uuid.UUID  `json:"id"`
+	PileID         string     `json:"pileId"`
+	FaultType      FaultType  `json:"faultType"`
+	Description    string     `json:"description"`
+	RatedPower     float64    `json:"ratedPower"`     // 额定功率
+	EffectivePower float64    `json:"effectivePower"` // 降功率运行时的实际功率
+	StartedAt      time.Time  `json:"startedAt"`
+	EndedAt        *time.Time `json:"endedAt,omitempty"`
+	CreatedAt      time.Time  `json:"createdAt"`
+}
+
 // ChargingPileControlRequest 充电桩控制请求
 type ChargingPileControlRequest struct {
 	Action string `json:"action" binding:"required,oneof=start stop maintenance"` // start/stop/maintenance
@@ -1,6 +1,9 @@
 package model
 
 import (
+	"errors"
+	"math"
+	"strconv"
 	"time"
 
 	"github.com/google/uuid"
@@ -18,38 +21,104 @@ const (
 type RequestStatus string
 
 const (
-	RequestStatusWaiting   RequestStatus = "waiting"   // 等候区等待
-	RequestStatusQueued    RequestStatus = "queued"    // 进入充电桩队列
-	RequestStatusCharging  RequestStatus = "charging"  // 正在充电
-	RequestStatusCompleted RequestStatus = "completed" // 充电完成
-	RequestStatusCancelled RequestStatus = "cancelled" // 已取消
+	RequestStatusWaitlisted RequestStatus = "waitlisted" // 等候区已满，在候补队列等待入场
+	RequestStatusWaiting    RequestStatus = "waiting"    // 等候区等待
+	RequestStatusQueued     RequestStatus = "queued"     // 进入充电桩队列
+	RequestStatusCharging   RequestStatus = "charging"   // 正在充电
+	RequestStatusCompleted  RequestStatus = "completed"  // 充电完成
+	RequestStatusCancelled  RequestStatus = "cancelled"  // 已取消
 )
 
 // ChargingRequest 充电请求
 type ChargingRequest struct {
 	ID                uuid.UUID     `json:"id"`
 	UserID            uuid.UUID     `json:"userId"`
-	ChargingMode      ChargingMode  `json:"chargingMode"`      // fast/slow
-	RequestedCapacity float64       `json:"requestedCapacity"` // 请求充电量(度)
-	QueueNumber       string        `json:"queueNumber"`       // F1, T1 等
-	PileID            string        `json:"pileId,omitempty"`  // 分配的充电桩ID
-	QueuePosition     int           `json:"queuePosition"`     // 队列位置
-	Status            RequestStatus `json:"status"`            // waiting/queued/charging/completed/cancelled
-	EstimatedWaitTime int           `json:"estimatedWaitTime"` // 预估等待时间(秒)
+	VehicleID         uuid.UUID     `json:"vehicleId"`                 // 充电车辆
+	ChargingMode      ChargingMode  `json:"chargingMode"`              // fast/slow
+	RequestedCapacity float64       `json:"requestedCapacity"`         // 请求充电量(度)
+	QueueNumber       string        `json:"queueNumber"`               // F1, T1 等
+	QueueDate         time.Time     `json:"queueDate"`                 // 队列号所属营业日，每日重置后序号从1开始
+	PileID            string        `json:"pileId,omitempty"`          // 分配的充电桩ID
+	QueuePosition     int           `json:"queuePosition"`             // 队列位置
+	Status            RequestStatus `json:"status"`                    // waiting/queued/charging/completed/cancelled
+	EstimatedWaitTime int           `json:"estimatedWaitTime"`         // 预估等待时间(秒)
+	StartSoC          *float64      `json:"startSoc,omitempty"`        // 起始电量百分比
+	TargetSoC         *float64      `json:"targetSoc,omitempty"`       // 目标电量百分比
+	Priority          int           `json:"priority"`                  // 等候区优先级，越大越先调度，默认0
+	ArrivalDeadline   *time.Time    `json:"arrivalDeadline,omitempty"` // 候补入场后须在此之前到场签到
 	CreatedAt         time.Time     `json:"createdAt"`
 	UpdatedAt         time.Time     `json:"updatedAt"`
 }
 
+// QueueSequence 队列号中的序号部分，如 F10 为 10
+func (r *ChargingRequest) QueueSequence() int {
+	if r.QueueNumber == "" {
+		return 0
+	}
+	seq, _ := strconv.Atoi(r.QueueNumber[1:])
+	return seq
+}
+
+// QueuedBefore 是否比另一请求先取号：先比较营业日，再按序号数值比较（F2 在 F10 之前）
+func (r *ChargingRequest) QueuedBefore(other *ChargingRequest) bool {
+	if !r.QueueDate.Equal(other.QueueDate) {
+		return r.QueueDate.Before(other.QueueDate)
+	}
+	if r.QueueSequence() != other.QueueSequence() {
+		return r.QueueSequence() < other.QueueSequence()
+	}
+	return r.CreatedAt.Before(other.CreatedAt)
+}
+
+// IsAdmitted 候补请求是否已获得入场资格（等候区位置已为其保留）
+func (r *ChargingRequest) IsAdmitted() bool {
+	return r.Status == RequestStatusWaitlisted && r.ArrivalDeadline != nil
+}
+
+// WaitlistStatus 候补队列状态
+type WaitlistStatus struct {
+	RequestID              uuid.UUID  `json:"requestId"`
+	Position               int        `json:"position"`                  // 候补位置，已获得入场资格时为0
+	WaitlistLength         int        `json:"waitlistLength"`            // 尚未获得入场资格的候补请求数
+	EstimatedAdmissionTime int        `json:"estimatedAdmissionTime"`    // 预计入场等待时间(秒)
+	ArrivalDeadline        *time.Time `json:"arrivalDeadline,omitempty"` // 已获得入场资格时的到场截止时间
+}
+
 // ChargingRequestCreate 创建充电请求
 type ChargingRequestCreate struct {
-	ChargingMode      ChargingMode `json:"chargingMode" binding:"required,oneof=fast slow"`
-	RequestedCapacity float64      `json:"requestedCapacity" binding:"required,gt=0"`
+	VehicleID         uuid.UUID    `json:"vehicleId"`                                             // 为空时使用用户唯一的车辆
+	ChargingMode      ChargingMode `json:"chargingMode" binding:"omitempty,oneof=fast slow"`      // 为空时使用车辆默认模式
+	RequestedCapacity float64      `json:"requestedCapacity" binding:"omitempty,gt=0"`            // 与SoC二选一
+	StartSoC          *float64     `json:"startSoc,omitempty" binding:"omitempty,gte=0,lte=100"`  // 当前电量百分比
+	TargetSoC         *float64     `json:"targetSoc,omitempty" binding:"omitempty,gte=0,lte=100"` // 目标电量百分比
 }
 
 // ChargingRequestUpdate 更新充电请求
 type ChargingRequestUpdate struct {
 	ChargingMode      ChargingMode `json:"chargingMode,omitempty" binding:"omitempty,oneof=fast slow"`
 	RequestedCapacity float64      `json:"requestedCapacity,omitempty" binding:"omitempty,gt=0"`
+	TargetSoC         *float64     `json:"targetSoc,omitempty" binding:"omitempty,gte=0,lte=100"` // 修改目标电量百分比
+}
+
+// HasSoCTarget 是否按SoC目标创建请求
+func (c *ChargingRequestCreate) HasSoCTarget() bool {
+	return c.StartSoC != nil && c.TargetSoC != nil
+}
+
+// ValidateSoCRange 校验起始与目标电量百分比
+func ValidateSoCRange(startSoC, targetSoC float64) error {
+	if startSoC < 0 || startSoC > 100 || targetSoC < 0 || targetSoC > 100 {
+		return errors.New("电量百分比必须在0到100之间")
+	}
+	if targetSoC <= startSoC {
+		return errors.New("目标电量必须高于当前电量")
+	}
+	return nil
+}
+
+// CapacityForSoC 根据电池容量计算从起始到目标SoC所需电量(度)
+func CapacityForSoC(batteryCapacity, startSoC, targetSoC float64) float64 {
+	return math.Round((targetSoC-startSoC)/100*batteryCapacity*100) / 100
 }
 
 // QueueItem 队列项
@@ -70,6 +139,7 @@ type QueueStatus struct {
 	FastQueue      []QueueItem `json:"fastQueue"`      // 快充队列
 	SlowQueue      []QueueItem `json:"slowQueue"`      // 慢充队列
 	AvailableSlots int         `json:"availableSlots"` // 等候区可用车位
+	WaitlistLength int         `json:"waitlistLength"` // 候补队列中尚未获得入场资格的请求数
 }
 
 // UserPosition 用户在队列中的位置
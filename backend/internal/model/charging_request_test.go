@@ -0,0 +1,132 @@
+package model
+
+import (
+	"testing"
+	"time"
+)
+
+func TestValidateSoCRange(t *testing.T) {
+	tests := []struct {
+		name      string
+		startSoC  float64
+		targetSoC float64
+		wantErr   bool
+	}{
+		{name: "正常范围", startSoC: 20, targetSoC: 80},
+		{name: "从0充到100", startSoC: 0, targetSoC: 100},
+		{name: "目标等于当前", startSoC: 50, targetSoC: 50, wantErr: true},
+		{name: "目标低于当前", startSoC: 80, targetSoC: 20, wantErr: true},
+		{name: "当前为负数", startSoC: -1, targetSoC: 50, wantErr: true},
+		{name: "目标超过100", startSoC: 20, targetSoC: 100.1, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateSoCRange(tt.startSoC, tt.targetSoC)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidateSoCRange(%v, %v) error = %v, wantErr %v", tt.startSoC, tt.targetSoC, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestCapacityForSoC(t *testing.T) {
+	tests := []struct {
+		name            string
+		batteryCapacity float64
+		startSoC        float64
+		targetSoC       float64
+		want            float64
+	}{
+		{name: "60度电池充20%到80%", batteryCapacity: 60, startSoC: 20, targetSoC: 80, want: 36},
+		{name: "充满", batteryCapacity: 75, startSoC: 0, targetSoC: 100, want: 75},
+		{name: "保留两位小数", batteryCapacity: 55.5, startSoC: 33, targetSoC: 66, want: 18.32},
+		{name: "起止相同", batteryCapacity: 60, startSoC: 50, targetSoC: 50, want: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := CapacityForSoC(tt.batteryCapacity, tt.startSoC, tt.targetSoC); got != tt.want {
+				t.Errorf("CapacityForSoC(%v, %v, %v) = %v, want %v", tt.batteryCapacity, tt.startSoC, tt.targetSoC, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestQueueSequence(t *testing.T) {
+	tests := []struct {
+		queueNumber string
+		want        int
+	}{
+		{"", 0},
+		{"F1", 1},
+		{"F10", 10},
+		{"T123", 123},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.queueNumber, func(t *testing.T) {
+			r := &ChargingRequest{QueueNumber: tt.queueNumber}
+			if got := r.QueueSequence(); got != tt.want {
+				t.Errorf("QueueSequence() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestQueuedBefore(t *testing.T) {
+	day1 := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	day2 := day1.AddDate(0, 0, 1)
+	created := day1.Add(8 * time.Hour)
+
+	tests := []struct {
+		name string
+		a, b *ChargingRequest
+		want bool
+	}{
+		{
+			name: "同一营业日按序号数值比较",
+			a:    &ChargingRequest{QueueNumber: "F2", QueueDate: day1, CreatedAt: created},
+			b:    &ChargingRequest{QueueNumber: "F10", QueueDate: day1, CreatedAt: created},
+			want: true,
+		},
+		{
+			name: "同一营业日序号大的在后",
+			a:    &ChargingRequest{QueueNumber: "F10", QueueDate: day1, CreatedAt: created},
+			b:    &ChargingRequest{QueueNumber: "F2", QueueDate: day1, CreatedAt: created},
+			want: false,
+		},
+		{
+			name: "前一营业日的大序号在次日小序号之前",
+			a:    &ChargingRequest{QueueNumber: "F30", QueueDate: day1, CreatedAt: created},
+			b:    &ChargingRequest{QueueNumber: "F1", QueueDate: day2, CreatedAt: created.Add(time.Hour)},
+			want: true,
+		},
+		{
+			name: "次日的小序号在前一营业日之后",
+			a:    &ChargingRequest{QueueNumber: "F1", QueueDate: day2, CreatedAt: created.Add(time.Hour)},
+			b:    &ChargingRequest{QueueNumber: "F30", QueueDate: day1, CreatedAt: created},
+			want: false,
+		},
+		{
+			name: "序号相同按创建时间",
+			a:    &ChargingRequest{QueueNumber: "F3", QueueDate: day1, CreatedAt: created},
+			b:    &ChargingRequest{QueueNumber: "T3", QueueDate: day1, CreatedAt: created.Add(time.Minute)},
+			want: true,
+		},
+		{
+			name: "完全相同不在之前",
+			a:    &ChargingRequest{QueueNumber: "F3", QueueDate: day1, CreatedAt: created},
+			b:    &ChargingRequest{QueueNumber: "F3", QueueDate: day1, CreatedAt: created},
+			want: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.a.QueuedBefore(tt.b); got != tt.want {
+				t.Errorf("%s.QueuedBefore(%s) = %v, want %v", tt.a.QueueNumber, tt.b.QueueNumber, got, tt.want)
+			}
+		})
+	}
+}
@@ -17,11 +17,45 @@ type ChargingSession struct {
 	ActualCapacity    float64       `json:"chargedCapacity"`
 	StartTime         time.Time     `json:"startTime"`
 	EndTime           *time.Time    `json:"endTime,omitempty"`
+	DepartedAt        *time.Time    `json:"departedAt,omitempty"` // 车辆驶离时间，充电结束后为空表示车辆仍占用桩位
 	Status            SessionStatus `json:"status"`
-	Duration          float64       `json:"chargingDuration"` // 充电时长(秒)
+	Duration          float64       `json:"chargingDuration"`            // 充电时长(秒)
+	StartSoC          *float64      `json:"startSoc,omitempty"`          // 起始电量百分比
+	TargetSoC         *float64      `json:"targetSoc,omitempty"`         // 目标电量百分比
+	CurrentSoC        *float64      `json:"currentSoc,omitempty"`        // 当前电量百分比
+	CurrentPower      *float64      `json:"currentPower,omitempty"`      // 瞬时充电功率(kW)
+	RemainingTime     *int          `json:"remainingTime,omitempty"`     // 预计剩余充电时间(秒)
+	OCPPTransactionID *int          `json:"ocppTransactionId,omitempty"` // OCPP交易号
+	OCPPMeterStart    *int          `json:"-"`                           // OCPP交易起始电表读数(Wh)
 	CreatedAt         time.Time     `json:"createdAt"`
 }
 
+// IsParked 充电已结束但车辆尚未驶离
+func (s *ChargingSession) IsParked() bool {
+	return s.EndTime != nil && s.DepartedAt == nil
+}
+
+// VehicleDeparture 车辆驶离结果
+type VehicleDeparture struct {
+	SessionID   uuid.UUID `json:"sessionId"`
+	PileID      string    `json:"pileId"`
+	EndTime     time.Time `json:"endTime"`     // 充电结束时间
+	DepartedAt  time.Time `json:"departedAt"`  // 驶离时间
+	IdleMinutes int       `json:"idleMinutes"` // 超出免费停留时间的占位分钟数
+	IdleFee     float64   `json:"idleFee"`     // 占位费
+}
+
+// ChargingProgress 模拟器上报的充电进度
+type ChargingProgress struct {
+	SessionID       uuid.UUID // 充电会话ID，旧版模拟器未上报时为uuid.Nil
+	PileID          string
+	UserID          string
+	CurrentCapacity float64  // 已充电量(度)
+	CurrentSoC      *float64 // 当前电量百分比，未上报时为nil
+	CurrentPower    float64  // 瞬时充电功率(kW)
+	RemainingTime   int      // 预计剩余时间(秒)
+}
+
 // SessionStatus 充电会话状态
 type SessionStatus string
 
@@ -39,19 +73,24 @@ type BillingDetail struct {
 	PileID            string    `json:"pileId"`
 	ChargingCapacity  float64   `json:"chargingCapacity"`
 	ChargingDuration  float64   `json:"chargingDuration"` // 小时
+	DegradedDuration  float64   `json:"degradedDuration"` // 充电桩降功率运行期间的充电时长（小时）
 	StartTime         time.Time `json:"startTime"`
 	EndTime           time.Time `json:"endTime"`
-	UnitPrice         float64   `json:"unitPrice"`         // 电价单价
-	PriceType         string    `json:"priceType"`         // 价格类型(peak/normal/valley)
-	ChargingFee       float64   `json:"chargingFee"`       // 充电费用
-	ServiceFee        float64   `json:"serviceFee"`        // 服务费用
-	TotalFee          float64   `json:"totalFee"`          // 总费用
-	PeakHours         float64   `json:"peakHours"`         // 峰时小时数
-	NormalHours       float64   `json:"normalHours"`       // 平时小时数
-	ValleyHours       float64   `json:"valleyHours"`       // 谷时小时数
-	PeakElectricity   float64   `json:"peakElectricity"`   // 峰时电量
-	NormalElectricity float64   `json:"normalElectricity"` // 平时电量
-	ValleyElectricity float64   `json:"valleyElectricity"` // 谷时电量
+	UnitPrice         float64   `json:"unitPrice"`          // 电价单价
+	PriceType         string    `json:"priceType"`          // 价格类型(peak/normal/valley)
+	ChargingFee       float64   `json:"chargingFee"`        // 充电费用
+	ServiceFee        float64   `json:"serviceFee"`         // 服务费用
+	IdleMinutes       int       `json:"idleMinutes"`        // 超出免费停留时间的占位分钟数
+	IdleFee           float64   `json:"idleFee"`            // 占位费
+	TotalFee          float64   `json:"totalFee"`           // 总费用，含占位费
+	PeakHours         float64   `json:"peakHours"`          // 峰时小时数
+	NormalHours       float64   `json:"normalHours"`        // 平时小时数
+	ValleyHours       float64   `json:"valleyHours"`        // 谷时小时数
+	PeakElectricity   float64   `json:"peakElectricity"`    // 峰时电量
+	NormalElectricity float64   `json:"normalElectricity"`  // 平时电量
+	ValleyElectricity float64   `json:"valleyElectricity"`  // 谷时电量
+	StartSoC          *float64  `json:"startSoc,omitempty"` // 起始电量百分比
+	EndSoC            *float64  `json:"endSoc,omitempty"`   // 结束电量百分比
 	GeneratedAt       time.Time `json:"generatedAt"`
 }
 
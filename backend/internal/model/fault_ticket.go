@@ -0,0 +1,107 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// FaultTicketStatus 故障工单状态
+type FaultTicketStatus string
+
+const (
+	FaultTicketStatusOpen         FaultTicketStatus = "open"         // 待受理
+	FaultTicketStatusAcknowledged FaultTicketStatus = "acknowledged" // 已受理
+	FaultTicketStatusInRepair     FaultTicketStatus = "in_repair"    // 维修中
+	FaultTicketStatusResolved     FaultTicketStatus = "resolved"     // 已修复，待验收
+	FaultTicketStatusVerified     FaultTicketStatus = "verified"     // 已验收
+)
+
+// faultTicketTransitions 允许的工单状态流转，验收不通过可退回维修
+var faultTicketTransitions = map[FaultTicketStatus][]FaultTicketStatus{
+	FaultTicketStatusOpen:         {FaultTicketStatusAcknowledged},
+	FaultTicketStatusAcknowledged: {FaultTicketStatusInRepair},
+	FaultTicketStatusInRepair:     {FaultTicketStatusResolved},
+	FaultTicketStatusResolved:     {FaultTicketStatusVerified, FaultTicketStatusInRepair},
+}
+
+// CanTransitionTo 工单能否从当前状态流转到目标状态
+func (s FaultTicketStatus) CanTransitionTo(to FaultTicketStatus) bool {
+	for _, next := range faultTicketTransitions[s] {
+		if next == to {
+			return true
+		}
+	}
+	return false
+}
+
+// IsRepaired 工单是否已修复（已修复或已验收）
+func (s FaultTicketStatus) IsRepaired() bool {
+	return s == FaultTicketStatusResolved || s == FaultTicketStatusVerified
+}
+
+// FaultTicket 故障工单
+type FaultTicket struct {
+	ID              uuid.UUID           `json:"id"`
+	FaultID         uuid.UUID           `json:"faultId"`
+	PileID          string              `json:"pileId"`
+	Status          FaultTicketStatus   `json:"status"`
+	AssigneeID      *uuid.UUID          `json:"assigneeId,omitempty"`
+	RecoveryNotes   string              `json:"recoveryNotes,omitempty"`
+	AckDueAt        time.Time           `json:"ackDueAt"`     // 受理时限
+	ResolveDueAt    time.Time           `json:"resolveDueAt"` // 修复时限
+	AcknowledgedAt  *time.Time          `json:"acknowledgedAt,omitempty"`
+	RepairStartedAt *time.Time          `json:"repairStartedAt,omitempty"`
+	ResolvedAt      *time.Time          `json:"resolvedAt,omitempty"`
+	VerifiedAt      *time.Time          `json:"verifiedAt,omitempty"`
+	AckOverdue      bool                `json:"ackOverdue"`     // 受理超时
+	ResolveOverdue  bool                `json:"resolveOverdue"` // 修复超时
+	Entries         []*FaultTicketEntry `json:"entries,omitempty"`
+	CreatedAt       time.Time           `json:"createdAt"`
+	UpdatedAt       time.Time           `json:"updatedAt"`
+}
+
+// UpdateSLA 根据当前时间计算受理与修复是否超时
+func (t *FaultTicket) UpdateSLA(now time.Time) {
+	t.AckOverdue = slaBreached(t.AckDueAt, t.AcknowledgedAt, now)
+	t.ResolveOverdue = slaBreached(t.ResolveDueAt, t.ResolvedAt, now)
+}
+
+// slaBreached 在时限内完成为未超时；尚未完成时以当前时间判断
+func slaBreached(due time.Time, doneAt *time.Time, now time.Time) bool {
+	if doneAt != nil {
+		return doneAt.After(due)
+	}
+	return now.After(due)
+}
+
+// FaultTicketEntryType 工单记录类型
+type FaultTicketEntryType string
+
+const (
+	FaultTicketEntryNote     FaultTicketEntryType = "note"     // 备注
+	FaultTicketEntryPart     FaultTicketEntryType = "part"     // 使用的配件
+	FaultTicketEntryStatus   FaultTicketEntryType = "status"   // 状态变更
+	FaultTicketEntryAssign   FaultTicketEntryType = "assign"   // 指派
+	FaultTicketEntryOverride FaultTicketEntryType = "override" // 未修复时强制恢复充电桩
+)
+
+// FaultTicketEntry 工单记录
+type FaultTicketEntry struct {
+	ID        uuid.UUID            `json:"id"`
+	TicketID  uuid.UUID            `json:"ticketId"`
+	EntryType FaultTicketEntryType `json:"entryType"`
+	AuthorID  *uuid.UUID           `json:"authorId,omitempty"`
+	Content   string               `json:"content,omitempty"`
+	PartName  string               `json:"partName,omitempty"`
+	Quantity  int                  `json:"quantity,omitempty"`
+	CreatedAt time.Time            `json:"createdAt"`
+}
+
+// FaultTicketFilter 工单查询条件
+type FaultTicketFilter struct {
+	Status      FaultTicketStatus
+	PileID      string
+	AssigneeID  *uuid.UUID
+	OverdueOnly bool // 只返回受理或修复超时的工单
+}
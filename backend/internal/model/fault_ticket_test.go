@@ -0,0 +1,55 @@
+package model
+
+import "testing"
+
+func TestFaultTicketStatusCanTransitionTo(t *testing.T) {
+	tests := []struct {
+		from FaultTicketStatus
+		to   FaultTicketStatus
+		want bool
+	}{
+		{FaultTicketStatusOpen, FaultTicketStatusAcknowledged, true},
+		{FaultTicketStatusAcknowledged, FaultTicketStatusInRepair, true},
+		{FaultTicketStatusInRepair, FaultTicketStatusResolved, true},
+		{FaultTicketStatusResolved, FaultTicketStatusVerified, true},
+		{FaultTicketStatusResolved, FaultTicketStatusInRepair, true}, // 验收不通过退回维修
+
+		{FaultTicketStatusOpen, FaultTicketStatusInRepair, false},
+		{FaultTicketStatusOpen, FaultTicketStatusResolved, false},
+		{FaultTicketStatusAcknowledged, FaultTicketStatusOpen, false},
+		{FaultTicketStatusInRepair, FaultTicketStatusVerified, false},
+		{FaultTicketStatusVerified, FaultTicketStatusInRepair, false},
+		{FaultTicketStatusVerified, FaultTicketStatusOpen, false},
+		{FaultTicketStatusOpen, FaultTicketStatusOpen, false},
+		{FaultTicketStatus("unknown"), FaultTicketStatusAcknowledged, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.from)+"->"+string(tt.to), func(t *testing.T) {
+			if got := tt.from.CanTransitionTo(tt.to); got != tt.want {
+				t.Errorf("%s.CanTransitionTo(%s) = %v, want %v", tt.from, tt.to, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFaultTicketStatusIsRepaired(t *testing.T) {
+	tests := []struct {
+		status FaultTicketStatus
+		want   bool
+	}{
+		{FaultTicketStatusOpen, false},
+		{FaultTicketStatusAcknowledged, false},
+		{FaultTicketStatusInRepair, false},
+		{FaultTicketStatusResolved, true},
+		{FaultTicketStatusVerified, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.status), func(t *testing.T) {
+			if got := tt.status.IsRepaired(); got != tt.want {
+				t.Errorf("%s.IsRepaired() = %v, want %v", tt.status, got, tt.want)
+			}
+		})
+	}
+}
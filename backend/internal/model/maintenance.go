@@ -0,0 +1,36 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// MaintenanceStatus 维护窗口状态
+type MaintenanceStatus string
+
+const (
+	MaintenanceStatusScheduled MaintenanceStatus = "scheduled" // 已计划
+	MaintenanceStatusDraining  MaintenanceStatus = "draining"  // 已进入提前期，排队车辆已转移
+	MaintenanceStatusActive    MaintenanceStatus = "active"    // 维护中
+	MaintenanceStatusCompleted MaintenanceStatus = "completed" // 已结束，充电桩已恢复服务
+	MaintenanceStatusCancelled MaintenanceStatus = "cancelled" // 已取消
+)
+
+// MaintenanceWindow 充电桩计划维护窗口
+type MaintenanceWindow struct {
+	ID        uuid.UUID         `json:"id"`
+	PileID    string            `json:"pileId"`
+	StartTime time.Time         `json:"startTime"`
+	EndTime   time.Time         `json:"endTime"`
+	Reason    string            `json:"reason,omitempty"`
+	Status    MaintenanceStatus `json:"status"`
+	CreatedBy *uuid.UUID        `json:"createdBy,omitempty"`
+	CreatedAt time.Time         `json:"createdAt"`
+	UpdatedAt time.Time         `json:"updatedAt"`
+}
+
+// IsFinished 维护窗口是否已结束或取消
+func (w *MaintenanceWindow) IsFinished() bool {
+	return w.Status == MaintenanceStatusCompleted || w.Status == MaintenanceStatusCancelled
+}
@@ -0,0 +1,119 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// NotificationEvent 通知事件
+type NotificationEvent string
+
+const (
+	NotificationQueueCalled       NotificationEvent = "queue_called"       // 叫号：请求分配到充电桩
+	NotificationChargingStarted   NotificationEvent = "charging_started"   // 开始充电
+	NotificationChargingCompleted NotificationEvent = "charging_completed" // 充电完成
+	NotificationFaultRescheduled  NotificationEvent = "fault_rescheduled"  // 充电桩故障或维护，请求被重新调度
+	NotificationBillReady         NotificationEvent = "bill_ready"         // 详单已生成
+	NotificationBillUpdated       NotificationEvent = "bill_updated"       // 详单计入占位费
+	NotificationWaitlistAdmitted  NotificationEvent = "waitlist_admitted"  // 候补请求获得入场资格
+	NotificationWaitlistExpired   NotificationEvent = "waitlist_expired"   // 候补请求超时未到场被取消
+)
+
+// NotificationEvents 全部通知事件
+var NotificationEvents = []NotificationEvent{
+	NotificationQueueCalled,
+	NotificationChargingStarted,
+	NotificationChargingCompleted,
+	NotificationFaultRescheduled,
+	NotificationBillReady,
+	NotificationBillUpdated,
+	NotificationWaitlistAdmitted,
+	NotificationWaitlistExpired,
+}
+
+// NotificationChannel 通知渠道
+type NotificationChannel string
+
+const (
+	NotificationChannelInApp   NotificationChannel = "in_app"  // 站内信
+	NotificationChannelWebhook NotificationChannel = "webhook" // 用户配置的webhook地址
+	NotificationChannelEmail   NotificationChannel = "email"   // 邮件（SMTP）
+)
+
+// NotificationChannels 全部通知渠道
+var NotificationChannels = []NotificationChannel{
+	NotificationChannelInApp,
+	NotificationChannelWebhook,
+	NotificationChannelEmail,
+}
+
+// Notification 通知
+type Notification struct {
+	ID        uuid.UUID         `json:"id"`
+	UserID    uuid.UUID         `json:"userId"`
+	Event     NotificationEvent `json:"event"`
+	Title     string            `json:"title"`
+	Content   string            `json:"content"`
+	Data      map[string]any    `json:"data,omitempty"` // 渲染模板使用的事件数据
+	InApp     bool              `json:"-"`              // 是否出现在站内信箱
+	ReadAt    *time.Time        `json:"readAt,omitempty"`
+	CreatedAt time.Time         `json:"createdAt"`
+}
+
+// NotificationPreference 用户的通知渠道偏好
+type NotificationPreference struct {
+	Channel   NotificationChannel `json:"channel"`
+	Enabled   bool                `json:"enabled"`
+	Target    string              `json:"target,omitempty"` // webhook地址或邮箱
+	Events    []NotificationEvent `json:"events,omitempty"` // 订阅的事件，为空时订阅全部
+	UpdatedAt *time.Time          `json:"updatedAt,omitempty"`
+}
+
+// Subscribes 渠道是否开启且订阅了该事件
+func (p *NotificationPreference) Subscribes(event NotificationEvent) bool {
+	if !p.Enabled {
+		return false
+	}
+	if len(p.Events) == 0 {
+		return true
+	}
+	for _, e := range p.Events {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
+
+// NotificationDeliveryStatus 通知投递状态
+type NotificationDeliveryStatus string
+
+const (
+	NotificationDeliveryPending NotificationDeliveryStatus = "pending" // 等待投递或重试
+	NotificationDeliverySent    NotificationDeliveryStatus = "sent"    // 已投递
+	NotificationDeliveryFailed  NotificationDeliveryStatus = "failed"  // 重试次数用尽
+)
+
+// NotificationDelivery 通知投递记录
+type NotificationDelivery struct {
+	ID             uuid.UUID                  `json:"id"`
+	NotificationID uuid.UUID                  `json:"notificationId"`
+	UserID         uuid.UUID                  `json:"userId"`
+	Event          NotificationEvent          `json:"event"`
+	Channel        NotificationChannel        `json:"channel"`
+	Target         string                     `json:"target,omitempty"`
+	Status         NotificationDeliveryStatus `json:"status"`
+	Attempts       int                        `json:"attempts"`
+	NextAttemptAt  time.Time                  `json:"nextAttemptAt"`
+	LastError      string                     `json:"lastError,omitempty"`
+	SentAt         *time.Time                 `json:"sentAt,omitempty"`
+	CreatedAt      time.Time                  `json:"createdAt"`
+}
+
+// NotificationDeliveryFilter 投递记录筛选条件
+type NotificationDeliveryFilter struct {
+	Status  NotificationDeliveryStatus
+	Channel NotificationChannel
+	UserID  *uuid.UUID
+}
@@ -0,0 +1,90 @@
+package model
+
+// Permission 表示一项操作权限
+type Permission string
+
+const (
+	PermPileControl      Permission = "pile:control"      // 控制充电桩启停
+	PermQueueView        Permission = "queue:view"        // 查看队列及他人充电请求
+	PermQueueManage      Permission = "queue:manage"      // 人工干预队列：移动、强制停止、调整顺序、代取消
+	PermAuditView        Permission = "audit:view"        // 查看审计日志
+	PermFaultView        Permission = "fault:view"        // 查看故障记录
+	PermFaultRepair      Permission = "fault:repair"      // 维修充电桩
+	PermFaultAssign      Permission = "fault:assign"      // 指派故障工单
+	PermBillingView      Permission = "billing:view"      // 查看他人账单及账单统计
+	PermReportPileUsage  Permission = "report:pile-usage" // 充电桩使用报表
+	PermReportOperations Permission = "report:operations" // 运营统计报表
+	PermUserView         Permission = "user:view"         // 查看他人用户信息
+	PermUserManage       Permission = "user:manage"       // 管理用户（强制下线等）
+	PermRoleAssign       Permission = "role:assign"       // 分配角色
+	PermSchedulingConfig Permission = "scheduling:config" // 修改调度配置
+	PermWebhookManage    Permission = "webhook:manage"    // 管理第三方webhook订阅与重放
+)
+
+// AllPermissions 全部权限
+var AllPermissions = []Permission{
+	PermPileControl,
+	PermQueueView,
+	PermQueueManage,
+	PermAuditView,
+	PermFaultView,
+	PermFaultRepair,
+	PermFaultAssign,
+	PermBillingView,
+	PermReportPileUsage,
+	PermReportOperations,
+	PermUserView,
+	PermUserManage,
+	PermRoleAssign,
+	PermSchedulingConfig,
+	PermWebhookManage,
+}
+
+// RolePermissions 角色权限表
+var RolePermissions = map[UserType][]Permission{
+	UserTypeUser:  {},
+	UserTypeAdmin: AllPermissions,
+	UserTypeOperator: {
+		PermPileControl,
+		PermQueueView,
+		PermQueueManage,
+		PermFaultView,
+		PermFaultAssign,
+		PermReportPileUsage,
+	},
+	UserTypeMaintenance: {
+		PermFaultView,
+		PermFaultRepair,
+		PermFaultAssign,
+	},
+	UserTypeFinance: {
+		PermBillingView,
+		PermReportPileUsage,
+		PermReportOperations,
+	},
+	UserTypeAuditor: {
+		PermQueueView,
+		PermAuditView,
+		PermFaultView,
+		PermBillingView,
+		PermReportPileUsage,
+		PermReportOperations,
+		PermUserView,
+	},
+}
+
+// IsValidUserType 检查角色是否有效
+func IsValidUserType(userType UserType) bool {
+	_, ok := RolePermissions[userType]
+	return ok
+}
+
+// HasPermission 检查角色是否拥有指定权限
+func HasPermission(userType UserType, perm Permission) bool {
+	for _, p := range RolePermissions[userType] {
+		if p == perm {
+			return true
+		}
+	}
+	return false
+}
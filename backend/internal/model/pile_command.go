@@ -0,0 +1,42 @@
+package model
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// PileCommandType 充电桩指令类型
+type PileCommandType string
+
+const (
+	PileCommandAssign PileCommandType = "assign" // 分配充电
+	PileCommandStop   PileCommandType = "stop"   // 停止充电
+)
+
+// PileCommandStatus 充电桩指令状态
+type PileCommandStatus string
+
+const (
+	PileCommandStatusPending      PileCommandStatus = "pending"      // 等待投递或重试
+	PileCommandStatusAcknowledged PileCommandStatus = "acknowledged" // 模拟器已确认
+	PileCommandStatusEscalated    PileCommandStatus = "escalated"    // 超时未确认，已转故障处理
+	PileCommandStatusCancelled    PileCommandStatus = "cancelled"    // 充电桩已转故障处理，不再投递
+)
+
+// PileCommand 后端下发给充电桩的指令
+type PileCommand struct {
+	ID             uuid.UUID         `json:"id"`
+	PileID         string            `json:"pileId"`
+	SessionID      *uuid.UUID        `json:"sessionId,omitempty"`
+	CommandType    PileCommandType   `json:"commandType"`
+	Payload        json.RawMessage   `json:"payload"`
+	Status         PileCommandStatus `json:"status"`
+	Attempts       int               `json:"attempts"`
+	NextAttemptAt  time.Time         `json:"nextAttemptAt"`
+	LastError      string            `json:"lastError,omitempty"`
+	CreatedAt      time.Time         `json:"createdAt"`
+	AcknowledgedAt *time.Time        `json:"acknowledgedAt,omitempty"`
+	UpdatedAt      time.Time         `json:"updatedAt"`
+}
@@ -0,0 +1,21 @@
+package model
+
+import (
+	"time"
+)
+
+// PileRegistration 充电桩自注册信息
+type PileRegistration struct {
+	PileID   string     `json:"pileId"`
+	PileType PileType   `json:"pileType"`
+	Power    float64    `json:"power"`
+	Status   PileStatus `json:"status"`
+}
+
+// PileEndpoint 充电桩的指令下发地址
+type PileEndpoint struct {
+	PileID       string    `json:"pileId"`
+	EndpointURL  string    `json:"endpointUrl"`
+	RegisteredAt time.Time `json:"registeredAt"`
+	UpdatedAt    time.Time `json:"updatedAt"`
+}
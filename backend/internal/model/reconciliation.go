@@ -0,0 +1,66 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// PileStatusReport 模拟器上报的充电桩状态
+type PileStatusReport struct {
+	PileID     string          `json:"pileId"`
+	Status     string          `json:"status"` // charging|available|fault|maintenance|offline
+	Vehicle    *ReportedCharge `json:"vehicle,omitempty"`
+	ReportedAt time.Time       `json:"reportedAt"`
+}
+
+// ReportedCharge 模拟器上报的当前充电
+type ReportedCharge struct {
+	SessionID         string    `json:"sessionId,omitempty"`
+	UserID            string    `json:"userId"`
+	StartTime         time.Time `json:"startTime"`
+	RequestedCapacity float64   `json:"requestedCapacity"`
+	CurrentCapacity   float64   `json:"currentCapacity"`
+}
+
+// BackendPileState 对账时后端记录的充电桩状态
+type BackendPileState struct {
+	Status    PileStatus `json:"status"`
+	SessionID *uuid.UUID `json:"sessionId,omitempty"`
+	UserID    *uuid.UUID `json:"userId,omitempty"`
+	RequestID *uuid.UUID `json:"requestId,omitempty"`
+}
+
+// MismatchKind 对账不一致类型
+type MismatchKind string
+
+const (
+	MismatchChargingWithoutSession MismatchKind = "charging_without_session" // 模拟器在充电，后端无活跃会话
+	MismatchSessionWithoutCharging MismatchKind = "session_without_charging" // 后端有活跃会话，模拟器空闲
+	MismatchSessionConflict        MismatchKind = "session_conflict"         // 双方都在充电，但会话不同
+	MismatchFaultNotRecorded       MismatchKind = "fault_not_recorded"       // 模拟器故障，后端未记录
+	MismatchFaultNotCleared        MismatchKind = "fault_not_cleared"        // 后端仍为故障，模拟器已恢复
+)
+
+// ReconcileAction 对账处理结果
+type ReconcileAction string
+
+const (
+	ReconcileActionAlerted          ReconcileAction = "alerted"           // 仅告警
+	ReconcileActionCorrected        ReconcileAction = "corrected"         // 已自动纠正
+	ReconcileActionCorrectionFailed ReconcileAction = "correction_failed" // 自动纠正失败
+)
+
+// ReconciliationAlert 对账告警
+type ReconciliationAlert struct {
+	ID             uuid.UUID         `json:"id"`
+	PileID         string            `json:"pileId"`
+	Kind           MismatchKind      `json:"kind"`
+	BackendState   BackendPileState  `json:"backendState"`
+	SimulatorState *PileStatusReport `json:"simulatorState"`
+	Diff           string            `json:"diff"`
+	Action         ReconcileAction   `json:"action"`
+	Detail         string            `json:"detail,omitempty"`
+	CreatedAt      time.Time         `json:"createdAt"`
+	ResolvedAt     *time.Time        `json:"resolvedAt,omitempty"`
+}
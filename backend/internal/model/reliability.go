@@ -0,0 +1,51 @@
+package model
+
+import "time"
+
+// ReliabilityThresholds 可靠性告警阈值
+type ReliabilityThresholds struct {
+	AlertFaultCount int     `json:"alertFaultCount"` // 统计窗口内故障次数达到该值时标记巡检
+	AlertWindow     int     `json:"alertWindow"`     // 故障次数统计窗口（秒）
+	MinAvailability float64 `json:"minAvailability"` // 可用率低于该值（%）时标记巡检，0表示不检查
+}
+
+// PileReliability 充电桩可靠性统计
+type PileReliability struct {
+	PileID            string   `json:"pileId"`
+	PileType          PileType `json:"pileType"`
+	FaultCount        int      `json:"faultCount"`            // 统计期内发生的故障次数
+	MTBF              *float64 `json:"mtbf"`                  // 平均故障间隔（小时），无故障时为空
+	MTTR              *float64 `json:"mttr"`                  // 平均修复时间（小时），无已修复故障时为空
+	DowntimeHours     float64  `json:"downtimeHours"`         // 统计期内故障停机时长（小时）
+	Availability      float64  `json:"availability"`          // 可用率（%）
+	AffectedSessions  int      `json:"affectedSessions"`      // 受影响的充电会话数
+	DegradedPeriods   int      `json:"degradedPeriods"`       // 统计期内的降功率运行次数
+	DegradedHours     float64  `json:"degradedHours"`         // 统计期内降功率运行时长（小时）
+	LostRevenue       float64  `json:"lostRevenue"`           // 停机损失收入估算（元）
+	MaxFaultsInWindow int      `json:"maxFaultsInWindow"`     // 任一统计窗口内的最多故障次数
+	Flagged           bool     `json:"flagged"`               // 是否需要巡检
+	FlagReasons       []string `json:"flagReasons,omitempty"` // 标记巡检的原因
+}
+
+// FaultTypeReliability 按故障类型的可靠性统计
+type FaultTypeReliability struct {
+	FaultType        FaultType `json:"faultType"`
+	FaultCount       int       `json:"faultCount"`
+	MTBF             *float64  `json:"mtbf"`             // 全部充电桩运行时长 / 该类故障次数（小时）
+	MTTR             *float64  `json:"mttr"`             // 平均修复时间（小时）
+	DowntimeHours    float64   `json:"downtimeHours"`    // 停机时长（小时）
+	AffectedSessions int       `json:"affectedSessions"` // 受影响的充电会话数
+	DegradedPeriods  int       `json:"degradedPeriods"`  // 该类故障导致的降功率运行次数
+	DegradedHours    float64   `json:"degradedHours"`    // 降功率运行时长（小时）
+	LostRevenue      float64   `json:"lostRevenue"`      // 停机损失收入估算（元）
+}
+
+// ReliabilityReport 可靠性报表
+type ReliabilityReport struct {
+	StartTime    time.Time               `json:"startTime"`
+	EndTime      time.Time               `json:"endTime"`
+	Thresholds   ReliabilityThresholds   `json:"thresholds"`
+	Piles        []*PileReliability      `json:"piles"`
+	FaultTypes   []*FaultTypeReliability `json:"faultTypes"`
+	FlaggedPiles []string                `json:"flaggedPiles"` // 需要巡检的充电桩
+}
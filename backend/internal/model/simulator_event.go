@@ -0,0 +1,30 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// SimulatorEventType 模拟器回调事件类型
+type SimulatorEventType string
+
+const (
+	SimulatorEventChargingProgress SimulatorEventType = "charging_progress"
+	SimulatorEventChargingComplete SimulatorEventType = "charging_complete"
+	SimulatorEventFaultReport      SimulatorEventType = "fault_report"
+	SimulatorEventFaultRecovery    SimulatorEventType = "fault_recovery"
+	SimulatorEventVehicleDeparted  SimulatorEventType = "vehicle_departed"
+)
+
+// SimulatorEvent 已处理的模拟器回调事件
+// 相同事件ID的重放直接返回首次处理的结果
+type SimulatorEvent struct {
+	EventID      string             `json:"eventId"`
+	EventType    SimulatorEventType `json:"eventType"`
+	PileID       string             `json:"pileId"`
+	SessionID    *uuid.UUID         `json:"sessionId,omitempty"`
+	StatusCode   int                `json:"statusCode"`
+	ResponseBody string             `json:"responseBody"`
+	CreatedAt    time.Time          `json:"createdAt"`
+}
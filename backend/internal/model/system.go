@@ -33,6 +33,7 @@ type FaultRecord struct {
 	RecoveredAt      *time.Time  `json:"recoveredAt,omitempty"`
 	AffectedSessions int         `json:"affectedSessions"`
 	Status           FaultStatus `json:"status"`
+	RecoveryNotes    string      `json:"recoveryNotes,omitempty"` // 维修完成时的恢复说明
 	CreatedAt        time.Time   `json:"createdAt"`
 }
 
@@ -65,6 +66,19 @@ const (
 	ExtendedModeBatch    ExtendedSchedulingMode = "batch"    // 批量调度总充电时长最短
 )
 
+// FaultReschedulingPolicy 故障调度策略
+type FaultReschedulingPolicy string
+
+const (
+	FaultPolicyPriority  FaultReschedulingPolicy = "priority"   // 优先级调度：故障队列车辆优先分配到同类型其他充电桩
+	FaultPolicyTimeOrder FaultReschedulingPolicy = "time_order" // 时间顺序调度：故障队列车辆与同类型充电桩未充电车辆合并按排队号重新分配
+)
+
+// IsValidFaultReschedulingPolicy 检查故障调度策略是否有效
+func IsValidFaultReschedulingPolicy(policy FaultReschedulingPolicy) bool {
+	return policy == FaultPolicyPriority || policy == FaultPolicyTimeOrder
+}
+
 // SchedulingConfig 调度配置
 type SchedulingConfig struct {
 	Strategy               string                 `json:"strategy"` // shortest_completion_time, etc.
@@ -75,6 +89,8 @@ type SchedulingConfig struct {
 	FastChargingPower      float64                `json:"fastChargingPower"`      // 度/小时
 	SlowChargingPower      float64                `json:"slowChargingPower"`      // 度/小时
 	ExtendedSchedulingMode ExtendedSchedulingMode `json:"extendedSchedulingMode"` // 扩展调度模式
+
+	FaultReschedulingPolicy FaultReschedulingPolicy `json:"faultReschedulingPolicy"` // 故障调度策略
 }
 
 // StatisticsReport 统计报表
@@ -10,20 +10,50 @@ import (
 type UserType string
 
 const (
-	UserTypeUser  UserType = "user"
-	UserTypeAdmin UserType = "admin"
+	UserTypeUser        UserType = "user"
+	UserTypeAdmin       UserType = "admin"
+	UserTypeOperator    UserType = "operator"    // 运营人员：充电桩控制与队列查看
+	UserTypeMaintenance UserType = "maintenance" // 维修技术员：故障与维修
+	UserTypeFinance     UserType = "finance"     // 财务：账单与营收报表
+	UserTypeAuditor     UserType = "auditor"     // 审计员：只读
 )
 
 // User 表示系统中的用户
 type User struct {
-	ID              uuid.UUID `json:"id"`
-	Username        string    `json:"username"`
-	PasswordHash    string    `json:"-"` // 不包含在JSON响应中
-	UserType        UserType  `json:"userType"`
-	LicensePlate    string    `json:"licensePlate"`
-	BatteryCapacity float64   `json:"batteryCapacity"`
-	CreatedAt       time.Time `json:"createdAt"`
-	UpdatedAt       time.Time `json:"updatedAt"`
+	ID              uuid.UUID  `json:"id"`
+	Username        string     `json:"username"`
+	PasswordHash    string     `json:"-"` // 不包含在JSON响应中
+	UserType        UserType   `json:"userType"`
+	LicensePlate    string     `json:"licensePlate"`
+	BatteryCapacity float64    `json:"batteryCapacity"`
+	DisabledAt      *time.Time `json:"disabledAt,omitempty"`
+	DisabledReason  string     `json:"disabledReason,omitempty"`
+	CreatedAt       time.Time  `json:"createdAt"`
+	UpdatedAt       time.Time  `json:"updatedAt"`
+}
+
+// IsDisabled 账户是否已被禁用
+func (u *User) IsDisabled() bool {
+	return u.DisabledAt != nil
+}
+
+// UserFilter 用户列表筛选条件
+type UserFilter struct {
+	Keyword  string   // 用户名或车牌号模糊匹配
+	UserType UserType // 角色
+	Status   string   // active / disabled
+}
+
+// UserActivity 用户活动概览
+type UserActivity struct {
+	User          *User              `json:"user"`
+	ActiveRequest *ChargingRequest   `json:"activeRequest,omitempty"`
+	Requests      []*ChargingRequest `json:"recentRequests"`
+	TotalRequests int                `json:"totalRequests"`
+	Bills         []*BillingDetail   `json:"recentBills"`
+	TotalBills    int                `json:"totalBills"`
+	TotalFee      float64            `json:"totalFee"`
+	Sessions      []*UserSession     `json:"activeSessions"`
 }
 
 // CreateUserRequest 创建用户的请求体
@@ -36,16 +66,41 @@ type CreateUserRequest struct {
 
 // LoginRequest 登录请求体
 type LoginRequest struct {
-	Username string `json:"username" binding:"required"`
-	Password string `json:"password" binding:"required"`
+	Username  string `json:"username" binding:"required"`
+	Password  string `json:"password" binding:"required"`
+	UserAgent string `json:"-"` // 登录设备信息，由处理器从请求头填充
+	IPAddress string `json:"-"`
 }
 
 // LoginResponse 登录响应体
 type LoginResponse struct {
-	Token     string    `json:"token"`
-	UserID    uuid.UUID `json:"userId"`
-	UserType  UserType  `json:"userType"`
-	ExpiresIn int       `json:"expiresIn"`
+	Token            string    `json:"token"`
+	RefreshToken     string    `json:"refreshToken"`
+	SessionID        uuid.UUID `json:"sessionId"`
+	UserID           uuid.UUID `json:"userId"`
+	UserType         UserType  `json:"userType"`
+	ExpiresIn        int       `json:"expiresIn"`
+	RefreshExpiresIn int       `json:"refreshExpiresIn"`
+}
+
+// UserSession 用户登录会话（每个设备一个，承载刷新令牌）
+type UserSession struct {
+	ID         uuid.UUID  `json:"sessionId"`
+	UserID     uuid.UUID  `json:"userId"`
+	UserAgent  string     `json:"userAgent"`
+	IPAddress  string     `json:"ipAddress"`
+	CreatedAt  time.Time  `json:"createdAt"`
+	LastUsedAt time.Time  `json:"lastUsedAt"`
+	ExpiresAt  time.Time  `json:"expiresAt"`
+	RevokedAt  *time.Time `json:"revokedAt,omitempty"`
+	Current    bool       `json:"current"`
+}
+
+// AccessClaims 访问令牌中携带的身份信息
+type AccessClaims struct {
+	UserID    uuid.UUID
+	SessionID uuid.UUID
+	IssuedAt  time.Time
 }
 
 // UserInfo 用户信息响应
@@ -0,0 +1,39 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Vehicle 用户车辆
+type Vehicle struct {
+	ID                 uuid.UUID    `json:"id"`
+	UserID             uuid.UUID    `json:"userId"`
+	LicensePlate       string       `json:"licensePlate"`
+	BatteryCapacity    float64      `json:"batteryCapacity"`    // 电池容量(度)
+	SupportedPileTypes []PileType   `json:"supportedPileTypes"` // 支持的充电桩类型
+	DefaultMode        ChargingMode `json:"defaultMode"`        // 默认充电模式
+	VehicleProfile     string       `json:"vehicleProfile"`     // 模拟器车辆配置名称（充电曲线），为空时使用默认配置
+	CreatedAt          time.Time    `json:"createdAt"`
+	UpdatedAt          time.Time    `json:"updatedAt"`
+}
+
+// SupportsMode 车辆是否支持指定充电模式
+func (v *Vehicle) SupportsMode(mode ChargingMode) bool {
+	for _, t := range v.SupportedPileTypes {
+		if string(t) == string(mode) {
+			return true
+		}
+	}
+	return false
+}
+
+// VehicleCreate 登记车辆请求
+type VehicleCreate struct {
+	LicensePlate       string       `json:"licensePlate" binding:"required"`
+	BatteryCapacity    float64      `json:"batteryCapacity" binding:"required,gt=0"`
+	SupportedPileTypes []PileType   `json:"supportedPileTypes"` // 为空时支持全部类型
+	DefaultMode        ChargingMode `json:"defaultMode"`        // 为空时取第一个支持的类型
+	VehicleProfile     string       `json:"vehicleProfile"`     // 模拟器车辆配置名称，为空时使用默认配置
+}
@@ -0,0 +1,127 @@
+package model
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// WebhookEventType 推送给第三方的事件类型
+type WebhookEventType string
+
+const (
+	WebhookEventRequestCreated    WebhookEventType = "request.created"    // 提交充电请求
+	WebhookEventRequestAssigned   WebhookEventType = "request.assigned"   // 请求分配到充电桩
+	WebhookEventChargingStarted   WebhookEventType = "charging.started"   // 开始充电
+	WebhookEventChargingCompleted WebhookEventType = "charging.completed" // 充电结束
+	WebhookEventBillCreated       WebhookEventType = "bill.created"       // 详单已生成
+	WebhookEventBillUpdated       WebhookEventType = "bill.updated"       // 详单费用变化（计入占位费）
+	WebhookEventPileFaulted       WebhookEventType = "pile.faulted"       // 充电桩故障
+)
+
+// WebhookEventTypes 全部webhook事件类型
+var WebhookEventTypes = []WebhookEventType{
+	WebhookEventRequestCreated,
+	WebhookEventRequestAssigned,
+	WebhookEventChargingStarted,
+	WebhookEventChargingCompleted,
+	WebhookEventBillCreated,
+	WebhookEventBillUpdated,
+	WebhookEventPileFaulted,
+}
+
+// IsValidWebhookEventType 检查webhook事件类型是否有效
+func IsValidWebhookEventType(eventType WebhookEventType) bool {
+	for _, t := range WebhookEventTypes {
+		if t == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+// WebhookSubscription 第三方webhook订阅
+type WebhookSubscription struct {
+	ID        uuid.UUID          `json:"id"`
+	Name      string             `json:"name"`
+	URL       string             `json:"url"`
+	Secret    string             `json:"-"`                // 签名密钥，只在创建与轮换时返回
+	Events    []WebhookEventType `json:"events,omitempty"` // 订阅的事件，为空时订阅全部
+	Enabled   bool               `json:"enabled"`
+	CreatedBy *uuid.UUID         `json:"createdBy,omitempty"`
+	CreatedAt time.Time          `json:"createdAt"`
+	UpdatedAt time.Time          `json:"updatedAt"`
+}
+
+// Subscribes 订阅是否启用且订阅了该事件
+func (s *WebhookSubscription) Subscribes(eventType WebhookEventType) bool {
+	if !s.Enabled {
+		return false
+	}
+	if len(s.Events) == 0 {
+		return true
+	}
+	for _, t := range s.Events {
+		if t == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+// WebhookEvent 推送给第三方的事件，作为请求体签名发送
+type WebhookEvent struct {
+	ID         uuid.UUID        `json:"id"` // 同一事件重试与重放时不变，接收方可据此去重
+	Type       WebhookEventType `json:"type"`
+	OccurredAt time.Time        `json:"occurredAt"`
+	Data       map[string]any   `json:"data"`
+}
+
+// WebhookDeliveryStatus webhook投递状态
+type WebhookDeliveryStatus string
+
+const (
+	WebhookDeliveryPending   WebhookDeliveryStatus = "pending"   // 等待投递或重试
+	WebhookDeliveryDelivered WebhookDeliveryStatus = "delivered" // 对方返回2xx
+	WebhookDeliveryDead      WebhookDeliveryStatus = "dead"      // 重试次数用尽，进入死信
+)
+
+// WebhookDelivery webhook投递记录
+type WebhookDelivery struct {
+	ID             uuid.UUID             `json:"id"`
+	SubscriptionID uuid.UUID             `json:"subscriptionId"`
+	EventID        uuid.UUID             `json:"eventId"`
+	EventType      WebhookEventType      `json:"eventType"`
+	Payload        json.RawMessage       `json:"payload"`
+	Status         WebhookDeliveryStatus `json:"status"`
+	Attempts       int                   `json:"attempts"`
+	NextAttemptAt  time.Time             `json:"nextAttemptAt"`
+	LastStatusCode *int                  `json:"lastStatusCode,omitempty"`
+	LastError      string                `json:"lastError,omitempty"`
+	DeliveredAt    *time.Time            `json:"deliveredAt,omitempty"`
+	ReplayCount    int                   `json:"replayCount"`
+	CreatedAt      time.Time             `json:"createdAt"`
+}
+
+// WebhookDeliveryFilter webhook投递记录筛选条件
+type WebhookDeliveryFilter struct {
+	Status         WebhookDeliveryStatus
+	EventType      WebhookEventType
+	SubscriptionID *uuid.UUID
+}
+
+// WebhookSubscriptionCreate 创建webhook订阅
+type WebhookSubscriptionCreate struct {
+	Name   string             `json:"name" binding:"required"`
+	URL    string             `json:"url" binding:"required"`
+	Events []WebhookEventType `json:"events"` // 为空时订阅全部事件
+}
+
+// WebhookSubscriptionUpdate 更新webhook订阅，名称、地址与事件整体替换
+type WebhookSubscriptionUpdate struct {
+	Name    string             `json:"name" binding:"required"`
+	URL     string             `json:"url" binding:"required"`
+	Events  []WebhookEventType `json:"events"`
+	Enabled *bool              `json:"enabled,omitempty"` // 为空时保持不变
+}
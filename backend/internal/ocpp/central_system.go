@@ -0,0 +1,365 @@
+package ocpp
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"backend/internal/config"
+	"backend/internal/repository"
+	"backend/internal/service"
+
+	"github.com/google/uuid"
+)
+
+// 默认参数
+const (
+	defaultHeartbeatInterval = 60
+	defaultCallTimeout       = 10
+)
+
+// callHandler 处理充电桩发起的Call
+type callHandler func(cp *chargePoint, payload json.RawMessage) (any, *CallError)
+
+// CentralSystem OCPP 1.6-J 中央系统
+// 充电桩以 /ocpp/{chargePointId} 建立WebSocket连接，chargePointId 即充电桩ID
+type CentralSystem struct {
+	scheduler   *service.SchedulerService
+	pileService *service.ChargingPileService
+	sessionRepo *repository.ChargingSessionRepository
+
+	heartbeatInterval int
+	callTimeout       time.Duration
+
+	handlers     map[string]callHandler
+	chargePoints map[string]*chargePoint
+	mu           sync.RWMutex
+}
+
+// chargePoint 已连接的充电桩
+type chargePoint struct {
+	id   string
+	conn *Conn
+
+	pending         map[string]chan *message // 等待响应的Call
+	transactionID   int                      // 当前交易号，0表示无交易
+	stopRequested   bool                     // 已因充满下发远程停止
+	stoppedByServer map[int]bool             // 由调度器结束会话的交易，StopTransaction时无需再结算
+	mu              sync.Mutex
+}
+
+// NewCentralSystem 创建OCPP中央系统
+func NewCentralSystem(services *service.Services, cfg config.OCPPConfig) *CentralSystem {
+	cs := &CentralSystem{
+		scheduler:         services.Scheduler,
+		pileService:       services.ChargingPile,
+		sessionRepo:       services.ChargingSessionRepo,
+		heartbeatInterval: cfg.HeartbeatInterval,
+		callTimeout:       time.Duration(cfg.CallTimeout) * time.Second,
+		chargePoints:      make(map[string]*chargePoint),
+	}
+	if cs.heartbeatInterval <= 0 {
+		cs.heartbeatInterval = defaultHeartbeatInterval
+	}
+	if cs.callTimeout <= 0 {
+		cs.callTimeout = defaultCallTimeout * time.Second
+	}
+
+	cs.handlers = map[string]callHandler{
+		ActionBootNotification:   cs.handleBootNotification,
+		ActionHeartbeat:          cs.handleHeartbeat,
+		ActionStatusNotification: cs.handleStatusNotification,
+		ActionAuthorize:          cs.handleAuthorize,
+		ActionStartTransaction:   cs.handleStartTransaction,
+		ActionStopTransaction:    cs.handleStopTransaction,
+		ActionMeterValues:        cs.handleMeterValues,
+	}
+
+	return cs
+}
+
+// ServeWS 处理充电桩WebSocket连接
+func (cs *CentralSystem) ServeWS(w http.ResponseWriter, r *http.Request) {
+	chargePointID := r.PathValue("chargePointId")
+	if chargePointID == "" {
+		http.Error(w, "充电桩ID不能为空", http.StatusBadRequest)
+		return
+	}
+	if _, err := cs.pileService.GetPileByID(chargePointID); err != nil {
+		http.Error(w, "充电桩不存在", http.StatusNotFound)
+		return
+	}
+
+	// OCPP 1.6 安全配置1：升级连接前以HTTP基本认证校验充电桩身份
+	username, password, ok := r.BasicAuth()
+	if !ok || !cs.pileService.AuthenticateChargePoint(chargePointID, username, password) {
+		log.Printf("OCPP连接认证失败: 充电桩=%s (%s)", chargePointID, r.RemoteAddr)
+		w.Header().Set("WWW-Authenticate", `Basic realm="OCPP"`)
+		http.Error(w, "充电桩认证失败", http.StatusUnauthorized)
+		return
+	}
+
+	conn, err := upgrade(w, r, Subprotocol)
+	if err != nil {
+		log.Printf("OCPP连接升级失败: 充电桩=%s, 错误=%v", chargePointID, err)
+		return
+	}
+
+	cp := &chargePoint{
+		id:              chargePointID,
+		conn:            conn,
+		pending:         make(map[string]chan *message),
+		stoppedByServer: make(map[int]bool),
+	}
+
+	// 同一充电桩重复连接时替换旧连接
+	cs.mu.Lock()
+	if old, exists := cs.chargePoints[chargePointID]; exists {
+		old.conn.Close()
+	}
+	cs.chargePoints[chargePointID] = cp
+	cs.mu.Unlock()
+
+	log.Printf("OCPP充电桩已连接: %s (%s)", chargePointID, r.RemoteAddr)
+	cs.readLoop(cp)
+
+	cs.mu.Lock()
+	if cs.chargePoints[chargePointID] == cp {
+		delete(cs.chargePoints, chargePointID)
+	}
+	cs.mu.Unlock()
+	log.Printf("OCPP充电桩已断开: %s", chargePointID)
+}
+
+// readLoop 读取充电桩消息直到连接关闭
+func (cs *CentralSystem) readLoop(cp *chargePoint) {
+	defer cp.conn.Close()
+
+	// 超过两个心跳周期未收到任何消息视为掉线
+	timeout := time.Duration(cs.heartbeatInterval*2) * time.Second
+	for {
+		cp.conn.SetReadDeadline(time.Now().Add(timeout))
+		data, err := cp.conn.ReadMessage()
+		if err != nil {
+			if !errors.Is(err, errConnClosed) {
+				log.Printf("读取OCPP消息失败: 充电桩=%s, 错误=%v", cp.id, err)
+			}
+			cp.failPending()
+			return
+		}
+
+		msg, err := parseMessage(data)
+		if err != nil {
+			log.Printf("解析OCPP消息失败: 充电桩=%s, 错误=%v", cp.id, err)
+			continue
+		}
+
+		switch msg.Type {
+		case messageTypeCall:
+			// 处理可能再次下发指令（如开始下一辆车充电），不能阻塞读循环
+			go cs.handleCall(cp, msg)
+		case messageTypeCallResult, messageTypeCallError:
+			cp.resolve(msg)
+		}
+	}
+}
+
+// handleCall 分发充电桩发起的Call并回复
+func (cs *CentralSystem) handleCall(cp *chargePoint, msg *message) {
+	var reply []byte
+	var err error
+
+	handler, ok := cs.handlers[msg.Action]
+	if !ok {
+		reply, err = encodeCallError(msg.UniqueID, &CallError{Code: ErrorNotImplemented, Description: "不支持的动作: " + msg.Action})
+	} else {
+		result, callErr := handler(cp, msg.Payload)
+		if callErr != nil {
+			log.Printf("处理OCPP %s 失败: 充电桩=%s, 错误=%v", msg.Action, cp.id, callErr)
+			reply, err = encodeCallError(msg.UniqueID, callErr)
+		} else {
+			reply, err = encodeCallResult(msg.UniqueID, result)
+		}
+	}
+	if err != nil {
+		log.Printf("编码OCPP响应失败: %v", err)
+		return
+	}
+
+	if err := cp.conn.WriteText(reply); err != nil {
+		log.Printf("发送OCPP响应失败: 充电桩=%s, 错误=%v", cp.id, err)
+	}
+}
+
+// call 向充电桩发起Call并等待响应
+func (cs *CentralSystem) call(pileID, action string, request, response any) error {
+	cp := cs.getChargePoint(pileID)
+	if cp == nil {
+		return fmt.Errorf("充电桩 %s 未通过OCPP连接", pileID)
+	}
+
+	uniqueID := uuid.NewString()
+	data, err := encodeCall(uniqueID, action, request)
+	if err != nil {
+		return fmt.Errorf("编码OCPP请求失败: %w", err)
+	}
+
+	ch := make(chan *message, 1)
+	cp.mu.Lock()
+	cp.pending[uniqueID] = ch
+	cp.mu.Unlock()
+	defer func() {
+		cp.mu.Lock()
+		delete(cp.pending, uniqueID)
+		cp.mu.Unlock()
+	}()
+
+	if err := cp.conn.WriteText(data); err != nil {
+		return fmt.Errorf("发送OCPP请求失败: %w", err)
+	}
+
+	select {
+	case msg := <-ch:
+		if msg == nil {
+			return fmt.Errorf("充电桩 %s 连接已断开", pileID)
+		}
+		if msg.Type == messageTypeCallError {
+			return &CallError{Code: msg.ErrorCode, Description: msg.ErrorDesc}
+		}
+		if response != nil {
+			if err := json.Unmarshal(msg.Payload, response); err != nil {
+				return fmt.Errorf("解析OCPP响应失败: %w", err)
+			}
+		}
+		return nil
+	case <-time.After(cs.callTimeout):
+		return fmt.Errorf("等待充电桩 %s 响应 %s 超时", pileID, action)
+	}
+}
+
+// resolve 将响应交给等待中的Call
+func (cp *chargePoint) resolve(msg *message) {
+	cp.mu.Lock()
+	ch, ok := cp.pending[msg.UniqueID]
+	cp.mu.Unlock()
+	if !ok {
+		log.Printf("收到未知的OCPP响应: 充电桩=%s, 消息ID=%s", cp.id, msg.UniqueID)
+		return
+	}
+	select {
+	case ch <- msg:
+	default:
+	}
+}
+
+// failPending 连接断开时结束所有等待中的Call
+func (cp *chargePoint) failPending() {
+	cp.mu.Lock()
+	defer cp.mu.Unlock()
+	for id, ch := range cp.pending {
+		select {
+		case ch <- nil:
+		default:
+		}
+		delete(cp.pending, id)
+	}
+}
+
+// getChargePoint 获取已连接的充电桩
+func (cs *CentralSystem) getChargePoint(pileID string) *chargePoint {
+	cs.mu.RLock()
+	defer cs.mu.RUnlock()
+	return cs.chargePoints[pileID]
+}
+
+// IsConnected 充电桩是否通过OCPP连接
+func (cs *CentralSystem) IsConnected(pileID string) bool {
+	return cs.getChargePoint(pileID) != nil
+}
+
+// ConnectedChargePoints 已连接的充电桩ID列表
+func (cs *CentralSystem) ConnectedChargePoints() []string {
+	cs.mu.RLock()
+	defer cs.mu.RUnlock()
+
+	ids := make([]string, 0, len(cs.chargePoints))
+	for id := range cs.chargePoints {
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+// RemoteStart 远程启动充电
+func (cs *CentralSystem) RemoteStart(pileID string, userID uuid.UUID) error {
+	connectorID := 1
+	var resp StatusResponse
+	err := cs.call(pileID, ActionRemoteStartTransaction, &RemoteStartTransactionRequest{
+		ConnectorID: &connectorID,
+		IdTag:       IDTagForUser(userID),
+	}, &resp)
+	if err != nil {
+		return err
+	}
+	if resp.Status != RemoteAccepted {
+		return fmt.Errorf("充电桩 %s 拒绝远程启动", pileID)
+	}
+	return nil
+}
+
+// RemoteStop 远程停止充电桩当前交易
+func (cs *CentralSystem) RemoteStop(pileID string) error {
+	transactionID := cs.currentTransaction(pileID)
+	if transactionID == 0 {
+		return fmt.Errorf("充电桩 %s 没有进行中的OCPP交易", pileID)
+	}
+
+	var resp StatusResponse
+	if err := cs.call(pileID, ActionRemoteStopTransaction, &RemoteStopTransactionRequest{TransactionID: transactionID}, &resp); err != nil {
+		return err
+	}
+	if resp.Status != RemoteAccepted {
+		return fmt.Errorf("充电桩 %s 拒绝远程停止", pileID)
+	}
+	return nil
+}
+
+// ChangeAvailability 修改充电桩可用性，未通过OCPP连接的充电桩直接忽略
+func (cs *CentralSystem) ChangeAvailability(pileID string, available bool) error {
+	if !cs.IsConnected(pileID) {
+		return nil
+	}
+
+	availability := AvailabilityInoperative
+	if available {
+		availability = AvailabilityOperative
+	}
+
+	var resp StatusResponse
+	if err := cs.call(pileID, ActionChangeAvailability, &ChangeAvailabilityRequest{ConnectorID: 0, Type: availability}, &resp); err != nil {
+		return err
+	}
+	log.Printf("OCPP修改可用性: 充电桩=%s, 类型=%s, 结果=%s", pileID, availability, resp.Status)
+	return nil
+}
+
+// currentTransaction 获取充电桩当前交易号，内存中没有时从活跃会话恢复
+func (cs *CentralSystem) currentTransaction(pileID string) int {
+	if cp := cs.getChargePoint(pileID); cp != nil {
+		cp.mu.Lock()
+		transactionID := cp.transactionID
+		cp.mu.Unlock()
+		if transactionID != 0 {
+			return transactionID
+		}
+	}
+
+	session, err := cs.sessionRepo.GetActiveSessionByPileID(pileID)
+	if err != nil || session.OCPPTransactionID == nil {
+		return 0
+	}
+	return *session.OCPPTransactionID
+}
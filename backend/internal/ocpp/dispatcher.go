@@ -0,0 +1,57 @@
+package ocpp
+
+import (
+	"fmt"
+	"log"
+
+	"backend/internal/service"
+
+	"github.com/google/uuid"
+)
+
+// Dispatcher 充电指令分发器
+// 通过OCPP连接的充电桩使用远程启停，其余充电桩仍走模拟器HTTP接口
+type Dispatcher struct {
+	cs       *CentralSystem
+	fallback service.ChargingDispatcher
+}
+
+// NewDispatcher 创建充电指令分发器
+func NewDispatcher(cs *CentralSystem, fallback service.ChargingDispatcher) *Dispatcher {
+	return &Dispatcher{cs: cs, fallback: fallback}
+}
+
+// AssignCharging 分配充电任务
+func (d *Dispatcher) AssignCharging(req service.ChargingAssignRequest) error {
+	if !d.cs.IsConnected(req.PileID) {
+		return d.fallback.AssignCharging(req)
+	}
+
+	userID, err := uuid.Parse(req.UserID)
+	if err != nil {
+		return fmt.Errorf("无效的用户ID: %w", err)
+	}
+	return d.cs.RemoteStart(req.PileID, userID)
+}
+
+// StopCharging 停止充电，会话由调度器结束，充电桩上报的StopTransaction不再重复结算
+func (d *Dispatcher) StopCharging(req service.ChargingStopRequest) error {
+	pileID := req.PileID
+	if !d.cs.IsConnected(pileID) {
+		return d.fallback.StopCharging(req)
+	}
+
+	transactionID := d.cs.currentTransaction(pileID)
+	if transactionID == 0 {
+		log.Printf("充电桩 %s 尚未开始OCPP交易，无需远程停止", pileID)
+		return nil
+	}
+	if cp := d.cs.getChargePoint(pileID); cp != nil {
+		cp.mu.Lock()
+		cp.stoppedByServer[transactionID] = true
+		cp.mu.Unlock()
+	}
+
+	log.Printf("OCPP远程停止: 充电桩=%s, 用户=%s, 原因=%s", pileID, req.UserID, req.Reason)
+	return d.cs.RemoteStop(pileID)
+}
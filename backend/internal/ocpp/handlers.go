@@ -0,0 +1,348 @@
+package ocpp
+
+import (
+	"encoding/json"
+	"log"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"backend/internal/model"
+)
+
+// decodePayload 解析Call负载
+func decodePayload(payload json.RawMessage, v any) *CallError {
+	if err := json.Unmarshal(payload, v); err != nil {
+		return &CallError{Code: ErrorFormationViolation, Description: err.Error()}
+	}
+	return nil
+}
+
+// handleBootNotification 处理启动通知，只接受系统中已登记的充电桩
+func (cs *CentralSystem) handleBootNotification(cp *chargePoint, payload json.RawMessage) (any, *CallError) {
+	var req BootNotificationRequest
+	if callErr := decodePayload(payload, &req); callErr != nil {
+		return nil, callErr
+	}
+
+	status := RegistrationAccepted
+	if _, err := cs.pileService.GetPileByID(cp.id); err != nil {
+		status = RegistrationRejected
+	}
+	log.Printf("OCPP启动通知: 充电桩=%s, 厂商=%s, 型号=%s, 结果=%s", cp.id, req.ChargePointVendor, req.ChargePointModel, status)
+
+	return &BootNotificationResponse{
+		Status:      status,
+		CurrentTime: time.Now().UTC(),
+		Interval:    cs.heartbeatInterval,
+	}, nil
+}
+
+// handleHeartbeat 处理心跳
+func (cs *CentralSystem) handleHeartbeat(cp *chargePoint, payload json.RawMessage) (any, *CallError) {
+	return &HeartbeatResponse{CurrentTime: time.Now().UTC()}, nil
+}
+
+// handleStatusNotification 处理状态通知，故障与恢复走调度器的故障处理流程
+func (cs *CentralSystem) handleStatusNotification(cp *chargePoint, payload json.RawMessage) (any, *CallError) {
+	var req StatusNotificationRequest
+	if callErr := decodePayload(payload, &req); callErr != nil {
+		return nil, callErr
+	}
+
+	pile, err := cs.pileService.GetPileByID(cp.id)
+	if err != nil {
+		return nil, &CallError{Code: ErrorGenericError, Description: err.Error()}
+	}
+
+	switch req.Status {
+	case ChargePointFaulted:
+		if pile.Status == model.PileStatusFault {
+			break
+		}
+		faultType := faultTypeForErrorCode(req.ErrorCode)
+		description := req.ErrorCode
+		if req.Info != "" {
+			description += ": " + req.Info
+		}
+		if err := cs.pileService.ReportPileFault(cp.id, string(faultType), description); err != nil {
+			log.Printf("OCPP报告故障失败: 充电桩=%s, 错误=%v", cp.id, err)
+			break
+		}
+		if err := cs.scheduler.HandlePileFault(cp.id, string(faultType), description); err != nil {
+			log.Printf("OCPP故障调度失败: 充电桩=%s, 错误=%v", cp.id, err)
+		}
+	case ChargePointAvailable:
+		if pile.Status != model.PileStatusFault {
+			// 交易结束后恢复空闲表示车辆已拔枪驶离
+			cs.handleVehicleDeparted(cp, req.Timestamp)
+			break
+		}
+		if err := cs.scheduler.HandlePileRecovery(cp.id); err != nil {
+			log.Printf("OCPP故障恢复失败: 充电桩=%s, 错误=%v", cp.id, err)
+		}
+	}
+
+	return struct{}{}, nil
+}
+
+// handleVehicleDeparted 充电桩上有等待驶离的车辆时记录驶离
+func (cs *CentralSystem) handleVehicleDeparted(cp *chargePoint, timestamp *time.Time) {
+	parked, err := cs.sessionRepo.GetParkedSessionByPileID(cp.id)
+	if err != nil || parked == nil {
+		return
+	}
+	departedAt := time.Now().UTC()
+	if timestamp != nil {
+		departedAt = timestamp.UTC()
+	}
+	if _, err := cs.scheduler.HandleVehicleDeparture(parked.ID, cp.id, departedAt); err != nil {
+		log.Printf("OCPP车辆驶离处理失败: 充电桩=%s, 错误=%v", cp.id, err)
+	}
+}
+
+// faultTypeForErrorCode 将OCPP错误码映射为故障类型
+func faultTypeForErrorCode(errorCode string) model.FaultType {
+	switch errorCode {
+	case "OverVoltage", "UnderVoltage", "OverCurrentFailure", "GroundFailure", "PowerMeterFailure", "PowerSwitchFailure":
+		return model.FaultTypePower
+	case "InternalError", "ReaderFailure", "LocalListConflict", "WeakSignal":
+		return model.FaultTypeSoftware
+	default:
+		return model.FaultTypeHardware
+	}
+}
+
+// handleAuthorize 处理授权，idTag须属于该桩当前会话的用户
+func (cs *CentralSystem) handleAuthorize(cp *chargePoint, payload json.RawMessage) (any, *CallError) {
+	var req AuthorizeRequest
+	if callErr := decodePayload(payload, &req); callErr != nil {
+		return nil, callErr
+	}
+
+	status := AuthorizationInvalid
+	if session, err := cs.sessionRepo.GetActiveSessionByPileID(cp.id); err == nil && IDTagForUser(session.UserID) == req.IdTag {
+		status = AuthorizationAccepted
+	}
+
+	return &AuthorizeResponse{IdTagInfo: IdTagInfo{Status: status}}, nil
+}
+
+// handleStartTransaction 处理开始交易，将交易号绑定到调度器已创建的充电会话
+func (cs *CentralSystem) handleStartTransaction(cp *chargePoint, payload json.RawMessage) (any, *CallError) {
+	var req StartTransactionRequest
+	if callErr := decodePayload(payload, &req); callErr != nil {
+		return nil, callErr
+	}
+
+	session, err := cs.sessionRepo.GetActiveSessionByPileID(cp.id)
+	if err != nil || IDTagForUser(session.UserID) != req.IdTag {
+		log.Printf("OCPP开始交易被拒绝: 充电桩=%s, idTag=%s", cp.id, req.IdTag)
+		return &StartTransactionResponse{IdTagInfo: IdTagInfo{Status: AuthorizationInvalid}}, nil
+	}
+
+	transactionID, err := cs.sessionRepo.AttachOCPPTransaction(session.ID, req.MeterStart)
+	if err != nil {
+		return nil, &CallError{Code: ErrorInternalError, Description: err.Error()}
+	}
+
+	cp.mu.Lock()
+	cp.transactionID = transactionID
+	cp.stopRequested = false
+	cp.mu.Unlock()
+
+	log.Printf("OCPP开始交易: 充电桩=%s, 交易号=%d, 会话=%s, 起始读数=%dWh", cp.id, transactionID, session.ID, req.MeterStart)
+	return &StartTransactionResponse{
+		IdTagInfo:     IdTagInfo{Status: AuthorizationAccepted},
+		TransactionID: transactionID,
+	}, nil
+}
+
+// handleMeterValues 处理计量数据，换算为充电进度；达到请求电量时下发远程停止
+func (cs *CentralSystem) handleMeterValues(cp *chargePoint, payload json.RawMessage) (any, *CallError) {
+	var req MeterValuesRequest
+	if callErr := decodePayload(payload, &req); callErr != nil {
+		return nil, callErr
+	}
+
+	reading := latestReading(req.MeterValue)
+	if reading.powerOfferedKW != nil {
+		cs.updateOfferedPower(cp.id, *reading.powerOfferedKW)
+	}
+	if req.TransactionID == nil {
+		return struct{}{}, nil
+	}
+
+	session, err := cs.sessionRepo.GetByOCPPTransactionID(*req.TransactionID)
+	if err != nil || session.Status != model.SessionStatusActive {
+		return struct{}{}, nil
+	}
+
+	if reading.energyWh == nil {
+		return struct{}{}, nil
+	}
+
+	meterStart := 0
+	if session.OCPPMeterStart != nil {
+		meterStart = *session.OCPPMeterStart
+	}
+	capacity := math.Max(0, (*reading.energyWh-float64(meterStart))/1000)
+
+	remainingTime := 0
+	if reading.powerKW > 0 && capacity < session.RequestedCapacity {
+		remainingTime = int((session.RequestedCapacity - capacity) / reading.powerKW * 3600)
+	}
+
+	err = cs.scheduler.UpdateChargingProgress(&model.ChargingProgress{
+		SessionID:       session.ID,
+		PileID:          cp.id,
+		UserID:          session.UserID.String(),
+		CurrentCapacity: capacity,
+		CurrentSoC:      reading.soc,
+		CurrentPower:    reading.powerKW,
+		RemainingTime:   remainingTime,
+	})
+	if err != nil {
+		log.Printf("OCPP更新充电进度失败: 充电桩=%s, 错误=%v", cp.id, err)
+	}
+
+	// OCPP不携带目标电量，由中央系统在达到请求电量或目标SoC时停止
+	reached := capacity >= session.RequestedCapacity
+	if reading.soc != nil && session.TargetSoC != nil && *reading.soc >= *session.TargetSoC {
+		reached = true
+	}
+	if reached {
+		cp.mu.Lock()
+		alreadyRequested := cp.stopRequested
+		cp.stopRequested = true
+		cp.mu.Unlock()
+
+		if !alreadyRequested {
+			go func() {
+				if err := cs.RemoteStop(cp.id); err != nil {
+					log.Printf("OCPP远程停止失败: 充电桩=%s, 错误=%v", cp.id, err)
+				}
+			}()
+		}
+	}
+
+	return struct{}{}, nil
+}
+
+// handleStopTransaction 处理结束交易，调度器尚未结束的会话按充电完成结算
+func (cs *CentralSystem) handleStopTransaction(cp *chargePoint, payload json.RawMessage) (any, *CallError) {
+	var req StopTransactionRequest
+	if callErr := decodePayload(payload, &req); callErr != nil {
+		return nil, callErr
+	}
+
+	cp.mu.Lock()
+	stoppedByServer := cp.stoppedByServer[req.TransactionID]
+	delete(cp.stoppedByServer, req.TransactionID)
+	if cp.transactionID == req.TransactionID {
+		cp.transactionID = 0
+		cp.stopRequested = false
+	}
+	cp.mu.Unlock()
+
+	response := &StopTransactionResponse{IdTagInfo: &IdTagInfo{Status: AuthorizationAccepted}}
+
+	session, err := cs.sessionRepo.GetByOCPPTransactionID(req.TransactionID)
+	if err != nil {
+		log.Printf("OCPP结束交易: 充电桩=%s, 交易号=%d, 错误=%v", cp.id, req.TransactionID, err)
+		return response, nil
+	}
+	if stoppedByServer || session.Status != model.SessionStatusActive {
+		log.Printf("OCPP结束交易: 充电桩=%s, 交易号=%d, 会话已由调度器结束", cp.id, req.TransactionID)
+		return response, nil
+	}
+
+	meterStart := 0
+	if session.OCPPMeterStart != nil {
+		meterStart = *session.OCPPMeterStart
+	}
+	actualCapacity := math.Max(0, float64(req.MeterStop-meterStart)/1000)
+
+	endSoC := session.CurrentSoC
+	if reading := latestReading(req.TransactionData); reading.soc != nil {
+		endSoC = reading.soc
+	}
+
+	endTime := req.Timestamp
+	if endTime.IsZero() {
+		endTime = time.Now().UTC()
+	}
+	duration := int(endTime.Sub(session.StartTime).Seconds())
+
+	err = cs.scheduler.CompleteCharging(session.ID, cp.id, session.UserID.String(), session.StartTime, endTime,
+		session.RequestedCapacity, actualCapacity, endSoC, duration)
+	if err != nil {
+		log.Printf("OCPP充电完成处理失败: 充电桩=%s, 交易号=%d, 错误=%v", cp.id, req.TransactionID, err)
+	}
+
+	return response, nil
+}
+
+// updateOfferedPower 充电桩可提供功率低于额定功率时降功率运行，恢复到额定功率时结束降功率运行
+func (cs *CentralSystem) updateOfferedPower(pileID string, offeredKW float64) {
+	pile, err := cs.pileService.GetPileByID(pileID)
+	if err != nil {
+		return
+	}
+
+	switch {
+	case offeredKW > 0 && offeredKW < pile.Power:
+		if pile.DegradedPower != nil && *pile.DegradedPower == offeredKW {
+			return
+		}
+		if err := cs.pileService.ReportPileDegradation(pileID, string(model.FaultTypePower), "OCPP上报可提供功率降低", offeredKW); err != nil {
+			log.Printf("OCPP降功率运行处理失败: 充电桩=%s, 错误=%v", pileID, err)
+		}
+	case offeredKW >= pile.Power && pile.IsDegraded():
+		if _, err := cs.pileService.ClearPileDegradation(pileID); err != nil {
+			log.Printf("OCPP恢复额定功率失败: 充电桩=%s, 错误=%v", pileID, err)
+		}
+	}
+}
+
+// meterReading 从采样值中提取的读数
+type meterReading struct {
+	energyWh       *float64
+	powerKW        float64
+	powerOfferedKW *float64
+	soc            *float64
+}
+
+// latestReading 取最后一组计量数据中的电能、功率、可提供功率与SoC
+func latestReading(values []MeterValue) meterReading {
+	var reading meterReading
+	for _, mv := range values {
+		for _, sv := range mv.SampledValue {
+			value, err := strconv.ParseFloat(sv.Value, 64)
+			if err != nil {
+				continue
+			}
+			switch sv.Measurand {
+			case "", MeasurandEnergyActiveImportRegister:
+				if strings.EqualFold(sv.Unit, "kWh") {
+					value *= 1000
+				}
+				reading.energyWh = &value
+			case MeasurandPowerActiveImport:
+				if !strings.EqualFold(sv.Unit, "kW") {
+					value /= 1000
+				}
+				reading.powerKW = value
+			case MeasurandPowerOffered:
+				if !strings.EqualFold(sv.Unit, "kW") {
+					value /= 1000
+				}
+				reading.powerOfferedKW = &value
+			case MeasurandSoC:
+				reading.soc = &value
+			}
+		}
+	}
+	return reading
+}
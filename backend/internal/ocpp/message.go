@@ -0,0 +1,276 @@
+package ocpp
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Subprotocol OCPP 1.6-J WebSocket子协议
+const Subprotocol = "ocpp1.6"
+
+// OCPP-J 消息类型
+const (
+	messageTypeCall       = 2
+	messageTypeCallResult = 3
+	messageTypeCallError  = 4
+)
+
+// OCPP 动作
+const (
+	ActionBootNotification       = "BootNotification"
+	ActionHeartbeat              = "Heartbeat"
+	ActionStatusNotification     = "StatusNotification"
+	ActionAuthorize              = "Authorize"
+	ActionStartTransaction       = "StartTransaction"
+	ActionStopTransaction        = "StopTransaction"
+	ActionMeterValues            = "MeterValues"
+	ActionRemoteStartTransaction = "RemoteStartTransaction"
+	ActionRemoteStopTransaction  = "RemoteStopTransaction"
+	ActionChangeAvailability     = "ChangeAvailability"
+)
+
+// CallError 错误码
+const (
+	ErrorNotImplemented     = "NotImplemented"
+	ErrorInternalError      = "InternalError"
+	ErrorProtocolError      = "ProtocolError"
+	ErrorFormationViolation = "FormationViolation"
+	ErrorGenericError       = "GenericError"
+)
+
+// 状态取值
+const (
+	RegistrationAccepted = "Accepted"
+	RegistrationRejected = "Rejected"
+
+	AuthorizationAccepted = "Accepted"
+	AuthorizationInvalid  = "Invalid"
+	AuthorizationBlocked  = "Blocked"
+
+	ChargePointAvailable   = "Available"
+	ChargePointCharging    = "Charging"
+	ChargePointFaulted     = "Faulted"
+	ChargePointFinishing   = "Finishing" // 交易已结束，车辆尚未拔枪驶离
+	ChargePointUnavailable = "Unavailable"
+
+	AvailabilityOperative   = "Operative"
+	AvailabilityInoperative = "Inoperative"
+
+	RemoteAccepted = "Accepted"
+	RemoteRejected = "Rejected"
+)
+
+// 计量项
+const (
+	MeasurandEnergyActiveImportRegister = "Energy.Active.Import.Register"
+	MeasurandPowerActiveImport          = "Power.Active.Import"
+	MeasurandPowerOffered               = "Power.Offered"
+	MeasurandSoC                        = "SoC"
+)
+
+// message 解析后的OCPP-J消息
+type message struct {
+	Type      int
+	UniqueID  string
+	Action    string          // 仅Call
+	Payload   json.RawMessage // Call与CallResult
+	ErrorCode string          // 仅CallError
+	ErrorDesc string          // 仅CallError
+}
+
+// parseMessage 解析OCPP-J消息数组
+func parseMessage(data []byte) (*message, error) {
+	var fields []json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, fmt.Errorf("消息不是JSON数组: %w", err)
+	}
+	if len(fields) < 3 {
+		return nil, errors.New("消息字段不足")
+	}
+
+	msg := &message{}
+	if err := json.Unmarshal(fields[0], &msg.Type); err != nil {
+		return nil, fmt.Errorf("无效的消息类型: %w", err)
+	}
+	if err := json.Unmarshal(fields[1], &msg.UniqueID); err != nil {
+		return nil, fmt.Errorf("无效的消息ID: %w", err)
+	}
+
+	switch msg.Type {
+	case messageTypeCall:
+		if len(fields) != 4 {
+			return nil, errors.New("Call消息字段数量错误")
+		}
+		if err := json.Unmarshal(fields[2], &msg.Action); err != nil {
+			return nil, fmt.Errorf("无效的动作: %w", err)
+		}
+		msg.Payload = fields[3]
+	case messageTypeCallResult:
+		msg.Payload = fields[2]
+	case messageTypeCallError:
+		if len(fields) < 4 {
+			return nil, errors.New("CallError消息字段数量错误")
+		}
+		json.Unmarshal(fields[2], &msg.ErrorCode)
+		json.Unmarshal(fields[3], &msg.ErrorDesc)
+	default:
+		return nil, fmt.Errorf("未知的消息类型: %d", msg.Type)
+	}
+
+	return msg, nil
+}
+
+// encodeCall 编码Call消息
+func encodeCall(uniqueID, action string, payload any) ([]byte, error) {
+	return json.Marshal([]any{messageTypeCall, uniqueID, action, payload})
+}
+
+// encodeCallResult 编码CallResult消息
+func encodeCallResult(uniqueID string, payload any) ([]byte, error) {
+	return json.Marshal([]any{messageTypeCallResult, uniqueID, payload})
+}
+
+// encodeCallError 编码CallError消息
+func encodeCallError(uniqueID string, callErr *CallError) ([]byte, error) {
+	return json.Marshal([]any{messageTypeCallError, uniqueID, callErr.Code, callErr.Description, struct{}{}})
+}
+
+// CallError 处理Call时返回给充电桩的错误
+type CallError struct {
+	Code        string
+	Description string
+}
+
+// Error 实现error接口
+func (e *CallError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Code, e.Description)
+}
+
+// IDTagForUser 将用户ID转换为OCPP idTag（CiString20）
+func IDTagForUser(userID uuid.UUID) string {
+	return strings.ToUpper(strings.ReplaceAll(userID.String(), "-", ""))[:20]
+}
+
+// IdTagInfo 授权信息
+type IdTagInfo struct {
+	Status     string     `json:"status"`
+	ExpiryDate *time.Time `json:"expiryDate,omitempty"`
+}
+
+// BootNotificationRequest 充电桩启动通知
+type BootNotificationRequest struct {
+	ChargePointVendor       string `json:"chargePointVendor"`
+	ChargePointModel        string `json:"chargePointModel"`
+	ChargePointSerialNumber string `json:"chargePointSerialNumber,omitempty"`
+	FirmwareVersion         string `json:"firmwareVersion,omitempty"`
+}
+
+// BootNotificationResponse 启动通知响应
+type BootNotificationResponse struct {
+	Status      string    `json:"status"`
+	CurrentTime time.Time `json:"currentTime"`
+	Interval    int       `json:"interval"`
+}
+
+// HeartbeatResponse 心跳响应
+type HeartbeatResponse struct {
+	CurrentTime time.Time `json:"currentTime"`
+}
+
+// StatusNotificationRequest 状态通知
+type StatusNotificationRequest struct {
+	ConnectorID     int        `json:"connectorId"`
+	ErrorCode       string     `json:"errorCode"`
+	Status          string     `json:"status"`
+	Info            string     `json:"info,omitempty"`
+	Timestamp       *time.Time `json:"timestamp,omitempty"`
+	VendorErrorCode string     `json:"vendorErrorCode,omitempty"`
+}
+
+// AuthorizeRequest 授权请求
+type AuthorizeRequest struct {
+	IdTag string `json:"idTag"`
+}
+
+// AuthorizeResponse 授权响应
+type AuthorizeResponse struct {
+	IdTagInfo IdTagInfo `json:"idTagInfo"`
+}
+
+// StartTransactionRequest 开始交易
+type StartTransactionRequest struct {
+	ConnectorID   int       `json:"connectorId"`
+	IdTag         string    `json:"idTag"`
+	MeterStart    int       `json:"meterStart"` // Wh
+	ReservationID *int      `json:"reservationId,omitempty"`
+	Timestamp     time.Time `json:"timestamp"`
+}
+
+// StartTransactionResponse 开始交易响应
+type StartTransactionResponse struct {
+	IdTagInfo     IdTagInfo `json:"idTagInfo"`
+	TransactionID int       `json:"transactionId"`
+}
+
+// StopTransactionRequest 结束交易
+type StopTransactionRequest struct {
+	IdTag           string       `json:"idTag,omitempty"`
+	MeterStop       int          `json:"meterStop"` // Wh
+	Timestamp       time.Time    `json:"timestamp"`
+	TransactionID   int          `json:"transactionId"`
+	Reason          string       `json:"reason,omitempty"`
+	TransactionData []MeterValue `json:"transactionData,omitempty"`
+}
+
+// StopTransactionResponse 结束交易响应
+type StopTransactionResponse struct {
+	IdTagInfo *IdTagInfo `json:"idTagInfo,omitempty"`
+}
+
+// MeterValuesRequest 计量数据
+type MeterValuesRequest struct {
+	ConnectorID   int          `json:"connectorId"`
+	TransactionID *int         `json:"transactionId,omitempty"`
+	MeterValue    []MeterValue `json:"meterValue"`
+}
+
+// MeterValue 某一时刻的计量数据
+type MeterValue struct {
+	Timestamp    time.Time      `json:"timestamp"`
+	SampledValue []SampledValue `json:"sampledValue"`
+}
+
+// SampledValue 单项采样值
+type SampledValue struct {
+	Value     string `json:"value"`
+	Context   string `json:"context,omitempty"`
+	Measurand string `json:"measurand,omitempty"`
+	Unit      string `json:"unit,omitempty"`
+}
+
+// RemoteStartTransactionRequest 远程启动充电
+type RemoteStartTransactionRequest struct {
+	ConnectorID *int   `json:"connectorId,omitempty"`
+	IdTag       string `json:"idTag"`
+}
+
+// RemoteStopTransactionRequest 远程停止充电
+type RemoteStopTransactionRequest struct {
+	TransactionID int `json:"transactionId"`
+}
+
+// ChangeAvailabilityRequest 修改可用性
+type ChangeAvailabilityRequest struct {
+	ConnectorID int    `json:"connectorId"`
+	Type        string `json:"type"` // Operative|Inoperative
+}
+
+// StatusResponse 仅包含状态字段的响应
+type StatusResponse struct {
+	Status string `json:"status"`
+}
@@ -0,0 +1,94 @@
+package ocpp
+
+import "testing"
+
+func TestParseMessage(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		want    message
+		wantErr bool
+	}{
+		{
+			name: "Call",
+			data: `[2,"42","Heartbeat",{}]`,
+			want: message{Type: messageTypeCall, UniqueID: "42", Action: "Heartbeat", Payload: []byte(`{}`)},
+		},
+		{
+			name: "CallResult",
+			data: `[3,"42",{"currentTime":"2024-06-01T00:00:00Z"}]`,
+			want: message{Type: messageTypeCallResult, UniqueID: "42", Payload: []byte(`{"currentTime":"2024-06-01T00:00:00Z"}`)},
+		},
+		{
+			name: "CallError",
+			data: `[4,"42","NotImplemented","不支持",{}]`,
+			want: message{Type: messageTypeCallError, UniqueID: "42", ErrorCode: "NotImplemented", ErrorDesc: "不支持"},
+		},
+		{name: "不是数组", data: `{"type":2}`, wantErr: true},
+		{name: "字段不足", data: `[2,"42"]`, wantErr: true},
+		{name: "Call缺少负载", data: `[2,"42","Heartbeat"]`, wantErr: true},
+		{name: "CallError字段不足", data: `[4,"42","NotImplemented"]`, wantErr: true},
+		{name: "消息ID不是字符串", data: `[2,42,"Heartbeat",{}]`, wantErr: true},
+		{name: "未知消息类型", data: `[5,"42",{}]`, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseMessage([]byte(tt.data))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseMessage() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if got.Type != tt.want.Type || got.UniqueID != tt.want.UniqueID || got.Action != tt.want.Action ||
+				string(got.Payload) != string(tt.want.Payload) ||
+				got.ErrorCode != tt.want.ErrorCode || got.ErrorDesc != tt.want.ErrorDesc {
+				t.Errorf("parseMessage() = %+v, want %+v", *got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEncodeMessages(t *testing.T) {
+	tests := []struct {
+		name   string
+		encode func() ([]byte, error)
+		want   string
+	}{
+		{
+			name: "Call",
+			encode: func() ([]byte, error) {
+				return encodeCall("1", "RemoteStopTransaction", map[string]int{"transactionId": 7})
+			},
+			want: `[2,"1","RemoteStopTransaction",{"transactionId":7}]`,
+		},
+		{
+			name:   "CallResult",
+			encode: func() ([]byte, error) { return encodeCallResult("1", map[string]string{"status": "Accepted"}) },
+			want:   `[3,"1",{"status":"Accepted"}]`,
+		},
+		{
+			name: "CallError",
+			encode: func() ([]byte, error) {
+				return encodeCallError("1", &CallError{Code: ErrorNotImplemented, Description: "不支持"})
+			},
+			want: `[4,"1","NotImplemented","不支持",{}]`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := tt.encode()
+			if err != nil {
+				t.Fatalf("编码失败: %v", err)
+			}
+			if string(data) != tt.want {
+				t.Errorf("编码结果 = %s, want %s", data, tt.want)
+			}
+			if _, err := parseMessage(data); err != nil {
+				t.Errorf("编码结果无法解析: %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,103 @@
+package ocpp
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// maxMessageSize 单条消息最大长度
+const maxMessageSize = 1 << 20
+
+// writeTimeout 单条消息的发送超时
+const writeTimeout = 10 * time.Second
+
+// errConnClosed 连接已关闭
+var errConnClosed = errors.New("WebSocket连接已关闭")
+
+// upgrader 充电桩不是浏览器，不校验Origin；认证在升级前以HTTP基本认证完成
+var upgrader = websocket.Upgrader{
+	CheckOrigin: func(*http.Request) bool { return true },
+}
+
+// Conn 服务端WebSocket连接，仅提供OCPP所需的文本消息收发
+// 帧编解码、ping/pong与关闭握手由 gorilla/websocket 处理
+type Conn struct {
+	ws      *websocket.Conn
+	writeMu sync.Mutex // gorilla/websocket 同一时刻只允许一个写入方
+	closed  bool
+}
+
+// upgrade 将HTTP请求升级为WebSocket连接，并协商OCPP子协议
+// 客户端提供了子协议但不包含 subprotocol 时拒绝升级；未提供时按 subprotocol 处理
+func upgrade(w http.ResponseWriter, r *http.Request, subprotocol string) (*Conn, error) {
+	if offered := websocket.Subprotocols(r); len(offered) > 0 && !contains(offered, subprotocol) {
+		http.Error(w, "不支持的子协议", http.StatusBadRequest)
+		return nil, fmt.Errorf("客户端未提供子协议 %s", subprotocol)
+	}
+
+	header := http.Header{"Sec-WebSocket-Protocol": {subprotocol}}
+	ws, err := upgrader.Upgrade(w, r, header)
+	if err != nil {
+		// Upgrade 失败时已向客户端写入错误响应
+		return nil, err
+	}
+	ws.SetReadLimit(maxMessageSize)
+	return &Conn{ws: ws}, nil
+}
+
+// contains 判断子协议列表中是否包含指定值
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// SetReadDeadline 设置读超时
+func (c *Conn) SetReadDeadline(t time.Time) error {
+	return c.ws.SetReadDeadline(t)
+}
+
+// ReadMessage 读取一条完整的数据消息，对端正常关闭时返回 errConnClosed
+func (c *Conn) ReadMessage() ([]byte, error) {
+	_, data, err := c.ws.ReadMessage()
+	if err != nil {
+		if websocket.IsCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) {
+			c.Close()
+			return nil, errConnClosed
+		}
+		return nil, err
+	}
+	return data, nil
+}
+
+// WriteText 发送文本消息
+func (c *Conn) WriteText(data []byte) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+
+	if c.closed {
+		return errConnClosed
+	}
+	c.ws.SetWriteDeadline(time.Now().Add(writeTimeout))
+	return c.ws.WriteMessage(websocket.TextMessage, data)
+}
+
+// Close 关闭连接
+func (c *Conn) Close() error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+
+	if c.closed {
+		return nil
+	}
+	c.closed = true
+	return c.ws.Close()
+}
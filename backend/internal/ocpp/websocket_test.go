@@ -0,0 +1,179 @@
+package ocpp
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// newTestServer 启动升级为WebSocket的测试服务端，升级成功的连接通过通道返回
+func newTestServer(t *testing.T) (string, <-chan *Conn) {
+	t.Helper()
+	conns := make(chan *Conn, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrade(w, r, Subprotocol)
+		if err != nil {
+			return
+		}
+		conns <- conn
+	}))
+	t.Cleanup(server.Close)
+	return "ws" + strings.TrimPrefix(server.URL, "http"), conns
+}
+
+// acceptConn 等待服务端完成升级
+func acceptConn(t *testing.T, conns <-chan *Conn) *Conn {
+	t.Helper()
+	select {
+	case conn := <-conns:
+		t.Cleanup(func() { conn.Close() })
+		return conn
+	case <-time.After(5 * time.Second):
+		t.Fatal("等待服务端升级超时")
+		return nil
+	}
+}
+
+func TestUpgradeSubprotocol(t *testing.T) {
+	tests := []struct {
+		name       string
+		offered    []string
+		wantStatus int
+	}{
+		{name: "提供OCPP子协议", offered: []string{Subprotocol}, wantStatus: http.StatusSwitchingProtocols},
+		{name: "提供多个子协议", offered: []string{"ocpp2.0.1", Subprotocol}, wantStatus: http.StatusSwitchingProtocols},
+		{name: "未提供子协议", offered: nil, wantStatus: http.StatusSwitchingProtocols},
+		{name: "仅提供其他子协议", offered: []string{"ocpp2.0.1"}, wantStatus: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			url, _ := newTestServer(t)
+			dialer := websocket.Dialer{Subprotocols: tt.offered}
+			ws, resp, err := dialer.Dial(url, nil)
+			if resp == nil {
+				t.Fatalf("Dial() error = %v", err)
+			}
+			if resp.StatusCode != tt.wantStatus {
+				t.Fatalf("状态码 = %d, want %d", resp.StatusCode, tt.wantStatus)
+			}
+			if ws == nil {
+				return
+			}
+			defer ws.Close()
+			if got := ws.Subprotocol(); got != Subprotocol {
+				t.Errorf("协商的子协议 = %q, want %q", got, Subprotocol)
+			}
+		})
+	}
+}
+
+func TestConnMessages(t *testing.T) {
+	url, conns := newTestServer(t)
+	ws, _, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		t.Fatalf("Dial() error = %v", err)
+	}
+	defer ws.Close()
+	conn := acceptConn(t, conns)
+
+	// 客户端分片发送的消息由服务端合并
+	writer, err := ws.NextWriter(websocket.TextMessage)
+	if err != nil {
+		t.Fatalf("NextWriter() error = %v", err)
+	}
+	writer.Write([]byte(`[2,"1",`))
+	writer.Write([]byte(`"Heartbeat",{}]`))
+	writer.Close()
+
+	data, err := conn.ReadMessage()
+	if err != nil {
+		t.Fatalf("ReadMessage() error = %v", err)
+	}
+	if string(data) != `[2,"1","Heartbeat",{}]` {
+		t.Errorf("ReadMessage() = %s", data)
+	}
+
+	if err := conn.WriteText([]byte(`[3,"1",{}]`)); err != nil {
+		t.Fatalf("WriteText() error = %v", err)
+	}
+	messageType, reply, err := ws.ReadMessage()
+	if err != nil || messageType != websocket.TextMessage || string(reply) != `[3,"1",{}]` {
+		t.Errorf("客户端收到 %d %s, %v", messageType, reply, err)
+	}
+}
+
+func TestConnPingDoesNotInterruptRead(t *testing.T) {
+	url, conns := newTestServer(t)
+	ws, _, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		t.Fatalf("Dial() error = %v", err)
+	}
+	defer ws.Close()
+	conn := acceptConn(t, conns)
+
+	pong := make(chan string, 1)
+	ws.SetPongHandler(func(data string) error {
+		pong <- data
+		return nil
+	})
+	go ws.ReadMessage() // 客户端需要读取才能处理pong
+
+	if err := ws.WriteControl(websocket.PingMessage, []byte("hb"), time.Now().Add(time.Second)); err != nil {
+		t.Fatalf("WriteControl() error = %v", err)
+	}
+	ws.WriteMessage(websocket.TextMessage, []byte("after-ping"))
+
+	data, err := conn.ReadMessage()
+	if err != nil || string(data) != "after-ping" {
+		t.Fatalf("ReadMessage() = %s, %v", data, err)
+	}
+	select {
+	case got := <-pong:
+		if got != "hb" {
+			t.Errorf("pong = %q, want %q", got, "hb")
+		}
+	case <-time.After(5 * time.Second):
+		t.Error("未收到pong")
+	}
+}
+
+func TestConnRejectsOversizedMessage(t *testing.T) {
+	url, conns := newTestServer(t)
+	ws, _, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		t.Fatalf("Dial() error = %v", err)
+	}
+	defer ws.Close()
+	conn := acceptConn(t, conns)
+
+	ws.WriteMessage(websocket.TextMessage, make([]byte, maxMessageSize+1))
+	if _, err := conn.ReadMessage(); err == nil || errors.Is(err, errConnClosed) {
+		t.Errorf("ReadMessage() error = %v, want 消息过大", err)
+	}
+}
+
+func TestConnClose(t *testing.T) {
+	url, conns := newTestServer(t)
+	ws, _, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		t.Fatalf("Dial() error = %v", err)
+	}
+	defer ws.Close()
+	conn := acceptConn(t, conns)
+
+	closeMsg := websocket.FormatCloseMessage(websocket.CloseNormalClosure, "")
+	ws.WriteControl(websocket.CloseMessage, closeMsg, time.Now().Add(time.Second))
+
+	if _, err := conn.ReadMessage(); !errors.Is(err, errConnClosed) {
+		t.Fatalf("ReadMessage() error = %v, want errConnClosed", err)
+	}
+	if err := conn.WriteText([]byte("x")); !errors.Is(err, errConnClosed) {
+		t.Errorf("关闭后 WriteText() error = %v, want errConnClosed", err)
+	}
+}
@@ -0,0 +1,116 @@
+package repository
+
+import (
+	"database/sql"
+	"encoding/json"
+
+	"backend/internal/model"
+
+	"github.com/google/uuid"
+)
+
+// AuditLogRepository 审计日志仓库
+type AuditLogRepository struct {
+	db *sql.DB
+}
+
+// NewAuditLogRepository 创建审计日志仓库
+func NewAuditLogRepository(db *sql.DB) *AuditLogRepository {
+	return &AuditLogRepository{
+		db: db,
+	}
+}
+
+// Create 写入审计日志
+func (r *AuditLogRepository) Create(log *model.AuditLog) error {
+	var detail []byte
+	if log.Detail != nil {
+		var err error
+		if detail, err = json.Marshal(log.Detail); err != nil {
+			return err
+		}
+	}
+
+	var pileID any
+	if log.PileID != "" {
+		pileID = log.PileID
+	}
+
+	query := `
+		INSERT INTO audit_logs (id, operator_id, action, request_id, pile_id, reason, detail, success, error_message, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+	`
+	_, err := r.db.Exec(query,
+		log.ID,
+		log.OperatorID,
+		log.Action,
+		log.RequestID,
+		pileID,
+		log.Reason,
+		detail,
+		log.Success,
+		log.ErrorMessage,
+		log.CreatedAt,
+	)
+	return err
+}
+
+// GetLogs 分页查询审计日志，action为空时返回全部操作
+func (r *AuditLogRepository) GetLogs(action model.AuditAction, page, pageSize int) ([]*model.AuditLog, int, error) {
+	var total int
+	if err := r.db.QueryRow(`SELECT COUNT(*) FROM audit_logs WHERE $1 = '' OR action = $1`, action).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	rows, err := r.db.Query(`
+		SELECT id, operator_id, action, request_id, pile_id, reason, detail, success, error_message, created_at
+		FROM audit_logs
+		WHERE $1 = '' OR action = $1
+		ORDER BY created_at DESC
+		LIMIT $2 OFFSET $3
+	`, action, pageSize, (page-1)*pageSize)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	logs := []*model.AuditLog{}
+	for rows.Next() {
+		var log model.AuditLog
+		var operatorID, requestID uuid.NullUUID
+		var pileID, reason, errorMessage sql.NullString
+		var detail []byte
+		err := rows.Scan(
+			&log.ID,
+			&operatorID,
+			&log.Action,
+			&requestID,
+			&pileID,
+			&reason,
+			&detail,
+			&log.Success,
+			&errorMessage,
+			&log.CreatedAt,
+		)
+		if err != nil {
+			return nil, 0, err
+		}
+		if operatorID.Valid {
+			log.OperatorID = &operatorID.UUID
+		}
+		if requestID.Valid {
+			log.RequestID = &requestID.UUID
+		}
+		if detail != nil {
+			if err := json.Unmarshal(detail, &log.Detail); err != nil {
+				return nil, 0, err
+			}
+		}
+		log.PileID = pileID.String
+		log.Reason = reason.String
+		log.ErrorMessage = errorMessage.String
+		logs = append(logs, &log)
+	}
+
+	return logs, total, rows.Err()
+}
@@ -22,21 +22,60 @@ func NewBillingRepository(db *sql.DB) *BillingRepository {
 	}
 }
 
+// billingColumns 充电详单查询字段
+const billingColumns = `id, session_id, user_id, pile_id, charging_capacity, charging_duration, degraded_duration,
+		       start_time, stop_time, unit_price, price_type, charging_fee, service_fee, idle_minutes, idle_fee,
+		       total_fee, start_soc, end_soc, generated_at`
+
+// scanBillingDetail 扫描充电详单记录，处理可能为NULL的字段
+func scanBillingDetail(row interface{ Scan(...any) error }) (*model.BillingDetail, error) {
+	var bill model.BillingDetail
+	var startSoC, endSoC sql.NullFloat64
+
+	err := row.Scan(
+		&bill.ID,
+		&bill.SessionID,
+		&bill.UserID,
+		&bill.PileID,
+		&bill.ChargingCapacity,
+		&bill.ChargingDuration,
+		&bill.DegradedDuration,
+		&bill.StartTime,
+		&bill.EndTime,
+		&bill.UnitPrice,
+		&bill.PriceType,
+		&bill.ChargingFee,
+		&bill.ServiceFee,
+		&bill.IdleMinutes,
+		&bill.IdleFee,
+		&bill.TotalFee,
+		&startSoC,
+		&endSoC,
+		&bill.GeneratedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	bill.StartSoC = nullFloatPtr(startSoC)
+	bill.EndSoC = nullFloatPtr(endSoC)
+
+	return &bill, nil
+}
+
 // CreateBillingDetail 创建充电详单
 func (r *BillingRepository) CreateBillingDetail(bill *model.BillingDetail) (*model.BillingDetail, error) {
 	query := `
 		INSERT INTO billing_details 
-		(id, session_id, user_id, pile_id, charging_capacity, charging_duration,
-		 start_time, stop_time, unit_price, price_type, charging_fee, service_fee, total_fee, generated_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14)
-		RETURNING id, session_id, user_id, pile_id, charging_capacity, charging_duration,
-				  start_time, stop_time, unit_price, price_type, charging_fee, service_fee, total_fee, generated_at
-	`
+		(id, session_id, user_id, pile_id, charging_capacity, charging_duration, degraded_duration,
+		 start_time, stop_time, unit_price, price_type, charging_fee, service_fee, idle_minutes, idle_fee,
+		 total_fee, start_soc, end_soc, generated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19)
+		RETURNING ` + billingColumns
 
 	now := time.Now().UTC()
 
-	var newBill model.BillingDetail
-	err := r.db.QueryRow(
+	return scanBillingDetail(r.db.QueryRow(
 		query,
 		bill.ID,
 		bill.SessionID,
@@ -44,110 +83,53 @@ func (r *BillingRepository) CreateBillingDetail(bill *model.BillingDetail) (*mod
 		bill.PileID,
 		bill.ChargingCapacity,
 		bill.ChargingDuration,
+		bill.DegradedDuration,
 		bill.StartTime,
 		bill.EndTime,
 		bill.UnitPrice,
 		bill.PriceType,
 		bill.ChargingFee,
 		bill.ServiceFee,
+		bill.IdleMinutes,
+		bill.IdleFee,
 		bill.TotalFee,
+		floatPtrParam(bill.StartSoC),
+		floatPtrParam(bill.EndSoC),
 		now,
-	).Scan(
-		&newBill.ID,
-		&newBill.SessionID,
-		&newBill.UserID,
-		&newBill.PileID,
-		&newBill.ChargingCapacity,
-		&newBill.ChargingDuration,
-		&newBill.StartTime,
-		&newBill.EndTime,
-		&newBill.UnitPrice,
-		&newBill.PriceType,
-		&newBill.ChargingFee,
-		&newBill.ServiceFee,
-		&newBill.TotalFee,
-		&newBill.GeneratedAt,
-	)
-
-	if err != nil {
-		return nil, err
-	}
-
-	return &newBill, nil
+	))
 }
 
 // GetByID 通过ID获取充电详单
 func (r *BillingRepository) GetByID(id uuid.UUID) (*model.BillingDetail, error) {
 	query := `
-		SELECT id, session_id, user_id, pile_id, charging_capacity, charging_duration,
-			   start_time, stop_time, unit_price, price_type, charging_fee, service_fee, total_fee, generated_at
+		SELECT ` + billingColumns + `
 		FROM billing_details
 		WHERE id = $1
 	`
 
-	var bill model.BillingDetail
-	err := r.db.QueryRow(query, id).Scan(
-		&bill.ID,
-		&bill.SessionID,
-		&bill.UserID,
-		&bill.PileID,
-		&bill.ChargingCapacity,
-		&bill.ChargingDuration,
-		&bill.StartTime,
-		&bill.EndTime,
-		&bill.UnitPrice,
-		&bill.PriceType,
-		&bill.ChargingFee,
-		&bill.ServiceFee,
-		&bill.TotalFee,
-		&bill.GeneratedAt,
-	)
-
-	if err != nil {
-		if err == sql.ErrNoRows {
-			return nil, err
-		}
-		return nil, err
-	}
-
-	return &bill, nil
+	return scanBillingDetail(r.db.QueryRow(query, id))
 }
 
 // GetBySessionID 通过会话ID获取充电详单
 func (r *BillingRepository) GetBySessionID(sessionID uuid.UUID) (*model.BillingDetail, error) {
 	query := `
-		SELECT id, session_id, user_id, pile_id, charging_capacity, charging_duration,
-			   start_time, stop_time, unit_price, price_type, charging_fee, service_fee, total_fee, generated_at
+		SELECT ` + billingColumns + `
 		FROM billing_details
 		WHERE session_id = $1
 	`
 
-	var bill model.BillingDetail
-	err := r.db.QueryRow(query, sessionID).Scan(
-		&bill.ID,
-		&bill.SessionID,
-		&bill.UserID,
-		&bill.PileID,
-		&bill.ChargingCapacity,
-		&bill.ChargingDuration,
-		&bill.StartTime,
-		&bill.EndTime,
-		&bill.UnitPrice,
-		&bill.PriceType,
-		&bill.ChargingFee,
-		&bill.ServiceFee,
-		&bill.TotalFee,
-		&bill.GeneratedAt,
-	)
+	return scanBillingDetail(r.db.QueryRow(query, sessionID))
+}
 
-	if err != nil {
-		if err == sql.ErrNoRows {
-			return nil, err
-		}
-		return nil, err
-	}
+// UpdateIdleFee 记录详单的占位时长与占位费，并重新计算总费用
+func (r *BillingRepository) UpdateIdleFee(id uuid.UUID, idleMinutes int, idleFee float64) (*model.BillingDetail, error) {
+	query := `
+		UPDATE billing_details
+		SET idle_minutes = $2, idle_fee = $3, total_fee = charging_fee + service_fee + $3
+		WHERE id = $1
+		RETURNING ` + billingColumns
 
-	return &bill, nil
+	return scanBillingDetail(r.db.QueryRow(query, id, idleMinutes, idleFee))
 }
 
 // GetUserBillingDetails 获取用户的充电详单
@@ -184,13 +166,12 @@ func (r *BillingRepository) GetUserBillingDetails(userID uuid.UUID, startDate, e
 	// 分页查询
 	offset := (page - 1) * pageSize
 	query := fmt.Sprintf(`
-		SELECT id, session_id, user_id, pile_id, charging_capacity, charging_duration,
-			   start_time, stop_time, unit_price, price_type, charging_fee, service_fee, total_fee, generated_at
+		SELECT %s
 		FROM billing_details
 		%s
 		ORDER BY generated_at DESC
 		LIMIT $%d OFFSET $%d
-	`, whereClause, paramCount+1, paramCount+2)
+	`, billingColumns, whereClause, paramCount+1, paramCount+2)
 
 	queryArgs = append(queryArgs, pageSize, offset)
 	rows, err := r.db.Query(query, queryArgs...)
@@ -201,27 +182,11 @@ func (r *BillingRepository) GetUserBillingDetails(userID uuid.UUID, startDate, e
 
 	var bills []*model.BillingDetail
 	for rows.Next() {
-		var bill model.BillingDetail
-		err := rows.Scan(
-			&bill.ID,
-			&bill.SessionID,
-			&bill.UserID,
-			&bill.PileID,
-			&bill.ChargingCapacity,
-			&bill.ChargingDuration,
-			&bill.StartTime,
-			&bill.EndTime,
-			&bill.UnitPrice,
-			&bill.PriceType,
-			&bill.ChargingFee,
-			&bill.ServiceFee,
-			&bill.TotalFee,
-			&bill.GeneratedAt,
-		)
+		bill, err := scanBillingDetail(rows)
 		if err != nil {
 			return nil, 0, err
 		}
-		bills = append(bills, &bill)
+		bills = append(bills, bill)
 	}
 
 	if err := rows.Err(); err != nil {
@@ -231,6 +196,13 @@ func (r *BillingRepository) GetUserBillingDetails(userID uuid.UUID, startDate, e
 	return bills, total, nil
 }
 
+// GetUserTotalFee 获取用户累计消费金额
+func (r *BillingRepository) GetUserTotalFee(userID uuid.UUID) (float64, error) {
+	var total float64
+	err := r.db.QueryRow(`SELECT COALESCE(SUM(total_fee), 0) FROM billing_details WHERE user_id = $1`, userID).Scan(&total)
+	return total, err
+}
+
 // GetCurrentPricing 获取当前时间对应的电价配置
 func (r *BillingRepository) GetCurrentPricing(t time.Time) (*model.PriceRate, error) {
 	hour, min, sec := t.Clock()
@@ -20,11 +20,42 @@ func NewChargingPileRepository(db *sql.DB) *ChargingPileRepository {
 	}
 }
 
+// chargingPileColumns 充电桩查询字段
+const chargingPileColumns = `id, pile_type, power, degraded_power, status, queue_length,
+		       total_sessions, total_duration, total_energy, created_at, updated_at`
+
+// scanChargingPile 扫描充电桩记录，处理可能为NULL的字段
+func scanChargingPile(row interface{ Scan(...any) error }) (*model.ChargingPile, error) {
+	var pile model.ChargingPile
+	var degradedPower sql.NullFloat64
+
+	err := row.Scan(
+		&pile.ID,
+		&pile.PileType,
+		&pile.Power,
+		&degradedPower,
+		&pile.Status,
+		&pile.QueueLength,
+		&pile.TotalSessions,
+		&pile.TotalDuration,
+		&pile.TotalEnergy,
+		&pile.CreatedAt,
+		&pile.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if degradedPower.Valid {
+		pile.DegradedPower = &degradedPower.Float64
+	}
+	return &pile, nil
+}
+
 // GetAll 获取所有充电桩
 func (r *ChargingPileRepository) GetAll() ([]*model.ChargingPile, error) {
 	query := `
-		SELECT id, pile_type, power, status, queue_length, 
-		       total_sessions, total_duration, total_energy, created_at, updated_at
+		SELECT ` + chargingPileColumns + `
 		FROM charging_piles
 		ORDER BY id
 	`
@@ -37,23 +68,11 @@ func (r *ChargingPileRepository) GetAll() ([]*model.ChargingPile, error) {
 
 	var piles []*model.ChargingPile
 	for rows.Next() {
-		var pile model.ChargingPile
-		err := rows.Scan(
-			&pile.ID,
-			&pile.PileType,
-			&pile.Power,
-			&pile.Status,
-			&pile.QueueLength,
-			&pile.TotalSessions,
-			&pile.TotalDuration,
-			&pile.TotalEnergy,
-			&pile.CreatedAt,
-			&pile.UpdatedAt,
-		)
+		pile, err := scanChargingPile(rows)
 		if err != nil {
 			return nil, err
 		}
-		piles = append(piles, &pile)
+		piles = append(piles, pile)
 	}
 
 	if err := rows.Err(); err != nil {
@@ -66,26 +85,12 @@ func (r *ChargingPileRepository) GetAll() ([]*model.ChargingPile, error) {
 // GetByID 根据ID获取充电桩
 func (r *ChargingPileRepository) GetByID(id string) (*model.ChargingPile, error) {
 	query := `
-		SELECT id, pile_type, power, status, queue_length, 
-		       total_sessions, total_duration, total_energy, created_at, updated_at
+		SELECT ` + chargingPileColumns + `
 		FROM charging_piles
 		WHERE id = $1
 	`
 
-	var pile model.ChargingPile
-	err := r.db.QueryRow(query, id).Scan(
-		&pile.ID,
-		&pile.PileType,
-		&pile.Power,
-		&pile.Status,
-		&pile.QueueLength,
-		&pile.TotalSessions,
-		&pile.TotalDuration,
-		&pile.TotalEnergy,
-		&pile.CreatedAt,
-		&pile.UpdatedAt,
-	)
-
+	pile, err := scanChargingPile(r.db.QueryRow(query, id))
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, errors.New("充电桩不存在")
@@ -93,14 +98,13 @@ func (r *ChargingPileRepository) GetByID(id string) (*model.ChargingPile, error)
 		return nil, err
 	}
 
-	return &pile, nil
+	return pile, nil
 }
 
 // GetByType 根据类型获取充电桩
 func (r *ChargingPileRepository) GetByType(pileType model.PileType) ([]*model.ChargingPile, error) {
 	query := `
-		SELECT id, pile_type, power, status, queue_length, 
-		       total_sessions, total_duration, total_energy, created_at, updated_at
+		SELECT ` + chargingPileColumns + `
 		FROM charging_piles
 		WHERE pile_type = $1
 		ORDER BY id
@@ -114,23 +118,11 @@ func (r *ChargingPileRepository) GetByType(pileType model.PileType) ([]*model.Ch
 
 	var piles []*model.ChargingPile
 	for rows.Next() {
-		var pile model.ChargingPile
-		err := rows.Scan(
-			&pile.ID,
-			&pile.PileType,
-			&pile.Power,
-			&pile.Status,
-			&pile.QueueLength,
-			&pile.TotalSessions,
-			&pile.TotalDuration,
-			&pile.TotalEnergy,
-			&pile.CreatedAt,
-			&pile.UpdatedAt,
-		)
+		pile, err := scanChargingPile(rows)
 		if err != nil {
 			return nil, err
 		}
-		piles = append(piles, &pile)
+		piles = append(piles, pile)
 	}
 
 	if err := rows.Err(); err != nil {
@@ -182,8 +174,7 @@ func (r *ChargingPileRepository) UpdateQueueLength(id string, queueLength int) e
 // GetAvailablePiles 获取可用的充电桩
 func (r *ChargingPileRepository) GetAvailablePiles(pileType model.PileType, maxQueueLength int) ([]*model.ChargingPile, error) {
 	query := `
-		SELECT id, pile_type, power, status, queue_length, 
-		       total_sessions, total_duration, total_energy, created_at, updated_at
+		SELECT ` + chargingPileColumns + `
 		FROM charging_piles
 		WHERE pile_type = $1 AND status != 'fault' AND status != 'maintenance' AND status != 'offline' AND queue_length < $2
 		ORDER BY queue_length, id
@@ -197,23 +188,11 @@ func (r *ChargingPileRepository) GetAvailablePiles(pileType model.PileType, maxQ
 
 	var piles []*model.ChargingPile
 	for rows.Next() {
-		var pile model.ChargingPile
-		err := rows.Scan(
-			&pile.ID,
-			&pile.PileType,
-			&pile.Power,
-			&pile.Status,
-			&pile.QueueLength,
-			&pile.TotalSessions,
-			&pile.TotalDuration,
-			&pile.TotalEnergy,
-			&pile.CreatedAt,
-			&pile.UpdatedAt,
-		)
+		pile, err := scanChargingPile(rows)
 		if err != nil {
 			return nil, err
 		}
-		piles = append(piles, &pile)
+		piles = append(piles, pile)
 	}
 
 	if err := rows.Err(); err != nil {
@@ -226,8 +205,7 @@ func (r *ChargingPileRepository) GetAvailablePiles(pileType model.PileType, maxQ
 // GetNormalPiles 获取正常状态的充电桩
 func (r *ChargingPileRepository) GetNormalPiles(pileType model.PileType) ([]*model.ChargingPile, error) {
 	query := `
-		SELECT id, pile_type, power, status, queue_length, 
-		       total_sessions, total_duration, total_energy, created_at, updated_at
+		SELECT ` + chargingPileColumns + `
 		FROM charging_piles
 		WHERE pile_type = $1 AND status != 'fault' AND status != 'maintenance' AND status != 'offline'
 		ORDER BY queue_length, id
@@ -241,23 +219,11 @@ func (r *ChargingPileRepository) GetNormalPiles(pileType model.PileType) ([]*mod
 
 	var piles []*model.ChargingPile
 	for rows.Next() {
-		var pile model.ChargingPile
-		err := rows.Scan(
-			&pile.ID,
-			&pile.PileType,
-			&pile.Power,
-			&pile.Status,
-			&pile.QueueLength,
-			&pile.TotalSessions,
-			&pile.TotalDuration,
-			&pile.TotalEnergy,
-			&pile.CreatedAt,
-			&pile.UpdatedAt,
-		)
+		pile, err := scanChargingPile(rows)
 		if err != nil {
 			return nil, err
 		}
-		piles = append(piles, &pile)
+		piles = append(piles, pile)
 	}
 
 	if err := rows.Err(); err != nil {
@@ -290,3 +256,27 @@ func (r *ChargingPileRepository) Create(pile *model.ChargingPile) error {
 	)
 	return err
 }
+
+// UpdateSpec 更新充电桩类型与功率
+func (r *ChargingPileRepository) UpdateSpec(id string, pileType model.PileType, power float64) error {
+	query := `
+		UPDATE charging_piles
+		SET pile_type = $1, power = $2, updated_at = $3
+		WHERE id = $4
+	`
+
+	_, err := r.db.Exec(query, pileType, power, time.Now().UTC(), id)
+	return err
+}
+
+// UpdateDegradedPower 更新充电桩降功率运行时的实际功率，为nil时恢复额定功率
+func (r *ChargingPileRepository) UpdateDegradedPower(id string, degradedPower *float64) error {
+	query := `
+		UPDATE charging_piles
+		SET degraded_power = $1, updated_at = $2
+		WHERE id = $3
+	`
+
+	_, err := r.db.Exec(query, degradedPower, time.Now().UTC(), id)
+	return err
+}
@@ -3,6 +3,7 @@ package repository
 import (
 	"database/sql"
 	"errors"
+	"fmt"
 	"time"
 
 	"backend/internal/model"
@@ -22,106 +23,206 @@ func NewChargingRequestRepository(db *sql.DB) *ChargingRequestRepository {
 	}
 }
 
+// requestColumns 充电请求查询字段
+const requestColumns = `id, user_id, vehicle_id, charging_mode, requested_capacity, queue_number, queue_date, status,
+		       pile_id, queue_position, estimated_wait_time, start_soc, target_soc, priority, arrival_deadline, created_at, updated_at`
+
+// scanRequest 扫描充电请求记录，处理可能为NULL的字段
+func scanRequest(row interface{ Scan(...any) error }) (*model.ChargingRequest, error) {
+	var request model.ChargingRequest
+	var vehicleID uuid.NullUUID
+	var pileID sql.NullString
+	var queuePosition sql.NullInt64
+	var estimatedWaitTime sql.NullInt64
+	var startSoC, targetSoC sql.NullFloat64
+	var queueDate, arrivalDeadline sql.NullTime
+
+	err := row.Scan(
+		&request.ID,
+		&request.UserID,
+		&vehicleID,
+		&request.ChargingMode,
+		&request.RequestedCapacity,
+		&request.QueueNumber,
+		&queueDate,
+		&request.Status,
+		&pileID,
+		&queuePosition,
+		&estimatedWaitTime,
+		&startSoC,
+		&targetSoC,
+		&request.Priority,
+		&arrivalDeadline,
+		&request.CreatedAt,
+		&request.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if vehicleID.Valid {
+		request.VehicleID = vehicleID.UUID
+	}
+	if queueDate.Valid {
+		request.QueueDate = queueDate.Time
+	}
+	if pileID.Valid {
+		request.PileID = pileID.String
+	}
+	if queuePosition.Valid {
+		request.QueuePosition = int(queuePosition.Int64)
+	}
+	if estimatedWaitTime.Valid {
+		request.EstimatedWaitTime = int(estimatedWaitTime.Int64)
+	}
+	request.StartSoC = nullFloatPtr(startSoC)
+	request.TargetSoC = nullFloatPtr(targetSoC)
+	if arrivalDeadline.Valid {
+		request.ArrivalDeadline = &arrivalDeadline.Time
+	}
+
+	return &request, nil
+}
+
+// nullFloatPtr 将可能为NULL的浮点数转换为指针
+func nullFloatPtr(v sql.NullFloat64) *float64 {
+	if !v.Valid {
+		return nil
+	}
+	return &v.Float64
+}
+
+// floatPtrParam 将浮点数指针转换为查询参数，nil写入NULL
+func floatPtrParam(v *float64) any {
+	if v == nil {
+		return nil
+	}
+	return *v
+}
+
+// queueDateParam 将队列号营业日转换为数据库参数，未取号时为NULL
+func queueDateParam(v time.Time) any {
+	if v.IsZero() {
+		return nil
+	}
+	return v.Format("2006-01-02")
+}
+
+// GetMissingQueueDates 获取已取号但缺少营业日的请求（升级前的历史请求），返回请求ID到创建时间的映射
+func (r *ChargingRequestRepository) GetMissingQueueDates() (map[uuid.UUID]time.Time, error) {
+	rows, err := r.db.Query(`
+		SELECT id, created_at FROM charging_requests
+		WHERE queue_date IS NULL AND queue_number IS NOT NULL AND queue_number <> ''
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	createdAt := make(map[uuid.UUID]time.Time)
+	for rows.Next() {
+		var id uuid.UUID
+		var at time.Time
+		if err := rows.Scan(&id, &at); err != nil {
+			return nil, err
+		}
+		createdAt[id] = at
+	}
+	return createdAt, rows.Err()
+}
+
+// SetQueueDates 批量写入请求的队列号营业日
+func (r *ChargingRequestRepository) SetQueueDates(dates map[uuid.UUID]time.Time) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("开始事务失败: %w", err)
+	}
+	defer tx.Rollback()
+
+	for id, date := range dates {
+		if _, err := tx.Exec(`UPDATE charging_requests SET queue_date = $1 WHERE id = $2 AND queue_date IS NULL`,
+			queueDateParam(date), id); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// queryRequests 查询充电请求列表
+func (r *ChargingRequestRepository) queryRequests(query string, args ...any) ([]*model.ChargingRequest, error) {
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var requests []*model.ChargingRequest
+	for rows.Next() {
+		request, err := scanRequest(rows)
+		if err != nil {
+			return nil, err
+		}
+		requests = append(requests, request)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return requests, nil
+}
+
 // Create 创建充电请求
 func (r *ChargingRequestRepository) Create(request *model.ChargingRequest) (*model.ChargingRequest, error) {
-	// 检查用户是否已有活跃充电请求
+	// 检查车辆是否已有活跃充电请求
 	var count int
 	err := r.db.QueryRow(`
 		SELECT COUNT(*) 
 		FROM charging_requests 
-		WHERE user_id = $1 AND status IN ('waiting', 'queued', 'charging')
-	`, request.UserID).Scan(&count)
+		WHERE vehicle_id = $1 AND status IN ('waitlisted', 'waiting', 'queued', 'charging')
+	`, request.VehicleID).Scan(&count)
 
 	if err != nil {
 		return nil, err
 	}
 
 	if count > 0 {
-		return nil, errors.New("用户已有活跃的充电请求")
+		return nil, errors.New("该车辆已有活跃的充电请求")
 	}
 	// 插入新的充电请求
 	query := `
 		INSERT INTO charging_requests 
-		(id, user_id, charging_mode, requested_capacity, queue_number, status, created_at, updated_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
-		RETURNING id, user_id, charging_mode, requested_capacity, queue_number, status, 
-		          pile_id, queue_position, estimated_wait_time, created_at, updated_at
-	`
+		(id, user_id, vehicle_id, charging_mode, requested_capacity, queue_number, queue_date, status,
+		 start_soc, target_soc, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+		RETURNING ` + requestColumns
 	now := time.Now().UTC()
-	var newRequest model.ChargingRequest
-	var pileID sql.NullString
-	var queuePosition sql.NullInt64
-	var estimatedWaitTime sql.NullInt64
-	err = r.db.QueryRow(
+	return scanRequest(r.db.QueryRow(
 		query,
 		request.ID,
 		request.UserID,
+		request.VehicleID,
 		request.ChargingMode,
 		request.RequestedCapacity,
 		request.QueueNumber,
+		queueDateParam(request.QueueDate),
 		request.Status,
+		floatPtrParam(request.StartSoC),
+		floatPtrParam(request.TargetSoC),
 		now,
 		now,
-	).Scan(
-		&newRequest.ID,
-		&newRequest.UserID,
-		&newRequest.ChargingMode,
-		&newRequest.RequestedCapacity,
-		&newRequest.QueueNumber,
-		&newRequest.Status,
-		&pileID,
-		&queuePosition,
-		&estimatedWaitTime,
-		&newRequest.CreatedAt,
-		&newRequest.UpdatedAt,
-	)
-
-	// 处理可能为NULL的字段
-	if pileID.Valid {
-		newRequest.PileID = pileID.String
-	}
-	if queuePosition.Valid {
-		newRequest.QueuePosition = int(queuePosition.Int64)
-	}
-	if estimatedWaitTime.Valid {
-		newRequest.EstimatedWaitTime = int(estimatedWaitTime.Int64)
-	}
-
-	if err != nil {
-		return nil, err
-	}
-
-	return &newRequest, nil
+	))
 }
 
 // GetByID 通过ID获取充电请求
 func (r *ChargingRequestRepository) GetByID(id uuid.UUID) (*model.ChargingRequest, error) {
 	query := `
-		SELECT id, user_id, charging_mode, requested_capacity, queue_number, status, 
-		       pile_id, queue_position, estimated_wait_time, created_at, updated_at
+		SELECT ` + requestColumns + `
 		FROM charging_requests
 		WHERE id = $1
 	`
 
-	var request model.ChargingRequest
-	var pileID sql.NullString
-	var queuePosition sql.NullInt64
-	var estimatedWaitTime sql.NullInt64
-
-	err := r.db.QueryRow(query, id).Scan(
-		&request.ID,
-		&request.UserID,
-		&request.ChargingMode,
-		&request.RequestedCapacity,
-		&request.QueueNumber,
-		&request.Status,
-		&pileID,
-		&queuePosition,
-		&estimatedWaitTime,
-		&request.CreatedAt,
-		&request.UpdatedAt,
-	)
-
+	request, err := scanRequest(r.db.QueryRow(query, id))
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, errors.New("充电请求不存在")
@@ -129,49 +230,20 @@ func (r *ChargingRequestRepository) GetByID(id uuid.UUID) (*model.ChargingReques
 		return nil, err
 	}
 
-	if pileID.Valid {
-		request.PileID = pileID.String
-	}
-	if queuePosition.Valid {
-		request.QueuePosition = int(queuePosition.Int64)
-	}
-	if estimatedWaitTime.Valid {
-		request.EstimatedWaitTime = int(estimatedWaitTime.Int64)
-	}
-
-	return &request, nil
+	return request, nil
 }
 
-// GetActiveRequestByUserID 通过用户ID获取活跃请求
+// GetActiveRequestByUserID 通过用户ID获取活跃请求（多辆车时返回最新的一条）
 func (r *ChargingRequestRepository) GetActiveRequestByUserID(userID uuid.UUID) (*model.ChargingRequest, error) {
 	query := `
-		SELECT id, user_id, charging_mode, requested_capacity, queue_number, status, 
-		       pile_id, queue_position, estimated_wait_time, created_at, updated_at
+		SELECT ` + requestColumns + `
 		FROM charging_requests
-		WHERE user_id = $1 AND status IN ('waiting', 'queued', 'charging')
+		WHERE user_id = $1 AND status IN ('waitlisted', 'waiting', 'queued', 'charging')
 		ORDER BY created_at DESC
 		LIMIT 1
 	`
 
-	var request model.ChargingRequest
-	var pileID sql.NullString
-	var queuePosition sql.NullInt64
-	var estimatedWaitTime sql.NullInt64
-
-	err := r.db.QueryRow(query, userID).Scan(
-		&request.ID,
-		&request.UserID,
-		&request.ChargingMode,
-		&request.RequestedCapacity,
-		&request.QueueNumber,
-		&request.Status,
-		&pileID,
-		&queuePosition,
-		&estimatedWaitTime,
-		&request.CreatedAt,
-		&request.UpdatedAt,
-	)
-
+	request, err := scanRequest(r.db.QueryRow(query, userID))
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, errors.New("用户没有活跃的充电请求")
@@ -179,49 +251,53 @@ func (r *ChargingRequestRepository) GetActiveRequestByUserID(userID uuid.UUID) (
 		return nil, err
 	}
 
-	if pileID.Valid {
-		request.PileID = pileID.String
-	}
-	if queuePosition.Valid {
-		request.QueuePosition = int(queuePosition.Int64)
-	}
-	if estimatedWaitTime.Valid {
-		request.EstimatedWaitTime = int(estimatedWaitTime.Int64)
+	return request, nil
+}
+
+// GetActiveRequestsByUserID 获取用户所有车辆的活跃请求
+func (r *ChargingRequestRepository) GetActiveRequestsByUserID(userID uuid.UUID) ([]*model.ChargingRequest, error) {
+	query := `
+		SELECT ` + requestColumns + `
+		FROM charging_requests
+		WHERE user_id = $1 AND status IN ('waitlisted', 'waiting', 'queued', 'charging')
+		ORDER BY created_at DESC
+	`
+
+	return r.queryRequests(query, userID)
+}
+
+// GetActiveRequestByVehicleID 通过车辆ID获取活跃请求
+func (r *ChargingRequestRepository) GetActiveRequestByVehicleID(vehicleID uuid.UUID) (*model.ChargingRequest, error) {
+	query := `
+		SELECT ` + requestColumns + `
+		FROM charging_requests
+		WHERE vehicle_id = $1 AND status IN ('waitlisted', 'waiting', 'queued', 'charging')
+		ORDER BY created_at DESC
+		LIMIT 1
+	`
+
+	request, err := scanRequest(r.db.QueryRow(query, vehicleID))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errors.New("车辆没有活跃的充电请求")
+		}
+		return nil, err
 	}
 
-	return &request, nil
+	return request, nil
 }
 
 // GetLatestRequestByUserID 通过用户ID获取最新请求
 func (r *ChargingRequestRepository) GetLatestRequestByUserID(userID uuid.UUID) (*model.ChargingRequest, error) {
 	query := `
-		SELECT id, user_id, charging_mode, requested_capacity, queue_number, status, 
-		       pile_id, queue_position, estimated_wait_time, created_at, updated_at
+		SELECT ` + requestColumns + `
 		FROM charging_requests
 		WHERE user_id = $1
 		ORDER BY created_at DESC
 		LIMIT 1
 	`
 
-	var request model.ChargingRequest
-	var pileID sql.NullString
-	var queuePosition sql.NullInt64
-	var estimatedWaitTime sql.NullInt64
-
-	err := r.db.QueryRow(query, userID).Scan(
-		&request.ID,
-		&request.UserID,
-		&request.ChargingMode,
-		&request.RequestedCapacity,
-		&request.QueueNumber,
-		&request.Status,
-		&pileID,
-		&queuePosition,
-		&estimatedWaitTime,
-		&request.CreatedAt,
-		&request.UpdatedAt,
-	)
-
+	request, err := scanRequest(r.db.QueryRow(query, userID))
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, errors.New("用户没有充电请求记录")
@@ -229,17 +305,7 @@ func (r *ChargingRequestRepository) GetLatestRequestByUserID(userID uuid.UUID) (
 		return nil, err
 	}
 
-	if pileID.Valid {
-		request.PileID = pileID.String
-	}
-	if queuePosition.Valid {
-		request.QueuePosition = int(queuePosition.Int64)
-	}
-	if estimatedWaitTime.Valid {
-		request.EstimatedWaitTime = int(estimatedWaitTime.Int64)
-	}
-
-	return &request, nil
+	return request, nil
 }
 
 // UpdateRequestStatus 更新请求状态
@@ -278,10 +344,10 @@ func (r *ChargingRequestRepository) AssignToPile(id uuid.UUID, pileID string, qu
 func (r *ChargingRequestRepository) UpdateRequest(request *model.ChargingRequest) error {
 	query := `
 		UPDATE charging_requests
-		SET charging_mode = $1, requested_capacity = $2, queue_number = $3, 
-		    pile_id = $4, queue_position = $5, status = $6, estimated_wait_time = $7, 
-		    updated_at = $8
-		WHERE id = $10
+		SET charging_mode = $1, requested_capacity = $2, queue_number = $3, queue_date = $4,
+		    pile_id = $5, queue_position = $6, status = $7, estimated_wait_time = $8, 
+		    start_soc = $9, target_soc = $10, updated_at = $11
+		WHERE id = $12
 	`
 
 	var pileID any = nil
@@ -294,10 +360,13 @@ func (r *ChargingRequestRepository) UpdateRequest(request *model.ChargingRequest
 		request.ChargingMode,
 		request.RequestedCapacity,
 		request.QueueNumber,
+		queueDateParam(request.QueueDate),
 		pileID,
 		request.QueuePosition,
 		request.Status,
 		request.EstimatedWaitTime,
+		floatPtrParam(request.StartSoC),
+		floatPtrParam(request.TargetSoC),
 		time.Now().UTC(),
 		request.ID,
 	)
@@ -308,62 +377,54 @@ func (r *ChargingRequestRepository) UpdateRequest(request *model.ChargingRequest
 // GetWaitingRequestsByMode 获取特定模式的等待请求
 func (r *ChargingRequestRepository) GetWaitingRequestsByMode(mode model.ChargingMode) ([]*model.ChargingRequest, error) {
 	query := `
-		SELECT id, user_id, charging_mode, requested_capacity, queue_number, status, 
-		       pile_id, queue_position, estimated_wait_time, created_at, updated_at
+		SELECT ` + requestColumns + `
 		FROM charging_requests
 		WHERE charging_mode = $1 AND status = 'waiting'
-		ORDER BY created_at ASC
+		ORDER BY priority DESC, created_at ASC
 	`
 
-	rows, err := r.db.Query(query, mode)
+	return r.queryRequests(query, mode)
+}
+
+// SetWaitingPriorities 按给定顺序设置等候区请求的优先级
+// 列表中的请求依次获得递减的优先级，该模式下其余等待请求的优先级清零
+func (r *ChargingRequestRepository) SetWaitingPriorities(mode model.ChargingMode, orderedIDs []uuid.UUID) error {
+	tx, err := r.db.Begin()
 	if err != nil {
-		return nil, err
+		return fmt.Errorf("开始事务失败: %w", err)
 	}
-	defer rows.Close()
-
-	var requests []*model.ChargingRequest
-	for rows.Next() {
-		var request model.ChargingRequest
-		var pileID sql.NullString
-		var queuePosition sql.NullInt64
-		var estimatedWaitTime sql.NullInt64
-
-		err := rows.Scan(
-			&request.ID,
-			&request.UserID,
-			&request.ChargingMode,
-			&request.RequestedCapacity,
-			&request.QueueNumber,
-			&request.Status,
-			&pileID,
-			&queuePosition,
-			&estimatedWaitTime,
-			&request.CreatedAt,
-			&request.UpdatedAt,
-		)
-
-		if err != nil {
-			return nil, err
-		}
+	defer tx.Rollback()
 
-		if pileID.Valid {
-			request.PileID = pileID.String
-		}
-		if queuePosition.Valid {
-			request.QueuePosition = int(queuePosition.Int64)
-		}
-		if estimatedWaitTime.Valid {
-			request.EstimatedWaitTime = int(estimatedWaitTime.Int64)
+	now := time.Now().UTC()
+	if _, err := tx.Exec(`
+		UPDATE charging_requests
+		SET priority = 0, updated_at = $1
+		WHERE charging_mode = $2 AND status = 'waiting' AND priority <> 0
+	`, now, mode); err != nil {
+		return fmt.Errorf("清除等候区优先级失败: %w", err)
+	}
+
+	for i, id := range orderedIDs {
+		if _, err := tx.Exec(`
+			UPDATE charging_requests
+			SET priority = $1, updated_at = $2
+			WHERE id = $3 AND charging_mode = $4 AND status = 'waiting'
+		`, len(orderedIDs)-i, now, id, mode); err != nil {
+			return fmt.Errorf("设置请求 %s 优先级失败: %w", id, err)
 		}
-
-		requests = append(requests, &request)
 	}
 
-	if err := rows.Err(); err != nil {
-		return nil, err
-	}
+	return tx.Commit()
+}
 
-	return requests, nil
+// UpdatePriority 设置请求的优先级
+func (r *ChargingRequestRepository) UpdatePriority(id uuid.UUID, priority int) error {
+	_, err := r.db.Exec(`
+		UPDATE charging_requests
+		SET priority = $1, updated_at = $2
+		WHERE id = $3
+	`, priority, time.Now().UTC(), id)
+	return err
 }
 
 // CountWaitingRequests 计算等待请求的数量
@@ -373,126 +434,102 @@ func (r *ChargingRequestRepository) CountWaitingRequests() (int, error) {
 	return count, err
 }
 
-// GetQueuedRequestsByPile 获取指定充电桩的排队请求（按优先级和时间排序）
-func (r *ChargingRequestRepository) GetQueuedRequestsByPile(pileID string) ([]*model.ChargingRequest, error) {
+// CountWaitlist 统计候补队列：尚未获得入场资格的请求数与已获得入场资格、等待到场的请求数
+func (r *ChargingRequestRepository) CountWaitlist() (waitlisted, admitted int, err error) {
+	err = r.db.QueryRow(`
+		SELECT COUNT(*) FILTER (WHERE arrival_deadline IS NULL),
+		       COUNT(*) FILTER (WHERE arrival_deadline IS NOT NULL)
+		FROM charging_requests
+		WHERE status = 'waitlisted'
+	`).Scan(&waitlisted, &admitted)
+	return waitlisted, admitted, err
+}
+
+// GetWaitlistedRequests 获取候补队列中的请求（按加入候补的先后排序）
+func (r *ChargingRequestRepository) GetWaitlistedRequests() ([]*model.ChargingRequest, error) {
 	query := `
-		SELECT id, user_id, charging_mode, requested_capacity, queue_number, status, 
-		       pile_id, queue_position, estimated_wait_time, created_at, updated_at
+		SELECT ` + requestColumns + `
 		FROM charging_requests
-		WHERE pile_id = $1 AND status = 'queued'
-		ORDER BY queue_position ASC, created_at ASC
+		WHERE status = 'waitlisted'
+		ORDER BY created_at ASC, id ASC
 	`
 
-	rows, err := r.db.Query(query, pileID)
+	return r.queryRequests(query)
+}
+
+// AdmitFromWaitlist 候补请求获得入场资格，只对尚未获得资格的候补请求生效
+func (r *ChargingRequestRepository) AdmitFromWaitlist(id uuid.UUID, arrivalDeadline time.Time) (bool, error) {
+	result, err := r.db.Exec(`
+		UPDATE charging_requests
+		SET arrival_deadline = $1, updated_at = $2
+		WHERE id = $3 AND status = 'waitlisted' AND arrival_deadline IS NULL
+	`, arrivalDeadline, time.Now().UTC(), id)
 	if err != nil {
-		return nil, err
+		return false, err
 	}
-	defer rows.Close()
+	rows, err := result.RowsAffected()
+	return rows > 0, err
+}
 
-	var requests []*model.ChargingRequest
-	for rows.Next() {
-		var request model.ChargingRequest
-		var pID sql.NullString
-		var queuePosition sql.NullInt64
-		var estimatedWaitTime sql.NullInt64
-
-		err := rows.Scan(
-			&request.ID,
-			&request.UserID,
-			&request.ChargingMode,
-			&request.RequestedCapacity,
-			&request.QueueNumber,
-			&request.Status,
-			&pID,
-			&queuePosition,
-			&estimatedWaitTime,
-			&request.CreatedAt,
-			&request.UpdatedAt,
-		)
+// CheckIn 已获得入场资格的候补请求在到场截止时间前签到，进入等候区
+func (r *ChargingRequestRepository) CheckIn(id uuid.UUID, queueNumber string, queueDate time.Time, at time.Time) (bool, error) {
+	result, err := r.db.Exec(`
+		UPDATE charging_requests
+		SET status = 'waiting', queue_number = $1, queue_date = $2, updated_at = $3
+		WHERE id = $4 AND status = 'waitlisted' AND arrival_deadline >= $3
+	`, queueNumber, queueDateParam(queueDate), at, id)
+	if err != nil {
+		return false, err
+	}
+	rows, err := result.RowsAffected()
+	return rows > 0, err
+}
 
-		if err != nil {
-			return nil, err
-		}
+// ExpireArrivals 取消超过到场截止时间仍未签到的候补请求，返回被取消的请求
+func (r *ChargingRequestRepository) ExpireArrivals(now time.Time) ([]*model.ChargingRequest, error) {
+	query := `
+		UPDATE charging_requests
+		SET status = 'cancelled', updated_at = $1
+		WHERE status = 'waitlisted' AND arrival_deadline < $1
+		RETURNING ` + requestColumns
 
-		if pID.Valid {
-			request.PileID = pID.String
-		}
-		if queuePosition.Valid {
-			request.QueuePosition = int(queuePosition.Int64)
-		}
-		if estimatedWaitTime.Valid {
-			request.EstimatedWaitTime = int(estimatedWaitTime.Int64)
-		}
+	return r.queryRequests(query, now)
+}
 
-		requests = append(requests, &request)
-	}
+// IsQueueNumberActive 队列号是否仍被未结束的请求占用
+func (r *ChargingRequestRepository) IsQueueNumberActive(queueNumber string) (bool, error) {
+	var exists bool
+	err := r.db.QueryRow(`
+		SELECT EXISTS (
+			SELECT 1 FROM charging_requests
+			WHERE queue_number = $1 AND status IN ('waiting', 'queued', 'charging')
+		)
+	`, queueNumber).Scan(&exists)
+	return exists, err
+}
 
-	if err := rows.Err(); err != nil {
-		return nil, err
-	}
+// GetQueuedRequestsByPile 获取指定充电桩的排队请求（按优先级和时间排序）
+func (r *ChargingRequestRepository) GetQueuedRequestsByPile(pileID string) ([]*model.ChargingRequest, error) {
+	query := `
+		SELECT ` + requestColumns + `
+		FROM charging_requests
+		WHERE pile_id = $1 AND status = 'queued'
+		ORDER BY queue_position ASC, created_at ASC
+	`
 
-	return requests, nil
+	return r.queryRequests(query, pileID)
 }
 
 // GetRequestsByPile 获取特定充电桩的请求
 func (r *ChargingRequestRepository) GetRequestsByPile(pileID string) ([]*model.ChargingRequest, error) {
 	query := `
-		SELECT id, user_id, charging_mode, requested_capacity, queue_number, status, 
-		       pile_id, queue_position, estimated_wait_time, created_at, updated_at
+		SELECT ` + requestColumns + `
 		FROM charging_requests
 		WHERE pile_id = $1 AND status IN ('queued', 'charging')
 		ORDER BY queue_position ASC
 	`
 
-	rows, err := r.db.Query(query, pileID)
-	if err != nil {
-		return nil, err
-	}
-	defer rows.Close()
-
-	var requests []*model.ChargingRequest
-	for rows.Next() {
-		var request model.ChargingRequest
-		var pID sql.NullString
-		var queuePosition sql.NullInt64
-		var estimatedWaitTime sql.NullInt64
-
-		err := rows.Scan(
-			&request.ID,
-			&request.UserID,
-			&request.ChargingMode,
-			&request.RequestedCapacity,
-			&request.QueueNumber,
-			&request.Status,
-			&pID,
-			&queuePosition,
-			&estimatedWaitTime,
-			&request.CreatedAt,
-			&request.UpdatedAt,
-		)
-
-		if err != nil {
-			return nil, err
-		}
-
-		if pID.Valid {
-			request.PileID = pID.String
-		}
-		if queuePosition.Valid {
-			request.QueuePosition = int(queuePosition.Int64)
-		}
-		if estimatedWaitTime.Valid {
-			request.EstimatedWaitTime = int(estimatedWaitTime.Int64)
-		}
-
-		requests = append(requests, &request)
-	}
-
-	if err := rows.Err(); err != nil {
-		return nil, err
-	}
-
-	return requests, nil
+	return r.queryRequests(query, pileID)
 }
 
 // GetUserRequests 获取用户的充电请求历史
@@ -506,61 +543,17 @@ func (r *ChargingRequestRepository) GetUserRequests(userID uuid.UUID, page, page
 	// 分页查询
 	offset := (page - 1) * pageSize
 	query := `
-		SELECT id, user_id, charging_mode, requested_capacity, queue_number, status, 
-		       pile_id, queue_position, estimated_wait_time, created_at, updated_at
+		SELECT ` + requestColumns + `
 		FROM charging_requests
 		WHERE user_id = $1
 		ORDER BY created_at DESC
 		LIMIT $2 OFFSET $3
 	`
 
-	rows, err := r.db.Query(query, userID, pageSize, offset)
+	requests, err := r.queryRequests(query, userID, pageSize, offset)
 	if err != nil {
 		return nil, 0, err
 	}
-	defer rows.Close()
-
-	var requests []*model.ChargingRequest
-	for rows.Next() {
-		var request model.ChargingRequest
-		var pileID sql.NullString
-		var queuePosition sql.NullInt64
-		var estimatedWaitTime sql.NullInt64
-
-		err := rows.Scan(
-			&request.ID,
-			&request.UserID,
-			&request.ChargingMode,
-			&request.RequestedCapacity,
-			&request.QueueNumber,
-			&request.Status,
-			&pileID,
-			&queuePosition,
-			&estimatedWaitTime,
-			&request.CreatedAt,
-			&request.UpdatedAt,
-		)
-
-		if err != nil {
-			return nil, 0, err
-		}
-
-		if pileID.Valid {
-			request.PileID = pileID.String
-		}
-		if queuePosition.Valid {
-			request.QueuePosition = int(queuePosition.Int64)
-		}
-		if estimatedWaitTime.Valid {
-			request.EstimatedWaitTime = int(estimatedWaitTime.Int64)
-		}
-
-		requests = append(requests, &request)
-	}
-
-	if err := rows.Err(); err != nil {
-		return nil, 0, err
-	}
 
 	return requests, total, nil
 }
@@ -22,19 +22,86 @@ func NewChargingSessionRepository(db *sql.DB) *ChargingSessionRepository {
 	}
 }
 
+// ErrSessionNotFound 充电会话不存在
+var ErrSessionNotFound = errors.New("充电会话不存在")
+
+// ErrNoActiveSession 充电桩没有活跃的充电会话
+var ErrNoActiveSession = errors.New("没有活跃的充电会话")
+
+// chargingSessionColumns 充电会话查询字段
+const chargingSessionColumns = `id, request_id, user_id, pile_id, queue_number, requested_capacity,
+		       actual_capacity, start_time, end_time, departed_at, status, duration, start_soc, target_soc, current_soc,
+		       current_power, remaining_time, ocpp_transaction_id, ocpp_meter_start, created_at`
+
+// scanChargingSession 扫描充电会话记录，处理可能为NULL的字段
+func scanChargingSession(row interface{ Scan(...any) error }) (*model.ChargingSession, error) {
+	var session model.ChargingSession
+	var endTime, departedAt sql.NullTime
+	var startSoC, targetSoC, currentSoC, currentPower sql.NullFloat64
+	var remainingTime, transactionID, meterStart sql.NullInt64
+
+	err := row.Scan(
+		&session.ID,
+		&session.RequestID,
+		&session.UserID,
+		&session.PileID,
+		&session.QueueNumber,
+		&session.RequestedCapacity,
+		&session.ActualCapacity,
+		&session.StartTime,
+		&endTime,
+		&departedAt,
+		&session.Status,
+		&session.Duration,
+		&startSoC,
+		&targetSoC,
+		&currentSoC,
+		&currentPower,
+		&remainingTime,
+		&transactionID,
+		&meterStart,
+		&session.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if endTime.Valid {
+		session.EndTime = &endTime.Time
+	}
+	if departedAt.Valid {
+		session.DepartedAt = &departedAt.Time
+	}
+	session.StartSoC = nullFloatPtr(startSoC)
+	session.TargetSoC = nullFloatPtr(targetSoC)
+	session.CurrentSoC = nullFloatPtr(currentSoC)
+	session.CurrentPower = nullFloatPtr(currentPower)
+	session.RemainingTime = nullIntPtr(remainingTime)
+	session.OCPPTransactionID = nullIntPtr(transactionID)
+	session.OCPPMeterStart = nullIntPtr(meterStart)
+
+	return &session, nil
+}
+
+// nullIntPtr 将可能为NULL的整数转换为指针
+func nullIntPtr(v sql.NullInt64) *int {
+	if !v.Valid {
+		return nil
+	}
+	i := int(v.Int64)
+	return &i
+}
+
 // Create 创建充电会话
 func (r *ChargingSessionRepository) Create(session *model.ChargingSession) (*model.ChargingSession, error) {
 	query := `
 		INSERT INTO charging_sessions 
 		(id, request_id, user_id, pile_id, queue_number, requested_capacity, actual_capacity,
-		 start_time, status, duration, created_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
-		RETURNING id, request_id, user_id, pile_id, queue_number, requested_capacity, 
-				  actual_capacity, start_time, duration, status, created_at
-	`
+		 start_time, status, duration, start_soc, target_soc, current_soc, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14)
+		RETURNING ` + chargingSessionColumns
 	now := time.Now().UTC()
-	var newSession model.ChargingSession
-	err := r.db.QueryRow(
+	return scanChargingSession(r.db.QueryRow(
 		query,
 		session.ID,
 		session.RequestID,
@@ -46,98 +113,43 @@ func (r *ChargingSessionRepository) Create(session *model.ChargingSession) (*mod
 		session.StartTime,
 		session.Status,
 		session.Duration,
+		floatPtrParam(session.StartSoC),
+		floatPtrParam(session.TargetSoC),
+		floatPtrParam(session.CurrentSoC),
 		now,
-	).Scan(
-		&newSession.ID,
-		&newSession.RequestID,
-		&newSession.UserID,
-		&newSession.PileID,
-		&newSession.QueueNumber,
-		&newSession.RequestedCapacity,
-		&newSession.ActualCapacity,
-		&newSession.StartTime,
-		&newSession.Duration,
-		&newSession.Status,
-		&newSession.CreatedAt,
-	)
-
-	if err != nil {
-		return nil, err
-	}
-
-	return &newSession, nil
+	))
 }
 
 // GetByID 通过ID获取充电会话
 func (r *ChargingSessionRepository) GetByID(id uuid.UUID) (*model.ChargingSession, error) {
 	query := `
-		SELECT id, request_id, user_id, pile_id, queue_number, requested_capacity, 
-			   actual_capacity, start_time, end_time, status, duration, created_at
+		SELECT ` + chargingSessionColumns + `
 		FROM charging_sessions
 		WHERE id = $1
 	`
 
-	var session model.ChargingSession
-	var endTime sql.NullTime
-
-	err := r.db.QueryRow(query, id).Scan(
-		&session.ID,
-		&session.RequestID,
-		&session.UserID,
-		&session.PileID,
-		&session.QueueNumber,
-		&session.RequestedCapacity,
-		&session.ActualCapacity,
-		&session.StartTime,
-		&endTime,
-		&session.Status,
-		&session.Duration,
-		&session.CreatedAt,
-	)
-
+	session, err := scanChargingSession(r.db.QueryRow(query, id))
 	if err != nil {
 		if err == sql.ErrNoRows {
-			return nil, errors.New("充电会话不存在")
+			return nil, ErrSessionNotFound
 		}
 		return nil, err
 	}
 
-	if endTime.Valid {
-		session.EndTime = &endTime.Time
-	}
-
-	return &session, nil
+	return session, nil
 }
 
 // GetByRequestID 通过请求ID获取充电会话
 func (r *ChargingSessionRepository) GetByRequestID(requestID uuid.UUID) (*model.ChargingSession, error) {
 	query := `
-		SELECT id, request_id, user_id, pile_id, queue_number, requested_capacity, 
-			   actual_capacity, start_time, end_time, status, duration, created_at
+		SELECT ` + chargingSessionColumns + `
 		FROM charging_sessions
 		WHERE request_id = $1
 		ORDER BY created_at DESC
 		LIMIT 1
 	`
 
-	var session model.ChargingSession
-	var endTime sql.NullTime
-
-	err := r.db.QueryRow(query, requestID).Scan(
-		&session.ID,
-		&session.RequestID,
-		&session.UserID,
-		&session.PileID,
-		&session.QueueNumber,
-		&session.RequestedCapacity,
-		&session.ActualCapacity,
-		&session.StartTime,
-		&endTime,
-		&session.Status,
-		&session.Duration,
-		&session.CreatedAt,
-	)
-
+	session, err := scanChargingSession(r.db.QueryRow(query, requestID))
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, errors.New("充电会话不存在")
@@ -145,68 +157,47 @@ func (r *ChargingSessionRepository) GetByRequestID(requestID uuid.UUID) (*model.
 		return nil, err
 	}
 
-	if endTime.Valid {
-		session.EndTime = &endTime.Time
-	}
-
-	return &session, nil
+	return session, nil
 }
 
 // GetActiveSessionByPileID 通过充电桩ID获取活跃会话
 func (r *ChargingSessionRepository) GetActiveSessionByPileID(pileID string) (*model.ChargingSession, error) {
 	query := `
-		SELECT id, request_id, user_id, pile_id, queue_number, requested_capacity, 
-			   actual_capacity, start_time, end_time, status, duration, created_at
+		SELECT ` + chargingSessionColumns + `
 		FROM charging_sessions
 		WHERE pile_id = $1 AND status = 'active'
 		ORDER BY start_time DESC
 		LIMIT 1
 	`
 
-	var session model.ChargingSession
-	var endTime sql.NullTime
-
-	err := r.db.QueryRow(query, pileID).Scan(
-		&session.ID,
-		&session.RequestID,
-		&session.UserID,
-		&session.PileID,
-		&session.QueueNumber,
-		&session.RequestedCapacity,
-		&session.ActualCapacity,
-		&session.StartTime,
-		&endTime,
-		&session.Status,
-		&session.Duration,
-		&session.CreatedAt,
-	)
-
+	session, err := scanChargingSession(r.db.QueryRow(query, pileID))
 	if err != nil {
 		if err == sql.ErrNoRows {
-			return nil, errors.New("没有活跃的充电会话")
+			return nil, ErrNoActiveSession
 		}
 		return nil, err
 	}
 
-	if endTime.Valid {
-		session.EndTime = &endTime.Time
-	}
-
-	return &session, nil
+	return session, nil
 }
 
 // Update 更新充电会话
 func (r *ChargingSessionRepository) Update(session *model.ChargingSession) error {
 	query := `
 		UPDATE charging_sessions
-		SET actual_capacity = $1, end_time = $2, status = $3, duration = $4
-		WHERE id = $5
+		SET actual_capacity = $1, end_time = $2, status = $3, duration = $4, current_soc = $5,
+		    current_power = $6, remaining_time = $7
+		WHERE id = $8
 	`
 
 	var endTime any = nil
 	if session.EndTime != nil {
 		endTime = *session.EndTime
 	}
+	var remainingTimeParam any = nil
+	if session.RemainingTime != nil {
+		remainingTimeParam = *session.RemainingTime
+	}
 
 	_, err := r.db.Exec(
 		query,
@@ -214,6 +205,9 @@ func (r *ChargingSessionRepository) Update(session *model.ChargingSession) error
 		endTime,
 		session.Status,
 		session.Duration,
+		floatPtrParam(session.CurrentSoC),
+		floatPtrParam(session.CurrentPower),
+		remainingTimeParam,
 		session.ID,
 	)
 
@@ -232,8 +226,7 @@ func (r *ChargingSessionRepository) GetUserSessions(userID uuid.UUID, page, page
 	// 分页查询
 	offset := (page - 1) * pageSize
 	query := `
-		SELECT id, request_id, user_id, pile_id, queue_number, requested_capacity, 
-			   actual_capacity, start_time, end_time, status, duration, created_at
+		SELECT ` + chargingSessionColumns + `
 		FROM charging_sessions
 		WHERE user_id = $1
 		ORDER BY created_at DESC
@@ -248,33 +241,11 @@ func (r *ChargingSessionRepository) GetUserSessions(userID uuid.UUID, page, page
 
 	var sessions []*model.ChargingSession
 	for rows.Next() {
-		var session model.ChargingSession
-		var endTime sql.NullTime
-
-		err := rows.Scan(
-			&session.ID,
-			&session.RequestID,
-			&session.UserID,
-			&session.PileID,
-			&session.QueueNumber,
-			&session.RequestedCapacity,
-			&session.ActualCapacity,
-			&session.StartTime,
-			&endTime,
-			&session.Status,
-			&session.Duration,
-			&session.CreatedAt,
-		)
-
+		session, err := scanChargingSession(rows)
 		if err != nil {
 			return nil, 0, err
 		}
-
-		if endTime.Valid {
-			session.EndTime = &endTime.Time
-		}
-
-		sessions = append(sessions, &session)
+		sessions = append(sessions, session)
 	}
 
 	if err := rows.Err(); err != nil {
@@ -320,8 +291,7 @@ func (r *ChargingSessionRepository) GetSessionsInPeriod(startTime, endTime time.
 
 	if pileID != nil {
 		query = `
-			SELECT id, request_id, user_id, pile_id, queue_number, requested_capacity, 
-				   actual_capacity, start_time, end_time, status, duration, created_at
+			SELECT ` + chargingSessionColumns + `
 			FROM charging_sessions
 			WHERE start_time >= $1 AND (end_time <= $2 OR end_time IS NULL)
 				AND pile_id = $3
@@ -330,8 +300,7 @@ func (r *ChargingSessionRepository) GetSessionsInPeriod(startTime, endTime time.
 		args = []any{startTime, endTime, *pileID}
 	} else {
 		query = `
-			SELECT id, request_id, user_id, pile_id, queue_number, requested_capacity, 
-				   actual_capacity, start_time, end_time, status, duration, created_at
+			SELECT ` + chargingSessionColumns + `
 			FROM charging_sessions
 			WHERE start_time >= $1 AND (end_time <= $2 OR end_time IS NULL)
 			ORDER BY start_time ASC
@@ -347,33 +316,11 @@ func (r *ChargingSessionRepository) GetSessionsInPeriod(startTime, endTime time.
 
 	var sessions []*model.ChargingSession
 	for rows.Next() {
-		var session model.ChargingSession
-		var endTime sql.NullTime
-
-		err := rows.Scan(
-			&session.ID,
-			&session.RequestID,
-			&session.UserID,
-			&session.PileID,
-			&session.QueueNumber,
-			&session.RequestedCapacity,
-			&session.ActualCapacity,
-			&session.StartTime,
-			&endTime,
-			&session.Status,
-			&session.Duration,
-			&session.CreatedAt,
-		)
-
+		session, err := scanChargingSession(rows)
 		if err != nil {
 			return nil, err
 		}
-
-		if endTime.Valid {
-			session.EndTime = &endTime.Time
-		}
-
-		sessions = append(sessions, &session)
+		sessions = append(sessions, session)
 	}
 
 	if err := rows.Err(); err != nil {
@@ -386,8 +333,7 @@ func (r *ChargingSessionRepository) GetSessionsInPeriod(startTime, endTime time.
 // GetSessionsByPileID 获取指定充电桩在特定时期内的会话
 func (r *ChargingSessionRepository) GetSessionsByPileID(pileID string, startTime, endTime time.Time) ([]*model.ChargingSession, error) {
 	query := `
-		SELECT id, request_id, user_id, pile_id, queue_number, requested_capacity, 
-			   actual_capacity, start_time, end_time, status, duration, created_at
+		SELECT ` + chargingSessionColumns + `
 		FROM charging_sessions
 		WHERE pile_id = $1 AND start_time >= $2 AND (end_time <= $3 OR end_time IS NULL)
 		ORDER BY start_time ASC
@@ -401,38 +347,92 @@ func (r *ChargingSessionRepository) GetSessionsByPileID(pileID string, startTime
 
 	var sessions []*model.ChargingSession
 	for rows.Next() {
-		var session model.ChargingSession
-		var endTime sql.NullTime
-
-		err := rows.Scan(
-			&session.ID,
-			&session.RequestID,
-			&session.UserID,
-			&session.PileID,
-			&session.QueueNumber,
-			&session.RequestedCapacity,
-			&session.ActualCapacity,
-			&session.StartTime,
-			&endTime,
-			&session.Status,
-			&session.Duration,
-			&session.CreatedAt,
-		)
-
+		session, err := scanChargingSession(rows)
 		if err != nil {
 			return nil, err
 		}
+		sessions = append(sessions, session)
+	}
 
-		if endTime.Valid {
-			session.EndTime = &endTime.Time
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return sessions, nil
+}
+
+// AttachOCPPTransaction 为充电会话分配OCPP交易号并记录起始电表读数
+func (r *ChargingSessionRepository) AttachOCPPTransaction(sessionID uuid.UUID, meterStart int) (int, error) {
+	var transactionID int
+	err := r.db.QueryRow(`
+		UPDATE charging_sessions
+		SET ocpp_transaction_id = COALESCE(ocpp_transaction_id, nextval('ocpp_transaction_id_seq')),
+		    ocpp_meter_start = COALESCE(ocpp_meter_start, $2)
+		WHERE id = $1
+		RETURNING ocpp_transaction_id
+	`, sessionID, meterStart).Scan(&transactionID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return 0, errors.New("充电会话不存在")
 		}
+		return 0, err
+	}
+	return transactionID, nil
+}
+
+// GetByOCPPTransactionID 通过OCPP交易号获取充电会话
+func (r *ChargingSessionRepository) GetByOCPPTransactionID(transactionID int) (*model.ChargingSession, error) {
+	query := `
+		SELECT ` + chargingSessionColumns + `
+		FROM charging_sessions
+		WHERE ocpp_transaction_id = $1
+	`
 
-		sessions = append(sessions, &session)
+	session, err := scanChargingSession(r.db.QueryRow(query, transactionID))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errors.New("OCPP交易不存在")
+		}
+		return nil, err
 	}
 
-	if err := rows.Err(); err != nil {
+	return session, nil
+}
+
+// GetParkedSessionByPileID 获取充电桩上充电已结束但车辆尚未驶离的会话，没有时返回nil
+func (r *ChargingSessionRepository) GetParkedSessionByPileID(pileID string) (*model.ChargingSession, error) {
+	query := `
+		SELECT ` + chargingSessionColumns + `
+		FROM charging_sessions
+		WHERE pile_id = $1 AND end_time IS NOT NULL AND departed_at IS NULL
+		ORDER BY end_time DESC
+		LIMIT 1
+	`
+
+	session, err := scanChargingSession(r.db.QueryRow(query, pileID))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
 		return nil, err
 	}
 
-	return sessions, nil
+	return session, nil
+}
+
+// MarkDeparted 记录车辆驶离时间，已记录过时返回false
+func (r *ChargingSessionRepository) MarkDeparted(id uuid.UUID, departedAt time.Time) (bool, error) {
+	result, err := r.db.Exec(`
+		UPDATE charging_sessions
+		SET departed_at = $2
+		WHERE id = $1 AND departed_at IS NULL
+	`, id, departedAt)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected > 0, nil
 }
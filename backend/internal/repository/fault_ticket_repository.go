@@ -0,0 +1,274 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"backend/internal/model"
+
+	"github.com/google/uuid"
+)
+
+// FaultTicketRepository 故障工单仓库
+type FaultTicketRepository struct {
+	db *sql.DB
+}
+
+// NewFaultTicketRepository 创建故障工单仓库
+func NewFaultTicketRepository(db *sql.DB) *FaultTicketRepository {
+	return &FaultTicketRepository{
+		db: db,
+	}
+}
+
+// faultTicketColumns 故障工单查询字段
+const faultTicketColumns = `id, fault_id, pile_id, status, assignee_id, recovery_notes, ack_due_at, resolve_due_at,
+		       acknowledged_at, repair_started_at, resolved_at, verified_at, created_at, updated_at`
+
+// scanFaultTicket 扫描故障工单记录，处理可能为NULL的字段
+func scanFaultTicket(row interface{ Scan(...any) error }) (*model.FaultTicket, error) {
+	var ticket model.FaultTicket
+	var assigneeID uuid.NullUUID
+	var recoveryNotes sql.NullString
+	var acknowledgedAt, repairStartedAt, resolvedAt, verifiedAt sql.NullTime
+
+	err := row.Scan(
+		&ticket.ID,
+		&ticket.FaultID,
+		&ticket.PileID,
+		&ticket.Status,
+		&assigneeID,
+		&recoveryNotes,
+		&ticket.AckDueAt,
+		&ticket.ResolveDueAt,
+		&acknowledgedAt,
+		&repairStartedAt,
+		&resolvedAt,
+		&verifiedAt,
+		&ticket.CreatedAt,
+		&ticket.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if assigneeID.Valid {
+		ticket.AssigneeID = &assigneeID.UUID
+	}
+	ticket.RecoveryNotes = recoveryNotes.String
+	if acknowledgedAt.Valid {
+		ticket.AcknowledgedAt = &acknowledgedAt.Time
+	}
+	if repairStartedAt.Valid {
+		ticket.RepairStartedAt = &repairStartedAt.Time
+	}
+	if resolvedAt.Valid {
+		ticket.ResolvedAt = &resolvedAt.Time
+	}
+	if verifiedAt.Valid {
+		ticket.VerifiedAt = &verifiedAt.Time
+	}
+	return &ticket, nil
+}
+
+// Create 创建故障工单
+func (r *FaultTicketRepository) Create(ticket *model.FaultTicket) error {
+	query := `
+		INSERT INTO fault_tickets (id, fault_id, pile_id, status, ack_due_at, resolve_due_at, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+	`
+	_, err := r.db.Exec(query,
+		ticket.ID,
+		ticket.FaultID,
+		ticket.PileID,
+		ticket.Status,
+		ticket.AckDueAt,
+		ticket.ResolveDueAt,
+		ticket.CreatedAt,
+		ticket.UpdatedAt,
+	)
+	return err
+}
+
+// Update 更新工单的状态、指派人、恢复说明及各阶段时间
+func (r *FaultTicketRepository) Update(ticket *model.FaultTicket) error {
+	ticket.UpdatedAt = time.Now().UTC()
+	query := `
+		UPDATE fault_tickets
+		SET status = $1, assignee_id = $2, recovery_notes = $3, acknowledged_at = $4,
+		    repair_started_at = $5, resolved_at = $6, verified_at = $7, updated_at = $8
+		WHERE id = $9
+	`
+	_, err := r.db.Exec(query,
+		ticket.Status,
+		ticket.AssigneeID,
+		ticket.RecoveryNotes,
+		ticket.AcknowledgedAt,
+		ticket.RepairStartedAt,
+		ticket.ResolvedAt,
+		ticket.VerifiedAt,
+		ticket.UpdatedAt,
+		ticket.ID,
+	)
+	return err
+}
+
+// GetByID 根据ID获取故障工单
+func (r *FaultTicketRepository) GetByID(id uuid.UUID) (*model.FaultTicket, error) {
+	row := r.db.QueryRow(`SELECT `+faultTicketColumns+` FROM fault_tickets WHERE id = $1`, id)
+	return scanFaultTicket(row)
+}
+
+// GetByFaultID 根据故障记录ID获取故障工单
+func (r *FaultTicketRepository) GetByFaultID(faultID uuid.UUID) (*model.FaultTicket, error) {
+	row := r.db.QueryRow(`SELECT `+faultTicketColumns+` FROM fault_tickets WHERE fault_id = $1`, faultID)
+	return scanFaultTicket(row)
+}
+
+// GetUnrepairedByPile 获取充电桩尚未修复的工单
+func (r *FaultTicketRepository) GetUnrepairedByPile(pileID string) ([]*model.FaultTicket, error) {
+	rows, err := r.db.Query(`
+		SELECT `+faultTicketColumns+`
+		FROM fault_tickets
+		WHERE pile_id = $1 AND status IN ('open', 'acknowledged', 'in_repair')
+		ORDER BY created_at ASC
+	`, pileID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tickets := []*model.FaultTicket{}
+	for rows.Next() {
+		ticket, err := scanFaultTicket(rows)
+		if err != nil {
+			return nil, err
+		}
+		tickets = append(tickets, ticket)
+	}
+	return tickets, rows.Err()
+}
+
+// GetTickets 分页查询故障工单
+func (r *FaultTicketRepository) GetTickets(filter *model.FaultTicketFilter, now time.Time, page, pageSize int) ([]*model.FaultTicket, int, error) {
+	// 构建筛选条件
+	where := "WHERE 1=1"
+	var args []any
+	if filter != nil {
+		if filter.Status != "" {
+			args = append(args, filter.Status)
+			where += fmt.Sprintf(" AND status = $%d", len(args))
+		}
+		if filter.PileID != "" {
+			args = append(args, filter.PileID)
+			where += fmt.Sprintf(" AND pile_id = $%d", len(args))
+		}
+		if filter.AssigneeID != nil {
+			args = append(args, *filter.AssigneeID)
+			where += fmt.Sprintf(" AND assignee_id = $%d", len(args))
+		}
+		if filter.OverdueOnly {
+			args = append(args, now)
+			where += fmt.Sprintf(" AND (COALESCE(acknowledged_at, $%d) > ack_due_at OR COALESCE(resolved_at, $%d) > resolve_due_at)", len(args), len(args))
+		}
+	}
+
+	// 获取总数
+	var total int
+	if err := r.db.QueryRow("SELECT COUNT(*) FROM fault_tickets "+where, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	// 分页查询
+	query := fmt.Sprintf(`
+		SELECT %s
+		FROM fault_tickets
+		%s
+		ORDER BY created_at DESC
+		LIMIT $%d OFFSET $%d
+	`, faultTicketColumns, where, len(args)+1, len(args)+2)
+
+	rows, err := r.db.Query(query, append(args, pageSize, (page-1)*pageSize)...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	tickets := []*model.FaultTicket{}
+	for rows.Next() {
+		ticket, err := scanFaultTicket(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		tickets = append(tickets, ticket)
+	}
+	return tickets, total, rows.Err()
+}
+
+// AddEntry 添加工单记录
+func (r *FaultTicketRepository) AddEntry(entry *model.FaultTicketEntry) error {
+	var partName, quantity any
+	if entry.EntryType == model.FaultTicketEntryPart {
+		partName, quantity = entry.PartName, entry.Quantity
+	}
+
+	query := `
+		INSERT INTO fault_ticket_entries (id, ticket_id, entry_type, author_id, content, part_name, quantity, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+	`
+	_, err := r.db.Exec(query,
+		entry.ID,
+		entry.TicketID,
+		entry.EntryType,
+		entry.AuthorID,
+		entry.Content,
+		partName,
+		quantity,
+		entry.CreatedAt,
+	)
+	return err
+}
+
+// GetEntries 获取工单的全部记录，按时间排序
+func (r *FaultTicketRepository) GetEntries(ticketID uuid.UUID) ([]*model.FaultTicketEntry, error) {
+	rows, err := r.db.Query(`
+		SELECT id, ticket_id, entry_type, author_id, content, part_name, quantity, created_at
+		FROM fault_ticket_entries
+		WHERE ticket_id = $1
+		ORDER BY created_at ASC
+	`, ticketID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := []*model.FaultTicketEntry{}
+	for rows.Next() {
+		var entry model.FaultTicketEntry
+		var authorID uuid.NullUUID
+		var content, partName sql.NullString
+		var quantity sql.NullInt64
+		err := rows.Scan(
+			&entry.ID,
+			&entry.TicketID,
+			&entry.EntryType,
+			&authorID,
+			&content,
+			&partName,
+			&quantity,
+			&entry.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		if authorID.Valid {
+			entry.AuthorID = &authorID.UUID
+		}
+		entry.Content = content.String
+		entry.PartName = partName.String
+		entry.Quantity = int(quantity.Int64)
+		entries = append(entries, &entry)
+	}
+	return entries, rows.Err()
+}
@@ -0,0 +1,155 @@
+package repository
+
+import (
+	"database/sql"
+	"time"
+
+	"backend/internal/model"
+
+	"github.com/google/uuid"
+)
+
+// MaintenanceRepository 计划维护窗口仓库
+type MaintenanceRepository struct {
+	db *sql.DB
+}
+
+// NewMaintenanceRepository 创建计划维护窗口仓库
+func NewMaintenanceRepository(db *sql.DB) *MaintenanceRepository {
+	return &MaintenanceRepository{
+		db: db,
+	}
+}
+
+// maintenanceColumns 维护窗口查询字段
+const maintenanceColumns = `id, pile_id, start_time, end_time, reason, status, created_by, created_at, updated_at`
+
+// Create 创建维护窗口
+func (r *MaintenanceRepository) Create(window *model.MaintenanceWindow) error {
+	query := `
+		INSERT INTO maintenance_windows (` + maintenanceColumns + `)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+	`
+	_, err := r.db.Exec(query,
+		window.ID,
+		window.PileID,
+		window.StartTime,
+		window.EndTime,
+		window.Reason,
+		window.Status,
+		window.CreatedBy,
+		window.CreatedAt,
+		window.UpdatedAt,
+	)
+	return err
+}
+
+// GetByID 根据ID获取维护窗口
+func (r *MaintenanceRepository) GetByID(id uuid.UUID) (*model.MaintenanceWindow, error) {
+	row := r.db.QueryRow(`SELECT `+maintenanceColumns+` FROM maintenance_windows WHERE id = $1`, id)
+	return scanMaintenanceWindow(row)
+}
+
+// UpdateStatus 更新维护窗口状态
+func (r *MaintenanceRepository) UpdateStatus(id uuid.UUID, status model.MaintenanceStatus) error {
+	_, err := r.db.Exec(`
+		UPDATE maintenance_windows
+		SET status = $1, updated_at = $2
+		WHERE id = $3
+	`, status, time.Now().UTC(), id)
+	return err
+}
+
+// GetPending 获取未结束的维护窗口，按开始时间排序
+func (r *MaintenanceRepository) GetPending() ([]*model.MaintenanceWindow, error) {
+	return r.queryWindows(`
+		SELECT ` + maintenanceColumns + `
+		FROM maintenance_windows
+		WHERE status IN ('scheduled', 'draining', 'active')
+		ORDER BY start_time ASC
+	`)
+}
+
+// GetStartingBefore 获取在指定时间之前开始且尚未结束的维护窗口
+func (r *MaintenanceRepository) GetStartingBefore(before time.Time) ([]*model.MaintenanceWindow, error) {
+	return r.queryWindows(`
+		SELECT `+maintenanceColumns+`
+		FROM maintenance_windows
+		WHERE status IN ('scheduled', 'draining', 'active') AND start_time <= $1
+		ORDER BY start_time ASC
+	`, before)
+}
+
+// HasOverlap 检查充电桩在时间段内是否已有未结束的维护窗口
+func (r *MaintenanceRepository) HasOverlap(pileID string, start, end time.Time) (bool, error) {
+	var exists bool
+	err := r.db.QueryRow(`
+		SELECT EXISTS (
+			SELECT 1 FROM maintenance_windows
+			WHERE pile_id = $1 AND status IN ('scheduled', 'draining', 'active')
+			  AND start_time < $3 AND end_time > $2
+		)
+	`, pileID, start, end).Scan(&exists)
+	return exists, err
+}
+
+// GetWindows 查询维护窗口，pileID为空时返回全部充电桩，pendingOnly为true时只返回未结束的
+func (r *MaintenanceRepository) GetWindows(pileID string, pendingOnly bool) ([]*model.MaintenanceWindow, error) {
+	query := `
+		SELECT ` + maintenanceColumns + `
+		FROM maintenance_windows
+		WHERE ($1 = '' OR pile_id = $1)
+	`
+	if pendingOnly {
+		query += ` AND status IN ('scheduled', 'draining', 'active')`
+	}
+	query += ` ORDER BY start_time DESC`
+
+	return r.queryWindows(query, pileID)
+}
+
+// queryWindows 执行查询并扫描维护窗口列表
+func (r *MaintenanceRepository) queryWindows(query string, args ...any) ([]*model.MaintenanceWindow, error) {
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	windows := []*model.MaintenanceWindow{}
+	for rows.Next() {
+		window, err := scanMaintenanceWindow(rows)
+		if err != nil {
+			return nil, err
+		}
+		windows = append(windows, window)
+	}
+
+	return windows, rows.Err()
+}
+
+// scanMaintenanceWindow 扫描一行维护窗口
+func scanMaintenanceWindow(row interface{ Scan(...any) error }) (*model.MaintenanceWindow, error) {
+	var window model.MaintenanceWindow
+	var reason sql.NullString
+	var createdBy uuid.NullUUID
+	err := row.Scan(
+		&window.ID,
+		&window.PileID,
+		&window.StartTime,
+		&window.EndTime,
+		&reason,
+		&window.Status,
+		&createdBy,
+		&window.CreatedAt,
+		&window.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	window.Reason = reason.String
+	if createdBy.Valid {
+		window.CreatedBy = &createdBy.UUID
+	}
+	return &window, nil
+}
@@ -0,0 +1,421 @@
+package repository
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"backend/internal/model"
+
+	"github.com/google/uuid"
+)
+
+// NotificationRepository 通知仓库，包括站内信、渠道偏好与投递记录
+type NotificationRepository struct {
+	db *sql.DB
+}
+
+// NewNotificationRepository 创建通知仓库
+func NewNotificationRepository(db *sql.DB) *NotificationRepository {
+	return &NotificationRepository{
+		db: db,
+	}
+}
+
+// notificationColumns 通知查询字段
+const notificationColumns = `id, user_id, event, title, content, data, in_app, read_at, created_at`
+
+// scanNotification 扫描通知记录，处理可能为NULL的字段
+func scanNotification(row interface{ Scan(...any) error }) (*model.Notification, error) {
+	var notification model.Notification
+	var data []byte
+	var readAt sql.NullTime
+
+	err := row.Scan(
+		&notification.ID,
+		&notification.UserID,
+		&notification.Event,
+		&notification.Title,
+		&notification.Content,
+		&data,
+		&notification.InApp,
+		&readAt,
+		&notification.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if data != nil {
+		if err := json.Unmarshal(data, &notification.Data); err != nil {
+			return nil, err
+		}
+	}
+	if readAt.Valid {
+		notification.ReadAt = &readAt.Time
+	}
+	return &notification, nil
+}
+
+// Create 创建通知及其投递记录
+func (r *NotificationRepository) Create(notification *model.Notification, deliveries []*model.NotificationDelivery) error {
+	var data []byte
+	if notification.Data != nil {
+		var err error
+		if data, err = json.Marshal(notification.Data); err != nil {
+			return err
+		}
+	}
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("开始事务失败: %w", err)
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`
+		INSERT INTO notifications (id, user_id, event, title, content, data, in_app, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+	`,
+		notification.ID,
+		notification.UserID,
+		notification.Event,
+		notification.Title,
+		notification.Content,
+		data,
+		notification.InApp,
+		notification.CreatedAt,
+	)
+	if err != nil {
+		return err
+	}
+
+	for _, delivery := range deliveries {
+		_, err = tx.Exec(`
+			INSERT INTO notification_deliveries (id, notification_id, user_id, channel, target, status, attempts,
+			                                     next_attempt_at, sent_at, created_at, updated_at)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $10)
+		`,
+			delivery.ID,
+			delivery.NotificationID,
+			delivery.UserID,
+			delivery.Channel,
+			nullString(delivery.Target),
+			delivery.Status,
+			delivery.Attempts,
+			delivery.NextAttemptAt,
+			delivery.SentAt,
+			delivery.CreatedAt,
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// GetByID 获取通知
+func (r *NotificationRepository) GetByID(id uuid.UUID) (*model.Notification, error) {
+	row := r.db.QueryRow(`SELECT `+notificationColumns+` FROM notifications WHERE id = $1`, id)
+	return scanNotification(row)
+}
+
+// GetInbox 分页查询用户站内信，unreadOnly 为真时只返回未读通知
+func (r *NotificationRepository) GetInbox(userID uuid.UUID, unreadOnly bool, page, pageSize int) ([]*model.Notification, int, error) {
+	where := "WHERE user_id = $1 AND in_app"
+	if unreadOnly {
+		where += " AND read_at IS NULL"
+	}
+
+	var total int
+	if err := r.db.QueryRow("SELECT COUNT(*) FROM notifications "+where, userID).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	rows, err := r.db.Query(`
+		SELECT `+notificationColumns+`
+		FROM notifications
+		`+where+`
+		ORDER BY created_at DESC
+		LIMIT $2 OFFSET $3
+	`, userID, pageSize, (page-1)*pageSize)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	notifications := []*model.Notification{}
+	for rows.Next() {
+		notification, err := scanNotification(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		notifications = append(notifications, notification)
+	}
+	return notifications, total, rows.Err()
+}
+
+// CountUnread 用户站内信未读数
+func (r *NotificationRepository) CountUnread(userID uuid.UUID) (int, error) {
+	var count int
+	err := r.db.QueryRow(`
+		SELECT COUNT(*) FROM notifications WHERE user_id = $1 AND in_app AND read_at IS NULL
+	`, userID).Scan(&count)
+	return count, err
+}
+
+// MarkRead 将用户的一条站内信标记为已读，通知不存在或不属于该用户时返回false
+func (r *NotificationRepository) MarkRead(userID, id uuid.UUID) (bool, error) {
+	result, err := r.db.Exec(`
+		UPDATE notifications
+		SET read_at = COALESCE(read_at, $3)
+		WHERE id = $1 AND user_id = $2 AND in_app
+	`, id, userID, time.Now().UTC())
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	return affected > 0, err
+}
+
+// MarkAllRead 将用户的全部站内信标记为已读，返回标记的数量
+func (r *NotificationRepository) MarkAllRead(userID uuid.UUID) (int64, error) {
+	result, err := r.db.Exec(`
+		UPDATE notifications
+		SET read_at = $2
+		WHERE user_id = $1 AND in_app AND read_at IS NULL
+	`, userID, time.Now().UTC())
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// GetPreferences 获取用户已保存的渠道偏好
+func (r *NotificationRepository) GetPreferences(userID uuid.UUID) ([]*model.NotificationPreference, error) {
+	rows, err := r.db.Query(`
+		SELECT channel, enabled, target, events, updated_at
+		FROM notification_preferences
+		WHERE user_id = $1
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var preferences []*model.NotificationPreference
+	for rows.Next() {
+		var preference model.NotificationPreference
+		var target sql.NullString
+		var events []byte
+		var updatedAt time.Time
+		if err := rows.Scan(&preference.Channel, &preference.Enabled, &target, &events, &updatedAt); err != nil {
+			return nil, err
+		}
+		preference.Target = target.String
+		preference.UpdatedAt = &updatedAt
+		if events != nil {
+			if err := json.Unmarshal(events, &preference.Events); err != nil {
+				return nil, err
+			}
+		}
+		preferences = append(preferences, &preference)
+	}
+	return preferences, rows.Err()
+}
+
+// SavePreferences 保存用户的渠道偏好，已有的渠道偏好被覆盖
+func (r *NotificationRepository) SavePreferences(userID uuid.UUID, preferences []*model.NotificationPreference) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("开始事务失败: %w", err)
+	}
+	defer tx.Rollback()
+
+	now := time.Now().UTC()
+	for _, preference := range preferences {
+		var events []byte
+		if len(preference.Events) > 0 {
+			if events, err = json.Marshal(preference.Events); err != nil {
+				return err
+			}
+		}
+		_, err = tx.Exec(`
+			INSERT INTO notification_preferences (user_id, channel, enabled, target, events, updated_at)
+			VALUES ($1, $2, $3, $4, $5, $6)
+			ON CONFLICT (user_id, channel) DO UPDATE
+			SET enabled = EXCLUDED.enabled, target = EXCLUDED.target, events = EXCLUDED.events, updated_at = EXCLUDED.updated_at
+		`, userID, preference.Channel, preference.Enabled, nullString(preference.Target), events, now)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// notificationDeliveryColumns 投递记录查询字段
+const notificationDeliveryColumns = `d.id, d.notification_id, d.user_id, n.event, d.channel, d.target, d.status, d.attempts,
+		       d.next_attempt_at, d.last_error, d.sent_at, d.created_at`
+
+// scanNotificationDelivery 扫描投递记录，处理可能为NULL的字段
+func scanNotificationDelivery(row interface{ Scan(...any) error }) (*model.NotificationDelivery, error) {
+	var delivery model.NotificationDelivery
+	var target, lastError sql.NullString
+	var sentAt sql.NullTime
+
+	err := row.Scan(
+		&delivery.ID,
+		&delivery.NotificationID,
+		&delivery.UserID,
+		&delivery.Event,
+		&delivery.Channel,
+		&target,
+		&delivery.Status,
+		&delivery.Attempts,
+		&delivery.NextAttemptAt,
+		&lastError,
+		&sentAt,
+		&delivery.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	delivery.Target = target.String
+	delivery.LastError = lastError.String
+	if sentAt.Valid {
+		delivery.SentAt = &sentAt.Time
+	}
+	return &delivery, nil
+}
+
+// ClaimDueDeliveries 认领到达重试时间的待投递记录，按创建时间排序
+// 认领时把下次投递时间推迟一个租约，并跳过其他实例已锁定的记录，多实例不会重复投递同一记录
+func (r *NotificationRepository) ClaimDueDeliveries(now time.Time, limit int, lease time.Duration) ([]*model.NotificationDelivery, error) {
+	rows, err := r.db.Query(`
+		WITH claimed AS (
+			UPDATE notification_deliveries
+			SET next_attempt_at = $3
+			WHERE id IN (
+				SELECT id FROM notification_deliveries
+				WHERE status = $1 AND next_attempt_at <= $2
+				ORDER BY created_at, id
+				LIMIT $4
+				FOR UPDATE SKIP LOCKED
+			)
+			RETURNING *
+		)
+		SELECT `+notificationDeliveryColumns+`
+		FROM claimed d
+		JOIN notifications n ON n.id = d.notification_id
+		ORDER BY d.created_at, d.id
+	`, model.NotificationDeliveryPending, now, now.Add(lease), limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var deliveries []*model.NotificationDelivery
+	for rows.Next() {
+		delivery, err := scanNotificationDelivery(rows)
+		if err != nil {
+			return nil, err
+		}
+		deliveries = append(deliveries, delivery)
+	}
+	return deliveries, rows.Err()
+}
+
+// GetDeliveries 分页查询投递记录
+func (r *NotificationRepository) GetDeliveries(filter *model.NotificationDeliveryFilter, page, pageSize int) ([]*model.NotificationDelivery, int, error) {
+	// 构建筛选条件
+	where := "WHERE 1=1"
+	var args []any
+	if filter != nil {
+		if filter.Status != "" {
+			args = append(args, filter.Status)
+			where += fmt.Sprintf(" AND d.status = $%d", len(args))
+		}
+		if filter.Channel != "" {
+			args = append(args, filter.Channel)
+			where += fmt.Sprintf(" AND d.channel = $%d", len(args))
+		}
+		if filter.UserID != nil {
+			args = append(args, *filter.UserID)
+			where += fmt.Sprintf(" AND d.user_id = $%d", len(args))
+		}
+	}
+
+	// 获取总数
+	var total int
+	if err := r.db.QueryRow("SELECT COUNT(*) FROM notification_deliveries d "+where, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	// 分页查询
+	query := fmt.Sprintf(`
+		SELECT %s
+		FROM notification_deliveries d
+		JOIN notifications n ON n.id = d.notification_id
+		%s
+		ORDER BY d.created_at DESC
+		LIMIT $%d OFFSET $%d
+	`, notificationDeliveryColumns, where, len(args)+1, len(args)+2)
+
+	rows, err := r.db.Query(query, append(args, pageSize, (page-1)*pageSize)...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	deliveries := []*model.NotificationDelivery{}
+	for rows.Next() {
+		delivery, err := scanNotificationDelivery(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		deliveries = append(deliveries, delivery)
+	}
+	return deliveries, total, rows.Err()
+}
+
+// MarkDeliverySent 标记投递成功
+func (r *NotificationRepository) MarkDeliverySent(id uuid.UUID, attempts int) error {
+	_, err := r.db.Exec(`
+		UPDATE notification_deliveries
+		SET status = $2, attempts = $3, last_error = NULL, sent_at = NOW(), updated_at = NOW()
+		WHERE id = $1
+	`, id, model.NotificationDeliverySent, attempts)
+	return err
+}
+
+// MarkDeliveryRetry 记录投递失败并安排下次重试
+func (r *NotificationRepository) MarkDeliveryRetry(id uuid.UUID, attempts int, nextAttemptAt time.Time, lastError string) error {
+	_, err := r.db.Exec(`
+		UPDATE notification_deliveries
+		SET attempts = $2, next_attempt_at = $3, last_error = $4, updated_at = NOW()
+		WHERE id = $1
+	`, id, attempts, nextAttemptAt, lastError)
+	return err
+}
+
+// MarkDeliveryFailed 重试次数用尽，标记投递失败
+func (r *NotificationRepository) MarkDeliveryFailed(id uuid.UUID, attempts int, lastError string) error {
+	_, err := r.db.Exec(`
+		UPDATE notification_deliveries
+		SET status = $2, attempts = $3, last_error = $4, updated_at = NOW()
+		WHERE id = $1
+	`, id, model.NotificationDeliveryFailed, attempts, lastError)
+	return err
+}
+
+// nullString 将字符串转换为查询参数，空字符串写入NULL
+func nullString(s string) any {
+	if s == "" {
+		return nil
+	}
+	return s
+}
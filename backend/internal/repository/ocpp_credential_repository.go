@@ -0,0 +1,50 @@
+package repository
+
+import (
+	"database/sql"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// OCPPCredentialRepository OCPP充电桩连接凭据仓库
+type OCPPCredentialRepository struct {
+	db *sql.DB
+}
+
+// NewOCPPCredentialRepository 创建OCPP充电桩连接凭据仓库
+func NewOCPPCredentialRepository(db *sql.DB) *OCPPCredentialRepository {
+	return &OCPPCredentialRepository{
+		db: db,
+	}
+}
+
+// SetPassword 设置充电桩的连接密码，只保存哈希
+func (r *OCPPCredentialRepository) SetPassword(pileID, password string) error {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		INSERT INTO ocpp_credentials (pile_id, password_hash, updated_at)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (pile_id) DO UPDATE
+		SET password_hash = EXCLUDED.password_hash, updated_at = EXCLUDED.updated_at
+	`
+	_, err = r.db.Exec(query, pileID, string(hash), time.Now().UTC())
+	return err
+}
+
+// VerifyPassword 校验充电桩的连接密码，未设置密码时校验失败
+func (r *OCPPCredentialRepository) VerifyPassword(pileID, password string) (bool, error) {
+	var hash string
+	err := r.db.QueryRow(`SELECT password_hash FROM ocpp_credentials WHERE pile_id = $1`, pileID).Scan(&hash)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil, nil
+}
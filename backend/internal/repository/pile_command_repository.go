@@ -0,0 +1,202 @@
+package repository
+
+import (
+	"database/sql"
+	"time"
+
+	"backend/internal/model"
+
+	"github.com/google/uuid"
+)
+
+// PileCommandRepository 充电桩指令仓库
+type PileCommandRepository struct {
+	db *sql.DB
+}
+
+// NewPileCommandRepository 创建充电桩指令仓库
+func NewPileCommandRepository(db *sql.DB) *PileCommandRepository {
+	return &PileCommandRepository{
+		db: db,
+	}
+}
+
+// pileCommandColumns 充电桩指令查询字段
+const pileCommandColumns = `id, pile_id, session_id, command_type, payload, status, attempts,
+		       next_attempt_at, last_error, created_at, acknowledged_at, updated_at`
+
+// scanPileCommand 扫描充电桩指令记录，处理可能为NULL的字段
+func scanPileCommand(row interface{ Scan(...any) error }) (*model.PileCommand, error) {
+	var command model.PileCommand
+	var sessionID uuid.NullUUID
+	var lastError sql.NullString
+	var acknowledgedAt sql.NullTime
+	var payload []byte
+
+	err := row.Scan(
+		&command.ID,
+		&command.PileID,
+		&sessionID,
+		&command.CommandType,
+		&payload,
+		&command.Status,
+		&command.Attempts,
+		&command.NextAttemptAt,
+		&lastError,
+		&command.CreatedAt,
+		&acknowledgedAt,
+		&command.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	command.Payload = payload
+	if sessionID.Valid {
+		command.SessionID = &sessionID.UUID
+	}
+	command.LastError = lastError.String
+	if acknowledgedAt.Valid {
+		command.AcknowledgedAt = &acknowledgedAt.Time
+	}
+	return &command, nil
+}
+
+// queryPileCommands 执行查询并扫描指令列表
+func (r *PileCommandRepository) queryPileCommands(query string, args ...any) ([]*model.PileCommand, error) {
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var commands []*model.PileCommand
+	for rows.Next() {
+		command, err := scanPileCommand(rows)
+		if err != nil {
+			return nil, err
+		}
+		commands = append(commands, command)
+	}
+	return commands, rows.Err()
+}
+
+// Create 创建指令
+func (r *PileCommandRepository) Create(command *model.PileCommand) error {
+	query := `
+		INSERT INTO pile_commands (id, pile_id, session_id, command_type, payload, status, attempts,
+		                           next_attempt_at, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $9)
+	`
+
+	_, err := r.db.Exec(query,
+		command.ID,
+		command.PileID,
+		command.SessionID,
+		command.CommandType,
+		[]byte(command.Payload),
+		command.Status,
+		command.Attempts,
+		command.NextAttemptAt,
+		command.CreatedAt,
+	)
+	return err
+}
+
+// ClaimDue 认领到达重试时间的指令，每个充电桩只认领最早的待投递指令，保证分配与停止的顺序
+// 认领时把下次投递时间推迟一个租约，并跳过其他实例已锁定的指令，多实例不会重复下发同一指令；
+// 前一条指令确认或转故障前，同一充电桩的后续指令不会被认领
+func (r *PileCommandRepository) ClaimDue(now time.Time, limit int, lease time.Duration) ([]*model.PileCommand, error) {
+	return r.queryPileCommands(`
+		WITH claimed AS (
+			UPDATE pile_commands
+			SET next_attempt_at = $3, updated_at = NOW()
+			WHERE id IN (
+				SELECT c.id FROM pile_commands c
+				WHERE c.status = $1 AND c.next_attempt_at <= $2
+				  AND NOT EXISTS (
+					SELECT 1 FROM pile_commands e
+					WHERE e.pile_id = c.pile_id AND e.status = $1
+					  AND (e.created_at, e.id) < (c.created_at, c.id)
+				  )
+				ORDER BY c.created_at, c.id
+				LIMIT $4
+				FOR UPDATE SKIP LOCKED
+			)
+			RETURNING *
+		)
+		SELECT `+pileCommandColumns+`
+		FROM claimed
+		ORDER BY created_at, id
+	`, model.PileCommandStatusPending, now, now.Add(lease), limit)
+}
+
+// HasPending 充电桩是否有待投递的指令
+func (r *PileCommandRepository) HasPending(pileID string) (bool, error) {
+	var exists bool
+	err := r.db.QueryRow(`
+		SELECT EXISTS(SELECT 1 FROM pile_commands WHERE pile_id = $1 AND status = $2)
+	`, pileID, model.PileCommandStatusPending).Scan(&exists)
+	return exists, err
+}
+
+// GetStuck 获取卡住的指令：投递失败仍在重试的，以及指定时间之后超时转故障处理的
+func (r *PileCommandRepository) GetStuck(escalatedSince time.Time) ([]*model.PileCommand, error) {
+	return r.queryPileCommands(`
+		SELECT `+pileCommandColumns+`
+		FROM pile_commands
+		WHERE (status = $1 AND attempts > 0) OR (status = $2 AND updated_at >= $3)
+		ORDER BY created_at, id
+	`, model.PileCommandStatusPending, model.PileCommandStatusEscalated, escalatedSince)
+}
+
+// MarkAcknowledged 标记指令已确认
+func (r *PileCommandRepository) MarkAcknowledged(id uuid.UUID, attempts int) error {
+	_, err := r.db.Exec(`
+		UPDATE pile_commands
+		SET status = $2, attempts = $3, last_error = NULL, acknowledged_at = NOW(), updated_at = NOW()
+		WHERE id = $1
+	`, id, model.PileCommandStatusAcknowledged, attempts)
+	return err
+}
+
+// MarkRetry 记录投递失败并安排下次重试
+func (r *PileCommandRepository) MarkRetry(id uuid.UUID, attempts int, nextAttemptAt time.Time, lastError string) error {
+	_, err := r.db.Exec(`
+		UPDATE pile_commands
+		SET attempts = $2, next_attempt_at = $3, last_error = $4, updated_at = NOW()
+		WHERE id = $1
+	`, id, attempts, nextAttemptAt, lastError)
+	return err
+}
+
+// MarkEscalated 标记指令超时未确认，并取消该充电桩其余待投递的指令
+func (r *PileCommandRepository) MarkEscalated(id uuid.UUID, attempts int, lastError string) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var pileID string
+	err = tx.QueryRow(`
+		UPDATE pile_commands
+		SET status = $2, attempts = $3, last_error = $4, updated_at = NOW()
+		WHERE id = $1
+		RETURNING pile_id
+	`, id, model.PileCommandStatusEscalated, attempts, lastError).Scan(&pileID)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(`
+		UPDATE pile_commands
+		SET status = $2, updated_at = NOW()
+		WHERE pile_id = $1 AND status = $3
+	`, pileID, model.PileCommandStatusCancelled, model.PileCommandStatusPending)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,111 @@
+package repository
+
+import (
+	"database/sql"
+	"time"
+
+	"backend/internal/model"
+
+	"github.com/google/uuid"
+)
+
+// PileDegradationRepository 充电桩降功率运行时段仓库
+type PileDegradationRepository struct {
+	db *sql.DB
+}
+
+// NewPileDegradationRepository 创建降功率运行时段仓库
+func NewPileDegradationRepository(db *sql.DB) *PileDegradationRepository {
+	return &PileDegradationRepository{
+		db: db,
+	}
+}
+
+// pileDegradationColumns 降功率运行时段查询字段
+const pileDegradationColumns = `id, pile_id, fault_type, description, rated_power, effective_power, started_at, ended_at, created_at`
+
+// scanPileDegradation 扫描降功率运行时段记录，处理可能为NULL的字段
+func scanPileDegradation(row interface{ Scan(...any) error }) (*model.PileDegradation, error) {
+	var degradation model.PileDegradation
+	var description sql.NullString
+	var endedAt sql.NullTime
+
+	err := row.Scan(
+		&degradation.ID,
+		&degradation.PileID,
+		&degradation.FaultType,
+		&description,
+		&degradation.RatedPower,
+		&degradation.EffectivePower,
+		&degradation.StartedAt,
+		&endedAt,
+		&degradation.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	degradation.Description = description.String
+	if endedAt.Valid {
+		degradation.EndedAt = &endedAt.Time
+	}
+	return &degradation, nil
+}
+
+// Create 创建降功率运行时段
+func (r *PileDegradationRepository) Create(degradation *model.PileDegradation) error {
+	query := `
+		INSERT INTO pile_degradations (id, pile_id, fault_type, description, rated_power, effective_power, started_at, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+	`
+
+	if degradation.ID == uuid.Nil {
+		degradation.ID = uuid.New()
+	}
+	degradation.CreatedAt = time.Now().UTC()
+	_, err := r.db.Exec(query,
+		degradation.ID,
+		degradation.PileID,
+		degradation.FaultType,
+		degradation.Description,
+		degradation.RatedPower,
+		degradation.EffectivePower,
+		degradation.StartedAt,
+		degradation.CreatedAt,
+	)
+	return err
+}
+
+// EndActive 结束充电桩进行中的降功率运行时段
+func (r *PileDegradationRepository) EndActive(pileID string, endedAt time.Time) error {
+	_, err := r.db.Exec(`
+		UPDATE pile_degradations
+		SET ended_at = $1
+		WHERE pile_id = $2 AND ended_at IS NULL
+	`, endedAt, pileID)
+	return err
+}
+
+// GetOverlapping 获取与时间段有交集的降功率运行时段，pileID为空时返回全部充电桩
+func (r *PileDegradationRepository) GetOverlapping(pileID string, startTime, endTime time.Time) ([]*model.PileDegradation, error) {
+	rows, err := r.db.Query(`
+		SELECT `+pileDegradationColumns+`
+		FROM pile_degradations
+		WHERE ($1 = '' OR pile_id = $1) AND started_at <= $3 AND (ended_at IS NULL OR ended_at >= $2)
+		ORDER BY started_at ASC
+	`, pileID, startTime, endTime)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	degradations := []*model.PileDegradation{}
+	for rows.Next() {
+		degradation, err := scanPileDegradation(rows)
+		if err != nil {
+			return nil, err
+		}
+		degradations = append(degradations, degradation)
+	}
+	return degradations, rows.Err()
+}
@@ -0,0 +1,92 @@
+package repository
+
+import (
+	"database/sql"
+	"time"
+
+	"backend/internal/model"
+)
+
+// PileEndpointRepository 充电桩注册仓库
+type PileEndpointRepository struct {
+	db *sql.DB
+}
+
+// NewPileEndpointRepository 创建充电桩注册仓库
+func NewPileEndpointRepository(db *sql.DB) *PileEndpointRepository {
+	return &PileEndpointRepository{
+		db: db,
+	}
+}
+
+// Upsert 保存充电桩的指令下发地址，已注册时更新地址
+func (r *PileEndpointRepository) Upsert(pileID, endpointURL string) error {
+	query := `
+		INSERT INTO pile_endpoints (pile_id, endpoint_url, registered_at, updated_at)
+		VALUES ($1, $2, $3, $3)
+		ON CONFLICT (pile_id) DO UPDATE
+		SET endpoint_url = EXCLUDED.endpoint_url, updated_at = EXCLUDED.updated_at
+	`
+
+	_, err := r.db.Exec(query, pileID, endpointURL, time.Now().UTC())
+	return err
+}
+
+// GetByPileID 获取充电桩的注册信息，未注册时返回nil
+func (r *PileEndpointRepository) GetByPileID(pileID string) (*model.PileEndpoint, error) {
+	query := `
+		SELECT pile_id, endpoint_url, registered_at, updated_at
+		FROM pile_endpoints
+		WHERE pile_id = $1
+	`
+
+	var endpoint model.PileEndpoint
+	err := r.db.QueryRow(query, pileID).Scan(
+		&endpoint.PileID,
+		&endpoint.EndpointURL,
+		&endpoint.RegisteredAt,
+		&endpoint.UpdatedAt,
+	)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &endpoint, nil
+}
+
+// GetAll 获取全部充电桩注册信息
+func (r *PileEndpointRepository) GetAll() ([]*model.PileEndpoint, error) {
+	query := `
+		SELECT pile_id, endpoint_url, registered_at, updated_at
+		FROM pile_endpoints
+		ORDER BY pile_id
+	`
+
+	rows, err := r.db.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var endpoints []*model.PileEndpoint
+	for rows.Next() {
+		var endpoint model.PileEndpoint
+		if err := rows.Scan(
+			&endpoint.PileID,
+			&endpoint.EndpointURL,
+			&endpoint.RegisteredAt,
+			&endpoint.UpdatedAt,
+		); err != nil {
+			return nil, err
+		}
+		endpoints = append(endpoints, &endpoint)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return endpoints, nil
+}
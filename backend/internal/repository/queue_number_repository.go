@@ -0,0 +1,35 @@
+package repository
+
+import (
+	"database/sql"
+	"time"
+
+	"backend/internal/model"
+)
+
+// QueueNumberRepository 队列号序列仓库
+type QueueNumberRepository struct {
+	db *sql.DB
+}
+
+// NewQueueNumberRepository 创建队列号序列仓库
+func NewQueueNumberRepository(db *sql.DB) *QueueNumberRepository {
+	return &QueueNumberRepository{
+		db: db,
+	}
+}
+
+// Next 原子地取得站点某充电模式在营业日内的下一个序号，营业日首次取号从1开始
+func (r *QueueNumberRepository) Next(stationID string, mode model.ChargingMode, businessDate time.Time) (int, error) {
+	query := `
+		INSERT INTO queue_number_sequences (station_id, charging_mode, business_date, last_value, updated_at)
+		VALUES ($1, $2, $3, 1, $4)
+		ON CONFLICT (station_id, charging_mode, business_date)
+		DO UPDATE SET last_value = queue_number_sequences.last_value + 1, updated_at = EXCLUDED.updated_at
+		RETURNING last_value
+	`
+
+	var value int
+	err := r.db.QueryRow(query, stationID, mode, businessDate.Format("2006-01-02"), time.Now().UTC()).Scan(&value)
+	return value, err
+}
@@ -265,9 +265,14 @@ func (r *QueueRepository) GetQueueStatus() (*model.QueueStatus, error) {
 		return nil, err
 	}
 
-	// 计算等候区可用车位数
-	var totalCount int
-	err = r.db.QueryRow(`SELECT COUNT(*) FROM charging_requests WHERE status = 'waiting'`).Scan(&totalCount)
+	// 计算等候区可用车位数，为已获得入场资格的候补请求保留的位置不可用
+	var totalCount, waitlistLength int
+	err = r.db.QueryRow(`
+		SELECT COUNT(*) FILTER (WHERE status = 'waiting' OR arrival_deadline IS NOT NULL),
+		       COUNT(*) FILTER (WHERE status = 'waitlisted' AND arrival_deadline IS NULL)
+		FROM charging_requests
+		WHERE status IN ('waiting', 'waitlisted')
+	`).Scan(&totalCount, &waitlistLength)
 	if err != nil {
 		return nil, err
 	}
@@ -287,5 +292,6 @@ func (r *QueueRepository) GetQueueStatus() (*model.QueueStatus, error) {
 		FastQueue:      fastQueue,
 		SlowQueue:      slowQueue,
 		AvailableSlots: availableSlots,
+		WaitlistLength: waitlistLength,
 	}, nil
 }
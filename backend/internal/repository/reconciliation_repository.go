@@ -0,0 +1,119 @@
+package repository
+
+import (
+	"database/sql"
+	"encoding/json"
+
+	"backend/internal/model"
+)
+
+// ReconciliationRepository 对账告警仓库
+type ReconciliationRepository struct {
+	db *sql.DB
+}
+
+// NewReconciliationRepository 创建对账告警仓库
+func NewReconciliationRepository(db *sql.DB) *ReconciliationRepository {
+	return &ReconciliationRepository{
+		db: db,
+	}
+}
+
+// CreateAlert 创建对账告警
+func (r *ReconciliationRepository) CreateAlert(alert *model.ReconciliationAlert) error {
+	backendState, err := json.Marshal(alert.BackendState)
+	if err != nil {
+		return err
+	}
+	simulatorState, err := json.Marshal(alert.SimulatorState)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		INSERT INTO reconciliation_alerts (id, pile_id, kind, backend_state, simulator_state, diff, action, detail, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+	`
+	_, err = r.db.Exec(query,
+		alert.ID,
+		alert.PileID,
+		alert.Kind,
+		backendState,
+		simulatorState,
+		alert.Diff,
+		alert.Action,
+		alert.Detail,
+		alert.CreatedAt,
+	)
+	return err
+}
+
+// ResolveAlerts 状态恢复一致后关闭该充电桩指定类型的未关闭告警
+func (r *ReconciliationRepository) ResolveAlerts(pileID string, kind model.MismatchKind) error {
+	_, err := r.db.Exec(`
+		UPDATE reconciliation_alerts
+		SET resolved_at = NOW()
+		WHERE pile_id = $1 AND kind = $2 AND resolved_at IS NULL
+	`, pileID, kind)
+	return err
+}
+
+// GetAlerts 分页查询对账告警，unresolvedOnly为true时只返回未关闭的告警
+func (r *ReconciliationRepository) GetAlerts(unresolvedOnly bool, page, pageSize int) ([]*model.ReconciliationAlert, int, error) {
+	where := ""
+	if unresolvedOnly {
+		where = "WHERE resolved_at IS NULL"
+	}
+
+	var total int
+	if err := r.db.QueryRow(`SELECT COUNT(*) FROM reconciliation_alerts ` + where).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	rows, err := r.db.Query(`
+		SELECT id, pile_id, kind, backend_state, simulator_state, diff, action, detail, created_at, resolved_at
+		FROM reconciliation_alerts `+where+`
+		ORDER BY created_at DESC
+		LIMIT $1 OFFSET $2
+	`, pageSize, (page-1)*pageSize)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	alerts := []*model.ReconciliationAlert{}
+	for rows.Next() {
+		var alert model.ReconciliationAlert
+		var backendState, simulatorState []byte
+		var detail sql.NullString
+		var resolvedAt sql.NullTime
+		err := rows.Scan(
+			&alert.ID,
+			&alert.PileID,
+			&alert.Kind,
+			&backendState,
+			&simulatorState,
+			&alert.Diff,
+			&alert.Action,
+			&detail,
+			&alert.CreatedAt,
+			&resolvedAt,
+		)
+		if err != nil {
+			return nil, 0, err
+		}
+		if err := json.Unmarshal(backendState, &alert.BackendState); err != nil {
+			return nil, 0, err
+		}
+		if err := json.Unmarshal(simulatorState, &alert.SimulatorState); err != nil {
+			return nil, 0, err
+		}
+		alert.Detail = detail.String
+		if resolvedAt.Valid {
+			alert.ResolvedAt = &resolvedAt.Time
+		}
+		alerts = append(alerts, &alert)
+	}
+
+	return alerts, total, rows.Err()
+}
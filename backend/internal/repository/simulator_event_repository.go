@@ -0,0 +1,83 @@
+package repository
+
+import (
+	"database/sql"
+	"time"
+
+	"backend/internal/model"
+
+	"github.com/google/uuid"
+)
+
+// SimulatorEventRepository 模拟器回调事件仓库
+type SimulatorEventRepository struct {
+	db *sql.DB
+}
+
+// NewSimulatorEventRepository 创建模拟器回调事件仓库
+func NewSimulatorEventRepository(db *sql.DB) *SimulatorEventRepository {
+	return &SimulatorEventRepository{
+		db: db,
+	}
+}
+
+// GetByEventID 通过事件ID获取已处理的事件，未处理过时返回nil
+func (r *SimulatorEventRepository) GetByEventID(eventID string) (*model.SimulatorEvent, error) {
+	query := `
+		SELECT event_id, event_type, pile_id, session_id, status_code, response_body, created_at
+		FROM simulator_events
+		WHERE event_id = $1
+	`
+
+	var event model.SimulatorEvent
+	var sessionID uuid.NullUUID
+	err := r.db.QueryRow(query, eventID).Scan(
+		&event.EventID,
+		&event.EventType,
+		&event.PileID,
+		&sessionID,
+		&event.StatusCode,
+		&event.ResponseBody,
+		&event.CreatedAt,
+	)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	if sessionID.Valid {
+		event.SessionID = &sessionID.UUID
+	}
+
+	return &event, nil
+}
+
+// Create 保存事件处理结果，事件ID已存在时不覆盖首次结果
+func (r *SimulatorEventRepository) Create(event *model.SimulatorEvent) error {
+	query := `
+		INSERT INTO simulator_events (event_id, event_type, pile_id, session_id, status_code, response_body, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		ON CONFLICT (event_id) DO NOTHING
+	`
+
+	_, err := r.db.Exec(query,
+		event.EventID,
+		event.EventType,
+		event.PileID,
+		event.SessionID,
+		event.StatusCode,
+		event.ResponseBody,
+		event.CreatedAt,
+	)
+	return err
+}
+
+// DeleteBefore 删除指定时间之前的事件，返回删除条数
+func (r *SimulatorEventRepository) DeleteBefore(before time.Time) (int64, error) {
+	result, err := r.db.Exec(`DELETE FROM simulator_events WHERE created_at < $1`, before)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
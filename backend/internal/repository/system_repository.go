@@ -124,6 +124,8 @@ func (r *SystemRepository) GetSchedulingConfig() (*model.SchedulingConfig, error
 		ChargingQueueLen:    2,
 		FastChargingPower:   30.0,
 		SlowChargingPower:   7.0,
+
+		FaultReschedulingPolicy: model.FaultPolicyPriority,
 	}
 
 	// 应用配置，如果存在的话
@@ -167,6 +169,12 @@ func (r *SystemRepository) GetSchedulingConfig() (*model.SchedulingConfig, error
 		}
 	}
 
+	if val, ok := configMap["fault_rescheduling_policy"]; ok {
+		if policy := model.FaultReschedulingPolicy(val); model.IsValidFaultReschedulingPolicy(policy) {
+			schedulingConfig.FaultReschedulingPolicy = policy
+		}
+	}
+
 	return &schedulingConfig, nil
 }
 
@@ -235,6 +243,12 @@ func (r *SystemRepository) UpdateSchedulingConfig(config *model.SchedulingConfig
 		return err
 	}
 
+	_, err = stmt.Exec(string(config.FaultReschedulingPolicy), now, "fault_rescheduling_policy")
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
 	// 提交事务
 	return tx.Commit()
 }
@@ -247,37 +261,40 @@ func (r *SystemRepository) CreateFaultRecord(record *model.FaultRecord) error {
 		VALUES ($1, $2, $3, $4, $5, $6, $7)
 	`
 
-	now := time.Now().UTC()
+	if record.ID == uuid.Nil {
+		record.ID = uuid.New()
+	}
+	record.CreatedAt = time.Now().UTC()
 	_, err := r.db.Exec(
 		query,
-		uuid.New(),
+		record.ID,
 		record.PileID,
 		record.FaultType,
 		record.Description,
 		record.OccurredAt,
 		record.Status,
-		now,
+		record.CreatedAt,
 	)
 
 	return err
 }
 
 // UpdateFaultRecord 更新故障记录
-func (r *SystemRepository) UpdateFaultRecord(id uuid.UUID, recoveredAt time.Time, affectedSessions int) error {
+func (r *SystemRepository) UpdateFaultRecord(id uuid.UUID, recoveredAt time.Time, affectedSessions int, recoveryNotes string) error {
 	query := `
 		UPDATE fault_records
-		SET recovered_at = $1, affected_sessions = $2, status = 'resolved'
-		WHERE id = $3
+		SET recovered_at = $1, affected_sessions = $2, recovery_notes = $3, status = 'resolved'
+		WHERE id = $4
 	`
 
-	_, err := r.db.Exec(query, recoveredAt, affectedSessions, id)
+	_, err := r.db.Exec(query, recoveredAt, affectedSessions, recoveryNotes, id)
 	return err
 }
 
 // GetActiveFaultByPileID 通过充电桩ID获取活跃故障
 func (r *SystemRepository) GetActiveFaultByPileID(pileID string) (*model.FaultRecord, error) {
 	query := `
-		SELECT id, pile_id, fault_type, description, occurred_at, recovered_at, affected_sessions, status, created_at
+		SELECT id, pile_id, fault_type, description, occurred_at, recovered_at, affected_sessions, status, COALESCE(recovery_notes, ''), created_at
 		FROM fault_records
 		WHERE pile_id = $1 AND status = 'active'
 		ORDER BY occurred_at DESC
@@ -295,6 +312,7 @@ func (r *SystemRepository) GetActiveFaultByPileID(pileID string) (*model.FaultRe
 		&recoveredAt,
 		&record.AffectedSessions,
 		&record.Status,
+		&record.RecoveryNotes,
 		&record.CreatedAt,
 	)
 
@@ -330,7 +348,7 @@ func (r *SystemRepository) GetFaultRecords(startTime, endTime time.Time, pileID
 		countArgs = append(countArgs, *pileID)
 
 		dataQuery = `
-			SELECT id, pile_id, fault_type, description, occurred_at, recovered_at, affected_sessions, status, created_at
+			SELECT id, pile_id, fault_type, description, occurred_at, recovered_at, affected_sessions, status, COALESCE(recovery_notes, ''), created_at
 			FROM fault_records
 			WHERE occurred_at >= $1 AND occurred_at <= $2 AND pile_id = $3
 			ORDER BY occurred_at DESC
@@ -345,7 +363,7 @@ func (r *SystemRepository) GetFaultRecords(startTime, endTime time.Time, pileID
 		`
 
 		dataQuery = `
-			SELECT id, pile_id, fault_type, description, occurred_at, recovered_at, affected_sessions, status, created_at
+			SELECT id, pile_id, fault_type, description, occurred_at, recovered_at, affected_sessions, status, COALESCE(recovery_notes, ''), created_at
 			FROM fault_records
 			WHERE occurred_at >= $1 AND occurred_at <= $2
 			ORDER BY occurred_at DESC
@@ -381,6 +399,7 @@ func (r *SystemRepository) GetFaultRecords(startTime, endTime time.Time, pileID
 			&recoveredAt,
 			&record.AffectedSessions,
 			&record.Status,
+			&record.RecoveryNotes,
 			&record.CreatedAt,
 		)
 
@@ -402,6 +421,48 @@ func (r *SystemRepository) GetFaultRecords(startTime, endTime time.Time, pileID
 	return records, total, nil
 }
 
+// GetFaultsOverlapping 获取与时间段有交集的全部故障记录，按发生时间排序
+func (r *SystemRepository) GetFaultsOverlapping(startTime, endTime time.Time) ([]*model.FaultRecord, error) {
+	rows, err := r.db.Query(`
+		SELECT id, pile_id, fault_type, description, occurred_at, recovered_at, affected_sessions, status, COALESCE(recovery_notes, ''), created_at
+		FROM fault_records
+		WHERE occurred_at <= $2 AND (recovered_at IS NULL OR recovered_at >= $1)
+		ORDER BY occurred_at ASC
+	`, startTime, endTime)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	records := []*model.FaultRecord{}
+	for rows.Next() {
+		var record model.FaultRecord
+		var recoveredAt sql.NullTime
+		err := rows.Scan(
+			&record.ID,
+			&record.PileID,
+			&record.FaultType,
+			&record.Description,
+			&record.OccurredAt,
+			&recoveredAt,
+			&record.AffectedSessions,
+			&record.Status,
+			&record.RecoveryNotes,
+			&record.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		if recoveredAt.Valid {
+			record.RecoveredAt = &recoveredAt.Time
+		}
+		records = append(records, &record)
+	}
+
+	return records, rows.Err()
+}
+
 // GenerateStatisticsReport 生成统计报表
 func (r *SystemRepository) GenerateStatisticsReport(period string, date time.Time) (*model.StatisticsReport, error) {
 	var startDate, endDate time.Time
@@ -3,6 +3,7 @@ package repository
 import (
 	"database/sql"
 	"errors"
+	"fmt"
 	"time"
 
 	"backend/internal/model"
@@ -36,6 +37,16 @@ func (r *UserRepository) Create(user *model.CreateUserRequest) (*model.User, err
 		return nil, errors.New("用户名已存在")
 	}
 
+	// 检查车牌是否已被登记
+	err = r.db.QueryRow("SELECT COUNT(*) FROM vehicles WHERE license_plate = $1 AND deleted_at IS NULL", user.LicensePlate).Scan(&count)
+	if err != nil {
+		return nil, err
+	}
+
+	if count > 0 {
+		return nil, errors.New("车牌号已被登记")
+	}
+
 	// 生成密码哈希
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(user.Password), bcrypt.DefaultCost)
 	if err != nil {
@@ -46,6 +57,13 @@ func (r *UserRepository) Create(user *model.CreateUserRequest) (*model.User, err
 	userID := uuid.New()
 	now := time.Now().UTC()
 
+	// 用户与其首辆车在同一事务中创建
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
 	// 插入新用户
 	query := `
 		INSERT INTO users (id, username, password_hash, user_type, license_plate, battery_capacity, created_at, updated_at) 
@@ -54,7 +72,7 @@ func (r *UserRepository) Create(user *model.CreateUserRequest) (*model.User, err
 	`
 
 	var newUser model.User
-	err = r.db.QueryRow(
+	err = tx.QueryRow(
 		query,
 		userID,
 		user.Username,
@@ -78,18 +96,32 @@ func (r *UserRepository) Create(user *model.CreateUserRequest) (*model.User, err
 		return nil, err
 	}
 
+	// 登记注册时填写的车辆
+	_, err = tx.Exec(`
+		INSERT INTO vehicles (id, user_id, license_plate, battery_capacity, default_mode, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+	`, uuid.New(), userID, user.LicensePlate, user.BatteryCapacity, model.ChargingModeFast, now, now)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, err
+	}
+
 	return &newUser, nil
 }
 
 // GetByID 根据ID获取用户
 func (r *UserRepository) GetByID(id uuid.UUID) (*model.User, error) {
 	query := `
-		SELECT id, username, password_hash, user_type, license_plate, battery_capacity, created_at, updated_at
+		SELECT id, username, password_hash, user_type, license_plate, battery_capacity, disabled_at, COALESCE(disabled_reason, ''), created_at, updated_at
 		FROM users
 		WHERE id = $1
 	`
 
 	var user model.User
+	var disabledAt sql.NullTime
 	err := r.db.QueryRow(query, id).Scan(
 		&user.ID,
 		&user.Username,
@@ -97,6 +129,8 @@ func (r *UserRepository) GetByID(id uuid.UUID) (*model.User, error) {
 		&user.UserType,
 		&user.LicensePlate,
 		&user.BatteryCapacity,
+		&disabledAt,
+		&user.DisabledReason,
 		&user.CreatedAt,
 		&user.UpdatedAt,
 	)
@@ -108,18 +142,23 @@ func (r *UserRepository) GetByID(id uuid.UUID) (*model.User, error) {
 		return nil, err
 	}
 
+	if disabledAt.Valid {
+		user.DisabledAt = &disabledAt.Time
+	}
+
 	return &user, nil
 }
 
 // GetByUsername 根据用户名获取用户
 func (r *UserRepository) GetByUsername(username string) (*model.User, error) {
 	query := `
-		SELECT id, username, password_hash, user_type, license_plate, battery_capacity, created_at, updated_at 
+		SELECT id, username, password_hash, user_type, license_plate, battery_capacity, disabled_at, COALESCE(disabled_reason, ''), created_at, updated_at 
 		FROM users 
 		WHERE username = $1
 	`
 
 	var user model.User
+	var disabledAt sql.NullTime
 	err := r.db.QueryRow(query, username).Scan(
 		&user.ID,
 		&user.Username,
@@ -127,6 +166,8 @@ func (r *UserRepository) GetByUsername(username string) (*model.User, error) {
 		&user.UserType,
 		&user.LicensePlate,
 		&user.BatteryCapacity,
+		&disabledAt,
+		&user.DisabledReason,
 		&user.CreatedAt,
 		&user.UpdatedAt,
 	)
@@ -138,28 +179,53 @@ func (r *UserRepository) GetByUsername(username string) (*model.User, error) {
 		return nil, err
 	}
 
+	if disabledAt.Valid {
+		user.DisabledAt = &disabledAt.Time
+	}
+
 	return &user, nil
 }
 
 // List 获取用户列表
-func (r *UserRepository) List(page, pageSize int) ([]*model.User, int, error) {
+func (r *UserRepository) List(filter *model.UserFilter, page, pageSize int) ([]*model.User, int, error) {
+	// 构建筛选条件
+	where := "WHERE 1=1"
+	var args []any
+	if filter != nil {
+		if filter.Keyword != "" {
+			args = append(args, "%"+filter.Keyword+"%")
+			where += fmt.Sprintf(" AND (username ILIKE $%d OR license_plate ILIKE $%d)", len(args), len(args))
+		}
+		if filter.UserType != "" {
+			args = append(args, filter.UserType)
+			where += fmt.Sprintf(" AND user_type = $%d", len(args))
+		}
+		switch filter.Status {
+		case "active":
+			where += " AND disabled_at IS NULL"
+		case "disabled":
+			where += " AND disabled_at IS NOT NULL"
+		}
+	}
+
 	// 获取总数
 	var total int
-	err := r.db.QueryRow("SELECT COUNT(*) FROM users").Scan(&total)
+	err := r.db.QueryRow("SELECT COUNT(*) FROM users "+where, args...).Scan(&total)
 	if err != nil {
 		return nil, 0, err
 	}
 
 	// 分页查询
 	offset := (page - 1) * pageSize
-	query := `
-		SELECT id, username, user_type, license_plate, battery_capacity, created_at, updated_at 
+	query := fmt.Sprintf(`
+		SELECT id, username, user_type, license_plate, battery_capacity, disabled_at, COALESCE(disabled_reason, ''), created_at, updated_at 
 		FROM users
+		%s
 		ORDER BY created_at DESC
-		LIMIT $1 OFFSET $2
-	`
+		LIMIT $%d OFFSET $%d
+	`, where, len(args)+1, len(args)+2)
 
-	rows, err := r.db.Query(query, pageSize, offset)
+	rows, err := r.db.Query(query, append(args, pageSize, offset)...)
 	if err != nil {
 		return nil, 0, err
 	}
@@ -168,18 +234,24 @@ func (r *UserRepository) List(page, pageSize int) ([]*model.User, int, error) {
 	var users []*model.User
 	for rows.Next() {
 		var user model.User
+		var disabledAt sql.NullTime
 		err := rows.Scan(
 			&user.ID,
 			&user.Username,
 			&user.UserType,
 			&user.LicensePlate,
 			&user.BatteryCapacity,
+			&disabledAt,
+			&user.DisabledReason,
 			&user.CreatedAt,
 			&user.UpdatedAt,
 		)
 		if err != nil {
 			return nil, 0, err
 		}
+		if disabledAt.Valid {
+			user.DisabledAt = &disabledAt.Time
+		}
 		users = append(users, &user)
 	}
 
@@ -209,6 +281,192 @@ func (r *UserRepository) Update(user *model.User) error {
 	return err
 }
 
+// ErrLastAdmin 操作会移除最后一个可用的管理员
+var ErrLastAdmin = errors.New("系统至少需要保留一个可用的管理员")
+
+// ensureOtherAdmin 在事务内锁定全部可用管理员，用户是可用管理员且没有其他可用管理员时返回 ErrLastAdmin
+// 并发的降级或禁用会在锁上排队，后执行的一方看到前一方提交后的结果
+func ensureOtherAdmin(tx *sql.Tx, id uuid.UUID) error {
+	rows, err := tx.Query(`
+		SELECT id FROM users
+		WHERE user_type = $1 AND disabled_at IS NULL
+		FOR UPDATE
+	`, model.UserTypeAdmin)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	isAdmin := false
+	others := 0
+	for rows.Next() {
+		var adminID uuid.UUID
+		if err := rows.Scan(&adminID); err != nil {
+			return err
+		}
+		if adminID == id {
+			isAdmin = true
+		} else {
+			others++
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	if isAdmin && others == 0 {
+		return ErrLastAdmin
+	}
+	return nil
+}
+
+// UpdateUserType 更新用户角色，不允许将最后一个可用的管理员降级
+func (r *UserRepository) UpdateUserType(id uuid.UUID, userType model.UserType) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("开始事务失败: %w", err)
+	}
+	defer tx.Rollback()
+
+	if userType != model.UserTypeAdmin {
+		if err := ensureOtherAdmin(tx, id); err != nil {
+			return err
+		}
+	}
+
+	query := `UPDATE users SET user_type = $1, updated_at = $2 WHERE id = $3`
+	result, err := tx.Exec(query, userType, time.Now().UTC(), id)
+	if err != nil {
+		return err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return errors.New("用户不存在")
+	}
+	return tx.Commit()
+}
+
+// SetDisabled 禁用或启用用户，不允许禁用最后一个可用的管理员
+func (r *UserRepository) SetDisabled(id uuid.UUID, disabled bool, reason string) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("开始事务失败: %w", err)
+	}
+	defer tx.Rollback()
+
+	var query string
+	var args []any
+	now := time.Now().UTC()
+	if disabled {
+		if err := ensureOtherAdmin(tx, id); err != nil {
+			return err
+		}
+		query = `UPDATE users SET disabled_at = $1, disabled_reason = $2, updated_at = $1 WHERE id = $3`
+		args = []any{now, reason, id}
+	} else {
+		query = `UPDATE users SET disabled_at = NULL, disabled_reason = NULL, updated_at = $1 WHERE id = $2`
+		args = []any{now, id}
+	}
+
+	result, err := tx.Exec(query, args...)
+	if err != nil {
+		return err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return errors.New("用户不存在")
+	}
+	return tx.Commit()
+}
+
+// CreatePasswordResetToken 创建密码重置令牌，同一用户之前未使用的令牌作废
+func (r *UserRepository) CreatePasswordResetToken(userID, createdBy uuid.UUID, tokenHash string, expiresAt time.Time) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	now := time.Now().UTC()
+	_, err = tx.Exec(`
+		UPDATE password_reset_tokens SET used_at = $1
+		WHERE user_id = $2 AND used_at IS NULL
+	`, now, userID)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(`
+		INSERT INTO password_reset_tokens (token_hash, user_id, created_by, expires_at, created_at)
+		VALUES ($1, $2, $3, $4, $5)
+	`, tokenHash, userID, createdBy, expiresAt, now)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// ResetPasswordWithToken 使用重置令牌修改密码，返回用户ID
+func (r *UserRepository) ResetPasswordWithToken(tokenHash, newPassword string) (uuid.UUID, error) {
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(newPassword), bcrypt.DefaultCost)
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		return uuid.Nil, err
+	}
+	defer tx.Rollback()
+
+	var userID uuid.UUID
+	var expiresAt time.Time
+	var usedAt sql.NullTime
+	err = tx.QueryRow(`
+		SELECT user_id, expires_at, used_at
+		FROM password_reset_tokens
+		WHERE token_hash = $1
+		FOR UPDATE
+	`, tokenHash).Scan(&userID, &expiresAt, &usedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return uuid.Nil, errors.New("重置令牌无效")
+		}
+		return uuid.Nil, err
+	}
+
+	now := time.Now().UTC()
+	if usedAt.Valid {
+		return uuid.Nil, errors.New("重置令牌已使用")
+	}
+	if !expiresAt.After(now) {
+		return uuid.Nil, errors.New("重置令牌已过期")
+	}
+
+	_, err = tx.Exec(`UPDATE password_reset_tokens SET used_at = $1 WHERE token_hash = $2`, now, tokenHash)
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	_, err = tx.Exec(`UPDATE users SET password_hash = $1, updated_at = $2 WHERE id = $3`, string(hashedPassword), now, userID)
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return uuid.Nil, err
+	}
+	return userID, nil
+}
+
 // VerifyPassword 验证密码
 func (r *UserRepository) VerifyPassword(username, password string) (*model.User, error) {
 	user, err := r.GetByUsername(username)
@@ -224,48 +482,233 @@ func (r *UserRepository) VerifyPassword(username, password string) (*model.User,
 	return user, nil
 }
 
-// SaveSession 保存用户会话
-func (r *UserRepository) SaveSession(userID uuid.UUID, token string, expiresAt time.Time) error {
+// ErrRefreshTokenReused 刷新令牌已被轮换后再次使用
+var ErrRefreshTokenReused = errors.New("刷新令牌已被使用")
+
+// sessionColumns 会话查询字段
+const sessionColumns = `id, user_id, user_agent, ip_address, created_at, last_used_at, expires_at, revoked_at`
+
+// scanSession 扫描会话记录
+func scanSession(row interface{ Scan(...any) error }) (*model.UserSession, error) {
+	var session model.UserSession
+	var revokedAt sql.NullTime
+	err := row.Scan(
+		&session.ID,
+		&session.UserID,
+		&session.UserAgent,
+		&session.IPAddress,
+		&session.CreatedAt,
+		&session.LastUsedAt,
+		&session.ExpiresAt,
+		&revokedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if revokedAt.Valid {
+		session.RevokedAt = &revokedAt.Time
+	}
+	return &session, nil
+}
+
+// CreateSession 创建登录会话
+func (r *UserRepository) CreateSession(userID uuid.UUID, refreshTokenHash, userAgent, ipAddress string, expiresAt time.Time) (*model.UserSession, error) {
+	now := time.Now().UTC()
+	session := &model.UserSession{
+		ID:         uuid.New(),
+		UserID:     userID,
+		UserAgent:  userAgent,
+		IPAddress:  ipAddress,
+		CreatedAt:  now,
+		LastUsedAt: now,
+		ExpiresAt:  expiresAt,
+	}
+
 	query := `
-		INSERT INTO user_sessions (id, user_id, token, expires_at, created_at)
-		VALUES ($1, $2, $3, $4, $5)
+		INSERT INTO user_sessions (id, user_id, refresh_token_hash, user_agent, ip_address, expires_at, created_at, last_used_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
 	`
 
 	_, err := r.db.Exec(
 		query,
-		uuid.New(),
+		session.ID,
 		userID,
-		token,
+		refreshTokenHash,
+		userAgent,
+		ipAddress,
 		expiresAt,
-		time.Now().UTC(),
+		now,
+		now,
 	)
+	if err != nil {
+		return nil, err
+	}
 
-	return err
+	return session, nil
 }
 
-// InvalidateSession 使会话无效
-func (r *UserRepository) InvalidateSession(token string) error {
-	query := `DELETE FROM user_sessions WHERE token = $1`
-	_, err := r.db.Exec(query, token)
-	return err
+// RotateRefreshToken 轮换刷新令牌
+// 旧令牌写入已轮换表；若传入的是已轮换过的令牌，返回所属会话和 ErrRefreshTokenReused
+func (r *UserRepository) RotateRefreshToken(oldHash, newHash string, expiresAt time.Time) (*model.UserSession, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	session, err := scanSession(tx.QueryRow(`
+		SELECT `+sessionColumns+`
+		FROM user_sessions
+		WHERE refresh_token_hash = $1
+		FOR UPDATE
+	`, oldHash))
+	if err == sql.ErrNoRows {
+		// 检查是否为已轮换的旧令牌
+		var sessionID uuid.UUID
+		err = tx.QueryRow(`SELECT session_id FROM user_session_rotated_tokens WHERE token_hash = $1`, oldHash).Scan(&sessionID)
+		if err == sql.ErrNoRows {
+			return nil, errors.New("刷新令牌无效")
+		}
+		if err != nil {
+			return nil, err
+		}
+		reused, err := scanSession(tx.QueryRow(`SELECT `+sessionColumns+` FROM user_sessions WHERE id = $1`, sessionID))
+		if err != nil {
+			return nil, err
+		}
+		return reused, ErrRefreshTokenReused
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	if session.RevokedAt != nil {
+		return nil, errors.New("会话已被注销")
+	}
+	if !session.ExpiresAt.After(now) {
+		return nil, errors.New("刷新令牌已过期")
+	}
+
+	_, err = tx.Exec(`
+		INSERT INTO user_session_rotated_tokens (token_hash, session_id, rotated_at)
+		VALUES ($1, $2, $3)
+	`, oldHash, session.ID, now)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = tx.Exec(`
+		UPDATE user_sessions
+		SET refresh_token_hash = $1, expires_at = $2, last_used_at = $3
+		WHERE id = $4
+	`, newHash, expiresAt, now, session.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	session.ExpiresAt = expiresAt
+	session.LastUsedAt = now
+	return session, nil
+}
+
+// GetSessionByID 根据ID获取会话
+func (r *UserRepository) GetSessionByID(sessionID uuid.UUID) (*model.UserSession, error) {
+	session, err := scanSession(r.db.QueryRow(`SELECT `+sessionColumns+` FROM user_sessions WHERE id = $1`, sessionID))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errors.New("会话不存在")
+		}
+		return nil, err
+	}
+	return session, nil
+}
+
+// GetActiveSessionsByUserID 获取用户未注销且未过期的会话
+func (r *UserRepository) GetActiveSessionsByUserID(userID uuid.UUID) ([]*model.UserSession, error) {
+	rows, err := r.db.Query(`
+		SELECT `+sessionColumns+`
+		FROM user_sessions
+		WHERE user_id = $1 AND revoked_at IS NULL AND expires_at > $2
+		ORDER BY last_used_at DESC
+	`, userID, time.Now().UTC())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var sessions []*model.UserSession
+	for rows.Next() {
+		session, err := scanSession(rows)
+		if err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, session)
+	}
+
+	return sessions, rows.Err()
 }
 
-// GetSessionByToken 通过Token获取会话
-func (r *UserRepository) GetSessionByToken(token string) (*uuid.UUID, error) {
+// GetSessionsRevokedSince 获取指定时间之后注销的会话
+func (r *UserRepository) GetSessionsRevokedSince(since time.Time) ([]*model.UserSession, error) {
+	rows, err := r.db.Query(`
+		SELECT `+sessionColumns+`
+		FROM user_sessions
+		WHERE revoked_at >= $1
+	`, since)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var sessions []*model.UserSession
+	for rows.Next() {
+		session, err := scanSession(rows)
+		if err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, session)
+	}
+
+	return sessions, rows.Err()
+}
+
+// RevokeSession 注销单个会话
+func (r *UserRepository) RevokeSession(sessionID uuid.UUID, reason string) error {
 	query := `
-		SELECT user_id 
-		FROM user_sessions 
-		WHERE token = $1 AND expires_at > $2
+		UPDATE user_sessions
+		SET revoked_at = $1, revoke_reason = $2
+		WHERE id = $3 AND revoked_at IS NULL
 	`
+	_, err := r.db.Exec(query, time.Now().UTC(), reason, sessionID)
+	return err
+}
 
-	var userID uuid.UUID
-	err := r.db.QueryRow(query, token, time.Now().UTC()).Scan(&userID)
+// RevokeUserSessions 注销用户的所有会话，exceptSessionID 不为 uuid.Nil 时保留该会话
+func (r *UserRepository) RevokeUserSessions(userID uuid.UUID, exceptSessionID uuid.UUID, reason string) ([]uuid.UUID, error) {
+	rows, err := r.db.Query(`
+		UPDATE user_sessions
+		SET revoked_at = $1, revoke_reason = $2
+		WHERE user_id = $3 AND id <> $4 AND revoked_at IS NULL
+		RETURNING id
+	`, time.Now().UTC(), reason, userID, exceptSessionID)
 	if err != nil {
-		if err == sql.ErrNoRows {
-			return nil, errors.New("会话不存在或已过期")
-		}
 		return nil, err
 	}
+	defer rows.Close()
+
+	var revoked []uuid.UUID
+	for rows.Next() {
+		var id uuid.UUID
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		revoked = append(revoked, id)
+	}
 
-	return &userID, nil
+	return revoked, rows.Err()
 }
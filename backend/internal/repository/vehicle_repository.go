@@ -0,0 +1,185 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"backend/internal/model"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// VehicleRepository 车辆仓库
+type VehicleRepository struct {
+	db *sql.DB
+}
+
+// NewVehicleRepository 创建车辆仓库
+func NewVehicleRepository(db *sql.DB) *VehicleRepository {
+	return &VehicleRepository{
+		db: db,
+	}
+}
+
+// vehicleColumns 车辆查询字段
+const vehicleColumns = `id, user_id, license_plate, battery_capacity, supported_pile_types, default_mode, vehicle_profile,
+		       created_at, updated_at`
+
+// scanVehicle 扫描车辆记录
+func scanVehicle(row interface{ Scan(...any) error }) (*model.Vehicle, error) {
+	var vehicle model.Vehicle
+	var pileTypes []string
+	err := row.Scan(
+		&vehicle.ID,
+		&vehicle.UserID,
+		&vehicle.LicensePlate,
+		&vehicle.BatteryCapacity,
+		pq.Array(&pileTypes),
+		&vehicle.DefaultMode,
+		&vehicle.VehicleProfile,
+		&vehicle.CreatedAt,
+		&vehicle.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, t := range pileTypes {
+		vehicle.SupportedPileTypes = append(vehicle.SupportedPileTypes, model.PileType(t))
+	}
+	return &vehicle, nil
+}
+
+// pileTypesToArray 转换为数据库数组参数
+func pileTypesToArray(types []model.PileType) any {
+	values := make([]string, len(types))
+	for i, t := range types {
+		values[i] = string(t)
+	}
+	return pq.Array(values)
+}
+
+// Create 登记车辆
+func (r *VehicleRepository) Create(vehicle *model.Vehicle) error {
+	// 检查车牌是否已被登记
+	var count int
+	err := r.db.QueryRow(`
+		SELECT COUNT(*) FROM vehicles
+		WHERE license_plate = $1 AND deleted_at IS NULL
+	`, vehicle.LicensePlate).Scan(&count)
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		return errors.New("车牌号已被登记")
+	}
+
+	now := time.Now().UTC()
+	vehicle.CreatedAt = now
+	vehicle.UpdatedAt = now
+
+	query := `
+		INSERT INTO vehicles (id, user_id, license_plate, battery_capacity, supported_pile_types, default_mode, vehicle_profile,
+		                      created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+	`
+
+	_, err = r.db.Exec(
+		query,
+		vehicle.ID,
+		vehicle.UserID,
+		vehicle.LicensePlate,
+		vehicle.BatteryCapacity,
+		pileTypesToArray(vehicle.SupportedPileTypes),
+		vehicle.DefaultMode,
+		vehicle.VehicleProfile,
+		now,
+		now,
+	)
+
+	return err
+}
+
+// GetByID 根据ID获取车辆（包括已删除车辆，便于查询历史请求）
+func (r *VehicleRepository) GetByID(id uuid.UUID) (*model.Vehicle, error) {
+	vehicle, err := scanVehicle(r.db.QueryRow(`SELECT `+vehicleColumns+` FROM vehicles WHERE id = $1`, id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errors.New("车辆不存在")
+		}
+		return nil, err
+	}
+	return vehicle, nil
+}
+
+// GetByUserID 获取用户登记的车辆
+func (r *VehicleRepository) GetByUserID(userID uuid.UUID) ([]*model.Vehicle, error) {
+	query := `
+		SELECT ` + vehicleColumns + `
+		FROM vehicles
+		WHERE user_id = $1 AND deleted_at IS NULL
+		ORDER BY created_at ASC
+	`
+
+	rows, err := r.db.Query(query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var vehicles []*model.Vehicle
+	for rows.Next() {
+		vehicle, err := scanVehicle(rows)
+		if err != nil {
+			return nil, err
+		}
+		vehicles = append(vehicles, vehicle)
+	}
+
+	return vehicles, rows.Err()
+}
+
+// Update 更新车辆信息
+func (r *VehicleRepository) Update(vehicle *model.Vehicle) error {
+	// 检查车牌是否与其他车辆冲突
+	var count int
+	err := r.db.QueryRow(`
+		SELECT COUNT(*) FROM vehicles
+		WHERE license_plate = $1 AND id <> $2 AND deleted_at IS NULL
+	`, vehicle.LicensePlate, vehicle.ID).Scan(&count)
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		return errors.New("车牌号已被登记")
+	}
+
+	query := `
+		UPDATE vehicles
+		SET license_plate = $1, battery_capacity = $2, supported_pile_types = $3, default_mode = $4,
+		    vehicle_profile = $5, updated_at = $6
+		WHERE id = $7 AND deleted_at IS NULL
+	`
+
+	_, err = r.db.Exec(
+		query,
+		vehicle.LicensePlate,
+		vehicle.BatteryCapacity,
+		pileTypesToArray(vehicle.SupportedPileTypes),
+		vehicle.DefaultMode,
+		vehicle.VehicleProfile,
+		time.Now().UTC(),
+		vehicle.ID,
+	)
+
+	return err
+}
+
+// Delete 删除车辆（软删除，保留历史请求关联）
+func (r *VehicleRepository) Delete(id uuid.UUID) error {
+	now := time.Now().UTC()
+	_, err := r.db.Exec(`UPDATE vehicles SET deleted_at = $1, updated_at = $1 WHERE id = $2`, now, id)
+	return err
+}
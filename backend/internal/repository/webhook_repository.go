@@ -0,0 +1,390 @@
+package repository
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"backend/internal/model"
+
+	"github.com/google/uuid"
+)
+
+// WebhookRepository 第三方webhook订阅与投递记录仓库
+type WebhookRepository struct {
+	db *sql.DB
+}
+
+// NewWebhookRepository 创建webhook仓库
+func NewWebhookRepository(db *sql.DB) *WebhookRepository {
+	return &WebhookRepository{
+		db: db,
+	}
+}
+
+// webhookSubscriptionColumns 订阅查询字段
+const webhookSubscriptionColumns = `id, name, url, secret, events, enabled, created_by, created_at, updated_at`
+
+// scanWebhookSubscription 扫描订阅记录，处理可能为NULL的字段
+func scanWebhookSubscription(row interface{ Scan(...any) error }) (*model.WebhookSubscription, error) {
+	var subscription model.WebhookSubscription
+	var events []byte
+	var createdBy uuid.NullUUID
+
+	err := row.Scan(
+		&subscription.ID,
+		&subscription.Name,
+		&subscription.URL,
+		&subscription.Secret,
+		&events,
+		&subscription.Enabled,
+		&createdBy,
+		&subscription.CreatedAt,
+		&subscription.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if events != nil {
+		if err := json.Unmarshal(events, &subscription.Events); err != nil {
+			return nil, err
+		}
+	}
+	if createdBy.Valid {
+		subscription.CreatedBy = &createdBy.UUID
+	}
+	return &subscription, nil
+}
+
+// marshalWebhookEvents 序列化订阅的事件，为空时写入NULL
+func marshalWebhookEvents(events []model.WebhookEventType) ([]byte, error) {
+	if len(events) == 0 {
+		return nil, nil
+	}
+	return json.Marshal(events)
+}
+
+// CreateSubscription 创建订阅
+func (r *WebhookRepository) CreateSubscription(subscription *model.WebhookSubscription) error {
+	events, err := marshalWebhookEvents(subscription.Events)
+	if err != nil {
+		return err
+	}
+
+	_, err = r.db.Exec(`
+		INSERT INTO webhook_subscriptions (id, name, url, secret, events, enabled, created_by, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+	`,
+		subscription.ID,
+		subscription.Name,
+		subscription.URL,
+		subscription.Secret,
+		events,
+		subscription.Enabled,
+		subscription.CreatedBy,
+		subscription.CreatedAt,
+		subscription.UpdatedAt,
+	)
+	return err
+}
+
+// GetSubscriptionByID 获取订阅
+func (r *WebhookRepository) GetSubscriptionByID(id uuid.UUID) (*model.WebhookSubscription, error) {
+	row := r.db.QueryRow(`SELECT `+webhookSubscriptionColumns+` FROM webhook_subscriptions WHERE id = $1`, id)
+	return scanWebhookSubscription(row)
+}
+
+// GetSubscriptions 获取全部订阅，enabledOnly 为真时只返回启用的订阅
+func (r *WebhookRepository) GetSubscriptions(enabledOnly bool) ([]*model.WebhookSubscription, error) {
+	query := `SELECT ` + webhookSubscriptionColumns + ` FROM webhook_subscriptions`
+	if enabledOnly {
+		query += ` WHERE enabled`
+	}
+	query += ` ORDER BY created_at`
+
+	rows, err := r.db.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	subscriptions := []*model.WebhookSubscription{}
+	for rows.Next() {
+		subscription, err := scanWebhookSubscription(rows)
+		if err != nil {
+			return nil, err
+		}
+		subscriptions = append(subscriptions, subscription)
+	}
+	return subscriptions, rows.Err()
+}
+
+// UpdateSubscription 更新订阅的名称、地址、事件与启用状态
+func (r *WebhookRepository) UpdateSubscription(subscription *model.WebhookSubscription) error {
+	events, err := marshalWebhookEvents(subscription.Events)
+	if err != nil {
+		return err
+	}
+
+	_, err = r.db.Exec(`
+		UPDATE webhook_subscriptions
+		SET name = $2, url = $3, events = $4, enabled = $5, updated_at = $6
+		WHERE id = $1
+	`, subscription.ID, subscription.Name, subscription.URL, events, subscription.Enabled, subscription.UpdatedAt)
+	return err
+}
+
+// UpdateSecret 轮换订阅的签名密钥
+func (r *WebhookRepository) UpdateSecret(id uuid.UUID, secret string) error {
+	_, err := r.db.Exec(`
+		UPDATE webhook_subscriptions SET secret = $2, updated_at = NOW() WHERE id = $1
+	`, id, secret)
+	return err
+}
+
+// DeleteSubscription 删除订阅及其投递记录，订阅不存在时返回false
+func (r *WebhookRepository) DeleteSubscription(id uuid.UUID) (bool, error) {
+	result, err := r.db.Exec(`DELETE FROM webhook_subscriptions WHERE id = $1`, id)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	return affected > 0, err
+}
+
+// CreateDeliveries 批量创建投递记录
+func (r *WebhookRepository) CreateDeliveries(deliveries []*model.WebhookDelivery) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("开始事务失败: %w", err)
+	}
+	defer tx.Rollback()
+
+	for _, delivery := range deliveries {
+		_, err = tx.Exec(`
+			INSERT INTO webhook_deliveries (id, subscription_id, event_id, event_type, payload, status, attempts,
+			                                next_attempt_at, created_at, updated_at)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $9)
+		`,
+			delivery.ID,
+			delivery.SubscriptionID,
+			delivery.EventID,
+			delivery.EventType,
+			[]byte(delivery.Payload),
+			delivery.Status,
+			delivery.Attempts,
+			delivery.NextAttemptAt,
+			delivery.CreatedAt,
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// webhookDeliveryColumns 投递记录查询字段
+const webhookDeliveryColumns = `id, subscription_id, event_id, event_type, payload, status, attempts, next_attempt_at,
+		       last_status_code, last_error, delivered_at, replay_count, created_at`
+
+// scanWebhookDelivery 扫描投递记录，处理可能为NULL的字段
+func scanWebhookDelivery(row interface{ Scan(...any) error }) (*model.WebhookDelivery, error) {
+	var delivery model.WebhookDelivery
+	var payload []byte
+	var statusCode sql.NullInt64
+	var lastError sql.NullString
+	var deliveredAt sql.NullTime
+
+	err := row.Scan(
+		&delivery.ID,
+		&delivery.SubscriptionID,
+		&delivery.EventID,
+		&delivery.EventType,
+		&payload,
+		&delivery.Status,
+		&delivery.Attempts,
+		&delivery.NextAttemptAt,
+		&statusCode,
+		&lastError,
+		&deliveredAt,
+		&delivery.ReplayCount,
+		&delivery.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	delivery.Payload = payload
+	delivery.LastError = lastError.String
+	if statusCode.Valid {
+		code := int(statusCode.Int64)
+		delivery.LastStatusCode = &code
+	}
+	if deliveredAt.Valid {
+		delivery.DeliveredAt = &deliveredAt.Time
+	}
+	return &delivery, nil
+}
+
+// GetDeliveryByID 获取投递记录
+func (r *WebhookRepository) GetDeliveryByID(id uuid.UUID) (*model.WebhookDelivery, error) {
+	row := r.db.QueryRow(`SELECT `+webhookDeliveryColumns+` FROM webhook_deliveries WHERE id = $1`, id)
+	return scanWebhookDelivery(row)
+}
+
+// ClaimDueDeliveries 认领到达重试时间的待投递记录，按创建时间排序
+// 认领时把下次投递时间推迟一个租约，并跳过其他实例已锁定的记录，多实例不会重复投递同一记录；
+// 投递结果写回前实例崩溃的记录在租约到期后重新投递
+func (r *WebhookRepository) ClaimDueDeliveries(now time.Time, limit int, lease time.Duration) ([]*model.WebhookDelivery, error) {
+	rows, err := r.db.Query(`
+		WITH claimed AS (
+			UPDATE webhook_deliveries
+			SET next_attempt_at = $3
+			WHERE id IN (
+				SELECT id FROM webhook_deliveries
+				WHERE status = $1 AND next_attempt_at <= $2
+				ORDER BY created_at, id
+				LIMIT $4
+				FOR UPDATE SKIP LOCKED
+			)
+			RETURNING *
+		)
+		SELECT `+webhookDeliveryColumns+`
+		FROM claimed
+		ORDER BY created_at, id
+	`, model.WebhookDeliveryPending, now, now.Add(lease), limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var deliveries []*model.WebhookDelivery
+	for rows.Next() {
+		delivery, err := scanWebhookDelivery(rows)
+		if err != nil {
+			return nil, err
+		}
+		deliveries = append(deliveries, delivery)
+	}
+	return deliveries, rows.Err()
+}
+
+// GetDeliveries 分页查询投递记录
+func (r *WebhookRepository) GetDeliveries(filter *model.WebhookDeliveryFilter, page, pageSize int) ([]*model.WebhookDelivery, int, error) {
+	// 构建筛选条件
+	where := "WHERE 1=1"
+	var args []any
+	if filter != nil {
+		if filter.Status != "" {
+			args = append(args, filter.Status)
+			where += fmt.Sprintf(" AND status = $%d", len(args))
+		}
+		if filter.EventType != "" {
+			args = append(args, filter.EventType)
+			where += fmt.Sprintf(" AND event_type = $%d", len(args))
+		}
+		if filter.SubscriptionID != nil {
+			args = append(args, *filter.SubscriptionID)
+			where += fmt.Sprintf(" AND subscription_id = $%d", len(args))
+		}
+	}
+
+	// 获取总数
+	var total int
+	if err := r.db.QueryRow("SELECT COUNT(*) FROM webhook_deliveries "+where, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	// 分页查询
+	query := fmt.Sprintf(`
+		SELECT %s
+		FROM webhook_deliveries
+		%s
+		ORDER BY created_at DESC
+		LIMIT $%d OFFSET $%d
+	`, webhookDeliveryColumns, where, len(args)+1, len(args)+2)
+
+	rows, err := r.db.Query(query, append(args, pageSize, (page-1)*pageSize)...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	deliveries := []*model.WebhookDelivery{}
+	for rows.Next() {
+		delivery, err := scanWebhookDelivery(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		deliveries = append(deliveries, delivery)
+	}
+	return deliveries, total, rows.Err()
+}
+
+// MarkDelivered 标记投递成功
+func (r *WebhookRepository) MarkDelivered(id uuid.UUID, attempts, statusCode int) error {
+	_, err := r.db.Exec(`
+		UPDATE webhook_deliveries
+		SET status = $2, attempts = $3, last_status_code = $4, last_error = NULL, delivered_at = NOW(), updated_at = NOW()
+		WHERE id = $1
+	`, id, model.WebhookDeliveryDelivered, attempts, statusCode)
+	return err
+}
+
+// MarkRetry 记录投递失败并安排下次重试，statusCode 为0表示未收到响应
+func (r *WebhookRepository) MarkRetry(id uuid.UUID, attempts, statusCode int, nextAttemptAt time.Time, lastError string) error {
+	_, err := r.db.Exec(`
+		UPDATE webhook_deliveries
+		SET attempts = $2, last_status_code = $3, next_attempt_at = $4, last_error = $5, updated_at = NOW()
+		WHERE id = $1
+	`, id, attempts, nullInt(statusCode), nextAttemptAt, lastError)
+	return err
+}
+
+// MarkDead 重试次数用尽，投递进入死信
+func (r *WebhookRepository) MarkDead(id uuid.UUID, attempts, statusCode int, lastError string) error {
+	_, err := r.db.Exec(`
+		UPDATE webhook_deliveries
+		SET status = $2, attempts = $3, last_status_code = $4, last_error = $5, updated_at = NOW()
+		WHERE id = $1
+	`, id, model.WebhookDeliveryDead, attempts, nullInt(statusCode), lastError)
+	return err
+}
+
+// Replay 重放一条已结束（已投递或死信）的投递记录，记录不存在或仍在投递中时返回false
+func (r *WebhookRepository) Replay(id uuid.UUID) (bool, error) {
+	result, err := r.db.Exec(`
+		UPDATE webhook_deliveries
+		SET status = $2, attempts = 0, next_attempt_at = NOW(), replay_count = replay_count + 1, updated_at = NOW()
+		WHERE id = $1 AND status <> $2
+	`, id, model.WebhookDeliveryPending)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	return affected > 0, err
+}
+
+// ReplayDead 重放订阅的全部死信，返回重放的数量
+func (r *WebhookRepository) ReplayDead(subscriptionID uuid.UUID) (int64, error) {
+	result, err := r.db.Exec(`
+		UPDATE webhook_deliveries
+		SET status = $2, attempts = 0, next_attempt_at = NOW(), replay_count = replay_count + 1, updated_at = NOW()
+		WHERE subscription_id = $1 AND status = $3
+	`, subscriptionID, model.WebhookDeliveryPending, model.WebhookDeliveryDead)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// nullInt 将整数转换为查询参数，0写入NULL
+func nullInt(n int) any {
+	if n == 0 {
+		return nil
+	}
+	return n
+}
@@ -0,0 +1,37 @@
+package service
+
+import (
+	"log"
+	"time"
+
+	"backend/internal/model"
+	"backend/internal/repository"
+
+	"github.com/google/uuid"
+)
+
+// AuditService 审计日志服务
+type AuditService struct {
+	auditLogRepo *repository.AuditLogRepository
+}
+
+// NewAuditService 创建审计日志服务
+func NewAuditService(auditLogRepo *repository.AuditLogRepository) *AuditService {
+	return &AuditService{
+		auditLogRepo: auditLogRepo,
+	}
+}
+
+// Record 写入审计日志，写入失败只记录日志，不影响已执行的操作
+func (s *AuditService) Record(entry *model.AuditLog) {
+	entry.ID = uuid.New()
+	entry.CreatedAt = time.Now().UTC()
+	if err := s.auditLogRepo.Create(entry); err != nil {
+		log.Printf("写入审计日志失败: 操作=%s, 错误=%v", entry.Action, err)
+	}
+}
+
+// GetLogs 分页查询审计日志
+func (s *AuditService) GetLogs(action model.AuditAction, page, pageSize int) ([]*model.AuditLog, int, error) {
+	return s.auditLogRepo.GetLogs(action, page, pageSize)
+}
@@ -17,6 +17,12 @@ type BillingService struct {
 	sessionRepo *repository.ChargingSessionRepository
 	systemRepo  *repository.SystemRepository
 	pileRepo    *repository.ChargingPileRepository
+
+	degradationRepo     *repository.PileDegradationRepository
+	notificationService *NotificationService // 详单生成后通知用户
+	webhookService      *WebhookService      // 详单生成后推送给第三方
+	idleGracePeriod     time.Duration        // 充电结束后的免费停留时间
+	idleFeePerMinute    float64              // 超出免费停留时间后每分钟的占位费
 }
 
 // NewBillingService 创建计费服务
@@ -25,12 +31,14 @@ func NewBillingService(
 	sessionRepo *repository.ChargingSessionRepository,
 	systemRepo *repository.SystemRepository,
 	pileRepo *repository.ChargingPileRepository,
+	degradationRepo *repository.PileDegradationRepository,
 ) *BillingService {
 	return &BillingService{
-		billingRepo: billingRepo,
-		sessionRepo: sessionRepo,
-		systemRepo:  systemRepo,
-		pileRepo:    pileRepo,
+		billingRepo:     billingRepo,
+		sessionRepo:     sessionRepo,
+		systemRepo:      systemRepo,
+		pileRepo:        pileRepo,
+		degradationRepo: degradationRepo,
 	}
 }
 
@@ -47,12 +55,16 @@ func (s *BillingService) GenerateBill(sessionID uuid.UUID) (*model.BillingDetail
 		return existingBill, nil
 	}
 	// 计算充电时长（小时）
-	var chargingDuration float64
+	sessionEnd := time.Now().UTC() // 如果还没结束，使用当前时间
 	if session.EndTime != nil {
-		chargingDuration = session.EndTime.Sub(session.StartTime).Hours()
-	} else {
-		// 如果还没结束，使用当前时间
-		chargingDuration = time.Now().UTC().Sub(session.StartTime).Hours()
+		sessionEnd = *session.EndTime
+	}
+	chargingDuration := sessionEnd.Sub(session.StartTime).Hours()
+
+	// 充电期间充电桩降功率运行的时长
+	degradedDuration, err := s.degradedDuration(session.PileID, session.StartTime, sessionEnd)
+	if err != nil {
+		return nil, err
 	}
 
 	// 使用充电会话中的实际充电量（ActualCapacity）
@@ -74,6 +86,7 @@ func (s *BillingService) GenerateBill(sessionID uuid.UUID) (*model.BillingDetail
 	// 四舍五入到小数点后2位
 	chargingCapacity = math.Round(chargingCapacity*100) / 100
 	chargingDuration = math.Round(chargingDuration*100) / 100
+	degradedDuration = math.Round(degradedDuration*100) / 100
 	chargingFee = math.Round(chargingFee*100) / 100
 	serviceFee = math.Round(serviceFee*100) / 100
 	totalFee = math.Round(totalFee*100) / 100
@@ -85,6 +98,7 @@ func (s *BillingService) GenerateBill(sessionID uuid.UUID) (*model.BillingDetail
 		PileID:           session.PileID,
 		ChargingCapacity: chargingCapacity,
 		ChargingDuration: chargingDuration,
+		DegradedDuration: degradedDuration,
 		StartTime:        session.StartTime,
 		EndTime:          time.Now().UTC(), // 使用当前时间作为结束时间
 		UnitPrice:        priceRate.ElectricFee,
@@ -92,10 +106,121 @@ func (s *BillingService) GenerateBill(sessionID uuid.UUID) (*model.BillingDetail
 		ChargingFee:      chargingFee,
 		ServiceFee:       serviceFee,
 		TotalFee:         totalFee,
+		StartSoC:         session.StartSoC,
+		EndSoC:           session.CurrentSoC,
 	}
 
 	// 保存到数据库
-	return s.billingRepo.CreateBillingDetail(bill)
+	created, err := s.billingRepo.CreateBillingDetail(bill)
+	if err != nil {
+		return nil, err
+	}
+
+	if s.webhookService != nil {
+		s.webhookService.Publish(model.WebhookEventBillCreated, map[string]any{
+			"detailId":         created.ID.String(),
+			"sessionId":        created.SessionID.String(),
+			"userId":           created.UserID.String(),
+			"pileId":           created.PileID,
+			"chargingCapacity": created.ChargingCapacity,
+			"chargingDuration": created.ChargingDuration,
+			"priceType":        created.PriceType,
+			"chargingFee":      created.ChargingFee,
+			"serviceFee":       created.ServiceFee,
+			"totalFee":         created.TotalFee,
+		})
+	}
+	if s.notificationService != nil {
+		s.notificationService.Notify(created.UserID, model.NotificationBillReady, map[string]any{
+			"detailId":         created.ID.String(),
+			"sessionId":        created.SessionID.String(),
+			"pileId":           created.PileID,
+			"chargingCapacity": created.ChargingCapacity,
+			"chargingFee":      created.ChargingFee,
+			"serviceFee":       created.ServiceFee,
+			"totalFee":         created.TotalFee,
+		})
+	}
+	return created, nil
+}
+
+// SetNotificationService 设置通知服务（避免循环依赖）
+func (s *BillingService) SetNotificationService(notificationService *NotificationService) {
+	s.notificationService = notificationService
+}
+
+// SetWebhookService 设置第三方webhook推送服务（避免循环依赖）
+func (s *BillingService) SetWebhookService(webhookService *WebhookService) {
+	s.webhookService = webhookService
+}
+
+// SetIdleFee 设置占位费：充电结束后停留超过gracePeriod的部分按分钟收费
+func (s *BillingService) SetIdleFee(gracePeriod time.Duration, feePerMinute float64) {
+	s.idleGracePeriod = gracePeriod
+	s.idleFeePerMinute = feePerMinute
+}
+
+// ApplyIdleFee 车辆驶离后将超出免费停留时间的占位费单独计入详单，不足一分钟按一分钟计
+func (s *BillingService) ApplyIdleFee(session *model.ChargingSession) (*model.BillingDetail, error) {
+	bill, err := s.GenerateBill(session.ID)
+	if err != nil {
+		return nil, err
+	}
+	if session.EndTime == nil || session.DepartedAt == nil || s.idleFeePerMinute <= 0 {
+		return bill, nil
+	}
+
+	overstay := session.DepartedAt.Sub(*session.EndTime) - s.idleGracePeriod
+	if overstay <= 0 {
+		return bill, nil
+	}
+	idleMinutes := int(math.Ceil(overstay.Minutes()))
+	idleFee := math.Round(float64(idleMinutes)*s.idleFeePerMinute*100) / 100
+
+	updated, err := s.billingRepo.UpdateIdleFee(bill.ID, idleMinutes, idleFee)
+	if err != nil {
+		return nil, err
+	}
+
+	// 详单总费用变化，通知第三方与用户
+	if s.webhookService != nil {
+		s.webhookService.Publish(model.WebhookEventBillUpdated, map[string]any{
+			"detailId":    updated.ID.String(),
+			"sessionId":   updated.SessionID.String(),
+			"userId":      updated.UserID.String(),
+			"pileId":      updated.PileID,
+			"chargingFee": updated.ChargingFee,
+			"serviceFee":  updated.ServiceFee,
+			"idleMinutes": updated.IdleMinutes,
+			"idleFee":     updated.IdleFee,
+			"totalFee":    updated.TotalFee,
+		})
+	}
+	if s.notificationService != nil {
+		s.notificationService.Notify(updated.UserID, model.NotificationBillUpdated, map[string]any{
+			"detailId":    updated.ID.String(),
+			"sessionId":   updated.SessionID.String(),
+			"pileId":      updated.PileID,
+			"idleMinutes": updated.IdleMinutes,
+			"idleFee":     updated.IdleFee,
+			"totalFee":    updated.TotalFee,
+		})
+	}
+	return updated, nil
+}
+
+// degradedDuration 计算充电期间充电桩降功率运行的时长（小时）
+func (s *BillingService) degradedDuration(pileID string, startTime, endTime time.Time) (float64, error) {
+	degradations, err := s.degradationRepo.GetOverlapping(pileID, startTime, endTime)
+	if err != nil {
+		return 0, err
+	}
+
+	var hours float64
+	for _, degradation := range degradations {
+		hours += periodOverlap(degradation.StartedAt, degradation.EndedAt, startTime, endTime).Hours()
+	}
+	return hours, nil
 }
 
 // GetBillByID 通过ID获取账单
@@ -107,6 +232,15 @@ func (s *BillingService) GetBillByID(billID uuid.UUID) (*model.BillingDetail, er
 	return bill, nil
 }
 
+// GetBillBySessionID 通过充电会话获取账单
+func (s *BillingService) GetBillBySessionID(sessionID uuid.UUID) (*model.BillingDetail, error) {
+	bill, err := s.billingRepo.GetBySessionID(sessionID)
+	if err != nil {
+		return nil, errors.New("账单不存在")
+	}
+	return bill, nil
+}
+
 // GetUserBills 获取用户账单
 func (s *BillingService) GetUserBills(userID uuid.UUID, startDate, endDate *time.Time, page, pageSize int) ([]*model.BillingDetail, int, error) {
 	if page <= 0 {
@@ -118,6 +252,11 @@ func (s *BillingService) GetUserBills(userID uuid.UUID, startDate, endDate *time
 	return s.billingRepo.GetUserBillingDetails(userID, startDate, endDate, page, pageSize)
 }
 
+// GetUserTotalFee 获取用户累计消费金额
+func (s *BillingService) GetUserTotalFee(userID uuid.UUID) (float64, error) {
+	return s.billingRepo.GetUserTotalFee(userID)
+}
+
 // GetBillingStatistics 获取计费统计
 func (s *BillingService) GetBillingStatistics(startTime, endTime time.Time, pileID *string) (*model.BillingStatistics, error) {
 	// 参数验证
@@ -61,6 +61,7 @@ func (s *BootstrapService) syncSystemConfig() error {
 		"fast_charging_power":       fmt.Sprintf("%.2f", s.config.Charging.FastChargingPower),
 		"trickle_charging_power":    fmt.Sprintf("%.2f", s.config.Charging.TrickleChargingPower),
 		"service_fee_per_unit":      fmt.Sprintf("%.2f", s.config.Charging.ServiceFeePerUnit),
+		"fault_rescheduling_policy": s.initialFaultReschedulingPolicy(),
 	}
 
 	// 对每个配置项，检查是否存在，不存在则创建，存在则更新
@@ -84,7 +85,7 @@ func (s *BootstrapService) syncSystemConfig() error {
 			} else {
 				return err
 			}
-		} else {
+		} else if key != "fault_rescheduling_policy" { // 故障调度策略由管理员在system_config中修改，不随配置文件覆盖
 			// 配置项存在，检查是否需要更新
 			if config.ConfigValue != value {
 				if err := s.systemRepo.UpdateConfig(key, value); err != nil {
@@ -97,6 +98,15 @@ func (s *BootstrapService) syncSystemConfig() error {
 	return nil
 }
 
+// initialFaultReschedulingPolicy 配置文件中的故障调度策略，未配置或无效时使用优先级调度
+func (s *BootstrapService) initialFaultReschedulingPolicy() string {
+	policy := model.FaultReschedulingPolicy(s.config.Charging.FaultReschedulingPolicy)
+	if !model.IsValidFaultReschedulingPolicy(policy) {
+		policy = model.FaultPolicyPriority
+	}
+	return string(policy)
+}
+
 // localTimeToUTC 将本地时间的小时分钟转换为UTC时间字符串
 func (s *BootstrapService) localTimeToUTC(hour, minute int) string {
 	// 使用今天的日期创建本地时间
@@ -242,7 +252,8 @@ func (s *BootstrapService) ensureChargingPilesExist() error {
 }
 
 // FindOrCreatePileByID 查找或创建充电桩
-func (s *BootstrapService) FindOrCreatePileByID(pileID string, status model.PileStatus) (*model.ChargingPile, error) {
+// pileType为空或power不大于0时按ID前缀与配置推断；已存在的充电桩按传入的类型与功率更新
+func (s *BootstrapService) FindOrCreatePileByID(pileID string, status model.PileStatus, pileType model.PileType, power float64) (*model.ChargingPile, error) {
 	// 尝试查找现有充电桩
 	pile, err := s.chargingPileRepo.GetByID(pileID)
 	if err == nil {
@@ -253,19 +264,31 @@ func (s *BootstrapService) FindOrCreatePileByID(pileID string, status model.Pile
 			}
 			pile.Status = status
 		}
+		// 注册信息与记录不一致时更新类型与功率
+		if pileType != "" && power > 0 && (pile.PileType != pileType || pile.Power != power) {
+			if err := s.chargingPileRepo.UpdateSpec(pileID, pileType, power); err != nil {
+				return nil, err
+			}
+			pile.PileType = pileType
+			pile.Power = power
+		}
 		return pile, nil
 	}
 
-	// 充电桩不存在，根据ID前缀判断类型
-	var pileType model.PileType
-	var power float64
-
-	if pileID[0] == 'F' || pileID[0] == 'A' || pileID[0] == 'B' {
-		pileType = model.PileTypeFast
-		power = s.config.Charging.FastChargingPower
-	} else {
-		pileType = model.PileTypeSlow
-		power = s.config.Charging.TrickleChargingPower
+	// 充电桩不存在，未提供类型时根据ID前缀判断
+	if pileType == "" {
+		if pileID[0] == 'F' || pileID[0] == 'A' || pileID[0] == 'B' {
+			pileType = model.PileTypeFast
+		} else {
+			pileType = model.PileTypeSlow
+		}
+	}
+	if power <= 0 {
+		if pileType == model.PileTypeFast {
+			power = s.config.Charging.FastChargingPower
+		} else {
+			power = s.config.Charging.TrickleChargingPower
+		}
 	}
 
 	// 创建新充电桩
@@ -300,6 +323,7 @@ func getConfigDescription(key string) string {
 		"fast_charging_power":       "快充功率(度/小时)",
 		"trickle_charging_power":    "慢充功率(度/小时)",
 		"service_fee_per_unit":      "服务费率(元/度)",
+		"fault_rescheduling_policy": "故障调度策略(priority/time_order)",
 	}
 
 	if desc, ok := descriptions[key]; ok {
@@ -2,6 +2,8 @@ package service
 
 import (
 	"errors"
+	"fmt"
+	"log"
 	"time"
 
 	"backend/internal/model"
@@ -14,6 +16,12 @@ type ChargingPileService struct {
 	sysRepo   *repository.SystemRepository
 	userRepo  *repository.UserRepository
 	queueRepo *repository.QueueRepository
+
+	degradationRepo *repository.PileDegradationRepository
+	credentialRepo  *repository.OCPPCredentialRepository // OCPP充电桩连接凭据
+
+	availability  AvailabilityController // 充电桩可用性控制（OCPP）
+	ticketService *FaultTicketService    // 故障工单
 }
 
 // NewChargingPileService 创建充电桩服务
@@ -22,15 +30,62 @@ func NewChargingPileService(
 	sysRepo *repository.SystemRepository,
 	userRepo *repository.UserRepository,
 	queueRepo *repository.QueueRepository,
+	degradationRepo *repository.PileDegradationRepository,
+	credentialRepo *repository.OCPPCredentialRepository,
 ) *ChargingPileService {
 	return &ChargingPileService{
-		pileRepo:  pileRepo,
-		sysRepo:   sysRepo,
-		userRepo:  userRepo,
-		queueRepo: queueRepo,
+		pileRepo:        pileRepo,
+		sysRepo:         sysRepo,
+		userRepo:        userRepo,
+		queueRepo:       queueRepo,
+		degradationRepo: degradationRepo,
+		credentialRepo:  credentialRepo,
 	}
 }
 
+// OCPP 1.6 安全配置1 的 AuthorizationKey 长度范围
+const (
+	minOCPPPasswordLength = 16
+	maxOCPPPasswordLength = 40
+)
+
+// ErrInvalidOCPPPassword OCPP连接密码不符合要求
+var ErrInvalidOCPPPassword = fmt.Errorf("OCPP连接密码长度须为%d到%d个字符", minOCPPPasswordLength, maxOCPPPasswordLength)
+
+// SetOCPPPassword 设置充电桩的OCPP连接密码
+func (s *ChargingPileService) SetOCPPPassword(pileID, password string) error {
+	if len(password) < minOCPPPasswordLength || len(password) > maxOCPPPasswordLength {
+		return ErrInvalidOCPPPassword
+	}
+	if _, err := s.pileRepo.GetByID(pileID); err != nil {
+		return errors.New("充电桩不存在")
+	}
+	return s.credentialRepo.SetPassword(pileID, password)
+}
+
+// AuthenticateChargePoint 校验OCPP充电桩连接的用户名（须为充电桩ID）与密码
+func (s *ChargingPileService) AuthenticateChargePoint(pileID, username, password string) bool {
+	if username != pileID {
+		return false
+	}
+	ok, err := s.credentialRepo.VerifyPassword(pileID, password)
+	if err != nil {
+		log.Printf("校验充电桩 %s 连接凭据失败: %v", pileID, err)
+		return false
+	}
+	return ok
+}
+
+// SetAvailabilityController 设置充电桩可用性控制
+func (s *ChargingPileService) SetAvailabilityController(controller AvailabilityController) {
+	s.availability = controller
+}
+
+// SetFaultTicketService 设置故障工单服务
+func (s *ChargingPileService) SetFaultTicketService(ticketService *FaultTicketService) {
+	s.ticketService = ticketService
+}
+
 // GetAllPiles 获取所有充电桩
 func (s *ChargingPileService) GetAllPiles() ([]*model.ChargingPile, error) {
 	return s.pileRepo.GetAll()
@@ -67,7 +122,20 @@ func (s *ChargingPileService) UpdatePileStatus(id string, status model.PileStatu
 		return errors.New("无效的充电桩状态")
 	}
 
-	return s.pileRepo.UpdateStatus(id, status)
+	if err := s.pileRepo.UpdateStatus(id, status); err != nil {
+		return err
+	}
+
+	// 同步充电桩硬件可用性
+	if s.availability != nil {
+		available := status == model.PileStatusAvailable
+		if status == model.PileStatusAvailable || status == model.PileStatusOffline || status == model.PileStatusMaintenance {
+			if err := s.availability.ChangeAvailability(id, available); err != nil {
+				log.Printf("同步充电桩 %s 可用性失败: %v", id, err)
+			}
+		}
+	}
+	return nil
 }
 
 // ReportPileFault 报告充电桩故障
@@ -89,6 +157,13 @@ func (s *ChargingPileService) ReportPileFault(id string, faultType string, descr
 		return err
 	}
 
+	// 降功率运行的充电桩完全故障时结束降功率时段
+	if pile.IsDegraded() {
+		if _, err := s.ClearPileDegradation(id); err != nil {
+			log.Printf("结束充电桩 %s 降功率运行失败: %v", id, err)
+		}
+	}
+
 	// 创建故障记录
 	faultRecord := &model.FaultRecord{
 		PileID:      id,
@@ -98,11 +173,84 @@ func (s *ChargingPileService) ReportPileFault(id string, faultType string, descr
 		Status:      "active",
 	}
 
-	return s.sysRepo.CreateFaultRecord(faultRecord)
+	if err := s.sysRepo.CreateFaultRecord(faultRecord); err != nil {
+		return err
+	}
+
+	// 为故障开维修工单
+	if s.ticketService != nil {
+		if _, err := s.ticketService.OpenTicket(faultRecord); err != nil {
+			log.Printf("%v", err)
+		}
+	}
+	return nil
 }
 
-// RepairPile 维修充电桩
-func (s *ChargingPileService) RepairPile(id string) error {
+// ReportPileDegradation 报告充电桩降功率运行，充电桩继续以降低后的功率服务
+// 已在降功率运行时更新为新的功率并开始新的时段
+func (s *ChargingPileService) ReportPileDegradation(id string, faultType string, description string, power float64) error {
+	pile, err := s.pileRepo.GetByID(id)
+	if err != nil {
+		return errors.New("充电桩不存在")
+	}
+
+	if pile.Status == model.PileStatusFault {
+		return errors.New("充电桩已处于故障状态")
+	}
+	if power <= 0 || power >= pile.Power {
+		return fmt.Errorf("降功率运行的功率须大于0且小于额定功率 %.2f", pile.Power)
+	}
+
+	now := time.Now().UTC()
+	if pile.IsDegraded() {
+		if err := s.degradationRepo.EndActive(id, now); err != nil {
+			return err
+		}
+	}
+
+	degradation := &model.PileDegradation{
+		PileID:         id,
+		FaultType:      model.FaultType(faultType),
+		Description:    description,
+		RatedPower:     pile.Power,
+		EffectivePower: power,
+		StartedAt:      now,
+	}
+	if err := s.degradationRepo.Create(degradation); err != nil {
+		return err
+	}
+	if err := s.pileRepo.UpdateDegradedPower(id, &power); err != nil {
+		return err
+	}
+
+	log.Printf("充电桩 %s 降功率运行: %.2f -> %.2f, 原因: %s", id, pile.Power, power, description)
+	return nil
+}
+
+// ClearPileDegradation 结束充电桩降功率运行，恢复额定功率；充电桩未降功率运行时返回false
+func (s *ChargingPileService) ClearPileDegradation(id string) (bool, error) {
+	pile, err := s.pileRepo.GetByID(id)
+	if err != nil {
+		return false, errors.New("充电桩不存在")
+	}
+	if !pile.IsDegraded() {
+		return false, nil
+	}
+
+	if err := s.degradationRepo.EndActive(id, time.Now().UTC()); err != nil {
+		return false, err
+	}
+	if err := s.pileRepo.UpdateDegradedPower(id, nil); err != nil {
+		return false, err
+	}
+
+	log.Printf("充电桩 %s 恢复额定功率 %.2f", id, pile.Power)
+	return true, nil
+}
+
+// RepairPile 维修充电桩，关闭活跃故障记录并保存恢复说明
+// recoveryNotes 为空时使用故障工单修复时填写的恢复说明
+func (s *ChargingPileService) RepairPile(id string, recoveryNotes string) error {
 	// 验证充电桩存在
 	pile, err := s.pileRepo.GetByID(id)
 	if err != nil {
@@ -130,9 +278,18 @@ func (s *ChargingPileService) RepairPile(id string) error {
 		return err
 	}
 
+	if recoveryNotes == "" && s.ticketService != nil {
+		ticket, err := s.ticketService.GetByFaultID(faultRecord.ID)
+		if err != nil {
+			log.Printf("获取故障工单失败: %v", err)
+		} else if ticket != nil {
+			recoveryNotes = ticket.RecoveryNotes
+		}
+	}
+
 	// 更新故障记录
 	now := time.Now().UTC()
-	return s.sysRepo.UpdateFaultRecord(faultRecord.ID, now, 0) // 影响的会话数可以从调度服务获取
+	return s.sysRepo.UpdateFaultRecord(faultRecord.ID, now, 0, recoveryNotes) // 影响的会话数可以从调度服务获取
 }
 
 // UpdateQueueLength 更新充电桩队列长度
@@ -3,9 +3,11 @@ package service
 import (
 	"errors"
 	"fmt"
+	"log"
 	"sync"
 	"time"
 
+	"backend/internal/config"
 	"backend/internal/model"
 	"backend/internal/repository"
 
@@ -18,10 +20,20 @@ type ChargingRequestService struct {
 	queueRepo       *repository.QueueRepository
 	pileRepo        *repository.ChargingPileRepository
 	systemRepo      *repository.SystemRepository
+	userRepo        *repository.UserRepository
+	vehicleRepo     *repository.VehicleRepository
+	queueNumberRepo *repository.QueueNumberRepository
 	schedulerSvc    *SchedulerService
-	fastQueueNumber int // 快充队列号计数器
-	slowQueueNumber int // 慢充队列号计数器
-	mutex           *sync.Mutex
+	webhookService  *WebhookService // 新请求推送给第三方
+	stationID       string          // 队列号序列所属站点
+	queueResetAt    int             // 队列号每日重置时间（当日第几分钟）
+	queueLocation   *time.Location  // 队列号重置时间所在时区
+
+	notificationService *NotificationService // 候补入场与超时通知用户
+	waitlistEnabled     bool                 // 等候区已满时是否进入候补队列
+	waitlistMaxSize     int                  // 候补队列容量，0表示不限
+	arrivalWindow       time.Duration        // 获得入场资格后到场签到的时限
+	mutex               *sync.Mutex          // 串行化候补入场
 }
 
 // NewChargingRequestService 创建充电请求服务
@@ -30,21 +42,64 @@ func NewChargingRequestService(
 	queueRepo *repository.QueueRepository,
 	pileRepo *repository.ChargingPileRepository,
 	systemRepo *repository.SystemRepository,
-) *ChargingRequestService {
+	userRepo *repository.UserRepository,
+	vehicleRepo *repository.VehicleRepository,
+	queueNumberRepo *repository.QueueNumberRepository,
+	cfg config.QueueNumberConfig,
+) (*ChargingRequestService, error) {
 	svc := &ChargingRequestService{
 		requestRepo:     requestRepo,
 		queueRepo:       queueRepo,
 		pileRepo:        pileRepo,
 		systemRepo:      systemRepo,
-		fastQueueNumber: 0,
-		slowQueueNumber: 0,
+		userRepo:        userRepo,
+		vehicleRepo:     vehicleRepo,
+		queueNumberRepo: queueNumberRepo,
+		stationID:       cfg.StationID,
+		queueLocation:   time.Local,
 		mutex:           &sync.Mutex{},
 	}
+	if svc.stationID == "" {
+		svc.stationID = "default"
+	}
+	if len(cfg.ResetTime) != 2 {
+		return nil, fmt.Errorf("队列号重置时间须为 [小时, 分钟]，当前为 %v", cfg.ResetTime)
+	}
+	hour, minute := cfg.ResetTime[0], cfg.ResetTime[1]
+	if hour < 0 || hour > 23 || minute < 0 || minute > 59 {
+		return nil, fmt.Errorf("队列号重置时间 %02d:%02d 超出范围", hour, minute)
+	}
+	svc.queueResetAt = hour*60 + minute
+	if cfg.Timezone != "" {
+		loc, err := time.LoadLocation(cfg.Timezone)
+		if err != nil {
+			return nil, fmt.Errorf("队列号时区 %s 无效: %w", cfg.Timezone, err)
+		}
+		svc.queueLocation = loc
+	}
 
-	// 初始化队列号
-	svc.initQueueNumbers()
+	return svc, nil
+}
+
+// BackfillQueueDates 为升级前取号、缺少营业日的请求按当前的重置时间与时区回填营业日
+func (s *ChargingRequestService) BackfillQueueDates() error {
+	createdAt, err := s.requestRepo.GetMissingQueueDates()
+	if err != nil {
+		return fmt.Errorf("获取缺少营业日的请求失败: %w", err)
+	}
+	if len(createdAt) == 0 {
+		return nil
+	}
 
-	return svc
+	dates := make(map[uuid.UUID]time.Time, len(createdAt))
+	for id, at := range createdAt {
+		dates[id] = s.queueBusinessDate(at)
+	}
+	if err := s.requestRepo.SetQueueDates(dates); err != nil {
+		return fmt.Errorf("回填队列号营业日失败: %w", err)
+	}
+	log.Printf("已回填 %d 个历史请求的队列号营业日", len(dates))
+	return nil
 }
 
 // SetSchedulerService 设置调度服务（避免循环依赖）
@@ -52,44 +107,53 @@ func (s *ChargingRequestService) SetSchedulerService(schedulerSvc *SchedulerServ
 	s.schedulerSvc = schedulerSvc
 }
 
-// initQueueNumbers 初始化队列号
-func (s *ChargingRequestService) initQueueNumbers() {
-	// 从数据库获取最大队列号
-	fastRequests, err := s.requestRepo.GetWaitingRequestsByMode(model.ChargingModeFast)
-	if err == nil && len(fastRequests) > 0 {
-		for _, req := range fastRequests {
-			if req.QueueNumber != "" {
-				// 从队列号（如 F10）中提取数字部分
-				var num int
-				_, err := fmt.Sscanf(req.QueueNumber, "F%d", &num)
-				if err == nil && num > s.fastQueueNumber {
-					s.fastQueueNumber = num
-				}
-			}
-		}
+// SetWebhookService 设置第三方webhook推送服务（避免循环依赖）
+func (s *ChargingRequestService) SetWebhookService(webhookService *WebhookService) {
+	s.webhookService = webhookService
+}
+
+// CreateRequest 创建充电请求
+func (s *ChargingRequestService) CreateRequest(userID uuid.UUID, req *model.ChargingRequestCreate) (*model.ChargingRequest, error) {
+	// 检查账户状态
+	user, err := s.userRepo.GetByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	if user.IsDisabled() {
+		return nil, errors.New("账户已被禁用")
 	}
 
-	slowRequests, err := s.requestRepo.GetWaitingRequestsByMode(model.ChargingModeSlow)
-	if err == nil && len(slowRequests) > 0 {
-		for _, req := range slowRequests {
-			if req.QueueNumber != "" {
-				// 从队列号（如 T10）中提取数字部分
-				var num int
-				_, err := fmt.Sscanf(req.QueueNumber, "T%d", &num)
-				if err == nil && num > s.slowQueueNumber {
-					s.slowQueueNumber = num
-				}
-			}
+	// 确定充电车辆
+	vehicle, err := s.resolveVehicle(userID, req.VehicleID)
+	if err != nil {
+		return nil, err
+	}
+
+	// 校验充电模式与请求充电量
+	if req.ChargingMode == "" {
+		req.ChargingMode = vehicle.DefaultMode
+	}
+	if !vehicle.SupportsMode(req.ChargingMode) {
+		return nil, errors.New("该车辆不支持所选充电模式")
+	}
+	if req.HasSoCTarget() {
+		// 按SoC目标由电池容量推算充电量
+		if err := model.ValidateSoCRange(*req.StartSoC, *req.TargetSoC); err != nil {
+			return nil, err
 		}
+		req.RequestedCapacity = model.CapacityForSoC(vehicle.BatteryCapacity, *req.StartSoC, *req.TargetSoC)
+	}
+	if req.RequestedCapacity <= 0 {
+		return nil, errors.New("请提供请求充电量或起始/目标电量百分比")
+	}
+	if req.RequestedCapacity > vehicle.BatteryCapacity {
+		return nil, fmt.Errorf("请求充电量不能超过车辆电池容量(%.2f度)", vehicle.BatteryCapacity)
 	}
-}
 
-// CreateRequest 创建充电请求
-func (s *ChargingRequestService) CreateRequest(userID uuid.UUID, req *model.ChargingRequestCreate) (*model.ChargingRequest, error) {
-	// 检查用户是否已有活跃请求
-	activeReq, err := s.requestRepo.GetActiveRequestByUserID(userID)
+	// 检查车辆是否已有活跃请求
+	activeReq, err := s.requestRepo.GetActiveRequestByVehicleID(vehicle.ID)
 	if err == nil && activeReq != nil {
-		return nil, errors.New("用户已有活跃的充电请求")
+		return nil, errors.New("该车辆已有活跃的充电请求")
 	}
 
 	// 检查等候区是否已满
@@ -104,24 +168,35 @@ func (s *ChargingRequestService) CreateRequest(userID uuid.UUID, req *model.Char
 		return nil, err
 	}
 
-	if count >= config.WaitingAreaSize {
-		return nil, errors.New("等候区已满，请稍后再试")
+	// 等候区已满时进入候补队列
+	waitlist, err := s.shouldWaitlist(count, config.WaitingAreaSize)
+	if err != nil {
+		return nil, err
 	}
 
-	// 生成队列号
-	queueNumber := s.generateQueueNumber(req.ChargingMode)
-
 	// 创建充电请求
 	chargingReq := &model.ChargingRequest{
 		ID:                uuid.New(),
 		UserID:            userID,
+		VehicleID:         vehicle.ID,
 		ChargingMode:      req.ChargingMode,
 		RequestedCapacity: req.RequestedCapacity,
-		QueueNumber:       queueNumber,
 		Status:            model.RequestStatusWaiting,
+		StartSoC:          req.StartSoC,
+		TargetSoC:         req.TargetSoC,
 		CreatedAt:         time.Now().UTC(),
 		UpdatedAt:         time.Now().UTC(),
 	}
+	if waitlist {
+		// 候补请求到场签到时才领取排队号
+		chargingReq.Status = model.RequestStatusWaitlisted
+	} else {
+		// 生成队列号
+		chargingReq.QueueNumber, chargingReq.QueueDate, err = s.generateQueueNumber(req.ChargingMode)
+		if err != nil {
+			return nil, err
+		}
+	}
 
 	// 保存到数据库
 	createdReq, err := s.requestRepo.Create(chargingReq)
@@ -129,12 +204,50 @@ func (s *ChargingRequestService) CreateRequest(userID uuid.UUID, req *model.Char
 		return nil, err
 	}
 
+	if s.webhookService != nil {
+		s.webhookService.Publish(model.WebhookEventRequestCreated, map[string]any{
+			"requestId":         createdReq.ID.String(),
+			"userId":            createdReq.UserID.String(),
+			"vehicleId":         createdReq.VehicleID.String(),
+			"queueNumber":       createdReq.QueueNumber,
+			"chargingMode":      createdReq.ChargingMode,
+			"requestedCapacity": createdReq.RequestedCapacity,
+			"createdAt":         createdReq.CreatedAt,
+		})
+	}
+
+	if waitlist {
+		// 等候区可能已有空位
+		go s.AdmitFromWaitlist()
+		return createdReq, nil
+	}
+
 	// 尝试进行调度
 	go s.schedulerSvc.TryScheduleRequests()
 
 	return createdReq, nil
 }
 
+// resolveVehicle 确定本次请求使用的车辆，未指定时仅在用户只有一辆车时自动选择
+func (s *ChargingRequestService) resolveVehicle(userID, vehicleID uuid.UUID) (*model.Vehicle, error) {
+	if vehicleID != uuid.Nil {
+		return findUserVehicle(s.vehicleRepo, userID, vehicleID)
+	}
+
+	vehicles, err := s.vehicleRepo.GetByUserID(userID)
+	if err != nil {
+		return nil, err
+	}
+	switch len(vehicles) {
+	case 0:
+		return nil, errors.New("请先登记车辆")
+	case 1:
+		return vehicles[0], nil
+	default:
+		return nil, errors.New("请选择充电车辆")
+	}
+}
+
 // UpdateRequest 更新充电请求
 func (s *ChargingRequestService) UpdateRequest(userID uuid.UUID, requestID uuid.UUID, req *model.ChargingRequestUpdate) (*model.ChargingRequest, error) {
 	// 获取请求
@@ -149,16 +262,48 @@ func (s *ChargingRequestService) UpdateRequest(userID uuid.UUID, requestID uuid.
 	}
 
 	// 检查请求状态
-	if currentReq.Status != model.RequestStatusWaiting {
-		return nil, errors.New("只有等候区的请求可以修改")
+	if currentReq.Status != model.RequestStatusWaiting && currentReq.Status != model.RequestStatusWaitlisted {
+		return nil, errors.New("只有等候区或候补队列的请求可以修改")
+	}
+
+	// 按车辆参数校验修改内容
+	if currentReq.VehicleID != uuid.Nil {
+		vehicle, err := s.vehicleRepo.GetByID(currentReq.VehicleID)
+		if err != nil {
+			return nil, err
+		}
+		if req.ChargingMode != "" && !vehicle.SupportsMode(req.ChargingMode) {
+			return nil, errors.New("该车辆不支持所选充电模式")
+		}
+		if req.TargetSoC != nil {
+			// 修改目标SoC时重新推算充电量
+			if currentReq.StartSoC == nil {
+				return nil, errors.New("该请求未记录起始电量，无法按目标电量修改")
+			}
+			if err := model.ValidateSoCRange(*currentReq.StartSoC, *req.TargetSoC); err != nil {
+				return nil, err
+			}
+			req.RequestedCapacity = model.CapacityForSoC(vehicle.BatteryCapacity, *currentReq.StartSoC, *req.TargetSoC)
+			currentReq.TargetSoC = req.TargetSoC
+		} else if req.RequestedCapacity > 0 {
+			// 直接修改充电量后原目标SoC不再适用
+			currentReq.TargetSoC = nil
+		}
+		if req.RequestedCapacity > vehicle.BatteryCapacity {
+			return nil, fmt.Errorf("请求充电量不能超过车辆电池容量(%.2f度)", vehicle.BatteryCapacity)
+		}
 	}
 
 	// 更新充电模式
 	if req.ChargingMode != "" && req.ChargingMode != currentReq.ChargingMode {
-		// 生成新队列号
-		queueNumber := s.generateQueueNumber(req.ChargingMode)
 		currentReq.ChargingMode = req.ChargingMode
-		currentReq.QueueNumber = queueNumber
+		// 等候区的请求生成新队列号，候补请求签到时才领取
+		if currentReq.Status == model.RequestStatusWaiting {
+			currentReq.QueueNumber, currentReq.QueueDate, err = s.generateQueueNumber(req.ChargingMode)
+			if err != nil {
+				return nil, err
+			}
+		}
 	}
 
 	// 更新请求充电量
@@ -173,6 +318,9 @@ func (s *ChargingRequestService) UpdateRequest(userID uuid.UUID, requestID uuid.
 	if err != nil {
 		return nil, err
 	}
+	if currentReq.Status == model.RequestStatusWaitlisted {
+		return currentReq, nil
+	}
 
 	// 尝试进行调度
 	go s.schedulerSvc.TryScheduleRequests()
@@ -195,9 +343,13 @@ func (s *ChargingRequestService) CancelRequest(userID uuid.UUID, requestID uuid.
 
 	// 根据请求状态执行不同处理
 	switch req.Status {
-	case model.RequestStatusWaiting:
-		// 等候区：直接标记为已取消
-		return s.requestRepo.UpdateRequestStatus(requestID, model.RequestStatusCancelled)
+	case model.RequestStatusWaitlisted, model.RequestStatusWaiting:
+		// 候补队列或等候区：直接标记为已取消，空出的位置让给下一位候补请求
+		if err := s.requestRepo.UpdateRequestStatus(requestID, model.RequestStatusCancelled); err != nil {
+			return err
+		}
+		go s.AdmitFromWaitlist()
+		return nil
 
 	case model.RequestStatusQueued:
 		// 充电区排队中：从队列移除，并触发重新调度
@@ -237,6 +389,21 @@ func (s *ChargingRequestService) CancelRequest(userID uuid.UUID, requestID uuid.
 	}
 }
 
+// CheckOut 用户确认车辆已驶离充电桩，返回占位时长与占位费
+func (s *ChargingRequestService) CheckOut(userID uuid.UUID, requestID uuid.UUID) (*model.VehicleDeparture, error) {
+	req, err := s.requestRepo.GetByID(requestID)
+	if err != nil {
+		return nil, err
+	}
+
+	// 检查请求所有者
+	if req.UserID != userID {
+		return nil, errors.New("无权操作该请求")
+	}
+
+	return s.schedulerSvc.CheckOut(requestID)
+}
+
 // GetActiveRequestByUserID 获取用户的活跃请求
 func (s *ChargingRequestService) GetActiveRequestByUserID(userID uuid.UUID) (*model.ChargingRequest, error) {
 	return s.requestRepo.GetActiveRequestByUserID(userID)
@@ -252,18 +419,40 @@ func (s *ChargingRequestService) GetRequestByID(requestID uuid.UUID) (*model.Cha
 	return s.requestRepo.GetByID(requestID)
 }
 
-// generateQueueNumber 生成队列号
-func (s *ChargingRequestService) generateQueueNumber(mode model.ChargingMode) string {
-	s.mutex.Lock()
-	defer s.mutex.Unlock()
-
+// generateQueueNumber 从数据库序列生成队列号，跳过仍被未结束请求占用的号码
+func (s *ChargingRequestService) generateQueueNumber(mode model.ChargingMode) (string, time.Time, error) {
+	prefix := "T"
 	if mode == model.ChargingModeFast {
-		s.fastQueueNumber++
-		return fmt.Sprintf("F%d", s.fastQueueNumber)
-	} else {
-		s.slowQueueNumber++
-		return fmt.Sprintf("T%d", s.slowQueueNumber)
+		prefix = "F"
+	}
+	businessDate := s.queueBusinessDate(time.Now())
+
+	for {
+		seq, err := s.queueNumberRepo.Next(s.stationID, mode, businessDate)
+		if err != nil {
+			return "", time.Time{}, fmt.Errorf("生成队列号失败: %w", err)
+		}
+		queueNumber := fmt.Sprintf("%s%d", prefix, seq)
+
+		// 跨营业日仍在排队或充电的请求可能持有相同号码
+		active, err := s.requestRepo.IsQueueNumberActive(queueNumber)
+		if err != nil {
+			return "", time.Time{}, fmt.Errorf("生成队列号失败: %w", err)
+		}
+		if !active {
+			return queueNumber, businessDate, nil
+		}
+	}
+}
+
+// queueBusinessDate 取号时间所属营业日：早于当日重置时间的取号计入前一营业日
+func (s *ChargingRequestService) queueBusinessDate(at time.Time) time.Time {
+	local := at.In(s.queueLocation)
+	date := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, time.UTC)
+	if local.Hour()*60+local.Minute() < s.queueResetAt {
+		date = date.AddDate(0, 0, -1)
 	}
+	return date
 }
 
 // GetQueueStatus 获取排队状态
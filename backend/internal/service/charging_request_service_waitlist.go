@@ -0,0 +1,267 @@
+package service
+
+import (
+	"errors"
+	"log"
+	"time"
+
+	"backend/internal/config"
+	"backend/internal/model"
+
+	"github.com/google/uuid"
+)
+
+const (
+	defaultArrivalWindow         = 15 * time.Minute // 获得入场资格后默认的到场时限
+	defaultWaitlistCheckInterval = 30 * time.Second // 默认的到场超时检查间隔
+)
+
+// 候补队列错误
+var (
+	// ErrWaitlistFull 等候区与候补队列均已满
+	ErrWaitlistFull = errors.New("等候区与候补队列均已满，请稍后再试")
+	// ErrNotAdmitted 候补请求尚未获得入场资格
+	ErrNotAdmitted = errors.New("候补请求尚未获得入场资格")
+	// ErrArrivalExpired 超过到场截止时间，候补资格已失效
+	ErrArrivalExpired = errors.New("已超过到场截止时间，候补资格已失效")
+)
+
+// EnableWaitlist 启用候补队列：等候区已满时请求进入候补队列，空出位置后按顺序通知到场
+func (s *ChargingRequestService) EnableWaitlist(cfg config.WaitlistConfig) {
+	s.waitlistEnabled = true
+	s.waitlistMaxSize = cfg.MaxSize
+	s.arrivalWindow = time.Duration(cfg.ArrivalWindow) * time.Second
+	if s.arrivalWindow <= 0 {
+		s.arrivalWindow = defaultArrivalWindow
+	}
+	checkInterval := time.Duration(cfg.CheckInterval) * time.Second
+	if checkInterval <= 0 {
+		checkInterval = defaultWaitlistCheckInterval
+	}
+
+	go s.waitlistLoop(checkInterval)
+}
+
+// SetNotificationService 设置通知服务（避免循环依赖）
+func (s *ChargingRequestService) SetNotificationService(notificationService *NotificationService) {
+	s.notificationService = notificationService
+}
+
+// shouldWaitlist 新请求是否进入候补队列
+// 为已获得入场资格的候补请求保留的位置不可用；已有请求候补时新请求排在其后，不越过候补队列
+func (s *ChargingRequestService) shouldWaitlist(waitingCount, waitingAreaSize int) (bool, error) {
+	if !s.waitlistEnabled {
+		if waitingCount >= waitingAreaSize {
+			return false, errors.New("等候区已满，请稍后再试")
+		}
+		return false, nil
+	}
+
+	waitlisted, admitted, err := s.requestRepo.CountWaitlist()
+	if err != nil {
+		return false, err
+	}
+	if waitingCount+admitted < waitingAreaSize && waitlisted == 0 {
+		return false, nil
+	}
+	if s.waitlistMaxSize > 0 && waitlisted >= s.waitlistMaxSize {
+		return false, ErrWaitlistFull
+	}
+	return true, nil
+}
+
+// freeWaitingPlaces 等候区空位数：容量减去等候区车辆与为候补请求保留的位置
+func (s *ChargingRequestService) freeWaitingPlaces() (int, error) {
+	config, err := s.systemRepo.GetSchedulingConfig()
+	if err != nil {
+		return 0, err
+	}
+	waiting, err := s.requestRepo.CountWaitingRequests()
+	if err != nil {
+		return 0, err
+	}
+	_, admitted, err := s.requestRepo.CountWaitlist()
+	if err != nil {
+		return 0, err
+	}
+	return config.WaitingAreaSize - waiting - admitted, nil
+}
+
+// AdmitFromWaitlist 等候区有空位时按候补顺序让请求获得入场资格，并通知用户在时限内到场签到
+func (s *ChargingRequestService) AdmitFromWaitlist() {
+	if !s.waitlistEnabled {
+		return
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	free, err := s.freeWaitingPlaces()
+	if err != nil {
+		log.Printf("获取等候区空位失败: %v", err)
+		return
+	}
+	if free <= 0 {
+		return
+	}
+
+	requests, err := s.requestRepo.GetWaitlistedRequests()
+	if err != nil {
+		log.Printf("获取候补队列失败: %v", err)
+		return
+	}
+	for _, req := range requests {
+		if free <= 0 {
+			break
+		}
+		if req.IsAdmitted() {
+			continue
+		}
+
+		deadline := time.Now().UTC().Add(s.arrivalWindow)
+		admitted, err := s.requestRepo.AdmitFromWaitlist(req.ID, deadline)
+		if err != nil {
+			log.Printf("候补请求 %s 入场失败: %v", req.ID, err)
+			return
+		}
+		if !admitted {
+			// 已被取消或已由其他实例处理
+			continue
+		}
+		free--
+
+		log.Printf("候补请求获得入场资格: 请求=%s, 到场截止=%s", req.ID, deadline.Format(time.RFC3339))
+		if s.notificationService != nil {
+			s.notificationService.Notify(req.UserID, model.NotificationWaitlistAdmitted, map[string]any{
+				"requestId":       req.ID.String(),
+				"chargingMode":    req.ChargingMode,
+				"arrivalDeadline": deadline,
+				"arrivalMinutes":  int(s.arrivalWindow.Minutes()),
+			})
+		}
+	}
+}
+
+// waitlistLoop 定期取消超时未到场的候补请求，并让下一位候补请求入场
+func (s *ChargingRequestService) waitlistLoop(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		s.expireArrivals()
+		s.AdmitFromWaitlist()
+		<-ticker.C
+	}
+}
+
+// expireArrivals 取消超过到场截止时间仍未签到的候补请求
+func (s *ChargingRequestService) expireArrivals() {
+	expired, err := s.requestRepo.ExpireArrivals(time.Now().UTC())
+	if err != nil {
+		log.Printf("取消超时未到场的候补请求失败: %v", err)
+		return
+	}
+
+	for _, req := range expired {
+		log.Printf("候补请求超时未到场，已取消: 请求=%s", req.ID)
+		if s.notificationService != nil {
+			s.notificationService.Notify(req.UserID, model.NotificationWaitlistExpired, map[string]any{
+				"requestId":       req.ID.String(),
+				"chargingMode":    req.ChargingMode,
+				"arrivalDeadline": req.ArrivalDeadline,
+			})
+		}
+	}
+}
+
+// CheckIn 已获得入场资格的用户到场签到，领取排队号进入等候区
+func (s *ChargingRequestService) CheckIn(userID uuid.UUID, requestID uuid.UUID) (*model.ChargingRequest, error) {
+	req, err := s.requestRepo.GetByID(requestID)
+	if err != nil {
+		return nil, err
+	}
+
+	// 检查请求所有者
+	if req.UserID != userID {
+		return nil, errors.New("无权操作该请求")
+	}
+	if !req.IsAdmitted() {
+		return nil, ErrNotAdmitted
+	}
+	now := time.Now().UTC()
+	if now.After(*req.ArrivalDeadline) {
+		return nil, ErrArrivalExpired
+	}
+
+	// 按到场签到的先后领取排队号
+	queueNumber, queueDate, err := s.generateQueueNumber(req.ChargingMode)
+	if err != nil {
+		return nil, err
+	}
+	checkedIn, err := s.requestRepo.CheckIn(requestID, queueNumber, queueDate, now)
+	if err != nil {
+		return nil, err
+	}
+	if !checkedIn {
+		return nil, ErrArrivalExpired
+	}
+	req.Status = model.RequestStatusWaiting
+	req.QueueNumber = queueNumber
+	req.QueueDate = queueDate
+	req.UpdatedAt = now
+
+	// 尝试进行调度
+	go s.schedulerSvc.TryScheduleRequests()
+
+	return req, nil
+}
+
+// GetWaitlistStatus 获取候补请求的候补位置与预计入场时间
+func (s *ChargingRequestService) GetWaitlistStatus(request *model.ChargingRequest) (*model.WaitlistStatus, error) {
+	status := &model.WaitlistStatus{
+		RequestID:       request.ID,
+		ArrivalDeadline: request.ArrivalDeadline,
+	}
+
+	requests, err := s.requestRepo.GetWaitlistedRequests()
+	if err != nil {
+		return nil, err
+	}
+	for _, req := range requests {
+		if req.IsAdmitted() {
+			continue
+		}
+		status.WaitlistLength++
+		if req.ID == request.ID {
+			status.Position = status.WaitlistLength
+		}
+	}
+	if status.Position == 0 {
+		return status, nil
+	}
+
+	free, err := s.freeWaitingPlaces()
+	if err != nil {
+		return nil, err
+	}
+	status.EstimatedAdmissionTime = s.estimateAdmissionWait(status.Position - max(free, 0))
+	return status, nil
+}
+
+// estimateAdmissionWait 估算还需空出n个等候区位置才能入场的等待时间（秒）
+// 超出当前各充电桩队列的部分按平均空出间隔外推
+func (s *ChargingRequestService) estimateAdmissionWait(n int) int {
+	if n <= 0 {
+		return 0
+	}
+	releases := s.schedulerSvc.EstimateWaitingAreaReleases()
+	if len(releases) == 0 {
+		return 0
+	}
+	if n <= len(releases) {
+		return int(releases[n-1])
+	}
+	last := releases[len(releases)-1]
+	interval := last / float64(len(releases))
+	return int(last + float64(n-len(releases))*interval)
+}
@@ -0,0 +1,227 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"backend/internal/config"
+	"backend/internal/model"
+	"backend/internal/repository"
+
+	"github.com/google/uuid"
+)
+
+// 指令队列默认参数
+const (
+	defaultCommandAckTimeout = 60 * time.Second
+	defaultCommandMaxBackoff = 15 * time.Second
+	commandPollInterval      = time.Second
+	commandBatchSize         = 100
+	stuckCommandWindow       = 24 * time.Hour // 管理端展示的转故障指令时间范围
+)
+
+// EscalationHandler 指令超时未确认时的故障处理
+type EscalationHandler func(pileID, description string) error
+
+// CommandQueueService 充电指令队列
+// 实现 ChargingDispatcher：启停指令先落库再异步投递，失败按指数退避重试，
+// 同一充电桩的指令按创建顺序投递，超时未确认的指令转充电桩故障处理
+type CommandQueueService struct {
+	commandRepo  *repository.PileCommandRepository
+	dispatcher   ChargingDispatcher // 实际下发指令（模拟器HTTP或OCPP）
+	onEscalate   EscalationHandler
+	ackTimeout   time.Duration
+	worker       *deliveryWorker[*model.PileCommand]
+	mu           sync.Mutex
+	dispatcherMu sync.RWMutex
+}
+
+// NewCommandQueueService 创建充电指令队列
+func NewCommandQueueService(commandRepo *repository.PileCommandRepository, dispatcher ChargingDispatcher, cfg config.DispatchConfig) *CommandQueueService {
+	q := &CommandQueueService{
+		commandRepo: commandRepo,
+		dispatcher:  dispatcher,
+		ackTimeout:  time.Duration(cfg.AckTimeout) * time.Second,
+	}
+	if q.ackTimeout <= 0 {
+		q.ackTimeout = defaultCommandAckTimeout
+	}
+	maxBackoff := time.Duration(cfg.MaxBackoff) * time.Second
+	if maxBackoff <= 0 {
+		maxBackoff = defaultCommandMaxBackoff
+	}
+	// 同一充电桩同时只投递一条指令，投递结束后立即认领该桩的下一条
+	q.worker = newDeliveryWorker[*model.PileCommand](q, deliveryWorkerConfig{
+		name:         "充电指令",
+		pollInterval: commandPollInterval,
+		batchSize:    commandBatchSize,
+		maxBackoff:   maxBackoff,
+		wakeOnDone:   true,
+	})
+
+	// 启动投递循环，继续投递重启前未确认的指令
+	q.worker.start()
+
+	return q
+}
+
+// SetDispatcher 设置实际下发指令的客户端
+func (q *CommandQueueService) SetDispatcher(dispatcher ChargingDispatcher) {
+	q.dispatcherMu.Lock()
+	defer q.dispatcherMu.Unlock()
+	q.dispatcher = dispatcher
+}
+
+// SetEscalationHandler 设置超时转故障处理（避免循环依赖）
+func (q *CommandQueueService) SetEscalationHandler(handler EscalationHandler) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.onEscalate = handler
+}
+
+// AssignCharging 分配充电指令入队
+func (q *CommandQueueService) AssignCharging(req ChargingAssignRequest) error {
+	return q.enqueue(req.PileID, req.SessionID, model.PileCommandAssign, req)
+}
+
+// StopCharging 停止充电指令入队
+func (q *CommandQueueService) StopCharging(req ChargingStopRequest) error {
+	return q.enqueue(req.PileID, req.SessionID, model.PileCommandStop, req)
+}
+
+// enqueue 指令落库并唤醒投递循环
+func (q *CommandQueueService) enqueue(pileID, sessionID string, commandType model.PileCommandType, payload any) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("序列化指令失败: %w", err)
+	}
+
+	now := time.Now().UTC()
+	command := &model.PileCommand{
+		ID:            uuid.New(),
+		PileID:        pileID,
+		CommandType:   commandType,
+		Payload:       data,
+		Status:        model.PileCommandStatusPending,
+		NextAttemptAt: now,
+		CreatedAt:     now,
+	}
+	if id, err := uuid.Parse(sessionID); err == nil {
+		command.SessionID = &id
+	}
+
+	if err := q.commandRepo.Create(command); err != nil {
+		return fmt.Errorf("保存指令失败: %w", err)
+	}
+
+	q.worker.wake()
+	return nil
+}
+
+// HasPendingCommands 充电桩是否有尚未确认的指令
+func (q *CommandQueueService) HasPendingCommands(pileID string) (bool, error) {
+	return q.commandRepo.HasPending(pileID)
+}
+
+// GetStuckCommands 获取仍在重试以及近期超时转故障处理的指令
+func (q *CommandQueueService) GetStuckCommands() ([]*model.PileCommand, error) {
+	return q.commandRepo.GetStuck(time.Now().UTC().Add(-stuckCommandWindow))
+}
+
+// claimDue 认领到期的指令，每个充电桩只认领最早的一条
+func (q *CommandQueueService) claimDue(now time.Time, limit int, lease time.Duration) ([]*model.PileCommand, error) {
+	return q.commandRepo.ClaimDue(now, limit, lease)
+}
+
+// deliveryKey 按充电桩区分，同一充电桩的指令依次投递
+func (q *CommandQueueService) deliveryKey(command *model.PileCommand) string {
+	return command.PileID
+}
+
+// deliveryAttempts 已尝试次数
+func (q *CommandQueueService) deliveryAttempts(command *model.PileCommand) int {
+	return command.Attempts
+}
+
+// describe 日志中的指令描述
+func (q *CommandQueueService) describe(command *model.PileCommand) string {
+	return fmt.Sprintf("指令=%s, 类型=%s, 充电桩=%s", command.ID, command.CommandType, command.PileID)
+}
+
+// exhausted 超时未确认后不再重试，转充电桩故障处理
+func (q *CommandQueueService) exhausted(command *model.PileCommand, _ int) bool {
+	return time.Since(command.CreatedAt) >= q.ackTimeout
+}
+
+// markSucceeded 记录指令已确认
+func (q *CommandQueueService) markSucceeded(command *model.PileCommand, attempts, _ int) error {
+	if err := q.commandRepo.MarkAcknowledged(command.ID, attempts); err != nil {
+		return err
+	}
+	log.Printf("充电指令已确认: 指令=%s, 类型=%s, 充电桩=%s, 尝试次数=%d",
+		command.ID, command.CommandType, command.PileID, attempts)
+	return nil
+}
+
+// markRetry 记录投递失败，等待重试
+func (q *CommandQueueService) markRetry(command *model.PileCommand, attempts, _ int, nextAttemptAt time.Time, cause error) error {
+	return q.commandRepo.MarkRetry(command.ID, attempts, nextAttemptAt, cause.Error())
+}
+
+// send 解析指令并通过实际客户端下发，指令ID随请求下发供模拟器去重
+func (q *CommandQueueService) send(command *model.PileCommand) (int, error) {
+	return 0, q.dispatch(command)
+}
+
+// dispatch 按指令类型调用实际客户端
+func (q *CommandQueueService) dispatch(command *model.PileCommand) error {
+	q.dispatcherMu.RLock()
+	dispatcher := q.dispatcher
+	q.dispatcherMu.RUnlock()
+	if dispatcher == nil {
+		return fmt.Errorf("模拟器客户端未配置")
+	}
+
+	switch command.CommandType {
+	case model.PileCommandAssign:
+		var req ChargingAssignRequest
+		if err := json.Unmarshal(command.Payload, &req); err != nil {
+			return fmt.Errorf("解析指令失败: %w", err)
+		}
+		req.CommandID = command.ID.String()
+		return dispatcher.AssignCharging(req)
+	case model.PileCommandStop:
+		var req ChargingStopRequest
+		if err := json.Unmarshal(command.Payload, &req); err != nil {
+			return fmt.Errorf("解析指令失败: %w", err)
+		}
+		req.CommandID = command.ID.String()
+		return dispatcher.StopCharging(req)
+	default:
+		return fmt.Errorf("未知的指令类型: %s", command.CommandType)
+	}
+}
+
+// markAbandoned 指令超时未确认，标记并交由故障处理
+func (q *CommandQueueService) markAbandoned(command *model.PileCommand, attempts, _ int, cause error) error {
+	description := fmt.Sprintf("充电指令超时未确认: 指令=%s, 类型=%s, 尝试%d次, 最后错误: %v",
+		command.ID, command.CommandType, attempts, cause)
+
+	if err := q.commandRepo.MarkEscalated(command.ID, attempts, cause.Error()); err != nil {
+		return err
+	}
+
+	q.mu.Lock()
+	onEscalate := q.onEscalate
+	q.mu.Unlock()
+	if onEscalate == nil {
+		return nil
+	}
+	if err := onEscalate(command.PileID, description); err != nil {
+		log.Printf("指令超时转故障处理失败: 充电桩=%s, 错误=%v", command.PileID, err)
+	}
+	return nil
+}
@@ -0,0 +1,156 @@
+package service
+
+import (
+	"log"
+	"sync"
+	"time"
+)
+
+// deliveryLease 认领记录的租约，超过该时间仍未记录结果（如实例崩溃）的记录可被其他实例重新认领
+const deliveryLease = 2 * time.Minute
+
+// deliveryQueue 重试投递队列，由各业务服务适配自己的仓库与发送方式
+type deliveryQueue[T any] interface {
+	// claimDue 认领已到投递时间的记录，租约期内其他实例不会再取到这些记录
+	claimDue(now time.Time, limit int, lease time.Duration) ([]T, error)
+	// deliveryKey 同一键的记录同时只投递一条
+	deliveryKey(item T) string
+	// deliveryAttempts 记录已尝试的次数
+	deliveryAttempts(item T) int
+	// describe 日志中的记录描述
+	describe(item T) string
+	// send 发送一条记录，返回对方的响应状态码（不适用或未收到响应时为0）
+	send(item T) (int, error)
+	// exhausted 本次失败后是否不再重试
+	exhausted(item T, attempts int) bool
+	// markSucceeded 记录投递成功
+	markSucceeded(item T, attempts, statusCode int) error
+	// markRetry 记录投递失败，等待重试
+	markRetry(item T, attempts, statusCode int, nextAttemptAt time.Time, cause error) error
+	// markAbandoned 记录投递失败且不再重试
+	markAbandoned(item T, attempts, statusCode int, cause error) error
+}
+
+// deliveryWorkerConfig 投递循环参数
+type deliveryWorkerConfig struct {
+	name         string        // 日志中的队列名称
+	pollInterval time.Duration // 轮询间隔
+	batchSize    int           // 每次认领的记录数
+	maxBackoff   time.Duration // 重试间隔上限
+	wakeOnDone   bool          // 一条记录投递结束后立即再次认领（用于同一键的后续记录）
+}
+
+// deliveryWorker 重试投递循环
+// 轮询或被唤醒时认领到期记录并异步发送，失败按指数退避重试，重试用尽后交由队列处理
+type deliveryWorker[T any] struct {
+	queue    deliveryQueue[T]
+	cfg      deliveryWorkerConfig
+	inFlight map[string]bool // 正在投递的键
+	wakeCh   chan struct{}
+	mu       sync.Mutex
+}
+
+// newDeliveryWorker 创建投递循环，调用 start 后开始投递
+func newDeliveryWorker[T any](queue deliveryQueue[T], cfg deliveryWorkerConfig) *deliveryWorker[T] {
+	return &deliveryWorker[T]{
+		queue:    queue,
+		cfg:      cfg,
+		inFlight: make(map[string]bool),
+		wakeCh:   make(chan struct{}, 1),
+	}
+}
+
+// start 启动投递循环，继续投递重启前未完成的记录
+func (w *deliveryWorker[T]) start() {
+	go w.loop()
+}
+
+// wake 唤醒投递循环
+func (w *deliveryWorker[T]) wake() {
+	select {
+	case w.wakeCh <- struct{}{}:
+	default:
+	}
+}
+
+// loop 投递循环
+func (w *deliveryWorker[T]) loop() {
+	ticker := time.NewTicker(w.cfg.pollInterval)
+	defer ticker.Stop()
+
+	for {
+		w.dispatch()
+		select {
+		case <-ticker.C:
+		case <-w.wakeCh:
+		}
+	}
+}
+
+// dispatch 认领并投递到达重试时间的记录
+func (w *deliveryWorker[T]) dispatch() {
+	items, err := w.queue.claimDue(time.Now().UTC(), w.cfg.batchSize, deliveryLease)
+	if err != nil {
+		log.Printf("获取待投递%s失败: %v", w.cfg.name, err)
+		return
+	}
+
+	for _, item := range items {
+		key := w.queue.deliveryKey(item)
+		w.mu.Lock()
+		busy := w.inFlight[key]
+		if !busy {
+			w.inFlight[key] = true
+		}
+		w.mu.Unlock()
+		if busy {
+			continue
+		}
+
+		go w.deliver(key, item)
+	}
+}
+
+// deliver 投递一条记录并记录结果
+func (w *deliveryWorker[T]) deliver(key string, item T) {
+	defer func() {
+		w.mu.Lock()
+		delete(w.inFlight, key)
+		w.mu.Unlock()
+		if w.cfg.wakeOnDone {
+			w.wake()
+		}
+	}()
+
+	attempts := w.queue.deliveryAttempts(item) + 1
+	statusCode, err := w.queue.send(item)
+	if err == nil {
+		if err := w.queue.markSucceeded(item, attempts, statusCode); err != nil {
+			log.Printf("更新%s投递状态失败: %s, 错误=%v", w.cfg.name, w.queue.describe(item), err)
+		}
+		return
+	}
+
+	if w.queue.exhausted(item, attempts) {
+		log.Printf("%s投递失败，不再重试: %s, 尝试%d次, 错误=%v", w.cfg.name, w.queue.describe(item), attempts, err)
+		if err := w.queue.markAbandoned(item, attempts, statusCode, err); err != nil {
+			log.Printf("更新%s投递状态失败: %s, 错误=%v", w.cfg.name, w.queue.describe(item), err)
+		}
+		return
+	}
+
+	backoff := deliveryBackoff(attempts, w.cfg.maxBackoff)
+	log.Printf("%s投递失败: %s, 第%d次, %s后重试, 错误=%v", w.cfg.name, w.queue.describe(item), attempts, backoff, err)
+	if err := w.queue.markRetry(item, attempts, statusCode, time.Now().UTC().Add(backoff), err); err != nil {
+		log.Printf("更新%s投递状态失败: %s, 错误=%v", w.cfg.name, w.queue.describe(item), err)
+	}
+}
+
+// deliveryBackoff 第n次失败后的重试间隔：从1秒起每次翻倍，不超过上限
+func deliveryBackoff(attempts int, maxBackoff time.Duration) time.Duration {
+	backoff := time.Second
+	for i := 1; i < attempts && backoff < maxBackoff; i++ {
+		backoff *= 2
+	}
+	return min(backoff, maxBackoff)
+}
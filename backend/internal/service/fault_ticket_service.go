@@ -0,0 +1,294 @@
+package service
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"backend/internal/config"
+	"backend/internal/model"
+	"backend/internal/repository"
+
+	"github.com/google/uuid"
+)
+
+// 故障工单默认SLA
+const (
+	defaultTicketAckSLA     = 15 * time.Minute
+	defaultTicketResolveSLA = 4 * time.Hour
+)
+
+// 故障工单错误
+var (
+	// ErrInvalidTicketOperation 工单当前状态不允许该操作或参数无效
+	ErrInvalidTicketOperation = errors.New("无效的工单操作")
+	// ErrTicketNotFound 工单不存在
+	ErrTicketNotFound = errors.New("工单不存在")
+	// ErrTicketNotResolved 充电桩仍有未修复的故障工单，不能恢复
+	ErrTicketNotResolved = errors.New("故障工单尚未修复")
+)
+
+// FaultTicketService 故障工单服务
+// 每次故障开一张工单，维修技术员受理、维修、修复后由他人验收；工单修复前充电桩不能恢复服务
+type FaultTicketService struct {
+	ticketRepo *repository.FaultTicketRepository
+	userRepo   *repository.UserRepository
+	ackSLA     time.Duration
+	resolveSLA time.Duration
+}
+
+// NewFaultTicketService 创建故障工单服务
+func NewFaultTicketService(ticketRepo *repository.FaultTicketRepository, userRepo *repository.UserRepository, cfg config.FaultTicketConfig) *FaultTicketService {
+	svc := &FaultTicketService{
+		ticketRepo: ticketRepo,
+		userRepo:   userRepo,
+		ackSLA:     time.Duration(cfg.AckSLA) * time.Second,
+		resolveSLA: time.Duration(cfg.ResolveSLA) * time.Second,
+	}
+	if svc.ackSLA <= 0 {
+		svc.ackSLA = defaultTicketAckSLA
+	}
+	if svc.resolveSLA <= 0 {
+		svc.resolveSLA = defaultTicketResolveSLA
+	}
+	return svc
+}
+
+// OpenTicket 为故障记录开工单，SLA从故障发生时开始计时
+func (s *FaultTicketService) OpenTicket(fault *model.FaultRecord) (*model.FaultTicket, error) {
+	now := time.Now().UTC()
+	ticket := &model.FaultTicket{
+		ID:           uuid.New(),
+		FaultID:      fault.ID,
+		PileID:       fault.PileID,
+		Status:       model.FaultTicketStatusOpen,
+		AckDueAt:     fault.OccurredAt.Add(s.ackSLA),
+		ResolveDueAt: fault.OccurredAt.Add(s.resolveSLA),
+		CreatedAt:    now,
+		UpdatedAt:    now,
+	}
+	if err := s.ticketRepo.Create(ticket); err != nil {
+		return nil, fmt.Errorf("创建故障工单失败: %w", err)
+	}
+
+	log.Printf("故障工单已创建: 工单=%s, 充电桩=%s, 故障类型=%s", ticket.ID, fault.PileID, fault.FaultType)
+	return ticket, nil
+}
+
+// GetTickets 分页查询故障工单
+func (s *FaultTicketService) GetTickets(filter *model.FaultTicketFilter, page, pageSize int) ([]*model.FaultTicket, int, error) {
+	now := time.Now().UTC()
+	tickets, total, err := s.ticketRepo.GetTickets(filter, now, page, pageSize)
+	if err != nil {
+		return nil, 0, err
+	}
+	for _, ticket := range tickets {
+		ticket.UpdateSLA(now)
+	}
+	return tickets, total, nil
+}
+
+// GetTicket 获取工单详情及全部记录
+func (s *FaultTicketService) GetTicket(id uuid.UUID) (*model.FaultTicket, error) {
+	ticket, err := s.getTicket(id)
+	if err != nil {
+		return nil, err
+	}
+
+	entries, err := s.ticketRepo.GetEntries(id)
+	if err != nil {
+		return nil, fmt.Errorf("获取工单记录失败: %w", err)
+	}
+	ticket.Entries = entries
+	return ticket, nil
+}
+
+// GetByFaultID 获取故障记录对应的工单，没有工单时返回nil
+func (s *FaultTicketService) GetByFaultID(faultID uuid.UUID) (*model.FaultTicket, error) {
+	ticket, err := s.ticketRepo.GetByFaultID(faultID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	return ticket, err
+}
+
+// Assign 将工单指派给维修技术员
+func (s *FaultTicketService) Assign(operatorID, ticketID, assigneeID uuid.UUID) (*model.FaultTicket, error) {
+	ticket, err := s.getTicket(ticketID)
+	if err != nil {
+		return nil, err
+	}
+	if ticket.Status == model.FaultTicketStatusVerified {
+		return nil, fmt.Errorf("%w: 工单已验收", ErrInvalidTicketOperation)
+	}
+
+	assignee, err := s.userRepo.GetByID(assigneeID)
+	if err != nil {
+		return nil, fmt.Errorf("%w: 用户不存在", ErrInvalidTicketOperation)
+	}
+	if !model.HasPermission(assignee.UserType, model.PermFaultRepair) {
+		return nil, fmt.Errorf("%w: 用户 %s 没有维修权限", ErrInvalidTicketOperation, assignee.Username)
+	}
+
+	ticket.AssigneeID = &assigneeID
+	if err := s.ticketRepo.Update(ticket); err != nil {
+		return nil, fmt.Errorf("更新工单失败: %w", err)
+	}
+	s.addEntry(ticket.ID, model.FaultTicketEntryAssign, &operatorID, "指派给 "+assignee.Username)
+	return ticket, nil
+}
+
+// Transition 流转工单状态；修复时须填写恢复说明
+func (s *FaultTicketService) Transition(operatorID, ticketID uuid.UUID, to model.FaultTicketStatus, notes string) (*model.FaultTicket, error) {
+	ticket, err := s.getTicket(ticketID)
+	if err != nil {
+		return nil, err
+	}
+	if !ticket.Status.CanTransitionTo(to) {
+		return nil, fmt.Errorf("%w: 工单不能从 %s 变为 %s", ErrInvalidTicketOperation, ticket.Status, to)
+	}
+
+	now := time.Now().UTC()
+	switch to {
+	case model.FaultTicketStatusAcknowledged:
+		ticket.AcknowledgedAt = &now
+		// 受理时未指派则指派给受理人
+		if ticket.AssigneeID == nil {
+			ticket.AssigneeID = &operatorID
+		}
+	case model.FaultTicketStatusInRepair:
+		// 验收不通过退回维修时保留首次开始维修的时间
+		if ticket.RepairStartedAt == nil {
+			ticket.RepairStartedAt = &now
+		}
+		ticket.ResolvedAt = nil
+	case model.FaultTicketStatusResolved:
+		if notes == "" {
+			return nil, fmt.Errorf("%w: 修复时须填写恢复说明", ErrInvalidTicketOperation)
+		}
+		ticket.ResolvedAt = &now
+		ticket.RecoveryNotes = notes
+	case model.FaultTicketStatusVerified:
+		ticket.VerifiedAt = &now
+	}
+
+	from := ticket.Status
+	ticket.Status = to
+	if err := s.ticketRepo.Update(ticket); err != nil {
+		return nil, fmt.Errorf("更新工单失败: %w", err)
+	}
+
+	content := fmt.Sprintf("%s -> %s", from, to)
+	if notes != "" {
+		content += ": " + notes
+	}
+	s.addEntry(ticket.ID, model.FaultTicketEntryStatus, &operatorID, content)
+	ticket.UpdateSLA(now)
+	return ticket, nil
+}
+
+// AddNote 添加工单备注
+func (s *FaultTicketService) AddNote(authorID, ticketID uuid.UUID, content string) (*model.FaultTicketEntry, error) {
+	if content == "" {
+		return nil, fmt.Errorf("%w: 备注不能为空", ErrInvalidTicketOperation)
+	}
+	if _, err := s.getTicket(ticketID); err != nil {
+		return nil, err
+	}
+
+	entry := &model.FaultTicketEntry{
+		ID:        uuid.New(),
+		TicketID:  ticketID,
+		EntryType: model.FaultTicketEntryNote,
+		AuthorID:  &authorID,
+		Content:   content,
+		CreatedAt: time.Now().UTC(),
+	}
+	if err := s.ticketRepo.AddEntry(entry); err != nil {
+		return nil, fmt.Errorf("添加工单备注失败: %w", err)
+	}
+	return entry, nil
+}
+
+// AddPart 登记维修使用的配件
+func (s *FaultTicketService) AddPart(authorID, ticketID uuid.UUID, partName string, quantity int, note string) (*model.FaultTicketEntry, error) {
+	if partName == "" || quantity <= 0 {
+		return nil, fmt.Errorf("%w: 配件名称不能为空且数量必须大于0", ErrInvalidTicketOperation)
+	}
+	ticket, err := s.getTicket(ticketID)
+	if err != nil {
+		return nil, err
+	}
+	if ticket.Status == model.FaultTicketStatusVerified {
+		return nil, fmt.Errorf("%w: 工单已验收", ErrInvalidTicketOperation)
+	}
+
+	entry := &model.FaultTicketEntry{
+		ID:        uuid.New(),
+		TicketID:  ticketID,
+		EntryType: model.FaultTicketEntryPart,
+		AuthorID:  &authorID,
+		Content:   note,
+		PartName:  partName,
+		Quantity:  quantity,
+		CreatedAt: time.Now().UTC(),
+	}
+	if err := s.ticketRepo.AddEntry(entry); err != nil {
+		return nil, fmt.Errorf("登记配件失败: %w", err)
+	}
+	return entry, nil
+}
+
+// CheckRecoverable 充电桩的故障工单都已修复时才允许恢复
+func (s *FaultTicketService) CheckRecoverable(pileID string) error {
+	tickets, err := s.ticketRepo.GetUnrepairedByPile(pileID)
+	if err != nil {
+		return fmt.Errorf("获取故障工单失败: %w", err)
+	}
+	if len(tickets) > 0 {
+		return fmt.Errorf("%w: 充电桩 %s 的工单 %s 状态为 %s", ErrTicketNotResolved, pileID, tickets[0].ID, tickets[0].Status)
+	}
+	return nil
+}
+
+// RecordOverride 在未修复的工单上记录强制恢复
+func (s *FaultTicketService) RecordOverride(operatorID uuid.UUID, pileID, reason string) {
+	tickets, err := s.ticketRepo.GetUnrepairedByPile(pileID)
+	if err != nil {
+		log.Printf("获取故障工单失败: %v", err)
+		return
+	}
+	for _, ticket := range tickets {
+		s.addEntry(ticket.ID, model.FaultTicketEntryOverride, &operatorID, "未修复时强制恢复充电桩: "+reason)
+	}
+}
+
+// getTicket 获取工单并计算SLA
+func (s *FaultTicketService) getTicket(id uuid.UUID) (*model.FaultTicket, error) {
+	ticket, err := s.ticketRepo.GetByID(id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrTicketNotFound
+		}
+		return nil, fmt.Errorf("获取工单失败: %w", err)
+	}
+	ticket.UpdateSLA(time.Now().UTC())
+	return ticket, nil
+}
+
+// addEntry 添加工单记录，失败只记录日志
+func (s *FaultTicketService) addEntry(ticketID uuid.UUID, entryType model.FaultTicketEntryType, authorID *uuid.UUID, content string) {
+	entry := &model.FaultTicketEntry{
+		ID:        uuid.New(),
+		TicketID:  ticketID,
+		EntryType: entryType,
+		AuthorID:  authorID,
+		Content:   content,
+		CreatedAt: time.Now().UTC(),
+	}
+	if err := s.ticketRepo.AddEntry(entry); err != nil {
+		log.Printf("添加工单记录失败: 工单=%s, 错误=%v", ticketID, err)
+	}
+}
@@ -0,0 +1,247 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"backend/internal/config"
+	"backend/internal/model"
+	"backend/internal/repository"
+
+	"github.com/google/uuid"
+)
+
+// 计划维护默认参数
+const (
+	defaultMaintenanceLeadTime      = time.Hour
+	defaultMaintenanceCheckInterval = 30 * time.Second
+)
+
+// ErrInvalidMaintenanceWindow 维护窗口无效
+var ErrInvalidMaintenanceWindow = errors.New("无效的维护窗口")
+
+// MaintenanceService 计划维护服务
+// 维护开始前的提前期内不再向充电桩分配会与维护重叠的车辆，并把此类排队车辆按故障调度转移；
+// 维护开始时充电桩进入维护状态，结束后自动恢复服务
+type MaintenanceService struct {
+	maintenanceRepo     *repository.MaintenanceRepository
+	pileRepo            *repository.ChargingPileRepository
+	chargingPileService *ChargingPileService
+	schedulerService    *SchedulerService
+	leadTime            time.Duration
+	checkInterval       time.Duration
+	mu                  sync.Mutex // 串行推进维护窗口，避免重复进入维护
+}
+
+// NewMaintenanceService 创建计划维护服务
+func NewMaintenanceService(
+	maintenanceRepo *repository.MaintenanceRepository,
+	pileRepo *repository.ChargingPileRepository,
+	chargingPileService *ChargingPileService,
+	schedulerService *SchedulerService,
+	cfg config.MaintenanceConfig,
+) *MaintenanceService {
+	svc := &MaintenanceService{
+		maintenanceRepo:     maintenanceRepo,
+		pileRepo:            pileRepo,
+		chargingPileService: chargingPileService,
+		schedulerService:    schedulerService,
+		leadTime:            time.Duration(cfg.LeadTime) * time.Second,
+		checkInterval:       time.Duration(cfg.CheckInterval) * time.Second,
+	}
+	if svc.leadTime <= 0 {
+		svc.leadTime = defaultMaintenanceLeadTime
+	}
+	if svc.checkInterval <= 0 {
+		svc.checkInterval = defaultMaintenanceCheckInterval
+	}
+
+	// 调度器据此跳过完成时间与维护重叠的充电桩
+	schedulerService.SetMaintenanceRepository(maintenanceRepo, svc.leadTime)
+
+	// 启动检查循环，继续处理重启前未结束的维护窗口
+	go svc.checkLoop()
+
+	return svc
+}
+
+// ScheduleWindow 为充电桩计划一个维护窗口
+func (s *MaintenanceService) ScheduleWindow(operatorID uuid.UUID, pileID string, start, end time.Time, reason string) (*model.MaintenanceWindow, error) {
+	start, end = start.UTC(), end.UTC()
+	if !end.After(start) {
+		return nil, fmt.Errorf("%w: 结束时间必须晚于开始时间", ErrInvalidMaintenanceWindow)
+	}
+	if !end.After(time.Now().UTC()) {
+		return nil, fmt.Errorf("%w: 结束时间已过", ErrInvalidMaintenanceWindow)
+	}
+	if _, err := s.pileRepo.GetByID(pileID); err != nil {
+		return nil, fmt.Errorf("%w: 充电桩 %s 不存在", ErrInvalidMaintenanceWindow, pileID)
+	}
+
+	overlap, err := s.maintenanceRepo.HasOverlap(pileID, start, end)
+	if err != nil {
+		return nil, fmt.Errorf("检查维护窗口失败: %w", err)
+	}
+	if overlap {
+		return nil, fmt.Errorf("%w: 充电桩 %s 在该时间段已有维护计划", ErrInvalidMaintenanceWindow, pileID)
+	}
+
+	now := time.Now().UTC()
+	window := &model.MaintenanceWindow{
+		ID:        uuid.New(),
+		PileID:    pileID,
+		StartTime: start,
+		EndTime:   end,
+		Reason:    reason,
+		Status:    model.MaintenanceStatusScheduled,
+		CreatedBy: &operatorID,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if err := s.maintenanceRepo.Create(window); err != nil {
+		return nil, fmt.Errorf("创建维护窗口失败: %w", err)
+	}
+
+	log.Printf("计划维护: 充电桩=%s, 开始=%s, 结束=%s", pileID, start.Format(time.RFC3339), end.Format(time.RFC3339))
+
+	// 已在提前期内的窗口立即处理
+	go s.checkWindows()
+	return window, nil
+}
+
+// CancelWindow 取消维护窗口，维护中的充电桩立即恢复服务
+func (s *MaintenanceService) CancelWindow(id uuid.UUID) (*model.MaintenanceWindow, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	window, err := s.maintenanceRepo.GetByID(id)
+	if err != nil {
+		return nil, fmt.Errorf("%w: 维护窗口不存在", ErrInvalidMaintenanceWindow)
+	}
+	if window.IsFinished() {
+		return nil, fmt.Errorf("%w: 维护窗口已%s", ErrInvalidMaintenanceWindow, window.Status)
+	}
+
+	if err := s.maintenanceRepo.UpdateStatus(id, model.MaintenanceStatusCancelled); err != nil {
+		return nil, fmt.Errorf("更新维护窗口失败: %w", err)
+	}
+	if window.Status == model.MaintenanceStatusActive {
+		s.returnToService(window)
+	}
+	window.Status = model.MaintenanceStatusCancelled
+
+	log.Printf("取消计划维护: 充电桩=%s, 窗口=%s", window.PileID, id)
+	// 提前期内被拒绝的车辆可以重新分配到该充电桩
+	go s.schedulerService.TryScheduleRequests()
+	return window, nil
+}
+
+// GetWindows 查询维护窗口
+func (s *MaintenanceService) GetWindows(pileID string, pendingOnly bool) ([]*model.MaintenanceWindow, error) {
+	return s.maintenanceRepo.GetWindows(pileID, pendingOnly)
+}
+
+// checkLoop 定期检查维护窗口
+func (s *MaintenanceService) checkLoop() {
+	ticker := time.NewTicker(s.checkInterval)
+	defer ticker.Stop()
+
+	for {
+		s.checkWindows()
+		<-ticker.C
+	}
+}
+
+// checkWindows 按当前时间推进每个未结束的维护窗口
+func (s *MaintenanceService) checkWindows() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	windows, err := s.maintenanceRepo.GetPending()
+	if err != nil {
+		log.Printf("获取维护窗口失败: %v", err)
+		return
+	}
+
+	now := time.Now().UTC()
+	for _, window := range windows {
+		switch {
+		case !now.Before(window.EndTime):
+			s.finishWindow(window)
+		case !now.Before(window.StartTime):
+			if window.Status != model.MaintenanceStatusActive {
+				s.activateWindow(window)
+			}
+		case !now.Before(window.StartTime.Add(-s.leadTime)):
+			// 提前期内每次检查都重新转移，实际充电时长超出预计时后面的车辆同样会被转移
+			s.drainWindow(window)
+		}
+	}
+}
+
+// drainWindow 转移预计完成时间与维护重叠的排队车辆
+func (s *MaintenanceService) drainWindow(window *model.MaintenanceWindow) {
+	drained, err := s.schedulerService.DrainPileForMaintenance(window.PileID, window.StartTime)
+	if err != nil {
+		log.Printf("维护前转移排队车辆失败: 充电桩=%s, 错误=%v", window.PileID, err)
+		return
+	}
+
+	if window.Status == model.MaintenanceStatusScheduled {
+		if err := s.maintenanceRepo.UpdateStatus(window.ID, model.MaintenanceStatusDraining); err != nil {
+			log.Printf("更新维护窗口状态失败: %v", err)
+		}
+		log.Printf("充电桩 %s 进入维护提前期，转移 %d 辆排队车辆", window.PileID, drained)
+	}
+}
+
+// activateWindow 维护开始，充电桩进入维护状态
+func (s *MaintenanceService) activateWindow(window *model.MaintenanceWindow) {
+	if err := s.schedulerService.EnterMaintenance(window.PileID, window.Reason); err != nil {
+		log.Printf("充电桩 %s 进入维护失败: %v", window.PileID, err)
+	} else if err := s.chargingPileService.UpdatePileStatus(window.PileID, model.PileStatusMaintenance); err != nil {
+		// 同步充电桩硬件可用性
+		log.Printf("同步充电桩 %s 维护状态失败: %v", window.PileID, err)
+	}
+
+	if err := s.maintenanceRepo.UpdateStatus(window.ID, model.MaintenanceStatusActive); err != nil {
+		log.Printf("更新维护窗口状态失败: %v", err)
+	}
+}
+
+// finishWindow 维护结束，充电桩自动恢复服务
+func (s *MaintenanceService) finishWindow(window *model.MaintenanceWindow) {
+	if window.Status == model.MaintenanceStatusActive {
+		s.returnToService(window)
+	}
+
+	if err := s.maintenanceRepo.UpdateStatus(window.ID, model.MaintenanceStatusCompleted); err != nil {
+		log.Printf("更新维护窗口状态失败: %v", err)
+		return
+	}
+	log.Printf("计划维护结束: 充电桩=%s", window.PileID)
+}
+
+// returnToService 仍处于维护状态的充电桩恢复服务，期间转为故障或被手动调整的充电桩保持原状态
+func (s *MaintenanceService) returnToService(window *model.MaintenanceWindow) {
+	pile, err := s.pileRepo.GetByID(window.PileID)
+	if err != nil {
+		log.Printf("获取充电桩 %s 失败: %v", window.PileID, err)
+		return
+	}
+	if pile.Status != model.PileStatusMaintenance {
+		log.Printf("充电桩 %s 当前状态为 %s，维护结束后不自动恢复", window.PileID, pile.Status)
+		return
+	}
+
+	if err := s.chargingPileService.UpdatePileStatus(window.PileID, model.PileStatusAvailable); err != nil {
+		log.Printf("恢复充电桩 %s 状态失败: %v", window.PileID, err)
+		return
+	}
+	if err := s.schedulerService.HandlePileRecovery(window.PileID); err != nil {
+		log.Printf("充电桩 %s 恢复调度失败: %v", window.PileID, err)
+	}
+}
@@ -0,0 +1,149 @@
+package service
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"backend/internal/config"
+	"backend/internal/model"
+)
+
+// WebhookSender 以JSON POST的方式将通知推送到用户配置的地址
+type WebhookSender struct {
+	client *http.Client
+}
+
+// NewWebhookSender 创建webhook渠道
+func NewWebhookSender(timeout time.Duration) *WebhookSender {
+	return &WebhookSender{client: newWebhookHTTPClient(timeout)}
+}
+
+// newWebhookHTTPClient 创建向用户或合作方填写的地址推送的HTTP客户端
+// 每次建立连接前校验实际连接的地址，防止DNS重绑定绕过保存时的校验；
+// 不走环境变量代理，否则校验的是代理地址；不跟随重定向，3xx按推送失败处理
+func newWebhookHTTPClient(timeout time.Duration) *http.Client {
+	dialer := &net.Dialer{Timeout: timeout, Control: webhookDialControl}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+	return &http.Client{
+		Timeout:   timeout,
+		Transport: transport,
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+// errWebhookAddressForbidden webhook地址指向内网
+var errWebhookAddressForbidden = errors.New("webhook地址不能指向回环、私有或链路本地地址")
+
+// isPublicWebhookIP 是否为允许推送的地址，拒绝回环、私有、链路本地等内网地址
+func isPublicWebhookIP(ip net.IP) bool {
+	return !(ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast())
+}
+
+// checkWebhookHost 解析主机名，任一解析结果为内网地址即拒绝
+func checkWebhookHost(host string) error {
+	ips, err := net.LookupIP(host)
+	if err != nil {
+		return fmt.Errorf("无法解析主机 %s: %w", host, err)
+	}
+	for _, ip := range ips {
+		if !isPublicWebhookIP(ip) {
+			return fmt.Errorf("%w: %s", errWebhookAddressForbidden, ip)
+		}
+	}
+	return nil
+}
+
+// webhookDialControl 拨号时校验目标地址（此时已完成DNS解析）
+func webhookDialControl(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip := net.ParseIP(host)
+	if ip == nil || !isPublicWebhookIP(ip) {
+		return fmt.Errorf("%w: %s", errWebhookAddressForbidden, host)
+	}
+	return nil
+}
+
+// Send 推送通知，非2xx响应视为失败
+func (s *WebhookSender) Send(target string, notification *model.Notification) error {
+	body, err := json.Marshal(notification)
+	if err != nil {
+		return fmt.Errorf("序列化通知失败: %w", err)
+	}
+
+	req, err := http.NewRequest(http.MethodPost, target, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("创建请求失败: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Notification-Event", string(notification.Event))
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("发送请求失败: %w", err)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook返回状态码 %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// SMTPSender 通过SMTP发送邮件通知
+type SMTPSender struct {
+	cfg config.SMTPConfig
+}
+
+// NewSMTPSender 创建邮件渠道
+func NewSMTPSender(cfg config.SMTPConfig) *SMTPSender {
+	if cfg.Port == 0 {
+		cfg.Port = 25
+	}
+	return &SMTPSender{cfg: cfg}
+}
+
+// Send 发送纯文本邮件，未配置用户名时不进行认证
+func (s *SMTPSender) Send(target string, notification *model.Notification) error {
+	addr := net.JoinHostPort(s.cfg.Host, strconv.Itoa(s.cfg.Port))
+	var auth smtp.Auth
+	if s.cfg.Username != "" {
+		auth = smtp.PlainAuth("", s.cfg.Username, s.cfg.Password, s.cfg.Host)
+	}
+
+	var msg strings.Builder
+	msg.WriteString("From: " + s.cfg.From + "\r\n")
+	msg.WriteString("To: " + target + "\r\n")
+	msg.WriteString("Subject: " + mime.BEncoding.Encode("UTF-8", notification.Title) + "\r\n")
+	msg.WriteString("Date: " + notification.CreatedAt.Format(time.RFC1123Z) + "\r\n")
+	msg.WriteString("MIME-Version: 1.0\r\n")
+	msg.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	msg.WriteString("Content-Transfer-Encoding: 8bit\r\n")
+	msg.WriteString("\r\n")
+	msg.WriteString(notification.Content + "\r\n")
+
+	if err := smtp.SendMail(addr, auth, s.cfg.From, []string{target}, []byte(msg.String())); err != nil {
+		return fmt.Errorf("发送邮件失败: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,432 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/mail"
+	"net/url"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"backend/internal/config"
+	"backend/internal/model"
+	"backend/internal/repository"
+
+	"github.com/google/uuid"
+)
+
+// 通知默认参数
+const (
+	defaultNotificationMaxAttempts = 5
+	defaultNotificationMaxBackoff  = 5 * time.Minute
+	defaultWebhookTimeout          = 5 * time.Second
+	notificationPollInterval       = 2 * time.Second
+	notificationBatchSize          = 50
+)
+
+var (
+	// ErrNotificationNotFound 通知不存在或不属于当前用户
+	ErrNotificationNotFound = errors.New("通知不存在")
+	// ErrInvalidNotificationPreference 渠道偏好不合法
+	ErrInvalidNotificationPreference = errors.New("无效的通知偏好")
+)
+
+// NotificationSender 通知渠道，将通知发送到用户配置的目标
+type NotificationSender interface {
+	Send(target string, notification *model.Notification) error
+}
+
+// notificationTemplate 解析后的通知模板
+type notificationTemplate struct {
+	title *template.Template
+	body  *template.Template
+}
+
+// defaultNotificationTemplates 各事件的默认模板，可在配置中按事件覆盖
+var defaultNotificationTemplates = map[model.NotificationEvent]config.NotificationTemplate{
+	model.NotificationQueueCalled: {
+		Title: "请前往{{.pileId}}号充电桩",
+		Body:  "排队号 {{.queueNumber}} 已分配到 {{.pileId}} 号充电桩，当前为该桩队列第 {{.queuePosition}} 位，请按时前往。",
+	},
+	model.NotificationChargingStarted: {
+		Title: "开始充电",
+		Body:  "排队号 {{.queueNumber}} 已在 {{.pileId}} 号充电桩开始充电，请求电量 {{.amount}} 度。",
+	},
+	model.NotificationChargingCompleted: {
+		Title: "充电完成",
+		Body:  "排队号 {{.queueNumber}} 在 {{.pileId}} 号充电桩的充电已结束，实际充电 {{printf \"%.2f\" .actualCapacity}} 度，请及时挪车。",
+	},
+	model.NotificationFaultRescheduled: {
+		Title: "充电请求已重新调度",
+		Body:  "{{.fromPileId}} 号充电桩暂停服务，排队号 {{.queueNumber}} {{if .toPileId}}已改派到 {{.toPileId}} 号充电桩{{else}}已回到等候区优先等待{{end}}。",
+	},
+	model.NotificationBillReady: {
+		Title: "充电详单已生成",
+		Body:  "详单 {{.detailId}} 已生成：充电 {{printf \"%.2f\" .chargingCapacity}} 度，总费用 {{printf \"%.2f\" .totalFee}} 元。",
+	},
+	model.NotificationBillUpdated: {
+		Title: "充电详单已更新",
+		Body:  "详单 {{.detailId}} 计入占位费 {{printf \"%.2f\" .idleFee}} 元（超时 {{.idleMinutes}} 分钟），总费用 {{printf \"%.2f\" .totalFee}} 元。",
+	},
+	model.NotificationWaitlistAdmitted: {
+		Title: "等候区已有空位",
+		Body:  "您的候补充电请求已获得入场资格，请在 {{.arrivalMinutes}} 分钟内到场签到领取排队号，逾期将自动取消。",
+	},
+	model.NotificationWaitlistExpired: {
+		Title: "候补资格已失效",
+		Body:  "您的候补充电请求未在规定时间内到场签到，已自动取消。",
+	},
+}
+
+// NotificationService 通知服务
+// 业务事件按模板生成通知，写入站内信并按用户的渠道偏好投递到webhook与邮件，
+// 外部渠道的投递落库后异步发送，失败按指数退避重试
+type NotificationService struct {
+	notificationRepo *repository.NotificationRepository
+	senders          map[model.NotificationChannel]NotificationSender
+	templates        map[model.NotificationEvent]*notificationTemplate
+	maxAttempts      int
+	worker           *deliveryWorker[*model.NotificationDelivery]
+	mu               sync.Mutex
+}
+
+// NewNotificationService 创建通知服务
+func NewNotificationService(notificationRepo *repository.NotificationRepository, cfg config.NotificationConfig) *NotificationService {
+	s := &NotificationService{
+		notificationRepo: notificationRepo,
+		senders:          make(map[model.NotificationChannel]NotificationSender),
+		templates:        make(map[model.NotificationEvent]*notificationTemplate),
+		maxAttempts:      cfg.MaxAttempts,
+	}
+	if s.maxAttempts <= 0 {
+		s.maxAttempts = defaultNotificationMaxAttempts
+	}
+	maxBackoff := time.Duration(cfg.MaxBackoff) * time.Second
+	if maxBackoff <= 0 {
+		maxBackoff = defaultNotificationMaxBackoff
+	}
+	s.worker = newDeliveryWorker[*model.NotificationDelivery](s, deliveryWorkerConfig{
+		name:         "通知",
+		pollInterval: notificationPollInterval,
+		batchSize:    notificationBatchSize,
+		maxBackoff:   maxBackoff,
+	})
+
+	for event, tmpl := range defaultNotificationTemplates {
+		if override, ok := cfg.Templates[string(event)]; ok {
+			if override.Title != "" {
+				tmpl.Title = override.Title
+			}
+			if override.Body != "" {
+				tmpl.Body = override.Body
+			}
+		}
+		parsed, err := parseNotificationTemplate(event, tmpl)
+		if err != nil {
+			// 配置的模板有误时退回默认模板
+			log.Printf("通知模板解析失败，使用默认模板: 事件=%s, 错误=%v", event, err)
+			parsed, _ = parseNotificationTemplate(event, defaultNotificationTemplates[event])
+		}
+		s.templates[event] = parsed
+	}
+
+	webhookTimeout := time.Duration(cfg.WebhookTimeout) * time.Second
+	if webhookTimeout <= 0 {
+		webhookTimeout = defaultWebhookTimeout
+	}
+	s.RegisterChannel(model.NotificationChannelWebhook, NewWebhookSender(webhookTimeout))
+	if cfg.SMTP.Host != "" {
+		s.RegisterChannel(model.NotificationChannelEmail, NewSMTPSender(cfg.SMTP))
+	}
+
+	// 启动投递循环，继续投递重启前未完成的记录
+	s.worker.start()
+
+	return s
+}
+
+// parseNotificationTemplate 解析事件的标题与正文模板
+func parseNotificationTemplate(event model.NotificationEvent, tmpl config.NotificationTemplate) (*notificationTemplate, error) {
+	title, err := template.New(string(event) + ".title").Option("missingkey=zero").Parse(tmpl.Title)
+	if err != nil {
+		return nil, err
+	}
+	body, err := template.New(string(event) + ".body").Option("missingkey=zero").Parse(tmpl.Body)
+	if err != nil {
+		return nil, err
+	}
+	return &notificationTemplate{title: title, body: body}, nil
+}
+
+// RegisterChannel 注册或替换外部通知渠道
+func (s *NotificationService) RegisterChannel(channel model.NotificationChannel, sender NotificationSender) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.senders[channel] = sender
+}
+
+// sender 获取渠道的发送器
+func (s *NotificationService) sender(channel model.NotificationChannel) NotificationSender {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.senders[channel]
+}
+
+// Notify 向用户发送事件通知，通知失败只记录日志，不影响业务流程
+func (s *NotificationService) Notify(userID uuid.UUID, event model.NotificationEvent, data map[string]any) {
+	if err := s.notify(userID, event, data); err != nil {
+		log.Printf("发送通知失败: 用户=%s, 事件=%s, 错误=%v", userID, event, err)
+	}
+}
+
+// notify 渲染通知并按渠道偏好生成投递记录
+func (s *NotificationService) notify(userID uuid.UUID, event model.NotificationEvent, data map[string]any) error {
+	tmpl, ok := s.templates[event]
+	if !ok {
+		return fmt.Errorf("未知的通知事件: %s", event)
+	}
+	var title, body strings.Builder
+	if err := tmpl.title.Execute(&title, data); err != nil {
+		return fmt.Errorf("渲染通知标题失败: %w", err)
+	}
+	if err := tmpl.body.Execute(&body, data); err != nil {
+		return fmt.Errorf("渲染通知正文失败: %w", err)
+	}
+
+	preferences, err := s.GetPreferences(userID)
+	if err != nil {
+		return fmt.Errorf("获取通知偏好失败: %w", err)
+	}
+
+	now := time.Now().UTC()
+	notification := &model.Notification{
+		ID:        uuid.New(),
+		UserID:    userID,
+		Event:     event,
+		Title:     title.String(),
+		Content:   body.String(),
+		Data:      data,
+		CreatedAt: now,
+	}
+
+	var deliveries []*model.NotificationDelivery
+	for _, preference := range preferences {
+		if !preference.Subscribes(event) {
+			continue
+		}
+		delivery := &model.NotificationDelivery{
+			ID:             uuid.New(),
+			NotificationID: notification.ID,
+			UserID:         userID,
+			Event:          event,
+			Channel:        preference.Channel,
+			Target:         preference.Target,
+			Status:         model.NotificationDeliveryPending,
+			NextAttemptAt:  now,
+			CreatedAt:      now,
+		}
+		switch {
+		case preference.Channel == model.NotificationChannelInApp:
+			// 站内信随通知一起落库即视为送达
+			notification.InApp = true
+			delivery.Status = model.NotificationDeliverySent
+			delivery.Attempts = 1
+			delivery.SentAt = &now
+		case s.sender(preference.Channel) == nil:
+			delivery.Status = model.NotificationDeliveryFailed
+			delivery.LastError = "通知渠道未启用"
+		}
+		deliveries = append(deliveries, delivery)
+	}
+	if len(deliveries) == 0 {
+		return nil
+	}
+
+	if err := s.notificationRepo.Create(notification, deliveries); err != nil {
+		return fmt.Errorf("保存通知失败: %w", err)
+	}
+	s.worker.wake()
+	return nil
+}
+
+// GetNotifications 分页获取用户站内信及未读数
+func (s *NotificationService) GetNotifications(userID uuid.UUID, unreadOnly bool, page, pageSize int) ([]*model.Notification, int, int, error) {
+	notifications, total, err := s.notificationRepo.GetInbox(userID, unreadOnly, page, pageSize)
+	if err != nil {
+		return nil, 0, 0, err
+	}
+	unread, err := s.notificationRepo.CountUnread(userID)
+	if err != nil {
+		return nil, 0, 0, err
+	}
+	return notifications, total, unread, nil
+}
+
+// MarkRead 将站内信标记为已读
+func (s *NotificationService) MarkRead(userID, notificationID uuid.UUID) error {
+	ok, err := s.notificationRepo.MarkRead(userID, notificationID)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrNotificationNotFound
+	}
+	return nil
+}
+
+// MarkAllRead 将用户的全部站内信标记为已读
+func (s *NotificationService) MarkAllRead(userID uuid.UUID) (int64, error) {
+	return s.notificationRepo.MarkAllRead(userID)
+}
+
+// GetPreferences 获取用户全部渠道的偏好，未保存的渠道使用默认值（仅开启站内信）
+func (s *NotificationService) GetPreferences(userID uuid.UUID) ([]*model.NotificationPreference, error) {
+	saved, err := s.notificationRepo.GetPreferences(userID)
+	if err != nil {
+		return nil, err
+	}
+	byChannel := make(map[model.NotificationChannel]*model.NotificationPreference, len(saved))
+	for _, preference := range saved {
+		byChannel[preference.Channel] = preference
+	}
+
+	preferences := make([]*model.NotificationPreference, 0, len(model.NotificationChannels))
+	for _, channel := range model.NotificationChannels {
+		if preference, ok := byChannel[channel]; ok {
+			preferences = append(preferences, preference)
+			continue
+		}
+		preferences = append(preferences, &model.NotificationPreference{
+			Channel: channel,
+			Enabled: channel == model.NotificationChannelInApp,
+		})
+	}
+	return preferences, nil
+}
+
+// UpdatePreferences 校验并保存用户的渠道偏好，返回更新后的全部偏好
+func (s *NotificationService) UpdatePreferences(userID uuid.UUID, preferences []*model.NotificationPreference) ([]*model.NotificationPreference, error) {
+	seen := make(map[model.NotificationChannel]bool)
+	for _, preference := range preferences {
+		if err := validateNotificationPreference(preference); err != nil {
+			return nil, err
+		}
+		if seen[preference.Channel] {
+			return nil, fmt.Errorf("%w: 渠道 %s 重复", ErrInvalidNotificationPreference, preference.Channel)
+		}
+		seen[preference.Channel] = true
+	}
+
+	if err := s.notificationRepo.SavePreferences(userID, preferences); err != nil {
+		return nil, err
+	}
+	return s.GetPreferences(userID)
+}
+
+// validateNotificationPreference 校验渠道、目标地址与订阅事件
+func validateNotificationPreference(preference *model.NotificationPreference) error {
+	preference.Target = strings.TrimSpace(preference.Target)
+	switch preference.Channel {
+	case model.NotificationChannelInApp:
+		preference.Target = ""
+	case model.NotificationChannelWebhook:
+		if preference.Target != "" || preference.Enabled {
+			u, err := url.Parse(preference.Target)
+			if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+				return fmt.Errorf("%w: webhook地址须为http或https地址", ErrInvalidNotificationPreference)
+			}
+			if err := checkWebhookHost(u.Hostname()); err != nil {
+				return fmt.Errorf("%w: %v", ErrInvalidNotificationPreference, err)
+			}
+		}
+	case model.NotificationChannelEmail:
+		if preference.Target != "" || preference.Enabled {
+			if _, err := mail.ParseAddress(preference.Target); err != nil {
+				return fmt.Errorf("%w: 邮箱地址格式不正确", ErrInvalidNotificationPreference)
+			}
+		}
+	default:
+		return fmt.Errorf("%w: 未知的通知渠道 %s", ErrInvalidNotificationPreference, preference.Channel)
+	}
+
+	for _, event := range preference.Events {
+		if !isValidNotificationEvent(event) {
+			return fmt.Errorf("%w: 未知的通知事件 %s", ErrInvalidNotificationPreference, event)
+		}
+	}
+	return nil
+}
+
+// isValidNotificationEvent 是否为已知的通知事件
+func isValidNotificationEvent(event model.NotificationEvent) bool {
+	for _, e := range model.NotificationEvents {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
+
+// GetDeliveries 分页查询投递记录
+func (s *NotificationService) GetDeliveries(filter *model.NotificationDeliveryFilter, page, pageSize int) ([]*model.NotificationDelivery, int, error) {
+	return s.notificationRepo.GetDeliveries(filter, page, pageSize)
+}
+
+// claimDue 认领到期的投递记录
+func (s *NotificationService) claimDue(now time.Time, limit int, lease time.Duration) ([]*model.NotificationDelivery, error) {
+	return s.notificationRepo.ClaimDueDeliveries(now, limit, lease)
+}
+
+// deliveryKey 按投递记录区分
+func (s *NotificationService) deliveryKey(delivery *model.NotificationDelivery) string {
+	return delivery.ID.String()
+}
+
+// deliveryAttempts 已尝试次数
+func (s *NotificationService) deliveryAttempts(delivery *model.NotificationDelivery) int {
+	return delivery.Attempts
+}
+
+// describe 日志中的记录描述
+func (s *NotificationService) describe(delivery *model.NotificationDelivery) string {
+	return fmt.Sprintf("记录=%s, 渠道=%s", delivery.ID, delivery.Channel)
+}
+
+// exhausted 达到最大投递次数后不再重试
+func (s *NotificationService) exhausted(_ *model.NotificationDelivery, attempts int) bool {
+	return attempts >= s.maxAttempts
+}
+
+// markSucceeded 记录投递成功
+func (s *NotificationService) markSucceeded(delivery *model.NotificationDelivery, attempts, _ int) error {
+	return s.notificationRepo.MarkDeliverySent(delivery.ID, attempts)
+}
+
+// markRetry 记录投递失败，等待重试
+func (s *NotificationService) markRetry(delivery *model.NotificationDelivery, attempts, _ int, nextAttemptAt time.Time, cause error) error {
+	return s.notificationRepo.MarkDeliveryRetry(delivery.ID, attempts, nextAttemptAt, cause.Error())
+}
+
+// markAbandoned 重试次数用尽，记为失败
+func (s *NotificationService) markAbandoned(delivery *model.NotificationDelivery, attempts, _ int, cause error) error {
+	return s.notificationRepo.MarkDeliveryFailed(delivery.ID, attempts, cause.Error())
+}
+
+// send 通过渠道发送通知
+func (s *NotificationService) send(delivery *model.NotificationDelivery) (int, error) {
+	sender := s.sender(delivery.Channel)
+	if sender == nil {
+		return 0, fmt.Errorf("通知渠道未启用: %s", delivery.Channel)
+	}
+	if delivery.Target == "" {
+		return 0, fmt.Errorf("未配置通知目标")
+	}
+	notification, err := s.notificationRepo.GetByID(delivery.NotificationID)
+	if err != nil {
+		return 0, fmt.Errorf("获取通知失败: %w", err)
+	}
+	return 0, sender.Send(delivery.Target, notification)
+}
@@ -0,0 +1,218 @@
+package service
+
+import (
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"strings"
+
+	"backend/internal/model"
+	"backend/internal/repository"
+
+	"github.com/google/uuid"
+)
+
+// 充电桩注册错误
+var (
+	// ErrInvalidRegistration 充电桩注册信息无效
+	ErrInvalidRegistration = errors.New("无效的充电桩注册信息")
+	// ErrRegistrationUnauthorized 注册密钥缺失或错误
+	ErrRegistrationUnauthorized = errors.New("充电桩注册密钥无效")
+	// ErrEndpointConflict 充电桩已注册到其他地址，只能由管理员迁移
+	ErrEndpointConflict = errors.New("充电桩已注册到其他地址")
+	// ErrPileSpecBusy 充电桩有排队或充电中的请求，不能修改类型与功率
+	ErrPileSpecBusy = errors.New("充电桩有排队或充电中的请求，不能修改类型与功率")
+)
+
+// PileRegistryService 充电桩注册服务
+// 模拟器或充电桩启动时登记自己的充电桩及指令下发地址，调度指令按地址路由
+type PileRegistryService struct {
+	chargingPileRepo   *repository.ChargingPileRepository
+	endpointRepo       *repository.PileEndpointRepository
+	requestRepo        *repository.ChargingRequestRepository
+	bootstrapService   *BootstrapService
+	dispatcherClient   *ChargingDispatcherClient
+	auditService       *AuditService // 管理员迁移注册地址的审计
+	registrationSecret string        // 自注册的共享密钥，为空时拒绝自注册
+}
+
+// NewPileRegistryService 创建充电桩注册服务
+func NewPileRegistryService(
+	chargingPileRepo *repository.ChargingPileRepository,
+	endpointRepo *repository.PileEndpointRepository,
+	requestRepo *repository.ChargingRequestRepository,
+	bootstrapService *BootstrapService,
+	dispatcherClient *ChargingDispatcherClient,
+	auditService *AuditService,
+	registrationSecret string,
+) *PileRegistryService {
+	return &PileRegistryService{
+		chargingPileRepo:   chargingPileRepo,
+		endpointRepo:       endpointRepo,
+		requestRepo:        requestRepo,
+		bootstrapService:   bootstrapService,
+		dispatcherClient:   dispatcherClient,
+		auditService:       auditService,
+		registrationSecret: registrationSecret,
+	}
+}
+
+// LoadEndpoints 从数据库恢复已注册充电桩的指令下发地址
+func (s *PileRegistryService) LoadEndpoints() error {
+	endpoints, err := s.endpointRepo.GetAll()
+	if err != nil {
+		return err
+	}
+
+	for _, endpoint := range endpoints {
+		s.dispatcherClient.SetPileEndpoint(endpoint.PileID, endpoint.EndpointURL)
+	}
+	return nil
+}
+
+// Register 登记一组充电桩及其指令下发地址
+// 新充电桩按上报的类型、功率与状态创建；已有充电桩更新类型与功率，状态以后端记录为准，差异由对账处理
+// 已注册到其他地址的充电桩不能通过自注册迁移，有请求的充电桩不能修改类型与功率
+func (s *PileRegistryService) Register(secret, endpointURL string, registrations []model.PileRegistration) ([]*model.ChargingPile, error) {
+	if s.registrationSecret == "" || subtle.ConstantTimeCompare([]byte(secret), []byte(s.registrationSecret)) != 1 {
+		return nil, ErrRegistrationUnauthorized
+	}
+	endpointURL, err := normalizeEndpointURL(endpointURL)
+	if err != nil {
+		return nil, err
+	}
+	if len(registrations) == 0 {
+		return nil, fmt.Errorf("%w: 充电桩列表不能为空", ErrInvalidRegistration)
+	}
+	for _, reg := range registrations {
+		if err := validateRegistration(reg); err != nil {
+			return nil, err
+		}
+	}
+	// 全部校验通过后再写入，避免部分充电桩被登记
+	for _, reg := range registrations {
+		if err := s.checkRegistrationConflict(endpointURL, reg); err != nil {
+			return nil, err
+		}
+	}
+
+	piles := make([]*model.ChargingPile, 0, len(registrations))
+	for _, reg := range registrations {
+		status := reg.Status
+		if status == "" {
+			status = model.PileStatusAvailable
+		}
+		if existing, err := s.chargingPileRepo.GetByID(reg.PileID); err == nil {
+			status = existing.Status
+		}
+
+		pile, err := s.bootstrapService.FindOrCreatePileByID(reg.PileID, status, reg.PileType, reg.Power)
+		if err != nil {
+			return nil, fmt.Errorf("登记充电桩 %s 失败: %w", reg.PileID, err)
+		}
+		if err := s.endpointRepo.Upsert(reg.PileID, endpointURL); err != nil {
+			return nil, fmt.Errorf("保存充电桩 %s 地址失败: %w", reg.PileID, err)
+		}
+		s.dispatcherClient.SetPileEndpoint(reg.PileID, endpointURL)
+		piles = append(piles, pile)
+	}
+
+	log.Printf("充电桩注册: 地址=%s, 充电桩数=%d", endpointURL, len(piles))
+	return piles, nil
+}
+
+// checkRegistrationConflict 检查注册是否会迁移已有充电桩的地址或修改有请求的充电桩的规格
+func (s *PileRegistryService) checkRegistrationConflict(endpointURL string, reg model.PileRegistration) error {
+	endpoint, err := s.endpointRepo.GetByPileID(reg.PileID)
+	if err != nil {
+		return fmt.Errorf("获取充电桩 %s 注册信息失败: %w", reg.PileID, err)
+	}
+	if endpoint != nil && endpoint.EndpointURL != endpointURL {
+		return fmt.Errorf("%w: 充电桩 %s 已注册到 %s", ErrEndpointConflict, reg.PileID, endpoint.EndpointURL)
+	}
+
+	pile, err := s.chargingPileRepo.GetByID(reg.PileID)
+	if err != nil || (pile.PileType == reg.PileType && pile.Power == reg.Power) {
+		// 新充电桩或规格未变
+		return nil
+	}
+	requests, err := s.requestRepo.GetRequestsByPile(reg.PileID)
+	if err != nil {
+		return fmt.Errorf("获取充电桩 %s 队列失败: %w", reg.PileID, err)
+	}
+	if len(requests) > 0 {
+		return fmt.Errorf("%w: 充电桩 %s", ErrPileSpecBusy, reg.PileID)
+	}
+	return nil
+}
+
+// MoveEndpoint 管理员将已注册的充电桩迁移到新的指令下发地址
+func (s *PileRegistryService) MoveEndpoint(operatorID uuid.UUID, pileID, endpointURL, reason string) (*model.PileEndpoint, error) {
+	endpointURL, err := normalizeEndpointURL(endpointURL)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := s.chargingPileRepo.GetByID(pileID); err != nil {
+		return nil, fmt.Errorf("%w: 充电桩 %s 不存在", ErrInvalidRegistration, pileID)
+	}
+	previous, err := s.endpointRepo.GetByPileID(pileID)
+	if err != nil {
+		return nil, fmt.Errorf("获取充电桩 %s 注册信息失败: %w", pileID, err)
+	}
+
+	if err := s.endpointRepo.Upsert(pileID, endpointURL); err != nil {
+		return nil, fmt.Errorf("保存充电桩 %s 地址失败: %w", pileID, err)
+	}
+	s.dispatcherClient.SetPileEndpoint(pileID, endpointURL)
+
+	detail := map[string]any{"endpointUrl": endpointURL}
+	if previous != nil {
+		detail["previousEndpointUrl"] = previous.EndpointURL
+	}
+	s.auditService.Record(&model.AuditLog{
+		OperatorID: &operatorID,
+		Action:     model.AuditActionMoveEndpoint,
+		PileID:     pileID,
+		Reason:     reason,
+		Detail:     detail,
+		Success:    true,
+	})
+	log.Printf("迁移充电桩注册地址: 充电桩=%s, 地址=%s", pileID, endpointURL)
+	return s.endpointRepo.GetByPileID(pileID)
+}
+
+// GetEndpoints 获取全部充电桩注册信息
+func (s *PileRegistryService) GetEndpoints() ([]*model.PileEndpoint, error) {
+	return s.endpointRepo.GetAll()
+}
+
+// normalizeEndpointURL 校验指令下发地址并去掉末尾的斜杠
+func normalizeEndpointURL(endpointURL string) (string, error) {
+	parsed, err := url.Parse(endpointURL)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return "", fmt.Errorf("%w: 回调地址 %q 无效", ErrInvalidRegistration, endpointURL)
+	}
+	return strings.TrimRight(endpointURL, "/"), nil
+}
+
+// validateRegistration 校验单个充电桩的注册信息
+func validateRegistration(reg model.PileRegistration) error {
+	if reg.PileID == "" || len(reg.PileID) > 10 {
+		return fmt.Errorf("%w: 充电桩ID %q 无效", ErrInvalidRegistration, reg.PileID)
+	}
+	if reg.PileType != model.PileTypeFast && reg.PileType != model.PileTypeSlow {
+		return fmt.Errorf("%w: 充电桩 %s 类型 %q 无效", ErrInvalidRegistration, reg.PileID, reg.PileType)
+	}
+	if reg.Power <= 0 {
+		return fmt.Errorf("%w: 充电桩 %s 功率必须大于0", ErrInvalidRegistration, reg.PileID)
+	}
+	switch reg.Status {
+	case "", model.PileStatusAvailable, model.PileStatusOccupied, model.PileStatusFault,
+		model.PileStatusMaintenance, model.PileStatusOffline:
+		return nil
+	default:
+		return fmt.Errorf("%w: 充电桩 %s 状态 %q 无效", ErrInvalidRegistration, reg.PileID, reg.Status)
+	}
+}
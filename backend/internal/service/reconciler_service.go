@@ -0,0 +1,298 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"backend/internal/config"
+	"backend/internal/model"
+	"backend/internal/repository"
+
+	"github.com/google/uuid"
+)
+
+// 对账模式
+const (
+	ReconcileModeAlert   = "alert"   // 仅告警
+	ReconcileModeCorrect = "correct" // 自动纠正可确定的不一致
+)
+
+// 对账默认参数
+const defaultReconcileGracePeriod = 60 * time.Second
+
+// ReconcilerService 后端与模拟器充电桩状态对账
+// 比较模拟器上报的充电桩状态与后端记录，不一致持续超过宽限期后告警或自动纠正
+type ReconcilerService struct {
+	pileRepo            *repository.ChargingPileRepository
+	sessionRepo         *repository.ChargingSessionRepository
+	reconciliationRepo  *repository.ReconciliationRepository
+	chargingPileService *ChargingPileService
+	schedulerService    *SchedulerService
+	commandQueue        *CommandQueueService
+	simulatorClient     *ChargingDispatcherClient
+	mode                string
+	gracePeriod         time.Duration
+	pullInterval        time.Duration
+	mismatches          map[string]*pendingMismatch // 充电桩ID -> 当前不一致
+	mu                  sync.Mutex
+}
+
+// pendingMismatch 正在观察的不一致
+type pendingMismatch struct {
+	kind      model.MismatchKind
+	firstSeen time.Time
+	handled   bool // 已告警或纠正，同一次不一致只处理一次
+}
+
+// NewReconcilerService 创建对账服务
+func NewReconcilerService(
+	pileRepo *repository.ChargingPileRepository,
+	sessionRepo *repository.ChargingSessionRepository,
+	reconciliationRepo *repository.ReconciliationRepository,
+	chargingPileService *ChargingPileService,
+	schedulerService *SchedulerService,
+	commandQueue *CommandQueueService,
+	simulatorClient *ChargingDispatcherClient,
+	cfg config.ReconcileConfig,
+) *ReconcilerService {
+	svc := &ReconcilerService{
+		pileRepo:            pileRepo,
+		sessionRepo:         sessionRepo,
+		reconciliationRepo:  reconciliationRepo,
+		chargingPileService: chargingPileService,
+		schedulerService:    schedulerService,
+		commandQueue:        commandQueue,
+		simulatorClient:     simulatorClient,
+		mode:                cfg.Mode,
+		gracePeriod:         time.Duration(cfg.GracePeriod) * time.Second,
+		pullInterval:        time.Duration(cfg.PullInterval) * time.Second,
+		mismatches:          make(map[string]*pendingMismatch),
+	}
+	if svc.mode != ReconcileModeCorrect {
+		svc.mode = ReconcileModeAlert
+	}
+	if svc.gracePeriod <= 0 {
+		svc.gracePeriod = defaultReconcileGracePeriod
+	}
+
+	// 定期主动拉取模拟器状态
+	if svc.pullInterval > 0 && simulatorClient != nil {
+		go svc.pullLoop()
+	}
+
+	return svc
+}
+
+// Reconcile 对账一个充电桩
+func (s *ReconcilerService) Reconcile(report *model.PileStatusReport) error {
+	pile, err := s.pileRepo.GetByID(report.PileID)
+	if err != nil {
+		return fmt.Errorf("获取充电桩失败: %w", err)
+	}
+
+	backend := model.BackendPileState{Status: pile.Status}
+	session, err := s.sessionRepo.GetActiveSessionByPileID(report.PileID)
+	if err != nil && !errors.Is(err, repository.ErrNoActiveSession) {
+		return fmt.Errorf("获取充电会话失败: %w", err)
+	}
+	if session != nil {
+		backend.SessionID = &session.ID
+		backend.UserID = &session.UserID
+		backend.RequestID = &session.RequestID
+	}
+
+	kind, found := detectMismatch(backend, report)
+
+	s.mu.Lock()
+	current := s.mismatches[report.PileID]
+	if current != nil && (!found || current.kind != kind) {
+		// 之前的不一致已消失
+		delete(s.mismatches, report.PileID)
+		if current.handled {
+			if err := s.reconciliationRepo.ResolveAlerts(report.PileID, current.kind); err != nil {
+				log.Printf("关闭对账告警失败: 充电桩=%s, 错误=%v", report.PileID, err)
+			}
+			log.Printf("对账: 充电桩 %s 的不一致(%s)已恢复", report.PileID, current.kind)
+		}
+		current = nil
+	}
+	if !found {
+		s.mu.Unlock()
+		return nil
+	}
+	if current == nil {
+		current = &pendingMismatch{kind: kind, firstSeen: time.Now()}
+		s.mismatches[report.PileID] = current
+	}
+	ready := !current.handled && time.Since(current.firstSeen) >= s.gracePeriod
+	s.mu.Unlock()
+	if !ready {
+		return nil
+	}
+
+	// 指令尚在投递中的不一致由指令队列处理
+	if pending, err := s.commandQueue.HasPendingCommands(report.PileID); err != nil || pending {
+		return err
+	}
+
+	s.mu.Lock()
+	if current.handled {
+		s.mu.Unlock()
+		return nil
+	}
+	current.handled = true
+	s.mu.Unlock()
+
+	s.handleMismatch(kind, backend, report)
+	return nil
+}
+
+// detectMismatch 比较后端与模拟器状态，返回不一致类型
+func detectMismatch(backend model.BackendPileState, report *model.PileStatusReport) (model.MismatchKind, bool) {
+	simCharging := report.Status == "charging" && report.Vehicle != nil
+	simFault := report.Status == "fault"
+
+	switch {
+	case simFault && backend.Status != model.PileStatusFault:
+		return model.MismatchFaultNotRecorded, true
+	case simFault:
+		return "", false
+	case backend.Status == model.PileStatusFault && (simCharging || report.Status == "available"):
+		return model.MismatchFaultNotCleared, true
+	case simCharging && backend.SessionID == nil:
+		return model.MismatchChargingWithoutSession, true
+	case simCharging && report.Vehicle.SessionID != "" && report.Vehicle.SessionID != backend.SessionID.String():
+		return model.MismatchSessionConflict, true
+	case !simCharging && backend.SessionID != nil && report.Status == "available":
+		return model.MismatchSessionWithoutCharging, true
+	}
+	return "", false
+}
+
+// handleMismatch 告警或纠正不一致
+func (s *ReconcilerService) handleMismatch(kind model.MismatchKind, backend model.BackendPileState, report *model.PileStatusReport) {
+	alert := &model.ReconciliationAlert{
+		ID:             uuid.New(),
+		PileID:         report.PileID,
+		Kind:           kind,
+		BackendState:   backend,
+		SimulatorState: report,
+		Diff:           describeDiff(backend, report),
+		Action:         model.ReconcileActionAlerted,
+		CreatedAt:      time.Now().UTC(),
+	}
+
+	if s.mode == ReconcileModeCorrect {
+		detail, corrected, err := s.correct(kind, backend, report)
+		switch {
+		case err != nil:
+			alert.Action = model.ReconcileActionCorrectionFailed
+			alert.Detail = err.Error()
+		case corrected:
+			alert.Action = model.ReconcileActionCorrected
+			alert.Detail = detail
+		default:
+			alert.Detail = detail
+		}
+	}
+
+	log.Printf("对账不一致: 充电桩=%s, 类型=%s, 处理=%s, %s", alert.PileID, kind, alert.Action, alert.Diff)
+	if err := s.reconciliationRepo.CreateAlert(alert); err != nil {
+		log.Printf("保存对账告警失败: %v", err)
+	}
+}
+
+// correct 自动纠正不一致，无法确定正确状态的只告警
+func (s *ReconcilerService) correct(kind model.MismatchKind, backend model.BackendPileState, report *model.PileStatusReport) (string, bool, error) {
+	switch kind {
+	case model.MismatchChargingWithoutSession:
+		// 后端没有会话，停止模拟器上的充电
+		err := s.commandQueue.StopCharging(ChargingStopRequest{
+			SessionID: report.Vehicle.SessionID,
+			PileID:    report.PileID,
+			UserID:    report.Vehicle.UserID,
+			Reason:    "对账：后端无对应充电会话",
+		})
+		if err != nil {
+			return "", false, err
+		}
+		return "已下发停止充电指令", true, nil
+	case model.MismatchSessionWithoutCharging:
+		// 充电桩已空闲，按已充电量结束后端会话并结算
+		if err := s.schedulerService.StopCharging(*backend.RequestID, false); err != nil {
+			return "", false, err
+		}
+		return "已结束后端充电会话并生成详单", true, nil
+	case model.MismatchFaultNotRecorded:
+		description := "对账：模拟器报告充电桩故障"
+		if err := s.chargingPileService.ReportPileFault(report.PileID, string(model.FaultTypeSoftware), description); err != nil {
+			return "", false, err
+		}
+		if err := s.schedulerService.HandlePileFault(report.PileID, string(model.FaultTypeSoftware), description); err != nil {
+			return "", false, err
+		}
+		return "已按故障处理并重新调度", true, nil
+	default:
+		return "无法确定正确状态，需人工处理", false, nil
+	}
+}
+
+// describeDiff 描述后端与模拟器的状态差异
+func describeDiff(backend model.BackendPileState, report *model.PileStatusReport) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "后端: 状态=%s", backend.Status)
+	if backend.SessionID != nil {
+		fmt.Fprintf(&b, ", 会话=%s, 用户=%s", backend.SessionID, backend.UserID)
+	} else {
+		b.WriteString(", 会话=无")
+	}
+
+	fmt.Fprintf(&b, "; 模拟器: 状态=%s", report.Status)
+	if report.Vehicle != nil {
+		sessionID := report.Vehicle.SessionID
+		if sessionID == "" {
+			sessionID = "未知"
+		}
+		fmt.Fprintf(&b, ", 会话=%s, 用户=%s, 已充电量=%.1f/%.1fkWh",
+			sessionID, report.Vehicle.UserID, report.Vehicle.CurrentCapacity, report.Vehicle.RequestedCapacity)
+	} else {
+		b.WriteString(", 会话=无")
+	}
+	return b.String()
+}
+
+// GetAlerts 分页查询对账告警
+func (s *ReconcilerService) GetAlerts(unresolvedOnly bool, page, pageSize int) ([]*model.ReconciliationAlert, int, error) {
+	return s.reconciliationRepo.GetAlerts(unresolvedOnly, page, pageSize)
+}
+
+// pullLoop 定期拉取模拟器状态并对账
+func (s *ReconcilerService) pullLoop() {
+	ticker := time.NewTicker(s.pullInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		piles, err := s.simulatorClient.GetSimulatorStatus()
+		if err != nil {
+			// 部分模拟器不可达时仍对其余充电桩对账
+			log.Printf("拉取模拟器状态失败: %v", err)
+		}
+
+		now := time.Now().UTC()
+		for _, pile := range piles {
+			report := &model.PileStatusReport{
+				PileID:     pile.ID,
+				Status:     pile.Status,
+				Vehicle:    pile.CurrentVehicle,
+				ReportedAt: now,
+			}
+			if err := s.Reconcile(report); err != nil {
+				log.Printf("对账失败: 充电桩=%s, 错误=%v", pile.ID, err)
+			}
+		}
+	}
+}
@@ -0,0 +1,316 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"backend/internal/config"
+	"backend/internal/model"
+	"backend/internal/repository"
+)
+
+// 可靠性告警默认阈值：7天内故障3次标记巡检
+const (
+	defaultAlertFaultCount = 3
+	defaultAlertWindow     = 7 * 24 * time.Hour
+)
+
+// ReliabilityService 充电桩可靠性报表服务
+// 根据故障记录计算平均故障间隔、平均修复时间、可用率，统计降功率运行时段，并按充电桩的收入水平估算停机与降功率损失
+type ReliabilityService struct {
+	systemRepo  *repository.SystemRepository
+	pileRepo    *repository.ChargingPileRepository
+	billingRepo *repository.BillingRepository
+	degradeRepo *repository.PileDegradationRepository
+	thresholds  model.ReliabilityThresholds
+	alertWindow time.Duration
+}
+
+// NewReliabilityService 创建可靠性报表服务
+func NewReliabilityService(
+	systemRepo *repository.SystemRepository,
+	pileRepo *repository.ChargingPileRepository,
+	billingRepo *repository.BillingRepository,
+	degradeRepo *repository.PileDegradationRepository,
+	cfg config.ReliabilityConfig,
+) *ReliabilityService {
+	svc := &ReliabilityService{
+		systemRepo:  systemRepo,
+		pileRepo:    pileRepo,
+		billingRepo: billingRepo,
+		degradeRepo: degradeRepo,
+		alertWindow: time.Duration(cfg.AlertWindow) * time.Second,
+	}
+	if svc.alertWindow <= 0 {
+		svc.alertWindow = defaultAlertWindow
+	}
+	svc.thresholds = model.ReliabilityThresholds{
+		AlertFaultCount: cfg.AlertFaultCount,
+		AlertWindow:     int(svc.alertWindow / time.Second),
+		MinAvailability: cfg.MinAvailability,
+	}
+	if svc.thresholds.AlertFaultCount <= 0 {
+		svc.thresholds.AlertFaultCount = defaultAlertFaultCount
+	}
+	return svc
+}
+
+// pileReliabilityAcc 单个充电桩的统计累加
+type pileReliabilityAcc struct {
+	stat        *model.PileReliability
+	occurrences []time.Time // 含统计期前一个告警窗口内的故障，用于统计窗口内故障次数
+	repairHours float64
+	repaired    int
+	revenueRate float64 // 每运行小时收入（元）
+}
+
+// GetReport 生成统计期内按充电桩与故障类型的可靠性报表，统计期结束时间晚于当前时间时按当前时间计算
+func (s *ReliabilityService) GetReport(startTime, endTime time.Time) (*model.ReliabilityReport, error) {
+	if now := time.Now().UTC(); endTime.After(now) {
+		endTime = now
+	}
+	if !endTime.After(startTime) {
+		return nil, errors.New("开始时间必须早于结束时间")
+	}
+	periodHours := endTime.Sub(startTime).Hours()
+
+	piles, err := s.pileRepo.GetAll()
+	if err != nil {
+		return nil, fmt.Errorf("获取充电桩信息失败: %v", err)
+	}
+	faults, err := s.systemRepo.GetFaultsOverlapping(startTime.Add(-s.alertWindow), endTime)
+	if err != nil {
+		return nil, fmt.Errorf("获取故障记录失败: %v", err)
+	}
+	degradations, err := s.degradeRepo.GetOverlapping("", startTime, endTime)
+	if err != nil {
+		return nil, fmt.Errorf("获取降功率运行记录失败: %v", err)
+	}
+
+	accs := make(map[string]*pileReliabilityAcc, len(piles))
+	for _, pile := range piles {
+		accs[pile.ID] = &pileReliabilityAcc{
+			stat: &model.PileReliability{PileID: pile.ID, PileType: pile.PileType},
+		}
+	}
+
+	// 按充电桩累计故障次数、停机时长与修复时间
+	downtimes := make([]float64, len(faults))
+	for i, fault := range faults {
+		acc, ok := accs[fault.PileID]
+		if !ok {
+			continue
+		}
+		acc.occurrences = append(acc.occurrences, fault.OccurredAt)
+		downtimes[i] = faultDowntime(fault, startTime, endTime).Hours()
+		acc.stat.DowntimeHours += downtimes[i]
+
+		if fault.OccurredAt.Before(startTime) {
+			continue
+		}
+		acc.stat.FaultCount++
+		acc.stat.AffectedSessions += fault.AffectedSessions
+		if fault.RecoveredAt != nil {
+			acc.repairHours += fault.RecoveredAt.Sub(fault.OccurredAt).Hours()
+			acc.repaired++
+		}
+	}
+
+	// 按统计期内的实际收入计算每运行小时收入，没有收入的充电桩使用同类型充电桩的平均值
+	typeRevenue := make(map[model.PileType]float64)
+	typeUptime := make(map[model.PileType]float64)
+	for _, pile := range piles {
+		acc := accs[pile.ID]
+		uptime := periodHours - acc.stat.DowntimeHours
+		billing, err := s.billingRepo.GetBillingStatistics(startTime, endTime, &pile.ID)
+		if err != nil {
+			return nil, fmt.Errorf("获取充电桩 %s 的收入失败: %v", pile.ID, err)
+		}
+		if uptime > 0 {
+			acc.revenueRate = billing.TotalFee / uptime
+		}
+		typeRevenue[pile.PileType] += billing.TotalFee
+		typeUptime[pile.PileType] += uptime
+	}
+
+	// 降功率运行不计入停机，按损失的功率比例估算损失收入
+	degradedHours := make([]float64, len(degradations))
+	for i, degradation := range degradations {
+		acc, ok := accs[degradation.PileID]
+		if !ok {
+			continue
+		}
+		degradedHours[i] = periodOverlap(degradation.StartedAt, degradation.EndedAt, startTime, endTime).Hours()
+		acc.stat.DegradedPeriods++
+		acc.stat.DegradedHours += degradedHours[i]
+	}
+
+	report := &model.ReliabilityReport{
+		StartTime:    startTime,
+		EndTime:      endTime,
+		Thresholds:   s.thresholds,
+		Piles:        make([]*model.PileReliability, 0, len(piles)),
+		FaultTypes:   []*model.FaultTypeReliability{},
+		FlaggedPiles: []string{},
+	}
+
+	var totalUptime float64
+	for _, pile := range piles {
+		acc := accs[pile.ID]
+		if acc.revenueRate == 0 && typeUptime[pile.PileType] > 0 {
+			acc.revenueRate = typeRevenue[pile.PileType] / typeUptime[pile.PileType]
+		}
+
+		stat := acc.stat
+		uptime := periodHours - stat.DowntimeHours
+		totalUptime += uptime
+		stat.Availability = uptime / periodHours * 100
+		stat.LostRevenue = stat.DowntimeHours * acc.revenueRate
+		for i, degradation := range degradations {
+			if degradation.PileID == pile.ID {
+				stat.LostRevenue += degradedLoss(degradation, degradedHours[i], acc.revenueRate)
+			}
+		}
+		stat.MTBF = meanTimeBetweenFailures(uptime, stat.FaultCount)
+		stat.MTTR = meanTimeToRepair(acc.repairHours, acc.repaired)
+		stat.MaxFaultsInWindow = maxFaultsInWindow(acc.occurrences, startTime, s.alertWindow)
+
+		// 告警阈值
+		if stat.MaxFaultsInWindow >= s.thresholds.AlertFaultCount {
+			stat.FlagReasons = append(stat.FlagReasons, fmt.Sprintf("%s内故障%d次", formatAlertWindow(s.alertWindow), stat.MaxFaultsInWindow))
+		}
+		if s.thresholds.MinAvailability > 0 && stat.Availability < s.thresholds.MinAvailability {
+			stat.FlagReasons = append(stat.FlagReasons, fmt.Sprintf("可用率%.2f%%低于%.2f%%", stat.Availability, s.thresholds.MinAvailability))
+		}
+		if len(stat.FlagReasons) > 0 {
+			stat.Flagged = true
+			report.FlaggedPiles = append(report.FlaggedPiles, pile.ID)
+		}
+		report.Piles = append(report.Piles, stat)
+	}
+
+	// 按故障类型汇总
+	byType := make(map[model.FaultType]*model.FaultTypeReliability)
+	typeStat := func(faultType model.FaultType) *model.FaultTypeReliability {
+		stat, ok := byType[faultType]
+		if !ok {
+			stat = &model.FaultTypeReliability{FaultType: faultType}
+			byType[faultType] = stat
+			report.FaultTypes = append(report.FaultTypes, stat)
+		}
+		return stat
+	}
+	typeRepairHours := make(map[model.FaultType]float64)
+	typeRepaired := make(map[model.FaultType]int)
+	for i, fault := range faults {
+		acc, ok := accs[fault.PileID]
+		if !ok || (downtimes[i] == 0 && fault.OccurredAt.Before(startTime)) {
+			continue
+		}
+		stat := typeStat(fault.FaultType)
+		stat.DowntimeHours += downtimes[i]
+		stat.LostRevenue += downtimes[i] * acc.revenueRate
+
+		if fault.OccurredAt.Before(startTime) {
+			continue
+		}
+		stat.FaultCount++
+		stat.AffectedSessions += fault.AffectedSessions
+		if fault.RecoveredAt != nil {
+			typeRepairHours[fault.FaultType] += fault.RecoveredAt.Sub(fault.OccurredAt).Hours()
+			typeRepaired[fault.FaultType]++
+		}
+	}
+	for i, degradation := range degradations {
+		acc, ok := accs[degradation.PileID]
+		if !ok {
+			continue
+		}
+		stat := typeStat(degradation.FaultType)
+		stat.DegradedPeriods++
+		stat.DegradedHours += degradedHours[i]
+		stat.LostRevenue += degradedLoss(degradation, degradedHours[i], acc.revenueRate)
+	}
+	for _, stat := range report.FaultTypes {
+		stat.MTBF = meanTimeBetweenFailures(totalUptime, stat.FaultCount)
+		stat.MTTR = meanTimeToRepair(typeRepairHours[stat.FaultType], typeRepaired[stat.FaultType])
+	}
+	sort.Slice(report.FaultTypes, func(i, j int) bool {
+		return report.FaultTypes[i].FaultCount > report.FaultTypes[j].FaultCount
+	})
+
+	return report, nil
+}
+
+// faultDowntime 故障在统计期内的停机时长，未恢复的故障计到统计期结束
+func faultDowntime(fault *model.FaultRecord, startTime, endTime time.Time) time.Duration {
+	return periodOverlap(fault.OccurredAt, fault.RecoveredAt, startTime, endTime)
+}
+
+// periodOverlap 时段与统计期重叠的时长，未结束的时段计到统计期结束
+func periodOverlap(startedAt time.Time, endedAt *time.Time, startTime, endTime time.Time) time.Duration {
+	from, to := startedAt, endTime
+	if endedAt != nil && endedAt.Before(endTime) {
+		to = *endedAt
+	}
+	if from.Before(startTime) {
+		from = startTime
+	}
+	if !to.After(from) {
+		return 0
+	}
+	return to.Sub(from)
+}
+
+// degradedLoss 降功率运行损失收入估算：按损失的功率比例折算运行收入
+func degradedLoss(degradation *model.PileDegradation, hours, revenueRate float64) float64 {
+	if degradation.RatedPower <= 0 {
+		return 0
+	}
+	return hours * revenueRate * (1 - degradation.EffectivePower/degradation.RatedPower)
+}
+
+// maxFaultsInWindow 以统计期内每次故障为窗口终点，统计任一窗口内的最多故障次数
+func maxFaultsInWindow(occurrences []time.Time, startTime time.Time, window time.Duration) int {
+	maxCount, first := 0, 0
+	for last, t := range occurrences {
+		for !occurrences[first].After(t.Add(-window)) {
+			first++
+		}
+		if !t.Before(startTime) && last-first+1 > maxCount {
+			maxCount = last - first + 1
+		}
+	}
+	return maxCount
+}
+
+// formatAlertWindow 告警窗口整天数时按天显示，否则按小时显示
+func formatAlertWindow(window time.Duration) string {
+	if window%(24*time.Hour) == 0 {
+		return fmt.Sprintf("%d天", int(window/(24*time.Hour)))
+	}
+	return fmt.Sprintf("%g小时", window.Hours())
+}
+
+// meanTimeBetweenFailures 平均故障间隔（小时）：运行时长除以故障次数，没有故障时为空
+func meanTimeBetweenFailures(uptimeHours float64, faultCount int) *float64 {
+	if faultCount <= 0 {
+		return nil
+	}
+	return hoursPtr(uptimeHours / float64(faultCount))
+}
+
+// meanTimeToRepair 平均修复时间（小时）：只统计已恢复的故障，没有已恢复的故障时为空
+func meanTimeToRepair(repairHours float64, repaired int) *float64 {
+	if repaired <= 0 {
+		return nil
+	}
+	return hoursPtr(repairHours / float64(repaired))
+}
+
+// hoursPtr 返回小时数的指针，用于可能为空的统计值
+func hoursPtr(hours float64) *float64 {
+	return &hours
+}
@@ -0,0 +1,137 @@
+package service
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMaxFaultsInWindow(t *testing.T) {
+	start := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	day := 24 * time.Hour
+	window := 7 * day
+
+	tests := []struct {
+		name        string
+		occurrences []time.Time
+		want        int
+	}{
+		{name: "没有故障", occurrences: nil, want: 0},
+		{name: "单次故障", occurrences: []time.Time{start.Add(day)}, want: 1},
+		{
+			name:        "窗口内多次故障",
+			occurrences: []time.Time{start.Add(day), start.Add(2 * day), start.Add(3 * day)},
+			want:        3,
+		},
+		{
+			name:        "间隔超过窗口",
+			occurrences: []time.Time{start, start.Add(8 * day), start.Add(16 * day)},
+			want:        1,
+		},
+		{
+			name:        "恰好相隔一个窗口不计入同一窗口",
+			occurrences: []time.Time{start, start.Add(window)},
+			want:        1,
+		},
+		{
+			name:        "取最密集的窗口",
+			occurrences: []time.Time{start, start.Add(10 * day), start.Add(11 * day), start.Add(12 * day), start.Add(30 * day)},
+			want:        3,
+		},
+		{
+			name:        "统计期前的故障计入窗口",
+			occurrences: []time.Time{start.Add(-2 * day), start.Add(-day), start.Add(day)},
+			want:        3,
+		},
+		{
+			name:        "故障全部在统计期前",
+			occurrences: []time.Time{start.Add(-3 * day), start.Add(-2 * day)},
+			want:        0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := maxFaultsInWindow(tt.occurrences, start, window); got != tt.want {
+				t.Errorf("maxFaultsInWindow() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMeanTimeBetweenFailures(t *testing.T) {
+	tests := []struct {
+		name        string
+		uptimeHours float64
+		faultCount  int
+		want        *float64
+	}{
+		{name: "没有故障", uptimeHours: 720, faultCount: 0, want: nil},
+		{name: "一次故障", uptimeHours: 720, faultCount: 1, want: hoursPtr(720)},
+		{name: "多次故障", uptimeHours: 700, faultCount: 4, want: hoursPtr(175)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assertHours(t, meanTimeBetweenFailures(tt.uptimeHours, tt.faultCount), tt.want)
+		})
+	}
+}
+
+func TestMeanTimeToRepair(t *testing.T) {
+	tests := []struct {
+		name        string
+		repairHours float64
+		repaired    int
+		want        *float64
+	}{
+		{name: "没有已恢复的故障", repairHours: 0, repaired: 0, want: nil},
+		{name: "一次修复", repairHours: 2.5, repaired: 1, want: hoursPtr(2.5)},
+		{name: "多次修复取平均", repairHours: 9, repaired: 3, want: hoursPtr(3)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assertHours(t, meanTimeToRepair(tt.repairHours, tt.repaired), tt.want)
+		})
+	}
+}
+
+func TestPeriodOverlap(t *testing.T) {
+	start := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	end := start.Add(10 * time.Hour)
+	at := func(h float64) time.Time { return start.Add(time.Duration(h * float64(time.Hour))) }
+	ptr := func(t time.Time) *time.Time { return &t }
+
+	tests := []struct {
+		name      string
+		startedAt time.Time
+		endedAt   *time.Time
+		want      time.Duration
+	}{
+		{name: "完全在统计期内", startedAt: at(1), endedAt: ptr(at(3)), want: 2 * time.Hour},
+		{name: "开始于统计期前", startedAt: at(-2), endedAt: ptr(at(1)), want: time.Hour},
+		{name: "未结束计到统计期结束", startedAt: at(8), endedAt: nil, want: 2 * time.Hour},
+		{name: "结束于统计期后", startedAt: at(9), endedAt: ptr(at(12)), want: time.Hour},
+		{name: "统计期前已结束", startedAt: at(-3), endedAt: ptr(at(-1)), want: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := periodOverlap(tt.startedAt, tt.endedAt, start, end); got != tt.want {
+				t.Errorf("periodOverlap() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// assertHours 比较可能为空的小时数
+func assertHours(t *testing.T, got, want *float64) {
+	t.Helper()
+	switch {
+	case got == nil && want == nil:
+	case got == nil || want == nil:
+		t.Errorf("got %v, want %v", got, want)
+	case *got != *want:
+		t.Errorf("got %v, want %v", *got, *want)
+	}
+}
@@ -1,6 +1,7 @@
 package service
 
 import (
+	"errors"
 	"fmt"
 	"log"
 	"sort"
@@ -15,17 +16,27 @@ import (
 
 // SchedulerService 调度服务
 type SchedulerService struct {
-	requestRepo      *repository.ChargingRequestRepository
-	pileRepo         *repository.ChargingPileRepository
-	queueRepo        *repository.QueueRepository
-	sessionRepo      *repository.ChargingSessionRepository
-	systemRepo       *repository.SystemRepository
-	billingService   *BillingService
-	simulatorClient  *ChargingDispatcherClient // 模拟器客户端
-	waitingAreaLock  bool                      // 等候区锁定状态
-	requestChan      chan uuid.UUID            // 请求调度通道
-	stopChargingChan chan stopChargingReq      // 停止充电通道
-	mutex            *sync.Mutex
+	requestRepo         *repository.ChargingRequestRepository
+	pileRepo            *repository.ChargingPileRepository
+	queueRepo           *repository.QueueRepository
+	sessionRepo         *repository.ChargingSessionRepository
+	systemRepo          *repository.SystemRepository
+	vehicleRepo         *repository.VehicleRepository
+	billingService      *BillingService
+	auditService        *AuditService                     // 人工干预审计
+	maintenanceRepo     *repository.MaintenanceRepository // 计划维护窗口
+	maintenanceLeadTime time.Duration                     // 计划维护开始前停止分配会与维护重叠的车辆
+	faultTicketService  *FaultTicketService               // 故障工单，工单修复后才允许恢复充电桩
+	notificationService *NotificationService              // 叫号、充电开始与结束、故障改派时通知用户
+	webhookService      *WebhookService                   // 请求与充电状态变化推送给第三方
+	trackDeparture      bool                              // 充电结束后车辆驶离前桩位仍被占用
+	idleGracePeriod     time.Duration                     // 充电结束后的免费停留时间
+	waitlistAdmitter    func()                            // 等候区空出位置后让候补请求入场
+	simulatorClient     ChargingDispatcher                // 充电指令下发（指令队列，最终经模拟器HTTP或OCPP）
+	waitingAreaLock     bool                              // 等候区锁定状态
+	requestChan         chan uuid.UUID                    // 请求调度通道
+	stopChargingChan    chan stopChargingReq              // 停止充电通道
+	mutex               *sync.Mutex
 }
 
 // stopChargingReq 停止充电请求
@@ -41,6 +52,7 @@ func NewSchedulerService(
 	queueRepo *repository.QueueRepository,
 	sessionRepo *repository.ChargingSessionRepository,
 	systemRepo *repository.SystemRepository,
+	vehicleRepo *repository.VehicleRepository,
 ) *SchedulerService {
 	svc := &SchedulerService{
 		requestRepo:      requestRepo,
@@ -48,6 +60,7 @@ func NewSchedulerService(
 		queueRepo:        queueRepo,
 		sessionRepo:      sessionRepo,
 		systemRepo:       systemRepo,
+		vehicleRepo:      vehicleRepo,
 		waitingAreaLock:  false,
 		requestChan:      make(chan uuid.UUID, 100),
 		stopChargingChan: make(chan stopChargingReq, 100),
@@ -67,7 +80,7 @@ func (s *SchedulerService) SetBillingService(billingService *BillingService) {
 }
 
 // SetSimulatorClient 设置模拟器客户端（用于向模拟器发送充电指令）
-func (s *SchedulerService) SetSimulatorClient(client *ChargingDispatcherClient) {
+func (s *SchedulerService) SetSimulatorClient(client ChargingDispatcher) {
 	s.simulatorClient = client
 }
 
@@ -87,29 +100,73 @@ func (s *SchedulerService) StopCharging(requestID uuid.UUID, cancel bool) error
 	return nil
 }
 
-// UpdateChargingProgress 更新充电进度
-func (s *SchedulerService) UpdateChargingProgress(pileID, userID string, currentCapacity float64, remainingTime int) error {
-	s.mutex.Lock()
-	defer s.mutex.Unlock()
+// 回调会话校验错误
+var (
+	// ErrSessionEnded 回调对应的充电会话已结束，延迟或重复的回调不再生效
+	ErrSessionEnded = errors.New("充电会话已结束")
+	// ErrSessionMismatch 回调与充电会话不匹配
+	ErrSessionMismatch = errors.New("充电会话与回调不匹配")
+)
 
-	// 获取充电桩的充电会话
-	session, err := s.sessionRepo.GetActiveSessionByPileID(pileID)
+// findCallbackSession 查找模拟器回调对应的活跃充电会话
+// 携带会话ID时按ID查找并校验充电桩与用户；旧版回调按充电桩查找当前活跃会话
+func (s *SchedulerService) findCallbackSession(sessionID uuid.UUID, pileID, userID string) (*model.ChargingSession, error) {
+	var session *model.ChargingSession
+	var err error
+	if sessionID != uuid.Nil {
+		session, err = s.sessionRepo.GetByID(sessionID)
+		if errors.Is(err, repository.ErrSessionNotFound) {
+			return nil, fmt.Errorf("%w: 会话 %s 不存在", ErrSessionMismatch, sessionID)
+		}
+	} else {
+		session, err = s.sessionRepo.GetActiveSessionByPileID(pileID)
+	}
 	if err != nil {
-		return fmt.Errorf("获取充电会话失败: %w", err)
+		return nil, fmt.Errorf("获取充电会话失败: %w", err)
 	}
 
 	// 验证充电会话是否存在
 	if session == nil {
-		return fmt.Errorf("充电桩 %s 没有活跃的充电会话", pileID)
+		return nil, fmt.Errorf("%w: 充电桩 %s 没有活跃的充电会话", ErrSessionMismatch, pileID)
 	}
 
-	// 验证用户ID是否匹配
+	// 验证充电桩与用户ID是否匹配
+	if session.PileID != pileID {
+		return nil, fmt.Errorf("%w: 会话充电桩=%s, 请求充电桩=%s", ErrSessionMismatch, session.PileID, pileID)
+	}
 	if session.UserID.String() != userID {
-		return fmt.Errorf("用户ID不匹配: 会话用户=%s, 请求用户=%s", session.UserID, userID)
+		return nil, fmt.Errorf("%w: 会话用户=%s, 请求用户=%s", ErrSessionMismatch, session.UserID, userID)
+	}
+
+	if session.Status != model.SessionStatusActive {
+		return nil, fmt.Errorf("%w: 会话 %s 状态为 %s", ErrSessionEnded, session.ID, session.Status)
+	}
+
+	return session, nil
+}
+
+// UpdateChargingProgress 更新充电进度
+func (s *SchedulerService) UpdateChargingProgress(progress *model.ChargingProgress) error {
+	pileID, userID := progress.PileID, progress.UserID
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	// 获取回调对应的充电会话，已结束的会话不再接受进度
+	session, err := s.findCallbackSession(progress.SessionID, pileID, userID)
+	if err != nil {
+		return err
 	}
 
-	// 更新充电会话的充电量
-	session.ActualCapacity = currentCapacity
+	// 更新充电会话的充电量、SoC与瞬时功率
+	session.ActualCapacity = progress.CurrentCapacity
+	if progress.CurrentSoC != nil {
+		session.CurrentSoC = progress.CurrentSoC
+	}
+	currentPower := progress.CurrentPower
+	session.CurrentPower = &currentPower
+	remainingTime := progress.RemainingTime
+	session.RemainingTime = &remainingTime
 
 	// 保存更新的会话
 	err = s.sessionRepo.Update(session)
@@ -117,8 +174,13 @@ func (s *SchedulerService) UpdateChargingProgress(pileID, userID string, current
 		return fmt.Errorf("更新充电会话失败: %w", err)
 	}
 
-	log.Printf("已更新充电进度: 充电桩=%s, 用户=%s, 当前电量=%.1fkWh, 剩余时间=%d秒",
-		pileID, userID, currentCapacity, remainingTime)
+	if session.CurrentSoC != nil {
+		log.Printf("已更新充电进度: 充电桩=%s, 用户=%s, 当前电量=%.1fkWh, SoC=%.1f%%, 功率=%.1fkW, 剩余时间=%d秒",
+			pileID, userID, progress.CurrentCapacity, *session.CurrentSoC, progress.CurrentPower, progress.RemainingTime)
+	} else {
+		log.Printf("已更新充电进度: 充电桩=%s, 用户=%s, 当前电量=%.1fkWh, 功率=%.1fkW, 剩余时间=%d秒",
+			pileID, userID, progress.CurrentCapacity, progress.CurrentPower, progress.RemainingTime)
+	}
 
 	return nil
 }
@@ -160,6 +222,9 @@ func (s *SchedulerService) executeSchedule() {
 	} else {
 		s.executeNormalScheduling(config)
 	}
+
+	// 叫入充电区的车辆空出等候区位置
+	s.notifyWaitingAreaChanged()
 }
 
 // executeNormalScheduling 执行正常调度
@@ -203,6 +268,7 @@ func (s *SchedulerService) executeNormalScheduling(config *model.SchedulingConfi
 
 		fastRequests = fastRequests[1:]
 		s.scheduleRequestToPile(req.ID, bestPile.ID, bestPile.QueueLength+1)
+		s.notifyQueueCalled(req.ID)
 
 		// 更新本地充电桩队列长度以便下次计算
 		bestPile.QueueLength++
@@ -218,6 +284,7 @@ func (s *SchedulerService) executeNormalScheduling(config *model.SchedulingConfi
 
 		slowRequests = slowRequests[1:]
 		s.scheduleRequestToPile(req.ID, bestPile.ID, bestPile.QueueLength+1)
+		s.notifyQueueCalled(req.ID)
 
 		// 更新本地充电桩队列长度以便下次计算
 		bestPile.QueueLength++
@@ -226,9 +293,12 @@ func (s *SchedulerService) executeNormalScheduling(config *model.SchedulingConfi
 
 // sortRequests 根据配置的调度策略对请求进行排序
 func (s *SchedulerService) sortRequests(requests []*model.ChargingRequest) {
-	// 时间顺序调度：纯粹按队列号(时间)排序
+	// 管理员调整过顺序的请求按优先级在前，其余按队列号(时间)排序
 	sort.Slice(requests, func(i, j int) bool {
-		return requests[i].QueueNumber < requests[j].QueueNumber
+		if requests[i].Priority != requests[j].Priority {
+			return requests[i].Priority > requests[j].Priority
+		}
+		return requests[i].QueuedBefore(requests[j])
 	})
 }
 
@@ -236,6 +306,7 @@ func (s *SchedulerService) sortRequests(requests []*model.ChargingRequest) {
 func (s *SchedulerService) findBestPile(piles []*model.ChargingPile, requestedCapacity float64, maxQueueLen int) *model.ChargingPile {
 	var bestPile *model.ChargingPile
 	var minCompletionTime float64 = -1
+	maintenance := s.upcomingMaintenance()
 
 	for _, pile := range piles {
 		// 检查是否有空位
@@ -245,9 +316,14 @@ func (s *SchedulerService) findBestPile(piles []*model.ChargingPile, requestedCa
 
 		// 计算完成充电所需时长 = 等待时间 + 自己充电时间
 		waitTime := s.calculateWaitTime(pile.ID)
-		selfChargingTime := requestedCapacity / pile.Power * 3600 // 转换为秒
+		selfChargingTime := requestedCapacity / pile.EffectivePower() * 3600 // 转换为秒
 		completionTime := waitTime + selfChargingTime
 
+		// 完成时间与计划维护重叠的充电桩不再分配
+		if overlapsMaintenance(maintenance, pile.ID, completionTime) {
+			continue
+		}
+
 		if minCompletionTime < 0 || completionTime < minCompletionTime {
 			minCompletionTime = completionTime
 			bestPile = pile
@@ -273,10 +349,11 @@ func (s *SchedulerService) calculateWaitTime(pileID string) float64 {
 		return 0
 	}
 
-	var totalWaitTime float64 = 0
+	// 停留车辆驶离前无法开始充电
+	totalWaitTime := s.parkedWaitTime(pileID)
 	for _, req := range requests {
 		// 计算每个请求的充电时间（秒）
-		chargingTime := req.RequestedCapacity / pile.Power * 3600
+		chargingTime := req.RequestedCapacity / pile.EffectivePower() * 3600
 		totalWaitTime += chargingTime
 	}
 
@@ -300,7 +377,7 @@ func (s *SchedulerService) scheduleRequestToPile(requestID uuid.UUID, pileID str
 	}
 
 	// 计算预估等待时间
-	waitTime := s.calculateEstimatedWaitTime(pileID, request.RequestedCapacity, pile.Power)
+	waitTime := s.calculateEstimatedWaitTime(pileID, request.RequestedCapacity, pile.EffectivePower())
 
 	// 更新请求状态
 	err = s.requestRepo.AssignToPile(requestID, pileID, queuePosition, waitTime, model.RequestStatusQueued)
@@ -334,8 +411,18 @@ func (s *SchedulerService) scheduleRequestToPile(requestID uuid.UUID, pileID str
 		return
 	}
 
-	// 如果是第一个位置，开始充电
-	if queuePosition == 1 {
+	s.publish(model.WebhookEventRequestAssigned, map[string]any{
+		"requestId":         requestID.String(),
+		"userId":            request.UserID.String(),
+		"queueNumber":       request.QueueNumber,
+		"chargingMode":      request.ChargingMode,
+		"pileId":            pileID,
+		"queuePosition":     queuePosition,
+		"estimatedWaitTime": waitTime,
+	})
+
+	// 如果是第一个位置且桩位已空出，开始充电；否则等车辆驶离后开始
+	if queuePosition == 1 && !s.pileBlocked(pileID) {
 		s.startCharging(requestID, pileID)
 	}
 }
@@ -349,8 +436,8 @@ func (s *SchedulerService) calculateEstimatedWaitTime(pileID string, requestedCa
 		return 0
 	}
 
-	// 计算前面所有请求的充电时间总和
-	var totalWaitTime int
+	// 计算前面所有请求的充电时间总和，停留车辆驶离前无法开始充电
+	totalWaitTime := int(s.parkedWaitTime(pileID))
 	for _, req := range requests {
 		// 计算充电时间（秒）
 		chargingTime := int(req.RequestedCapacity / pilePower * 3600)
@@ -405,6 +492,9 @@ func (s *SchedulerService) startCharging(requestID uuid.UUID, pileID string) {
 		StartTime:         time.Now().UTC(),
 		Status:            model.SessionStatusActive,
 		Duration:          0,
+		StartSoC:          request.StartSoC,
+		TargetSoC:         request.TargetSoC,
+		CurrentSoC:        request.StartSoC,
 	}
 
 	_, err = s.sessionRepo.Create(session)
@@ -413,6 +503,23 @@ func (s *SchedulerService) startCharging(requestID uuid.UUID, pileID string) {
 		return
 	}
 
+	s.publish(model.WebhookEventChargingStarted, map[string]any{
+		"requestId":         requestID.String(),
+		"sessionId":         session.ID.String(),
+		"userId":            request.UserID.String(),
+		"queueNumber":       request.QueueNumber,
+		"pileId":            pileID,
+		"requestedCapacity": request.RequestedCapacity,
+		"startTime":         session.StartTime,
+	})
+	s.notify(request.UserID, model.NotificationChargingStarted, map[string]any{
+		"requestId":   requestID.String(),
+		"sessionId":   session.ID.String(),
+		"queueNumber": request.QueueNumber,
+		"pileId":      pileID,
+		"amount":      request.RequestedCapacity,
+	})
+
 	// 向模拟器发送充电指令
 	if s.simulatorClient != nil {
 		// 根据充电模式确定传递给模拟器的模式参数
@@ -421,18 +528,30 @@ func (s *SchedulerService) startCharging(requestID uuid.UUID, pileID string) {
 			chargingMode = "fast"
 		}
 
-		// 发送充电指令到模拟器
-		err = s.simulatorClient.AssignCharging(
-			pileID,
-			request.UserID.String(),
-			request.RequestedCapacity,
-			chargingMode,
-		)
+		assign := ChargingAssignRequest{
+			SessionID:         session.ID.String(),
+			PileID:            pileID,
+			UserID:            request.UserID.String(),
+			RequestedCapacity: request.RequestedCapacity,
+			ChargingMode:      chargingMode,
+			StartSoC:          request.StartSoC,
+			TargetSoC:         request.TargetSoC,
+		}
+		// 携带车辆电池容量与车辆配置，便于模拟器按SoC跟踪进度并套用充电曲线
+		if request.VehicleID != uuid.Nil && s.vehicleRepo != nil {
+			if vehicle, err := s.vehicleRepo.GetByID(request.VehicleID); err == nil {
+				assign.BatteryCapacity = vehicle.BatteryCapacity
+				assign.VehicleProfile = vehicle.VehicleProfile
+			}
+		}
+
+		// 充电指令加入指令队列，由队列重试投递，超时未确认时转故障处理
+		err = s.simulatorClient.AssignCharging(assign)
 		if err != nil {
-			log.Printf("向模拟器发送充电指令失败: %v", err)
-			// 即使发送失败，也不中断充电流程，仅记录日志
+			log.Printf("充电指令入队失败: %v", err)
+			// 即使入队失败，也不中断充电流程，仅记录日志
 		} else {
-			log.Printf("成功向模拟器发送充电指令: 充电桩=%s, 用户=%s, 电量=%.1f, 模式=%s",
+			log.Printf("充电指令已入队: 充电桩=%s, 用户=%s, 电量=%.1f, 模式=%s",
 				pileID, request.UserID.String(), request.RequestedCapacity, chargingMode)
 		}
 	} else {
@@ -445,54 +564,62 @@ func (s *SchedulerService) executeStopCharging(requestID uuid.UUID, cancel bool)
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
 
+	reason := "正常停止"
+	status := model.RequestStatusCompleted
+	if cancel {
+		reason = "用户取消"
+		status = model.RequestStatusCancelled
+	}
+
+	if _, err := s.stopChargingLocked(requestID, status, reason); err != nil {
+		log.Printf("停止充电失败: %v", err)
+	}
+}
+
+// stopChargingLocked 停止充电请求的会话，生成详单并让队列中的下一辆车开始充电
+// 调用方需持有调度锁；status 为请求结束后的状态（已完成或已取消）
+func (s *SchedulerService) stopChargingLocked(requestID uuid.UUID, status model.RequestStatus, reason string) (*model.ChargingSession, error) {
 	// 获取请求
 	request, err := s.requestRepo.GetByID(requestID)
 	if err != nil {
-		log.Printf("获取请求失败: %v", err)
-		return
+		return nil, fmt.Errorf("获取请求失败: %w", err)
 	}
 
 	// 检查请求状态
 	if request.Status != model.RequestStatusCharging {
-		log.Printf("请求不处于充电状态: %v", requestID)
-		return
+		return nil, fmt.Errorf("%w: 请求 %s 不处于充电状态", ErrInvalidIntervention, requestID)
 	}
 	pileID := request.PileID
 
-	// 向模拟器发送停止充电指令
-	if s.simulatorClient != nil {
-		reason := "正常停止"
-		if cancel {
-			reason = "用户取消"
-		}
+	// 获取充电会话
+	session, err := s.sessionRepo.GetByRequestID(requestID)
+	if err != nil {
+		return nil, fmt.Errorf("获取充电会话失败: %w", err)
+	}
 
-		err = s.simulatorClient.StopCharging(pileID, request.UserID.String(), reason)
+	// 停止充电指令加入指令队列
+	if s.simulatorClient != nil {
+		err = s.simulatorClient.StopCharging(ChargingStopRequest{
+			SessionID: session.ID.String(),
+			PileID:    pileID,
+			UserID:    request.UserID.String(),
+			Reason:    reason,
+		})
 		if err != nil {
-			log.Printf("向模拟器发送停止充电指令失败: %v", err)
-			// 即使发送失败，也不中断停止充电流程，仅记录日志
+			log.Printf("停止充电指令入队失败: %v", err)
+			// 即使入队失败，也不中断停止充电流程，仅记录日志
 		} else {
-			log.Printf("成功向模拟器发送停止充电指令: 充电桩=%s, 用户=%s, 原因=%s",
+			log.Printf("停止充电指令已入队: 充电桩=%s, 用户=%s, 原因=%s",
 				pileID, request.UserID.String(), reason)
 		}
 	} else {
 		log.Printf("模拟器客户端未配置，跳过发送停止充电指令")
 	}
 
-	// 获取充电会话
-	session, err := s.sessionRepo.GetByRequestID(requestID)
-	if err != nil {
-		log.Printf("获取充电会话失败: %v", err)
-		return
-	}
-
 	// 更新会话状态
 	now := time.Now().UTC()
 	session.EndTime = &now
-	session.Duration = now.Sub(session.StartTime).Seconds() // 获取充电桩信息（用于后续更新统计和队列）
-	if err != nil {
-		log.Printf("获取充电桩失败: %v", err)
-		return
-	}
+	session.Duration = now.Sub(session.StartTime).Seconds()
 
 	// 计算充电时长（小时），用于统计
 	chargingHours := float64(session.Duration) / 3600
@@ -514,43 +641,35 @@ func (s *SchedulerService) executeStopCharging(requestID uuid.UUID, cancel bool)
 	// 更新会话
 	err = s.sessionRepo.Update(session)
 	if err != nil {
-		log.Printf("更新充电会话失败: %v", err)
-		return
+		return nil, fmt.Errorf("更新充电会话失败: %w", err)
 	}
 
 	// 更新请求状态
-	var status model.RequestStatus
-	if cancel {
-		status = model.RequestStatusCancelled
-	} else {
-		status = model.RequestStatusCompleted
-	}
-
 	err = s.requestRepo.UpdateRequestStatus(requestID, status)
 	if err != nil {
-		log.Printf("更新充电请求状态失败: %v", err)
-		return
+		return nil, fmt.Errorf("更新充电请求状态失败: %w", err)
+	}
+	s.publishChargingCompleted(session, status)
+	if status == model.RequestStatusCompleted {
+		s.notifyChargingCompleted(session)
 	}
 
 	// 从队列中移除
 	err = s.queueRepo.RemoveFromQueueAndDecrementPile(requestID, pileID)
 	if err != nil {
-		log.Printf("从队列中移除失败: %v", err)
-		return
+		return nil, fmt.Errorf("从队列中移除失败: %w", err)
 	}
 
-	// 更新充电桩状态和队列长度
-	err = s.pileRepo.UpdateStatus(pileID, model.PileStatusAvailable)
+	// 释放充电桩，跟踪驶离时等车辆驶离后才空出
+	err = s.releasePileAfterCharging(session)
 	if err != nil {
-		log.Printf("更新充电桩状态失败: %v", err)
-		return
+		return nil, fmt.Errorf("更新充电桩状态失败: %w", err)
 	}
 
 	// 更新充电桩统计信息
 	err = s.pileRepo.UpdateStats(pileID, 1, chargingHours, session.ActualCapacity)
 	if err != nil {
-		log.Printf("更新充电桩统计信息失败: %v", err)
-		return
+		return nil, fmt.Errorf("更新充电桩统计信息失败: %w", err)
 	}
 
 	// 生成详单
@@ -561,40 +680,49 @@ func (s *SchedulerService) executeStopCharging(requestID uuid.UUID, cancel bool)
 		}
 	}
 
-	// 检查是否有下一个请求
+	// 重新排序队列并让下一辆车开始充电
+	if err := s.advancePileQueue(pileID); err != nil {
+		log.Printf("%v", err)
+	}
+
+	// 在释放锁后触发调度，避免死锁
+	go s.TryScheduleRequests()
+
+	return session, nil
+}
+
+// advancePileQueue 重新编排充电桩队列位置，新排到首位的请求开始充电
+func (s *SchedulerService) advancePileQueue(pileID string) error {
 	queueItems, err := s.queueRepo.GetQueueItemsByPile(pileID)
 	if err != nil {
-		log.Printf("获取队列项失败: %v", err)
-		return
+		return fmt.Errorf("获取队列项失败: %w", err)
 	}
 
-	// 重新排序队列
 	for i, item := range queueItems {
-		// 更新位置
 		newPosition := i + 1
-		if item.Position != newPosition {
-			err = s.queueRepo.UpdateQueuePosition(pileID, item.RequestID, newPosition)
-			if err != nil {
-				log.Printf("更新队列位置失败: %v", err)
-				continue
-			}
+		if item.Position == newPosition {
+			continue
+		}
 
-			err = s.requestRepo.AssignToPile(item.RequestID, pileID, newPosition, 0, model.RequestStatusQueued)
-			if err != nil {
-				log.Printf("更新请求队列位置失败: %v", err)
-				continue
-			}
+		err = s.queueRepo.UpdateQueuePosition(pileID, item.RequestID, newPosition)
+		if err != nil {
+			log.Printf("更新队列位置失败: %v", err)
+			continue
+		}
+
+		err = s.requestRepo.AssignToPile(item.RequestID, pileID, newPosition, 0, model.RequestStatusQueued)
+		if err != nil {
+			log.Printf("更新请求队列位置失败: %v", err)
+			continue
 		}
-		// 如果新位置是1，开始充电
-		if newPosition == 1 {
+
+		// 如果新位置是1且桩位已空出，开始充电；否则等车辆驶离后开始
+		if newPosition == 1 && !s.pileBlocked(pileID) {
 			s.startCharging(item.RequestID, pileID)
 		}
 	}
 
-	// 在释放锁后触发调度，避免死锁
-	defer func() {
-		go s.TryScheduleRequests()
-	}()
+	return nil
 }
 
 // HandlePileFault 处理充电桩故障
@@ -633,7 +761,7 @@ func (s *SchedulerService) HandlePileFault(pileID string, faultType string, desc
 	// 获取该充电桩上正在充电的会话
 	session, err := s.sessionRepo.GetActiveSessionByPileID(pileID)
 	if err != nil {
-		if err.Error() != "没有活跃的充电会话" {
+		if !errors.Is(err, repository.ErrNoActiveSession) {
 			log.Printf("获取充电会话失败: %v", err)
 		}
 	} else if session != nil { // 停止当前充电会话
@@ -662,7 +790,37 @@ func (s *SchedulerService) HandlePileFault(pileID string, faultType string, desc
 				log.Printf("生成部分详单失败: %v", err)
 			}
 		}
+
+		// 故障中断的充电不收取占位费
+		if _, err := s.sessionRepo.MarkDeparted(session.ID, now); err != nil {
+			log.Printf("记录车辆驶离失败: %v", err)
+		}
+	}
+
+	// 故障前已结束充电、仍停在桩位的车辆按故障时刻驶离结算
+	if parked, err := s.sessionRepo.GetParkedSessionByPileID(pileID); err != nil {
+		log.Printf("获取充电桩停留车辆失败: %v", err)
+	} else if parked != nil {
+		if _, err := s.departLocked(parked, time.Now().UTC()); err != nil {
+			log.Printf("%v", err)
+		}
+	}
+
+	faultData := map[string]any{
+		"pileId":      pileID,
+		"faultType":   faultType,
+		"description": description,
+	}
+	displacedIDs := make([]string, 0, len(queuedRequests))
+	for _, req := range queuedRequests {
+		displacedIDs = append(displacedIDs, req.ID.String())
 	}
+	faultData["displacedRequestIds"] = displacedIDs
+	if session != nil {
+		faultData["interruptedSessionId"] = session.ID.String()
+		faultData["interruptedCapacity"] = session.ActualCapacity
+	}
+	s.publish(model.WebhookEventPileFaulted, faultData)
 
 	// 获取故障充电桩的类型信息
 	faultPile, err := s.pileRepo.GetByID(pileID)
@@ -670,7 +828,7 @@ func (s *SchedulerService) HandlePileFault(pileID string, faultType string, desc
 		log.Printf("获取故障充电桩信息失败: %v", err)
 	} else if len(queuedRequests) > 0 {
 		// 执行智能故障调度
-		s.executeFaultRescheduling(faultPile.PileType, queuedRequests)
+		s.executeFaultRescheduling(pileID, faultPile.PileType, queuedRequests)
 	}
 
 	// 在释放锁后触发调度，避免死锁
@@ -746,6 +904,7 @@ func (s *SchedulerService) ExecuteBatchScheduling() error {
 			continue
 		}
 		s.scheduleRequestToPile(requestID, pileID, pile.QueueLength+1)
+		s.notifyQueueCalled(requestID)
 	}
 
 	return nil
@@ -754,6 +913,7 @@ func (s *SchedulerService) ExecuteBatchScheduling() error {
 // calculateGlobalOptimalAssignment 计算全局最优分配方案（忽略充电模式）
 func (s *SchedulerService) calculateGlobalOptimalAssignment(requests []*model.ChargingRequest, piles []*model.ChargingPile, maxQueueLen int) map[uuid.UUID]string {
 	assignment := make(map[uuid.UUID]string)
+	maintenance := s.upcomingMaintenance()
 
 	// 简化版本：使用贪心算法为每个请求找到最佳充电桩
 	for _, req := range requests {
@@ -767,8 +927,11 @@ func (s *SchedulerService) calculateGlobalOptimalAssignment(requests []*model.Ch
 
 			// 计算完成时间
 			waitTime := s.calculateWaitTimeForPile(pile)
-			selfChargingTime := req.RequestedCapacity / pile.Power * 3600
+			selfChargingTime := req.RequestedCapacity / pile.EffectivePower() * 3600
 			completionTime := waitTime + selfChargingTime
+			if overlapsMaintenance(maintenance, pile.ID, completionTime) {
+				continue
+			}
 
 			if minCompletionTime < 0 || completionTime < minCompletionTime {
 				minCompletionTime = completionTime
@@ -793,9 +956,9 @@ func (s *SchedulerService) calculateWaitTimeForPile(pile *model.ChargingPile) fl
 		return 0
 	}
 
-	var totalWaitTime float64 = 0
+	totalWaitTime := s.parkedWaitTime(pile.ID)
 	for _, req := range requests {
-		chargingTime := req.RequestedCapacity / pile.Power * 3600
+		chargingTime := req.RequestedCapacity / pile.EffectivePower() * 3600
 		totalWaitTime += chargingTime
 	}
 
@@ -803,24 +966,15 @@ func (s *SchedulerService) calculateWaitTimeForPile(pile *model.ChargingPile) fl
 }
 
 // CompleteCharging 处理充电完成
-func (s *SchedulerService) CompleteCharging(pileID, userID string, startTime, endTime time.Time, requestedCapacity, actualCapacity float64, chargingDuration int) error {
+// sessionID 为uuid.Nil时结束充电桩当前的活跃会话（旧版模拟器）
+func (s *SchedulerService) CompleteCharging(sessionID uuid.UUID, pileID, userID string, startTime, endTime time.Time, requestedCapacity, actualCapacity float64, endSoC *float64, chargingDuration int) error {
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
 
-	// 获取充电桩的活跃充电会话
-	session, err := s.sessionRepo.GetActiveSessionByPileID(pileID)
+	// 获取回调对应的充电会话，避免延迟的完成回调结束下一辆车的会话
+	session, err := s.findCallbackSession(sessionID, pileID, userID)
 	if err != nil {
-		return fmt.Errorf("获取充电会话失败: %w", err)
-	}
-
-	// 验证充电会话是否存在
-	if session == nil {
-		return fmt.Errorf("充电桩 %s 没有活跃的充电会话", pileID)
-	}
-
-	// 验证用户ID是否匹配
-	if session.UserID.String() != userID {
-		return fmt.Errorf("用户ID不匹配: 会话用户=%s, 请求用户=%s", session.UserID, userID)
+		return err
 	}
 
 	// 更新充电会话信息
@@ -828,6 +982,9 @@ func (s *SchedulerService) CompleteCharging(pileID, userID string, startTime, en
 	session.ActualCapacity = actualCapacity
 	session.Duration = float64(chargingDuration)
 	session.Status = model.SessionStatusCompleted
+	if endSoC != nil {
+		session.CurrentSoC = endSoC
+	}
 
 	// 保存更新的会话
 	err = s.sessionRepo.Update(session)
@@ -840,6 +997,8 @@ func (s *SchedulerService) CompleteCharging(pileID, userID string, startTime, en
 	if err != nil {
 		return fmt.Errorf("更新充电请求状态失败: %w", err)
 	}
+	s.publishChargingCompleted(session, model.RequestStatusCompleted)
+	s.notifyChargingCompleted(session)
 
 	// 从队列中移除
 	err = s.queueRepo.RemoveFromQueueAndDecrementPile(session.RequestID, pileID)
@@ -847,8 +1006,8 @@ func (s *SchedulerService) CompleteCharging(pileID, userID string, startTime, en
 		return fmt.Errorf("从队列中移除失败: %w", err)
 	}
 
-	// 更新充电桩状态和队列长度
-	err = s.pileRepo.UpdateStatus(pileID, model.PileStatusAvailable)
+	// 释放充电桩，跟踪驶离时等车辆驶离后才空出
+	err = s.releasePileAfterCharging(session)
 	if err != nil {
 		return fmt.Errorf("更新充电桩状态失败: %w", err)
 	}
@@ -869,32 +1028,8 @@ func (s *SchedulerService) CompleteCharging(pileID, userID string, startTime, en
 	}
 
 	// 重新排序队列并尝试开始下一个充电
-	queueItems, err := s.queueRepo.GetQueueItemsByPile(pileID)
-	if err != nil {
-		log.Printf("获取队列项失败: %v", err)
-	} else {
-		// 重新排序队列
-		for i, item := range queueItems {
-			newPosition := i + 1
-			if item.Position != newPosition {
-				err = s.queueRepo.UpdateQueuePosition(pileID, item.RequestID, newPosition)
-				if err != nil {
-					log.Printf("更新队列位置失败: %v", err)
-					continue
-				}
-
-				err = s.requestRepo.AssignToPile(item.RequestID, pileID, newPosition, 0, model.RequestStatusQueued)
-				if err != nil {
-					log.Printf("更新请求队列位置失败: %v", err)
-					continue
-				}
-			}
-
-			// 如果新位置是1，开始充电
-			if newPosition == 1 {
-				s.startCharging(item.RequestID, pileID)
-			}
-		}
+	if err := s.advancePileQueue(pileID); err != nil {
+		log.Printf("%v", err)
 	}
 
 	// 在持有锁的情况下，启动新的goroutine来触发调度
@@ -952,11 +1087,10 @@ func (s *SchedulerService) checkAndExecuteBatchScheduling(config *model.Scheduli
 	}
 }
 
-// executeFaultRescheduling 执行智能故障调度
-// 首先在同类型充电桩中查找空位，如果不够则重新调度所有排队请求
-func (s *SchedulerService) executeFaultRescheduling(pileType model.PileType, faultRequests []*model.ChargingRequest) {
-	log.Printf("开始执行故障调度，充电桩类型: %s, 故障队列请求数: %d", pileType, len(faultRequests))
-
+// executeFaultRescheduling 按配置的故障调度策略转移故障充电桩的排队请求，并将所用策略记入审计日志
+// 优先级调度：故障队列请求优先分配到同类型其他充电桩的空位，分配不下的放回等候区并排在最前
+// 时间顺序调度：故障队列请求与同类型充电桩中尚未充电的请求合并，按排队号重新分配
+func (s *SchedulerService) executeFaultRescheduling(pileID string, pileType model.PileType, faultRequests []*model.ChargingRequest) {
 	// 获取系统配置
 	config, err := s.systemRepo.GetSchedulingConfig()
 	if err != nil {
@@ -964,36 +1098,79 @@ func (s *SchedulerService) executeFaultRescheduling(pileType model.PileType, fau
 		return
 	}
 
+	policy := config.FaultReschedulingPolicy
+	log.Printf("开始执行故障调度，策略: %s, 充电桩类型: %s, 故障队列请求数: %d", policy, pileType, len(faultRequests))
+
+	displaced := make([]string, 0, len(faultRequests))
+	for _, req := range faultRequests {
+		displaced = append(displaced, req.QueueNumber)
+	}
+	detail := map[string]any{
+		"policy":    policy,
+		"pileType":  pileType,
+		"displaced": displaced,
+	}
+
+	var scheduled, total int
+	switch policy {
+	case model.FaultPolicyTimeOrder:
+		scheduled, total, err = s.executeGlobalReschedulingForFault(pileType, faultRequests, config)
+	default:
+		scheduled, err = s.executePriorityRescheduling(pileType, faultRequests, config)
+		total = len(faultRequests)
+	}
+	if err != nil {
+		log.Printf("故障调度失败: %v", err)
+	}
+
+	detail["rescheduled"] = scheduled
+	detail["returnedToWaiting"] = total - scheduled
+	s.recordAudit(uuid.Nil, model.AuditActionFaultReschedule, nil, pileID, "", detail, err)
+
+	s.notifyFaultRescheduled(pileID, faultRequests)
+}
+
+// executePriorityRescheduling 优先级调度：故障队列请求按排队号依次分配到同类型其他充电桩
+// 分配不下的请求放回等候区，优先级高于等候区现有请求，空出车位时最先叫号
+func (s *SchedulerService) executePriorityRescheduling(pileType model.PileType, faultRequests []*model.ChargingRequest, config *model.SchedulingConfig) (int, error) {
 	// 获取所有同类型的可用充电桩
 	availablePiles, err := s.pileRepo.GetAvailablePiles(pileType, config.ChargingQueueLen)
 	if err != nil {
-		log.Printf("获取可用充电桩失败: %v", err)
-		return
+		return 0, fmt.Errorf("获取可用充电桩失败: %w", err)
 	}
 
-	// 计算同类型充电桩的总空位数
-	totalAvailableSlots := 0
-	for _, pile := range availablePiles {
-		totalAvailableSlots += (config.ChargingQueueLen - pile.QueueLength)
+	unscheduled := s.redistributeFaultRequests(faultRequests, availablePiles, config.ChargingQueueLen)
+	if len(unscheduled) == 0 {
+		return len(faultRequests), nil
 	}
 
-	log.Printf("同类型充电桩总空位数: %d, 故障队列请求数: %d", totalAvailableSlots, len(faultRequests))
-	if totalAvailableSlots >= len(faultRequests) {
-		// 空位足够，直接将故障队列请求分配到其他充电桩
-		log.Printf("空位充足，直接分配故障队列请求到其他充电桩")
-		s.redistributeFaultRequests(faultRequests, availablePiles, config.ChargingQueueLen)
-	} else {
-		// 空位不够，需要重新调度所有同类型充电桩的排队请求
-		log.Printf("空位不足，执行全局重调度")
-		s.executeGlobalReschedulingForFault(pileType, faultRequests, config)
+	// 放回等候区的故障请求排在等候区现有请求之前
+	waiting, err := s.requestRepo.GetWaitingRequestsByMode(unscheduled[0].ChargingMode)
+	if err != nil {
+		return len(faultRequests) - len(unscheduled), fmt.Errorf("获取等候区请求失败: %w", err)
 	}
+	maxPriority := 0
+	for _, req := range waiting {
+		if req.Priority > maxPriority {
+			maxPriority = req.Priority
+		}
+	}
+	for i, req := range unscheduled {
+		if err := s.requestRepo.UpdatePriority(req.ID, maxPriority+len(unscheduled)-i); err != nil {
+			log.Printf("设置故障请求 %s 优先级失败: %v", req.ID, err)
+		}
+	}
+
+	log.Printf("优先级调度: %d 个故障请求无空位，已放回等候区最前", len(unscheduled))
+	return len(faultRequests) - len(unscheduled), nil
 }
 
-// redistributeFaultRequests 将故障队列请求重新分配到可用充电桩
-func (s *SchedulerService) redistributeFaultRequests(faultRequests []*model.ChargingRequest, availablePiles []*model.ChargingPile, maxQueueLen int) {
+// redistributeFaultRequests 将故障队列请求重新分配到可用充电桩，返回没有空位而放回等待区的请求
+func (s *SchedulerService) redistributeFaultRequests(faultRequests []*model.ChargingRequest, availablePiles []*model.ChargingPile, maxQueueLen int) []*model.ChargingRequest {
 	// 按排队号排序故障请求，保持原有顺序
 	s.sortRequests(faultRequests)
 
+	var unscheduled []*model.ChargingRequest
 	for _, req := range faultRequests {
 		// 找到最佳充电桩（队列最短且有空位）
 		bestPile := s.findBestPile(availablePiles, req.RequestedCapacity, maxQueueLen)
@@ -1005,6 +1182,7 @@ func (s *SchedulerService) redistributeFaultRequests(faultRequests []*model.Char
 			log.Printf("故障请求 %s (排队号: %s) 重新分配到充电桩 %s", req.ID, req.QueueNumber, bestPile.ID)
 		} else {
 			// 没有可用充电桩，将请求放回等待区
+			unscheduled = append(unscheduled, req)
 			err := s.requestRepo.AssignToPile(req.ID, "", 0, 0, model.RequestStatusWaiting)
 			if err != nil {
 				log.Printf("将请求 %s 放回等待区失败: %v", req.ID, err)
@@ -1014,22 +1192,24 @@ func (s *SchedulerService) redistributeFaultRequests(faultRequests []*model.Char
 		}
 		time.Sleep(10 * time.Millisecond) // 避免过度并发
 	}
+	return unscheduled
 }
 
-// executeGlobalReschedulingForFault 执行全局重调度处理故障
-func (s *SchedulerService) executeGlobalReschedulingForFault(pileType model.PileType, faultRequests []*model.ChargingRequest, config *model.SchedulingConfig) {
+// executeGlobalReschedulingForFault 执行全局重调度处理故障，返回成功调度的请求数与参与调度的总请求数
+func (s *SchedulerService) executeGlobalReschedulingForFault(pileType model.PileType, faultRequests []*model.ChargingRequest, config *model.SchedulingConfig) (int, int, error) {
 	// 收集所有同类型充电桩中的排队请求
 	allQueuedRequests, err := s.collectQueuedRequestsFromSameTypePiles(pileType)
 	if err != nil {
-		log.Printf("收集同类型充电桩排队请求失败: %v", err)
-		return
+		return 0, len(faultRequests), fmt.Errorf("收集同类型充电桩排队请求失败: %w", err)
 	}
 
 	// 合并故障请求和现有排队请求
 	allRequests := append(allQueuedRequests, faultRequests...)
 
-	// 按排队号排序，保持公平性
-	s.sortRequests(allRequests)
+	// 按排队号排序，保持公平性；时间顺序调度不考虑人工调整的优先级
+	sort.Slice(allRequests, func(i, j int) bool {
+		return allRequests[i].QueuedBefore(allRequests[j])
+	})
 
 	log.Printf("全局重调度: 总请求数 %d (故障: %d, 现有排队: %d)", len(allRequests), len(faultRequests), len(allQueuedRequests))
 
@@ -1050,8 +1230,7 @@ func (s *SchedulerService) executeGlobalReschedulingForFault(pileType model.Pile
 	// 获取可用的同类型充电桩
 	availablePiles, err := s.pileRepo.GetNormalPiles(pileType)
 	if err != nil {
-		log.Printf("获取可用充电桩失败: %v", err)
-		return
+		return 0, len(allRequests), fmt.Errorf("获取可用充电桩失败: %w", err)
 	}
 
 	// 计算可容纳的总请求数，考虑正在充电的充电桩
@@ -1094,6 +1273,7 @@ func (s *SchedulerService) executeGlobalReschedulingForFault(pileType model.Pile
 	}
 
 	log.Printf("全局重调度完成: 成功调度 %d 个请求，%d 个请求放回等待区", scheduledCount, len(allRequests)-scheduledCount)
+	return scheduledCount, len(allRequests), nil
 }
 
 // calculateActualAvailableCapacity 计算实际可用容量，考虑正在充电的充电桩
@@ -0,0 +1,295 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"log"
+
+	"backend/internal/model"
+
+	"github.com/google/uuid"
+)
+
+// ErrInvalidIntervention 人工干预的目标状态不允许该操作
+var ErrInvalidIntervention = errors.New("无法执行该操作")
+
+// SetAuditService 设置审计服务（避免循环依赖）
+func (s *SchedulerService) SetAuditService(auditService *AuditService) {
+	s.auditService = auditService
+}
+
+// MoveRequest 将排队中的请求移到其他同类型充电桩，targetPileID为空时放回等候区
+func (s *SchedulerService) MoveRequest(operatorID, requestID uuid.UUID, targetPileID, reason string) (err error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	var fromPileID string
+	detail := map[string]any{"toPileId": targetPileID}
+	defer func() {
+		detail["fromPileId"] = fromPileID
+		s.recordAudit(operatorID, model.AuditActionMoveRequest, &requestID, fromPileID, reason, detail, err)
+	}()
+
+	request, err := s.requestRepo.GetByID(requestID)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidIntervention, err)
+	}
+	if request.Status != model.RequestStatusQueued {
+		return fmt.Errorf("%w: 只能移动排队中的请求，当前状态为 %s", ErrInvalidIntervention, request.Status)
+	}
+	fromPileID = request.PileID
+	if targetPileID == fromPileID {
+		return fmt.Errorf("%w: 请求已在充电桩 %s 排队", ErrInvalidIntervention, targetPileID)
+	}
+
+	// 校验目标充电桩：类型与充电模式一致、可用且队列未满
+	if targetPileID != "" {
+		pile, err := s.pileRepo.GetByID(targetPileID)
+		if err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidIntervention, err)
+		}
+		if string(pile.PileType) != string(request.ChargingMode) {
+			return fmt.Errorf("%w: 充电桩 %s 类型为 %s，与请求的充电模式 %s 不符", ErrInvalidIntervention, targetPileID, pile.PileType, request.ChargingMode)
+		}
+		if pile.Status != model.PileStatusAvailable && pile.Status != model.PileStatusOccupied {
+			return fmt.Errorf("%w: 充电桩 %s 当前状态为 %s", ErrInvalidIntervention, targetPileID, pile.Status)
+		}
+		config, err := s.systemRepo.GetSchedulingConfig()
+		if err != nil {
+			return fmt.Errorf("获取系统配置失败: %w", err)
+		}
+		if pile.QueueLength >= config.ChargingQueueLen {
+			return fmt.Errorf("%w: 充电桩 %s 队列已满", ErrInvalidIntervention, targetPileID)
+		}
+	}
+
+	// 从原充电桩队列移除，后面的车辆前移
+	if err := s.queueRepo.RemoveFromQueueAndDecrementPile(requestID, fromPileID); err != nil {
+		return fmt.Errorf("从队列中移除失败: %w", err)
+	}
+	if err := s.advancePileQueue(fromPileID); err != nil {
+		log.Printf("%v", err)
+	}
+
+	if targetPileID == "" {
+		// 放回等候区，由调度器按等候区顺序重新分配
+		if err := s.requestRepo.AssignToPile(requestID, "", 0, 0, model.RequestStatusWaiting); err != nil {
+			return fmt.Errorf("放回等候区失败: %w", err)
+		}
+	} else {
+		pile, err := s.pileRepo.GetByID(targetPileID)
+		if err != nil {
+			return fmt.Errorf("获取充电桩失败: %w", err)
+		}
+		s.scheduleRequestToPile(requestID, targetPileID, pile.QueueLength+1)
+
+		// scheduleRequestToPile 只记录日志，这里确认请求已进入目标队列
+		moved, err := s.requestRepo.GetByID(requestID)
+		if err != nil {
+			return fmt.Errorf("获取请求失败: %w", err)
+		}
+		if moved.PileID != targetPileID {
+			return fmt.Errorf("调度到充电桩 %s 失败，请求当前状态为 %s", targetPileID, moved.Status)
+		}
+		detail["queuePosition"] = moved.QueuePosition
+	}
+
+	log.Printf("人工移动请求: 请求=%s, 原充电桩=%s, 目标=%s", requestID, fromPileID, targetPileID)
+	go s.TryScheduleRequests()
+	return nil
+}
+
+// ForceStopCharging 强制停止充电中的请求，按已充电量结束会话并生成详单
+func (s *SchedulerService) ForceStopCharging(operatorID, requestID uuid.UUID, reason string) (session *model.ChargingSession, err error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	var pileID string
+	detail := map[string]any{}
+	defer func() {
+		s.recordAudit(operatorID, model.AuditActionForceStop, &requestID, pileID, reason, detail, err)
+	}()
+
+	request, err := s.requestRepo.GetByID(requestID)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidIntervention, err)
+	}
+	pileID = request.PileID
+
+	session, err = s.stopChargingLocked(requestID, model.RequestStatusCompleted, "管理员强制停止: "+reason)
+	if err != nil {
+		return nil, err
+	}
+
+	detail["sessionId"] = session.ID
+	detail["sessionStatus"] = session.Status
+	detail["actualCapacity"] = session.ActualCapacity
+	log.Printf("人工强制停止充电: 请求=%s, 充电桩=%s, 原因=%s", requestID, pileID, reason)
+	return session, nil
+}
+
+// ReorderWaitingArea 按给定顺序调整等候区某一充电模式的请求
+// 列出的请求排在最前，未列出的请求按原顺序排在其后
+func (s *SchedulerService) ReorderWaitingArea(operatorID uuid.UUID, mode model.ChargingMode, requestIDs []uuid.UUID, reason string) (err error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	detail := map[string]any{"chargingMode": mode}
+	defer func() {
+		s.recordAudit(operatorID, model.AuditActionReorderWaiting, nil, "", reason, detail, err)
+	}()
+
+	waiting, err := s.requestRepo.GetWaitingRequestsByMode(mode)
+	if err != nil {
+		return fmt.Errorf("获取等候区请求失败: %w", err)
+	}
+	s.sortRequests(waiting)
+
+	// 记录调整前的顺序，并校验请求都在该模式的等候区中且不重复
+	waitingByID := make(map[uuid.UUID]*model.ChargingRequest, len(waiting))
+	before := make([]string, 0, len(waiting))
+	for _, req := range waiting {
+		waitingByID[req.ID] = req
+		before = append(before, req.QueueNumber)
+	}
+	detail["before"] = before
+
+	after := make([]string, 0, len(requestIDs))
+	seen := make(map[uuid.UUID]bool, len(requestIDs))
+	for _, id := range requestIDs {
+		req, ok := waitingByID[id]
+		if !ok {
+			return fmt.Errorf("%w: 请求 %s 不在%s等候区中", ErrInvalidIntervention, id, mode)
+		}
+		if seen[id] {
+			return fmt.Errorf("%w: 请求 %s 重复", ErrInvalidIntervention, id)
+		}
+		seen[id] = true
+		after = append(after, req.QueueNumber)
+	}
+	detail["after"] = after
+
+	if err := s.requestRepo.SetWaitingPriorities(mode, requestIDs); err != nil {
+		return err
+	}
+
+	log.Printf("人工调整等候区顺序: 模式=%s, 顺序=%v", mode, after)
+	go s.TryScheduleRequests()
+	return nil
+}
+
+// CancelRequestByAdmin 代用户取消请求，充电中的请求按已充电量结算
+func (s *SchedulerService) CancelRequestByAdmin(operatorID, requestID uuid.UUID, reason string) (err error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	var pileID string
+	detail := map[string]any{}
+	defer func() {
+		s.recordAudit(operatorID, model.AuditActionCancelRequest, &requestID, pileID, reason, detail, err)
+	}()
+
+	request, err := s.requestRepo.GetByID(requestID)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidIntervention, err)
+	}
+	pileID = request.PileID
+	detail["previousStatus"] = request.Status
+	detail["userId"] = request.UserID
+
+	switch request.Status {
+	case model.RequestStatusWaitlisted, model.RequestStatusWaiting:
+		// 候补队列或等候区：空出的位置让给下一位候补请求
+		if err := s.requestRepo.UpdateRequestStatus(requestID, model.RequestStatusCancelled); err != nil {
+			return fmt.Errorf("更新充电请求状态失败: %w", err)
+		}
+		s.notifyWaitingAreaChanged()
+
+	case model.RequestStatusQueued:
+		// 从充电桩队列移除，后面的车辆前移
+		if err := s.queueRepo.RemoveFromQueueAndDecrementPile(requestID, pileID); err != nil {
+			return fmt.Errorf("从队列中移除失败: %w", err)
+		}
+		if err := s.requestRepo.UpdateRequestStatus(requestID, model.RequestStatusCancelled); err != nil {
+			return fmt.Errorf("更新充电请求状态失败: %w", err)
+		}
+		if err := s.advancePileQueue(pileID); err != nil {
+			log.Printf("%v", err)
+		}
+		go s.TryScheduleRequests()
+
+	case model.RequestStatusCharging:
+		session, err := s.stopChargingLocked(requestID, model.RequestStatusCancelled, "管理员代取消: "+reason)
+		if err != nil {
+			return err
+		}
+		detail["sessionId"] = session.ID
+		detail["actualCapacity"] = session.ActualCapacity
+
+	default:
+		return fmt.Errorf("%w: 请求已完成或已取消", ErrInvalidIntervention)
+	}
+
+	log.Printf("人工取消请求: 请求=%s, 原状态=%s, 原因=%s", requestID, request.Status, reason)
+	return nil
+}
+
+// GetFaultReschedulingPolicy 获取当前的故障调度策略
+func (s *SchedulerService) GetFaultReschedulingPolicy() (model.FaultReschedulingPolicy, error) {
+	config, err := s.systemRepo.GetSchedulingConfig()
+	if err != nil {
+		return "", fmt.Errorf("获取系统配置失败: %w", err)
+	}
+	return config.FaultReschedulingPolicy, nil
+}
+
+// SetFaultReschedulingPolicy 修改故障调度策略，在调度锁内生效，进行中的故障调度使用修改前的策略
+func (s *SchedulerService) SetFaultReschedulingPolicy(operatorID uuid.UUID, policy model.FaultReschedulingPolicy, reason string) (err error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	detail := map[string]any{"to": policy}
+	defer func() {
+		s.recordAudit(operatorID, model.AuditActionFaultPolicy, nil, "", reason, detail, err)
+	}()
+
+	if !model.IsValidFaultReschedulingPolicy(policy) {
+		return fmt.Errorf("%w: 无效的故障调度策略 %s", ErrInvalidIntervention, policy)
+	}
+
+	config, err := s.systemRepo.GetSchedulingConfig()
+	if err != nil {
+		return fmt.Errorf("获取系统配置失败: %w", err)
+	}
+	detail["from"] = config.FaultReschedulingPolicy
+
+	if err := s.systemRepo.UpdateConfig("fault_rescheduling_policy", string(policy)); err != nil {
+		return fmt.Errorf("保存故障调度策略失败: %w", err)
+	}
+
+	log.Printf("故障调度策略修改: %s -> %s, 原因=%s", config.FaultReschedulingPolicy, policy, reason)
+	return nil
+}
+
+// recordAudit 记录人工干预的审计日志，失败的操作同样记录；operatorID 为空时记为系统操作
+func (s *SchedulerService) recordAudit(operatorID uuid.UUID, action model.AuditAction, requestID *uuid.UUID, pileID, reason string, detail map[string]any, err error) {
+	if s.auditService == nil {
+		return
+	}
+
+	entry := &model.AuditLog{
+		Action:    action,
+		RequestID: requestID,
+		PileID:    pileID,
+		Reason:    reason,
+		Detail:    detail,
+		Success:   err == nil,
+	}
+	if operatorID != uuid.Nil {
+		entry.OperatorID = &operatorID
+	}
+	if err != nil {
+		entry.ErrorMessage = err.Error()
+	}
+	s.auditService.Record(entry)
+}
@@ -0,0 +1,202 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"backend/internal/model"
+
+	"github.com/google/uuid"
+)
+
+// 车辆驶离错误
+var (
+	// ErrNoParkedVehicle 没有充电已结束、等待驶离的车辆
+	ErrNoParkedVehicle = errors.New("没有等待驶离的车辆")
+	// ErrVehicleStillCharging 车辆仍在充电，需先停止充电
+	ErrVehicleStillCharging = errors.New("车辆仍在充电")
+)
+
+// SetOverstayPolicy 启用车辆驶离跟踪：充电结束后车辆驶离前桩位仍被占用，gracePeriod为免费停留时间
+func (s *SchedulerService) SetOverstayPolicy(gracePeriod time.Duration) {
+	s.trackDeparture = true
+	s.idleGracePeriod = gracePeriod
+}
+
+// pileBlocked 充电桩上是否停着充电已结束、尚未驶离的车辆
+func (s *SchedulerService) pileBlocked(pileID string) bool {
+	if !s.trackDeparture {
+		return false
+	}
+	session, err := s.sessionRepo.GetParkedSessionByPileID(pileID)
+	if err != nil {
+		log.Printf("获取充电桩 %s 停留车辆失败: %v", pileID, err)
+		return false
+	}
+	return session != nil
+}
+
+// parkedWaitTime 停留车辆剩余的免费停留时间（秒），用于估算排队车辆的等待时间
+func (s *SchedulerService) parkedWaitTime(pileID string) float64 {
+	if !s.trackDeparture {
+		return 0
+	}
+	session, err := s.sessionRepo.GetParkedSessionByPileID(pileID)
+	if err != nil || session == nil {
+		return 0
+	}
+	remaining := s.idleGracePeriod - time.Since(*session.EndTime)
+	if remaining <= 0 {
+		return 0
+	}
+	return remaining.Seconds()
+}
+
+// releasePileAfterCharging 充电结束后释放充电桩
+// 跟踪驶离时桩位保持占用直到车辆驶离，否则视为结束时即驶离；调用方需持有调度锁
+func (s *SchedulerService) releasePileAfterCharging(session *model.ChargingSession) error {
+	if s.trackDeparture {
+		log.Printf("充电桩 %s 充电结束，等待车辆驶离: 会话=%s", session.PileID, session.ID)
+		return nil
+	}
+	if _, err := s.sessionRepo.MarkDeparted(session.ID, *session.EndTime); err != nil {
+		return fmt.Errorf("记录车辆驶离失败: %w", err)
+	}
+	return s.pileRepo.UpdateStatus(session.PileID, model.PileStatusAvailable)
+}
+
+// HandleVehicleDeparture 处理车辆驶离：记录驶离时间、计入占位费并让队列中的下一辆车开始充电
+// sessionID 为uuid.Nil时按充电桩查找等待驶离的会话；重复的驶离事件返回首次记录的结果
+func (s *SchedulerService) HandleVehicleDeparture(sessionID uuid.UUID, pileID string, departedAt time.Time) (*model.VehicleDeparture, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	var session *model.ChargingSession
+	var err error
+	if sessionID != uuid.Nil {
+		session, err = s.sessionRepo.GetByID(sessionID)
+	} else {
+		session, err = s.sessionRepo.GetParkedSessionByPileID(pileID)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("获取充电会话失败: %w", err)
+	}
+	if session == nil {
+		return nil, fmt.Errorf("%w: 充电桩 %s", ErrNoParkedVehicle, pileID)
+	}
+	if pileID != "" && session.PileID != pileID {
+		return nil, fmt.Errorf("%w: 会话充电桩=%s, 请求充电桩=%s", ErrSessionMismatch, session.PileID, pileID)
+	}
+
+	return s.departLocked(session, departedAt)
+}
+
+// CheckOut 用户确认车辆已驶离
+func (s *SchedulerService) CheckOut(requestID uuid.UUID) (*model.VehicleDeparture, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	session, err := s.sessionRepo.GetByRequestID(requestID)
+	if err != nil {
+		return nil, fmt.Errorf("%w: 请求 %s 没有充电会话", ErrNoParkedVehicle, requestID)
+	}
+
+	return s.departLocked(session, time.Now().UTC())
+}
+
+// departLocked 记录车辆驶离并释放充电桩，调用方需持有调度锁
+func (s *SchedulerService) departLocked(session *model.ChargingSession, departedAt time.Time) (*model.VehicleDeparture, error) {
+	if session.EndTime == nil {
+		return nil, fmt.Errorf("%w: 会话 %s", ErrVehicleStillCharging, session.ID)
+	}
+	if session.DepartedAt != nil {
+		return s.departureResult(session), nil
+	}
+
+	// 驶离时间不早于充电结束时间
+	if departedAt.Before(*session.EndTime) {
+		departedAt = *session.EndTime
+	}
+	marked, err := s.sessionRepo.MarkDeparted(session.ID, departedAt)
+	if err != nil {
+		return nil, fmt.Errorf("记录车辆驶离失败: %w", err)
+	}
+	if !marked {
+		// 并发的驶离事件已记录
+		current, err := s.sessionRepo.GetByID(session.ID)
+		if err != nil {
+			return nil, fmt.Errorf("获取充电会话失败: %w", err)
+		}
+		return s.departureResult(current), nil
+	}
+	session.DepartedAt = &departedAt
+
+	// 超出免费停留时间的部分计入占位费
+	if s.billingService != nil {
+		if _, err := s.billingService.ApplyIdleFee(session); err != nil {
+			log.Printf("计算占位费失败: 会话=%s, 错误=%v", session.ID, err)
+		}
+	}
+
+	if err := s.freePileLocked(session.PileID); err != nil {
+		log.Printf("%v", err)
+	}
+	go s.TryScheduleRequests()
+
+	log.Printf("车辆驶离: 充电桩=%s, 会话=%s, 停留%.0f秒", session.PileID, session.ID, departedAt.Sub(*session.EndTime).Seconds())
+	return s.departureResult(session), nil
+}
+
+// freePileLocked 车辆驶离后将充电桩置为空闲，并让排在首位的车辆开始充电
+func (s *SchedulerService) freePileLocked(pileID string) error {
+	pile, err := s.pileRepo.GetByID(pileID)
+	if err != nil {
+		return fmt.Errorf("获取充电桩失败: %w", err)
+	}
+	switch pile.Status {
+	case model.PileStatusOccupied:
+		if err := s.pileRepo.UpdateStatus(pileID, model.PileStatusAvailable); err != nil {
+			return fmt.Errorf("更新充电桩状态失败: %w", err)
+		}
+	case model.PileStatusAvailable:
+	default:
+		// 故障、维护或离线的充电桩保持原状态，恢复后再开始充电
+		return nil
+	}
+
+	if err := s.advancePileQueue(pileID); err != nil {
+		return err
+	}
+	queueItems, err := s.queueRepo.GetQueueItemsByPile(pileID)
+	if err != nil || len(queueItems) == 0 {
+		return err
+	}
+	// 充电结束时首位已就位但因车辆未驶离而未开始充电
+	request, err := s.requestRepo.GetByID(queueItems[0].RequestID)
+	if err != nil {
+		return fmt.Errorf("获取请求失败: %w", err)
+	}
+	if request.Status == model.RequestStatusQueued {
+		s.startCharging(request.ID, pileID)
+	}
+	return nil
+}
+
+// departureResult 根据会话与详单构造驶离结果
+func (s *SchedulerService) departureResult(session *model.ChargingSession) *model.VehicleDeparture {
+	result := &model.VehicleDeparture{
+		SessionID:  session.ID,
+		PileID:     session.PileID,
+		EndTime:    *session.EndTime,
+		DepartedAt: *session.DepartedAt,
+	}
+	if s.billingService != nil {
+		if bill, err := s.billingService.GetBillBySessionID(session.ID); err == nil {
+			result.IdleMinutes = bill.IdleMinutes
+			result.IdleFee = bill.IdleFee
+		}
+	}
+	return result
+}
@@ -0,0 +1,157 @@
+package service
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"backend/internal/model"
+	"backend/internal/repository"
+)
+
+// SetMaintenanceRepository 设置计划维护窗口仓库及提前期（避免循环依赖）
+func (s *SchedulerService) SetMaintenanceRepository(maintenanceRepo *repository.MaintenanceRepository, leadTime time.Duration) {
+	s.maintenanceRepo = maintenanceRepo
+	s.maintenanceLeadTime = leadTime
+}
+
+// upcomingMaintenance 获取已进入提前期的充电桩及其最近一次维护的开始时间
+func (s *SchedulerService) upcomingMaintenance() map[string]time.Time {
+	if s.maintenanceRepo == nil {
+		return nil
+	}
+
+	windows, err := s.maintenanceRepo.GetStartingBefore(time.Now().UTC().Add(s.maintenanceLeadTime))
+	if err != nil {
+		log.Printf("获取计划维护窗口失败: %v", err)
+		return nil
+	}
+
+	starts := make(map[string]time.Time, len(windows))
+	for _, window := range windows {
+		if start, ok := starts[window.PileID]; !ok || window.StartTime.Before(start) {
+			starts[window.PileID] = window.StartTime
+		}
+	}
+	return starts
+}
+
+// overlapsMaintenance 在充电桩上从现在起经过completionSeconds秒完成充电是否会与计划维护重叠
+func overlapsMaintenance(maintenance map[string]time.Time, pileID string, completionSeconds float64) bool {
+	start, ok := maintenance[pileID]
+	return ok && finishesAfter(start, completionSeconds)
+}
+
+// finishesAfter 从现在起经过completionSeconds秒完成充电是否晚于指定时间
+func finishesAfter(start time.Time, completionSeconds float64) bool {
+	completion := time.Now().UTC().Add(time.Duration(completionSeconds * float64(time.Second)))
+	return completion.After(start)
+}
+
+// DrainPileForMaintenance 将预计完成时间晚于维护开始时间的排队车辆按故障调度转移到其他充电桩
+func (s *SchedulerService) DrainPileForMaintenance(pileID string, start time.Time) (int, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	drained, err := s.drainPileLocked(pileID, start)
+	if err != nil {
+		return 0, err
+	}
+	if drained > 0 {
+		go s.TryScheduleRequests()
+	}
+	return drained, nil
+}
+
+// EnterMaintenance 充电桩进入维护：转移全部排队车辆，停止正在充电的会话并生成详单
+func (s *SchedulerService) EnterMaintenance(pileID, reason string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	pile, err := s.pileRepo.GetByID(pileID)
+	if err != nil {
+		return fmt.Errorf("获取充电桩失败: %w", err)
+	}
+	if pile.Status == model.PileStatusFault {
+		return fmt.Errorf("充电桩 %s 处于故障状态，由故障流程处理", pileID)
+	}
+
+	// 先标记维护，避免故障调度把车辆重新分配回该充电桩
+	if err := s.pileRepo.UpdateStatus(pileID, model.PileStatusMaintenance); err != nil {
+		return fmt.Errorf("更新充电桩状态失败: %w", err)
+	}
+
+	if _, err := s.drainPileLocked(pileID, time.Time{}); err != nil {
+		return err
+	}
+
+	// 停止正在充电的车辆
+	requests, err := s.requestRepo.GetRequestsByPile(pileID)
+	if err != nil {
+		return fmt.Errorf("获取充电桩队列失败: %w", err)
+	}
+	for _, req := range requests {
+		if req.Status != model.RequestStatusCharging {
+			continue
+		}
+		if _, err := s.stopChargingLocked(req.ID, model.RequestStatusCompleted, "计划维护: "+reason); err != nil {
+			log.Printf("维护前停止充电失败: 请求=%s, 错误=%v", req.ID, err)
+		}
+	}
+
+	// stopChargingLocked 会把充电桩置为空闲，这里重新标记维护
+	if err := s.pileRepo.UpdateStatus(pileID, model.PileStatusMaintenance); err != nil {
+		return fmt.Errorf("更新充电桩状态失败: %w", err)
+	}
+
+	log.Printf("充电桩 %s 进入维护，原因: %s", pileID, reason)
+	go s.TryScheduleRequests()
+	return nil
+}
+
+// drainPileLocked 从充电桩队列中移出排队车辆并执行故障调度，start为零值时移出全部排队车辆
+// 调用方需持有调度锁
+func (s *SchedulerService) drainPileLocked(pileID string, start time.Time) (int, error) {
+	pile, err := s.pileRepo.GetByID(pileID)
+	if err != nil {
+		return 0, fmt.Errorf("获取充电桩失败: %w", err)
+	}
+
+	requests, err := s.requestRepo.GetRequestsByPile(pileID)
+	if err != nil {
+		return 0, fmt.Errorf("获取充电桩队列失败: %w", err)
+	}
+
+	// 按队列顺序累计预计完成时长，留下的车辆才计入后车的等待时间
+	var elapsed float64
+	var drained []*model.ChargingRequest
+	for _, req := range requests {
+		chargingTime := req.RequestedCapacity / pile.EffectivePower() * 3600
+		if req.Status == model.RequestStatusQueued &&
+			(start.IsZero() || finishesAfter(start, elapsed+chargingTime)) {
+			drained = append(drained, req)
+			continue
+		}
+		elapsed += chargingTime
+	}
+	if len(drained) == 0 {
+		return 0, nil
+	}
+
+	for _, req := range drained {
+		if err := s.queueRepo.RemoveFromQueueAndDecrementPile(req.ID, pileID); err != nil {
+			log.Printf("从队列移除请求 %s 失败: %v", req.ID, err)
+		}
+		// 先放回等候区，避免全局重调度把它当作原充电桩的排队请求重复收集
+		if err := s.requestRepo.AssignToPile(req.ID, "", 0, 0, model.RequestStatusWaiting); err != nil {
+			log.Printf("清除请求 %s 充电桩分配失败: %v", req.ID, err)
+		}
+	}
+	if err := s.advancePileQueue(pileID); err != nil {
+		log.Printf("%v", err)
+	}
+
+	log.Printf("充电桩 %s 计划维护，转移 %d 辆排队车辆", pileID, len(drained))
+	s.executeFaultRescheduling(pileID, pile.PileType, drained)
+	return len(drained), nil
+}
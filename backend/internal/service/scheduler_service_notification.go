@@ -0,0 +1,110 @@
+package service
+
+import (
+	"log"
+
+	"backend/internal/model"
+
+	"github.com/google/uuid"
+)
+
+// SetNotificationService 设置通知服务（避免循环依赖）
+func (s *SchedulerService) SetNotificationService(notificationService *NotificationService) {
+	s.notificationService = notificationService
+}
+
+// notify 发送用户通知，未配置通知服务时跳过
+func (s *SchedulerService) notify(userID uuid.UUID, event model.NotificationEvent, data map[string]any) {
+	if s.notificationService == nil {
+		return
+	}
+	s.notificationService.Notify(userID, event, data)
+}
+
+// notifyQueueCalled 通知从等候区叫号的用户前往分配的充电桩
+func (s *SchedulerService) notifyQueueCalled(requestID uuid.UUID) {
+	if s.notificationService == nil {
+		return
+	}
+	request, err := s.requestRepo.GetByID(requestID)
+	if err != nil {
+		log.Printf("获取请求失败: %v", err)
+		return
+	}
+	// scheduleRequestToPile 失败时请求仍在等候区
+	if request.PileID == "" {
+		return
+	}
+	s.notify(request.UserID, model.NotificationQueueCalled, map[string]any{
+		"requestId":     request.ID.String(),
+		"queueNumber":   request.QueueNumber,
+		"pileId":        request.PileID,
+		"queuePosition": request.QueuePosition,
+		"estimatedWait": request.EstimatedWaitTime,
+	})
+}
+
+// notifyChargingCompleted 通知用户充电结束
+func (s *SchedulerService) notifyChargingCompleted(session *model.ChargingSession) {
+	s.notify(session.UserID, model.NotificationChargingCompleted, map[string]any{
+		"requestId":      session.RequestID.String(),
+		"sessionId":      session.ID.String(),
+		"queueNumber":    session.QueueNumber,
+		"pileId":         session.PileID,
+		"actualCapacity": session.ActualCapacity,
+	})
+}
+
+// notifyFaultRescheduled 通知被转移的请求新的充电桩，未分配到充电桩的提示已回到等候区
+func (s *SchedulerService) notifyFaultRescheduled(fromPileID string, faultRequests []*model.ChargingRequest) {
+	if s.notificationService == nil {
+		return
+	}
+	for _, req := range faultRequests {
+		current, err := s.requestRepo.GetByID(req.ID)
+		if err != nil {
+			log.Printf("获取请求失败: %v", err)
+			continue
+		}
+		data := map[string]any{
+			"requestId":   current.ID.String(),
+			"queueNumber": current.QueueNumber,
+			"fromPileId":  fromPileID,
+			"status":      current.Status,
+		}
+		if current.PileID != "" && current.PileID != fromPileID && current.Status != model.RequestStatusWaiting {
+			data["toPileId"] = current.PileID
+			data["queuePosition"] = current.QueuePosition
+		}
+		s.notify(current.UserID, model.NotificationFaultRescheduled, data)
+	}
+}
+
+// SetWebhookService 设置第三方webhook推送服务（避免循环依赖）
+func (s *SchedulerService) SetWebhookService(webhookService *WebhookService) {
+	s.webhookService = webhookService
+}
+
+// publish 发布webhook事件，未配置推送服务时跳过
+func (s *SchedulerService) publish(eventType model.WebhookEventType, data map[string]any) {
+	if s.webhookService == nil {
+		return
+	}
+	s.webhookService.Publish(eventType, data)
+}
+
+// publishChargingCompleted 发布充电结束事件，requestStatus 区分正常结束与取消
+func (s *SchedulerService) publishChargingCompleted(session *model.ChargingSession, requestStatus model.RequestStatus) {
+	s.publish(model.WebhookEventChargingCompleted, map[string]any{
+		"requestId":      session.RequestID.String(),
+		"sessionId":      session.ID.String(),
+		"userId":         session.UserID.String(),
+		"queueNumber":    session.QueueNumber,
+		"pileId":         session.PileID,
+		"actualCapacity": session.ActualCapacity,
+		"duration":       session.Duration,
+		"sessionStatus":  session.Status,
+		"requestStatus":  requestStatus,
+		"endTime":        session.EndTime,
+	})
+}
@@ -5,13 +5,50 @@ import (
 	"fmt"
 	"log"
 	"time"
+
+	"github.com/google/uuid"
 )
 
-// HandlePileRecovery 处理充电桩恢复
+// SetFaultTicketService 设置故障工单服务（避免循环依赖）
+func (s *SchedulerService) SetFaultTicketService(faultTicketService *FaultTicketService) {
+	s.faultTicketService = faultTicketService
+}
+
+// HandlePileRecovery 处理充电桩恢复，充电桩仍有未修复的故障工单时返回 ErrTicketNotResolved
+// 工单检查与恢复在同一次调度锁内完成，两者之间不会插入其他调度操作
 func (s *SchedulerService) HandlePileRecovery(pileID string) error {
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
 
+	if s.faultTicketService != nil {
+		if err := s.faultTicketService.CheckRecoverable(pileID); err != nil {
+			return err
+		}
+	}
+	return s.recoverPileLocked(pileID)
+}
+
+// OverridePileRecovery 故障工单未修复时由运营人员强制恢复充电桩，记录在工单与审计日志中
+func (s *SchedulerService) OverridePileRecovery(operatorID uuid.UUID, pileID, reason string) (err error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	defer func() {
+		s.recordAudit(operatorID, model.AuditActionOverrideRecovery, nil, pileID, reason, nil, err)
+	}()
+
+	if err = s.recoverPileLocked(pileID); err != nil {
+		return err
+	}
+	// 恢复成功后才在工单上记录强制恢复
+	if s.faultTicketService != nil {
+		s.faultTicketService.RecordOverride(operatorID, pileID, reason)
+	}
+	return nil
+}
+
+// recoverPileLocked 充电桩恢复可用并重新调度，调用方需持有调度锁
+func (s *SchedulerService) recoverPileLocked(pileID string) error {
 	log.Printf("处理充电桩恢复: %s", pileID)
 
 	// 更新充电桩状态为可用
@@ -0,0 +1,48 @@
+package service
+
+import (
+	"log"
+	"sort"
+
+	"backend/internal/model"
+)
+
+// SetWaitlistAdmitter 设置候补入场处理，每次调度后按空出的等候区位置让候补请求入场（避免循环依赖）
+func (s *SchedulerService) SetWaitlistAdmitter(admit func()) {
+	s.waitlistAdmitter = admit
+}
+
+// notifyWaitingAreaChanged 调度后等候区可能空出位置，异步触发候补入场
+func (s *SchedulerService) notifyWaitingAreaChanged() {
+	if s.waitlistAdmitter != nil {
+		go s.waitlistAdmitter()
+	}
+}
+
+// EstimateWaitingAreaReleases 估算等候区依次空出位置的时间（秒，升序）
+// 充电桩队列中每完成一辆车，队首后移并从等候区叫入一辆车，空出一个等候区位置
+func (s *SchedulerService) EstimateWaitingAreaReleases() []float64 {
+	var releases []float64
+	for _, pileType := range []model.PileType{model.PileTypeFast, model.PileTypeSlow} {
+		piles, err := s.pileRepo.GetNormalPiles(pileType)
+		if err != nil {
+			log.Printf("获取充电桩失败: %v", err)
+			continue
+		}
+		for _, pile := range piles {
+			requests, err := s.requestRepo.GetRequestsByPile(pile.ID)
+			if err != nil {
+				log.Printf("获取充电桩 %s 队列失败: %v", pile.ID, err)
+				continue
+			}
+			finishAt := s.parkedWaitTime(pile.ID)
+			for _, req := range requests {
+				finishAt += req.RequestedCapacity / pile.EffectivePower() * 3600
+				releases = append(releases, finishAt)
+			}
+		}
+	}
+
+	sort.Float64s(releases)
+	return releases
+}
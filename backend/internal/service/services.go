@@ -2,25 +2,39 @@ package service
 
 import (
 	"database/sql"
+	"time"
 
 	"backend/internal/config"
+	"backend/internal/model"
 	"backend/internal/repository"
 )
 
 // Services 包含所有服务
 type Services struct {
 	User                *UserService
+	Vehicle             *VehicleService
 	ChargingPile        *ChargingPileService
 	ChargingRequest     *ChargingRequestService
 	Scheduler           *SchedulerService
 	Billing             *BillingService
 	System              *SystemService
 	Bootstrap           *BootstrapService
+	SimulatorEvent      *SimulatorEventService
+	CommandQueue        *CommandQueueService
+	Reconciler          *ReconcilerService
+	PileRegistry        *PileRegistryService
+	Audit               *AuditService
+	Maintenance         *MaintenanceService
+	FaultTicket         *FaultTicketService
+	Reliability         *ReliabilityService
+	Notification        *NotificationService
+	Webhook             *WebhookService
 	ChargingSessionRepo *repository.ChargingSessionRepository
+	SimulatorClient     *ChargingDispatcherClient
 }
 
 // NewServices 创建服务集合
-func NewServices(db *sql.DB, cfg *config.Config) *Services {
+func NewServices(db *sql.DB, cfg *config.Config) (*Services, error) {
 	// 创建仓库
 	userRepo := repository.NewUserRepository(db)
 	chargingPileRepo := repository.NewChargingPileRepository(db)
@@ -29,30 +43,110 @@ func NewServices(db *sql.DB, cfg *config.Config) *Services {
 	queueRepo := repository.NewQueueRepository(db)
 	billingRepo := repository.NewBillingRepository(db)
 	systemRepo := repository.NewSystemRepository(db)
+	vehicleRepo := repository.NewVehicleRepository(db)
+	simulatorEventRepo := repository.NewSimulatorEventRepository(db)
+	pileCommandRepo := repository.NewPileCommandRepository(db)
+	reconciliationRepo := repository.NewReconciliationRepository(db)
+	pileEndpointRepo := repository.NewPileEndpointRepository(db)
+	auditLogRepo := repository.NewAuditLogRepository(db)
+	maintenanceRepo := repository.NewMaintenanceRepository(db)
+	faultTicketRepo := repository.NewFaultTicketRepository(db)
+	degradationRepo := repository.NewPileDegradationRepository(db)
+	notificationRepo := repository.NewNotificationRepository(db)
+	webhookRepo := repository.NewWebhookRepository(db)
+	queueNumberRepo := repository.NewQueueNumberRepository(db)
+	ocppCredentialRepo := repository.NewOCPPCredentialRepository(db)
 	// 创建服务
-	userService := NewUserService(userRepo)
-	chargingRequestService := NewChargingRequestService(chargingRequestRepo, queueRepo, chargingPileRepo, systemRepo)
-	billingService := NewBillingService(billingRepo, chargingSessionRepo, systemRepo, chargingPileRepo)
+	userService := NewUserService(userRepo, cfg.Auth)
+	vehicleService := NewVehicleService(vehicleRepo, chargingRequestRepo)
+	chargingRequestService, err := NewChargingRequestService(chargingRequestRepo, queueRepo, chargingPileRepo, systemRepo, userRepo, vehicleRepo, queueNumberRepo, cfg.QueueNumber)
+	if err != nil {
+		return nil, err
+	}
+	if err := chargingRequestService.BackfillQueueDates(); err != nil {
+		return nil, err
+	}
+	billingService := NewBillingService(billingRepo, chargingSessionRepo, systemRepo, chargingPileRepo, degradationRepo)
 	systemService := NewSystemService(systemRepo, chargingRequestRepo, chargingSessionRepo, billingRepo, queueRepo, chargingPileRepo)
-	schedulerService := NewSchedulerService(chargingRequestRepo, chargingPileRepo, queueRepo, chargingSessionRepo, systemRepo)
-	chargingPileService := NewChargingPileService(chargingPileRepo, systemRepo, userRepo, queueRepo)
+	schedulerService := NewSchedulerService(chargingRequestRepo, chargingPileRepo, queueRepo, chargingSessionRepo, systemRepo, vehicleRepo)
+	chargingPileService := NewChargingPileService(chargingPileRepo, systemRepo, userRepo, queueRepo, degradationRepo, ocppCredentialRepo)
 	bootstrapService := NewBootstrapService(systemRepo, chargingPileRepo, cfg)
-	// 设置计费服务（避免循环依赖）
+	simulatorEventService := NewSimulatorEventService(simulatorEventRepo)
+	auditService := NewAuditService(auditLogRepo)
+	faultTicketService := NewFaultTicketService(faultTicketRepo, userRepo, cfg.FaultTicket)
+	reliabilityService := NewReliabilityService(systemRepo, chargingPileRepo, billingRepo, degradationRepo, cfg.Reliability)
+	notificationService := NewNotificationService(notificationRepo, cfg.Notification)
+	webhookService := NewWebhookService(webhookRepo, cfg.Webhook)
+	// 设置计费服务与审计服务（避免循环依赖）
 	schedulerService.SetBillingService(billingService)
+	schedulerService.SetAuditService(auditService)
+	// 故障开工单，工单修复后才允许恢复充电桩
+	chargingPileService.SetFaultTicketService(faultTicketService)
+	schedulerService.SetFaultTicketService(faultTicketService)
+	// 调度与计费事件通知用户
+	schedulerService.SetNotificationService(notificationService)
+	billingService.SetNotificationService(notificationService)
+	// 请求、调度与计费的状态变化推送给第三方
+	chargingRequestService.SetWebhookService(webhookService)
+	schedulerService.SetWebhookService(webhookService)
+	billingService.SetWebhookService(webhookService)
+	// 充电结束后车辆驶离前桩位仍被占用，超出免费停留时间收取占位费
+	if cfg.Overstay.Enabled {
+		gracePeriod := time.Duration(cfg.Overstay.GracePeriod) * time.Second
+		schedulerService.SetOverstayPolicy(gracePeriod)
+		billingService.SetIdleFee(gracePeriod, cfg.Overstay.IdleFeePerMinute)
+	}
 
-	// 创建模拟器客户端并设置到调度器
-	simulatorClient := NewChargingDispatcherClient("http://localhost:8090") // 模拟器的地址
-	schedulerService.SetSimulatorClient(simulatorClient)
+	// 创建模拟器客户端，调度器的指令经指令队列下发到充电桩注册的地址
+	defaultEndpoint := cfg.Dispatch.DefaultEndpoint
+	if defaultEndpoint == "" {
+		defaultEndpoint = "http://localhost:8090" // 未注册充电桩的模拟器地址
+	}
+	simulatorClient := NewChargingDispatcherClient(defaultEndpoint)
+	pileRegistryService := NewPileRegistryService(chargingPileRepo, pileEndpointRepo, chargingRequestRepo, bootstrapService, simulatorClient,
+		auditService, cfg.Dispatch.RegistrationSecret)
+	commandQueue := NewCommandQueueService(pileCommandRepo, simulatorClient, cfg.Dispatch)
+	schedulerService.SetSimulatorClient(commandQueue)
+	// 指令超时未确认时按软件故障处理（避免循环依赖）
+	commandQueue.SetEscalationHandler(func(pileID, description string) error {
+		if err := chargingPileService.ReportPileFault(pileID, string(model.FaultTypeSoftware), description); err != nil {
+			return err
+		}
+		return schedulerService.HandlePileFault(pileID, string(model.FaultTypeSoftware), description)
+	})
 	// 设置调度服务到充电请求服务（避免循环依赖）
 	chargingRequestService.SetSchedulerService(schedulerService)
+	// 等候区已满时进入候补队列，空出位置后按顺序通知用户到场签到
+	chargingRequestService.SetNotificationService(notificationService)
+	if cfg.Waitlist.Enabled {
+		chargingRequestService.EnableWaitlist(cfg.Waitlist)
+		schedulerService.SetWaitlistAdmitter(chargingRequestService.AdmitFromWaitlist)
+	}
+	// 对账服务比较模拟器上报的状态与后端记录
+	reconcilerService := NewReconcilerService(chargingPileRepo, chargingSessionRepo, reconciliationRepo,
+		chargingPileService, schedulerService, commandQueue, simulatorClient, cfg.Reconcile)
+	// 计划维护服务推进维护窗口，并为调度器提供即将开始的维护
+	maintenanceService := NewMaintenanceService(maintenanceRepo, chargingPileRepo, chargingPileService, schedulerService, cfg.Maintenance)
 	return &Services{
 		User:                userService,
+		Vehicle:             vehicleService,
 		ChargingPile:        chargingPileService,
 		ChargingRequest:     chargingRequestService,
 		Scheduler:           schedulerService,
 		Billing:             billingService,
 		System:              systemService,
 		Bootstrap:           bootstrapService,
+		SimulatorEvent:      simulatorEventService,
+		CommandQueue:        commandQueue,
+		Reconciler:          reconcilerService,
+		PileRegistry:        pileRegistryService,
+		Audit:               auditService,
+		Maintenance:         maintenanceService,
+		FaultTicket:         faultTicketService,
+		Reliability:         reliabilityService,
+		Notification:        notificationService,
+		Webhook:             webhookService,
 		ChargingSessionRepo: chargingSessionRepo,
-	}
+		SimulatorClient:     simulatorClient,
+	}, nil
 }
@@ -3,57 +3,110 @@ package service
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
+	"sync"
 	"time"
+
+	"backend/internal/model"
 )
 
+// ChargingDispatcher 充电指令下发接口
+// 由模拟器HTTP客户端或OCPP中央系统实现
+type ChargingDispatcher interface {
+	AssignCharging(req ChargingAssignRequest) error
+	StopCharging(req ChargingStopRequest) error
+}
+
+// AvailabilityController 充电桩可用性控制接口
+type AvailabilityController interface {
+	ChangeAvailability(pileID string, available bool) error
+}
+
 // ChargingDispatcherClient 充电派发客户端
-// 用于从模拟器向充电桩发送充电指令
+// 用于从模拟器向充电桩发送充电指令，指令发往充电桩注册的地址，未注册的发往baseURL
 type ChargingDispatcherClient struct {
-	client  *http.Client
-	baseURL string
+	client    *http.Client
+	baseURL   string
+	mu        sync.RWMutex
+	endpoints map[string]string // 充电桩ID -> 指令下发地址
 }
 
 // NewChargingDispatcherClient 创建充电派发客户端
 func NewChargingDispatcherClient(simulatorBaseURL string) *ChargingDispatcherClient {
 	return &ChargingDispatcherClient{
-		client:  &http.Client{Timeout: 10 * time.Second},
-		baseURL: simulatorBaseURL, // 例如："http://localhost:8090"
+		client:    &http.Client{Timeout: 10 * time.Second},
+		baseURL:   simulatorBaseURL, // 例如："http://localhost:8090"
+		endpoints: make(map[string]string),
+	}
+}
+
+// SetPileEndpoint 设置充电桩的指令下发地址
+func (c *ChargingDispatcherClient) SetPileEndpoint(pileID, endpointURL string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.endpoints[pileID] = endpointURL
+}
+
+// endpointFor 获取充电桩的指令下发地址
+func (c *ChargingDispatcherClient) endpointFor(pileID string) string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if endpointURL, ok := c.endpoints[pileID]; ok {
+		return endpointURL
+	}
+	return c.baseURL
+}
+
+// allEndpoints 获取默认地址与全部已注册地址（去重）
+func (c *ChargingDispatcherClient) allEndpoints() []string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	seen := map[string]bool{c.baseURL: true}
+	endpoints := []string{c.baseURL}
+	for _, endpointURL := range c.endpoints {
+		if !seen[endpointURL] {
+			seen[endpointURL] = true
+			endpoints = append(endpoints, endpointURL)
+		}
 	}
+	return endpoints
 }
 
 // ChargingAssignRequest 充电分配请求
 type ChargingAssignRequest struct {
-	PileID            string  `json:"pileId"`            // 充电桩ID
-	UserID            string  `json:"userId"`            // 用户ID
-	RequestedCapacity float64 `json:"requestedCapacity"` // 请求充电量
-	ChargingMode      string  `json:"chargingMode"`      // 充电模式(fast/trickle)
+	CommandID         string   `json:"commandId,omitempty"`       // 指令ID，重试时不变，模拟器据此去重
+	SessionID         string   `json:"sessionId"`                 // 充电会话ID，模拟器回调时携带
+	PileID            string   `json:"pileId"`                    // 充电桩ID
+	UserID            string   `json:"userId"`                    // 用户ID
+	RequestedCapacity float64  `json:"requestedCapacity"`         // 请求充电量
+	ChargingMode      string   `json:"chargingMode"`              // 充电模式(fast/trickle)
+	BatteryCapacity   float64  `json:"batteryCapacity,omitempty"` // 车辆电池容量(度)
+	VehicleProfile    string   `json:"vehicleProfile,omitempty"`  // 车辆配置名称，模拟器据此选择充电曲线
+	StartSoC          *float64 `json:"startSoc,omitempty"`        // 起始电量百分比
+	TargetSoC         *float64 `json:"targetSoc,omitempty"`       // 目标电量百分比
 }
 
 // ChargingStopRequest 停止充电请求
 type ChargingStopRequest struct {
-	PileID string `json:"pileId"` // 充电桩ID
-	UserID string `json:"userId"` // 用户ID
-	Reason string `json:"reason"` // 停止原因
+	CommandID string `json:"commandId,omitempty"` // 指令ID，重试时不变，模拟器据此去重
+	SessionID string `json:"sessionId,omitempty"` // 要停止的充电会话ID
+	PileID    string `json:"pileId"`              // 充电桩ID
+	UserID    string `json:"userId"`              // 用户ID
+	Reason    string `json:"reason"`              // 停止原因
 }
 
 // AssignCharging 分配充电
 // 后端调用此方法向模拟器发送充电指令
-func (c *ChargingDispatcherClient) AssignCharging(pileID, userID string, capacity float64, mode string) error {
-	req := ChargingAssignRequest{
-		PileID:            pileID,
-		UserID:            userID,
-		RequestedCapacity: capacity,
-		ChargingMode:      mode,
-	}
-
+func (c *ChargingDispatcherClient) AssignCharging(req ChargingAssignRequest) error {
 	jsonData, err := json.Marshal(req)
 	if err != nil {
 		return fmt.Errorf("序列化请求数据失败: %w", err)
 	}
 
-	url := c.baseURL + "/api/simulator/charging/assign"
+	url := c.endpointFor(req.PileID) + "/api/simulator/charging/assign"
 	httpReq, err := http.NewRequest("POST", url, bytes.NewBuffer(jsonData))
 	if err != nil {
 		return fmt.Errorf("创建HTTP请求失败: %w", err)
@@ -80,19 +133,13 @@ func (c *ChargingDispatcherClient) AssignCharging(pileID, userID string, capacit
 
 // StopCharging 停止充电
 // 后端调用此方法向模拟器发送停止充电指令
-func (c *ChargingDispatcherClient) StopCharging(pileID, userID, reason string) error {
-	req := ChargingStopRequest{
-		PileID: pileID,
-		UserID: userID,
-		Reason: reason,
-	}
-
+func (c *ChargingDispatcherClient) StopCharging(req ChargingStopRequest) error {
 	jsonData, err := json.Marshal(req)
 	if err != nil {
 		return fmt.Errorf("序列化请求数据失败: %w", err)
 	}
 
-	url := c.baseURL + "/api/simulator/charging/stop"
+	url := c.endpointFor(req.PileID) + "/api/simulator/charging/stop"
 	httpReq, err := http.NewRequest("POST", url, bytes.NewBuffer(jsonData))
 	if err != nil {
 		return fmt.Errorf("创建HTTP请求失败: %w", err)
@@ -118,9 +165,39 @@ func (c *ChargingDispatcherClient) StopCharging(pileID, userID, reason string) e
 	return nil
 }
 
-// GetSimulatorStatus 获取模拟器状态
-func (c *ChargingDispatcherClient) GetSimulatorStatus() ([]map[string]any, error) {
-	url := c.baseURL + "/api/simulator/status"
+// SimulatorPileStatus 模拟器状态接口返回的充电桩状态
+type SimulatorPileStatus struct {
+	ID             string                `json:"id"`
+	Type           string                `json:"type"`
+	Status         string                `json:"status"`
+	Power          float64               `json:"power"`
+	CurrentVehicle *model.ReportedCharge `json:"currentVehicle,omitempty"`
+}
+
+// GetSimulatorStatus 获取全部模拟器的充电桩状态
+// 每个充电桩只采用其注册地址返回的状态；部分地址不可达时仍返回其余结果，并附带错误
+func (c *ChargingDispatcherClient) GetSimulatorStatus() ([]SimulatorPileStatus, error) {
+	var piles []SimulatorPileStatus
+	var errs []error
+	for _, endpointURL := range c.allEndpoints() {
+		statuses, err := c.fetchStatus(endpointURL)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", endpointURL, err))
+			continue
+		}
+		for _, pile := range statuses {
+			if c.endpointFor(pile.ID) == endpointURL {
+				piles = append(piles, pile)
+			}
+		}
+	}
+
+	return piles, errors.Join(errs...)
+}
+
+// fetchStatus 获取单个模拟器的充电桩状态
+func (c *ChargingDispatcherClient) fetchStatus(endpointURL string) ([]SimulatorPileStatus, error) {
+	url := endpointURL + "/api/simulator/status"
 	httpReq, err := http.NewRequest("GET", url, nil)
 	if err != nil {
 		return nil, fmt.Errorf("创建HTTP请求失败: %w", err)
@@ -137,9 +214,9 @@ func (c *ChargingDispatcherClient) GetSimulatorStatus() ([]map[string]any, error
 	}
 
 	var response struct {
-		Code    int              `json:"code"`
-		Message string           `json:"message"`
-		Data    []map[string]any `json:"data"`
+		Code    int                   `json:"code"`
+		Message string                `json:"message"`
+		Data    []SimulatorPileStatus `json:"data"`
 	}
 
 	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
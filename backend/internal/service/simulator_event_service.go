@@ -0,0 +1,110 @@
+package service
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"backend/internal/model"
+	"backend/internal/repository"
+)
+
+// simulatorEventRetention 已处理事件的保留时间，超过后不再识别重放
+const simulatorEventRetention = 7 * 24 * time.Hour
+
+// SimulatorEventService 模拟器回调幂等服务
+// 按事件ID保存首次处理结果，重放时直接返回，保证每个回调只生效一次
+type SimulatorEventService struct {
+	eventRepo *repository.SimulatorEventRepository
+	inFlight  map[string]chan struct{} // 正在处理的事件ID
+	mu        sync.Mutex
+}
+
+// NewSimulatorEventService 创建模拟器回调幂等服务
+func NewSimulatorEventService(eventRepo *repository.SimulatorEventRepository) *SimulatorEventService {
+	svc := &SimulatorEventService{
+		eventRepo: eventRepo,
+		inFlight:  make(map[string]chan struct{}),
+	}
+
+	// 定期清理过期事件
+	go svc.cleanupLoop()
+
+	return svc
+}
+
+// Process 处理一次回调
+// handle 返回响应状态码与响应体；事件ID为空时不去重。返回的replayed表示结果来自之前的处理
+// 5xx结果不保存，便于模拟器重试
+func (s *SimulatorEventService) Process(event *model.SimulatorEvent, handle func() (int, []byte)) (statusCode int, body []byte, replayed bool, err error) {
+	if event.EventID == "" {
+		statusCode, body = handle()
+		return statusCode, body, false, nil
+	}
+
+	// 同一事件并发到达时后到者等待先到者处理完成
+	release := s.acquire(event.EventID)
+	defer release()
+
+	stored, err := s.eventRepo.GetByEventID(event.EventID)
+	if err != nil {
+		return 0, nil, false, fmt.Errorf("查询回调事件失败: %w", err)
+	}
+	if stored != nil {
+		log.Printf("重放模拟器回调: 事件=%s, 类型=%s, 充电桩=%s", stored.EventID, stored.EventType, stored.PileID)
+		return stored.StatusCode, []byte(stored.ResponseBody), true, nil
+	}
+
+	statusCode, body = handle()
+	if statusCode < http.StatusInternalServerError {
+		event.StatusCode = statusCode
+		event.ResponseBody = string(body)
+		event.CreatedAt = time.Now().UTC()
+		if err := s.eventRepo.Create(event); err != nil {
+			log.Printf("保存回调事件失败: 事件=%s, 错误=%v", event.EventID, err)
+		}
+	}
+
+	return statusCode, body, false, nil
+}
+
+// acquire 占用事件ID，返回释放函数
+func (s *SimulatorEventService) acquire(eventID string) func() {
+	for {
+		s.mu.Lock()
+		waitCh, busy := s.inFlight[eventID]
+		if !busy {
+			doneCh := make(chan struct{})
+			s.inFlight[eventID] = doneCh
+			s.mu.Unlock()
+
+			return func() {
+				s.mu.Lock()
+				delete(s.inFlight, eventID)
+				s.mu.Unlock()
+				close(doneCh)
+			}
+		}
+		s.mu.Unlock()
+		<-waitCh
+	}
+}
+
+// cleanupLoop 每小时清理过期事件
+func (s *SimulatorEventService) cleanupLoop() {
+	ticker := time.NewTicker(time.Hour)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		count, err := s.eventRepo.DeleteBefore(time.Now().UTC().Add(-simulatorEventRetention))
+		if err != nil {
+			log.Printf("清理回调事件失败: %v", err)
+			continue
+		}
+		if count > 0 {
+			log.Printf("已清理 %d 条过期回调事件", count)
+		}
+	}
+}
@@ -78,6 +78,9 @@ func (s *SystemService) UpdateSchedulingConfig(config *model.SchedulingConfig) e
 	if config.Strategy != "shortest_completion_time" && config.Strategy != "first_come_first_served" {
 		return errors.New("无效的调度策略")
 	}
+	if !model.IsValidFaultReschedulingPolicy(config.FaultReschedulingPolicy) {
+		return errors.New("无效的故障调度策略")
+	}
 
 	// 更新数据库
 	err := s.systemRepo.UpdateSchedulingConfig(config)
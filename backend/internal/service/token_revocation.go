@@ -0,0 +1,76 @@
+package service
+
+import (
+	"sync"
+	"time"
+
+	"backend/internal/model"
+
+	"github.com/google/uuid"
+)
+
+// tokenRevocationCache 访问令牌吊销缓存
+// 访问令牌有效期很短，吊销记录只需保留一个访问令牌有效期，之后令牌自然过期
+type tokenRevocationCache struct {
+	mu       sync.RWMutex
+	ttl      time.Duration
+	sessions map[uuid.UUID]time.Time // 会话ID -> 记录过期时间
+	users    map[uuid.UUID]time.Time // 用户ID -> 此时间之前签发的令牌全部无效
+}
+
+// newTokenRevocationCache 创建吊销缓存
+func newTokenRevocationCache(ttl time.Duration) *tokenRevocationCache {
+	return &tokenRevocationCache{
+		ttl:      ttl,
+		sessions: make(map[uuid.UUID]time.Time),
+		users:    make(map[uuid.UUID]time.Time),
+	}
+}
+
+// revokeSession 吊销会话下的所有访问令牌
+func (c *tokenRevocationCache) revokeSession(sessionID uuid.UUID, revokedAt time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.pruneLocked()
+	c.sessions[sessionID] = revokedAt.Add(c.ttl)
+}
+
+// revokeUserBefore 吊销用户在指定时间之前签发的所有访问令牌
+func (c *tokenRevocationCache) revokeUserBefore(userID uuid.UUID, cutoff time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.pruneLocked()
+	// iat 精度为秒，同一秒内签发的令牌由会话吊销兜底
+	c.users[userID] = cutoff.Truncate(time.Second)
+}
+
+// isRevoked 检查访问令牌是否已被吊销
+func (c *tokenRevocationCache) isRevoked(claims *model.AccessClaims) bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if _, ok := c.sessions[claims.SessionID]; ok {
+		return true
+	}
+	if cutoff, ok := c.users[claims.UserID]; ok && claims.IssuedAt.Before(cutoff) {
+		return true
+	}
+	return false
+}
+
+// pruneLocked 清理已无意义的吊销记录（调用方需持有写锁）
+func (c *tokenRevocationCache) pruneLocked() {
+	now := time.Now().UTC()
+	for id, expiresAt := range c.sessions {
+		if now.After(expiresAt) {
+			delete(c.sessions, id)
+		}
+	}
+	for id, cutoff := range c.users {
+		if now.After(cutoff.Add(c.ttl)) {
+			delete(c.users, id)
+		}
+	}
+}
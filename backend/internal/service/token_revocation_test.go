@@ -0,0 +1,112 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"backend/internal/model"
+
+	"github.com/google/uuid"
+)
+
+func TestTokenRevocationCacheIsRevoked(t *testing.T) {
+	userID := uuid.New()
+	sessionID := uuid.New()
+	now := time.Now().UTC()
+
+	tests := []struct {
+		name   string
+		setup  func(c *tokenRevocationCache)
+		claims model.AccessClaims
+		want   bool
+	}{
+		{
+			name:   "未吊销",
+			setup:  func(c *tokenRevocationCache) {},
+			claims: model.AccessClaims{UserID: userID, SessionID: sessionID, IssuedAt: now},
+			want:   false,
+		},
+		{
+			name:   "会话已吊销",
+			setup:  func(c *tokenRevocationCache) { c.revokeSession(sessionID, now) },
+			claims: model.AccessClaims{UserID: userID, SessionID: sessionID, IssuedAt: now},
+			want:   true,
+		},
+		{
+			name:   "吊销其他会话不影响",
+			setup:  func(c *tokenRevocationCache) { c.revokeSession(uuid.New(), now) },
+			claims: model.AccessClaims{UserID: userID, SessionID: sessionID, IssuedAt: now},
+			want:   false,
+		},
+		{
+			name:   "截止时间之前签发的令牌无效",
+			setup:  func(c *tokenRevocationCache) { c.revokeUserBefore(userID, now) },
+			claims: model.AccessClaims{UserID: userID, SessionID: sessionID, IssuedAt: now.Add(-time.Minute)},
+			want:   true,
+		},
+		{
+			name:   "截止时间之后签发的令牌有效",
+			setup:  func(c *tokenRevocationCache) { c.revokeUserBefore(userID, now) },
+			claims: model.AccessClaims{UserID: userID, SessionID: sessionID, IssuedAt: now.Add(time.Minute)},
+			want:   false,
+		},
+		{
+			name: "截止时间按秒截断，同一秒内签发的令牌不受影响",
+			setup: func(c *tokenRevocationCache) {
+				c.revokeUserBefore(userID, now.Truncate(time.Second).Add(500*time.Millisecond))
+			},
+			claims: model.AccessClaims{UserID: userID, SessionID: sessionID, IssuedAt: now.Truncate(time.Second)},
+			want:   false,
+		},
+		{
+			name:   "吊销其他用户不影响",
+			setup:  func(c *tokenRevocationCache) { c.revokeUserBefore(uuid.New(), now) },
+			claims: model.AccessClaims{UserID: userID, SessionID: sessionID, IssuedAt: now.Add(-time.Minute)},
+			want:   false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newTokenRevocationCache(time.Minute)
+			tt.setup(c)
+			if got := c.isRevoked(&tt.claims); got != tt.want {
+				t.Errorf("isRevoked() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTokenRevocationCachePrune(t *testing.T) {
+	ttl := time.Minute
+	now := time.Now().UTC()
+
+	tests := []struct {
+		name      string
+		revokedAt time.Time
+		wantKept  bool
+	}{
+		{name: "有效期内保留", revokedAt: now, wantKept: true},
+		{name: "超过访问令牌有效期后清理", revokedAt: now.Add(-2 * ttl), wantKept: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newTokenRevocationCache(ttl)
+			sessionID := uuid.New()
+			userID := uuid.New()
+			c.revokeSession(sessionID, tt.revokedAt)
+			c.revokeUserBefore(userID, tt.revokedAt)
+
+			// 下一次吊销时清理过期记录
+			c.revokeSession(uuid.New(), now)
+
+			if _, ok := c.sessions[sessionID]; ok != tt.wantKept {
+				t.Errorf("会话记录保留 = %v, want %v", ok, tt.wantKept)
+			}
+			if _, ok := c.users[userID]; ok != tt.wantKept {
+				t.Errorf("用户记录保留 = %v, want %v", ok, tt.wantKept)
+			}
+		})
+	}
+}
@@ -1,9 +1,14 @@
 package service
 
 import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
+	"log"
 	"time"
 
+	"backend/internal/config"
 	"backend/internal/model"
 	"backend/internal/repository"
 
@@ -12,19 +17,54 @@ import (
 )
 
 const (
-	jwtExpirationTime = 24 * time.Hour
-	jwtSecretKey      = "your-secret-key-here" // 实际项目中应从配置文件读取
+	defaultAccessTokenTTL  = 15 * time.Minute
+	defaultRefreshTokenTTL = 7 * 24 * time.Hour
+	defaultJWTSecretKey    = "your-secret-key-here" // 未配置密钥时使用
+	passwordResetTokenTTL  = 30 * time.Minute
+	sessionUserAgentMaxLen = 255 // 与 user_sessions.user_agent 字段长度一致
+	sessionIPAddressMaxLen = 64  // 与 user_sessions.ip_address 字段长度一致
 )
 
 // UserService 用户服务
 type UserService struct {
-	userRepo *repository.UserRepository
+	userRepo        *repository.UserRepository
+	jwtSecret       []byte
+	accessTokenTTL  time.Duration
+	refreshTokenTTL time.Duration
+	revocations     *tokenRevocationCache
 }
 
 // NewUserService 创建用户服务
-func NewUserService(userRepo *repository.UserRepository) *UserService {
-	return &UserService{
-		userRepo: userRepo,
+func NewUserService(userRepo *repository.UserRepository, authCfg config.AuthConfig) *UserService {
+	s := &UserService{
+		userRepo:        userRepo,
+		jwtSecret:       []byte(authCfg.JWTSecret),
+		accessTokenTTL:  time.Duration(authCfg.JWTExpirationMin) * time.Minute,
+		refreshTokenTTL: time.Duration(authCfg.RefreshExpirationDays) * 24 * time.Hour,
+	}
+	if len(s.jwtSecret) == 0 {
+		s.jwtSecret = []byte(defaultJWTSecretKey)
+	}
+	if s.accessTokenTTL <= 0 {
+		s.accessTokenTTL = defaultAccessTokenTTL
+	}
+	if s.refreshTokenTTL <= 0 {
+		s.refreshTokenTTL = defaultRefreshTokenTTL
+	}
+	s.revocations = newTokenRevocationCache(s.accessTokenTTL)
+	s.loadRecentRevocations()
+	return s
+}
+
+// loadRecentRevocations 启动时加载近期注销的会话，保证重启后已吊销的访问令牌仍然无效
+func (s *UserService) loadRecentRevocations() {
+	sessions, err := s.userRepo.GetSessionsRevokedSince(time.Now().UTC().Add(-s.accessTokenTTL))
+	if err != nil {
+		log.Printf("加载已注销会话失败: %v", err)
+		return
+	}
+	for _, session := range sessions {
+		s.revocations.revokeSession(session.ID, *session.RevokedAt)
 	}
 }
 
@@ -40,34 +80,146 @@ func (s *UserService) Login(req *model.LoginRequest) (*model.LoginResponse, erro
 	if err != nil {
 		return nil, err
 	}
+	if user.IsDisabled() {
+		return nil, errors.New("账户已被禁用")
+	}
+
+	// 生成刷新令牌并创建会话
+	refreshToken, err := generateRefreshToken()
+	if err != nil {
+		return nil, err
+	}
 
-	// 生成JWT令牌
-	token, err := s.generateJWT(user)
+	expiresAt := time.Now().UTC().Add(s.refreshTokenTTL)
+	userAgent := truncateRunes(req.UserAgent, sessionUserAgentMaxLen)
+	ipAddress := truncateRunes(req.IPAddress, sessionIPAddressMaxLen)
+	session, err := s.userRepo.CreateSession(user.ID, hashRefreshToken(refreshToken), userAgent, ipAddress, expiresAt)
 	if err != nil {
 		return nil, err
 	}
 
-	// 设置过期时间
-	expiresAt := time.Now().UTC().Add(jwtExpirationTime)
+	return s.buildTokenResponse(user, session.ID, refreshToken)
+}
 
-	// 保存会话到数据库
-	err = s.userRepo.SaveSession(user.ID, token, expiresAt)
+// RefreshToken 使用刷新令牌换取新的令牌对
+// 刷新令牌每次使用后轮换；已轮换的令牌再次出现说明可能被盗用，整个会话随即注销
+func (s *UserService) RefreshToken(refreshToken string) (*model.LoginResponse, error) {
+	newRefreshToken, err := generateRefreshToken()
 	if err != nil {
 		return nil, err
 	}
 
-	// 返回登录响应
-	return &model.LoginResponse{
-		Token:     token,
-		UserID:    user.ID,
-		UserType:  user.UserType,
-		ExpiresIn: int(jwtExpirationTime.Seconds()),
-	}, nil
+	expiresAt := time.Now().UTC().Add(s.refreshTokenTTL)
+	session, err := s.userRepo.RotateRefreshToken(hashRefreshToken(refreshToken), hashRefreshToken(newRefreshToken), expiresAt)
+	if errors.Is(err, repository.ErrRefreshTokenReused) {
+		log.Printf("检测到刷新令牌重复使用，注销会话 %s（用户 %s）", session.ID, session.UserID)
+		if revokeErr := s.revokeSession(session.ID, "refresh_token_reuse"); revokeErr != nil {
+			log.Printf("注销会话 %s 失败: %v", session.ID, revokeErr)
+		}
+		return nil, errors.New("刷新令牌已被使用，会话已注销，请重新登录")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := s.userRepo.GetByID(session.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if user.IsDisabled() {
+		return nil, errors.New("账户已被禁用")
+	}
+
+	return s.buildTokenResponse(user, session.ID, newRefreshToken)
 }
 
-// Logout 用户登出
-func (s *UserService) Logout(token string) error {
-	return s.userRepo.InvalidateSession(token)
+// Logout 用户登出，注销当前会话
+func (s *UserService) Logout(sessionID uuid.UUID) error {
+	return s.revokeSession(sessionID, "logout")
+}
+
+// GetUserSessions 获取用户的活跃会话列表
+func (s *UserService) GetUserSessions(userID, currentSessionID uuid.UUID) ([]*model.UserSession, error) {
+	sessions, err := s.userRepo.GetActiveSessionsByUserID(userID)
+	if err != nil {
+		return nil, err
+	}
+	for _, session := range sessions {
+		session.Current = session.ID == currentSessionID
+	}
+	return sessions, nil
+}
+
+// RevokeUserSession 用户注销自己的某个会话
+func (s *UserService) RevokeUserSession(userID, sessionID uuid.UUID) error {
+	session, err := s.userRepo.GetSessionByID(sessionID)
+	if err != nil {
+		return err
+	}
+	if session.UserID != userID {
+		return errors.New("会话不存在")
+	}
+	return s.revokeSession(sessionID, "user_revoked")
+}
+
+// RevokeOtherSessions 注销除当前会话外的所有会话
+func (s *UserService) RevokeOtherSessions(userID, currentSessionID uuid.UUID) (int, error) {
+	revoked, err := s.userRepo.RevokeUserSessions(userID, currentSessionID, "user_revoked")
+	if err != nil {
+		return 0, err
+	}
+	now := time.Now().UTC()
+	for _, id := range revoked {
+		s.revocations.revokeSession(id, now)
+	}
+	return len(revoked), nil
+}
+
+// ForceLogoutUser 管理员强制用户下线
+func (s *UserService) ForceLogoutUser(userID uuid.UUID) (int, error) {
+	if _, err := s.userRepo.GetByID(userID); err != nil {
+		return 0, err
+	}
+
+	revoked, err := s.userRepo.RevokeUserSessions(userID, uuid.Nil, "admin_force_logout")
+	if err != nil {
+		return 0, err
+	}
+
+	now := time.Now().UTC()
+	for _, id := range revoked {
+		s.revocations.revokeSession(id, now)
+	}
+	s.revocations.revokeUserBefore(userID, now)
+	log.Printf("用户 %s 已被强制下线，注销会话 %d 个", userID, len(revoked))
+	return len(revoked), nil
+}
+
+// revokeSession 注销会话并写入吊销缓存
+func (s *UserService) revokeSession(sessionID uuid.UUID, reason string) error {
+	if err := s.userRepo.RevokeSession(sessionID, reason); err != nil {
+		return err
+	}
+	s.revocations.revokeSession(sessionID, time.Now().UTC())
+	return nil
+}
+
+// buildTokenResponse 签发访问令牌并组装响应
+func (s *UserService) buildTokenResponse(user *model.User, sessionID uuid.UUID, refreshToken string) (*model.LoginResponse, error) {
+	token, err := s.generateJWT(user, sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &model.LoginResponse{
+		Token:            token,
+		RefreshToken:     refreshToken,
+		SessionID:        sessionID,
+		UserID:           user.ID,
+		UserType:         user.UserType,
+		ExpiresIn:        int(s.accessTokenTTL.Seconds()),
+		RefreshExpiresIn: int(s.refreshTokenTTL.Seconds()),
+	}, nil
 }
 
 // GetUserByID 根据ID获取用户
@@ -81,19 +233,109 @@ func (s *UserService) UpdateUser(user *model.User) error {
 }
 
 // GetUsers 获取用户列表
-func (s *UserService) GetUsers(page, pageSize int) ([]*model.User, int, error) {
-	return s.userRepo.List(page, pageSize)
+func (s *UserService) GetUsers(filter *model.UserFilter, page, pageSize int) ([]*model.User, int, error) {
+	if page <= 0 {
+		page = 1
+	}
+	if pageSize <= 0 {
+		pageSize = 10
+	}
+	return s.userRepo.List(filter, page, pageSize)
+}
+
+// DisableUser 禁用用户并强制下线
+func (s *UserService) DisableUser(operatorID, userID uuid.UUID, reason string) error {
+	if operatorID == userID {
+		return errors.New("不能禁用自己的账户")
+	}
+	// 最后一个管理员的检查与禁用在同一事务内完成，防止并发禁用全部管理员
+	if err := s.userRepo.SetDisabled(userID, true, reason); err != nil {
+		return err
+	}
+	log.Printf("用户 %s 已被禁用（操作人 %s）: %s", userID, operatorID, reason)
+
+	_, err := s.ForceLogoutUser(userID)
+	return err
+}
+
+// EnableUser 启用用户
+func (s *UserService) EnableUser(operatorID, userID uuid.UUID) error {
+	if err := s.userRepo.SetDisabled(userID, false, ""); err != nil {
+		return err
+	}
+	log.Printf("用户 %s 已被启用（操作人 %s）", userID, operatorID)
+	return nil
+}
+
+// CreatePasswordResetToken 管理员为用户生成一次性密码重置令牌
+func (s *UserService) CreatePasswordResetToken(operatorID, userID uuid.UUID) (string, time.Time, error) {
+	if _, err := s.userRepo.GetByID(userID); err != nil {
+		return "", time.Time{}, err
+	}
+
+	token, err := generateRefreshToken()
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	expiresAt := time.Now().UTC().Add(passwordResetTokenTTL)
+	if err := s.userRepo.CreatePasswordResetToken(userID, operatorID, hashRefreshToken(token), expiresAt); err != nil {
+		return "", time.Time{}, err
+	}
+
+	log.Printf("为用户 %s 生成密码重置令牌（操作人 %s）", userID, operatorID)
+	return token, expiresAt, nil
+}
+
+// ResetPassword 使用重置令牌设置新密码，并注销该用户的所有会话
+func (s *UserService) ResetPassword(token, newPassword string) error {
+	userID, err := s.userRepo.ResetPasswordWithToken(hashRefreshToken(token), newPassword)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.ForceLogoutUser(userID)
+	return err
+}
+
+// AssignRole 分配用户角色
+func (s *UserService) AssignRole(operatorID, userID uuid.UUID, userType model.UserType) (*model.User, error) {
+	if !model.IsValidUserType(userType) {
+		return nil, errors.New("无效的角色")
+	}
+
+	user, err := s.userRepo.GetByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	if user.UserType == userType {
+		return user, nil
+	}
+
+	if user.UserType == model.UserTypeAdmin && operatorID == userID {
+		return nil, errors.New("不能修改自己的管理员角色")
+	}
+
+	// 最后一个管理员的检查与更新在同一事务内完成，防止并发降级移除全部管理员
+	if err := s.userRepo.UpdateUserType(userID, userType); err != nil {
+		return nil, err
+	}
+
+	log.Printf("用户 %s 角色由 %s 变更为 %s（操作人 %s）", userID, user.UserType, userType, operatorID)
+	user.UserType = userType
+	return user, nil
 }
 
-// ValidateToken 验证JWT令牌
-func (s *UserService) ValidateToken(tokenString string) (*uuid.UUID, error) {
+// ValidateToken 验证访问令牌
+// 只校验签名、有效期和内存中的吊销缓存，不访问数据库
+func (s *UserService) ValidateToken(tokenString string) (*model.AccessClaims, error) {
 	// 解析令牌
 	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (any, error) {
 		// 验证签名方法
 		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
 			return nil, errors.New("非法的签名方法")
 		}
-		return []byte(jwtSecretKey), nil
+		return s.jwtSecret, nil
 	})
 
 	if err != nil {
@@ -105,7 +347,7 @@ func (s *UserService) ValidateToken(tokenString string) (*uuid.UUID, error) {
 		return nil, errors.New("无效的令牌")
 	}
 
-	// 从令牌中提取用户ID
+	// 从令牌中提取声明
 	claims, ok := token.Claims.(jwt.MapClaims)
 	if !ok {
 		return nil, errors.New("无效的令牌声明")
@@ -115,30 +357,81 @@ func (s *UserService) ValidateToken(tokenString string) (*uuid.UUID, error) {
 	if !ok {
 		return nil, errors.New("令牌中没有用户ID")
 	}
+	userID, err := uuid.Parse(userIDStr)
+	if err != nil {
+		return nil, err
+	}
 
-	// 将用户ID字符串转换为UUID
-	_, err = uuid.Parse(userIDStr)
+	sessionIDStr, ok := claims["sid"].(string)
+	if !ok {
+		return nil, errors.New("令牌中没有会话ID")
+	}
+	sessionID, err := uuid.Parse(sessionIDStr)
 	if err != nil {
 		return nil, err
 	}
 
-	// 从数据库验证会话有效性
-	return s.userRepo.GetSessionByToken(tokenString)
+	iat, ok := claims["iat"].(float64)
+	if !ok {
+		return nil, errors.New("令牌中没有签发时间")
+	}
+
+	accessClaims := &model.AccessClaims{
+		UserID:    userID,
+		SessionID: sessionID,
+		IssuedAt:  time.Unix(int64(iat), 0).UTC(),
+	}
+
+	// 检查吊销缓存
+	if s.revocations.isRevoked(accessClaims) {
+		return nil, errors.New("令牌已被吊销")
+	}
+
+	return accessClaims, nil
 }
 
-// generateJWT 生成JWT令牌
-func (s *UserService) generateJWT(user *model.User) (string, error) {
+// generateJWT 生成访问令牌
+func (s *UserService) generateJWT(user *model.User, sessionID uuid.UUID) (string, error) {
+	now := time.Now().UTC()
+
 	// 设置JWT声明
 	claims := jwt.MapClaims{
 		"user_id":   user.ID.String(),
 		"username":  user.Username,
 		"user_type": user.UserType,
-		"exp":       time.Now().UTC().Add(jwtExpirationTime).Unix(),
+		"sid":       sessionID.String(),
+		"jti":       uuid.New().String(),
+		"iat":       now.Unix(),
+		"exp":       now.Add(s.accessTokenTTL).Unix(),
 	}
 
 	// 创建令牌
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
 
 	// 签名令牌
-	return token.SignedString([]byte(jwtSecretKey))
+	return token.SignedString(s.jwtSecret)
+}
+
+// generateRefreshToken 生成随机刷新令牌
+func generateRefreshToken() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+// hashRefreshToken 计算刷新令牌哈希，数据库只保存哈希值
+func hashRefreshToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// truncateRunes 按字符截断字符串，客户端提供的字段超长时不影响登录
+func truncateRunes(s string, maxLen int) string {
+	runes := []rune(s)
+	if len(runes) <= maxLen {
+		return s
+	}
+	return string(runes[:maxLen])
 }
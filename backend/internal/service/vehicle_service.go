@@ -0,0 +1,138 @@
+package service
+
+import (
+	"errors"
+	"strings"
+
+	"backend/internal/model"
+	"backend/internal/repository"
+
+	"github.com/google/uuid"
+)
+
+// VehicleService 车辆服务
+type VehicleService struct {
+	vehicleRepo *repository.VehicleRepository
+	requestRepo *repository.ChargingRequestRepository
+}
+
+// NewVehicleService 创建车辆服务
+func NewVehicleService(vehicleRepo *repository.VehicleRepository, requestRepo *repository.ChargingRequestRepository) *VehicleService {
+	return &VehicleService{
+		vehicleRepo: vehicleRepo,
+		requestRepo: requestRepo,
+	}
+}
+
+// GetUserVehicles 获取用户的车辆列表
+func (s *VehicleService) GetUserVehicles(userID uuid.UUID) ([]*model.Vehicle, error) {
+	return s.vehicleRepo.GetByUserID(userID)
+}
+
+// GetVehicle 获取用户的指定车辆
+func (s *VehicleService) GetVehicle(userID, vehicleID uuid.UUID) (*model.Vehicle, error) {
+	return findUserVehicle(s.vehicleRepo, userID, vehicleID)
+}
+
+// CreateVehicle 登记车辆
+func (s *VehicleService) CreateVehicle(userID uuid.UUID, req *model.VehicleCreate) (*model.Vehicle, error) {
+	vehicle := &model.Vehicle{
+		ID:     uuid.New(),
+		UserID: userID,
+	}
+	if err := applyVehicleFields(vehicle, req); err != nil {
+		return nil, err
+	}
+
+	if err := s.vehicleRepo.Create(vehicle); err != nil {
+		return nil, err
+	}
+	return vehicle, nil
+}
+
+// UpdateVehicle 更新车辆信息
+func (s *VehicleService) UpdateVehicle(userID, vehicleID uuid.UUID, req *model.VehicleCreate) (*model.Vehicle, error) {
+	vehicle, err := findUserVehicle(s.vehicleRepo, userID, vehicleID)
+	if err != nil {
+		return nil, err
+	}
+
+	// 充电请求进行中时不允许修改，避免电池容量与请求不一致
+	if active, err := s.requestRepo.GetActiveRequestByVehicleID(vehicleID); err == nil && active != nil {
+		return nil, errors.New("车辆有进行中的充电请求，暂不能修改")
+	}
+
+	if err := applyVehicleFields(vehicle, req); err != nil {
+		return nil, err
+	}
+
+	if err := s.vehicleRepo.Update(vehicle); err != nil {
+		return nil, err
+	}
+	return vehicle, nil
+}
+
+// DeleteVehicle 删除车辆
+func (s *VehicleService) DeleteVehicle(userID, vehicleID uuid.UUID) error {
+	if _, err := findUserVehicle(s.vehicleRepo, userID, vehicleID); err != nil {
+		return err
+	}
+
+	if active, err := s.requestRepo.GetActiveRequestByVehicleID(vehicleID); err == nil && active != nil {
+		return errors.New("车辆有进行中的充电请求，暂不能删除")
+	}
+
+	return s.vehicleRepo.Delete(vehicleID)
+}
+
+// findUserVehicle 查找属于用户的未删除车辆
+func findUserVehicle(vehicleRepo *repository.VehicleRepository, userID, vehicleID uuid.UUID) (*model.Vehicle, error) {
+	vehicles, err := vehicleRepo.GetByUserID(userID)
+	if err != nil {
+		return nil, err
+	}
+	for _, vehicle := range vehicles {
+		if vehicle.ID == vehicleID {
+			return vehicle, nil
+		}
+	}
+	return nil, errors.New("车辆不存在")
+}
+
+// applyVehicleFields 校验并写入车辆字段
+func applyVehicleFields(vehicle *model.Vehicle, req *model.VehicleCreate) error {
+	plate := strings.TrimSpace(req.LicensePlate)
+	if plate == "" {
+		return errors.New("车牌号不能为空")
+	}
+	if req.BatteryCapacity <= 0 {
+		return errors.New("电池容量必须大于0")
+	}
+
+	pileTypes := req.SupportedPileTypes
+	if len(pileTypes) == 0 {
+		pileTypes = []model.PileType{model.PileTypeFast, model.PileTypeSlow}
+	}
+	for _, t := range pileTypes {
+		if t != model.PileTypeFast && t != model.PileTypeSlow {
+			return errors.New("无效的充电桩类型: " + string(t))
+		}
+	}
+
+	vehicle.LicensePlate = plate
+	vehicle.BatteryCapacity = req.BatteryCapacity
+	vehicle.SupportedPileTypes = pileTypes
+	vehicle.VehicleProfile = strings.TrimSpace(req.VehicleProfile)
+	if len(vehicle.VehicleProfile) > 64 {
+		return errors.New("车辆配置名称不能超过64个字符")
+	}
+
+	vehicle.DefaultMode = req.DefaultMode
+	if vehicle.DefaultMode == "" {
+		vehicle.DefaultMode = model.ChargingMode(pileTypes[0])
+	}
+	if !vehicle.SupportsMode(vehicle.DefaultMode) {
+		return errors.New("默认充电模式不在车辆支持的充电桩类型内")
+	}
+	return nil
+}
@@ -0,0 +1,380 @@
+package service
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"backend/internal/config"
+	"backend/internal/model"
+	"backend/internal/repository"
+
+	"github.com/google/uuid"
+)
+
+// webhook推送默认参数
+const (
+	defaultWebhookMaxAttempts = 8
+	defaultWebhookMaxBackoff  = 10 * time.Minute
+	defaultWebhookPushTimeout = 10 * time.Second
+	webhookPollInterval       = 2 * time.Second
+	webhookBatchSize          = 50
+)
+
+var (
+	// ErrWebhookSubscriptionNotFound webhook订阅不存在
+	ErrWebhookSubscriptionNotFound = errors.New("webhook订阅不存在")
+	// ErrInvalidWebhookSubscription webhook订阅参数不合法
+	ErrInvalidWebhookSubscription = errors.New("无效的webhook订阅")
+	// ErrWebhookDeliveryNotReplayable 投递记录不存在或仍在投递中
+	ErrWebhookDeliveryNotReplayable = errors.New("投递记录不存在或仍在投递中")
+)
+
+// WebhookService 第三方webhook推送服务
+// 调度与计费的状态变化发布为事件，按订阅的事件过滤生成投递记录，异步以HMAC签名的POST请求推送，
+// 失败按指数退避重试，重试次数用尽后进入死信，由管理员重放
+type WebhookService struct {
+	webhookRepo *repository.WebhookRepository
+	client      *http.Client
+	maxAttempts int
+	worker      *deliveryWorker[*model.WebhookDelivery]
+}
+
+// NewWebhookService 创建webhook推送服务
+func NewWebhookService(webhookRepo *repository.WebhookRepository, cfg config.WebhookConfig) *WebhookService {
+	timeout := time.Duration(cfg.Timeout) * time.Second
+	if timeout <= 0 {
+		timeout = defaultWebhookPushTimeout
+	}
+	s := &WebhookService{
+		webhookRepo: webhookRepo,
+		client:      newWebhookHTTPClient(timeout),
+		maxAttempts: cfg.MaxAttempts,
+	}
+	if s.maxAttempts <= 0 {
+		s.maxAttempts = defaultWebhookMaxAttempts
+	}
+	maxBackoff := time.Duration(cfg.MaxBackoff) * time.Second
+	if maxBackoff <= 0 {
+		maxBackoff = defaultWebhookMaxBackoff
+	}
+	s.worker = newDeliveryWorker[*model.WebhookDelivery](s, deliveryWorkerConfig{
+		name:         "webhook",
+		pollInterval: webhookPollInterval,
+		batchSize:    webhookBatchSize,
+		maxBackoff:   maxBackoff,
+	})
+
+	// 启动投递循环，继续投递重启前未完成的记录
+	s.worker.start()
+
+	return s
+}
+
+// Publish 发布事件，为订阅了该事件的每个订阅生成投递记录；发布失败只记录日志，不影响业务流程
+func (s *WebhookService) Publish(eventType model.WebhookEventType, data map[string]any) {
+	if err := s.publish(eventType, data); err != nil {
+		log.Printf("发布webhook事件失败: 事件=%s, 错误=%v", eventType, err)
+	}
+}
+
+// publish 序列化事件并生成投递记录
+func (s *WebhookService) publish(eventType model.WebhookEventType, data map[string]any) error {
+	subscriptions, err := s.webhookRepo.GetSubscriptions(true)
+	if err != nil {
+		return fmt.Errorf("获取webhook订阅失败: %w", err)
+	}
+
+	now := time.Now().UTC()
+	event := &model.WebhookEvent{
+		ID:         uuid.New(),
+		Type:       eventType,
+		OccurredAt: now,
+		Data:       data,
+	}
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("序列化事件失败: %w", err)
+	}
+
+	var deliveries []*model.WebhookDelivery
+	for _, subscription := range subscriptions {
+		if !subscription.Subscribes(eventType) {
+			continue
+		}
+		deliveries = append(deliveries, &model.WebhookDelivery{
+			ID:             uuid.New(),
+			SubscriptionID: subscription.ID,
+			EventID:        event.ID,
+			EventType:      eventType,
+			Payload:        payload,
+			Status:         model.WebhookDeliveryPending,
+			NextAttemptAt:  now,
+			CreatedAt:      now,
+		})
+	}
+	if len(deliveries) == 0 {
+		return nil
+	}
+
+	if err := s.webhookRepo.CreateDeliveries(deliveries); err != nil {
+		return fmt.Errorf("保存投递记录失败: %w", err)
+	}
+	s.worker.wake()
+	return nil
+}
+
+// CreateSubscription 创建订阅并生成签名密钥，密钥只在创建与轮换时返回
+func (s *WebhookService) CreateSubscription(operatorID uuid.UUID, req *model.WebhookSubscriptionCreate) (*model.WebhookSubscription, string, error) {
+	if err := validateWebhookSubscription(req.Name, req.URL, req.Events); err != nil {
+		return nil, "", err
+	}
+	secret, err := generateWebhookSecret()
+	if err != nil {
+		return nil, "", fmt.Errorf("生成签名密钥失败: %w", err)
+	}
+
+	now := time.Now().UTC()
+	subscription := &model.WebhookSubscription{
+		ID:        uuid.New(),
+		Name:      strings.TrimSpace(req.Name),
+		URL:       strings.TrimSpace(req.URL),
+		Secret:    secret,
+		Events:    req.Events,
+		Enabled:   true,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if operatorID != uuid.Nil {
+		subscription.CreatedBy = &operatorID
+	}
+
+	if err := s.webhookRepo.CreateSubscription(subscription); err != nil {
+		return nil, "", err
+	}
+	return subscription, secret, nil
+}
+
+// GetSubscriptions 获取全部订阅
+func (s *WebhookService) GetSubscriptions() ([]*model.WebhookSubscription, error) {
+	return s.webhookRepo.GetSubscriptions(false)
+}
+
+// UpdateSubscription 更新订阅的名称、地址、事件与启用状态
+func (s *WebhookService) UpdateSubscription(id uuid.UUID, req *model.WebhookSubscriptionUpdate) (*model.WebhookSubscription, error) {
+	subscription, err := s.getSubscription(id)
+	if err != nil {
+		return nil, err
+	}
+	if err := validateWebhookSubscription(req.Name, req.URL, req.Events); err != nil {
+		return nil, err
+	}
+
+	subscription.Name = strings.TrimSpace(req.Name)
+	subscription.URL = strings.TrimSpace(req.URL)
+	subscription.Events = req.Events
+	if req.Enabled != nil {
+		subscription.Enabled = *req.Enabled
+	}
+	subscription.UpdatedAt = time.Now().UTC()
+
+	if err := s.webhookRepo.UpdateSubscription(subscription); err != nil {
+		return nil, err
+	}
+	return subscription, nil
+}
+
+// RotateSecret 轮换订阅的签名密钥，之后的投递（包括重试）使用新密钥签名
+func (s *WebhookService) RotateSecret(id uuid.UUID) (string, error) {
+	if _, err := s.getSubscription(id); err != nil {
+		return "", err
+	}
+	secret, err := generateWebhookSecret()
+	if err != nil {
+		return "", fmt.Errorf("生成签名密钥失败: %w", err)
+	}
+	if err := s.webhookRepo.UpdateSecret(id, secret); err != nil {
+		return "", err
+	}
+	return secret, nil
+}
+
+// DeleteSubscription 删除订阅及其投递记录
+func (s *WebhookService) DeleteSubscription(id uuid.UUID) error {
+	ok, err := s.webhookRepo.DeleteSubscription(id)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrWebhookSubscriptionNotFound
+	}
+	return nil
+}
+
+// getSubscription 获取订阅，不存在时返回 ErrWebhookSubscriptionNotFound
+func (s *WebhookService) getSubscription(id uuid.UUID) (*model.WebhookSubscription, error) {
+	subscription, err := s.webhookRepo.GetSubscriptionByID(id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrWebhookSubscriptionNotFound
+	}
+	return subscription, err
+}
+
+// validateWebhookSubscription 校验订阅名称、地址与事件
+func validateWebhookSubscription(name, rawURL string, events []model.WebhookEventType) error {
+	if strings.TrimSpace(name) == "" {
+		return fmt.Errorf("%w: 名称不能为空", ErrInvalidWebhookSubscription)
+	}
+	u, err := url.Parse(strings.TrimSpace(rawURL))
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("%w: 地址须为http或https地址", ErrInvalidWebhookSubscription)
+	}
+	if err := checkWebhookHost(u.Hostname()); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidWebhookSubscription, err)
+	}
+	for _, eventType := range events {
+		if !model.IsValidWebhookEventType(eventType) {
+			return fmt.Errorf("%w: 未知的事件类型 %s", ErrInvalidWebhookSubscription, eventType)
+		}
+	}
+	return nil
+}
+
+// generateWebhookSecret 生成随机签名密钥
+func generateWebhookSecret() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return "whsec_" + hex.EncodeToString(buf), nil
+}
+
+// GetDeliveries 分页查询投递记录，status 为 dead 即死信队列
+func (s *WebhookService) GetDeliveries(filter *model.WebhookDeliveryFilter, page, pageSize int) ([]*model.WebhookDelivery, int, error) {
+	return s.webhookRepo.GetDeliveries(filter, page, pageSize)
+}
+
+// ReplayDelivery 重放一条死信或已投递的记录，事件ID与内容不变，重新计算重试次数
+func (s *WebhookService) ReplayDelivery(id uuid.UUID) (*model.WebhookDelivery, error) {
+	ok, err := s.webhookRepo.Replay(id)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrWebhookDeliveryNotReplayable
+	}
+	s.worker.wake()
+	return s.webhookRepo.GetDeliveryByID(id)
+}
+
+// ReplayDeadDeliveries 重放订阅的全部死信，返回重放的数量
+func (s *WebhookService) ReplayDeadDeliveries(subscriptionID uuid.UUID) (int64, error) {
+	if _, err := s.getSubscription(subscriptionID); err != nil {
+		return 0, err
+	}
+	count, err := s.webhookRepo.ReplayDead(subscriptionID)
+	if err != nil {
+		return 0, err
+	}
+	if count > 0 {
+		s.worker.wake()
+	}
+	return count, nil
+}
+
+// claimDue 认领到期的投递记录
+func (s *WebhookService) claimDue(now time.Time, limit int, lease time.Duration) ([]*model.WebhookDelivery, error) {
+	return s.webhookRepo.ClaimDueDeliveries(now, limit, lease)
+}
+
+// deliveryKey 按投递记录区分
+func (s *WebhookService) deliveryKey(delivery *model.WebhookDelivery) string {
+	return delivery.ID.String()
+}
+
+// deliveryAttempts 已尝试次数
+func (s *WebhookService) deliveryAttempts(delivery *model.WebhookDelivery) int {
+	return delivery.Attempts
+}
+
+// describe 日志中的记录描述
+func (s *WebhookService) describe(delivery *model.WebhookDelivery) string {
+	return fmt.Sprintf("记录=%s, 事件=%s", delivery.ID, delivery.EventType)
+}
+
+// exhausted 达到最大投递次数后进入死信
+func (s *WebhookService) exhausted(_ *model.WebhookDelivery, attempts int) bool {
+	return attempts >= s.maxAttempts
+}
+
+// markSucceeded 记录投递成功
+func (s *WebhookService) markSucceeded(delivery *model.WebhookDelivery, attempts, statusCode int) error {
+	return s.webhookRepo.MarkDelivered(delivery.ID, attempts, statusCode)
+}
+
+// markRetry 记录投递失败，等待重试
+func (s *WebhookService) markRetry(delivery *model.WebhookDelivery, attempts, statusCode int, nextAttemptAt time.Time, cause error) error {
+	return s.webhookRepo.MarkRetry(delivery.ID, attempts, statusCode, nextAttemptAt, cause.Error())
+}
+
+// markAbandoned 重试次数用尽，进入死信
+func (s *WebhookService) markAbandoned(delivery *model.WebhookDelivery, attempts, statusCode int, cause error) error {
+	return s.webhookRepo.MarkDead(delivery.ID, attempts, statusCode, cause.Error())
+}
+
+// send 签名并推送事件，返回对方的响应状态码（未收到响应时为0），非2xx视为失败
+func (s *WebhookService) send(delivery *model.WebhookDelivery) (int, error) {
+	subscription, err := s.webhookRepo.GetSubscriptionByID(delivery.SubscriptionID)
+	if err != nil {
+		return 0, fmt.Errorf("获取webhook订阅失败: %w", err)
+	}
+	if !subscription.Enabled {
+		return 0, fmt.Errorf("webhook订阅已停用")
+	}
+
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req, err := http.NewRequest(http.MethodPost, subscription.URL, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return 0, fmt.Errorf("创建请求失败: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Webhook-Id", delivery.EventID.String())
+	req.Header.Set("X-Webhook-Event", string(delivery.EventType))
+	req.Header.Set("X-Webhook-Delivery", delivery.ID.String())
+	req.Header.Set("X-Webhook-Timestamp", timestamp)
+	req.Header.Set("X-Webhook-Signature", "sha256="+signWebhookPayload(subscription.Secret, timestamp, delivery.Payload))
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("发送请求失败: %w", err)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode, fmt.Errorf("webhook返回状态码 %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
+
+// signWebhookPayload 计算签名：HMAC-SHA256(secret, timestamp + "." + body)，十六进制编码
+func signWebhookPayload(secret, timestamp string, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(payload)
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,158 @@
+package service
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSignWebhookPayload(t *testing.T) {
+	payload := []byte(`{"id":"evt_1","type":"bill.created"}`)
+
+	tests := []struct {
+		name      string
+		secret    string
+		timestamp string
+		payload   []byte
+		want      string
+	}{
+		{
+			name:      "签名覆盖时间戳与请求体",
+			secret:    "whsec_test",
+			timestamp: "1700000000",
+			payload:   payload,
+			want:      "8f6d312478087945f80541be9f19c483af07d2fd723625d4c4260c15a6d9849f",
+		},
+		{
+			name:      "不同密钥签名不同",
+			secret:    "other",
+			timestamp: "1700000000",
+			payload:   payload,
+			want:      "37d312469b7f39cf82bb3ecd47b5d88234e445782589da39f0abde11bdde8494",
+		},
+		{
+			name:      "空请求体",
+			secret:    "whsec_test",
+			timestamp: "1700000000",
+			payload:   nil,
+			want:      "5967f3c560522fa40cf2876ebc3c3a08551dd6959aaade3b413460591895bdcc",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := signWebhookPayload(tt.secret, tt.timestamp, tt.payload); got != tt.want {
+				t.Errorf("signWebhookPayload() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDeliveryBackoff(t *testing.T) {
+	tests := []struct {
+		name       string
+		attempts   int
+		maxBackoff time.Duration
+		want       time.Duration
+	}{
+		{name: "第1次失败", attempts: 1, maxBackoff: time.Minute, want: time.Second},
+		{name: "第2次失败", attempts: 2, maxBackoff: time.Minute, want: 2 * time.Second},
+		{name: "第5次失败", attempts: 5, maxBackoff: time.Minute, want: 16 * time.Second},
+		{name: "达到上限", attempts: 7, maxBackoff: time.Minute, want: time.Minute},
+		{name: "多次失败不溢出", attempts: 100, maxBackoff: 10 * time.Minute, want: 10 * time.Minute},
+		{name: "上限小于1秒", attempts: 1, maxBackoff: 500 * time.Millisecond, want: 500 * time.Millisecond},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := deliveryBackoff(tt.attempts, tt.maxBackoff); got != tt.want {
+				t.Errorf("deliveryBackoff(%d, %v) = %v, want %v", tt.attempts, tt.maxBackoff, got, tt.want)
+			}
+		})
+	}
+}
+
+// fakeDelivery 测试用投递记录
+type fakeDelivery struct {
+	id       string
+	attempts int
+}
+
+// fakeDeliveryQueue 记录投递循环写回的结果
+type fakeDeliveryQueue struct {
+	sendErr     error
+	maxAttempts int
+
+	outcome       string
+	attempts      int
+	nextAttemptAt time.Time
+}
+
+func (q *fakeDeliveryQueue) claimDue(time.Time, int, time.Duration) ([]*fakeDelivery, error) {
+	return nil, nil
+}
+func (q *fakeDeliveryQueue) deliveryKey(d *fakeDelivery) string   { return d.id }
+func (q *fakeDeliveryQueue) deliveryAttempts(d *fakeDelivery) int { return d.attempts }
+func (q *fakeDeliveryQueue) describe(d *fakeDelivery) string      { return d.id }
+func (q *fakeDeliveryQueue) send(*fakeDelivery) (int, error)      { return 0, q.sendErr }
+func (q *fakeDeliveryQueue) exhausted(_ *fakeDelivery, attempts int) bool {
+	return attempts >= q.maxAttempts
+}
+func (q *fakeDeliveryQueue) markSucceeded(_ *fakeDelivery, attempts, _ int) error {
+	q.outcome, q.attempts = "succeeded", attempts
+	return nil
+}
+func (q *fakeDeliveryQueue) markRetry(_ *fakeDelivery, attempts, _ int, nextAttemptAt time.Time, _ error) error {
+	q.outcome, q.attempts, q.nextAttemptAt = "retry", attempts, nextAttemptAt
+	return nil
+}
+func (q *fakeDeliveryQueue) markAbandoned(_ *fakeDelivery, attempts, _ int, _ error) error {
+	q.outcome, q.attempts = "abandoned", attempts
+	return nil
+}
+
+func TestDeliveryWorkerDeliver(t *testing.T) {
+	tests := []struct {
+		name         string
+		sendErr      error
+		prevAttempts int
+		wantOutcome  string
+		wantAttempts int
+		wantBackoff  time.Duration
+	}{
+		{name: "首次投递成功", wantOutcome: "succeeded", wantAttempts: 1},
+		{name: "重试后成功", prevAttempts: 2, wantOutcome: "succeeded", wantAttempts: 3},
+		{name: "失败后退避重试", sendErr: errTest, wantOutcome: "retry", wantAttempts: 1, wantBackoff: time.Second},
+		{name: "再次失败退避翻倍", sendErr: errTest, prevAttempts: 1, wantOutcome: "retry", wantAttempts: 2, wantBackoff: 2 * time.Second},
+		{name: "重试次数用尽", sendErr: errTest, prevAttempts: 2, wantOutcome: "abandoned", wantAttempts: 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			queue := &fakeDeliveryQueue{sendErr: tt.sendErr, maxAttempts: 3}
+			w := newDeliveryWorker[*fakeDelivery](queue, deliveryWorkerConfig{name: "测试", maxBackoff: time.Minute})
+			w.inFlight["d1"] = true
+
+			before := time.Now().UTC()
+			w.deliver("d1", &fakeDelivery{id: "d1", attempts: tt.prevAttempts})
+
+			if queue.outcome != tt.wantOutcome || queue.attempts != tt.wantAttempts {
+				t.Fatalf("结果 = %s/%d, want %s/%d", queue.outcome, queue.attempts, tt.wantOutcome, tt.wantAttempts)
+			}
+			if tt.wantBackoff > 0 {
+				if backoff := queue.nextAttemptAt.Sub(before); backoff < tt.wantBackoff || backoff > tt.wantBackoff+time.Second {
+					t.Errorf("重试间隔 = %v, want %v", backoff, tt.wantBackoff)
+				}
+			}
+			if w.inFlight["d1"] {
+				t.Error("投递结束后未释放投递中标记")
+			}
+		})
+	}
+}
+
+// errTest 测试用投递错误
+var errTest = testError("投递失败")
+
+type testError string
+
+func (e testError) Error() string { return string(e) }
@@ -0,0 +1,16 @@
+-- 删除索引
+DROP INDEX IF EXISTS idx_rotated_tokens_session_id;
+DROP INDEX IF EXISTS idx_user_sessions_revoked_at;
+
+-- 删除表
+DROP TABLE IF EXISTS user_session_rotated_tokens;
+
+-- 恢复会话表结构
+DELETE FROM user_sessions;
+ALTER TABLE user_sessions
+    DROP COLUMN IF EXISTS revoke_reason,
+    DROP COLUMN IF EXISTS revoked_at,
+    DROP COLUMN IF EXISTS last_used_at,
+    DROP COLUMN IF EXISTS ip_address,
+    DROP COLUMN IF EXISTS user_agent;
+ALTER TABLE user_sessions RENAME COLUMN refresh_token_hash TO token;
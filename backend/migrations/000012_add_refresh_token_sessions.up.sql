@@ -0,0 +1,27 @@
+-- 旧会话保存的是长期JWT，升级后全部失效
+DELETE FROM user_sessions;
+
+-- 会话表改为按设备保存刷新令牌（仅保存哈希）
+ALTER TABLE user_sessions RENAME COLUMN token TO refresh_token_hash;
+ALTER TABLE user_sessions
+    ADD COLUMN user_agent VARCHAR(255) DEFAULT '' NOT NULL,
+    ADD COLUMN ip_address VARCHAR(64) DEFAULT '' NOT NULL,
+    ADD COLUMN last_used_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    ADD COLUMN revoked_at TIMESTAMP,
+    ADD COLUMN revoke_reason VARCHAR(50);
+
+-- 创建已轮换刷新令牌表（用于重放检测）
+CREATE TABLE IF NOT EXISTS user_session_rotated_tokens (
+    token_hash VARCHAR(255) PRIMARY KEY,
+    session_id UUID NOT NULL,
+    rotated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL,
+
+    CONSTRAINT fk_rotated_tokens_session
+        FOREIGN KEY (session_id)
+        REFERENCES user_sessions(id)
+        ON DELETE CASCADE
+);
+
+-- 创建索引
+CREATE INDEX idx_user_sessions_revoked_at ON user_sessions(revoked_at);
+CREATE INDEX idx_rotated_tokens_session_id ON user_session_rotated_tokens(session_id);
@@ -0,0 +1,7 @@
+-- 新角色回退为普通用户
+UPDATE users SET user_type = 'user' WHERE user_type NOT IN ('user', 'admin');
+
+-- 恢复角色约束
+ALTER TABLE users DROP CONSTRAINT IF EXISTS users_user_type_check;
+ALTER TABLE users ADD CONSTRAINT users_user_type_check
+    CHECK (user_type IN ('user', 'admin'));
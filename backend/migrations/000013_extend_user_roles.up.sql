@@ -0,0 +1,4 @@
+-- 扩展用户角色
+ALTER TABLE users DROP CONSTRAINT IF EXISTS users_user_type_check;
+ALTER TABLE users ADD CONSTRAINT users_user_type_check
+    CHECK (user_type IN ('user', 'admin', 'operator', 'maintenance', 'finance', 'auditor'));
@@ -0,0 +1,11 @@
+-- 删除索引
+DROP INDEX IF EXISTS idx_password_reset_tokens_user_id;
+DROP INDEX IF EXISTS idx_users_disabled_at;
+
+-- 删除表
+DROP TABLE IF EXISTS password_reset_tokens;
+
+-- 删除用户禁用状态
+ALTER TABLE users
+    DROP COLUMN IF EXISTS disabled_reason,
+    DROP COLUMN IF EXISTS disabled_at;
@@ -0,0 +1,23 @@
+-- 用户禁用状态
+ALTER TABLE users
+    ADD COLUMN disabled_at TIMESTAMP,
+    ADD COLUMN disabled_reason VARCHAR(255);
+
+-- 创建password_reset_tokens表
+CREATE TABLE IF NOT EXISTS password_reset_tokens (
+    token_hash VARCHAR(64) PRIMARY KEY,
+    user_id UUID NOT NULL,
+    created_by UUID,
+    expires_at TIMESTAMP NOT NULL,
+    used_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL,
+
+    CONSTRAINT fk_password_reset_tokens_user
+        FOREIGN KEY (user_id)
+        REFERENCES users(id)
+        ON DELETE CASCADE
+);
+
+-- 创建索引
+CREATE INDEX idx_users_disabled_at ON users(disabled_at);
+CREATE INDEX idx_password_reset_tokens_user_id ON password_reset_tokens(user_id);
@@ -0,0 +1,15 @@
+-- 删除充电请求的车辆关联
+DROP INDEX IF EXISTS idx_charging_requests_vehicle_active;
+DROP INDEX IF EXISTS idx_charging_requests_vehicle_id;
+ALTER TABLE charging_requests DROP CONSTRAINT IF EXISTS fk_charging_requests_vehicle;
+ALTER TABLE charging_requests DROP COLUMN IF EXISTS vehicle_id;
+
+-- 删除触发器
+DROP TRIGGER IF EXISTS trigger_vehicles_updated_at ON vehicles;
+
+-- 删除索引
+DROP INDEX IF EXISTS idx_vehicles_license_plate;
+DROP INDEX IF EXISTS idx_vehicles_user_id;
+
+-- 删除表
+DROP TABLE IF EXISTS vehicles;
@@ -0,0 +1,61 @@
+-- 创建vehicles表
+CREATE TABLE IF NOT EXISTS vehicles (
+    id UUID PRIMARY KEY,
+    user_id UUID NOT NULL,
+    license_plate VARCHAR(20) NOT NULL,
+    battery_capacity DECIMAL(8,2) NOT NULL CHECK (battery_capacity > 0),
+    supported_pile_types TEXT[] NOT NULL DEFAULT ARRAY['fast', 'slow'],
+    default_mode VARCHAR(10) NOT NULL CHECK (default_mode IN ('fast', 'slow')),
+    deleted_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL,
+
+    CONSTRAINT fk_vehicles_user
+        FOREIGN KEY (user_id)
+        REFERENCES users(id)
+        ON DELETE CASCADE
+);
+
+-- 创建索引
+CREATE INDEX idx_vehicles_user_id ON vehicles(user_id);
+
+-- 使用之前创建的updated_at更新触发器
+CREATE TRIGGER trigger_vehicles_updated_at
+BEFORE UPDATE ON vehicles
+FOR EACH ROW
+EXECUTE FUNCTION update_updated_at_column();
+
+-- 将用户现有车辆信息迁移为车辆记录
+-- 多个用户填写了相同车牌时保留最早注册的用户，其余车牌加后缀 #2、#3…（截断以符合长度限制），由用户自行更正
+INSERT INTO vehicles (id, user_id, license_plate, battery_capacity, default_mode, created_at, updated_at)
+SELECT md5(random()::text || id::text)::uuid, id,
+       CASE WHEN plate_rank = 1 THEN license_plate
+            ELSE left(license_plate, 20 - length('#' || plate_rank)) || '#' || plate_rank
+       END,
+       battery_capacity, 'fast', created_at, updated_at
+FROM (
+    SELECT id, license_plate, battery_capacity, created_at, updated_at,
+           ROW_NUMBER() OVER (PARTITION BY license_plate ORDER BY created_at, id) AS plate_rank
+    FROM users
+    WHERE user_type = 'user'
+) numbered;
+
+-- 回填完成后再建车牌唯一索引
+CREATE UNIQUE INDEX idx_vehicles_license_plate ON vehicles(license_plate) WHERE deleted_at IS NULL;
+
+-- 充电请求关联车辆
+ALTER TABLE charging_requests ADD COLUMN vehicle_id UUID;
+ALTER TABLE charging_requests ADD CONSTRAINT fk_charging_requests_vehicle
+    FOREIGN KEY (vehicle_id)
+    REFERENCES vehicles(id)
+    ON DELETE SET NULL;
+
+UPDATE charging_requests cr
+SET vehicle_id = v.id
+FROM vehicles v
+WHERE v.user_id = cr.user_id;
+
+-- 每辆车同时只能有一个活跃请求
+CREATE INDEX idx_charging_requests_vehicle_id ON charging_requests(vehicle_id);
+CREATE UNIQUE INDEX idx_charging_requests_vehicle_active ON charging_requests(vehicle_id)
+    WHERE status IN ('waiting', 'queued', 'charging');
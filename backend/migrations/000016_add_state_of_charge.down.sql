@@ -0,0 +1,12 @@
+-- 删除详单SoC字段
+ALTER TABLE billing_details DROP COLUMN IF EXISTS end_soc;
+ALTER TABLE billing_details DROP COLUMN IF EXISTS start_soc;
+
+-- 删除充电会话SoC字段
+ALTER TABLE charging_sessions DROP COLUMN IF EXISTS current_soc;
+ALTER TABLE charging_sessions DROP COLUMN IF EXISTS target_soc;
+ALTER TABLE charging_sessions DROP COLUMN IF EXISTS start_soc;
+
+-- 删除充电请求SoC字段
+ALTER TABLE charging_requests DROP COLUMN IF EXISTS target_soc;
+ALTER TABLE charging_requests DROP COLUMN IF EXISTS start_soc;
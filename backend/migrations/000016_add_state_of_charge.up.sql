@@ -0,0 +1,12 @@
+-- 充电请求记录起始/目标电量百分比(SoC)
+ALTER TABLE charging_requests ADD COLUMN start_soc DECIMAL(5,2) CHECK (start_soc >= 0 AND start_soc <= 100);
+ALTER TABLE charging_requests ADD COLUMN target_soc DECIMAL(5,2) CHECK (target_soc >= 0 AND target_soc <= 100);
+
+-- 充电会话记录SoC进度
+ALTER TABLE charging_sessions ADD COLUMN start_soc DECIMAL(5,2);
+ALTER TABLE charging_sessions ADD COLUMN target_soc DECIMAL(5,2);
+ALTER TABLE charging_sessions ADD COLUMN current_soc DECIMAL(5,2);
+
+-- 详单同时展示SoC与电量
+ALTER TABLE billing_details ADD COLUMN start_soc DECIMAL(5,2);
+ALTER TABLE billing_details ADD COLUMN end_soc DECIMAL(5,2);
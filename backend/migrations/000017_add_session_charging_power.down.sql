@@ -0,0 +1,3 @@
+-- 删除充电会话功率字段
+ALTER TABLE charging_sessions DROP COLUMN IF EXISTS remaining_time;
+ALTER TABLE charging_sessions DROP COLUMN IF EXISTS current_power;
@@ -0,0 +1,3 @@
+-- 充电会话记录模拟器上报的瞬时功率与剩余时间
+ALTER TABLE charging_sessions ADD COLUMN current_power DECIMAL(8,2);
+ALTER TABLE charging_sessions ADD COLUMN remaining_time INTEGER;
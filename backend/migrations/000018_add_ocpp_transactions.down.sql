@@ -0,0 +1,7 @@
+-- 删除OCPP交易关联
+DROP INDEX IF EXISTS idx_charging_sessions_ocpp_transaction;
+ALTER TABLE charging_sessions DROP COLUMN IF EXISTS ocpp_meter_start;
+ALTER TABLE charging_sessions DROP COLUMN IF EXISTS ocpp_transaction_id;
+
+-- 删除OCPP交易号序列
+DROP SEQUENCE IF EXISTS ocpp_transaction_id_seq;
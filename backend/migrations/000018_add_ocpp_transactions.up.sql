@@ -0,0 +1,9 @@
+-- OCPP交易号序列
+CREATE SEQUENCE IF NOT EXISTS ocpp_transaction_id_seq START 1;
+
+-- 充电会话关联OCPP交易
+ALTER TABLE charging_sessions ADD COLUMN ocpp_transaction_id INTEGER;
+ALTER TABLE charging_sessions ADD COLUMN ocpp_meter_start INTEGER;
+
+CREATE UNIQUE INDEX idx_charging_sessions_ocpp_transaction ON charging_sessions(ocpp_transaction_id)
+    WHERE ocpp_transaction_id IS NOT NULL;
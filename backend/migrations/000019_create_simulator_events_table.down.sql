@@ -0,0 +1,2 @@
+-- 删除模拟器回调事件表
+DROP TABLE IF EXISTS simulator_events;
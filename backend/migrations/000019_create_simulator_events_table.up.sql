@@ -0,0 +1,15 @@
+-- 模拟器回调事件表，按事件ID去重并保存首次处理结果
+CREATE TABLE IF NOT EXISTS simulator_events (
+    event_id VARCHAR(64) PRIMARY KEY,
+    event_type VARCHAR(32) NOT NULL CHECK (event_type IN ('charging_progress', 'charging_complete', 'fault_report', 'fault_recovery')),
+    pile_id VARCHAR(10) NOT NULL,
+    session_id UUID,
+    status_code INTEGER NOT NULL,
+    response_body TEXT NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL
+);
+
+-- 创建索引
+CREATE INDEX idx_simulator_events_pile_id ON simulator_events(pile_id);
+CREATE INDEX idx_simulator_events_session_id ON simulator_events(session_id);
+CREATE INDEX idx_simulator_events_created_at ON simulator_events(created_at);
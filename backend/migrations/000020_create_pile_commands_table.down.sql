@@ -0,0 +1,2 @@
+-- 删除充电桩指令表
+DROP TABLE IF EXISTS pile_commands;
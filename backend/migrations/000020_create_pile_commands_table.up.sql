@@ -0,0 +1,20 @@
+-- 充电桩指令表，后端下发的启停指令先落库，由指令队列重试投递直到模拟器确认
+CREATE TABLE IF NOT EXISTS pile_commands (
+    id UUID PRIMARY KEY,
+    pile_id VARCHAR(10) NOT NULL,
+    session_id UUID,
+    command_type VARCHAR(20) NOT NULL CHECK (command_type IN ('assign', 'stop')),
+    payload JSONB NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'acknowledged', 'escalated', 'cancelled')),
+    attempts INTEGER DEFAULT 0 NOT NULL,
+    next_attempt_at TIMESTAMP NOT NULL,
+    last_error TEXT,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    acknowledged_at TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL
+);
+
+-- 创建索引
+CREATE INDEX idx_pile_commands_status ON pile_commands(status, created_at);
+CREATE INDEX idx_pile_commands_pile_id ON pile_commands(pile_id);
+CREATE INDEX idx_pile_commands_session_id ON pile_commands(session_id);
@@ -0,0 +1,2 @@
+-- 删除对账告警表
+DROP TABLE IF EXISTS reconciliation_alerts;
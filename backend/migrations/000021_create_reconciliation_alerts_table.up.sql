@@ -0,0 +1,18 @@
+-- 对账告警表，记录后端与模拟器充电桩状态不一致的情况及处理结果
+CREATE TABLE IF NOT EXISTS reconciliation_alerts (
+    id UUID PRIMARY KEY,
+    pile_id VARCHAR(10) NOT NULL,
+    kind VARCHAR(40) NOT NULL,
+    backend_state JSONB NOT NULL,
+    simulator_state JSONB NOT NULL,
+    diff TEXT NOT NULL,
+    action VARCHAR(20) NOT NULL CHECK (action IN ('alerted', 'corrected', 'correction_failed')),
+    detail TEXT,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    resolved_at TIMESTAMP
+);
+
+-- 创建索引
+CREATE INDEX idx_reconciliation_alerts_pile_id ON reconciliation_alerts(pile_id);
+CREATE INDEX idx_reconciliation_alerts_created_at ON reconciliation_alerts(created_at);
+CREATE INDEX idx_reconciliation_alerts_unresolved ON reconciliation_alerts(pile_id, kind) WHERE resolved_at IS NULL;
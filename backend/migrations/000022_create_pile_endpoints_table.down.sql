@@ -0,0 +1,2 @@
+-- 删除充电桩注册表
+DROP TABLE IF EXISTS pile_endpoints;
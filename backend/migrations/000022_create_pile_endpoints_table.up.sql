@@ -0,0 +1,10 @@
+-- 充电桩注册表，记录模拟器或充电桩自注册时上报的指令下发地址
+CREATE TABLE IF NOT EXISTS pile_endpoints (
+    pile_id VARCHAR(10) PRIMARY KEY REFERENCES charging_piles(id) ON DELETE CASCADE,
+    endpoint_url VARCHAR(255) NOT NULL,
+    registered_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL
+);
+
+-- 创建索引
+CREATE INDEX idx_pile_endpoints_endpoint_url ON pile_endpoints(endpoint_url);
@@ -0,0 +1,2 @@
+-- 删除等候区优先级
+ALTER TABLE charging_requests DROP COLUMN IF EXISTS priority;
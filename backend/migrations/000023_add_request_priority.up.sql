@@ -0,0 +1,2 @@
+-- 等候区优先级，管理员调整等候区顺序时设置，值越大越先调度
+ALTER TABLE charging_requests ADD COLUMN priority INTEGER DEFAULT 0 NOT NULL;
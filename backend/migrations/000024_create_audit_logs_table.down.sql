@@ -0,0 +1,2 @@
+-- 删除审计日志表
+DROP TABLE IF EXISTS audit_logs;
@@ -0,0 +1,18 @@
+-- 审计日志表，记录管理员对请求、队列与会话的人工干预
+CREATE TABLE IF NOT EXISTS audit_logs (
+    id UUID PRIMARY KEY,
+    operator_id UUID,
+    action VARCHAR(50) NOT NULL,
+    request_id UUID,
+    pile_id VARCHAR(10),
+    reason TEXT,
+    detail JSONB,
+    success BOOLEAN NOT NULL,
+    error_message TEXT,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL
+);
+
+-- 创建索引
+CREATE INDEX idx_audit_logs_created_at ON audit_logs(created_at);
+CREATE INDEX idx_audit_logs_action ON audit_logs(action, created_at);
+CREATE INDEX idx_audit_logs_request_id ON audit_logs(request_id);
@@ -0,0 +1,2 @@
+-- 删除计划维护窗口表
+DROP TABLE IF EXISTS maintenance_windows;
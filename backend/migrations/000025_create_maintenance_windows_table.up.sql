@@ -0,0 +1,17 @@
+-- 计划维护窗口表，维护开始前停止向该充电桩分配会与窗口重叠的车辆，窗口结束后自动恢复服务
+CREATE TABLE IF NOT EXISTS maintenance_windows (
+    id UUID PRIMARY KEY,
+    pile_id VARCHAR(10) NOT NULL REFERENCES charging_piles(id) ON DELETE CASCADE,
+    start_time TIMESTAMP NOT NULL,
+    end_time TIMESTAMP NOT NULL,
+    reason TEXT,
+    status VARCHAR(20) NOT NULL DEFAULT 'scheduled' CHECK (status IN ('scheduled', 'draining', 'active', 'completed', 'cancelled')),
+    created_by UUID,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    CHECK (end_time > start_time)
+);
+
+-- 创建索引
+CREATE INDEX idx_maintenance_windows_pile_id ON maintenance_windows(pile_id, start_time);
+CREATE INDEX idx_maintenance_windows_pending ON maintenance_windows(start_time) WHERE status IN ('scheduled', 'draining', 'active');
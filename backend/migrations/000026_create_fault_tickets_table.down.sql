@@ -0,0 +1,6 @@
+-- 删除故障工单相关表
+DROP TABLE IF EXISTS fault_ticket_entries;
+DROP TABLE IF EXISTS fault_tickets;
+
+-- 删除故障记录的恢复说明
+ALTER TABLE fault_records DROP COLUMN IF EXISTS recovery_notes;
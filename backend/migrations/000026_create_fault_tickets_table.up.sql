@@ -0,0 +1,38 @@
+-- 故障记录保存维修完成时的恢复说明
+ALTER TABLE fault_records ADD COLUMN IF NOT EXISTS recovery_notes TEXT;
+
+-- 故障工单表，每条故障记录对应一张工单，跟踪指派、状态流转与SLA
+CREATE TABLE IF NOT EXISTS fault_tickets (
+    id UUID PRIMARY KEY,
+    fault_id UUID NOT NULL UNIQUE REFERENCES fault_records(id) ON DELETE CASCADE,
+    pile_id VARCHAR(10) NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'open' CHECK (status IN ('open', 'acknowledged', 'in_repair', 'resolved', 'verified')),
+    assignee_id UUID REFERENCES users(id) ON DELETE SET NULL,
+    recovery_notes TEXT,
+    ack_due_at TIMESTAMP NOT NULL,
+    resolve_due_at TIMESTAMP NOT NULL,
+    acknowledged_at TIMESTAMP,
+    repair_started_at TIMESTAMP,
+    resolved_at TIMESTAMP,
+    verified_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL
+);
+
+-- 工单记录表：备注、使用的配件、状态变更、指派与强制恢复
+CREATE TABLE IF NOT EXISTS fault_ticket_entries (
+    id UUID PRIMARY KEY,
+    ticket_id UUID NOT NULL REFERENCES fault_tickets(id) ON DELETE CASCADE,
+    entry_type VARCHAR(20) NOT NULL CHECK (entry_type IN ('note', 'part', 'status', 'assign', 'override')),
+    author_id UUID,
+    content TEXT,
+    part_name VARCHAR(100),
+    quantity INTEGER,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL
+);
+
+-- 创建索引
+CREATE INDEX idx_fault_tickets_pile_id ON fault_tickets(pile_id, status);
+CREATE INDEX idx_fault_tickets_assignee_id ON fault_tickets(assignee_id);
+CREATE INDEX idx_fault_tickets_status ON fault_tickets(status, created_at);
+CREATE INDEX idx_fault_ticket_entries_ticket_id ON fault_ticket_entries(ticket_id, created_at);
@@ -0,0 +1,8 @@
+-- 删除详单降功率时长
+ALTER TABLE billing_details DROP COLUMN IF EXISTS degraded_duration;
+
+-- 删除降功率运行时段表
+DROP TABLE IF EXISTS pile_degradations;
+
+-- 删除充电桩降功率字段
+ALTER TABLE charging_piles DROP COLUMN IF EXISTS degraded_power;
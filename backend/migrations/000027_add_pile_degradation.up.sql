@@ -0,0 +1,23 @@
+-- 充电桩降功率运行：部分功率模块故障时以降低后的功率继续服务，为NULL时按额定功率运行
+ALTER TABLE charging_piles ADD COLUMN degraded_power DECIMAL(5,2) CHECK (degraded_power > 0);
+
+-- 降功率运行时段，用于详单与可靠性报表
+CREATE TABLE IF NOT EXISTS pile_degradations (
+    id UUID PRIMARY KEY,
+    pile_id VARCHAR(10) NOT NULL REFERENCES charging_piles(id) ON DELETE CASCADE,
+    fault_type VARCHAR(50) NOT NULL CHECK (fault_type IN ('hardware', 'software', 'power')),
+    description TEXT,
+    rated_power DECIMAL(5,2) NOT NULL,
+    effective_power DECIMAL(5,2) NOT NULL CHECK (effective_power > 0),
+    started_at TIMESTAMP NOT NULL,
+    ended_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL
+);
+
+-- 创建索引
+CREATE INDEX idx_pile_degradations_pile_id ON pile_degradations(pile_id, started_at);
+-- 每个充电桩同时只有一个进行中的降功率时段
+CREATE UNIQUE INDEX idx_pile_degradations_active ON pile_degradations(pile_id) WHERE ended_at IS NULL;
+
+-- 详单记录降功率运行期间的充电时长（小时）
+ALTER TABLE billing_details ADD COLUMN degraded_duration DECIMAL(8,4) NOT NULL DEFAULT 0;
@@ -0,0 +1,45 @@
+-- 通知表，调度与计费事件按模板生成的通知，in_app 为真时出现在用户的站内信箱
+CREATE TABLE IF NOT EXISTS notifications (
+    id UUID PRIMARY KEY,
+    user_id UUID NOT NULL REFERENCES users(id),
+    event VARCHAR(30) NOT NULL,
+    title VARCHAR(200) NOT NULL,
+    content TEXT NOT NULL,
+    data JSONB,
+    in_app BOOLEAN NOT NULL DEFAULT TRUE,
+    read_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL
+);
+
+-- 用户通知渠道偏好，没有记录的渠道按默认值处理（仅开启站内信）
+CREATE TABLE IF NOT EXISTS notification_preferences (
+    user_id UUID NOT NULL REFERENCES users(id),
+    channel VARCHAR(20) NOT NULL CHECK (channel IN ('in_app', 'webhook', 'email')),
+    enabled BOOLEAN NOT NULL DEFAULT FALSE,
+    target VARCHAR(500),
+    events JSONB,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    PRIMARY KEY (user_id, channel)
+);
+
+-- 通知投递记录，外部渠道投递失败按指数退避重试
+CREATE TABLE IF NOT EXISTS notification_deliveries (
+    id UUID PRIMARY KEY,
+    notification_id UUID NOT NULL REFERENCES notifications(id) ON DELETE CASCADE,
+    user_id UUID NOT NULL REFERENCES users(id),
+    channel VARCHAR(20) NOT NULL CHECK (channel IN ('in_app', 'webhook', 'email')),
+    target VARCHAR(500),
+    status VARCHAR(20) NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'sent', 'failed')),
+    attempts INTEGER DEFAULT 0 NOT NULL,
+    next_attempt_at TIMESTAMP NOT NULL,
+    last_error TEXT,
+    sent_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL
+);
+
+-- 创建索引
+CREATE INDEX idx_notifications_user_id ON notifications(user_id, created_at);
+CREATE INDEX idx_notifications_unread ON notifications(user_id) WHERE in_app AND read_at IS NULL;
+CREATE INDEX idx_notification_deliveries_status ON notification_deliveries(status, next_attempt_at);
+CREATE INDEX idx_notification_deliveries_notification_id ON notification_deliveries(notification_id);
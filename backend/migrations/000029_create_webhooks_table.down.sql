@@ -0,0 +1,3 @@
+-- 删除webhook相关表
+DROP TABLE IF EXISTS webhook_deliveries;
+DROP TABLE IF EXISTS webhook_subscriptions;
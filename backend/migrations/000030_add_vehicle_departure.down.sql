@@ -0,0 +1,13 @@
+-- 恢复模拟器回调事件类型
+DELETE FROM simulator_events WHERE event_type = 'vehicle_departed';
+ALTER TABLE simulator_events DROP CONSTRAINT IF EXISTS simulator_events_event_type_check;
+ALTER TABLE simulator_events ADD CONSTRAINT simulator_events_event_type_check
+    CHECK (event_type IN ('charging_progress', 'charging_complete', 'fault_report', 'fault_recovery'));
+
+-- 删除详单占位费字段
+ALTER TABLE billing_details DROP COLUMN IF EXISTS idle_fee;
+ALTER TABLE billing_details DROP COLUMN IF EXISTS idle_minutes;
+
+-- 删除车辆驶离时间
+DROP INDEX IF EXISTS idx_charging_sessions_parked;
+ALTER TABLE charging_sessions DROP COLUMN IF EXISTS departed_at;
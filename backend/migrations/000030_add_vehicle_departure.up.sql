@@ -0,0 +1,17 @@
+-- 车辆驶离时间：充电结束后车辆仍停在桩位，驶离前充电桩不能开始下一次充电，为NULL时表示尚未驶离
+ALTER TABLE charging_sessions ADD COLUMN departed_at TIMESTAMP;
+
+-- 已结束的历史会话视为结束时即驶离
+UPDATE charging_sessions SET departed_at = end_time WHERE end_time IS NOT NULL;
+
+-- 按充电桩查询停留中的会话
+CREATE INDEX idx_charging_sessions_parked ON charging_sessions(pile_id) WHERE end_time IS NOT NULL AND departed_at IS NULL;
+
+-- 详单记录超时占位时长（分钟）与占位费，占位费单独列示并计入总费用
+ALTER TABLE billing_details ADD COLUMN idle_minutes INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE billing_details ADD COLUMN idle_fee DECIMAL(10,2) NOT NULL DEFAULT 0;
+
+-- 模拟器回调增加车辆驶离事件
+ALTER TABLE simulator_events DROP CONSTRAINT IF EXISTS simulator_events_event_type_check;
+ALTER TABLE simulator_events ADD CONSTRAINT simulator_events_event_type_check
+    CHECK (event_type IN ('charging_progress', 'charging_complete', 'fault_report', 'fault_recovery', 'vehicle_departed'));
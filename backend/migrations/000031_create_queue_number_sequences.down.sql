@@ -0,0 +1,5 @@
+-- 删除队列号营业日
+ALTER TABLE charging_requests DROP COLUMN IF EXISTS queue_date;
+
+-- 删除队列号序列
+DROP TABLE IF EXISTS queue_number_sequences;
@@ -0,0 +1,14 @@
+-- 队列号序列：按站点、充电模式和营业日分别计数，多个后端实例共享同一计数器
+CREATE TABLE IF NOT EXISTS queue_number_sequences (
+    station_id VARCHAR(50) NOT NULL,
+    charging_mode VARCHAR(10) NOT NULL CHECK (charging_mode IN ('fast', 'slow')),
+    business_date DATE NOT NULL,
+    last_value INTEGER DEFAULT 0 NOT NULL,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    PRIMARY KEY (station_id, charging_mode, business_date)
+);
+
+-- 队列号所属营业日，排序时先比较营业日再按序号数值比较
+ALTER TABLE charging_requests ADD COLUMN queue_date DATE;
+
+-- 历史请求的营业日由后端启动时按配置的重置时间与时区回填，与新取号使用同一规则
@@ -0,0 +1,8 @@
+-- 删除到场截止时间
+ALTER TABLE charging_requests DROP COLUMN IF EXISTS arrival_deadline;
+
+-- 恢复充电请求状态，候补中的请求视为已取消
+UPDATE charging_requests SET status = 'cancelled' WHERE status = 'waitlisted';
+ALTER TABLE charging_requests DROP CONSTRAINT IF EXISTS charging_requests_status_check;
+ALTER TABLE charging_requests ADD CONSTRAINT charging_requests_status_check
+    CHECK (status IN ('waiting', 'queued', 'charging', 'completed', 'cancelled'));
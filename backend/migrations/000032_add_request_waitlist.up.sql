@@ -0,0 +1,7 @@
+-- 等候区已满时请求进入候补队列（waitlisted），按创建时间排序
+ALTER TABLE charging_requests DROP CONSTRAINT IF EXISTS charging_requests_status_check;
+ALTER TABLE charging_requests ADD CONSTRAINT charging_requests_status_check
+    CHECK (status IN ('waitlisted', 'waiting', 'queued', 'charging', 'completed', 'cancelled'));
+
+-- 候补请求获得入场资格后须在此之前到场签到，期间为其保留等候区位置，为NULL时表示尚未获得入场资格
+ALTER TABLE charging_requests ADD COLUMN arrival_deadline TIMESTAMP;
@@ -0,0 +1,2 @@
+-- 删除OCPP充电桩连接凭据
+DROP TABLE IF EXISTS ocpp_credentials;
@@ -0,0 +1,6 @@
+-- OCPP充电桩连接凭据（OCPP 1.6 安全配置1：HTTP基本认证），用户名为充电桩ID，只保存密码哈希
+CREATE TABLE IF NOT EXISTS ocpp_credentials (
+    pile_id VARCHAR(10) PRIMARY KEY REFERENCES charging_piles(id) ON DELETE CASCADE,
+    password_hash VARCHAR(100) NOT NULL,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL
+);
@@ -0,0 +1,2 @@
+-- 删除车辆配置名称
+ALTER TABLE vehicles DROP COLUMN IF EXISTS vehicle_profile;
@@ -0,0 +1,2 @@
+-- 车辆配置名称：充电时随指令下发，模拟器按对应的充电曲线充电，为空时使用模拟器默认配置
+ALTER TABLE vehicles ADD COLUMN vehicle_profile VARCHAR(64) NOT NULL DEFAULT '';
@@ -14,9 +14,33 @@ export class ApiError extends Error {
   }
 }
 
+// 使用刷新令牌换取新的访问令牌
+async function refreshAccessToken(): Promise<boolean> {
+  const refreshToken = browser ? localStorage.getItem('refreshToken') : null;
+  if (!refreshToken) {
+    return false;
+  }
+
+  const response = await fetch(`${API_BASE_URL}/auth/refresh`, {
+    method: 'POST',
+    headers: { 'Content-Type': 'application/json' },
+    body: JSON.stringify({ refreshToken }),
+  });
+  if (!response.ok) {
+    localStorage.removeItem('refreshToken');
+    return false;
+  }
+
+  const data = await response.json();
+  localStorage.setItem('token', data.data.token);
+  localStorage.setItem('refreshToken', data.data.refreshToken);
+  return true;
+}
+
 export async function fetchApi<T>(
   endpoint: string,
-  options: RequestInit = {}
+  options: RequestInit = {},
+  retried = false
 ): Promise<T> {
   const token = browser ? localStorage.getItem('token') : null;
   const headers = {
@@ -30,11 +54,17 @@ export async function fetchApi<T>(
     headers,
   });
 
+  // 访问令牌过期时尝试刷新一次
+  if (response.status === 401 && token && !retried && await refreshAccessToken()) {
+    return fetchApi<T>(endpoint, options, true);
+  }
+
   const data: ApiResponse<T> = await response.json();
 
   if (data.code !== 200) {
     if (data.code === 401) {
       localStorage.removeItem('token');
+      localStorage.removeItem('refreshToken');
       localStorage.removeItem('user');
       goto('/login');
     }
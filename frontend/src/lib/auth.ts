@@ -11,6 +11,7 @@ export const login = async (username: string, password: string) => {
     // 临时将token保存到localStorage，以便fetchApi能够获取到token
     if (browser) {
       localStorage.setItem('token', response.token);
+      localStorage.setItem('refreshToken', response.refreshToken);
     }
     
     const userInfo = await api.users.getInfo(response.userId);
@@ -43,6 +43,7 @@ function createAuthStore() {
     logout: () => {
       if (browser) {
         localStorage.removeItem('token');
+        localStorage.removeItem('refreshToken');
         localStorage.removeItem('user');
         localStorage.removeItem('userType');
       }
@@ -11,9 +11,12 @@ export interface User {
 
 export interface AuthResponse {
   token: string;
+  refreshToken: string;
+  sessionId: string;
   userId: string;
   userType: 'user' | 'admin';
   expiresIn: number;
+  refreshExpiresIn: number;
 }
 
 // 充电请求相关类型
@@ -1,3 +1,5 @@
 module simulator
 
 go 1.24.3
+
+require github.com/gorilla/websocket v1.5.3
@@ -2,6 +2,7 @@ package config
 
 import (
 	"encoding/json"
+	"fmt"
 	"os"
 )
 
@@ -10,31 +11,81 @@ type Config struct {
 	// 充电桩配置
 	Piles struct {
 		Fast struct {
-			Count int     `json:"count"` // 快充数量
-			Power float64 `json:"power"` // 快充功率(kW)
+			Count  int     `json:"count"`  // 快充数量
+			Power  float64 `json:"power"`  // 快充功率(kW)
+			Prefix string  `json:"prefix"` // ID前缀，默认F
 		} `json:"fast"`
 		Trickle struct {
-			Count int     `json:"count"` // 慢充数量
-			Power float64 `json:"power"` // 慢充功率(kW)
+			Count  int     `json:"count"`  // 慢充数量
+			Power  float64 `json:"power"`  // 慢充功率(kW)
+			Prefix string  `json:"prefix"` // ID前缀，默认T
 		} `json:"trickle"`
 	} `json:"piles"`
 
+	// 指令接口配置，仅HTTP模式使用
+	Server struct {
+		Port        int    `json:"port"`        // 指令接口端口，默认8090
+		CallbackURL string `json:"callbackURL"` // 注册到后端的指令下发地址，默认 http://localhost:{port}
+	} `json:"server"`
+
 	// 故障模拟配置
 	Fault struct {
 		RandomFault  bool    `json:"randomFault"`  // 是否启用随机故障
 		FaultChance  float64 `json:"faultChance"`  // 故障概率 (0-1)
 		MaxFaultTime int     `json:"maxFaultTime"` // 最大故障持续时间(分钟)
 		MinFaultTime int     `json:"minFaultTime"` // 最小故障持续时间(分钟)
+
+		DegradeChance      float64 `json:"degradeChance"`      // 随机故障中降功率运行的比例 (0-1)
+		DegradedPowerRatio float64 `json:"degradedPowerRatio"` // 降功率运行时的功率比例 (0-1)，默认0.5
 	} `json:"fault"`
 
+	// 车辆驶离模拟配置，充电结束后车辆停留一段时间再驶离
+	Departure struct {
+		Manual   bool `json:"manual"`   // 为true时车辆不自动驶离，需通过depart命令驶离
+		MinDelay int  `json:"minDelay"` // 充电结束后最短停留时间(秒)
+		MaxDelay int  `json:"maxDelay"` // 充电结束后最长停留时间(秒)
+	} `json:"departure"`
+
 	// 后端API设置
 	BackendAPI struct {
 		BaseURL           string `json:"baseURL"`           // API基础URL
 		StatusInterval    int    `json:"statusInterval"`    // 状态上报间隔(秒)
 		ProgressInterval  int    `json:"progressInterval"`  // 进度上报间隔(秒)
 		HeartbeatInterval int    `json:"heartbeatInterval"` // 心跳间隔(秒)
+		Protocol          string `json:"protocol"`          // 与后端通信的协议: http|ocpp，默认http
+
+		RegistrationSecret string `json:"registrationSecret"` // 充电桩自注册的共享密钥，与后端 dispatch.registrationSecret 一致
 	} `json:"backendAPI"`
 
+	// OCPP充电桩模式配置，backendAPI.protocol为ocpp时生效
+	OCPP struct {
+		CentralSystemURL  string `json:"centralSystemURL"`  // 中央系统地址，连接时追加 /{充电桩ID}
+		ChargePointVendor string `json:"chargePointVendor"` // 充电桩厂商
+		ChargePointModel  string `json:"chargePointModel"`  // 充电桩型号
+		ReconnectInterval int    `json:"reconnectInterval"` // 断线重连间隔(秒)
+		CallTimeout       int    `json:"callTimeout"`       // 等待中央系统响应超时(秒)
+
+		Password  string            `json:"password"`  // 连接中央系统的密码（HTTP基本认证，用户名为充电桩ID）
+		Passwords map[string]string `json:"passwords"` // 按充电桩ID单独配置的密码，优先于password
+	} `json:"ocpp"`
+
+	// 上报发件箱配置，仅HTTP模式使用
+	Outbox struct {
+		Path           string `json:"path"`           // 发件箱文件路径
+		InitialBackoff int    `json:"initialBackoff"` // 首次重试间隔(秒)
+		MaxBackoff     int    `json:"maxBackoff"`     // 最大重试间隔(秒)
+		MaxAttempts    int    `json:"maxAttempts"`    // 最大投递次数，超过后标记为失败，0表示不限
+	} `json:"outbox"`
+
+	// 网络混沌注入配置，仅作用于HTTP模式的APIClient与ServerAPI
+	Chaos ChaosConfig `json:"chaos"`
+
+	// 车辆充电曲线配置
+	VehicleProfiles struct {
+		Default  string           `json:"default"`  // 默认车辆配置名称
+		Profiles []VehicleProfile `json:"profiles"` // 车辆配置列表
+	} `json:"vehicleProfiles"`
+
 	// 模拟设置
 	Simulation struct {
 		SpeedFactor float64 `json:"speedFactor"` // 模拟加速比例
@@ -42,6 +93,113 @@ type Config struct {
 	} `json:"simulation"`
 }
 
+// ChaosConfig 网络混沌注入参数，比例均为百分比(0-100)
+type ChaosConfig struct {
+	Latency       int     `json:"latency"`       // 固定延迟(毫秒)
+	Jitter        int     `json:"jitter"`        // 随机抖动上限(毫秒)
+	DropRate      float64 `json:"dropRate"`      // 丢弃请求比例
+	DuplicateRate float64 `json:"duplicateRate"` // 重复发送进度/完成上报的比例
+	ReorderRate   float64 `json:"reorderRate"`   // 延后发送进度/完成上报的比例，造成乱序
+	ReorderDelay  int     `json:"reorderDelay"`  // 乱序上报的延后时间(毫秒)
+	Scenario      string  `json:"scenario"`      // 启动时执行的场景文件，为空不执行
+	LogFile       string  `json:"logFile"`       // 注入记录文件，为空只记录在内存
+}
+
+// VehicleProfile 车辆充电配置
+type VehicleProfile struct {
+	Name            string  `json:"name"`            // 配置名称
+	BatteryCapacity float64 `json:"batteryCapacity"` // 电池容量(kWh)，后端未提供时使用
+
+	Curve struct {
+		Type              string  `json:"type"`              // 曲线类型: constant|cccv
+		MaxAcceptanceRate float64 `json:"maxAcceptanceRate"` // 车辆最大接受功率(kW)，0表示不限制
+		TaperStartSoC     float64 `json:"taperStartSoc"`     // 开始降功率的SoC(百分比)
+		MinPowerRatio     float64 `json:"minPowerRatio"`     // SoC为100%时的功率比例(0-1)
+	} `json:"curve"`
+
+	Temperature struct {
+		Enabled         bool    `json:"enabled"`         // 是否启用温度影响
+		Ambient         float64 `json:"ambient"`         // 环境温度(℃)
+		OptimalMin      float64 `json:"optimalMin"`      // 最佳温度下限(℃)
+		OptimalMax      float64 `json:"optimalMax"`      // 最佳温度上限(℃)
+		DeratePerDegree float64 `json:"deratePerDegree"` // 每偏离1℃降低的功率比例
+		MinFactor       float64 `json:"minFactor"`       // 最低功率系数
+	} `json:"temperature"`
+}
+
+// 通信协议
+const (
+	ProtocolHTTP = "http"
+	ProtocolOCPP = "ocpp"
+)
+
+// UseOCPP 是否以OCPP充电桩模式连接后端
+func (c *Config) UseOCPP() bool {
+	return c.BackendAPI.Protocol == ProtocolOCPP
+}
+
+// OCPPPassword 充电桩连接中央系统的密码
+func (c *Config) OCPPPassword(pileID string) string {
+	if password, ok := c.OCPP.Passwords[pileID]; ok {
+		return password
+	}
+	return c.OCPP.Password
+}
+
+// ServerPort 指令接口端口
+func (c *Config) ServerPort() int {
+	if c.Server.Port <= 0 {
+		return 8090
+	}
+	return c.Server.Port
+}
+
+// CallbackURL 后端下发指令使用的地址
+func (c *Config) CallbackURL() string {
+	if c.Server.CallbackURL != "" {
+		return c.Server.CallbackURL
+	}
+	return fmt.Sprintf("http://localhost:%d", c.ServerPort())
+}
+
+// FastPilePrefix 快充桩ID前缀
+func (c *Config) FastPilePrefix() string {
+	if c.Piles.Fast.Prefix == "" {
+		return "F"
+	}
+	return c.Piles.Fast.Prefix
+}
+
+// TricklePilePrefix 慢充桩ID前缀
+func (c *Config) TricklePilePrefix() string {
+	if c.Piles.Trickle.Prefix == "" {
+		return "T"
+	}
+	return c.Piles.Trickle.Prefix
+}
+
+// DegradedPower 降功率运行时的功率(kW)
+func (c *Config) DegradedPower(ratedPower float64) float64 {
+	ratio := c.Fault.DegradedPowerRatio
+	if ratio <= 0 || ratio >= 1 {
+		ratio = 0.5
+	}
+	return ratedPower * ratio
+}
+
+// FindVehicleProfile 按名称查找车辆配置，名称为空时使用默认配置
+func (c *Config) FindVehicleProfile(name string) *VehicleProfile {
+	if name == "" {
+		name = c.VehicleProfiles.Default
+	}
+	for i := range c.VehicleProfiles.Profiles {
+		if c.VehicleProfiles.Profiles[i].Name == name {
+			return &c.VehicleProfiles.Profiles[i]
+		}
+	}
+	return nil
+}
+
 // LoadConfig 从指定路径加载配置
 func LoadConfig(path string) (*Config, error) {
 	file, err := os.Open(path)
@@ -55,10 +213,26 @@ func LoadConfig(path string) (*Config, error) {
 	if err := decoder.Decode(config); err != nil {
 		return nil, err
 	}
+	if err := config.validateVehicleProfiles(); err != nil {
+		return nil, err
+	}
 
 	return config, nil
 }
 
+// validateVehicleProfiles 校验车辆配置，功率比例为0时充电功率降到0，会话永远无法结束
+func (c *Config) validateVehicleProfiles() error {
+	for _, profile := range c.VehicleProfiles.Profiles {
+		if profile.Curve.Type == "cccv" && (profile.Curve.MinPowerRatio <= 0 || profile.Curve.MinPowerRatio > 1) {
+			return fmt.Errorf("车辆配置 %s 的 minPowerRatio 须在(0, 1]范围内", profile.Name)
+		}
+		if profile.Temperature.Enabled && (profile.Temperature.MinFactor <= 0 || profile.Temperature.MinFactor > 1) {
+			return fmt.Errorf("车辆配置 %s 的 temperature.minFactor 须在(0, 1]范围内", profile.Name)
+		}
+	}
+	return nil
+}
+
 // SaveConfig 保存配置到指定路径
 func SaveConfig(config *Config, path string) error {
 	file, err := os.Create(path)
@@ -0,0 +1,41 @@
+package config
+
+import "testing"
+
+func TestValidateVehicleProfiles(t *testing.T) {
+	tests := []struct {
+		name      string
+		curveType string
+		minRatio  float64
+		tempOn    bool
+		minFactor float64
+		wantErr   bool
+	}{
+		{name: "恒功率曲线无需功率比例", curveType: "constant"},
+		{name: "CCCV曲线有效比例", curveType: "cccv", minRatio: 0.2},
+		{name: "CCCV曲线比例为1", curveType: "cccv", minRatio: 1},
+		{name: "CCCV曲线比例为0", curveType: "cccv", minRatio: 0, wantErr: true},
+		{name: "CCCV曲线比例为负数", curveType: "cccv", minRatio: -0.1, wantErr: true},
+		{name: "CCCV曲线比例超过1", curveType: "cccv", minRatio: 1.5, wantErr: true},
+		{name: "温度影响有效系数", curveType: "constant", tempOn: true, minFactor: 0.3},
+		{name: "温度影响系数为0", curveType: "constant", tempOn: true, minFactor: 0, wantErr: true},
+		{name: "未启用温度影响时不校验", curveType: "constant", tempOn: false, minFactor: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			profile := VehicleProfile{Name: "test"}
+			profile.Curve.Type = tt.curveType
+			profile.Curve.MinPowerRatio = tt.minRatio
+			profile.Temperature.Enabled = tt.tempOn
+			profile.Temperature.MinFactor = tt.minFactor
+
+			cfg := &Config{}
+			cfg.VehicleProfiles.Profiles = []VehicleProfile{profile}
+			err := cfg.validateVehicleProfiles()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("validateVehicleProfiles() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,78 @@
+package models
+
+import "math"
+
+// ChargingCurve 充电曲线，根据电量状态计算车辆实际接受的充电功率
+type ChargingCurve interface {
+	// Power 返回给定SoC(百分比)和充电桩额定功率(kW)下的瞬时充电功率(kW)
+	Power(soc, pilePower float64) float64
+}
+
+// ConstantCurve 恒功率曲线，仅受车辆最大接受功率限制
+type ConstantCurve struct {
+	MaxAcceptanceRate float64 // 车辆最大接受功率(kW)，0表示不限制
+}
+
+// Power 计算瞬时功率
+func (c *ConstantCurve) Power(soc, pilePower float64) float64 {
+	return limitPower(pilePower, c.MaxAcceptanceRate)
+}
+
+// CCCVCurve 恒流恒压曲线，SoC超过拐点后功率线性衰减
+type CCCVCurve struct {
+	MaxAcceptanceRate float64 // 车辆最大接受功率(kW)，0表示不限制
+	TaperStartSoC     float64 // 开始降功率的SoC(百分比)
+	MinPowerRatio     float64 // SoC为100%时的功率比例(0-1)
+}
+
+// Power 计算瞬时功率
+func (c *CCCVCurve) Power(soc, pilePower float64) float64 {
+	power := limitPower(pilePower, c.MaxAcceptanceRate)
+	if soc <= c.TaperStartSoC || c.TaperStartSoC >= 100 {
+		return power
+	}
+
+	progress := math.Min((soc-c.TaperStartSoC)/(100-c.TaperStartSoC), 1)
+	ratio := 1 - (1-c.MinPowerRatio)*progress
+	return power * ratio
+}
+
+// TemperatureCurve 温度影响，环境温度偏离最佳区间时按比例降功率
+type TemperatureCurve struct {
+	Curve           ChargingCurve // 被修正的基础曲线
+	Ambient         float64       // 环境温度(℃)
+	OptimalMin      float64       // 最佳温度下限(℃)
+	OptimalMax      float64       // 最佳温度上限(℃)
+	DeratePerDegree float64       // 每偏离1℃降低的功率比例
+	MinFactor       float64       // 最低功率系数
+}
+
+// Power 计算瞬时功率
+func (c *TemperatureCurve) Power(soc, pilePower float64) float64 {
+	return c.Curve.Power(soc, pilePower) * c.Factor()
+}
+
+// Factor 当前温度下的功率系数
+func (c *TemperatureCurve) Factor() float64 {
+	var deviation float64
+	switch {
+	case c.Ambient < c.OptimalMin:
+		deviation = c.OptimalMin - c.Ambient
+	case c.Ambient > c.OptimalMax:
+		deviation = c.Ambient - c.OptimalMax
+	}
+
+	factor := 1 - deviation*c.DeratePerDegree
+	if factor < c.MinFactor {
+		factor = c.MinFactor
+	}
+	return factor
+}
+
+// limitPower 取充电桩功率与车辆最大接受功率中的较小值
+func limitPower(pilePower, maxAcceptanceRate float64) float64 {
+	if maxAcceptanceRate > 0 && maxAcceptanceRate < pilePower {
+		return maxAcceptanceRate
+	}
+	return pilePower
+}
@@ -0,0 +1,95 @@
+package models
+
+import (
+	"math"
+	"testing"
+)
+
+func TestConstantCurvePower(t *testing.T) {
+	tests := []struct {
+		name              string
+		maxAcceptanceRate float64
+		soc               float64
+		pilePower         float64
+		want              float64
+	}{
+		{name: "不限制车辆功率", maxAcceptanceRate: 0, soc: 50, pilePower: 30, want: 30},
+		{name: "车辆功率低于充电桩", maxAcceptanceRate: 22, soc: 50, pilePower: 30, want: 22},
+		{name: "车辆功率高于充电桩", maxAcceptanceRate: 100, soc: 95, pilePower: 30, want: 30},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			curve := &ConstantCurve{MaxAcceptanceRate: tt.maxAcceptanceRate}
+			if got := curve.Power(tt.soc, tt.pilePower); got != tt.want {
+				t.Errorf("Power(%v, %v) = %v, want %v", tt.soc, tt.pilePower, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCCCVCurvePower(t *testing.T) {
+	curve := &CCCVCurve{MaxAcceptanceRate: 50, TaperStartSoC: 80, MinPowerRatio: 0.2}
+
+	tests := []struct {
+		name      string
+		soc       float64
+		pilePower float64
+		want      float64
+	}{
+		{name: "拐点前恒功率", soc: 50, pilePower: 30, want: 30},
+		{name: "拐点处恒功率", soc: 80, pilePower: 30, want: 30},
+		{name: "拐点前受车辆功率限制", soc: 50, pilePower: 120, want: 50},
+		{name: "衰减一半", soc: 90, pilePower: 30, want: 30 * 0.6},
+		{name: "SoC为100%时降到最低比例", soc: 100, pilePower: 30, want: 30 * 0.2},
+		{name: "超过100%不再继续衰减", soc: 105, pilePower: 30, want: 30 * 0.2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := curve.Power(tt.soc, tt.pilePower); math.Abs(got-tt.want) > 1e-9 {
+				t.Errorf("Power(%v, %v) = %v, want %v", tt.soc, tt.pilePower, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCCCVCurveWithoutTaper(t *testing.T) {
+	curve := &CCCVCurve{TaperStartSoC: 100, MinPowerRatio: 0.2}
+	if got := curve.Power(99, 30); got != 30 {
+		t.Errorf("拐点为100%%时不应降功率: Power(99, 30) = %v", got)
+	}
+}
+
+func TestTemperatureCurveFactor(t *testing.T) {
+	tests := []struct {
+		name    string
+		ambient float64
+		want    float64
+	}{
+		{name: "最佳区间内", ambient: 25, want: 1},
+		{name: "区间边界", ambient: 15, want: 1},
+		{name: "低于下限", ambient: 5, want: 0.8},
+		{name: "高于上限", ambient: 40, want: 0.9},
+		{name: "不低于最低系数", ambient: -30, want: 0.4},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			curve := &TemperatureCurve{
+				Curve:           &ConstantCurve{},
+				Ambient:         tt.ambient,
+				OptimalMin:      15,
+				OptimalMax:      35,
+				DeratePerDegree: 0.02,
+				MinFactor:       0.4,
+			}
+			if got := curve.Factor(); math.Abs(got-tt.want) > 1e-9 {
+				t.Errorf("Factor() = %v, want %v", got, tt.want)
+			}
+			if got := curve.Power(50, 30); math.Abs(got-30*tt.want) > 1e-9 {
+				t.Errorf("Power(50, 30) = %v, want %v", got, 30*tt.want)
+			}
+		})
+	}
+}
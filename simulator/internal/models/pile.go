@@ -1,6 +1,7 @@
 package models
 
 import (
+	"math"
 	"sync"
 	"time"
 )
@@ -41,7 +42,10 @@ type Pile struct {
 	Power        float64    `json:"power"`  // 充电功率(kW)
 	CurrentFault *Fault     `json:"fault"`  // 当前故障信息
 
-	CurrentVehicle *ChargingVehicle `json:"currentVehicle"` // 当前正在充电的车辆
+	DegradedPower float64 `json:"degradedPower,omitempty"` // 降功率运行时的可提供功率(kW)，0表示按额定功率运行
+
+	CurrentVehicle *ChargingVehicle `json:"currentVehicle"`          // 当前正在充电的车辆
+	ParkedVehicle  *ChargingVehicle `json:"parkedVehicle,omitempty"` // 充电已结束、尚未驶离的车辆
 
 	// 统计数据
 	TotalChargingSessions int     `json:"totalChargingSessions"` // 总充电次数
@@ -53,11 +57,68 @@ type Pile struct {
 
 // ChargingVehicle 充电中的车辆
 type ChargingVehicle struct {
-	UserID            string    `json:"userId"`            // 用户ID
-	StartTime         time.Time `json:"startTime"`         // 开始充电时间
-	RequestedCapacity float64   `json:"requestedCapacity"` // 请求充电量(kWh)
-	CurrentCapacity   float64   `json:"currentCapacity"`   // 当前已充电量(kWh)
-	ChargingMode      string    `json:"chargingMode"`      // 充电模式
+	SessionID         string    `json:"sessionId,omitempty"` // 后端充电会话ID，上报时携带
+	UserID            string    `json:"userId"`              // 用户ID
+	StartTime         time.Time `json:"startTime"`           // 开始充电时间
+	RequestedCapacity float64   `json:"requestedCapacity"`   // 请求充电量(kWh)
+	CurrentCapacity   float64   `json:"currentCapacity"`     // 当前已充电量(kWh)
+	ChargingMode      string    `json:"chargingMode"`        // 充电模式
+
+	// 电池状态，BatteryCapacity为0时表示后端未提供SoC信息
+	BatteryCapacity float64 `json:"batteryCapacity,omitempty"` // 电池容量(kWh)
+	StartSoC        float64 `json:"startSoc,omitempty"`        // 起始电量百分比
+	CurrentSoC      float64 `json:"currentSoc,omitempty"`      // 当前电量百分比
+	TargetSoC       float64 `json:"targetSoc,omitempty"`       // 目标电量百分比
+
+	// 充电曲线
+	Profile      string        `json:"profile,omitempty"` // 车辆配置名称
+	CurrentPower float64       `json:"currentPower"`      // 瞬时充电功率(kW)
+	Curve        ChargingCurve `json:"-"`                 // 充电曲线，为nil时按充电桩额定功率恒功率充电
+}
+
+// curveStep 按充电曲线积分的步长
+const curveStep = 30 * time.Second
+
+// BatteryState 分配充电时携带的电池状态
+type BatteryState struct {
+	Capacity  float64 // 电池容量(kWh)
+	StartSoC  float64 // 起始电量百分比
+	TargetSoC float64 // 目标电量百分比
+	Profile   string  // 车辆配置名称，为空时使用默认配置
+}
+
+// HasSoC 是否跟踪SoC
+func (v *ChargingVehicle) HasSoC() bool {
+	return v.BatteryCapacity > 0
+}
+
+// updateSoC 根据已充电量更新当前SoC
+func (v *ChargingVehicle) updateSoC() {
+	if !v.HasSoC() {
+		return
+	}
+	v.CurrentSoC = v.socAt(v.CurrentCapacity)
+}
+
+// socAt 计算已充电量对应的SoC
+func (v *ChargingVehicle) socAt(capacity float64) float64 {
+	if !v.HasSoC() {
+		return 0
+	}
+	return math.Min(v.StartSoC+capacity/v.BatteryCapacity*100, 100)
+}
+
+// RefreshPower 按当前充电量刷新瞬时功率
+func (v *ChargingVehicle) RefreshPower(pilePower float64) {
+	v.CurrentPower = v.powerAt(v.CurrentCapacity, pilePower)
+}
+
+// powerAt 计算已充电量对应的瞬时功率(kW)
+func (v *ChargingVehicle) powerAt(capacity, pilePower float64) float64 {
+	if v.Curve == nil {
+		return pilePower
+	}
+	return v.Curve.Power(v.socAt(capacity), pilePower)
 }
 
 // Fault 故障信息
@@ -109,14 +170,36 @@ func (p *Pile) StopCharging() *ChargingVehicle {
 	p.TotalChargingTime += int64(chargingTime)
 	p.TotalChargingAmount += vehicle.CurrentCapacity
 
-	// 清空当前充电记录
+	// 清空当前充电记录，车辆驶离前仍停在桩位
 	completedVehicle := p.CurrentVehicle
 	p.CurrentVehicle = nil
+	p.ParkedVehicle = completedVehicle
 	p.Status = PileStatusAvailable
 
 	return completedVehicle
 }
 
+// Depart 停在桩位的车辆驶离，vehicle不为nil时只有停留的正是该车辆才驶离，没有车辆驶离时返回nil
+func (p *Pile) Depart(vehicle *ChargingVehicle) *ChargingVehicle {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	parked := p.ParkedVehicle
+	if parked == nil || (vehicle != nil && parked != vehicle) {
+		return nil
+	}
+	p.ParkedVehicle = nil
+	return parked
+}
+
+// IsParked 是否有充电已结束、尚未驶离的车辆
+func (p *Pile) IsParked() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.ParkedVehicle != nil
+}
+
 // UpdateChargingProgress 更新充电进度
 func (p *Pile) UpdateChargingProgress(elapsed time.Duration) float64 {
 	p.mu.Lock()
@@ -126,15 +209,23 @@ func (p *Pile) UpdateChargingProgress(elapsed time.Duration) float64 {
 		return 0
 	}
 
-	// 计算本次更新充电量
-	hoursFraction := elapsed.Hours()
-	additionalCharge := p.Power * hoursFraction // kW * h = kWh
+	// 按充电曲线分步累计充电量，功率随SoC变化
+	vehicle := p.CurrentVehicle
+	for remaining := elapsed; remaining > 0 && vehicle.CurrentCapacity < vehicle.RequestedCapacity; remaining -= curveStep {
+		step := min(remaining, curveStep)
+		power := vehicle.powerAt(vehicle.CurrentCapacity, p.effectivePower())
+		if power <= 0 {
+			break
+		}
+		vehicle.CurrentCapacity += power * step.Hours() // kW * h = kWh
+	}
 
 	// 更新充电量，不超过请求量
-	p.CurrentVehicle.CurrentCapacity += additionalCharge
-	if p.CurrentVehicle.CurrentCapacity > p.CurrentVehicle.RequestedCapacity {
-		p.CurrentVehicle.CurrentCapacity = p.CurrentVehicle.RequestedCapacity
+	if vehicle.CurrentCapacity > vehicle.RequestedCapacity {
+		vehicle.CurrentCapacity = vehicle.RequestedCapacity
 	}
+	vehicle.updateSoC()
+	vehicle.RefreshPower(p.effectivePower())
 
 	return p.CurrentVehicle.CurrentCapacity
 }
@@ -148,14 +239,34 @@ func (p *Pile) RemainingTime() int {
 		return 0
 	}
 
-	remainingCapacity := p.CurrentVehicle.RequestedCapacity - p.CurrentVehicle.CurrentCapacity
+	vehicle := p.CurrentVehicle
+	remainingCapacity := vehicle.RequestedCapacity - vehicle.CurrentCapacity
 	if remainingCapacity <= 0 {
 		return 0
 	}
 
-	// 剩余时间 = 剩余电量 / 充电功率 (小时) * 3600 (转换为秒)
-	remainingTimeSeconds := int((remainingCapacity / p.Power) * 3600)
-	return remainingTimeSeconds
+	// 无充电曲线时：剩余时间 = 剩余电量 / 充电功率 (小时) * 3600 (转换为秒)
+	if vehicle.Curve == nil {
+		return int((remainingCapacity / p.effectivePower()) * 3600)
+	}
+
+	// 按充电曲线向前推演剩余时间
+	capacity := vehicle.CurrentCapacity
+	var seconds float64
+	for capacity < vehicle.RequestedCapacity {
+		power := vehicle.powerAt(capacity, p.effectivePower())
+		if power <= 0 {
+			break
+		}
+		energy := power * curveStep.Hours()
+		if capacity+energy >= vehicle.RequestedCapacity {
+			seconds += (vehicle.RequestedCapacity - capacity) / power * 3600
+			break
+		}
+		capacity += energy
+		seconds += curveStep.Seconds()
+	}
+	return int(seconds)
 }
 
 // ReportFault 报告故障
@@ -165,6 +276,8 @@ func (p *Pile) ReportFault(faultType FaultType, description string, duration tim
 
 	now := time.Now().UTC()
 	p.Status = PileStatusFault
+	p.DegradedPower = 0
+	p.ParkedVehicle = nil // 后端按故障时刻结算停留车辆
 	p.CurrentFault = &Fault{
 		Type:        faultType,
 		Description: description,
@@ -173,17 +286,83 @@ func (p *Pile) ReportFault(faultType FaultType, description string, duration tim
 	}
 }
 
-// RecoverFromFault 从故障恢复
+// Degrade 降功率运行，充电桩保持当前状态并以降低后的功率继续充电
+func (p *Pile) Degrade(faultType FaultType, description string, power float64, duration time.Duration) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	now := time.Now().UTC()
+	p.DegradedPower = power
+	p.CurrentFault = &Fault{
+		Type:        faultType,
+		Description: description,
+		StartTime:   now,
+		EndTime:     now.Add(duration),
+	}
+	if p.CurrentVehicle != nil {
+		p.CurrentVehicle.RefreshPower(p.effectivePower())
+	}
+}
+
+// RecoverFromFault 从故障或降功率运行恢复
 func (p *Pile) RecoverFromFault() {
 	p.mu.Lock()
 	defer p.mu.Unlock()
 
-	if p.Status != PileStatusFault {
+	if p.Status != PileStatusFault && p.DegradedPower == 0 {
 		return
 	}
 
-	p.Status = PileStatusAvailable
+	if p.Status == PileStatusFault {
+		p.Status = PileStatusAvailable
+	}
+	p.DegradedPower = 0
 	p.CurrentFault = nil
+	if p.CurrentVehicle != nil {
+		p.CurrentVehicle.RefreshPower(p.Power)
+	}
+}
+
+// IsDegraded 是否降功率运行
+func (p *Pile) IsDegraded() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.DegradedPower > 0
+}
+
+// EffectivePower 当前可提供的充电功率(kW)
+func (p *Pile) EffectivePower() float64 {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.effectivePower()
+}
+
+// effectivePower 降功率运行时返回降低后的功率，否则返回额定功率，调用方需持有锁
+func (p *Pile) effectivePower() float64 {
+	if p.DegradedPower > 0 {
+		return p.DegradedPower
+	}
+	return p.Power
+}
+
+// SetAvailable 切换空闲与维护状态，充电中或故障时返回false
+func (p *Pile) SetAvailable(available bool) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	switch p.Status {
+	case PileStatusAvailable, PileStatusMaintenance:
+		if available {
+			p.Status = PileStatusAvailable
+		} else {
+			p.Status = PileStatusMaintenance
+		}
+		return true
+	default:
+		return false
+	}
 }
 
 // GetStatus 获取充电桩状态信息
@@ -194,13 +373,8 @@ func (p *Pile) GetStatus() (PileStatus, *ChargingVehicle) {
 	var vehicle *ChargingVehicle
 	if p.CurrentVehicle != nil {
 		// 创建副本，避免外部修改
-		vehicle = &ChargingVehicle{
-			UserID:            p.CurrentVehicle.UserID,
-			StartTime:         p.CurrentVehicle.StartTime,
-			RequestedCapacity: p.CurrentVehicle.RequestedCapacity,
-			CurrentCapacity:   p.CurrentVehicle.CurrentCapacity,
-			ChargingMode:      p.CurrentVehicle.ChargingMode,
-		}
+		copied := *p.CurrentVehicle
+		vehicle = &copied
 	}
 	return p.Status, vehicle
 }
@@ -0,0 +1,153 @@
+package ocpp
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// CallHandler 处理中央系统发起的Call
+type CallHandler func(action string, payload json.RawMessage) (any, *CallError)
+
+// Client OCPP-J 客户端，封装单条WebSocket连接上的Call/CallResult收发
+type Client struct {
+	conn        *Conn
+	handler     CallHandler
+	callTimeout time.Duration
+
+	pending map[string]chan *Message
+	mu      sync.Mutex
+	callMu  sync.Mutex // OCPP要求同一时刻只有一个未完成的Call
+}
+
+// NewClient 在已建立的连接上创建OCPP客户端
+func NewClient(conn *Conn, handler CallHandler, callTimeout time.Duration) *Client {
+	return &Client{
+		conn:        conn,
+		handler:     handler,
+		callTimeout: callTimeout,
+		pending:     make(map[string]chan *Message),
+	}
+}
+
+// Run 读取消息直到连接关闭，返回关闭原因
+func (c *Client) Run() error {
+	defer c.failPending()
+
+	for {
+		data, err := c.conn.ReadMessage()
+		if err != nil {
+			return err
+		}
+
+		msg, err := ParseMessage(data)
+		if err != nil {
+			continue
+		}
+
+		switch msg.Type {
+		case MessageTypeCall:
+			// 处理远程启动等指令时需要再发起Call，不能阻塞读循环
+			go c.handleCall(msg)
+		case MessageTypeCallResult, MessageTypeCallError:
+			c.mu.Lock()
+			ch, ok := c.pending[msg.UniqueID]
+			c.mu.Unlock()
+			if ok {
+				select {
+				case ch <- msg:
+				default:
+				}
+			}
+		}
+	}
+}
+
+// handleCall 处理中央系统发起的Call并回复
+func (c *Client) handleCall(msg *Message) {
+	var reply []byte
+	var err error
+
+	result, callErr := c.handler(msg.Action, msg.Payload)
+	if callErr != nil {
+		reply, err = EncodeCallError(msg.UniqueID, callErr)
+	} else {
+		reply, err = EncodeCallResult(msg.UniqueID, result)
+	}
+	if err != nil {
+		return
+	}
+	c.conn.WriteText(reply)
+}
+
+// Call 向中央系统发起Call并等待响应
+func (c *Client) Call(action string, request, response any) error {
+	c.callMu.Lock()
+	defer c.callMu.Unlock()
+
+	uniqueID := newUniqueID()
+	data, err := EncodeCall(uniqueID, action, request)
+	if err != nil {
+		return fmt.Errorf("编码OCPP请求失败: %w", err)
+	}
+
+	ch := make(chan *Message, 1)
+	c.mu.Lock()
+	c.pending[uniqueID] = ch
+	c.mu.Unlock()
+	defer func() {
+		c.mu.Lock()
+		delete(c.pending, uniqueID)
+		c.mu.Unlock()
+	}()
+
+	if err := c.conn.WriteText(data); err != nil {
+		return fmt.Errorf("发送OCPP请求失败: %w", err)
+	}
+
+	select {
+	case msg := <-ch:
+		if msg == nil {
+			return ErrConnClosed
+		}
+		if msg.Type == MessageTypeCallError {
+			return &CallError{Code: msg.ErrorCode, Description: msg.ErrorDesc}
+		}
+		if response != nil {
+			if err := json.Unmarshal(msg.Payload, response); err != nil {
+				return fmt.Errorf("解析OCPP响应失败: %w", err)
+			}
+		}
+		return nil
+	case <-time.After(c.callTimeout):
+		return fmt.Errorf("等待 %s 响应超时", action)
+	}
+}
+
+// Close 关闭连接
+func (c *Client) Close() error {
+	return c.conn.Close()
+}
+
+// failPending 连接断开时结束所有等待中的Call
+func (c *Client) failPending() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for id, ch := range c.pending {
+		select {
+		case ch <- nil:
+		default:
+		}
+		delete(c.pending, id)
+	}
+}
+
+// newUniqueID 生成消息ID
+func newUniqueID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,94 @@
+package ocpp
+
+import "testing"
+
+func TestParseMessage(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		want    Message
+		wantErr bool
+	}{
+		{
+			name: "Call",
+			data: `[2,"42","RemoteStartTransaction",{"idTag":"ABC"}]`,
+			want: Message{Type: MessageTypeCall, UniqueID: "42", Action: "RemoteStartTransaction", Payload: []byte(`{"idTag":"ABC"}`)},
+		},
+		{
+			name: "CallResult",
+			data: `[3,"42",{"status":"Accepted"}]`,
+			want: Message{Type: MessageTypeCallResult, UniqueID: "42", Payload: []byte(`{"status":"Accepted"}`)},
+		},
+		{
+			name: "CallError",
+			data: `[4,"42","InternalError","充电桩不存在",{}]`,
+			want: Message{Type: MessageTypeCallError, UniqueID: "42", ErrorCode: "InternalError", ErrorDesc: "充电桩不存在"},
+		},
+		{name: "不是数组", data: `"Heartbeat"`, wantErr: true},
+		{name: "字段不足", data: `[3,"42"]`, wantErr: true},
+		{name: "Call缺少负载", data: `[2,"42","Reset"]`, wantErr: true},
+		{name: "CallError字段不足", data: `[4,"42","InternalError"]`, wantErr: true},
+		{name: "消息类型不是数字", data: `["2","42","Reset",{}]`, wantErr: true},
+		{name: "未知消息类型", data: `[9,"42",{}]`, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseMessage([]byte(tt.data))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseMessage() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if got.Type != tt.want.Type || got.UniqueID != tt.want.UniqueID || got.Action != tt.want.Action ||
+				string(got.Payload) != string(tt.want.Payload) ||
+				got.ErrorCode != tt.want.ErrorCode || got.ErrorDesc != tt.want.ErrorDesc {
+				t.Errorf("ParseMessage() = %+v, want %+v", *got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEncodeMessages(t *testing.T) {
+	tests := []struct {
+		name   string
+		encode func() ([]byte, error)
+		want   string
+	}{
+		{
+			name: "Call",
+			encode: func() ([]byte, error) {
+				return EncodeCall("1", "StatusNotification", map[string]int{"connectorId": 1})
+			},
+			want: `[2,"1","StatusNotification",{"connectorId":1}]`,
+		},
+		{
+			name:   "CallResult",
+			encode: func() ([]byte, error) { return EncodeCallResult("1", map[string]string{"status": "Accepted"}) },
+			want:   `[3,"1",{"status":"Accepted"}]`,
+		},
+		{
+			name: "CallError",
+			encode: func() ([]byte, error) {
+				return EncodeCallError("1", &CallError{Code: ErrorNotImplemented, Description: "不支持"})
+			},
+			want: `[4,"1","NotImplemented","不支持",{}]`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := tt.encode()
+			if err != nil {
+				t.Fatalf("编码失败: %v", err)
+			}
+			if string(data) != tt.want {
+				t.Errorf("编码结果 = %s, want %s", data, tt.want)
+			}
+			if _, err := ParseMessage(data); err != nil {
+				t.Errorf("编码结果无法解析: %v", err)
+			}
+		})
+	}
+}
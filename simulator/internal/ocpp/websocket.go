@@ -0,0 +1,103 @@
+package ocpp
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// maxMessageSize 单条消息最大长度
+const maxMessageSize = 1 << 20
+
+// writeTimeout 单条消息的发送超时
+const writeTimeout = 10 * time.Second
+
+// ErrConnClosed 连接已关闭
+var ErrConnClosed = errors.New("WebSocket连接已关闭")
+
+// Conn 客户端WebSocket连接，仅提供OCPP所需的文本消息收发
+// 帧编解码、ping/pong与关闭握手由 gorilla/websocket 处理
+type Conn struct {
+	ws      *websocket.Conn
+	writeMu sync.Mutex // gorilla/websocket 同一时刻只允许一个写入方
+	closed  bool
+}
+
+// Dial 连接WebSocket服务端并协商子协议，支持 ws:// 与 wss://
+// 地址中携带用户名与密码时使用HTTP基本认证（OCPP 1.6 安全配置1）
+func Dial(rawURL, subprotocol string, timeout time.Duration) (*Conn, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("无效的地址: %w", err)
+	}
+
+	header := http.Header{}
+	if u.User != nil {
+		password, _ := u.User.Password()
+		credentials := base64.StdEncoding.EncodeToString([]byte(u.User.Username() + ":" + password))
+		header.Set("Authorization", "Basic "+credentials)
+		u.User = nil
+	}
+
+	dialer := websocket.Dialer{
+		HandshakeTimeout: timeout,
+		Subprotocols:     []string{subprotocol},
+	}
+	ws, resp, err := dialer.Dial(u.String(), header)
+	if err != nil {
+		if resp != nil {
+			return nil, fmt.Errorf("握手失败(状态码: %d)", resp.StatusCode)
+		}
+		return nil, err
+	}
+	if ws.Subprotocol() != subprotocol {
+		ws.Close()
+		return nil, fmt.Errorf("服务端未接受子协议 %s", subprotocol)
+	}
+
+	ws.SetReadLimit(maxMessageSize)
+	return &Conn{ws: ws}, nil
+}
+
+// ReadMessage 读取一条完整的数据消息，对端正常关闭时返回 ErrConnClosed
+func (c *Conn) ReadMessage() ([]byte, error) {
+	_, data, err := c.ws.ReadMessage()
+	if err != nil {
+		if websocket.IsCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) {
+			c.Close()
+			return nil, ErrConnClosed
+		}
+		return nil, err
+	}
+	return data, nil
+}
+
+// WriteText 发送文本消息
+func (c *Conn) WriteText(data []byte) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+
+	if c.closed {
+		return ErrConnClosed
+	}
+	c.ws.SetWriteDeadline(time.Now().Add(writeTimeout))
+	return c.ws.WriteMessage(websocket.TextMessage, data)
+}
+
+// Close 关闭连接
+func (c *Conn) Close() error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+
+	if c.closed {
+		return nil
+	}
+	c.closed = true
+	return c.ws.Close()
+}
@@ -0,0 +1,155 @@
+package ocpp
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// testServer 模拟中央系统，校验基本认证后升级连接
+type testServer struct {
+	url      string
+	username string
+	password string
+	conns    chan *websocket.Conn
+}
+
+func newTestServer(t *testing.T, subprotocols []string) *testServer {
+	t.Helper()
+	s := &testServer{conns: make(chan *websocket.Conn, 1)}
+	upgrader := websocket.Upgrader{Subprotocols: subprotocols}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		username, password, ok := r.BasicAuth()
+		if !ok || username != "P1" || password != "secret" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		ws, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		s.conns <- ws
+	}))
+	t.Cleanup(server.Close)
+	s.url = "ws://" + strings.TrimPrefix(server.URL, "http://")
+	return s
+}
+
+// accept 等待中央系统完成升级
+func (s *testServer) accept(t *testing.T) *websocket.Conn {
+	t.Helper()
+	select {
+	case ws := <-s.conns:
+		t.Cleanup(func() { ws.Close() })
+		return ws
+	case <-time.After(5 * time.Second):
+		t.Fatal("等待中央系统升级超时")
+		return nil
+	}
+}
+
+// withUser 在地址中加入基本认证信息
+func withUser(rawURL, username, password string) string {
+	return strings.Replace(rawURL, "ws://", "ws://"+username+":"+password+"@", 1)
+}
+
+func TestDial(t *testing.T) {
+	tests := []struct {
+		name         string
+		subprotocols []string
+		username     string
+		password     string
+		wantErr      bool
+	}{
+		{name: "认证通过并协商子协议", subprotocols: []string{Subprotocol}, username: "P1", password: "secret"},
+		{name: "密码错误", subprotocols: []string{Subprotocol}, username: "P1", password: "wrong", wantErr: true},
+		{name: "未携带认证信息", subprotocols: []string{Subprotocol}, wantErr: true},
+		{name: "中央系统不支持子协议", subprotocols: []string{"ocpp2.0.1"}, username: "P1", password: "secret", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newTestServer(t, tt.subprotocols)
+			url := server.url + "/ocpp/P1"
+			if tt.username != "" {
+				url = withUser(url, tt.username, tt.password)
+			}
+
+			conn, err := Dial(url, Subprotocol, 5*time.Second)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Dial() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if conn != nil {
+				conn.Close()
+			}
+		})
+	}
+}
+
+func TestConnMessages(t *testing.T) {
+	server := newTestServer(t, []string{Subprotocol})
+	conn, err := Dial(withUser(server.url, "P1", "secret"), Subprotocol, 5*time.Second)
+	if err != nil {
+		t.Fatalf("Dial() error = %v", err)
+	}
+	defer conn.Close()
+	ws := server.accept(t)
+
+	if err := conn.WriteText([]byte(`[2,"1","Heartbeat",{}]`)); err != nil {
+		t.Fatalf("WriteText() error = %v", err)
+	}
+	if _, data, err := ws.ReadMessage(); err != nil || string(data) != `[2,"1","Heartbeat",{}]` {
+		t.Fatalf("中央系统收到 %s, %v", data, err)
+	}
+
+	// 中央系统分片发送的消息由客户端合并
+	writer, _ := ws.NextWriter(websocket.TextMessage)
+	writer.Write([]byte(`[3,"1",`))
+	writer.Write([]byte(`{"currentTime":"2024-06-01T00:00:00Z"}]`))
+	writer.Close()
+
+	data, err := conn.ReadMessage()
+	if err != nil || string(data) != `[3,"1",{"currentTime":"2024-06-01T00:00:00Z"}]` {
+		t.Errorf("ReadMessage() = %s, %v", data, err)
+	}
+}
+
+func TestConnRejectsOversizedMessage(t *testing.T) {
+	server := newTestServer(t, []string{Subprotocol})
+	conn, err := Dial(withUser(server.url, "P1", "secret"), Subprotocol, 5*time.Second)
+	if err != nil {
+		t.Fatalf("Dial() error = %v", err)
+	}
+	defer conn.Close()
+	ws := server.accept(t)
+
+	ws.WriteMessage(websocket.TextMessage, make([]byte, maxMessageSize+1))
+	if _, err := conn.ReadMessage(); err == nil || errors.Is(err, ErrConnClosed) {
+		t.Errorf("ReadMessage() error = %v, want 消息过大", err)
+	}
+}
+
+func TestConnClose(t *testing.T) {
+	server := newTestServer(t, []string{Subprotocol})
+	conn, err := Dial(withUser(server.url, "P1", "secret"), Subprotocol, 5*time.Second)
+	if err != nil {
+		t.Fatalf("Dial() error = %v", err)
+	}
+	defer conn.Close()
+	ws := server.accept(t)
+
+	closeMsg := websocket.FormatCloseMessage(websocket.CloseGoingAway, "")
+	ws.WriteControl(websocket.CloseMessage, closeMsg, time.Now().Add(time.Second))
+
+	if _, err := conn.ReadMessage(); !errors.Is(err, ErrConnClosed) {
+		t.Fatalf("ReadMessage() error = %v, want ErrConnClosed", err)
+	}
+	if err := conn.WriteText([]byte("x")); !errors.Is(err, ErrConnClosed) {
+		t.Errorf("关闭后 WriteText() error = %v, want ErrConnClosed", err)
+	}
+}
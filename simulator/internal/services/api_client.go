@@ -2,9 +2,12 @@ package services
 
 import (
 	"bytes"
+	"crypto/rand"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"strconv"
 	"time"
 
 	"simulator/internal/config"
@@ -12,11 +15,27 @@ import (
 	"simulator/internal/utils"
 )
 
+// BackendReporter 充电桩事件上报接口
+// HTTP模式由APIClient实现，OCPP模式由OCPPClient实现
+type BackendReporter interface {
+	UpdateChargingProgress(pile *models.Pile) error
+	CompleteCharging(pile *models.Pile, vehicle *models.ChargingVehicle) error
+	ReportFault(pile *models.Pile, faultType models.FaultType, description string) error
+	RecoverFault(pile *models.Pile) error
+	SendHeartbeat(pileIDs []string) error
+	ReportPileStatus(pile *models.Pile) error
+	ReportVehicleDeparted(pile *models.Pile, vehicle *models.ChargingVehicle) error
+}
+
 // APIClient 后端API客户端
 type APIClient struct {
 	client  *http.Client
 	baseURL string
 	logger  *utils.Logger
+	chaos   *ChaosInjector // 网络混沌注入，为nil时不注入
+	outbox  *Outbox        // 持久化发件箱，为nil时直接发送
+
+	registrationSecret string // 充电桩自注册的共享密钥
 }
 
 // NewAPIClient 创建API客户端
@@ -25,30 +44,75 @@ func NewAPIClient(cfg *config.Config, logger *utils.Logger) *APIClient {
 		client:  &http.Client{Timeout: 10 * time.Second},
 		baseURL: cfg.BackendAPI.BaseURL,
 		logger:  logger,
+
+		registrationSecret: cfg.BackendAPI.RegistrationSecret,
 	}
 }
 
+// registerPath 充电桩自注册接口，需携带共享密钥
+const registerPath = "/api/v1/simulator/register"
+
+// SetChaos 设置网络混沌注入器
+func (c *APIClient) SetChaos(chaos *ChaosInjector) {
+	c.chaos = chaos
+}
+
+// SetOutbox 设置持久化发件箱，设置后上报先落盘再异步投递
+func (c *APIClient) SetOutbox(outbox *Outbox) {
+	c.outbox = outbox
+}
+
 // 充电桩状态上报请求
 type PileStatusRequest struct {
-	PileID         string `json:"pileId"`
-	Status         string `json:"status"` // charging|available|fault|maintenance|offline
-	CurrentVehicle *struct {
-		UserID            string    `json:"userId"`
-		StartTime         time.Time `json:"startTime"`
-		RequestedCapacity float64   `json:"requestedCapacity"`
-		CurrentCapacity   float64   `json:"currentCapacity"`
-	} `json:"currentVehicle,omitempty"`
+	PileID         string             `json:"pileId"`
+	Status         string             `json:"status"` // charging|available|fault|maintenance|offline
+	CurrentVehicle *PileStatusVehicle `json:"currentVehicle,omitempty"`
+}
+
+// PileStatusVehicle 状态上报中的当前充电车辆
+type PileStatusVehicle struct {
+	SessionID         string    `json:"sessionId,omitempty"`
+	UserID            string    `json:"userId"`
+	StartTime         time.Time `json:"startTime"`
+	RequestedCapacity float64   `json:"requestedCapacity"`
+	CurrentCapacity   float64   `json:"currentCapacity"`
+}
+
+// ReportPileStatus 上报充电桩状态，供后端对账
+func (c *APIClient) ReportPileStatus(pile *models.Pile) error {
+	status, vehicle := pile.GetStatus()
+
+	req := PileStatusRequest{
+		PileID: pile.ID,
+		Status: string(status),
+	}
+	if vehicle != nil {
+		req.CurrentVehicle = &PileStatusVehicle{
+			SessionID:         vehicle.SessionID,
+			UserID:            vehicle.UserID,
+			StartTime:         vehicle.StartTime,
+			RequestedCapacity: vehicle.RequestedCapacity,
+			CurrentCapacity:   vehicle.CurrentCapacity,
+		}
+	}
+
+	return c.sendRequest("POST", "/api/v1/simulator/pile-status", pile.ID, req)
 }
 
 // ChargingProgressRequest 充电进度更新请求
 type ChargingProgressRequest struct {
+	EventID           string    `json:"eventId"`             // 事件ID，重试时保持不变
+	SessionID         string    `json:"sessionId,omitempty"` // 后端充电会话ID
 	PileID            string    `json:"pileId"`
 	UserID            string    `json:"userId"`
 	StartTime         time.Time `json:"startTime"`
 	CurrentCapacity   float64   `json:"currentCapacity"`
 	RequestedCapacity float64   `json:"requestedCapacity"`
-	ChargingRate      float64   `json:"chargingRate"`  // 度/小时
-	RemainingTime     int       `json:"remainingTime"` // 秒
+	ChargingRate      float64   `json:"chargingRate"`         // 度/小时
+	RemainingTime     int       `json:"remainingTime"`        // 秒
+	CurrentSoC        *float64  `json:"currentSoc,omitempty"` // 当前电量百分比
+	CurrentPower      float64   `json:"currentPower"`         // 瞬时充电功率(kW)
+	RatedPower        float64   `json:"ratedPower"`           // 充电桩额定功率(kW)
 }
 
 // UpdateChargingProgress 上报充电进度
@@ -61,37 +125,54 @@ func (c *APIClient) UpdateChargingProgress(pile *models.Pile) error {
 
 	// 准备请求数据
 	req := ChargingProgressRequest{
+		EventID:           newEventID(),
+		SessionID:         vehicle.SessionID,
 		PileID:            pile.ID,
 		UserID:            vehicle.UserID,
 		StartTime:         vehicle.StartTime,
 		CurrentCapacity:   vehicle.CurrentCapacity,
 		RequestedCapacity: vehicle.RequestedCapacity,
-		ChargingRate:      pile.Power,           // kW
+		ChargingRate:      vehicle.CurrentPower, // kW，按充电曲线计算的实际速率
 		RemainingTime:     pile.RemainingTime(), // 秒
+		CurrentPower:      vehicle.CurrentPower,
+		RatedPower:        pile.Power,
+	}
+	if vehicle.HasSoC() {
+		soc := vehicle.CurrentSoC
+		req.CurrentSoC = &soc
 	}
 
 	// 发送请求
-	return c.sendRequest("POST", "/api/v1/simulator/charging-progress", req)
+	return c.sendRequest("POST", "/api/v1/simulator/charging-progress", pile.ID, req)
 }
 
 // FaultReportRequest 故障报告请求
 type FaultReportRequest struct {
+	EventID     string `json:"eventId"`             // 事件ID，重试时保持不变
+	SessionID   string `json:"sessionId,omitempty"` // 故障时正在充电的会话ID
 	PileID      string `json:"pileId"`
 	FaultType   string `json:"faultType"` // hardware|software|power
 	Description string `json:"description"`
+
+	DegradedPower float64 `json:"degradedPower,omitempty"` // 降功率运行时的可提供功率(kW)，为0时充电桩停止服务
 }
 
 // ReportFault 报告故障
 func (c *APIClient) ReportFault(pile *models.Pile, faultType models.FaultType, description string) error {
 	// 准备请求数据
 	req := FaultReportRequest{
+		EventID:     newEventID(),
+		SessionID:   currentSessionID(pile),
 		PileID:      pile.ID,
 		FaultType:   string(faultType),
 		Description: description,
 	}
+	if pile.IsDegraded() {
+		req.DegradedPower = pile.EffectivePower()
+	}
 
 	// 发送请求
-	return c.sendRequest("POST", "/api/v1/simulator/fault-report", req)
+	return c.sendRequest("POST", "/api/v1/simulator/fault-report", pile.ID, req)
 }
 
 // HeartbeatRequest 心跳请求
@@ -102,6 +183,17 @@ type HeartbeatRequest struct {
 
 // SendHeartbeat 发送心跳
 func (c *APIClient) SendHeartbeat(pileIDs []string) error {
+	// 网络分区中的充电桩不在心跳中出现
+	if c.chaos != nil {
+		reachable := make([]string, 0, len(pileIDs))
+		for _, pileID := range pileIDs {
+			if !c.chaos.IsPartitioned(pileID) {
+				reachable = append(reachable, pileID)
+			}
+		}
+		pileIDs = reachable
+	}
+
 	// 准备请求数据
 	req := HeartbeatRequest{
 		PileIDs:   pileIDs,
@@ -109,11 +201,51 @@ func (c *APIClient) SendHeartbeat(pileIDs []string) error {
 	}
 
 	// 发送请求
-	return c.sendRequest("POST", "/api/v1/simulator/heartbeat", req)
+	return c.sendRequest("POST", "/api/v1/simulator/heartbeat", "", req)
+}
+
+// RegisterRequest 充电桩注册请求
+type RegisterRequest struct {
+	CallbackURL string             `json:"callbackUrl"`
+	Piles       []RegisterPileInfo `json:"piles"`
+}
+
+// RegisterPileInfo 注册的充电桩信息
+type RegisterPileInfo struct {
+	ID     string  `json:"id"`
+	Type   string  `json:"type"`
+	Power  float64 `json:"power"`
+	Status string  `json:"status"`
+}
+
+// RegisterPiles 向后端注册充电桩及本模拟器的指令下发地址
+// 注册不经过发件箱，由调用方重试
+func (c *APIClient) RegisterPiles(callbackURL string, piles []*models.Pile) error {
+	req := RegisterRequest{
+		CallbackURL: callbackURL,
+		Piles:       make([]RegisterPileInfo, 0, len(piles)),
+	}
+	for _, pile := range piles {
+		status, _ := pile.GetStatus()
+		req.Piles = append(req.Piles, RegisterPileInfo{
+			ID:     pile.ID,
+			Type:   string(pile.Type),
+			Power:  pile.Power,
+			Status: string(status),
+		})
+	}
+
+	jsonData, err := json.Marshal(req)
+	if err != nil {
+		return fmt.Errorf("序列化请求数据失败: %w", err)
+	}
+	return c.send("POST", registerPath, "", 0, jsonData)
 }
 
 // ChargingCompleteRequest 充电完成请求
 type ChargingCompleteRequest struct {
+	EventID           string    `json:"eventId"`             // 事件ID，重试时保持不变
+	SessionID         string    `json:"sessionId,omitempty"` // 后端充电会话ID
 	PileID            string    `json:"pileId"`
 	UserID            string    `json:"userId"`
 	StartTime         time.Time `json:"startTime"`
@@ -121,6 +253,7 @@ type ChargingCompleteRequest struct {
 	RequestedCapacity float64   `json:"requestedCapacity"`
 	ActualCapacity    float64   `json:"actualCapacity"`
 	ChargingDuration  int       `json:"chargingDuration"` // 秒
+	EndSoC            *float64  `json:"endSoc,omitempty"` // 结束电量百分比
 }
 
 // CompleteCharging 上报充电完成
@@ -135,6 +268,8 @@ func (c *APIClient) CompleteCharging(pile *models.Pile, vehicle *models.Charging
 
 	// 准备请求数据
 	req := ChargingCompleteRequest{
+		EventID:           newEventID(),
+		SessionID:         vehicle.SessionID,
 		PileID:            pile.ID,
 		UserID:            vehicle.UserID,
 		StartTime:         vehicle.StartTime,
@@ -143,43 +278,128 @@ func (c *APIClient) CompleteCharging(pile *models.Pile, vehicle *models.Charging
 		ActualCapacity:    vehicle.CurrentCapacity,
 		ChargingDuration:  chargingDuration,
 	}
+	if vehicle.HasSoC() {
+		soc := vehicle.CurrentSoC
+		req.EndSoC = &soc
+	}
+
+	// 发送请求
+	return c.sendRequest("POST", "/api/v1/simulator/charging-complete", pile.ID, req)
+}
+
+// VehicleDepartedRequest 车辆驶离请求
+type VehicleDepartedRequest struct {
+	EventID    string    `json:"eventId"`             // 事件ID，重试时保持不变
+	SessionID  string    `json:"sessionId,omitempty"` // 已结束的充电会话ID
+	PileID     string    `json:"pileId"`
+	DepartedAt time.Time `json:"departedAt"`
+}
+
+// ReportVehicleDeparted 上报车辆驶离
+func (c *APIClient) ReportVehicleDeparted(pile *models.Pile, vehicle *models.ChargingVehicle) error {
+	req := VehicleDepartedRequest{
+		EventID:    newEventID(),
+		SessionID:  vehicle.SessionID,
+		PileID:     pile.ID,
+		DepartedAt: time.Now().UTC(),
+	}
 
 	// 发送请求
-	return c.sendRequest("POST", "/api/v1/simulator/charging-complete", req)
+	return c.sendRequest("POST", "/api/v1/simulator/vehicle-departed", pile.ID, req)
 }
 
 // FaultRecoveryRequest 故障恢复请求
 type FaultRecoveryRequest struct {
-	PileID string `json:"pileId"`
+	EventID   string `json:"eventId"`             // 事件ID，重试时保持不变
+	SessionID string `json:"sessionId,omitempty"` // 故障时中断的充电会话ID
+	PileID    string `json:"pileId"`
 }
 
 // RecoverFault 上报故障恢复
 func (c *APIClient) RecoverFault(pile *models.Pile) error {
 	// 准备请求数据
 	req := FaultRecoveryRequest{
-		PileID: pile.ID,
+		EventID:   newEventID(),
+		SessionID: currentSessionID(pile),
+		PileID:    pile.ID,
 	}
 
 	// 发送请求
-	return c.sendRequest("POST", "/api/v1/simulator/fault-recovery", req)
+	return c.sendRequest("POST", "/api/v1/simulator/fault-recovery", pile.ID, req)
 }
 
-// 发送HTTP请求的通用方法
-func (c *APIClient) sendRequest(method, path string, payload any) error {
-	url := c.baseURL + path
+// newEventID 生成上报事件ID，后端据此识别重复上报
+func newEventID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
 
-	// 序列化请求体
+// currentSessionID 充电桩当前车辆的会话ID，无车辆时为空
+func currentSessionID(pile *models.Pile) string {
+	if _, vehicle := pile.GetStatus(); vehicle != nil {
+		return vehicle.SessionID
+	}
+	return ""
+}
+
+// sendRequest 发送上报
+// 启用发件箱时事件类上报先落盘，由发件箱按序投递并重试；心跳与状态上报周期发送，失败无需重试；
+// 充电进度只保留每个充电桩最新的一条，与完成上报仍按顺序投递
+func (c *APIClient) sendRequest(method, path, pileID string, payload any) error {
 	jsonData, err := json.Marshal(payload)
 	if err != nil {
 		return fmt.Errorf("序列化请求数据失败: %w", err)
 	}
 
+	periodic := path == "/api/v1/simulator/heartbeat" || path == "/api/v1/simulator/pile-status"
+	switch {
+	case c.outbox == nil || periodic:
+		return c.send(method, path, pileID, 0, jsonData)
+	case path == "/api/v1/simulator/charging-progress":
+		_, err := c.outbox.EnqueueLatest(pileID, method, path, json.RawMessage(jsonData))
+		return err
+	default:
+		_, err := c.outbox.Enqueue(pileID, method, path, json.RawMessage(jsonData))
+		return err
+	}
+}
+
+// Deliver 投递发件箱中的一条上报
+func (c *APIClient) Deliver(entry *OutboxEntry) error {
+	return c.send(entry.Method, entry.Path, entry.PileID, entry.Seq, entry.Payload)
+}
+
+// send 发送上报，启用混沌注入时先经过混沌层
+// 进度与完成上报可能被重复或乱序发送
+func (c *APIClient) send(method, path, pileID string, seq int64, jsonData []byte) error {
+	if c.chaos == nil {
+		return c.doRequest(method, path, seq, jsonData)
+	}
+
+	reorderable := path == "/api/v1/simulator/charging-progress" || path == "/api/v1/simulator/charging-complete"
+	return c.chaos.Apply(pileID, path, reorderable, func() error {
+		return c.doRequest(method, path, seq, jsonData)
+	})
+}
+
+// 发送HTTP请求的通用方法
+// 4xx响应（请求超时与限流除外）重试也不会成功，返回PermanentError
+func (c *APIClient) doRequest(method, path string, seq int64, jsonData []byte) error {
+	url := c.baseURL + path
+
 	// 创建请求
 	req, err := http.NewRequest(method, url, bytes.NewBuffer(jsonData))
 	if err != nil {
 		return fmt.Errorf("创建HTTP请求失败: %w", err)
 	}
 	req.Header.Set("Content-Type", "application/json")
+	if path == registerPath {
+		req.Header.Set("X-Registration-Secret", c.registrationSecret)
+	}
+	if seq > 0 {
+		req.Header.Set("X-Outbox-Seq", strconv.FormatInt(seq, 10))
+	}
 
 	// 记录请求
 	c.logger.Info("发送请求: %s %s", method, url)
@@ -196,10 +416,15 @@ func (c *APIClient) sendRequest(method, path string, payload any) error {
 		var errorResp struct {
 			Message string `json:"message"`
 		}
-		if err := json.NewDecoder(resp.Body).Decode(&errorResp); err == nil && errorResp.Message != "" {
-			return fmt.Errorf("服务器返回错误(状态码: %d): %s", resp.StatusCode, errorResp.Message)
+		err := fmt.Errorf("服务器返回错误(状态码: %d)", resp.StatusCode)
+		if decodeErr := json.NewDecoder(resp.Body).Decode(&errorResp); decodeErr == nil && errorResp.Message != "" {
+			err = fmt.Errorf("服务器返回错误(状态码: %d): %s", resp.StatusCode, errorResp.Message)
+		}
+		if resp.StatusCode >= 400 && resp.StatusCode < 500 &&
+			resp.StatusCode != http.StatusRequestTimeout && resp.StatusCode != http.StatusTooManyRequests {
+			return &PermanentError{Err: err}
 		}
-		return fmt.Errorf("服务器返回错误(状态码: %d)", resp.StatusCode)
+		return err
 	}
 
 	return nil
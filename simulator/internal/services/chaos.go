@@ -0,0 +1,389 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"simulator/internal/config"
+	"simulator/internal/utils"
+)
+
+// maxChaosEvents 内存中保留的注入记录数
+const maxChaosEvents = 200
+
+// ErrChaosDropped 请求被混沌层丢弃
+var ErrChaosDropped = errors.New("混沌注入: 请求被丢弃")
+
+// ChaosEvent 一次注入记录
+type ChaosEvent struct {
+	Time   time.Time `json:"time"`
+	Kind   string    `json:"kind"`   // latency|drop|duplicate|reorder|partition|control
+	PileID string    `json:"pileId"` // 为空表示不针对单个充电桩
+	Target string    `json:"target"` // 请求路径或命令
+	Detail string    `json:"detail"`
+}
+
+// ChaosScenario 混沌场景文件
+type ChaosScenario struct {
+	Name  string              `json:"name"`
+	Steps []ChaosScenarioStep `json:"steps"`
+}
+
+// ChaosScenarioStep 场景步骤，命令格式与CLI的chaos子命令相同
+type ChaosScenarioStep struct {
+	At      int    `json:"at"`      // 相对场景开始的秒数
+	Command string `json:"command"` // 如 "latency 200 50"、"partition F1 60"
+}
+
+// ChaosInjector 网络混沌注入器
+// 作用于APIClient上报与ServerAPI指令接收，所有注入都会记录
+type ChaosInjector struct {
+	settings   config.ChaosConfig
+	partitions map[string]time.Time // 充电桩ID -> 分区结束时间
+	events     []ChaosEvent
+	logger     *utils.Logger
+	mu         sync.Mutex
+}
+
+// NewChaosInjector 创建混沌注入器
+func NewChaosInjector(cfg *config.Config, logger *utils.Logger) *ChaosInjector {
+	return &ChaosInjector{
+		settings:   cfg.Chaos,
+		partitions: make(map[string]time.Time),
+		logger:     logger,
+	}
+}
+
+// Apply 对一次上报施加混沌：分区、丢弃、延迟，可重复或乱序的上报还可能被重复或延后发送
+func (c *ChaosInjector) Apply(pileID, target string, reorderable bool, send func() error) error {
+	if c.IsPartitioned(pileID) {
+		c.record("partition", pileID, target, "网络分区中，请求未发出")
+		return fmt.Errorf("混沌注入: 充电桩 %s 网络分区中", pileID)
+	}
+
+	settings := c.Settings()
+	if roll(settings.DropRate) {
+		c.record("drop", pileID, target, fmt.Sprintf("按 %.1f%% 概率丢弃", settings.DropRate))
+		return ErrChaosDropped
+	}
+
+	c.delay(settings, pileID, target)
+
+	if reorderable && roll(settings.ReorderRate) {
+		delay := time.Duration(settings.ReorderDelay) * time.Millisecond
+		c.record("reorder", pileID, target, fmt.Sprintf("延后 %dms 发送", settings.ReorderDelay))
+		go func() {
+			time.Sleep(delay)
+			if err := send(); err != nil {
+				c.logger.Error("混沌乱序上报发送失败: %s %s: %v", pileID, target, err)
+			}
+		}()
+		return nil
+	}
+
+	err := send()
+
+	if reorderable && roll(settings.DuplicateRate) {
+		c.record("duplicate", pileID, target, "重复发送一次")
+		if dupErr := send(); dupErr != nil {
+			c.logger.Error("混沌重复上报发送失败: %s %s: %v", pileID, target, dupErr)
+		}
+	}
+
+	return err
+}
+
+// Intercept 对接收到的指令施加混沌，返回false表示该指令应当被丢弃
+func (c *ChaosInjector) Intercept(pileID, target string) bool {
+	if c.IsPartitioned(pileID) {
+		c.record("partition", pileID, target, "网络分区中，指令被丢弃")
+		return false
+	}
+
+	settings := c.Settings()
+	if roll(settings.DropRate) {
+		c.record("drop", pileID, target, fmt.Sprintf("按 %.1f%% 概率丢弃指令", settings.DropRate))
+		return false
+	}
+
+	c.delay(settings, pileID, target)
+	return true
+}
+
+// delay 按配置注入延迟与抖动
+func (c *ChaosInjector) delay(settings config.ChaosConfig, pileID, target string) {
+	latency := settings.Latency
+	if settings.Jitter > 0 {
+		latency += rand.Intn(settings.Jitter + 1)
+	}
+	if latency <= 0 {
+		return
+	}
+	c.record("latency", pileID, target, fmt.Sprintf("延迟 %dms", latency))
+	time.Sleep(time.Duration(latency) * time.Millisecond)
+}
+
+// IsPartitioned 充电桩是否处于网络分区，过期的分区自动解除
+func (c *ChaosInjector) IsPartitioned(pileID string) bool {
+	if pileID == "" {
+		return false
+	}
+
+	c.mu.Lock()
+	until, exists := c.partitions[pileID]
+	if exists && time.Now().After(until) {
+		delete(c.partitions, pileID)
+		exists = false
+	}
+	c.mu.Unlock()
+
+	if !exists && until != (time.Time{}) {
+		c.record("partition", pileID, "", "网络分区结束")
+	}
+	return exists
+}
+
+// roll 按百分比概率判定
+func roll(percent float64) bool {
+	return percent > 0 && rand.Float64()*100 < percent
+}
+
+// record 记录一次注入
+func (c *ChaosInjector) record(kind, pileID, target, detail string) {
+	event := ChaosEvent{
+		Time:   time.Now().UTC(),
+		Kind:   kind,
+		PileID: pileID,
+		Target: target,
+		Detail: detail,
+	}
+
+	c.mu.Lock()
+	c.events = append(c.events, event)
+	if len(c.events) > maxChaosEvents {
+		c.events = c.events[len(c.events)-maxChaosEvents:]
+	}
+	logFile := c.settings.LogFile
+	c.mu.Unlock()
+
+	c.logger.Warning("[混沌] %s 充电桩=%s 目标=%s %s", kind, pileID, target, detail)
+
+	if logFile != "" {
+		if err := appendChaosEvent(logFile, event); err != nil {
+			c.logger.Error("写入混沌记录失败: %v", err)
+		}
+	}
+}
+
+// appendChaosEvent 以JSON行追加注入记录
+func appendChaosEvent(path string, event ChaosEvent) error {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	return json.NewEncoder(file).Encode(event)
+}
+
+// Settings 当前混沌参数
+func (c *ChaosInjector) Settings() config.ChaosConfig {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.settings
+}
+
+// Partitions 当前网络分区及结束时间
+func (c *ChaosInjector) Partitions() map[string]time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	partitions := make(map[string]time.Time, len(c.partitions))
+	for pileID, until := range c.partitions {
+		partitions[pileID] = until
+	}
+	return partitions
+}
+
+// Events 最近的注入记录，n<=0时返回全部
+func (c *ChaosInjector) Events(n int) []ChaosEvent {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	events := c.events
+	if n > 0 && len(events) > n {
+		events = events[len(events)-n:]
+	}
+	return append([]ChaosEvent(nil), events...)
+}
+
+// Exec 执行混沌控制命令，CLI与场景文件共用
+//
+//	latency <ms> [jitterMs]   设置延迟与抖动
+//	drop <percent>            设置丢弃比例
+//	dup <percent>             设置重复上报比例
+//	reorder <percent> [ms]    设置乱序比例与延后时间
+//	partition <pileID> <sec>  对充电桩注入网络分区
+//	heal [pileID]             解除网络分区，不指定则全部解除
+//	reset                     清除所有注入
+func (c *ChaosInjector) Exec(args []string) (string, error) {
+	if len(args) == 0 {
+		return "", errors.New("缺少混沌命令")
+	}
+
+	c.mu.Lock()
+	message, err := c.applyCommand(args)
+	c.mu.Unlock()
+	if err != nil {
+		return "", err
+	}
+
+	c.record("control", "", strings.Join(args, " "), message)
+	return message, nil
+}
+
+// applyCommand 修改混沌参数，调用方持有锁
+func (c *ChaosInjector) applyCommand(args []string) (string, error) {
+	var message string
+	switch args[0] {
+	case "latency":
+		latency, err := intArg(args, 1, -1)
+		if err != nil {
+			return "", err
+		}
+		jitter, err := intArg(args, 2, 0)
+		if err != nil {
+			return "", err
+		}
+		c.settings.Latency, c.settings.Jitter = latency, jitter
+		message = fmt.Sprintf("延迟 %dms，抖动 %dms", latency, jitter)
+	case "drop":
+		rate, err := percentArg(args, 1)
+		if err != nil {
+			return "", err
+		}
+		c.settings.DropRate = rate
+		message = fmt.Sprintf("丢弃比例 %.1f%%", rate)
+	case "dup":
+		rate, err := percentArg(args, 1)
+		if err != nil {
+			return "", err
+		}
+		c.settings.DuplicateRate = rate
+		message = fmt.Sprintf("重复上报比例 %.1f%%", rate)
+	case "reorder":
+		rate, err := percentArg(args, 1)
+		if err != nil {
+			return "", err
+		}
+		delay, err := intArg(args, 2, c.settings.ReorderDelay)
+		if err != nil {
+			return "", err
+		}
+		c.settings.ReorderRate, c.settings.ReorderDelay = rate, delay
+		message = fmt.Sprintf("乱序比例 %.1f%%，延后 %dms", rate, delay)
+	case "partition":
+		if len(args) < 3 {
+			return "", errors.New("用法: partition <pileID> <seconds>")
+		}
+		seconds, err := intArg(args, 2, -1)
+		if err != nil {
+			return "", err
+		}
+		c.partitions[args[1]] = time.Now().Add(time.Duration(seconds) * time.Second)
+		message = fmt.Sprintf("充电桩 %s 网络分区 %d秒", args[1], seconds)
+	case "heal":
+		if len(args) > 1 {
+			delete(c.partitions, args[1])
+			message = fmt.Sprintf("已解除充电桩 %s 的网络分区", args[1])
+		} else {
+			c.partitions = make(map[string]time.Time)
+			message = "已解除所有网络分区"
+		}
+	case "reset":
+		logFile, scenario := c.settings.LogFile, c.settings.Scenario
+		c.settings = config.ChaosConfig{ReorderDelay: c.settings.ReorderDelay, LogFile: logFile, Scenario: scenario}
+		c.partitions = make(map[string]time.Time)
+		message = "已清除所有混沌注入"
+	default:
+		return "", fmt.Errorf("未知的混沌命令: %s", args[0])
+	}
+
+	return message, nil
+}
+
+// RunScenario 按时间顺序执行场景文件中的步骤，stopCh关闭时中止
+func (c *ChaosInjector) RunScenario(path string, stopCh <-chan struct{}) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("读取场景文件失败: %w", err)
+	}
+
+	var scenario ChaosScenario
+	if err := json.Unmarshal(data, &scenario); err != nil {
+		return fmt.Errorf("解析场景文件失败: %w", err)
+	}
+
+	// 先校验全部命令，避免场景执行到一半才报错
+	steps := append([]ChaosScenarioStep(nil), scenario.Steps...)
+	sort.SliceStable(steps, func(i, j int) bool { return steps[i].At < steps[j].At })
+	for i, step := range steps {
+		if len(strings.Fields(step.Command)) == 0 {
+			return fmt.Errorf("场景第 %d 步缺少命令", i+1)
+		}
+	}
+
+	c.record("control", "", "scenario "+path, fmt.Sprintf("开始执行场景 %s，共 %d 步", scenario.Name, len(steps)))
+
+	go func() {
+		start := time.Now()
+		for _, step := range steps {
+			wait := time.Until(start.Add(time.Duration(step.At) * time.Second))
+			select {
+			case <-stopCh:
+				return
+			case <-time.After(wait):
+			}
+			if _, err := c.Exec(strings.Fields(step.Command)); err != nil {
+				c.logger.Error("场景 %s 执行命令 %q 失败: %v", scenario.Name, step.Command, err)
+			}
+		}
+		c.record("control", "", "scenario "+path, fmt.Sprintf("场景 %s 执行完毕", scenario.Name))
+	}()
+
+	return nil
+}
+
+// intArg 解析非负整数参数，def<0表示必填
+func intArg(args []string, index, def int) (int, error) {
+	if index >= len(args) {
+		if def < 0 {
+			return 0, fmt.Errorf("命令 %s 缺少参数", args[0])
+		}
+		return def, nil
+	}
+	value, err := strconv.Atoi(args[index])
+	if err != nil || value < 0 {
+		return 0, fmt.Errorf("无效的参数: %s", args[index])
+	}
+	return value, nil
+}
+
+// percentArg 解析0-100的百分比参数
+func percentArg(args []string, index int) (float64, error) {
+	if index >= len(args) {
+		return 0, fmt.Errorf("命令 %s 缺少参数", args[0])
+	}
+	value, err := strconv.ParseFloat(args[index], 64)
+	if err != nil || value < 0 || value > 100 {
+		return 0, fmt.Errorf("无效的百分比: %s", args[index])
+	}
+	return value, nil
+}
@@ -0,0 +1,57 @@
+package services
+
+import (
+	"simulator/internal/config"
+	"simulator/internal/models"
+)
+
+// newChargingCurve 根据车辆配置创建充电曲线
+func newChargingCurve(profile *config.VehicleProfile) models.ChargingCurve {
+	var curve models.ChargingCurve
+	switch profile.Curve.Type {
+	case "cccv":
+		curve = &models.CCCVCurve{
+			MaxAcceptanceRate: profile.Curve.MaxAcceptanceRate,
+			TaperStartSoC:     profile.Curve.TaperStartSoC,
+			MinPowerRatio:     profile.Curve.MinPowerRatio,
+		}
+	default:
+		curve = &models.ConstantCurve{
+			MaxAcceptanceRate: profile.Curve.MaxAcceptanceRate,
+		}
+	}
+
+	if profile.Temperature.Enabled {
+		curve = &models.TemperatureCurve{
+			Curve:           curve,
+			Ambient:         profile.Temperature.Ambient,
+			OptimalMin:      profile.Temperature.OptimalMin,
+			OptimalMax:      profile.Temperature.OptimalMax,
+			DeratePerDegree: profile.Temperature.DeratePerDegree,
+			MinFactor:       profile.Temperature.MinFactor,
+		}
+	}
+	return curve
+}
+
+// applyVehicleProfile 为充电车辆套用车辆配置，后端未提供电池信息时使用配置中的电池容量
+func (s *PileService) applyVehicleProfile(vehicle *models.ChargingVehicle, profileName string) {
+	profile := s.config.FindVehicleProfile(profileName)
+	if profile == nil {
+		if profileName != "" {
+			s.logger.Warning("车辆配置 %s 不存在，按充电桩额定功率充电", profileName)
+		}
+		return
+	}
+
+	vehicle.Profile = profile.Name
+	vehicle.Curve = newChargingCurve(profile)
+
+	if !vehicle.HasSoC() && profile.BatteryCapacity > 0 {
+		// 按请求电量反推起始SoC，假定充满为止
+		vehicle.BatteryCapacity = profile.BatteryCapacity
+		vehicle.TargetSoC = 100
+		vehicle.StartSoC = max(100-vehicle.RequestedCapacity/profile.BatteryCapacity*100, 0)
+		vehicle.CurrentSoC = vehicle.StartSoC
+	}
+}
@@ -0,0 +1,593 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"simulator/internal/config"
+	"simulator/internal/models"
+	"simulator/internal/ocpp"
+	"simulator/internal/utils"
+)
+
+// 默认参数
+const (
+	defaultReconnectInterval = 10
+	defaultOCPPCallTimeout   = 10
+	defaultOCPPBattery       = 60.0 // 未配置车辆电池容量时使用(kWh)
+	ocppConnectorID          = 1
+)
+
+// OCPPClient OCPP 1.6-J 充电桩客户端
+// 每个充电桩单独建立WebSocket连接；OCPP不携带目标电量，充电持续到中央系统远程停止或车辆充满
+type OCPPClient struct {
+	config      *config.Config
+	logger      *utils.Logger
+	pileService *PileService
+
+	chargePoints map[string]*ocppChargePoint
+	mu           sync.Mutex
+	stopCh       chan struct{}
+	wg           sync.WaitGroup
+}
+
+// ocppChargePoint 单个充电桩的OCPP连接状态
+type ocppChargePoint struct {
+	pileID        string
+	client        *ocpp.Client // 未连接时为nil
+	transactionID int          // 当前交易号，0表示无交易
+	meterStart    int          // 交易开始时的电能表读数(Wh)
+	meterWh       int          // 电能表累计读数(Wh)
+	mu            sync.Mutex
+}
+
+// NewOCPPClient 创建OCPP充电桩客户端
+func NewOCPPClient(cfg *config.Config, logger *utils.Logger) *OCPPClient {
+	return &OCPPClient{
+		config:       cfg,
+		logger:       logger,
+		chargePoints: make(map[string]*ocppChargePoint),
+		stopCh:       make(chan struct{}),
+	}
+}
+
+// Start 为每个充电桩建立OCPP连接
+func (c *OCPPClient) Start(pileService *PileService) {
+	c.pileService = pileService
+
+	for _, pile := range pileService.GetAllPiles() {
+		cp := &ocppChargePoint{pileID: pile.ID}
+		c.mu.Lock()
+		c.chargePoints[pile.ID] = cp
+		c.mu.Unlock()
+
+		c.wg.Add(1)
+		go func() {
+			defer c.wg.Done()
+			c.connectLoop(cp)
+		}()
+	}
+	c.logger.Info("OCPP充电桩模式已启动，中央系统: %s", c.config.OCPP.CentralSystemURL)
+}
+
+// Stop 断开所有OCPP连接
+func (c *OCPPClient) Stop() {
+	close(c.stopCh)
+
+	c.mu.Lock()
+	for _, cp := range c.chargePoints {
+		if client := cp.getClient(); client != nil {
+			client.Close()
+		}
+	}
+	c.mu.Unlock()
+
+	c.wg.Wait()
+}
+
+// connectLoop 保持充电桩与中央系统的连接，断线后按间隔重连
+func (c *OCPPClient) connectLoop(cp *ocppChargePoint) {
+	reconnect := time.Duration(c.config.OCPP.ReconnectInterval) * time.Second
+	if reconnect <= 0 {
+		reconnect = defaultReconnectInterval * time.Second
+	}
+	endpoint, err := chargePointURL(c.config.OCPP.CentralSystemURL, cp.pileID, c.config.OCPPPassword(cp.pileID))
+	if err != nil {
+		c.logger.Error("充电桩 %s 中央系统地址无效: %v", cp.pileID, err)
+		return
+	}
+
+	for {
+		conn, err := ocpp.Dial(endpoint, ocpp.Subprotocol, c.callTimeout())
+		if err != nil {
+			c.logger.Error("充电桩 %s 连接中央系统失败: %v", cp.pileID, err)
+		} else {
+			client := ocpp.NewClient(conn, func(action string, payload json.RawMessage) (any, *ocpp.CallError) {
+				return c.handleCall(cp, action, payload)
+			}, c.callTimeout())
+			cp.setClient(client)
+			c.logger.Info("充电桩 %s 已连接中央系统", cp.pileID)
+
+			go c.bootNotification(cp)
+			err = client.Run()
+			cp.setClient(nil)
+			c.logger.Warning("充电桩 %s 与中央系统断开: %v", cp.pileID, err)
+		}
+
+		select {
+		case <-c.stopCh:
+			return
+		case <-time.After(reconnect):
+		}
+	}
+}
+
+// callTimeout 等待中央系统响应的超时时间
+func (c *OCPPClient) callTimeout() time.Duration {
+	if c.config.OCPP.CallTimeout <= 0 {
+		return defaultOCPPCallTimeout * time.Second
+	}
+	return time.Duration(c.config.OCPP.CallTimeout) * time.Second
+}
+
+// bootNotification 连接建立后发送启动通知与当前状态
+func (c *OCPPClient) bootNotification(cp *ocppChargePoint) {
+	var resp ocpp.BootNotificationResponse
+	err := cp.call(ocpp.ActionBootNotification, &ocpp.BootNotificationRequest{
+		ChargePointVendor:       c.config.OCPP.ChargePointVendor,
+		ChargePointModel:        c.config.OCPP.ChargePointModel,
+		ChargePointSerialNumber: cp.pileID,
+	}, &resp)
+	if err != nil {
+		c.logger.Error("充电桩 %s 发送启动通知失败: %v", cp.pileID, err)
+		return
+	}
+	if resp.Status != ocpp.RegistrationAccepted {
+		c.logger.Warning("充电桩 %s 启动通知被拒绝: %s", cp.pileID, resp.Status)
+		return
+	}
+	c.logger.Info("充电桩 %s 启动通知已接受，心跳间隔: %d秒", cp.pileID, resp.Interval)
+
+	pile, err := c.pileService.GetPile(cp.pileID)
+	if err != nil {
+		return
+	}
+	c.sendStatus(cp, ocppPileStatus(pile), ocpp.ErrorCodeNoError, "")
+}
+
+// handleCall 处理中央系统下发的指令
+func (c *OCPPClient) handleCall(cp *ocppChargePoint, action string, payload json.RawMessage) (any, *ocpp.CallError) {
+	switch action {
+	case ocpp.ActionRemoteStartTransaction:
+		var req ocpp.RemoteStartTransactionRequest
+		if err := json.Unmarshal(payload, &req); err != nil {
+			return nil, &ocpp.CallError{Code: ocpp.ErrorFormationViolation, Description: err.Error()}
+		}
+		return c.remoteStart(cp, req.IdTag), nil
+	case ocpp.ActionRemoteStopTransaction:
+		var req ocpp.RemoteStopTransactionRequest
+		if err := json.Unmarshal(payload, &req); err != nil {
+			return nil, &ocpp.CallError{Code: ocpp.ErrorFormationViolation, Description: err.Error()}
+		}
+		return c.remoteStop(cp, req.TransactionID), nil
+	case ocpp.ActionChangeAvailability:
+		var req ocpp.ChangeAvailabilityRequest
+		if err := json.Unmarshal(payload, &req); err != nil {
+			return nil, &ocpp.CallError{Code: ocpp.ErrorFormationViolation, Description: err.Error()}
+		}
+		return c.changeAvailability(cp, req.Type == ocpp.AvailabilityOperative), nil
+	default:
+		return nil, &ocpp.CallError{Code: ocpp.ErrorNotImplemented, Description: "不支持的动作: " + action}
+	}
+}
+
+// remoteStart 处理远程启动，接受后异步完成授权与开始交易
+func (c *OCPPClient) remoteStart(cp *ocppChargePoint, idTag string) *ocpp.StatusResponse {
+	pile, err := c.pileService.GetPile(cp.pileID)
+	if err != nil {
+		return &ocpp.StatusResponse{Status: ocpp.RemoteRejected}
+	}
+	if status, _ := pile.GetStatus(); status != models.PileStatusAvailable {
+		c.logger.Warning("充电桩 %s 当前状态为 %s，拒绝远程启动", cp.pileID, status)
+		return &ocpp.StatusResponse{Status: ocpp.RemoteRejected}
+	}
+
+	go c.startTransaction(cp, pile, idTag)
+	return &ocpp.StatusResponse{Status: ocpp.RemoteAccepted}
+}
+
+// startTransaction 授权、开始充电并上报开始交易
+func (c *OCPPClient) startTransaction(cp *ocppChargePoint, pile *models.Pile, idTag string) {
+	var auth ocpp.AuthorizeResponse
+	if err := cp.call(ocpp.ActionAuthorize, &ocpp.AuthorizeRequest{IdTag: idTag}, &auth); err != nil {
+		c.logger.Error("充电桩 %s 授权失败: %v", cp.pileID, err)
+		return
+	}
+	if auth.IdTagInfo.Status != ocpp.AuthorizationAccepted {
+		c.logger.Warning("充电桩 %s 授权被拒绝: idTag=%s, 状态=%s", cp.pileID, idTag, auth.IdTagInfo.Status)
+		return
+	}
+
+	// 接入车辆：随机起始SoC，充满为止
+	capacity := defaultOCPPBattery
+	if profile := c.config.FindVehicleProfile(""); profile != nil && profile.BatteryCapacity > 0 {
+		capacity = profile.BatteryCapacity
+	}
+	startSoC := utils.RandomFloat(10, 40)
+	battery := &models.BatteryState{Capacity: capacity, StartSoC: startSoC, TargetSoC: 100}
+	amount := capacity * (100 - startSoC) / 100
+
+	mode := string(models.ChargingModeTrickle)
+	if pile.Type == models.PileTypeFast {
+		mode = string(models.ChargingModeFast)
+	}
+	if err := c.pileService.AssignVehicle(cp.pileID, "", idTag, amount, mode, battery); err != nil {
+		c.logger.Error("充电桩 %s 开始充电失败: %v", cp.pileID, err)
+		return
+	}
+
+	cp.mu.Lock()
+	meterStart := cp.meterWh
+	cp.mu.Unlock()
+
+	var resp ocpp.StartTransactionResponse
+	err := cp.call(ocpp.ActionStartTransaction, &ocpp.StartTransactionRequest{
+		ConnectorID: ocppConnectorID,
+		IdTag:       idTag,
+		MeterStart:  meterStart,
+		Timestamp:   time.Now().UTC(),
+	}, &resp)
+	if err != nil || resp.IdTagInfo.Status != ocpp.AuthorizationAccepted {
+		c.logger.Error("充电桩 %s 开始交易失败: %v, 状态=%s", cp.pileID, err, resp.IdTagInfo.Status)
+		c.pileService.StopCharging(cp.pileID, idTag, "开始交易失败")
+		return
+	}
+
+	cp.mu.Lock()
+	cp.transactionID = resp.TransactionID
+	cp.meterStart = meterStart
+	cp.mu.Unlock()
+
+	c.logger.Info("充电桩 %s 开始交易: 交易号=%d, 起始读数=%dWh", cp.pileID, resp.TransactionID, meterStart)
+	c.sendStatus(cp, ocpp.ChargePointCharging, ocpp.ErrorCodeNoError, "")
+}
+
+// remoteStop 处理远程停止，接受后异步停止充电并上报结束交易
+func (c *OCPPClient) remoteStop(cp *ocppChargePoint, transactionID int) *ocpp.StatusResponse {
+	cp.mu.Lock()
+	current := cp.transactionID
+	cp.mu.Unlock()
+	if current == 0 || current != transactionID {
+		c.logger.Warning("充电桩 %s 远程停止的交易号 %d 与当前交易 %d 不符", cp.pileID, transactionID, current)
+		return &ocpp.StatusResponse{Status: ocpp.RemoteRejected}
+	}
+
+	pile, err := c.pileService.GetPile(cp.pileID)
+	if err != nil {
+		return &ocpp.StatusResponse{Status: ocpp.RemoteRejected}
+	}
+	_, vehicle := pile.GetStatus()
+	if vehicle == nil {
+		return &ocpp.StatusResponse{Status: ocpp.RemoteRejected}
+	}
+
+	go func() {
+		if err := c.pileService.StopCharging(cp.pileID, vehicle.UserID, "中央系统远程停止"); err != nil {
+			c.logger.Error("充电桩 %s 远程停止失败: %v", cp.pileID, err)
+			return
+		}
+		c.stopTransaction(cp, vehicle, ocpp.ReasonRemote)
+		c.sendStatus(cp, ocpp.ChargePointFinishing, ocpp.ErrorCodeNoError, "")
+	}()
+	return &ocpp.StatusResponse{Status: ocpp.RemoteAccepted}
+}
+
+// changeAvailability 处理可用性修改
+func (c *OCPPClient) changeAvailability(cp *ocppChargePoint, available bool) *ocpp.StatusResponse {
+	if err := c.pileService.SetPileAvailability(cp.pileID, available); err != nil {
+		c.logger.Warning("充电桩 %s 修改可用性失败: %v", cp.pileID, err)
+		return &ocpp.StatusResponse{Status: ocpp.RemoteRejected}
+	}
+
+	status := ocpp.ChargePointAvailable
+	if !available {
+		status = ocpp.ChargePointUnavailable
+	}
+	go c.sendStatus(cp, status, ocpp.ErrorCodeNoError, "")
+	return &ocpp.StatusResponse{Status: ocpp.RemoteAccepted}
+}
+
+// stopTransaction 上报结束交易并推进电能表读数
+func (c *OCPPClient) stopTransaction(cp *ocppChargePoint, vehicle *models.ChargingVehicle, reason string) {
+	cp.mu.Lock()
+	transactionID := cp.transactionID
+	meterStop := cp.meterStart + int(vehicle.CurrentCapacity*1000)
+	if transactionID != 0 {
+		cp.meterWh = meterStop
+	}
+	cp.transactionID = 0
+	cp.mu.Unlock()
+
+	if transactionID == 0 {
+		return
+	}
+
+	req := &ocpp.StopTransactionRequest{
+		IdTag:         vehicle.UserID,
+		MeterStop:     meterStop,
+		Timestamp:     time.Now().UTC(),
+		TransactionID: transactionID,
+		Reason:        reason,
+	}
+	if vehicle.HasSoC() {
+		req.TransactionData = []ocpp.MeterValue{{
+			Timestamp:    req.Timestamp,
+			SampledValue: []ocpp.SampledValue{socSample(vehicle.CurrentSoC)},
+		}}
+	}
+
+	if err := cp.call(ocpp.ActionStopTransaction, req, &ocpp.StopTransactionResponse{}); err != nil {
+		c.logger.Error("充电桩 %s 上报结束交易失败: %v", cp.pileID, err)
+		return
+	}
+	c.logger.Info("充电桩 %s 结束交易: 交易号=%d, 结束读数=%dWh, 原因=%s", cp.pileID, transactionID, meterStop, reason)
+}
+
+// sendStatus 上报充电桩状态
+func (c *OCPPClient) sendStatus(cp *ocppChargePoint, status, errorCode, info string) {
+	now := time.Now().UTC()
+	err := cp.call(ocpp.ActionStatusNotification, &ocpp.StatusNotificationRequest{
+		ConnectorID: ocppConnectorID,
+		ErrorCode:   errorCode,
+		Status:      status,
+		Info:        truncate(info, 50),
+		Timestamp:   &now,
+	}, nil)
+	if err != nil {
+		c.logger.Error("充电桩 %s 上报状态失败: %v", cp.pileID, err)
+	}
+}
+
+// UpdateChargingProgress 以MeterValues上报充电进度
+func (c *OCPPClient) UpdateChargingProgress(pile *models.Pile) error {
+	cp, err := c.getChargePoint(pile.ID)
+	if err != nil {
+		return err
+	}
+
+	status, vehicle := pile.GetStatus()
+	if status != models.PileStatusCharging || vehicle == nil {
+		return fmt.Errorf("充电桩 %s 当前未在充电", pile.ID)
+	}
+
+	cp.mu.Lock()
+	transactionID := cp.transactionID
+	energy := cp.meterStart + int(vehicle.CurrentCapacity*1000)
+	cp.mu.Unlock()
+
+	req := &ocpp.MeterValuesRequest{ConnectorID: ocppConnectorID}
+	if transactionID != 0 {
+		req.TransactionID = &transactionID
+	}
+	sampled := []ocpp.SampledValue{
+		{Value: strconv.Itoa(energy), Measurand: ocpp.MeasurandEnergyActiveImportRegister, Unit: "Wh"},
+		{Value: strconv.FormatFloat(vehicle.CurrentPower*1000, 'f', 0, 64), Measurand: ocpp.MeasurandPowerActiveImport, Unit: "W"},
+	}
+	if vehicle.HasSoC() {
+		sampled = append(sampled, socSample(vehicle.CurrentSoC))
+	}
+	req.MeterValue = []ocpp.MeterValue{{Timestamp: time.Now().UTC(), SampledValue: sampled}}
+
+	return cp.call(ocpp.ActionMeterValues, req, nil)
+}
+
+// CompleteCharging 车辆充满后上报结束交易
+func (c *OCPPClient) CompleteCharging(pile *models.Pile, vehicle *models.ChargingVehicle) error {
+	cp, err := c.getChargePoint(pile.ID)
+	if err != nil {
+		return err
+	}
+
+	c.stopTransaction(cp, vehicle, ocpp.ReasonEVDisconnected)
+	c.sendStatus(cp, ocpp.ChargePointFinishing, ocpp.ErrorCodeNoError, "")
+	return nil
+}
+
+// ReportVehicleDeparted 车辆拔枪驶离后上报空闲状态
+func (c *OCPPClient) ReportVehicleDeparted(pile *models.Pile, vehicle *models.ChargingVehicle) error {
+	cp, err := c.getChargePoint(pile.ID)
+	if err != nil {
+		return err
+	}
+
+	if status, _ := pile.GetStatus(); status == models.PileStatusAvailable {
+		c.sendStatus(cp, ocpp.ChargePointAvailable, ocpp.ErrorCodeNoError, "")
+	}
+	return nil
+}
+
+// ReportFault 上报故障状态，进行中的交易随之结束；降功率运行时以Power.Offered上报可提供功率，交易继续
+func (c *OCPPClient) ReportFault(pile *models.Pile, faultType models.FaultType, description string) error {
+	cp, err := c.getChargePoint(pile.ID)
+	if err != nil {
+		return err
+	}
+
+	if pile.IsDegraded() {
+		return c.sendOfferedPower(cp, pile.EffectivePower())
+	}
+
+	c.sendStatus(cp, ocpp.ChargePointFaulted, ocppErrorCode(faultType), description)
+
+	if _, vehicle := pile.GetStatus(); vehicle != nil {
+		c.stopTransaction(cp, vehicle, ocpp.ReasonOther)
+	}
+	return nil
+}
+
+// RecoverFault 上报故障恢复，并以Power.Offered上报恢复额定功率
+// 降功率运行期间仍在充电的充电桩保持充电状态
+func (c *OCPPClient) RecoverFault(pile *models.Pile) error {
+	cp, err := c.getChargePoint(pile.ID)
+	if err != nil {
+		return err
+	}
+
+	if status, _ := pile.GetStatus(); status != models.PileStatusCharging {
+		c.sendStatus(cp, ocpp.ChargePointAvailable, ocpp.ErrorCodeNoError, "")
+	}
+	return c.sendOfferedPower(cp, pile.EffectivePower())
+}
+
+// sendOfferedPower 以不关联交易的MeterValues上报充电桩可提供的功率
+func (c *OCPPClient) sendOfferedPower(cp *ocppChargePoint, powerKW float64) error {
+	req := &ocpp.MeterValuesRequest{
+		ConnectorID: ocppConnectorID,
+		MeterValue: []ocpp.MeterValue{{
+			Timestamp: time.Now().UTC(),
+			SampledValue: []ocpp.SampledValue{
+				{Value: strconv.FormatFloat(powerKW*1000, 'f', 0, 64), Measurand: ocpp.MeasurandPowerOffered, Unit: "W"},
+			},
+		}},
+	}
+	return cp.call(ocpp.ActionMeterValues, req, nil)
+}
+
+// SendHeartbeat 为每个已连接的充电桩发送心跳
+func (c *OCPPClient) SendHeartbeat(pileIDs []string) error {
+	var failed []string
+	for _, pileID := range pileIDs {
+		cp, err := c.getChargePoint(pileID)
+		if err != nil || cp.getClient() == nil {
+			continue
+		}
+		if err := cp.call(ocpp.ActionHeartbeat, struct{}{}, &ocpp.HeartbeatResponse{}); err != nil {
+			failed = append(failed, pileID)
+		}
+	}
+
+	if len(failed) > 0 {
+		return fmt.Errorf("充电桩 %s 心跳失败", strings.Join(failed, ","))
+	}
+	return nil
+}
+
+// ReportPileStatus 以StatusNotification上报充电桩当前状态
+func (c *OCPPClient) ReportPileStatus(pile *models.Pile) error {
+	cp, err := c.getChargePoint(pile.ID)
+	if err != nil {
+		return err
+	}
+	if cp.getClient() == nil {
+		return nil
+	}
+
+	status, _ := pile.GetStatus()
+	errorCode := ocpp.ErrorCodeNoError
+	if status == models.PileStatusFault && pile.CurrentFault != nil {
+		errorCode = ocppErrorCode(pile.CurrentFault.Type)
+	}
+	c.sendStatus(cp, ocppPileStatus(pile), errorCode, "")
+	return nil
+}
+
+// getChargePoint 获取充电桩的OCPP连接状态
+func (c *OCPPClient) getChargePoint(pileID string) (*ocppChargePoint, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	cp, exists := c.chargePoints[pileID]
+	if !exists {
+		return nil, fmt.Errorf("充电桩 %s 未启用OCPP", pileID)
+	}
+	return cp, nil
+}
+
+// getClient 获取当前连接
+func (cp *ocppChargePoint) getClient() *ocpp.Client {
+	cp.mu.Lock()
+	defer cp.mu.Unlock()
+	return cp.client
+}
+
+// setClient 设置当前连接
+func (cp *ocppChargePoint) setClient(client *ocpp.Client) {
+	cp.mu.Lock()
+	defer cp.mu.Unlock()
+	cp.client = client
+}
+
+// call 通过当前连接发起Call
+func (cp *ocppChargePoint) call(action string, request, response any) error {
+	client := cp.getClient()
+	if client == nil {
+		return fmt.Errorf("充电桩 %s 未连接中央系统", cp.pileID)
+	}
+	return client.Call(action, request, response)
+}
+
+// ocppStatus 将充电桩状态映射为OCPP连接器状态
+func ocppStatus(status models.PileStatus) string {
+	switch status {
+	case models.PileStatusCharging:
+		return ocpp.ChargePointCharging
+	case models.PileStatusFault:
+		return ocpp.ChargePointFaulted
+	case models.PileStatusAvailable:
+		return ocpp.ChargePointAvailable
+	default:
+		return ocpp.ChargePointUnavailable
+	}
+}
+
+// ocppPileStatus 充电桩的OCPP连接器状态，车辆未驶离时上报Finishing
+func ocppPileStatus(pile *models.Pile) string {
+	status, _ := pile.GetStatus()
+	if status == models.PileStatusAvailable && pile.IsParked() {
+		return ocpp.ChargePointFinishing
+	}
+	return ocppStatus(status)
+}
+
+// ocppErrorCode 将故障类型映射为OCPP错误码
+func ocppErrorCode(faultType models.FaultType) string {
+	switch faultType {
+	case models.FaultTypePower:
+		return ocpp.ErrorCodePowerSwitchFailure
+	case models.FaultTypeSoftware:
+		return ocpp.ErrorCodeInternalError
+	default:
+		return ocpp.ErrorCodeOtherError
+	}
+}
+
+// socSample SoC采样值
+func socSample(soc float64) ocpp.SampledValue {
+	return ocpp.SampledValue{Value: strconv.FormatFloat(soc, 'f', 1, 64), Measurand: ocpp.MeasurandSoC, Unit: "Percent"}
+}
+
+// truncate 按字符截断字符串
+func truncate(s string, n int) string {
+	runes := []rune(s)
+	if len(runes) <= n {
+		return s
+	}
+	return string(runes[:n])
+}
+
+// chargePointURL 充电桩连接地址，以充电桩ID与密码作为HTTP基本认证凭据
+func chargePointURL(centralSystemURL, pileID, password string) (string, error) {
+	u, err := url.Parse(strings.TrimRight(centralSystemURL, "/") + "/" + pileID)
+	if err != nil {
+		return "", err
+	}
+	if password != "" {
+		u.User = url.UserPassword(pileID, password)
+	}
+	return u.String(), nil
+}
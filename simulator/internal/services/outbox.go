@@ -0,0 +1,398 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"simulator/internal/config"
+	"simulator/internal/utils"
+)
+
+// 投递状态
+const (
+	OutboxStatusPending = "pending" // 等待投递或重试
+	OutboxStatusFailed  = "failed"  // 不可重试或超过最大重试次数，移入死信等待人工处理，不阻塞后续上报
+)
+
+// 默认重试参数
+const (
+	defaultOutboxPath           = "data/outbox.json"
+	defaultOutboxInitialBackoff = 1
+	defaultOutboxMaxBackoff     = 300
+	outboxPollInterval          = time.Second
+)
+
+// OutboxEntry 待投递的上报
+type OutboxEntry struct {
+	Seq         int64           `json:"seq"`    // 全局递增序号
+	PileID      string          `json:"pileId"` // 同一充电桩的上报按序号顺序投递
+	Method      string          `json:"method"`
+	Path        string          `json:"path"`
+	Payload     json.RawMessage `json:"payload"`
+	CreatedAt   time.Time       `json:"createdAt"`
+	Attempts    int             `json:"attempts"`
+	NextAttempt time.Time       `json:"nextAttempt"`
+	LastError   string          `json:"lastError,omitempty"`
+	Status      string          `json:"status"`
+}
+
+// outboxFile 持久化文件格式
+type outboxFile struct {
+	NextSeq int64          `json:"nextSeq"`
+	Entries []*OutboxEntry `json:"entries"`
+}
+
+// DeliverFunc 投递一条上报，返回nil表示后端已确认
+type DeliverFunc func(entry *OutboxEntry) error
+
+// PermanentError 不可重试的投递错误
+type PermanentError struct {
+	Err error
+}
+
+// Error 实现error接口
+func (e *PermanentError) Error() string {
+	return e.Err.Error()
+}
+
+// Unwrap 返回原始错误
+func (e *PermanentError) Unwrap() error {
+	return e.Err
+}
+
+// Outbox 持久化发件箱
+// 上报先落盘再异步投递，失败按指数退避重试直到后端确认，模拟器重启后继续投递
+type Outbox struct {
+	path           string
+	initialBackoff time.Duration
+	maxBackoff     time.Duration
+	maxAttempts    int // 0表示不限次数
+
+	nextSeq  int64
+	entries  []*OutboxEntry
+	inFlight map[string]int64 // 充电桩ID -> 正在投递的序号
+
+	deliver DeliverFunc
+	logger  *utils.Logger
+	wakeCh  chan struct{}
+	stopCh  chan struct{}
+	wg      sync.WaitGroup
+	mu      sync.Mutex
+}
+
+// NewOutbox 创建发件箱并加载未完成的上报
+func NewOutbox(cfg *config.Config, logger *utils.Logger) (*Outbox, error) {
+	o := &Outbox{
+		path:           cfg.Outbox.Path,
+		initialBackoff: time.Duration(cfg.Outbox.InitialBackoff) * time.Second,
+		maxBackoff:     time.Duration(cfg.Outbox.MaxBackoff) * time.Second,
+		maxAttempts:    cfg.Outbox.MaxAttempts,
+		nextSeq:        1,
+		inFlight:       make(map[string]int64),
+		logger:         logger,
+		wakeCh:         make(chan struct{}, 1),
+		stopCh:         make(chan struct{}),
+	}
+	if o.path == "" {
+		o.path = defaultOutboxPath
+	}
+	if o.initialBackoff <= 0 {
+		o.initialBackoff = defaultOutboxInitialBackoff * time.Second
+	}
+	if o.maxBackoff <= 0 {
+		o.maxBackoff = defaultOutboxMaxBackoff * time.Second
+	}
+
+	if err := o.load(); err != nil {
+		return nil, err
+	}
+	return o, nil
+}
+
+// load 从文件加载发件箱
+func (o *Outbox) load() error {
+	data, err := os.ReadFile(o.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("读取发件箱失败: %w", err)
+	}
+
+	var file outboxFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return fmt.Errorf("解析发件箱 %s 失败: %w", o.path, err)
+	}
+	if file.NextSeq > o.nextSeq {
+		o.nextSeq = file.NextSeq
+	}
+	o.entries = file.Entries
+
+	if len(o.entries) > 0 {
+		o.logger.Info("发件箱已加载 %d 条未完成的上报", len(o.entries))
+	}
+	return nil
+}
+
+// save 原子写入发件箱文件，调用方持有锁
+func (o *Outbox) save() error {
+	data, err := json.MarshalIndent(outboxFile{NextSeq: o.nextSeq, Entries: o.entries}, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(o.path), 0755); err != nil {
+		return err
+	}
+
+	tmp := o.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, o.path)
+}
+
+// Enqueue 落盘一条上报，返回序号
+func (o *Outbox) Enqueue(pileID, method, path string, payload any) (int64, error) {
+	return o.enqueue(pileID, method, path, payload, false)
+}
+
+// EnqueueLatest 落盘一条上报，并丢弃该充电桩同一路径未投递成功的旧上报（含失败的上报）
+// 用于充电进度等只需最新值的上报，后端不可达时发件箱不会堆积
+func (o *Outbox) EnqueueLatest(pileID, method, path string, payload any) (int64, error) {
+	return o.enqueue(pileID, method, path, payload, true)
+}
+
+// enqueue 落盘一条上报，replace为true时先移除被取代的旧上报
+func (o *Outbox) enqueue(pileID, method, path string, payload any, replace bool) (int64, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return 0, fmt.Errorf("序列化上报数据失败: %w", err)
+	}
+
+	o.mu.Lock()
+	if replace {
+		o.removeSuperseded(pileID, path)
+	}
+	now := time.Now().UTC()
+	entry := &OutboxEntry{
+		Seq:         o.nextSeq,
+		PileID:      pileID,
+		Method:      method,
+		Path:        path,
+		Payload:     data,
+		CreatedAt:   now,
+		NextAttempt: now,
+		Status:      OutboxStatusPending,
+	}
+	o.nextSeq++
+	o.entries = append(o.entries, entry)
+	err = o.save()
+	o.mu.Unlock()
+
+	if err != nil {
+		return 0, fmt.Errorf("保存发件箱失败: %w", err)
+	}
+
+	o.wake()
+	return entry.Seq, nil
+}
+
+// removeSuperseded 移除充电桩同一路径不在投递中的上报（含失败的上报），调用方持有锁
+func (o *Outbox) removeSuperseded(pileID, path string) {
+	kept := o.entries[:0]
+	for _, entry := range o.entries {
+		inFlight, busy := o.inFlight[pileID]
+		superseded := entry.PileID == pileID && entry.Path == path && !(busy && inFlight == entry.Seq)
+		if !superseded {
+			kept = append(kept, entry)
+		}
+	}
+	clear(o.entries[len(kept):])
+	o.entries = kept
+}
+
+// Start 启动投递协程
+func (o *Outbox) Start(deliver DeliverFunc) {
+	o.deliver = deliver
+
+	o.wg.Add(1)
+	go func() {
+		defer o.wg.Done()
+		ticker := time.NewTicker(outboxPollInterval)
+		defer ticker.Stop()
+
+		for {
+			o.dispatch()
+			select {
+			case <-ticker.C:
+			case <-o.wakeCh:
+			case <-o.stopCh:
+				return
+			}
+		}
+	}()
+}
+
+// Stop 停止投递，未完成的上报保留在文件中
+func (o *Outbox) Stop() {
+	close(o.stopCh)
+	o.wg.Wait()
+}
+
+// wake 唤醒投递协程
+func (o *Outbox) wake() {
+	select {
+	case o.wakeCh <- struct{}{}:
+	default:
+	}
+}
+
+// dispatch 为每个充电桩投递序号最小的待投递上报
+// 退避中的上报阻塞该充电桩的后续上报；失败的上报已移入死信，不再阻塞，
+// 否则后端按设计返回的409（会话已结束、故障工单未解决）会卡住该充电桩的完成与故障上报
+func (o *Outbox) dispatch() {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	now := time.Now().UTC()
+	heads := make(map[string]*OutboxEntry)
+	for _, entry := range o.entries {
+		if entry.Status != OutboxStatusPending {
+			continue
+		}
+		if head, exists := heads[entry.PileID]; !exists || entry.Seq < head.Seq {
+			heads[entry.PileID] = entry
+		}
+	}
+
+	for pileID, entry := range heads {
+		if _, busy := o.inFlight[pileID]; busy || entry.NextAttempt.After(now) {
+			continue
+		}
+		o.inFlight[pileID] = entry.Seq
+
+		o.wg.Add(1)
+		go func(entry OutboxEntry) {
+			defer o.wg.Done()
+			err := o.deliver(&entry)
+			o.complete(&entry, err)
+		}(*entry)
+	}
+}
+
+// complete 记录投递结果
+func (o *Outbox) complete(delivered *OutboxEntry, err error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	delete(o.inFlight, delivered.PileID)
+	index := o.indexOf(delivered.Seq)
+	if index < 0 {
+		return
+	}
+	entry := o.entries[index]
+
+	if err == nil {
+		o.entries = append(o.entries[:index], o.entries[index+1:]...)
+		if entry.Attempts > 0 {
+			o.logger.Info("上报 #%d %s 第 %d 次重试后投递成功", entry.Seq, entry.Path, entry.Attempts)
+		}
+	} else {
+		entry.Attempts++
+		entry.LastError = err.Error()
+
+		var permanent *PermanentError
+		if errors.As(err, &permanent) || (o.maxAttempts > 0 && entry.Attempts >= o.maxAttempts) {
+			entry.Status = OutboxStatusFailed
+			o.logger.Error("上报 #%d %s 投递失败，不再重试: %v", entry.Seq, entry.Path, err)
+		} else {
+			backoff := o.backoff(entry.Attempts)
+			entry.NextAttempt = time.Now().UTC().Add(backoff)
+			o.logger.Warning("上报 #%d %s 第 %d 次投递失败，%s后重试: %v",
+				entry.Seq, entry.Path, entry.Attempts, utils.FormatDuration(backoff), err)
+		}
+	}
+
+	if err := o.save(); err != nil {
+		o.logger.Error("保存发件箱失败: %v", err)
+	}
+	o.wake()
+}
+
+// backoff 第n次失败后的退避时间
+func (o *Outbox) backoff(attempts int) time.Duration {
+	backoff := o.initialBackoff
+	for i := 1; i < attempts && backoff < o.maxBackoff; i++ {
+		backoff *= 2
+	}
+	return min(backoff, o.maxBackoff)
+}
+
+// indexOf 按序号查找，调用方持有锁
+func (o *Outbox) indexOf(seq int64) int {
+	for i, entry := range o.entries {
+		if entry.Seq == seq {
+			return i
+		}
+	}
+	return -1
+}
+
+// Entries 返回所有未完成的上报，按序号排序
+func (o *Outbox) Entries() []OutboxEntry {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	entries := make([]OutboxEntry, 0, len(o.entries))
+	for _, entry := range o.entries {
+		entries = append(entries, *entry)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Seq < entries[j].Seq })
+	return entries
+}
+
+// Retry 将失败的上报重新置为待投递，seq为0时重试全部
+func (o *Outbox) Retry(seq int64) (int, error) {
+	o.mu.Lock()
+	count := 0
+	now := time.Now().UTC()
+	for _, entry := range o.entries {
+		if entry.Status != OutboxStatusFailed || (seq != 0 && entry.Seq != seq) {
+			continue
+		}
+		entry.Status = OutboxStatusPending
+		entry.Attempts = 0
+		entry.NextAttempt = now
+		count++
+	}
+	err := o.save()
+	o.mu.Unlock()
+
+	if seq != 0 && count == 0 {
+		return 0, fmt.Errorf("上报 #%d 不存在或不是失败状态", seq)
+	}
+	o.wake()
+	return count, err
+}
+
+// Drop 丢弃一条未完成的上报
+func (o *Outbox) Drop(seq int64) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	index := o.indexOf(seq)
+	if index < 0 {
+		return fmt.Errorf("上报 #%d 不存在", seq)
+	}
+	if inFlight, busy := o.inFlight[o.entries[index].PileID]; busy && inFlight == seq {
+		return fmt.Errorf("上报 #%d 正在投递", seq)
+	}
+	o.entries = append(o.entries[:index], o.entries[index+1:]...)
+	return o.save()
+}
@@ -0,0 +1,306 @@
+package services
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"simulator/internal/config"
+	"simulator/internal/utils"
+)
+
+// newTestOutbox 创建使用临时文件的发件箱
+func newTestOutbox(t *testing.T, maxAttempts int) *Outbox {
+	t.Helper()
+	cfg := &config.Config{}
+	cfg.Outbox.Path = filepath.Join(t.TempDir(), "outbox.json")
+	cfg.Outbox.MaxAttempts = maxAttempts
+
+	o, err := NewOutbox(cfg, utils.NewLogger(utils.LogLevelNone))
+	if err != nil {
+		t.Fatalf("NewOutbox() error = %v", err)
+	}
+	return o
+}
+
+// recorder 记录投递的序号，按序号返回预设的错误
+type recorder struct {
+	mu        sync.Mutex
+	delivered []int64
+	errs      map[int64]error
+}
+
+func (r *recorder) deliver(entry *OutboxEntry) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.delivered = append(r.delivered, entry.Seq)
+	return r.errs[entry.Seq]
+}
+
+// take 返回并清空已投递的序号
+func (r *recorder) take() []int64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delivered := r.delivered
+	r.delivered = nil
+	slices.Sort(delivered)
+	return delivered
+}
+
+// dispatchOnce 执行一轮投递并等待完成
+func dispatchOnce(o *Outbox) {
+	o.dispatch()
+	o.wg.Wait()
+}
+
+func mustEnqueue(t *testing.T, o *Outbox, pileID, path string) int64 {
+	t.Helper()
+	seq, err := o.Enqueue(pileID, "POST", path, map[string]string{"pileId": pileID})
+	if err != nil {
+		t.Fatalf("Enqueue() error = %v", err)
+	}
+	return seq
+}
+
+func TestOutboxDeliversInOrderPerPile(t *testing.T) {
+	o := newTestOutbox(t, 0)
+	r := &recorder{}
+	o.deliver = r.deliver
+
+	a1 := mustEnqueue(t, o, "A", "/a")
+	a2 := mustEnqueue(t, o, "A", "/a")
+	b1 := mustEnqueue(t, o, "B", "/b")
+
+	rounds := [][]int64{{a1, b1}, {a2}, nil}
+	for i, want := range rounds {
+		dispatchOnce(o)
+		if got := r.take(); !slices.Equal(got, want) {
+			t.Fatalf("第%d轮投递 = %v, want %v", i+1, got, want)
+		}
+	}
+	if entries := o.Entries(); len(entries) != 0 {
+		t.Errorf("投递完成后剩余 %d 条上报", len(entries))
+	}
+}
+
+func TestOutboxFailureHandling(t *testing.T) {
+	errTemporary := errors.New("connection refused")
+
+	tests := []struct {
+		name         string
+		maxAttempts  int
+		err          error
+		wantStatus   string
+		wantAttempts int
+		wantBackoff  bool
+	}{
+		{name: "临时错误退避重试", err: errTemporary, wantStatus: OutboxStatusPending, wantAttempts: 1, wantBackoff: true},
+		{name: "不可重试错误", err: &PermanentError{Err: errTemporary}, wantStatus: OutboxStatusFailed, wantAttempts: 1},
+		{name: "超过最大投递次数", maxAttempts: 1, err: errTemporary, wantStatus: OutboxStatusFailed, wantAttempts: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o := newTestOutbox(t, tt.maxAttempts)
+			first := mustEnqueue(t, o, "A", "/a")
+			second := mustEnqueue(t, o, "A", "/a")
+			r := &recorder{errs: map[int64]error{first: tt.err}}
+			o.deliver = r.deliver
+
+			dispatchOnce(o)
+			if got := r.take(); !slices.Equal(got, []int64{first}) {
+				t.Fatalf("首轮投递 = %v, want [%d]", got, first)
+			}
+
+			entries := o.Entries()
+			if len(entries) != 2 {
+				t.Fatalf("剩余上报 = %d, want 2", len(entries))
+			}
+			head := entries[0]
+			if head.Status != tt.wantStatus || head.Attempts != tt.wantAttempts {
+				t.Errorf("上报状态 = %s/%d, want %s/%d", head.Status, head.Attempts, tt.wantStatus, tt.wantAttempts)
+			}
+			if head.LastError == "" {
+				t.Error("未记录失败原因")
+			}
+			if tt.wantBackoff && !head.NextAttempt.After(time.Now()) {
+				t.Error("重试时间未推后")
+			}
+
+			// 退避中的上报阻塞同一充电桩的后续上报，失败的上报移入死信不再阻塞
+			var want []int64
+			if tt.wantStatus == OutboxStatusFailed {
+				want = []int64{second}
+			}
+			dispatchOnce(o)
+			if got := r.take(); !slices.Equal(got, want) {
+				t.Errorf("第二轮投递 = %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestOutboxRetryAndDrop(t *testing.T) {
+	newFailed := func(t *testing.T) (*Outbox, *recorder, int64, int64) {
+		o := newTestOutbox(t, 0)
+		first := mustEnqueue(t, o, "A", "/a")
+		second := mustEnqueue(t, o, "A", "/a")
+		r := &recorder{errs: map[int64]error{first: &PermanentError{Err: errors.New("bad request")}}}
+		o.deliver = r.deliver
+		dispatchOnce(o)
+		r.take()
+		delete(r.errs, first)
+		return o, r, first, second
+	}
+
+	t.Run("重试失败的上报", func(t *testing.T) {
+		o, r, first, _ := newFailed(t)
+		if count, err := o.Retry(first); err != nil || count != 1 {
+			t.Fatalf("Retry() = %d, %v", count, err)
+		}
+		dispatchOnce(o)
+		if got := r.take(); !slices.Equal(got, []int64{first}) {
+			t.Errorf("投递 = %v, want [%d]", got, first)
+		}
+	})
+
+	t.Run("丢弃失败的上报", func(t *testing.T) {
+		o, _, first, _ := newFailed(t)
+		if err := o.Drop(first); err != nil {
+			t.Fatalf("Drop() error = %v", err)
+		}
+		if entries := o.Entries(); len(entries) != 1 {
+			t.Errorf("剩余上报 = %d, want 1", len(entries))
+		}
+	})
+
+	t.Run("重试非失败的上报", func(t *testing.T) {
+		o, _, _, second := newFailed(t)
+		if _, err := o.Retry(second); err == nil {
+			t.Error("Retry() 待投递的上报应返回错误")
+		}
+	})
+
+	t.Run("丢弃不存在的上报", func(t *testing.T) {
+		o, _, _, _ := newFailed(t)
+		if err := o.Drop(999); err == nil {
+			t.Error("Drop() 不存在的上报应返回错误")
+		}
+	})
+}
+
+func TestOutboxEnqueueLatest(t *testing.T) {
+	o := newTestOutbox(t, 0)
+
+	failed := mustEnqueue(t, o, "A", "/progress")
+	o.deliver = (&recorder{errs: map[int64]error{failed: &PermanentError{Err: errors.New("bad request")}}}).deliver
+	dispatchOnce(o)
+
+	other := mustEnqueue(t, o, "A", "/other")
+	for range 3 {
+		if _, err := o.EnqueueLatest("A", "POST", "/progress", nil); err != nil {
+			t.Fatalf("EnqueueLatest() error = %v", err)
+		}
+	}
+	latest, _ := o.EnqueueLatest("A", "POST", "/progress", nil)
+	otherPile, _ := o.EnqueueLatest("B", "POST", "/progress", nil)
+
+	var got []int64
+	for _, entry := range o.Entries() {
+		got = append(got, entry.Seq)
+	}
+	// 同一充电桩同一路径只保留最新一条，失败的旧上报也被取代
+	if want := []int64{other, latest, otherPile}; !slices.Equal(got, want) {
+		t.Errorf("剩余上报 = %v, want %v (failed=%d)", got, want, failed)
+	}
+}
+
+func TestOutboxConflictDoesNotBlockPile(t *testing.T) {
+	var mu sync.Mutex
+	var received []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		received = append(received, r.URL.Path)
+		mu.Unlock()
+		if r.URL.Path == "/api/v1/simulator/charging-progress" {
+			w.WriteHeader(http.StatusConflict)
+			w.Write([]byte(`{"message":"会话已结束"}`))
+		}
+	}))
+	defer server.Close()
+
+	o := newTestOutbox(t, 0)
+	cfg := &config.Config{}
+	cfg.BackendAPI.BaseURL = server.URL
+	client := NewAPIClient(cfg, utils.NewLogger(utils.LogLevelNone))
+	client.SetOutbox(o)
+	o.deliver = client.Deliver
+
+	if err := client.sendRequest("POST", "/api/v1/simulator/charging-progress", "A", map[string]float64{"soc": 50}); err != nil {
+		t.Fatalf("sendRequest(progress) error = %v", err)
+	}
+	if err := client.sendRequest("POST", "/api/v1/simulator/charging-complete", "A", map[string]float64{"soc": 80}); err != nil {
+		t.Fatalf("sendRequest(complete) error = %v", err)
+	}
+
+	dispatchOnce(o)
+	dispatchOnce(o)
+
+	want := []string{"/api/v1/simulator/charging-progress", "/api/v1/simulator/charging-complete"}
+	mu.Lock()
+	defer mu.Unlock()
+	if !slices.Equal(received, want) {
+		t.Fatalf("后端收到 = %v, want %v", received, want)
+	}
+
+	entries := o.Entries()
+	if len(entries) != 1 || entries[0].Path != want[0] || entries[0].Status != OutboxStatusFailed {
+		t.Errorf("剩余上报 = %+v, want 仅失败的进度上报", entries)
+	}
+}
+
+func TestOutboxReload(t *testing.T) {
+	o := newTestOutbox(t, 0)
+	mustEnqueue(t, o, "A", "/a")
+	mustEnqueue(t, o, "B", "/b")
+
+	cfg := &config.Config{}
+	cfg.Outbox.Path = o.path
+	reloaded, err := NewOutbox(cfg, utils.NewLogger(utils.LogLevelNone))
+	if err != nil {
+		t.Fatalf("NewOutbox() error = %v", err)
+	}
+	if got := len(reloaded.Entries()); got != 2 {
+		t.Errorf("重新加载后上报数 = %d, want 2", got)
+	}
+	if seq := mustEnqueue(t, reloaded, "A", "/a"); seq != 3 {
+		t.Errorf("重新加载后序号 = %d, want 3", seq)
+	}
+}
+
+func TestOutboxBackoff(t *testing.T) {
+	o := &Outbox{initialBackoff: time.Second, maxBackoff: 30 * time.Second}
+
+	tests := []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{1, time.Second},
+		{2, 2 * time.Second},
+		{3, 4 * time.Second},
+		{5, 16 * time.Second},
+		{6, 30 * time.Second},
+		{100, 30 * time.Second},
+	}
+
+	for _, tt := range tests {
+		if got := o.backoff(tt.attempts); got != tt.want {
+			t.Errorf("backoff(%d) = %v, want %v", tt.attempts, got, tt.want)
+		}
+	}
+}
@@ -14,7 +14,7 @@ import (
 // PileService 充电桩服务
 type PileService struct {
 	Piles     map[string]*models.Pile
-	apiClient *APIClient
+	reporter  BackendReporter
 	config    *config.Config
 	logger    *utils.Logger
 	simTimer  *utils.SimulationTimer
@@ -23,10 +23,10 @@ type PileService struct {
 }
 
 // NewPileService 创建充电桩服务
-func NewPileService(cfg *config.Config, apiClient *APIClient, logger *utils.Logger) *PileService {
+func NewPileService(cfg *config.Config, reporter BackendReporter, logger *utils.Logger) *PileService {
 	return &PileService{
 		Piles:     make(map[string]*models.Pile),
-		apiClient: apiClient,
+		reporter:  reporter,
 		config:    cfg,
 		logger:    logger,
 		simTimer:  utils.NewSimulationTimer(cfg.Simulation.SpeedFactor),
@@ -41,7 +41,7 @@ func (s *PileService) InitializePiles() {
 
 	// 初始化快充桩
 	for i := 1; i <= s.config.Piles.Fast.Count; i++ {
-		id := fmt.Sprintf("F%d", i)
+		id := fmt.Sprintf("%s%d", s.config.FastPilePrefix(), i)
 		pile := models.NewPile(id, models.PileTypeFast, s.config.Piles.Fast.Power)
 		s.Piles[id] = pile
 		s.logger.Info("初始化快充桩: %s, 功率: %.1fkW", id, pile.Power)
@@ -49,7 +49,7 @@ func (s *PileService) InitializePiles() {
 
 	// 初始化慢充桩
 	for i := 1; i <= s.config.Piles.Trickle.Count; i++ {
-		id := fmt.Sprintf("T%d", i)
+		id := fmt.Sprintf("%s%d", s.config.TricklePilePrefix(), i)
 		pile := models.NewPile(id, models.PileTypeTrickle, s.config.Piles.Trickle.Power)
 		s.Piles[id] = pile
 		s.logger.Info("初始化慢充桩: %s, 功率: %.1fkW", id, pile.Power)
@@ -57,7 +57,8 @@ func (s *PileService) InitializePiles() {
 }
 
 // AssignVehicle 分配车辆到充电桩
-func (s *PileService) AssignVehicle(pileID, userID string, amount float64, chargingMode string) error {
+// sessionID 为后端下发的充电会话ID，手动模拟时为空；battery 为nil时不跟踪SoC
+func (s *PileService) AssignVehicle(pileID, sessionID, userID string, amount float64, chargingMode string, battery *models.BatteryState) error {
 	s.mu.Lock()
 
 	pile, exists := s.Piles[pileID]
@@ -71,14 +72,32 @@ func (s *PileService) AssignVehicle(pileID, userID string, amount float64, charg
 		return fmt.Errorf("充电桩 %s 当前不可用", pileID)
 	}
 
+	// 后端已分配下一辆车，仍停留的车辆先驶离
+	if parked := pile.Depart(nil); parked != nil {
+		s.reportDeparture(pile, parked)
+	}
+
 	// 创建充电车辆
 	vehicle := &models.ChargingVehicle{
+		SessionID:         sessionID,
 		UserID:            userID,
 		StartTime:         time.Now().UTC(),
 		RequestedCapacity: amount,
 		CurrentCapacity:   0,
 		ChargingMode:      chargingMode,
 	}
+	profileName := ""
+	if battery != nil {
+		profileName = battery.Profile
+		if battery.Capacity > 0 {
+			vehicle.BatteryCapacity = battery.Capacity
+			vehicle.StartSoC = battery.StartSoC
+			vehicle.CurrentSoC = battery.StartSoC
+			vehicle.TargetSoC = battery.TargetSoC
+		}
+	}
+	s.applyVehicleProfile(vehicle, profileName)
+	vehicle.RefreshPower(pile.EffectivePower())
 
 	// 开始充电
 	if !pile.StartCharging(vehicle) {
@@ -86,7 +105,12 @@ func (s *PileService) AssignVehicle(pileID, userID string, amount float64, charg
 		return fmt.Errorf("充电桩 %s 启动充电失败", pileID)
 	}
 
-	s.logger.Info("用户 %s 开始在充电桩 %s 充电，请求电量: %.1fkWh", userID, pileID, amount)
+	if vehicle.HasSoC() {
+		s.logger.Info("用户 %s 开始在充电桩 %s 充电，请求电量: %.1fkWh，SoC: %.1f%% -> %.1f%%，车辆配置: %s",
+			userID, pileID, amount, vehicle.StartSoC, vehicle.TargetSoC, vehicle.Profile)
+	} else {
+		s.logger.Info("用户 %s 开始在充电桩 %s 充电，请求电量: %.1fkWh", userID, pileID, amount)
+	}
 
 	// 释放锁后再启动充电模拟，避免死锁
 	s.mu.Unlock()
@@ -128,7 +152,7 @@ func (s *PileService) startChargingSimulation(pile *models.Pile) {
 				pile.UpdateChargingProgress(elapsed)
 
 				// 上报充电进度
-				if err := s.apiClient.UpdateChargingProgress(pile); err != nil {
+				if err := s.reporter.UpdateChargingProgress(pile); err != nil {
 					s.logger.Error("上报充电进度失败: %v", err)
 				}
 
@@ -140,6 +164,11 @@ func (s *PileService) startChargingSimulation(pile *models.Pile) {
 
 				// 随机故障处理
 				if s.config.Fault.RandomFault && rand.Float64() < s.config.Fault.FaultChance/100.0 {
+					// 部分故障只损坏功率模块，充电桩降功率继续充电
+					if !pile.IsDegraded() && rand.Float64() < s.config.Fault.DegradeChance {
+						s.randomDegradation(pile)
+						continue
+					}
 					s.randomFault(pile)
 					return
 				}
@@ -173,9 +202,55 @@ func (s *PileService) completeCharging(pile *models.Pile) {
 		vehicle.UserID, pile.ID, vehicle.CurrentCapacity)
 
 	// 上报充电完成
-	if err := s.apiClient.CompleteCharging(pile, vehicle); err != nil {
+	if err := s.reporter.CompleteCharging(pile, vehicle); err != nil {
 		s.logger.Error("上报充电完成失败: %v", err)
 	}
+
+	s.scheduleDeparture(pile, vehicle)
+}
+
+// scheduleDeparture 充电结束后车辆停留随机时间再驶离，manual模式下等待depart命令
+func (s *PileService) scheduleDeparture(pile *models.Pile, vehicle *models.ChargingVehicle) {
+	if s.config.Departure.Manual {
+		s.logger.Info("充电桩 %s 的车辆等待手动驶离", pile.ID)
+		return
+	}
+
+	delay := time.Duration(utils.RandomInt(s.config.Departure.MinDelay, s.config.Departure.MaxDelay)) * time.Second
+	s.logger.Info("充电桩 %s 的车辆将在 %d秒 后驶离", pile.ID, int(delay.Seconds()))
+
+	go func() {
+		s.simTimer.Sleep(delay)
+
+		// 期间已手动驶离或被下一辆车替换时不再处理
+		if departed := pile.Depart(vehicle); departed != nil {
+			s.reportDeparture(pile, departed)
+		}
+	}()
+}
+
+// DepartVehicle 手动让停在充电桩的车辆驶离
+func (s *PileService) DepartVehicle(pileID string) error {
+	pile, err := s.GetPile(pileID)
+	if err != nil {
+		return err
+	}
+
+	vehicle := pile.Depart(nil)
+	if vehicle == nil {
+		return fmt.Errorf("充电桩 %s 没有等待驶离的车辆", pileID)
+	}
+	s.reportDeparture(pile, vehicle)
+	return nil
+}
+
+// reportDeparture 上报车辆驶离
+func (s *PileService) reportDeparture(pile *models.Pile, vehicle *models.ChargingVehicle) {
+	s.logger.Info("用户 %s 的车辆驶离充电桩 %s", vehicle.UserID, pile.ID)
+
+	if err := s.reporter.ReportVehicleDeparted(pile, vehicle); err != nil {
+		s.logger.Error("上报车辆驶离失败: %v", err)
+	}
 }
 
 // randomFault 随机故障模拟
@@ -210,7 +285,7 @@ func (s *PileService) randomFault(pile *models.Pile) {
 		pile.ID, faultType, int(faultDuration.Minutes()))
 
 	// 上报故障
-	if err := s.apiClient.ReportFault(pile, faultType, description); err != nil {
+	if err := s.reporter.ReportFault(pile, faultType, description); err != nil {
 		s.logger.Error("上报故障失败: %v", err)
 	}
 
@@ -224,12 +299,80 @@ func (s *PileService) randomFault(pile *models.Pile) {
 		s.logger.Info("充电桩 %s 故障已恢复", pile.ID)
 
 		// 上报故障恢复
-		if err := s.apiClient.RecoverFault(pile); err != nil {
+		if err := s.reporter.RecoverFault(pile); err != nil {
 			s.logger.Error("上报故障恢复失败: %v", err)
 		}
 	}(pile, faultDuration)
 }
 
+// randomDegradation 随机降功率运行模拟
+func (s *PileService) randomDegradation(pile *models.Pile) {
+	minTime := s.config.Fault.MinFaultTime
+	maxTime := s.config.Fault.MaxFaultTime
+	duration := time.Duration(rand.Intn(maxTime-minTime+1)+minTime) * time.Minute
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.degrade(pile, models.FaultTypeHardware, "功率模块故障 - 随机生成", s.config.DegradedPower(pile.Power), duration)
+}
+
+// TriggerDegradation 手动触发降功率运行，power 为降低后的功率(kW)
+func (s *PileService) TriggerDegradation(pileID string, faultType models.FaultType, description string, power float64, duration time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	pile, exists := s.Piles[pileID]
+	if !exists {
+		return fmt.Errorf("充电桩 %s 不存在", pileID)
+	}
+
+	if pile.Status == models.PileStatusFault {
+		return fmt.Errorf("充电桩 %s 当前处于故障状态", pileID)
+	}
+	if power <= 0 {
+		power = s.config.DegradedPower(pile.Power)
+	}
+	if power >= pile.Power {
+		return fmt.Errorf("降功率运行的功率须小于额定功率 %.1fkW", pile.Power)
+	}
+
+	s.degrade(pile, faultType, description, power, duration)
+	return nil
+}
+
+// degrade 充电桩降功率运行并上报，到期后恢复额定功率，调用方需持有锁
+func (s *PileService) degrade(pile *models.Pile, faultType models.FaultType, description string, power float64, duration time.Duration) {
+	pile.Degrade(faultType, description, power, duration)
+
+	s.logger.Warning("充电桩 %s 发生%s故障，降功率运行: %.1fkW -> %.1fkW，预计恢复时间: %d分钟后",
+		pile.ID, faultType, pile.Power, power, int(duration.Minutes()))
+
+	// 上报降功率运行
+	if err := s.reporter.ReportFault(pile, faultType, description); err != nil {
+		s.logger.Error("上报降功率运行失败: %v", err)
+	}
+
+	go func(pile *models.Pile, duration time.Duration) {
+		time.Sleep(duration)
+
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		// 期间已完全故障或手动恢复时不再处理
+		if pile.Status == models.PileStatusFault || !pile.IsDegraded() {
+			return
+		}
+		pile.RecoverFromFault()
+		s.logger.Info("充电桩 %s 已恢复额定功率", pile.ID)
+
+		// 上报恢复
+		if err := s.reporter.RecoverFault(pile); err != nil {
+			s.logger.Error("上报故障恢复失败: %v", err)
+		}
+	}(pile, duration)
+}
+
 // TriggerFault 手动触发故障
 func (s *PileService) TriggerFault(pileID string, faultType models.FaultType, description string, duration time.Duration) error {
 	s.mu.Lock()
@@ -253,7 +396,7 @@ func (s *PileService) TriggerFault(pileID string, faultType models.FaultType, de
 		pileID, faultType, int(duration.Minutes()))
 
 	// 上报故障
-	if err := s.apiClient.ReportFault(pile, faultType, description); err != nil {
+	if err := s.reporter.ReportFault(pile, faultType, description); err != nil {
 		s.logger.Error("上报故障失败: %v", err)
 	}
 	// 启动故障恢复定时器
@@ -267,7 +410,7 @@ func (s *PileService) TriggerFault(pileID string, faultType models.FaultType, de
 		s.logger.Info("充电桩 %s 故障已恢复", pile.ID)
 
 		// 上报故障恢复
-		if err := s.apiClient.RecoverFault(pile); err != nil {
+		if err := s.reporter.RecoverFault(pile); err != nil {
 			s.logger.Error("上报故障恢复失败: %v", err)
 		}
 	}(pile, duration)
@@ -285,14 +428,14 @@ func (s *PileService) RecoverFault(pileID string) error {
 		return fmt.Errorf("充电桩 %s 不存在", pileID)
 	}
 
-	if pile.Status != models.PileStatusFault {
+	if pile.Status != models.PileStatusFault && !pile.IsDegraded() {
 		return fmt.Errorf("充电桩 %s 当前不是故障状态", pileID)
 	}
 	pile.RecoverFromFault()
 	s.logger.Info("充电桩 %s 故障已手动恢复", pileID)
 
 	// 上报故障恢复
-	if err := s.apiClient.RecoverFault(pile); err != nil {
+	if err := s.reporter.RecoverFault(pile); err != nil {
 		s.logger.Error("上报故障恢复失败: %v", err)
 	}
 
@@ -311,6 +454,26 @@ func (s *PileService) StartHeartbeat() {
 	s.logger.Info("启动心跳检测，间隔: %d秒", s.config.BackendAPI.HeartbeatInterval)
 }
 
+// StartStatusReport 按statusInterval定期上报每个充电桩的状态，供后端对账
+func (s *PileService) StartStatusReport() {
+	if s.config.BackendAPI.StatusInterval <= 0 {
+		return
+	}
+
+	interval := time.Duration(s.config.BackendAPI.StatusInterval) * time.Second
+	go func() {
+		ticker := time.NewTicker(interval)
+		for range ticker.C {
+			for _, pile := range s.GetAllPiles() {
+				if err := s.reporter.ReportPileStatus(pile); err != nil {
+					s.logger.Warning("上报充电桩 %s 状态失败: %v", pile.ID, err)
+				}
+			}
+		}
+	}()
+	s.logger.Info("启动状态上报，间隔: %d秒", s.config.BackendAPI.StatusInterval)
+}
+
 // sendHeartbeat 发送心跳
 func (s *PileService) sendHeartbeat() {
 	s.mu.Lock()
@@ -320,7 +483,7 @@ func (s *PileService) sendHeartbeat() {
 	}
 	s.mu.Unlock()
 
-	if err := s.apiClient.SendHeartbeat(pileIDs); err != nil {
+	if err := s.reporter.SendHeartbeat(pileIDs); err != nil {
 		s.logger.Error("发送心跳失败: %v", err)
 	}
 }
@@ -360,6 +523,26 @@ func (s *PileService) StopCharging(pileID, userID string, reason string) error {
 	s.logger.Info("用户 %s 在充电桩 %s 的充电被停止，原因: %s，已充电量: %.1fkWh",
 		userID, pileID, reason, stoppedVehicle.CurrentCapacity)
 
+	s.scheduleDeparture(pile, stoppedVehicle)
+	return nil
+}
+
+// SetPileAvailability 设置充电桩可用性，充电中或故障时无法修改
+func (s *PileService) SetPileAvailability(pileID string, available bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	pile, exists := s.Piles[pileID]
+	if !exists {
+		return fmt.Errorf("充电桩 %s 不存在", pileID)
+	}
+
+	if !pile.SetAvailable(available) {
+		status, _ := pile.GetStatus()
+		return fmt.Errorf("充电桩 %s 当前状态为 %s，无法修改可用性", pileID, status)
+	}
+
+	s.logger.Info("充电桩 %s 可用性已修改为: %v", pileID, available)
 	return nil
 }
 
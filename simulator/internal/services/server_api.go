@@ -1,16 +1,22 @@
 package services
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
+	"io"
 	"net/http"
 	"sync"
 	"time"
 
 	"simulator/internal/config"
+	"simulator/internal/models"
 	"simulator/internal/utils"
 )
 
+// commandRetention 已执行指令的保留时间，需长于后端的指令确认超时
+const commandRetention = time.Hour
+
 // ServerAPI 模拟器服务器端API
 type ServerAPI struct {
 	server           *http.Server
@@ -19,7 +25,9 @@ type ServerAPI struct {
 	logger           *utils.Logger
 	handlers         map[string]http.HandlerFunc
 	mu               sync.Mutex
-	onChargingAssign func(pileID, userID string, amount float64, mode string) error
+	chaos            *ChaosInjector       // 网络混沌注入，为nil时不注入
+	commands         map[string]time.Time // 已确认的指令ID -> 确认时间，用于去重重试的指令
+	onChargingAssign func(pileID, sessionID, userID string, amount float64, mode string, battery *models.BatteryState) error
 }
 
 // NewServerAPI 创建模拟器服务器API
@@ -29,6 +37,7 @@ func NewServerAPI(cfg *config.Config, pileService *PileService, logger *utils.Lo
 		pileService: pileService,
 		logger:      logger,
 		handlers:    make(map[string]http.HandlerFunc),
+		commands:    make(map[string]time.Time),
 	}
 
 	// 注册处理函数
@@ -40,24 +49,56 @@ func NewServerAPI(cfg *config.Config, pileService *PileService, logger *utils.Lo
 // registerHandlers 注册HTTP处理函数
 func (api *ServerAPI) registerHandlers() {
 	// 充电指令接收API
-	api.handlers["/api/simulator/charging/assign"] = api.handleChargingAssign
-	api.handlers["/api/simulator/charging/stop"] = api.handleChargingStop
+	api.handlers["/api/simulator/charging/assign"] = api.withChaos(api.handleChargingAssign)
+	api.handlers["/api/simulator/charging/stop"] = api.withChaos(api.handleChargingStop)
 	api.handlers["/api/simulator/status"] = api.handleStatus
 }
 
 // 充电分配请求结构
 type ChargingAssignRequest struct {
-	PileID            string  `json:"pileId"`            // 充电桩ID
-	UserID            string  `json:"userId"`            // 用户ID
-	RequestedCapacity float64 `json:"requestedCapacity"` // 请求充电量
-	ChargingMode      string  `json:"chargingMode"`      // 充电模式
+	CommandID         string   `json:"commandId,omitempty"`       // 后端指令ID，重试时不变
+	SessionID         string   `json:"sessionId,omitempty"`       // 后端充电会话ID
+	PileID            string   `json:"pileId"`                    // 充电桩ID
+	UserID            string   `json:"userId"`                    // 用户ID
+	RequestedCapacity float64  `json:"requestedCapacity"`         // 请求充电量
+	ChargingMode      string   `json:"chargingMode"`              // 充电模式
+	BatteryCapacity   float64  `json:"batteryCapacity,omitempty"` // 车辆电池容量(kWh)
+	StartSoC          *float64 `json:"startSoc,omitempty"`        // 起始电量百分比
+	TargetSoC         *float64 `json:"targetSoc,omitempty"`       // 目标电量百分比
+	VehicleProfile    string   `json:"vehicleProfile,omitempty"`  // 车辆配置名称，为空时使用默认配置
+}
+
+// batteryState 提取请求中的电池状态
+func (req *ChargingAssignRequest) batteryState() *models.BatteryState {
+	if req.BatteryCapacity <= 0 {
+		if req.VehicleProfile == "" {
+			return nil
+		}
+		return &models.BatteryState{Profile: req.VehicleProfile}
+	}
+	battery := &models.BatteryState{Capacity: req.BatteryCapacity, TargetSoC: 100, Profile: req.VehicleProfile}
+	if req.StartSoC != nil {
+		battery.StartSoC = *req.StartSoC
+	} else {
+		// 未提供起始SoC时按请求电量反推
+		battery.StartSoC = 100 - req.RequestedCapacity/req.BatteryCapacity*100
+		if battery.StartSoC < 0 {
+			battery.StartSoC = 0
+		}
+	}
+	if req.TargetSoC != nil {
+		battery.TargetSoC = *req.TargetSoC
+	}
+	return battery
 }
 
 // 停止充电请求结构
 type ChargingStopRequest struct {
-	PileID string `json:"pileId"` // 充电桩ID
-	UserID string `json:"userId"` // 用户ID
-	Reason string `json:"reason"` // 停止原因
+	CommandID string `json:"commandId,omitempty"` // 后端指令ID，重试时不变
+	SessionID string `json:"sessionId,omitempty"` // 要停止的充电会话ID
+	PileID    string `json:"pileId"`              // 充电桩ID
+	UserID    string `json:"userId"`              // 用户ID
+	Reason    string `json:"reason"`              // 停止原因
 }
 
 // 处理充电分配
@@ -82,13 +123,20 @@ func (api *ServerAPI) handleChargingAssign(w http.ResponseWriter, r *http.Reques
 	api.logger.Info("接收到充电分配请求: 充电桩=%s, 用户=%s, 电量=%.1f, 模式=%s",
 		req.PileID, req.UserID, req.RequestedCapacity, req.ChargingMode)
 
+	// 后端未收到确认而重试的指令，直接确认
+	if api.isCommandAcknowledged(req.CommandID) {
+		api.logger.Info("重复的充电分配指令 %s，直接确认", req.CommandID)
+		api.writeCommandResponse(w, "充电分配成功")
+		return
+	}
+
 	// 调用回调函数
 	var err error
 	if api.onChargingAssign != nil {
-		err = api.onChargingAssign(req.PileID, req.UserID, req.RequestedCapacity, req.ChargingMode)
+		err = api.onChargingAssign(req.PileID, req.SessionID, req.UserID, req.RequestedCapacity, req.ChargingMode, req.batteryState())
 	} else {
 		// 默认实现，直接调用充电桩服务
-		err = api.pileService.AssignVehicle(req.PileID, req.UserID, req.RequestedCapacity, req.ChargingMode)
+		err = api.pileService.AssignVehicle(req.PileID, req.SessionID, req.UserID, req.RequestedCapacity, req.ChargingMode, req.batteryState())
 	}
 
 	if err != nil {
@@ -96,20 +144,8 @@ func (api *ServerAPI) handleChargingAssign(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
-	// 构造响应
-	response := struct {
-		Code      int    `json:"code"`
-		Message   string `json:"message"`
-		Timestamp int64  `json:"timestamp"`
-	}{
-		Code:      200,
-		Message:   "充电分配成功",
-		Timestamp: time.Now().UTC().Unix(),
-	}
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(response)
-	api.logger.Info("发送充电分配响应: %v", response)
+	api.acknowledgeCommand(req.CommandID)
+	api.writeCommandResponse(w, "充电分配成功")
 }
 
 // 处理停止充电
@@ -134,6 +170,25 @@ func (api *ServerAPI) handleChargingStop(w http.ResponseWriter, r *http.Request)
 	api.logger.Info("接收到停止充电请求: 充电桩=%s, 用户=%s, 原因=%s",
 		req.PileID, req.UserID, req.Reason)
 
+	// 后端未收到确认而重试的指令，直接确认
+	if api.isCommandAcknowledged(req.CommandID) {
+		api.logger.Info("重复的停止充电指令 %s，直接确认", req.CommandID)
+		api.writeCommandResponse(w, "停止充电成功")
+		return
+	}
+
+	// 指定会话已不在充电（已完成或分配指令未送达），停止指令视为已执行
+	if req.SessionID != "" {
+		if pile, err := api.pileService.GetPile(req.PileID); err == nil {
+			if _, vehicle := pile.GetStatus(); vehicle == nil || vehicle.SessionID != req.SessionID {
+				api.logger.Info("充电桩 %s 上的会话 %s 已结束，无需停止", req.PileID, req.SessionID)
+				api.acknowledgeCommand(req.CommandID)
+				api.writeCommandResponse(w, "充电已结束")
+				return
+			}
+		}
+	}
+
 	// 调用充电桩服务停止充电
 	err := api.pileService.StopCharging(req.PileID, req.UserID, req.Reason)
 	if err != nil {
@@ -141,21 +196,54 @@ func (api *ServerAPI) handleChargingStop(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	// 构造响应
+	api.acknowledgeCommand(req.CommandID)
+	api.writeCommandResponse(w, "停止充电成功")
+}
+
+// writeCommandResponse 返回指令执行成功
+func (api *ServerAPI) writeCommandResponse(w http.ResponseWriter, message string) {
 	response := struct {
 		Code      int    `json:"code"`
 		Message   string `json:"message"`
 		Timestamp int64  `json:"timestamp"`
 	}{
 		Code:      200,
-		Message:   "停止充电成功",
+		Message:   message,
 		Timestamp: time.Now().UTC().Unix(),
 	}
 
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(response)
-	api.logger.Info("发送停止充电响应: %v", response)
+	api.logger.Info("发送指令响应: %v", response)
+}
+
+// isCommandAcknowledged 指令是否已执行过
+func (api *ServerAPI) isCommandAcknowledged(commandID string) bool {
+	if commandID == "" {
+		return false
+	}
+	api.mu.Lock()
+	defer api.mu.Unlock()
+	_, exists := api.commands[commandID]
+	return exists
+}
+
+// acknowledgeCommand 记录已执行的指令，并清理过期记录
+func (api *ServerAPI) acknowledgeCommand(commandID string) {
+	if commandID == "" {
+		return
+	}
+	api.mu.Lock()
+	defer api.mu.Unlock()
+
+	now := time.Now()
+	for id, at := range api.commands {
+		if now.Sub(at) > commandRetention {
+			delete(api.commands, id)
+		}
+	}
+	api.commands[commandID] = now
 }
 
 // 处理状态查询
@@ -178,15 +266,28 @@ func (api *ServerAPI) handleStatus(w http.ResponseWriter, r *http.Request) {
 			"status": pileStatus,
 			"power":  pile.Power,
 		}
+		if pile.IsDegraded() {
+			pileInfo["degradedPower"] = pile.EffectivePower()
+		}
 
 		if vehicle != nil {
-			pileInfo["currentVehicle"] = map[string]any{
+			vehicleInfo := map[string]any{
+				"sessionId":         vehicle.SessionID,
 				"userId":            vehicle.UserID,
 				"startTime":         vehicle.StartTime,
 				"requestedCapacity": vehicle.RequestedCapacity,
 				"currentCapacity":   vehicle.CurrentCapacity,
 				"remainingTime":     pile.RemainingTime(),
 			}
+			if vehicle.HasSoC() {
+				vehicleInfo["currentSoc"] = vehicle.CurrentSoC
+				vehicleInfo["targetSoc"] = vehicle.TargetSoC
+			}
+			vehicleInfo["currentPower"] = vehicle.CurrentPower
+			if vehicle.Profile != "" {
+				vehicleInfo["profile"] = vehicle.Profile
+			}
+			pileInfo["currentVehicle"] = vehicleInfo
 		}
 
 		status = append(status, pileInfo)
@@ -210,8 +311,53 @@ func (api *ServerAPI) handleStatus(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(response)
 }
 
+// SetChaos 设置网络混沌注入器
+func (api *ServerAPI) SetChaos(chaos *ChaosInjector) {
+	api.mu.Lock()
+	defer api.mu.Unlock()
+	api.chaos = chaos
+}
+
+// withChaos 对携带pileId的指令施加混沌，被丢弃的指令直接断开连接，后端表现为请求失败
+func (api *ServerAPI) withChaos(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		api.mu.Lock()
+		chaos := api.chaos
+		api.mu.Unlock()
+		if chaos == nil || r.Body == nil {
+			next(w, r)
+			return
+		}
+
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, "读取请求失败", http.StatusBadRequest)
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+
+		var target struct {
+			PileID string `json:"pileId"`
+		}
+		json.Unmarshal(body, &target)
+
+		if !chaos.Intercept(target.PileID, r.URL.Path) {
+			if hijacker, ok := w.(http.Hijacker); ok {
+				if conn, _, err := hijacker.Hijack(); err == nil {
+					conn.Close()
+					return
+				}
+			}
+			http.Error(w, "混沌注入: 指令被丢弃", http.StatusServiceUnavailable)
+			return
+		}
+
+		next(w, r)
+	}
+}
+
 // SetOnChargingAssign 设置充电分配回调函数
-func (api *ServerAPI) SetOnChargingAssign(callback func(pileID, userID string, amount float64, mode string) error) {
+func (api *ServerAPI) SetOnChargingAssign(callback func(pileID, sessionID, userID string, amount float64, mode string, battery *models.BatteryState) error) {
 	api.mu.Lock()
 	defer api.mu.Unlock()
 	api.onChargingAssign = callback
@@ -9,6 +9,7 @@ import (
 	"sync"
 
 	"simulator/internal/config"
+	"simulator/internal/services"
 	"simulator/internal/utils"
 )
 
@@ -114,10 +115,18 @@ func (m *Manager) runCLI() {
 			m.showStatus(args)
 		case "fault":
 			m.triggerFault(args)
+		case "degrade":
+			m.triggerDegradation(args)
 		case "recover":
 			m.recoverFault(args)
+		case "depart":
+			m.departVehicle(args)
 		case "sim":
 			m.simulateRequest(args)
+		case "chaos":
+			m.controlChaos(args)
+		case "outbox":
+			m.controlOutbox(args)
 		case "reload":
 			m.reloadConfig()
 		case "exit", "quit", "stop":
@@ -140,10 +149,22 @@ func (m *Manager) printHelp() {
 	fmt.Println("  fault <pileID> <type> <minutes> <desc>")
 	fmt.Println("                          - 触发充电桩故障")
 	fmt.Println("                            type: hardware/software/power")
-	fmt.Println("  recover <pileID>        - 手动恢复故障")
-	fmt.Println("  sim <userID> <amount> <mode>")
+	fmt.Println("  degrade <pileID> <type> <minutes> <kW> [desc]")
+	fmt.Println("                          - 触发降功率运行，充电桩以<kW>继续充电")
+	fmt.Println("                            kW为0时按配置的功率比例降低")
+	fmt.Println("  recover <pileID>        - 手动恢复故障或降功率运行")
+	fmt.Println("  depart <pileID>         - 充电已结束的车辆驶离充电桩")
+	fmt.Println("  sim <userID> <amount> <mode> [profile]")
 	fmt.Println("                          - 模拟充电请求")
 	fmt.Println("                            mode: fast/trickle")
+	fmt.Println("                            profile: 车辆配置名称，默认使用配置中的default")
+	fmt.Println("  chaos [command]         - 网络混沌注入，不带参数显示当前注入")
+	fmt.Println("                            latency <ms> [jitterMs] / drop <percent>")
+	fmt.Println("                            dup <percent> / reorder <percent> [ms]")
+	fmt.Println("                            partition <pileID> <seconds> / heal [pileID]")
+	fmt.Println("                            reset / scenario <file> / log [n]")
+	fmt.Println("  outbox [retry <seq|all> | drop <seq>]")
+	fmt.Println("                          - 查看待投递与失败的上报，重试或丢弃")
 	fmt.Println("  reload                  - 重新加载配置")
 	fmt.Println("  help                    - 显示帮助信息")
 	fmt.Println("  exit                    - 退出程序")
@@ -173,6 +194,15 @@ func (m *Manager) showStatus(args []string) {
 			fmt.Printf("    当前电量: %.1f kWh (%.1f%%)\n",
 				vehicle.CurrentCapacity,
 				vehicle.CurrentCapacity/vehicle.RequestedCapacity*100)
+			if vehicle.HasSoC() {
+				fmt.Printf("    SoC: %.1f%% -> %.1f%% (当前 %.1f%%)\n",
+					vehicle.StartSoC, vehicle.TargetSoC, vehicle.CurrentSoC)
+			}
+			fmt.Printf("    瞬时功率: %.1f kW", vehicle.CurrentPower)
+			if vehicle.Profile != "" {
+				fmt.Printf(" (车辆配置: %s)", vehicle.Profile)
+			}
+			fmt.Println()
 		}
 	} else {
 		// 显示所有充电桩状态
@@ -185,10 +215,20 @@ func (m *Manager) showStatus(args []string) {
 
 			fmt.Printf("充电桩 %s (%s):\n", pile.ID, pile.Type)
 			fmt.Printf("  状态: %s\n", status)
+			if pile.IsDegraded() {
+				fmt.Printf("  降功率运行: %.1f / %.1f kW\n", pile.EffectivePower(), pile.Power)
+			}
+			if pile.IsParked() {
+				fmt.Println("  车辆充电已结束，等待驶离")
+			}
 
 			if vehicle != nil {
 				fmt.Printf("  当前: %s (%.1f/%.1f kWh)\n",
 					vehicle.UserID, vehicle.CurrentCapacity, vehicle.RequestedCapacity)
+				if vehicle.HasSoC() {
+					fmt.Printf("  SoC: %.1f%% / %.1f%%\n", vehicle.CurrentSoC, vehicle.TargetSoC)
+				}
+				fmt.Printf("  功率: %.1f kW\n", vehicle.CurrentPower)
 			}
 			fmt.Println("-------------------------------------")
 		}
@@ -225,6 +265,42 @@ func (m *Manager) triggerFault(args []string) {
 	fmt.Printf("已触发充电桩 %s 的 %s 故障，持续时间: %d分钟\n", pileID, faultType, minutes)
 }
 
+// triggerDegradation 触发降功率运行
+func (m *Manager) triggerDegradation(args []string) {
+	if len(args) < 5 {
+		fmt.Println("用法: degrade <pileID> <type> <minutes> <kW> [description]")
+		fmt.Println("类型: hardware, software, power")
+		return
+	}
+
+	pileID := args[1]
+	faultType := args[2]
+
+	minutes, err := strconv.Atoi(args[3])
+	if err != nil {
+		fmt.Printf("无效的时间格式: %s\n", args[3])
+		return
+	}
+
+	power, err := strconv.ParseFloat(args[4], 64)
+	if err != nil || power < 0 {
+		fmt.Printf("无效的功率: %s\n", args[4])
+		return
+	}
+
+	description := "手动触发降功率运行"
+	if len(args) > 5 {
+		description = strings.Join(args[5:], " ")
+	}
+
+	if err := m.simulator.TriggerDegradation(pileID, faultType, description, power, minutes); err != nil {
+		fmt.Printf("触发降功率运行失败: %v\n", err)
+		return
+	}
+
+	fmt.Printf("已触发充电桩 %s 的 %s 故障降功率运行，持续时间: %d分钟\n", pileID, faultType, minutes)
+}
+
 // recoverFault 恢复故障
 func (m *Manager) recoverFault(args []string) {
 	if len(args) < 2 {
@@ -242,10 +318,27 @@ func (m *Manager) recoverFault(args []string) {
 	fmt.Printf("已恢复充电桩 %s 的故障\n", pileID)
 }
 
+// departVehicle 车辆驶离
+func (m *Manager) departVehicle(args []string) {
+	if len(args) < 2 {
+		fmt.Println("用法: depart <pileID>")
+		return
+	}
+
+	pileID := args[1]
+
+	if err := m.simulator.DepartVehicle(pileID); err != nil {
+		fmt.Printf("车辆驶离失败: %v\n", err)
+		return
+	}
+
+	fmt.Printf("充电桩 %s 的车辆已驶离\n", pileID)
+}
+
 // simulateRequest 模拟充电请求
 func (m *Manager) simulateRequest(args []string) {
 	if len(args) < 4 {
-		fmt.Println("用法: sim <userID> <amount> <mode>")
+		fmt.Println("用法: sim <userID> <amount> <mode> [profile]")
 		fmt.Println("模式: fast, trickle")
 		return
 	}
@@ -264,10 +357,133 @@ func (m *Manager) simulateRequest(args []string) {
 		return
 	}
 
-	m.simulator.SimulateChargingRequest(userID, amount, mode)
+	profile := ""
+	if len(args) > 4 {
+		profile = args[4]
+		if m.config.FindVehicleProfile(profile) == nil {
+			fmt.Printf("车辆配置 %s 不存在\n", profile)
+			return
+		}
+	}
+
+	m.simulator.SimulateChargingRequest(userID, amount, mode, profile)
 	fmt.Printf("已模拟用户 %s 的充电请求: %.1f kWh, 模式: %s\n", userID, amount, mode)
 }
 
+// controlChaos 控制网络混沌注入
+func (m *Manager) controlChaos(args []string) {
+	chaos := m.simulator.Chaos()
+	if len(args) < 2 {
+		settings := chaos.Settings()
+		fmt.Println("网络混沌注入:")
+		fmt.Printf("  延迟: %dms (抖动 %dms)\n", settings.Latency, settings.Jitter)
+		fmt.Printf("  丢弃: %.1f%%\n", settings.DropRate)
+		fmt.Printf("  重复上报: %.1f%%\n", settings.DuplicateRate)
+		fmt.Printf("  乱序上报: %.1f%% (延后 %dms)\n", settings.ReorderRate, settings.ReorderDelay)
+		for pileID, until := range chaos.Partitions() {
+			fmt.Printf("  网络分区: %s 至 %s\n", pileID, until.Format("15:04:05"))
+		}
+		return
+	}
+
+	switch args[1] {
+	case "scenario":
+		if len(args) < 3 {
+			fmt.Println("用法: chaos scenario <file>")
+			return
+		}
+		if err := m.simulator.RunChaosScenario(args[2]); err != nil {
+			fmt.Printf("执行混沌场景失败: %v\n", err)
+			return
+		}
+		fmt.Printf("已开始执行混沌场景 %s\n", args[2])
+	case "log":
+		n := 20
+		if len(args) > 2 {
+			if v, err := strconv.Atoi(args[2]); err == nil {
+				n = v
+			}
+		}
+		for _, event := range chaos.Events(n) {
+			fmt.Printf("  %s %-9s %-4s %s %s\n", event.Time.Local().Format("15:04:05.000"),
+				event.Kind, event.PileID, event.Target, event.Detail)
+		}
+	default:
+		message, err := chaos.Exec(args[1:])
+		if err != nil {
+			fmt.Printf("混沌注入失败: %v\n", err)
+			return
+		}
+		fmt.Println(message)
+	}
+}
+
+// controlOutbox 查看与处理发件箱
+func (m *Manager) controlOutbox(args []string) {
+	outbox := m.simulator.Outbox()
+	if outbox == nil {
+		fmt.Println("发件箱未启用")
+		return
+	}
+
+	if len(args) >= 3 {
+		switch args[1] {
+		case "retry":
+			var seq int64
+			if args[2] != "all" {
+				v, err := strconv.ParseInt(args[2], 10, 64)
+				if err != nil {
+					fmt.Printf("无效的序号: %s\n", args[2])
+					return
+				}
+				seq = v
+			}
+			count, err := outbox.Retry(seq)
+			if err != nil {
+				fmt.Printf("重试失败: %v\n", err)
+				return
+			}
+			fmt.Printf("已重新排队 %d 条上报\n", count)
+		case "drop":
+			seq, err := strconv.ParseInt(args[2], 10, 64)
+			if err != nil {
+				fmt.Printf("无效的序号: %s\n", args[2])
+				return
+			}
+			if err := outbox.Drop(seq); err != nil {
+				fmt.Printf("丢弃失败: %v\n", err)
+				return
+			}
+			fmt.Printf("已丢弃上报 #%d\n", seq)
+		default:
+			fmt.Println("用法: outbox [retry <seq|all> | drop <seq>]")
+		}
+		return
+	}
+
+	entries := outbox.Entries()
+	pending, failed := 0, 0
+	for _, entry := range entries {
+		if entry.Status == services.OutboxStatusFailed {
+			failed++
+		} else {
+			pending++
+		}
+	}
+	fmt.Printf("发件箱: 待投递 %d 条，失败 %d 条\n", pending, failed)
+	fmt.Println("-------------------------------------")
+	for _, entry := range entries {
+		fmt.Printf("#%-5d %-7s %-4s %s 尝试 %d 次", entry.Seq, entry.Status, entry.PileID, entry.Path, entry.Attempts)
+		if entry.Status == services.OutboxStatusPending && entry.Attempts > 0 {
+			fmt.Printf("，下次 %s", entry.NextAttempt.Local().Format("15:04:05"))
+		}
+		fmt.Println()
+		if entry.LastError != "" {
+			fmt.Printf("       最近错误: %s\n", entry.LastError)
+		}
+	}
+}
+
 // reloadConfig 重新加载配置
 func (m *Manager) reloadConfig() {
 	// 停止当前模拟器
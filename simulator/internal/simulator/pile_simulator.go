@@ -1,6 +1,7 @@
 package simulator
 
 import (
+	"errors"
 	"sync"
 	"time"
 
@@ -14,7 +15,10 @@ import (
 type PileSimulator struct {
 	pileService *services.PileService
 	apiClient   *services.APIClient
+	ocppClient  *services.OCPPClient // OCPP模式下替代apiClient与serverAPI
 	serverAPI   *services.ServerAPI
+	chaos       *services.ChaosInjector
+	outbox      *services.Outbox // HTTP模式的持久化发件箱，加载失败时为nil
 	config      *config.Config
 	logger      *utils.Logger
 	isRunning   bool
@@ -24,19 +28,43 @@ type PileSimulator struct {
 
 // NewPileSimulator 创建新的充电桩模拟器
 func NewPileSimulator(cfg *config.Config, logger *utils.Logger) *PileSimulator {
-	// 创建API客户端
+	// 创建API客户端，挂载网络混沌注入
+	chaos := services.NewChaosInjector(cfg, logger)
 	apiClient := services.NewAPIClient(cfg, logger)
+	apiClient.SetChaos(chaos)
+
+	// 按配置选择上报方式：HTTP或OCPP
+	var reporter services.BackendReporter = apiClient
+	var ocppClient *services.OCPPClient
+	var outbox *services.Outbox
+	if cfg.UseOCPP() {
+		ocppClient = services.NewOCPPClient(cfg, logger)
+		reporter = ocppClient
+	} else {
+		// HTTP上报先落盘，由发件箱重试投递
+		var err error
+		outbox, err = services.NewOutbox(cfg, logger)
+		if err != nil {
+			logger.Error("加载发件箱失败，上报将不会重试: %v", err)
+		} else {
+			apiClient.SetOutbox(outbox)
+		}
+	}
 
 	// 创建充电桩服务
-	pileService := services.NewPileService(cfg, apiClient, logger)
+	pileService := services.NewPileService(cfg, reporter, logger)
 
 	// 创建服务器API
 	serverAPI := services.NewServerAPI(cfg, pileService, logger)
+	serverAPI.SetChaos(chaos)
 
 	return &PileSimulator{
 		pileService: pileService,
 		apiClient:   apiClient,
+		ocppClient:  ocppClient,
 		serverAPI:   serverAPI,
+		chaos:       chaos,
+		outbox:      outbox,
 		config:      cfg,
 		logger:      logger,
 		stopCh:      make(chan struct{}),
@@ -55,27 +83,47 @@ func (s *PileSimulator) Start() error {
 	// 初始化充电桩
 	s.pileService.InitializePiles()
 
-	// 启动心跳检测
+	// 启动心跳检测与状态上报
 	s.pileService.StartHeartbeat()
+	s.pileService.StartStatusReport()
 
-	// 设置充电分配回调
-	s.serverAPI.SetOnChargingAssign(s.pileService.AssignVehicle)
+	// 启动发件箱投递，继续投递上次未完成的上报
+	if s.outbox != nil {
+		s.outbox.Start(s.apiClient.Deliver)
+	}
 
-	// 启动服务器
-	s.wg.Add(1)
-	go func() {
-		defer s.wg.Done()
-		port := 8090 // 默认端口
-		if err := s.serverAPI.Start(port); err != nil {
-			s.logger.Error("服务器启动失败: %v", err)
-		}
-	}()
+	if s.ocppClient != nil {
+		// OCPP模式下由中央系统远程启停，不启动HTTP指令接口
+		s.ocppClient.Start(s.pileService)
+	} else {
+		// 设置充电分配回调
+		s.serverAPI.SetOnChargingAssign(s.pileService.AssignVehicle)
+
+		// 启动服务器
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			if err := s.serverAPI.Start(s.config.ServerPort()); err != nil {
+				s.logger.Error("服务器启动失败: %v", err)
+			}
+		}()
+
+		// 向后端注册充电桩，后端据此将指令发往本模拟器
+		s.registerPiles()
+	}
 
 	// 启动自动故障模拟
 	if s.config.Fault.RandomFault {
 		s.startRandomFaultSimulation()
 	}
 
+	// 执行配置的混沌场景
+	if s.config.Chaos.Scenario != "" {
+		if err := s.RunChaosScenario(s.config.Chaos.Scenario); err != nil {
+			s.logger.Error("执行混沌场景失败: %v", err)
+		}
+	}
+
 	return nil
 }
 
@@ -88,17 +136,56 @@ func (s *PileSimulator) Stop() error {
 	s.logger.Info("停止充电桩模拟器")
 	close(s.stopCh)
 
-	// 停止服务器
-	if err := s.serverAPI.Stop(); err != nil {
+	// 断开OCPP连接或停止服务器
+	if s.ocppClient != nil {
+		s.ocppClient.Stop()
+	} else if err := s.serverAPI.Stop(); err != nil {
 		s.logger.Error("停止服务器失败: %v", err)
 	}
 
+	// 停止发件箱投递，未完成的上报保留在文件中
+	if s.outbox != nil {
+		s.outbox.Stop()
+	}
+
 	// 等待所有协程退出
 	s.wg.Wait()
 	s.isRunning = false
 	return nil
 }
 
+// registerPiles 向后端注册充电桩，后端不可达时按指数退避重试
+func (s *PileSimulator) registerPiles() {
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+
+		callbackURL := s.config.CallbackURL()
+		backoff := time.Second
+		for {
+			err := s.apiClient.RegisterPiles(callbackURL, s.pileService.GetAllPiles())
+			if err == nil {
+				s.logger.Info("充电桩已注册到后端，指令下发地址: %s", callbackURL)
+				return
+			}
+
+			var permanent *services.PermanentError
+			if errors.As(err, &permanent) {
+				s.logger.Error("充电桩注册被后端拒绝: %v", err)
+				return
+			}
+			s.logger.Warning("充电桩注册失败，%v后重试: %v", backoff, err)
+
+			select {
+			case <-time.After(backoff):
+			case <-s.stopCh:
+				return
+			}
+			backoff = min(backoff*2, time.Minute)
+		}
+	}()
+}
+
 // startRandomFaultSimulation 启动随机故障模拟
 func (s *PileSimulator) startRandomFaultSimulation() {
 	s.wg.Add(1)
@@ -125,7 +212,8 @@ func (s *PileSimulator) startRandomFaultSimulation() {
 }
 
 // SimulateChargingRequest 模拟充电请求
-func (s *PileSimulator) SimulateChargingRequest(userID string, amount float64, mode string) {
+// profile 为车辆配置名称，为空时使用默认配置
+func (s *PileSimulator) SimulateChargingRequest(userID string, amount float64, mode string, profile string) {
 	// 随机选择一个可用的充电桩
 	piles := s.pileService.GetAllPiles()
 	var availablePiles []*models.Pile
@@ -151,30 +239,38 @@ func (s *PileSimulator) SimulateChargingRequest(userID string, amount float64, m
 	selectedPile := availablePiles[selectedIndex]
 
 	// 分配车辆到充电桩
-	if err := s.pileService.AssignVehicle(selectedPile.ID, userID, amount, mode); err != nil {
+	if err := s.pileService.AssignVehicle(selectedPile.ID, "", userID, amount, mode, &models.BatteryState{Profile: profile}); err != nil {
 		s.logger.Error("分配车辆到充电桩失败: %v", err)
 	}
 }
 
 // TriggerFault 手动触发故障
 func (s *PileSimulator) TriggerFault(pileID string, faultType string, description string, durationMinutes int) error {
-	// 转换故障类型
-	var ft models.FaultType
+	// 故障持续时间
+	duration := time.Duration(durationMinutes) * time.Minute
+
+	return s.pileService.TriggerFault(pileID, parseFaultType(faultType), description, duration)
+}
+
+// TriggerDegradation 手动触发降功率运行，power 为0时按配置的功率比例降低
+func (s *PileSimulator) TriggerDegradation(pileID string, faultType string, description string, power float64, durationMinutes int) error {
+	duration := time.Duration(durationMinutes) * time.Minute
+
+	return s.pileService.TriggerDegradation(pileID, parseFaultType(faultType), description, power, duration)
+}
+
+// parseFaultType 转换故障类型，未知类型按硬件故障处理
+func parseFaultType(faultType string) models.FaultType {
 	switch faultType {
 	case "hardware":
-		ft = models.FaultTypeHardware
+		return models.FaultTypeHardware
 	case "software":
-		ft = models.FaultTypeSoftware
+		return models.FaultTypeSoftware
 	case "power":
-		ft = models.FaultTypePower
+		return models.FaultTypePower
 	default:
-		ft = models.FaultTypeHardware
+		return models.FaultTypeHardware
 	}
-
-	// 故障持续时间
-	duration := time.Duration(durationMinutes) * time.Minute
-
-	return s.pileService.TriggerFault(pileID, ft, description, duration)
 }
 
 // RecoverFault 手动恢复故障
@@ -182,6 +278,11 @@ func (s *PileSimulator) RecoverFault(pileID string) error {
 	return s.pileService.RecoverFault(pileID)
 }
 
+// DepartVehicle 手动让停在充电桩的车辆驶离
+func (s *PileSimulator) DepartVehicle(pileID string) error {
+	return s.pileService.DepartVehicle(pileID)
+}
+
 // GetPileStatus 获取充电桩状态
 func (s *PileSimulator) GetPileStatus(pileID string) (models.PileStatus, *models.ChargingVehicle, error) {
 	pile, err := s.pileService.GetPile(pileID)
@@ -197,3 +298,18 @@ func (s *PileSimulator) GetPileStatus(pileID string) (models.PileStatus, *models
 func (s *PileSimulator) GetAllPiles() []*models.Pile {
 	return s.pileService.GetAllPiles()
 }
+
+// Chaos 获取网络混沌注入器
+func (s *PileSimulator) Chaos() *services.ChaosInjector {
+	return s.chaos
+}
+
+// Outbox 获取发件箱，未启用时为nil
+func (s *PileSimulator) Outbox() *services.Outbox {
+	return s.outbox
+}
+
+// RunChaosScenario 执行混沌场景文件，模拟器停止时中止
+func (s *PileSimulator) RunChaosScenario(path string) error {
+	return s.chaos.RunScenario(path, s.stopCh)
+}